@@ -0,0 +1,231 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "time"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/consistency"
+    "github.com/crackmesone/crackmes.one/app/shared/drift"
+    "github.com/crackmesone/crackmes.one/app/shared/moderation"
+    "github.com/crackmesone/crackmes.one/app/shared/rating"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/shadow"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+    "github.com/josephspurrier/csrfbanana"
+)
+
+// AdminConsistencyGET displays the latest report of the consistency check
+// between the database and the storage
+func AdminConsistencyGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+
+    report, err := model.ConsistencyLatest()
+    if err != nil && err != model.ErrNoResult {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "admin/consistency"
+    if err == nil {
+        v.Vars["report"] = report
+        v.Vars["missing"] = report.Count(consistency.ProblemMissing)
+        v.Vars["orphans"] = report.Count(consistency.ProblemOrphan)
+        v.Vars["mismatches"] = report.Count(consistency.ProblemMismatch)
+        v.Vars["empty"] = report.Count(consistency.ProblemEmpty)
+        v.Vars["unreadable"] = report.Count(consistency.ProblemUnreadable)
+    }
+    v.Vars["running"] = consistency.Running()
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// AdminConsistencyPOST starts a consistency check in the background
+func AdminConsistencyPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+
+    if consistency.Start(model.ConsistencyRun) {
+        sess.AddFlash(view.Flash{"The check has started, reload the page in a moment to see the report.", view.FlashNotice})
+    } else {
+        sess.AddFlash(view.Flash{"A check is already running.", view.FlashWarning})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/admin/consistency", http.StatusFound)
+}
+
+// AdminDriftGET displays the latest report of the drift check of the
+// denormalized fields
+func AdminDriftGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+
+    report, err := model.DriftLatest()
+    if err != nil && err != model.ErrNoResult {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "admin/drift"
+    if err == nil {
+        v.Vars["report"] = report
+        v.Vars["counts"] = []map[string]interface{}{
+            {"Name": "Writeups of the crackmes", "N": report.Count("crackme", "nbsolutions")},
+            {"Name": "Comments of the crackmes", "N": report.Count("crackme", "nbcomments")},
+            {"Name": "Crackme names of the writeups", "N": report.Count("solution", "crackmename")},
+            {"Name": "Crackme names of the comments", "N": report.Count("comment", "crackmename")},
+            {"Name": "Crackmes of the users", "N": report.Count("user", "nbcrackmes")},
+            {"Name": "Writeups of the users", "N": report.Count("user", "nbsolutions")},
+            {"Name": "Comments of the users", "N": report.Count("user", "nbcomments")},
+        }
+    }
+    v.Vars["running"] = drift.Running()
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// AdminDriftPOST starts a drift check in the background, or sets the drifted
+// fields of the latest report to the values of their sources and checks again
+func AdminDriftPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    if r.FormValue("action") == "fix" {
+        report, err := model.DriftLatest()
+        if err != nil {
+            log.Println(err)
+            Error500(w, r)
+            return
+        }
+
+        fixed := 0
+        for _, issue := range report.Issues {
+            if err := model.DriftFix(issue); err != nil {
+                log.Println(err)
+                continue
+            }
+            fixed++
+        }
+        if fixed > 0 {
+            if err := model.AuditAdd(username, model.AuditDriftFix, "", fmt.Sprintf("%d of %d drifted fields", fixed, len(report.Issues)), auditIP(r)); err != nil {
+                log.Println(err)
+            }
+        }
+        if fixed < len(report.Issues) {
+            sess.AddFlash(view.Flash{fmt.Sprintf("%d fields fixed, %d failed, see the logs.", fixed, len(report.Issues)-fixed), view.FlashWarning})
+        } else {
+            sess.AddFlash(view.Flash{fmt.Sprintf("%d fields fixed, checking again.", fixed), view.FlashSuccess})
+        }
+        drift.Start(model.DriftRun)
+    } else if drift.Start(model.DriftRun) {
+        sess.AddFlash(view.Flash{"The check has started, reload the page in a moment to see the report.", view.FlashNotice})
+    } else {
+        sess.AddFlash(view.Flash{"A check is already running.", view.FlashWarning})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/admin/drift", http.StatusFound)
+}
+
+// AdminShadowGET displays how the new implementations of the queries compare
+// with the legacy ones
+func AdminShadowGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "admin/shadow"
+    v.Vars["stats"] = shadow.All()
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// AdminShadowPOST resets the comparisons of the queries
+func AdminShadowPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+
+    shadow.Reset()
+    sess.AddFlash(view.Flash{"The comparisons have been reset.", view.FlashNotice})
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/admin/shadow", http.StatusFound)
+}
+
+// AdminRatingsGET lists the suspicious patterns of the difficulty and quality
+// votes
+func AdminRatingsGET(w http.ResponseWriter, r *http.Request) {
+    votes, err := model.RatingVotes()
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "admin/ratings"
+    v.Vars["anomalies"] = rating.Anomalies(votes)
+    v.Vars["votes"] = len(votes)
+    v.Render(w)
+}
+
+// queueRow is a line of the moderation dashboard
+type queueRow struct {
+    Kind  string
+    Count int
+    Stale int
+    P50   string
+    P90   string
+    Max   string
+}
+
+// AdminModerationGET displays the ages of the submissions waiting for the
+// moderators and the stale ones
+func AdminModerationGET(w http.ResponseWriter, r *http.Request) {
+    items, err := model.ModerationQueue()
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    now := time.Now()
+    maxAge := moderation.ReadConfig().MaxAge
+    queue := moderation.Measure(items, now, time.Duration(maxAge)*time.Hour)
+
+    age := func(d time.Duration, count int) string {
+        if count == 0 {
+            return "-"
+        }
+        return moderation.Hours(d)
+    }
+    rows := make([]queueRow, len(queue.Stats))
+    for i, s := range queue.Stats {
+        rows[i] = queueRow{s.Kind, s.Count, s.Stale, age(s.P50, s.Count), age(s.P90, s.Count), age(s.Max, s.Count)}
+    }
+    stale := make([]map[string]string, len(queue.Stale))
+    authors := make([]string, len(queue.Stale))
+    for i, item := range queue.Stale {
+        stale[i] = map[string]string{"Kind": item.Kind, "HexId": item.HexId, "Title": item.Title, "Author": item.Author, "Age": moderation.Hours(item.Age(now))}
+        authors[i] = item.Author
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "admin/moderation"
+    v.Vars["rows"] = rows
+    v.Vars["stale"] = stale
+    v.Vars["notes"] = modNoteCounts(authors)
+    v.Vars["maxage"] = maxAge
+    v.Render(w)
+}
@@ -0,0 +1,52 @@
+package controller
+
+import (
+    "encoding/json"
+    "fmt"
+    "log"
+    "net/http"
+    "strconv"
+
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+)
+
+// apiJSON writes v as the JSON response
+func apiJSON(w http.ResponseWriter, status int, v interface{}) {
+    js, err := json.Marshal(v)
+    if err != nil {
+        log.Println(err)
+        status = http.StatusInternalServerError
+        js = []byte(`{"error":"Internal Server Error"}`)
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("Cache-Control", "no-store")
+    w.WriteHeader(status)
+    w.Write(js)
+}
+
+// apiError writes an error as the JSON response
+func apiError(w http.ResponseWriter, status int) {
+    apiJSON(w, status, map[string]string{"error": http.StatusText(status)})
+}
+
+// apiUser returns the name of the logged in user, or answers 401 and returns
+// false for anonymous visitors, who would get a redirection from the acl
+// middlewares
+func apiUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+    sess := session.Instance(r)
+    if sess.Values["name"] == nil {
+        apiError(w, http.StatusUnauthorized)
+        return "", false
+    }
+    return fmt.Sprintf("%s", sess.Values["name"]), true
+}
+
+// apiPage returns the page number of the "page" query parameter, 1 by default
+func apiPage(r *http.Request) int {
+    page, err := strconv.Atoi(r.URL.Query().Get("page"))
+    if err != nil || page < 1 {
+        return 1
+    }
+    return page
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/gorilla/context"
+    "github.com/gorilla/sessions"
+    "github.com/julienschmidt/httprouter"
+)
+
+// crackmeArchived is the message of the forms a crackme archived by its
+// author or the moderators doesn't take anymore
+const crackmeArchived = "This crackme is archived, it doesn't take new solutions or comments."
+
+// isModerator tells if the logged in user has the admin role, to show them
+// the moderation forms of a page
+func isModerator(r *http.Request) bool {
+    sess := session.Instance(r)
+    if sess.Values["name"] == nil {
+        return false
+    }
+
+    user, err := repo.UserByName(fmt.Sprintf("%s", sess.Values["name"]))
+    if err != nil {
+        if err != model.ErrNoResult {
+            log.Println(err)
+        }
+        return false
+    }
+    return user.IsAdmin()
+}
+
+// CrackmeArchivePOST archives a crackme of the logged in user, or takes it
+// out of the archive, then goes back to the crackme
+func CrackmeArchivePOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    params := context.Get(r, "params").(httprouter.Params)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+    hexid := params.ByName("hexid")
+
+    archived := r.FormValue("archived") == "on"
+    err := model.CrackmeSetArchived(hexid, username, archived)
+    if err == model.ErrNoResult {
+        sess.AddFlash(view.Flash{"Only the author can archive a crackme.", view.FlashError})
+    } else {
+        archiveFlash(sess, err, archived)
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/crackme/"+hexid, http.StatusFound)
+}
+
+// AdminCrackmeArchivePOST archives any crackme, or takes it out of the
+// archive, for the moderators. The author is notified and the action logged.
+func AdminCrackmeArchivePOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    params := context.Get(r, "params").(httprouter.Params)
+    moderator := fmt.Sprintf("%s", sess.Values["name"])
+
+    crackme, err := model.CrackmeByHexId(params.ByName("hexid"))
+    if err != nil {
+        log.Println(err)
+        Error404(w, r)
+        return
+    }
+
+    archived := r.FormValue("archived") == "on"
+    err = model.CrackmeSetArchived(crackme.HexId, "", archived)
+    archiveFlash(sess, err, archived)
+    if err == nil {
+        action, notification := "archive", "The moderators archived your crackme '" + crackme.Name + "', it doesn't take new solutions or comments."
+        if !archived {
+            action, notification = "unarchive", "The moderators took your crackme '" + crackme.Name + "' out of the archive."
+        }
+        if aerr := model.AuditAdd(moderator, model.AuditCrackmeArchive, crackme.Author, action + " " + crackme.HexId, auditIP(r)); aerr != nil {
+            log.Println(aerr)
+        }
+        if crackme.Author != moderator {
+            if nerr := model.NotificationAdd(crackme.Author, notification); nerr != nil {
+                log.Println(nerr)
+            }
+        }
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/crackme/"+crackme.HexId, http.StatusFound)
+}
+
+// archiveFlash tells the user how archiving a crackme went
+func archiveFlash(sess *sessions.Session, err error, archived bool) {
+    if err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    } else if archived {
+        sess.AddFlash(view.Flash{"The crackme is archived, it is still downloaded but takes no new solution or comment.", view.FlashSuccess})
+    } else {
+        sess.AddFlash(view.Flash{"The crackme takes solutions and comments again.", view.FlashSuccess})
+    }
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/gorilla/context"
+    "github.com/julienschmidt/httprouter"
+)
+
+// AttemptPOST marks the crackme as attempted by the logged in user
+func AttemptPOST(w http.ResponseWriter, r *http.Request) {
+    attemptSet(w, r, true)
+}
+
+// UnattemptPOST makes the logged in user stop attempting the crackme
+func UnattemptPOST(w http.ResponseWriter, r *http.Request) {
+    attemptSet(w, r, false)
+}
+
+// attemptSet adds or removes the attempt of the crackme by the logged in user,
+// then goes back to the crackme
+func attemptSet(w http.ResponseWriter, r *http.Request, attempt bool) {
+    sess := session.Instance(r)
+    var params httprouter.Params
+    params = context.Get(r, "params").(httprouter.Params)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    crackme, err := model.CrackmeByHexId(params.ByName("hexid"))
+    if err != nil {
+        log.Println(err)
+        Error404(w, r)
+        return
+    }
+
+    if attempt {
+        err = model.AttemptCreate(username, crackme)
+    } else {
+        err = model.AttemptDelete(username, crackme.HexId)
+    }
+
+    if err == model.ErrAlreadySolved || err == model.ErrOwnCrackme {
+        sess.AddFlash(view.Flash{err.Error(), view.FlashNotice})
+    } else if err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/crackme/"+crackme.HexId, http.StatusFound)
+}
@@ -0,0 +1,61 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/badge"
+    "github.com/crackmesone/crackmes.one/app/shared/cache"
+
+    "github.com/gorilla/context"
+    "github.com/julienschmidt/httprouter"
+)
+
+// badgeCache keeps the badge of each user for an hour, they are fetched by
+// every view of the READMEs they are in
+var badgeCache = cache.New(time.Hour)
+
+// UserBadgeGET renders the SVG badge of the solves and the rank of a user,
+// from the counters of their profile
+func UserBadgeGET(w http.ResponseWriter, r *http.Request) {
+    var params httprouter.Params
+    params = context.Get(r, "params").(httprouter.Params)
+    name := strings.TrimSuffix(params.ByName("name"), ".svg")
+
+    svg, ok := badgeCache.Get(strings.ToLower(name))
+    if !ok {
+        user, err := model.UserByName(name)
+        if err == model.ErrNoResult || (err == nil && user.Deleted) {
+            Error404(w, r)
+            return
+        } else if err != nil {
+            log.Println(err)
+            Error500(w, r)
+            return
+        }
+
+        rank, err := model.UserSolvesRank(user.NbSolutions)
+        if err != nil {
+            log.Println(err)
+            Error500(w, r)
+            return
+        }
+
+        message, color := "no solves yet", badge.Grey
+        if user.NbSolutions == 1 {
+            message, color = fmt.Sprintf("1 solve, rank #%d", rank), badge.Green
+        } else if user.NbSolutions > 1 {
+            message, color = fmt.Sprintf("%d solves, rank #%d", user.NbSolutions, rank), badge.Green
+        }
+        svg = badge.SVG("crackmes.one", message, color)
+        badgeCache.Set(strings.ToLower(name), svg)
+    }
+
+    w.Header().Set("Content-Type", "image/svg+xml")
+    w.Header().Set("Cache-Control", "public, max-age=3600")
+    w.Write(svg.([]byte))
+}
@@ -0,0 +1,33 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/recaptcha"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+)
+
+// captchaExempt tells if the logged in user is trusted enough to skip the
+// reCAPTCHA of the action
+func captchaExempt(r *http.Request, action string) bool {
+    sess := session.Instance(r)
+    if sess.Values["name"] == nil || recaptcha.ReadConfig().Trusted[action] <= 0 {
+        return false
+    }
+
+    user, err := model.UserByName(fmt.Sprintf("%s", sess.Values["name"]))
+    if err != nil {
+        log.Println(err)
+        return false
+    }
+    return recaptcha.Exempt(action, user.Reputation())
+}
+
+// captchaVerified returns whether the reCAPTCHA of the action was solved, or
+// skipped by a trusted user
+func captchaVerified(r *http.Request, action string) bool {
+    return captchaExempt(r, action) || recaptcha.Verified(r)
+}
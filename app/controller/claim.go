@@ -0,0 +1,142 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "strings"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/gorilla/context"
+    "github.com/josephspurrier/csrfbanana"
+    "github.com/julienschmidt/httprouter"
+    "github.com/kennygrant/sanitize"
+)
+
+// claimCrackme returns the crackme of the hexid parameter, or displays a 404
+// page when it doesn't exist or wasn't imported
+func claimCrackme(w http.ResponseWriter, r *http.Request) (model.Crackme, bool) {
+    params := context.Get(r, "params").(httprouter.Params)
+
+    crackme, err := model.CrackmeByHexId(params.ByName("hexid"))
+    if err != nil || !model.IsClaimable(crackme) {
+        Error404(w, r)
+        return crackme, false
+    }
+    return crackme, true
+}
+
+// ClaimGET displays the form claiming the authorship of an imported crackme
+func ClaimGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    crackme, ok := claimCrackme(w, r)
+    if !ok {
+        return
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "crackme/claim"
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Vars["hexid"] = crackme.HexId
+    v.Vars["name"] = crackme.Name
+    v.Vars["info"] = crackme.Info
+    view.Repopulate([]string{"evidence"}, r.Form, v.Vars)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// ClaimPOST records a claim, decided by the moderators
+func ClaimPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    crackme, ok := claimCrackme(w, r)
+    if !ok {
+        return
+    }
+
+    if validate, missingField := view.Validate(r, []string{"evidence"}); !validate {
+        sess.AddFlash(view.Flash{"Field missing: " + missingField, view.FlashError})
+        sess.Save(r, w)
+        ClaimGET(w, r)
+        return
+    }
+    evidence := strings.TrimSpace(sanitize.HTML(r.FormValue("evidence")))
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    err := model.ClaimCreate(crackme, username, evidence)
+    if err == model.ErrClaimExists {
+        sess.AddFlash(view.Flash{err.Error(), view.FlashError})
+    } else if err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+        sess.Save(r, w)
+        ClaimGET(w, r)
+        return
+    } else {
+        sess.AddFlash(view.Flash{"Your claim has been sent, you will be notified of the decision of the moderators.", view.FlashSuccess})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/crackme/"+crackme.HexId, http.StatusFound)
+}
+
+// AdminClaimsGET lists the pending claims
+func AdminClaimsGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+
+    claims, err := model.ClaimsPending()
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "admin/claims"
+    v.Vars["claims"] = claims
+    users := make([]string, len(claims))
+    for i, c := range claims {
+        users[i] = c.User
+    }
+    v.Vars["notes"] = modNoteCounts(users)
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// AdminClaimsPOST approves or rejects a claim
+func AdminClaimsPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    moderator := fmt.Sprintf("%s", sess.Values["name"])
+    hexid := r.FormValue("claim")
+
+    var err error
+    switch r.FormValue("action") {
+    case "approve":
+        err = model.ClaimApprove(hexid, moderator)
+        if err == nil {
+            sess.AddFlash(view.Flash{"Claim approved, the crackme has been transferred.", view.FlashSuccess})
+        }
+    case "reject":
+        err = model.ClaimReject(hexid, moderator, strings.TrimSpace(sanitize.HTML(r.FormValue("reason"))))
+        if err == nil {
+            sess.AddFlash(view.Flash{"Claim rejected.", view.FlashNotice})
+        }
+    default:
+        sess.AddFlash(view.Flash{"Unknown action.", view.FlashError})
+    }
+
+    if err == model.ErrClaimDecided {
+        sess.AddFlash(view.Flash{err.Error(), view.FlashWarning})
+    } else if err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/admin/claims", http.StatusFound)
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "strings"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/gorilla/sessions"
+    "github.com/josephspurrier/csrfbanana"
+)
+
+// setClock shows the dates to the user the way they prefer, from now on
+func setClock(sess *sessions.Session, user model.User) {
+    if user.Clock == nil {
+        view.SetClock(sess, "", false)
+        return
+    }
+    view.SetClock(sess, user.Clock.Timezone, user.Clock.Relative)
+}
+
+// ClockSettingsGET displays the timezone of the user
+func ClockSettingsGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    user, err := model.UserByName(username)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+    prefs := model.ClockPrefs{}
+    if user.Clock != nil {
+        prefs = *user.Clock
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "user/clock"
+    v.Vars["timezone"] = prefs.Timezone
+    v.Vars["relative"] = prefs.Relative
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// ClockSettingsPOST stores the timezone of the user
+func ClockSettingsPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    prefs := model.ClockPrefs{
+        Timezone: strings.TrimSpace(r.FormValue("timezone")),
+        Relative: r.FormValue("relative") == "on",
+    }
+    if prefs.Timezone == "UTC" {
+        prefs.Timezone = ""
+    }
+
+    if _, err := view.LoadLocation(prefs.Timezone); err != nil {
+        sess.AddFlash(view.Flash{"Unknown timezone, use a name like Europe/Paris.", view.FlashError})
+    } else if err := model.UserSetClock(username, prefs); err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    } else {
+        view.SetClock(sess, prefs.Timezone, prefs.Relative)
+        sess.AddFlash(view.Flash{"Your preferences have been saved.", view.FlashSuccess})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/settings/time", http.StatusFound)
+}
@@ -5,15 +5,22 @@ import (
     "log"
     "net/http"
     "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/contentfilter"
+    "github.com/crackmesone/crackmes.one/app/shared/gate"
     "github.com/crackmesone/crackmes.one/app/shared/recaptcha"
     "github.com/crackmesone/crackmes.one/app/shared/session"
     "github.com/crackmesone/crackmes.one/app/shared/view"
 
-    "github.com/kennygrant/sanitize"
     "github.com/gorilla/context"
+    "github.com/josephspurrier/csrfbanana"
     "github.com/julienschmidt/httprouter"
 )
 
+// commentForm are the rules of the comment form
+var commentForm = []*view.Rule{
+    view.Field("comment").Required(),
+}
+
 func LeaveCommentPOST(w http.ResponseWriter, r *http.Request) {
     // Get session
     sess := session.Instance(r)
@@ -22,15 +29,26 @@ func LeaveCommentPOST(w http.ResponseWriter, r *http.Request) {
     params = context.Get(r, "params").(httprouter.Params)
     crackmehexid := params.ByName("hexid")
 
-    // Validate with required fields
-    if validate, missingField := view.Validate(r, []string{"comment"}); !validate {
-        sess.AddFlash(view.Flash{"Field missing: " + missingField, view.FlashError})
+    if !gateAllowed(w, r, gate.Comment, "/crackme/" + crackmehexid) {
+        return
+    }
+
+    if crackme, err := model.CrackmeByHexId(crackmehexid); err == nil && crackme.Archived {
+        sess.AddFlash(view.Flash{crackmeArchived, view.FlashError})
+        sess.Save(r, w)
+        http.Redirect(w, r, "/crackme/" + crackmehexid, http.StatusFound)
+        return
+    }
+
+    // Validate the fields
+    if ok, problem := view.Check(r, commentForm...); !ok {
+        sess.AddFlash(view.Flash{problem, view.FlashError})
         sess.Save(r, w)
         CrackMeGET(w, r)
         return
     }
 
-    if !recaptcha.Verified(r) {
+    if !captchaVerified(r, recaptcha.ActionComment) {
         sess.AddFlash(view.Flash{"reCAPTCHA invalid!", view.FlashError})
         sess.Save(r, w)
         CrackMeGET(w, r)
@@ -38,11 +56,22 @@ func LeaveCommentPOST(w http.ResponseWriter, r *http.Request) {
     }
 
     username := fmt.Sprintf("%s", sess.Values["name"])
-    comment := r.FormValue("comment")
 
-    comment = sanitize.HTML(comment)
+    // Apply the limits and the policy of the content filter
+    filtered, problem := postedText(r.FormValue("comment"), "comment", maxComment)
+    if problem != "" {
+        sess.AddFlash(view.Flash{problem, view.FlashError})
+        sess.Save(r, w)
+        CrackMeGET(w, r)
+        return
+    }
+    comment := filtered.Text
 
-    err = model.CommentCreate(comment, username, crackmehexid)
+    if filtered.Action == contentfilter.Hold {
+        err = model.CommentCreateHeld(comment, username, crackmehexid, filtered.Reason)
+    } else {
+        err = model.CommentCreate(comment, username, crackmehexid)
+    }
 
     if err != nil {
         log.Println(err)
@@ -52,8 +81,22 @@ func LeaveCommentPOST(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    if filtered.Action == contentfilter.Hold {
+        sess.AddFlash(view.Flash{"Your comment will be shown once a moderator approves it.", view.FlashNotice})
+    } else {
+        commentPublished(crackmehexid, username)
+        sess.AddFlash(view.Flash{"Comment uploaded!", view.FlashSuccess})
+    }
+    sess.Save(r, w)
+    http.Redirect(w, r, "/crackme/" + crackmehexid, http.StatusFound)
+    return
+}
+
+// commentPublished counts a new visible comment on the crackme and notifies
+// its author
+func commentPublished(crackmehexid, username string) {
     // Increment the comment count for this crackme
-    err = model.CrackmeIncrementComments(crackmehexid)
+    err := model.CrackmeIncrementComments(crackmehexid)
     if err != nil {
         log.Println("Failed to increment comment count:", err)
     }
@@ -65,13 +108,98 @@ func LeaveCommentPOST(w http.ResponseWriter, r *http.Request) {
         if err != nil {
             log.Println(err)
         }
-    } else {
+    } else if err != nil {
+        log.Println(err)
+    }
+}
+
+// AdminCommentsGET lists the comments held by the content filter
+func AdminCommentsGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+
+    comments, err := model.CommentsHeld()
+    if err != nil {
         log.Println(err)
+        Error500(w, r)
+        return
     }
 
-    sess.AddFlash(view.Flash{"Comment uploaded!", view.FlashSuccess})
+    // Display the view
+    v := view.New(r)
+    v.Name = "admin/comments"
+    v.Vars["comments"] = comments
+    authors := make([]string, len(comments))
+    for i, c := range comments {
+        authors[i] = c.Author
+    }
+    v.Vars["notes"] = modNoteCounts(authors)
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Render(w)
     sess.Save(r, w)
-    http.Redirect(w, r, "/crackme/" + crackmehexid, http.StatusFound)
-    return
 }
 
+// AdminCommentsPOST publishes or deletes a held comment
+func AdminCommentsPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    hexid := r.FormValue("comment")
+
+    var comment model.Comment
+    var err error
+    switch r.FormValue("action") {
+    case "approve":
+        comment, err = model.CommentApprove(hexid)
+        if err == nil {
+            commentPublished(comment.CrackMeHexId, comment.Author)
+            sess.AddFlash(view.Flash{"Comment published.", view.FlashSuccess})
+        }
+    case "reject":
+        comment, err = model.CommentReject(hexid)
+        if err == nil {
+            if nerr := model.NotificationAdd(comment.Author, "Your comment on '" + comment.CrackmeName + "' has been rejected by the moderators."); nerr != nil {
+                log.Println(nerr)
+            }
+            sess.AddFlash(view.Flash{"Comment deleted.", view.FlashNotice})
+        }
+    default:
+        sess.AddFlash(view.Flash{"Unknown action.", view.FlashError})
+    }
+
+    if err == model.ErrNoResult {
+        sess.AddFlash(view.Flash{"This comment was already moderated.", view.FlashWarning})
+    } else if err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/admin/comments", http.StatusFound)
+}
+
+
+// ReactionPOST adds or removes the thumbs up of the logged in user on a
+// comment and returns, as JSON, the new number of reactions
+func ReactionPOST(w http.ResponseWriter, r *http.Request) {
+    username, ok := apiUser(w, r)
+    if !ok {
+        return
+    }
+    params := context.Get(r, "params").(httprouter.Params)
+
+    reacted, reactions, err := model.ReactionToggle(params.ByName("id"), username)
+    if err == model.ErrNoResult {
+        apiError(w, http.StatusNotFound)
+        return
+    } else if err == model.ErrOwnComment {
+        apiJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+        return
+    } else if err != nil {
+        log.Println(err)
+        apiError(w, http.StatusInternalServerError)
+        return
+    }
+
+    apiJSON(w, http.StatusOK, map[string]interface{}{
+        "reacted":   reacted,
+        "reactions": reactions,
+    })
+}
@@ -1,19 +1,34 @@
 package controller
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"errors"
 	"io/ioutil"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/crackmesone/crackmes.one/app/model"
+	"github.com/crackmesone/crackmes.one/app/shared/archive"
+	"github.com/crackmesone/crackmes.one/app/shared/download"
+	"github.com/crackmesone/crackmes.one/app/shared/exeinfo"
+	"github.com/crackmesone/crackmes.one/app/shared/gate"
+	"github.com/crackmesone/crackmes.one/app/shared/grace"
+	"github.com/crackmesone/crackmes.one/app/shared/markdown"
+	"github.com/crackmesone/crackmes.one/app/shared/preview"
+	"github.com/crackmesone/crackmes.one/app/shared/processing"
 	"github.com/crackmesone/crackmes.one/app/shared/recaptcha"
 	"github.com/crackmesone/crackmes.one/app/shared/session"
+	"github.com/crackmesone/crackmes.one/app/shared/shadow"
+	"github.com/crackmesone/crackmes.one/app/shared/site"
 	"github.com/crackmesone/crackmes.one/app/shared/view"
 
 	"github.com/gorilla/context"
@@ -22,6 +37,19 @@ import (
 	"github.com/kennygrant/sanitize"
 )
 
+// compilerLangs maps the compilers detected by exeinfo to the languages of the
+// upload form
+var compilerLangs = map[string]string{
+    "Go":           "Go",
+    "Rust":         "Rust",
+    ".NET":         ".NET",
+    "Delphi":       "Borland Delphi",
+    "Visual Basic": "(Visual) Basic",
+    "GCC":          "C/C++",
+    "Clang":        "C/C++",
+    "MSVC":         "C/C++",
+}
+
 func CrackMeGET(w http.ResponseWriter, r *http.Request) {
     // Display the view
     sess := session.Instance(r)
@@ -30,30 +58,89 @@ func CrackMeGET(w http.ResponseWriter, r *http.Request) {
     params = context.Get(r, "params").(httprouter.Params)
     hexid := params.ByName("hexid")
 
-    crackme, err := model.CrackmeByHexId(hexid)
+    crackme, err := repo.CrackmeByHexId(hexid)
     if err != nil {
         log.Println(err)
         Error500(w, r)
         return
     }
 
-    solutions, err := model.SolutionsByCrackme(crackme.ObjectId)
+    // The views make the crackme trend, failing to count one doesn't fail
+    // the page
+    if crackme.Visible {
+        if err := repo.CrackmeCountView(crackme.HexId); err != nil {
+            log.Println(err)
+        }
+    }
+
+    // The comments and the solutions are loaded afterwards by the page, from
+    // CrackMeCommentsAPIGET and CrackMeSolutionsAPIGET
+
+    // Followed users who solved it, to make the difficulty more relatable
+    var friendsolvers []string
+    if sess.Values["name"] != nil {
+        friendsolvers, err = repo.FollowedSolvers(fmt.Sprintf("%s", sess.Values["name"]), crackme.ObjectId)
+        if err != nil {
+            log.Println(err)
+        }
+    }
+
+    // The author sees all the hints, the other users the ones they revealed
+    hints, err := repo.HintsByCrackme(crackme.HexId)
     if err != nil {
         log.Println(err)
-        Error500(w, r)
-        return
+    }
+    isAuthor := sess.Values["name"] != nil && fmt.Sprintf("%s", sess.Values["name"]) == crackme.Author
+    revealedHints := hints
+    if !isAuthor {
+        revealed := map[string]bool{}
+        if sess.Values["name"] != nil {
+            revealed, err = repo.HintsRevealed(fmt.Sprintf("%s", sess.Values["name"]), crackme.HexId)
+            if err != nil {
+                log.Println(err)
+            }
+        }
+        revealedHints = nil
+        for _, hint := range hints {
+            if revealed[hint.HexId] {
+                revealedHints = append(revealedHints, hint)
+            }
+        }
     }
 
-    comments, err := model.CommentsByCrackMe(hexid)
+    // In spoiler-free mode the writeups stay hidden until the crackme is solved
+    spoilerfree := spoilerFree(sess)
+    spoilers := spoilerfree && hideSpoilers(sess, crackme)
+
+    // The solution picked by the author is shown above the others
+    var pick *model.Solution
+    if crackme.Pick != "" && !spoilers {
+        solution, err := repo.SolutionByHexId(crackme.Pick)
+        if err != nil {
+            log.Println(err)
+        } else {
+            pick = &solution
+        }
+    }
+
+    // Users attempting it, the logged in one maybe among them
+    attempters, err := repo.CountAttempters(crackme.HexId)
     if err != nil {
         log.Println(err)
-        Error500(w, r)
-        return
+    }
+    attempting := false
+    if sess.Values["name"] != nil && !isAuthor {
+        attempting, err = repo.IsAttempting(fmt.Sprintf("%s", sess.Values["name"]), crackme.HexId)
+        if err != nil {
+            log.Println(err)
+        }
     }
 
     v := view.New(r)
     v.Name = "crackme/read"
+    v.Vars["captchaexempt"] = captchaExempt(r, recaptcha.ActionComment)
     v.Vars["info"] = crackme.Info
+    v.Vars["readme"] = crackme.Readme
     v.Vars["name"] = crackme.Name
     v.Vars["hexid"] = crackme.HexId
     v.Vars["lang"] = crackme.Lang
@@ -61,18 +148,239 @@ func CrackMeGET(w http.ResponseWriter, r *http.Request) {
     v.Vars["createdat"] = crackme.CreatedAt
     v.Vars["username"] = crackme.Author
     v.Vars["platform"] = crackme.Platform
-    v.Vars["solutions"] = solutions
-    v.Vars["comments"] = comments
+    v.Vars["shortid"] = crackme.ShortId
+    v.Vars["binary"] = crackme.Binary
+    v.Vars["files"] = crackme.Files
+    v.Vars["format"] = crackme.Format
+    v.Vars["size"] = crackme.Size
+    v.Vars["sha256"] = crackme.SHA256
+    v.Vars["downloads"] = crackme.Downloads
+    v.Vars["attempters"] = attempters
+    v.Vars["attempting"] = attempting
+    v.Vars["friendsolvers"] = friendsolvers
+    v.Vars["pick"] = pick
+    v.Vars["spoilerfree"] = spoilerfree
+    v.Vars["hidespoilers"] = spoilers
+    v.Vars["hints"] = revealedHints
+    v.Vars["nbhints"] = len(hints)
+    v.Vars["hiddenhints"] = len(hints) - len(revealedHints)
+    v.Vars["isauthor"] = isAuthor
+    v.Vars["unlisted"] = crackme.Visibility == model.VisibilityUnlisted
+    v.Vars["archived"] = crackme.Archived
+    v.Vars["broken"] = crackme.Broken
+    v.Vars["ismoderator"] = !isAuthor && isModerator(r)
+    v.Vars["team"] = crackme.Team
+    v.Vars["claimable"] = model.IsClaimable(crackme)
+    v.Vars["maxhints"] = model.MaxHints
+    v.Vars["version"] = crackme.CurrentVersion()
+    v.Vars["versions"] = crackme.Versions
+    var writeupLangs []model.WriteupLanguage
+    for _, code := range crackme.WriteupLangs {
+        writeupLangs = append(writeupLangs, model.WriteupLanguage{Code: code, Name: model.WriteupLanguageName(code)})
+    }
+    v.Vars["writeuplangs"] = writeupLangs
+    var prereqs []model.Prerequisite
+    for _, code := range crackme.Prereqs {
+        prereqs = append(prereqs, model.Prerequisite{Code: code, Name: model.PrerequisiteName(code)})
+    }
+    v.Vars["prerequisites"] = prereqs
+    v.Vars["license"], _ = model.LicenseByCode(crackme.License)
     v.Vars["nbsolutions"] = crackme.NbSolutions
     v.Vars["nbcomments"] = crackme.NbComments
     v.Vars["difficulty"] = fmt.Sprintf("%.1f", crackme.Difficulty)
     v.Vars["quality"] = fmt.Sprintf("%.1f", crackme.Quality)
     v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    view.Repopulate([]string{"comment", "hint"}, r.Form, v.Vars)
+
+    // The details are the same for all the visitors of a kind and clock
+    // until the crackme changes
+    key := fragmentKey("header", crackme, fmt.Sprint(v.Vars["AuthLevel"]), strconv.FormatBool(isAuthor), v.Vars["clock"].(view.Clock).Key())
+    v.Vars["header"] = cachedFragment(v, "crackme/header", key)
+
     v.Render(w)
     sess.Save(r, w)
 
 }
 
+// apiComment is a comment returned by CrackMeCommentsAPIGET
+type apiComment struct {
+    Id        string    `json:"id"`
+    Author    string    `json:"author"`
+    ByAuthor  bool      `json:"byauthor"`
+    Content   string    `json:"content"`
+    CreatedAt time.Time `json:"created_at"`
+    Reactions int       `json:"reactions"`
+    Reacted   bool      `json:"reacted"` // The logged in user reacted to it
+    Editable  bool      `json:"editable"` // The logged in user wrote it and can still edit or withdraw it
+}
+
+// apiSolution is a solution returned by CrackMeSolutionsAPIGET
+type apiSolution struct {
+    HexId        string                 `json:"hexid"`
+    Author       string                 `json:"author"`
+    CoAuthors    []string               `json:"coauthors,omitempty"`
+    Info         string                 `json:"info"`
+    InfoHTML     string                 `json:"info_html"` // With the fenced code blocks highlighted
+    CreatedAt    time.Time              `json:"created_at"`
+    Download     string                 `json:"download"`
+    HintsUsed    int                    `json:"hintsused"`
+    Sections     *model.WriteupSections `json:"sections,omitempty"`
+    ApproachHTML string                 `json:"approach_html,omitempty"` // The sections which may hold code, rendered like the info
+    PatchHTML    string                 `json:"patch_html,omitempty"`
+    Picked       bool                   `json:"picked"`
+    Version      int                    `json:"version,omitempty"`
+    Language     string                 `json:"language,omitempty"`
+    License      string                 `json:"license,omitempty"`
+    LicenseURL   string                 `json:"license_url,omitempty"`
+}
+
+// apiCrackme returns the crackme of the hexid parameter, or answers 404
+func apiCrackme(w http.ResponseWriter, r *http.Request) (model.Crackme, bool) {
+    params := context.Get(r, "params").(httprouter.Params)
+
+    crackme, err := model.CrackmeByHexId(params.ByName("hexid"))
+    if err != nil {
+        log.Println(err)
+        apiError(w, http.StatusNotFound)
+        return crackme, false
+    }
+    return crackme, true
+}
+
+// CrackMeCommentsAPIGET returns, as JSON, a page of the comments of a crackme
+func CrackMeCommentsAPIGET(w http.ResponseWriter, r *http.Request) {
+    crackme, ok := apiCrackme(w, r)
+    if !ok {
+        return
+    }
+    page := apiPage(r)
+
+    // The pages are shared by all the visitors until the crackme changes
+    var comments []model.Comment
+    key := fragmentKey("comments", crackme, strconv.Itoa(page))
+    if cached, ok := fragmentCache.Get(key); ok {
+        comments = cached.([]model.Comment)
+    } else {
+        var err error
+        comments, err = model.CommentsByCrackMePage(crackme.HexId, page)
+        if err != nil {
+            log.Println(err)
+            apiError(w, http.StatusInternalServerError)
+            return
+        }
+        fragmentCache.Set(key, comments)
+    }
+
+    username := ""
+    if sess := session.Instance(r); sess.Values["name"] != nil {
+        username = fmt.Sprintf("%s", sess.Values["name"])
+    }
+    reacted, err := model.ReactedComments(username, comments)
+    if err != nil {
+        log.Println(err)
+    }
+
+    now := time.Now()
+    result := make([]apiComment, len(comments))
+    for i, c := range comments {
+        result[i] = apiComment{
+            Id:        c.ObjectId.Hex(),
+            Author:    c.Author,
+            ByAuthor:  c.ByAuthor,
+            Content:   c.Content,
+            CreatedAt: c.CreatedAt,
+            Reactions: c.Reactions,
+            Reacted:   reacted[c.ObjectId],
+            Editable:  c.Author == username && grace.Open(c.CreatedAt, now),
+        }
+    }
+
+    apiJSON(w, http.StatusOK, map[string]interface{}{
+        "comments": result,
+        "total":    crackme.NbComments,
+        "page":     page,
+        "next":     len(comments) == model.CommentsPerPage,
+    })
+}
+
+// CrackMeSolutionsAPIGET returns, as JSON, a page of the solutions of a
+// crackme, with their signed download URLs, in the language of the "lang"
+// query parameter if any
+func CrackMeSolutionsAPIGET(w http.ResponseWriter, r *http.Request) {
+    crackme, ok := apiCrackme(w, r)
+    if !ok {
+        return
+    }
+    page := apiPage(r)
+
+    // The download URLs are signed for each request, from the shared pages
+    lang := r.URL.Query().Get("lang")
+    var solutions []model.Solution
+    key := fragmentKey("solutions", crackme, lang, strconv.Itoa(page))
+    if cached, ok := fragmentCache.Get(key); ok {
+        solutions = cached.([]model.Solution)
+    } else {
+        var err error
+        solutions, err = model.SolutionsByCrackmePage(crackme.ObjectId, lang, page)
+        if err != nil {
+            log.Println(err)
+            apiError(w, http.StatusInternalServerError)
+            return
+        }
+        fragmentCache.Set(key, solutions)
+    }
+
+    result := make([]apiSolution, len(solutions))
+    for i, s := range solutions {
+        result[i] = apiSolution{HexId: s.HexId, Author: s.Author, CoAuthors: s.CoAuthors, Info: s.Info, CreatedAt: s.CreatedAt, Download: site.Path(download.URL(download.KindSolution, s.HexId)), HintsUsed: s.HintsUsed, Sections: s.Sections, Picked: s.HexId == crackme.Pick, Version: s.CrackmeVersion, Language: model.WriteupLanguageName(s.Language)}
+        if license, ok := model.LicenseByCode(s.License); ok {
+            result[i].License, result[i].LicenseURL = license.Name, license.URL
+        }
+        result[i].InfoHTML = string(markdown.Text(s.Info))
+        if s.Sections != nil {
+            result[i].ApproachHTML = string(markdown.Text(s.Sections.Approach))
+            result[i].PatchHTML = string(markdown.Text(s.Sections.Patch))
+        }
+    }
+
+    apiJSON(w, http.StatusOK, map[string]interface{}{
+        "solutions": result,
+        "total":     crackme.NbSolutions,
+        "page":      page,
+        "next":      len(solutions) == model.SolutionsPerPage,
+    })
+}
+
+// CrackMeRatingsAPIGET returns, as JSON, the number of difficulty and quality
+// votes of each level on a crackme, from 1 to 6, with their averages
+func CrackMeRatingsAPIGET(w http.ResponseWriter, r *http.Request) {
+    crackme, ok := apiCrackme(w, r)
+    if !ok {
+        return
+    }
+
+    difficulty, err := model.RatingDifficultyDistribution(crackme.HexId)
+    if err != nil {
+        log.Println(err)
+        apiError(w, http.StatusInternalServerError)
+        return
+    }
+
+    quality, err := model.RatingQualityDistribution(crackme.HexId)
+    if err != nil {
+        log.Println(err)
+        apiError(w, http.StatusInternalServerError)
+        return
+    }
+
+    apiJSON(w, http.StatusOK, map[string]interface{}{
+        "difficulty":         difficulty,
+        "quality":            quality,
+        "difficulty_average": crackme.Difficulty,
+        "quality_average":    crackme.Quality,
+    })
+}
+
 func LastCrackMesGET(w http.ResponseWriter, r *http.Request) {
     // Display the view
     var params httprouter.Params
@@ -88,19 +396,38 @@ func LastCrackMesGET(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    crackmes, err := model.LastCrackMes(pageint)
+    viewer := listingViewer(r)
+    start := time.Now()
+    crackmes, err := repo.LastCrackMes(pageint, viewer)
     if err != nil {
         log.Println(err)
         Error500(w, r)
         return
     }
 
+    // Compare this query with the aggregation meant to replace it
+    if shadow.Sample() {
+        legacy := crackmes
+        if legacy == nil {
+            legacy = []model.Crackme{}
+        }
+        shadow.Compare("lasts", legacy, time.Since(start), func() (interface{}, error) {
+            return repo.LastCrackMesAggregate(pageint, viewer)
+        })
+    }
+
     // NbComments and NbSolutions for each crackme are stored in the database
     // and are retrieved directly with the crackme documents (no need to count)
 
+    if renderCrackmesExport(w, r, "crackmes-"+page, crackmes) {
+        return
+    }
+
     v := view.New(r)
     v.Name = "crackme/lasts"
     v.Vars["crackmes"] = crackmes
+    v.Vars["marks"] = listingMarks(viewer, crackmes)
+    v.Vars["filtered"] = viewer.Prefs.Active()
 
     if pageint == 1 {
         v.Vars["prec"] = 1
@@ -111,54 +438,267 @@ func LastCrackMesGET(w http.ResponseWriter, r *http.Request) {
     v.Render(w)
 }
 
+// uploadFiles lists the files of an upload for the solvers, a lone file lists
+// itself. Archives with encrypted file names can't be listed.
+func uploadFiles(filename string, data []byte) []archive.Entry {
+    files, err := archive.List(data)
+    if err == archive.ErrUnknownFormat {
+        return []archive.Entry{{Name: filename, Size: int64(len(data))}}
+    } else if err != nil {
+        log.Println(err)
+    }
+    return files
+}
+
+// maxUploadFiles is the maximum number of files uploaded together
+const maxUploadFiles = 10
+
+// uploadBundle returns the name, the data and the files of an upload. The
+// readme and the runtime dependencies sent with the file are bundled with it
+// in a zip, so the moderators approve them and the solvers download them
+// together. The files of a lone upload are left to uploadFiles, nil is
+// returned. The errors are meant for the uploader.
+func uploadBundle(r *http.Request, filename string, data []byte) (string, []byte, []archive.Entry, error) {
+    var readmes, dependencies []*multipart.FileHeader
+    if r.MultipartForm != nil {
+        readmes = r.MultipartForm.File["readme"]
+        dependencies = r.MultipartForm.File["dependency"]
+    }
+    if len(readmes) == 0 && len(dependencies) == 0 {
+        return filename, data, nil, nil
+    }
+    if len(readmes) > 1 || 1+len(readmes)+len(dependencies) > maxUploadFiles {
+        return "", nil, nil, fmt.Errorf("Please upload one readme and at most %d files in all.", maxUploadFiles)
+    }
+
+    files := []archive.File{{Name: filename, Role: archive.RoleBinary, Data: data}}
+    for i, header := range append(readmes, dependencies...) {
+        role := archive.RoleDependency
+        if i < len(readmes) {
+            role = archive.RoleReadme
+        }
+        f, err := header.Open()
+        if err != nil {
+            return "", nil, nil, err
+        }
+        content, err := ioutil.ReadAll(f)
+        f.Close()
+        if err != nil {
+            return "", nil, nil, err
+        }
+        files = append(files, archive.File{Name: sanitize.Name(filepath.Base(header.Filename)), Role: role, Data: content})
+    }
+
+    bundle, entries, err := archive.Bundle(files)
+    if err == archive.ErrDuplicateName {
+        return "", nil, nil, errors.New("Two of the uploaded files have the same name.")
+    } else if err != nil {
+        return "", nil, nil, err
+    }
+    return strings.TrimSuffix(filename, filepath.Ext(filename)) + ".zip", bundle, entries, nil
+}
+
+// processCrackme extracts the metadata of a crackme upload stored at path in
+// the processing worker, files are those of its bundle if any
+func processCrackme(hexid, path, filename string, files []archive.Entry) {
+    processing.Queue(func() error {
+        data, err := ioutil.ReadFile(path)
+        if err != nil {
+            return err
+        }
+        if files == nil {
+            files = uploadFiles(filename, data)
+        }
+        return model.CrackmeSetProcessed(hexid, model.UploadMetadata{Files: files, Readme: uploadReadme(data, files)})
+    }, func(err error) {
+        if err == nil {
+            return
+        }
+        log.Println("Processing of crackme", hexid+":", err)
+        if err := model.CrackmeSetProcessingFailed(hexid); err != nil {
+            log.Println(err)
+        }
+    })
+}
+
+// UploadStatusAPIGET returns the processing status of a crackme upload to its
+// author, for the upload page to poll
+func UploadStatusAPIGET(w http.ResponseWriter, r *http.Request) {
+    username, ok := apiUser(w, r)
+    if !ok {
+        return
+    }
+    params := context.Get(r, "params").(httprouter.Params)
+
+    crackme, err := model.CrackmeUploadByHexId(params.ByName("hexid"))
+    if err == model.ErrNoResult || (err == nil && crackme.Author != username) {
+        apiError(w, http.StatusNotFound)
+        return
+    } else if err != nil {
+        log.Println(err)
+        apiError(w, http.StatusInternalServerError)
+        return
+    }
+
+    apiJSON(w, http.StatusOK, apiUploadStatus{
+        HexId:    crackme.HexId,
+        Status:   crackme.ProcessingStatus(),
+        Approved: crackme.Visible,
+        Files:    crackme.Files,
+    })
+}
+
+// apiUploadStatus is the status returned by UploadStatusAPIGET
+type apiUploadStatus struct {
+    HexId    string          `json:"hexid"`
+    Status   string          `json:"processing_status"`
+    Approved bool            `json:"approved"`
+    Files    []archive.Entry `json:"files,omitempty"`
+}
+
+// uploadReadme returns the text of the readme of an upload, without its HTML
+func uploadReadme(data []byte, files []archive.Entry) string {
+    return strings.TrimSpace(sanitize.HTML(archive.Readme(data, files)))
+}
+
+// uploadFormat returns the format of an uploaded file: its archive format,
+// else its executable format, else "file"
+func uploadFormat(data []byte, binary *exeinfo.Info) string {
+    if format := archive.Format(data); format != "" {
+        return format
+    }
+    if binary != nil {
+        return binary.Format
+    }
+    return "file"
+}
+
+// sha256Hex returns the hex encoded SHA-256 of data
+func sha256Hex(data []byte) string {
+    sum := sha256.Sum256(data)
+    return hex.EncodeToString(sum[:])
+}
+
 func UploadCrackMeGET(w http.ResponseWriter, r *http.Request) {
     // Get session
     sess := session.Instance(r)
 
+    if !gateAllowed(w, r, gate.Crackme, "/") {
+        return
+    }
+
     // Display the view
     v := view.New(r)
     v.Name = "crackme/create"
+    v.Vars["captchaexempt"] = captchaExempt(r, recaptcha.ActionCrackme)
     v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Vars["licenses"] = model.Licenses
+    v.Vars["teams"] = userTeams(fmt.Sprintf("%s", sess.Values["name"]))
+    v.Vars["prerequisites"] = model.Prerequisites
+    v.Vars["maxprerequisites"] = model.MaxPrerequisites
+    v.Vars["idempotencykey"] = idempotencyFormKey(v.Vars)
+    v.Vars["langs"] = crackmeLangs
+    v.Vars["archs"] = crackmeArchs
+    v.Vars["platforms"] = crackmePlatforms
+    view.Repopulate(draftFields["crackme"], draftForm(r, "crackme", fmt.Sprintf("%s", sess.Values["name"]), ""), v.Vars)
     v.Render(w)
     sess.Save(r, w)
 }
 
+// crackmeForm are the rules of the crackme upload form, the file and the
+// choices of the model are checked by the handler
+var crackmeForm = []*view.Rule{
+    view.Field("name").Required().MaxLen(64),
+    view.Field("difficulty").Required().Range(1, 6),
+    view.Field("lang").Label("language").Required().OneOf(crackmeLangs),
+    view.Field("arch").Label("architecture").Required().OneOf(crackmeArchs),
+    view.Field("platform").Required().OneOf(crackmePlatforms),
+    view.Field("info").Required(),
+}
+
 // NotepadCreatePOST handles the note creation form submission
 func UploadCrackMePOST(w http.ResponseWriter, r *http.Request) {
     // Get session
     sess := session.Instance(r)
 
-    // Validate with required fields
-    if validate, missingField := view.Validate(r, []string{"name", "info", "lang", "difficulty", "platform", "arch"}); !validate {
-        sess.AddFlash(view.Flash{"Field missing: " + missingField, view.FlashError})
+    if !gateAllowed(w, r, gate.Crackme, "/") {
+        return
+    }
+
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    // A retry of an upload that went through doesn't upload it twice
+    upload, replayed := idempotencyBegin(w, r, "crackme", username, "Crackme uploaded! Should be available soon.")
+    if replayed {
+        return
+    }
+    defer upload.end()
+
+    // Keep the fields, should the upload fail
+    if err := draftSave(r, "crackme", username, ""); err != nil {
+        log.Println(err)
+    }
+
+    // Validate the fields
+    if ok, problem := view.Check(r, crackmeForm...); !ok {
+        sess.AddFlash(view.Flash{problem, view.FlashError})
         sess.Save(r, w)
         UploadCrackMeGET(w, r)
         return
     }
 
-    username := fmt.Sprintf("%s", sess.Values["name"])
     name := r.FormValue("name")
     lang := r.FormValue("lang")
     arch := r.FormValue("arch")
     difficulty := r.FormValue("difficulty")
     info := r.FormValue("info")
     platform := r.FormValue("platform")
+    license := r.FormValue("license")
     file, header, err := r.FormFile("file")
+    prereqs := model.ValidPrerequisites(r.Form["prerequisite"])
 
     name = sanitize.HTML(name)
     lang = sanitize.HTML(lang)
     arch = sanitize.HTML(arch)
-    info = sanitize.HTML(info)
 
-    diffint, _ := strconv.Atoi(difficulty)
-    if diffint > 6 || diffint < 1 {
-        sess.AddFlash(view.Flash{"Wrong difficulty", view.FlashError})
+    // The crackme waits for approval, a held description is seen by the
+    // moderators anyway
+    filtered, problem := postedText(info, "description", maxInfo)
+    if problem != "" {
+        sess.AddFlash(view.Flash{problem, view.FlashError})
         sess.Save(r, w)
         UploadCrackMeGET(w, r)
         return
     }
+    info = filtered.Text
 
-    if !recaptcha.Verified(r) {
+    if _, ok := model.LicenseByCode(license); !ok {
+        sess.AddFlash(view.Flash{"Please choose a license for your crackme.", view.FlashError})
+        sess.Save(r, w)
+        UploadCrackMeGET(w, r)
+        return
+    }
+
+    visibility, ok := model.ParseVisibility(r.FormValue("visibility"))
+    if !ok {
+        sess.AddFlash(view.Flash{"Wrong visibility", view.FlashError})
+        sess.Save(r, w)
+        UploadCrackMeGET(w, r)
+        return
+    }
+
+    team, ok := postingTeam(r, username)
+    if !ok {
+        sess.AddFlash(view.Flash{"You can only post for the teams you are a member of.", view.FlashError})
+        sess.Save(r, w)
+        UploadCrackMeGET(w, r)
+        return
+    }
+
+    // In range, see crackmeForm
+    diffint, _ := strconv.Atoi(difficulty)
+
+    if !captchaVerified(r, recaptcha.ActionCrackme) {
         sess.AddFlash(view.Flash{"reCAPTCHA invalid!", view.FlashError})
         sess.Save(r, w)
         UploadCrackMeGET(w, r)
@@ -183,19 +723,60 @@ func UploadCrackMePOST(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    if len(data) > 5000000 {
+    if int64(len(data)) > maxUploadSize() {
         sess.AddFlash(view.Flash{"This file is too large !", view.FlashError})
         sess.Save(r, w)
         UploadCrackMeGET(w, r)
         return
     }
 
+    // Read the headers of the uploaded executable to fill in or check what
+    // the author declared
+    binary, err := exeinfo.Analyze(header.Filename, data)
+    if err == nil {
+        if arch == exeinfo.ArchOther {
+            arch = binary.Arch
+        }
+        if platform == "Unspecified/other" {
+            platform = binary.Platform
+        }
+        if lang == "Unspecified/other" && compilerLangs[binary.Compiler] != "" {
+            lang = compilerLangs[binary.Compiler]
+        }
+
+        // Archives with several executables may target several architectures
+        if binary.Executables == 1 && (!binary.MatchesArch(arch) || !binary.MatchesPlatform(platform)) {
+            sess.AddFlash(view.Flash{"The uploaded file is a " + binary.Arch + " " + binary.Format + " executable, please check the architecture and platform.", view.FlashError})
+            sess.Save(r, w)
+            UploadCrackMeGET(w, r)
+            return
+        }
+    } else {
+        binary = nil
+    }
+
+    // The readme and the runtime dependencies are stored and downloaded
+    // along with the crackme, in a zip
+    filename, data, files, err := uploadBundle(r, sanitize.Name(filepath.Base(header.Filename)), data)
+    if err != nil {
+        sess.AddFlash(view.Flash{err.Error(), view.FlashError})
+        sess.Save(r, w)
+        UploadCrackMeGET(w, r)
+        return
+    }
+    if int64(len(data)) > maxUploadSize() {
+        sess.AddFlash(view.Flash{"These files are too large !", view.FlashError})
+        sess.Save(r, w)
+        UploadCrackMeGET(w, r)
+        return
+    }
+
     // Check for duplicate pending submission (visible=false) with same name from same user
     // This prevents orphaned duplicate entries when users retry failed uploads
     _, err = model.CrackmeByUserAndName(username, name, false)
     if err == nil {
         // Found existing pending submission with same name
-        sess.AddFlash(view.Flash{"You already have a pending crackme with this name. Please wait for review or choose a different name.", view.FlashError})
+        sess.AddFlash(view.Flash{model.ErrCrackmeExists.Error(), view.FlashError})
         sess.Save(r, w)
         UploadCrackMeGET(w, r)
         return
@@ -209,14 +790,17 @@ func UploadCrackMePOST(w http.ResponseWriter, r *http.Request) {
         Error500(w, r)
         return
     }
-
-    filename := header.Filename
-
-    // Sanitize the filename
-    filename = filepath.Base(filename)
-
-    // Remove unsafe characters (use a sanitization library or do custom filtering)
-    filename = sanitize.Name(filename)
+    crackme.Binary = binary
+    crackme.License = license
+    crackme.Visibility = visibility
+    crackme.Team = team
+    crackme.Prereqs = prereqs
+    crackme.Format = uploadFormat(data, binary)
+    crackme.Processing = model.ProcessingPending
+
+    // The first version, later ones are uploaded from UploadVersionPOST
+    crackme.Version = 1
+    crackme.Versions = []model.CrackmeVersion{{Number: 1, SHA256: sha256Hex(data), Size: int64(len(data)), Format: crackme.Format, CreatedAt: crackme.CreatedAt}}
 
     // Join the path securely
     safePath := filepath.Join("tmp/crackme", username+"+++"+crackme.HexId+"+++"+filename)
@@ -242,7 +826,14 @@ func UploadCrackMePOST(w http.ResponseWriter, r *http.Request) {
 
     // Now insert the crackme into the database
     err = model.CrackmeInsert(crackme)
-    if err != nil {
+    if err == model.ErrCrackmeExists {
+        // A concurrent upload passed the check above first
+        os.Remove(safePath)
+        sess.AddFlash(view.Flash{err.Error(), view.FlashError})
+        sess.Save(r, w)
+        UploadCrackMeGET(w, r)
+        return
+    } else if err != nil {
         log.Println("Database insert error:", err)
         // Cleanup: remove the file we just wrote
         os.Remove(safePath)
@@ -285,12 +876,25 @@ func UploadCrackMePOST(w http.ResponseWriter, r *http.Request) {
         // Non-critical, continue
     }
 
+    // List the files and read the readme in the background, the uploader
+    // follows it from UploadStatusAPIGET
+    processCrackme(crackme.HexId, safePath, filename, files)
+
+    // Summarize the upload for the moderators in the background
+    preview.Queue(safePath, func(p *preview.Preview) {
+        if err := model.CrackmeSetPreview(crackme.HexId, p); err != nil {
+            log.Println(err)
+        }
+    })
+
     // Send notification (failure here is not critical)
     notifErr := model.NotificationAdd(username, "Crackme '" + crackme.Name + "' added, waiting for approval!")
     if notifErr != nil {
         log.Println(notifErr)
     }
 
+    upload.hexid = crackme.HexId
+    draftDelete("crackme", username, "")
     sess.AddFlash(view.Flash{"Crackme uploaded! Should be available soon.", view.FlashSuccess})
     sess.Save(r, w)
     http.Redirect(w, r, "/user/"+username, http.StatusFound)
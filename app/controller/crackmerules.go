@@ -7,6 +7,10 @@ import (
 )
 
 func CrackmeRulesGET(w http.ResponseWriter, r *http.Request) {
+	if renderPage(w, r, "crackme-rules") {
+		return
+	}
+
 	v := view.New(r)
 	v.Name = "rules/crackmerules"
 	v.Render(w)
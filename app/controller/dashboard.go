@@ -0,0 +1,66 @@
+package controller
+
+import (
+    "time"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+)
+
+// dashboardShown is the number of new crackmes and attempts on the dashboard
+const dashboardShown = 5
+
+// dashboard is what the home page shows a logged in user in place of the
+// counters of the site
+type dashboard struct {
+    Crackmes  []model.Crackme  // Their crackmes waiting for approval
+    Solutions []model.Solution // Their writeups waiting for approval
+    Unseen    int              // Notifications not seen yet
+    New       []model.Crackme  // Latest crackmes passing their listing preferences, not solved nor attempted
+    Attempts  []model.Attempt  // Crackmes they recently started, to go back to
+}
+
+// userDashboard returns the dashboard of a user
+func userDashboard(viewer model.Viewer) (dashboard, error) {
+    var d dashboard
+    var err error
+
+    // All the pending submissions, not only those of the grace period
+    if d.Crackmes, err = repo.CrackmesPendingByUser(viewer.Name, time.Time{}); err != nil {
+        return d, err
+    }
+    if d.Solutions, err = repo.SolutionsPendingByUser(viewer.Name, time.Time{}); err != nil {
+        return d, err
+    }
+    unseen, err := repo.NotificationsCountUnseen(viewer.Name)
+    if err != nil {
+        return d, err
+    }
+    d.Unseen = int(unseen)
+
+    attempts, err := repo.AttemptsByUser(viewer.Name)
+    if err != nil {
+        return d, err
+    }
+    active := time.Now().AddDate(0, 0, -model.AttemptActiveDays)
+    for _, a := range attempts {
+        if a.CreatedAt.After(active) && len(d.Attempts) < dashboardShown {
+            d.Attempts = append(d.Attempts, a)
+        }
+    }
+
+    latest, err := repo.LastCrackMes(1, viewer)
+    if err != nil {
+        return d, err
+    }
+    marks, err := repo.ListingMarks(viewer.Name, latest)
+    if err != nil {
+        return d, err
+    }
+    for _, c := range latest {
+        if c.Author != viewer.Name && marks[c.HexId] == "" && len(d.New) < dashboardShown {
+            d.New = append(d.New, c)
+        }
+    }
+
+    return d, nil
+}
@@ -2,17 +2,25 @@ package controller
 
 import (
     "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/gate"
+    "github.com/crackmesone/crackmes.one/app/shared/rating"
     "github.com/crackmesone/crackmes.one/app/shared/session"
     "github.com/crackmesone/crackmes.one/app/shared/view"
     "fmt"
     "log"
     "net/http"
     "strconv"
+    "time"
 
     "github.com/gorilla/context"
     "github.com/julienschmidt/httprouter"
 )
 
+// difficultyForm are the rules of the difficulty vote
+var difficultyForm = []*view.Rule{
+    view.Field("difficulty").Label("difficulty rating").Required().Range(1, 6),
+}
+
 func RateDifficultyPOST(w http.ResponseWriter, r *http.Request) {
     // Get session
     var already_exist bool
@@ -22,9 +30,9 @@ func RateDifficultyPOST(w http.ResponseWriter, r *http.Request) {
     params = context.Get(r, "params").(httprouter.Params)
     crackmehexid := params.ByName("hexid")
 
-    // Validate with required fields
-    if validate, missingField := view.Validate(r, []string{"difficulty"}); !validate {
-        sess.AddFlash(view.Flash{"Field missing: " + missingField, view.FlashError})
+    // Validate the fields
+    if ok, problem := view.Check(r, difficultyForm...); !ok {
+        sess.AddFlash(view.Flash{problem, view.FlashError})
         sess.Save(r, w)
         CrackMeGET(w, r)
         return
@@ -33,11 +41,10 @@ func RateDifficultyPOST(w http.ResponseWriter, r *http.Request) {
     username := fmt.Sprintf("%s", sess.Values["name"])
     rating := r.FormValue("difficulty")
 
+    // In range, see difficultyForm
     ratingint, _ := strconv.Atoi(rating)
 
-    if ratingint < 1 || ratingint > 6 {
-        log.Println("Wrong rating number")
-        Error500(w, r)
+    if !ratingAllowed(w, r, username, crackmehexid) {
         return
     }
 
@@ -78,3 +85,42 @@ func RateDifficultyPOST(w http.ResponseWriter, r *http.Request) {
     http.Redirect(w, r, "/crackme/" + crackmehexid, http.StatusFound)
     return
 }
+
+// ratingProblem returns why the user may not rate crackmes, empty if they may
+func ratingProblem(user model.User) string {
+    // The _id of the users holds their creation time
+    activity := user.NbCrackmes + user.NbSolutions + user.NbComments
+    switch rating.Allowed(user.ObjectId.Timestamp(), activity, time.Now()) {
+    case rating.ErrTooYoung:
+        return fmt.Sprintf("Your account must be at least %d days old to rate crackmes.", rating.ReadConfig().MinAccountDays)
+    case rating.ErrInactive:
+        return "Upload a crackme, a writeup or a comment before rating crackmes."
+    }
+    if err := gateCheck(user, gate.Rate); err != nil {
+        return err.Error()
+    }
+    return ""
+}
+
+// ratingAllowed returns true if the user may rate crackmes, else it explains
+// why not and goes back to the crackme
+func ratingAllowed(w http.ResponseWriter, r *http.Request, username, crackmehexid string) bool {
+    sess := session.Instance(r)
+
+    user, err := model.UserByName(username)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return false
+    }
+
+    problem := ratingProblem(user)
+    if problem == "" {
+        return true
+    }
+
+    sess.AddFlash(view.Flash{problem, view.FlashError})
+    sess.Save(r, w)
+    http.Redirect(w, r, "/crackme/" + crackmehexid, http.StatusFound)
+    return false
+}
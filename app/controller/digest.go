@@ -0,0 +1,108 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/digest"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/josephspurrier/csrfbanana"
+)
+
+// crackmeLangs, crackmeArchs and crackmePlatforms are the choices of the
+// upload form
+var (
+    crackmeLangs     = []string{"C/C++", "Assembler", "Java", "Go", "Rust", "WebAssembly", "(Visual) Basic", "Borland Delphi", "Turbo Pascal", ".NET", "Unspecified/other"}
+    crackmeArchs     = []string{"x86", "x86-64", "java", "ARM", "MIPS", "RISC-V", "other"}
+    crackmePlatforms = []string{"Mac OS X", "Multiplatform", "Unix/linux etc.", "Windows", "Android", "iOS", "Unspecified/other"}
+)
+
+// choice is a checkbox of the settings
+type choice struct {
+    Value   string
+    Checked bool
+}
+
+// choices returns the checkboxes of values, checked if selected
+func choices(values, selected []string) []choice {
+    choices := make([]choice, len(values))
+    for i, value := range values {
+        choices[i].Value = value
+        for _, s := range selected {
+            choices[i].Checked = choices[i].Checked || s == value
+        }
+    }
+    return choices
+}
+
+// checked returns the values of the checked choices
+func checked(choices []choice) []string {
+    var values []string
+    for _, c := range choices {
+        if c.Checked {
+            values = append(values, c.Value)
+        }
+    }
+    return values
+}
+
+// DigestSettingsGET displays the settings of the weekly digest of the user
+func DigestSettingsGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    user, err := model.UserByName(username)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+    prefs := model.DigestPrefs{}
+    if user.Digest != nil {
+        prefs = *user.Digest
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "user/digest"
+    v.Vars["enabled"] = prefs.Enabled
+    v.Vars["available"] = digest.ReadConfig().Enabled
+    v.Vars["langs"] = choices(crackmeLangs, prefs.Langs)
+    v.Vars["archs"] = choices(crackmeArchs, prefs.Archs)
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// DigestSettingsPOST stores the settings of the weekly digest of the user
+func DigestSettingsPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    if err := r.ParseForm(); err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+    enabled := r.FormValue("enabled") == "on"
+
+    // Only the known choices are kept
+    langs := checked(choices(crackmeLangs, r.Form["lang"]))
+    archs := checked(choices(crackmeArchs, r.Form["arch"]))
+
+    if err := model.DigestSetPrefs(username, enabled, langs, archs); err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    } else if enabled {
+        sess.AddFlash(view.Flash{"You will receive the weekly digest.", view.FlashSuccess})
+    } else {
+        sess.AddFlash(view.Flash{"You won't receive the weekly digest anymore.", view.FlashNotice})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/settings/digest", http.StatusFound)
+}
@@ -0,0 +1,135 @@
+package controller
+
+import (
+    "log"
+    "net/http"
+    "sort"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/clientip"
+    "github.com/crackmesone/crackmes.one/app/shared/download"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/gorilla/context"
+    "github.com/julienschmidt/httprouter"
+    "github.com/kennygrant/sanitize"
+)
+
+// DownloadGET serves the file of a crackme or a solution through a signed,
+// expiring URL, so other sites can't hotlink the files and every download is
+// counted. The solution packs of the crackmes are served the same way.
+func DownloadGET(w http.ResponseWriter, r *http.Request) {
+    var params httprouter.Params
+    params = context.Get(r, "params").(httprouter.Params)
+    kind := params.ByName("kind")
+    hexid := params.ByName("hexid")
+    sess := session.Instance(r)
+
+    // Find the page the file is downloaded from
+    var page string
+    var crackme model.Crackme
+    var err error
+    switch kind {
+    case download.KindCrackme, download.KindPack:
+        if crackme, err = model.CrackmeByHexId(hexid); err != nil {
+            Error404(w, r)
+            return
+        }
+        page = "/crackme/" + hexid
+    case download.KindSolution:
+        solution, err := model.SolutionByHexId(hexid)
+        if err != nil {
+            Error404(w, r)
+            return
+        }
+        page = "/crackme/" + solution.CrackmeHexId
+    default:
+        Error404(w, r)
+        return
+    }
+
+    // Links expired or copied elsewhere lead back to the page, for a new one
+    query := r.URL.Query()
+    if err := download.Verify(kind, hexid, query.Get("expires"), query.Get("sig")); err != nil {
+        if err == download.ErrExpired {
+            sess.AddFlash(view.Flash{"The download link expired, please try again.", view.FlashNotice})
+            sess.Save(r, w)
+        }
+        http.Redirect(w, r, page, http.StatusFound)
+        return
+    }
+
+    if sess.Values["name"] == nil {
+        ip := clientip.NetworkFromRequest(r)
+        if ok, retry := download.AllowAnonymous(ip); !ok {
+            tooManyRequests(w, r, retry, "Too many downloads, please log in or try again later.", false)
+            return
+        }
+    }
+
+    if kind == download.KindPack {
+        servePack(w, r, crackme)
+        return
+    }
+
+    // Not counted for an admin viewing the site as a user
+    if !impersonating(r) {
+        if kind == download.KindCrackme {
+            err = model.CrackmeIncrementDownloads(hexid)
+        } else {
+            err = model.SolutionIncrementDownloads(hexid)
+        }
+        if err != nil {
+            log.Println(err)
+        }
+    }
+
+    // Spare the bandwidth of the server when a mirror is up. The query is
+    // kept so mirrors can check the signature too.
+    country := ""
+    if header := download.ReadConfig().CountryHeader; header != "" {
+        country = r.Header.Get(header)
+    }
+    if mirror := download.MirrorURL(kind, hexid, country); mirror != "" {
+        http.Redirect(w, r, mirror+"?"+r.URL.RawQuery, http.StatusFound)
+        return
+    }
+
+    w.Header().Set("Content-Disposition", "attachment; filename=\""+hexid+".zip\"")
+    http.ServeFile(w, r, download.Path(kind, hexid))
+}
+
+// servePack sends the zip of the files of all the approved solutions of a
+// crackme, built on the first download and cached until they change. The
+// mirrors don't have the packs, and the solutions' download counters are left
+// alone.
+func servePack(w http.ResponseWriter, r *http.Request, crackme model.Crackme) {
+    solutions, err := model.SolutionsByCrackme(crackme.ObjectId)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+    if len(solutions) == 0 {
+        Error404(w, r)
+        return
+    }
+
+    // A stable order, so the same solutions always hit the same cached pack
+    sort.Slice(solutions, func(i, j int) bool { return solutions[i].HexId < solutions[j].HexId })
+    files := make([]download.PackFile, len(solutions))
+    for i, s := range solutions {
+        files[i] = download.PackFile{Name: sanitize.Name(s.Author) + "_" + s.HexId + ".zip", HexId: s.HexId}
+    }
+
+    path, err := download.Pack(crackme.HexId, files)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    w.Header().Set("Content-Disposition", "attachment; filename=\""+crackme.HexId+"-solutions.zip\"")
+    http.ServeFile(w, r, path)
+}
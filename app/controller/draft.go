@@ -0,0 +1,94 @@
+package controller
+
+import (
+    "log"
+    "net/http"
+    "net/url"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+
+    "github.com/gorilla/context"
+    "github.com/julienschmidt/httprouter"
+)
+
+// draftFields are the fields of the upload forms kept in their drafts, the
+// files and the tokens aren't
+var draftFields = map[string][]string{
+    "crackme":  {"name", "difficulty", "lang", "arch", "platform", "info", "license", "visibility", "team"},
+    "solution": {"info", "tools", "approach", "key", "patch", "language", "license", "coauthors", "team"},
+}
+
+// draftMaxField is the size in bytes of a field kept in a draft, enough for a
+// writeup section of multibyte characters
+const draftMaxField = 4 * writeupMaxSection
+
+// draftSave keeps the fields of the kind of upload form posted by username
+func draftSave(r *http.Request, kind, username, crackmehexid string) error {
+    fields := map[string]string{}
+    for _, name := range draftFields[kind] {
+        if value := r.FormValue(name); value != "" && len(value) <= draftMaxField {
+            fields[name] = value
+        }
+    }
+    return model.DraftSave(username, kind, crackmehexid, fields)
+}
+
+// draftForm returns the fields to refill an upload form with: the posted
+// ones when the form is shown again after an error, else the ones of the
+// draft of username, if any
+func draftForm(r *http.Request, kind, username, crackmehexid string) url.Values {
+    if r.Method == http.MethodPost {
+        return r.Form
+    }
+
+    values := url.Values{}
+    draft, err := model.DraftByForm(username, kind, crackmehexid)
+    if err != nil && err != model.ErrNoResult {
+        log.Println(err)
+    }
+    for name, value := range draft.Fields {
+        values.Set(name, value)
+    }
+    return values
+}
+
+// draftDelete forgets the draft of an upload form once it is uploaded
+func draftDelete(kind, username, crackmehexid string) {
+    if err := model.DraftDelete(username, kind, crackmehexid); err != nil {
+        log.Println(err)
+    }
+}
+
+// DraftCrackmePOST saves the draft of the crackme upload form of the logged
+// in user, sent by the form while it is written
+func DraftCrackmePOST(w http.ResponseWriter, r *http.Request) {
+    draftPOST(w, r, "crackme", "")
+}
+
+// DraftSolutionPOST saves the draft of the writeup upload form of the logged
+// in user for a crackme
+func DraftSolutionPOST(w http.ResponseWriter, r *http.Request) {
+    params := context.Get(r, "params").(httprouter.Params)
+    hexidcrackme := params.ByName("hexidcrackme")
+    if _, err := model.CrackmeByHexId(hexidcrackme); err != nil {
+        apiError(w, http.StatusNotFound)
+        return
+    }
+    draftPOST(w, r, "solution", hexidcrackme)
+}
+
+// draftPOST saves the draft of the kind of upload form and answers, as JSON,
+// whether it was saved
+func draftPOST(w http.ResponseWriter, r *http.Request, kind, crackmehexid string) {
+    username, ok := apiUser(w, r)
+    if !ok {
+        return
+    }
+
+    if err := draftSave(r, kind, username, crackmehexid); err != nil {
+        log.Println(err)
+        apiError(w, http.StatusInternalServerError)
+        return
+    }
+    apiJSON(w, http.StatusOK, map[string]bool{"saved": true})
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "net/url"
+    "os"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/archive"
+    "github.com/crackmesone/crackmes.one/app/shared/download"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/josephspurrier/csrfbanana"
+)
+
+// compareSide is one of the crackmes compared by the moderators
+type compareSide struct {
+    HexId   string
+    Other   string // Hexid of the other crackme
+    Found   bool
+    Crackme model.Crackme
+    Upload  model.CrackmeVersion // Latest version uploaded
+    Files   []compareFile
+}
+
+// compareFile is a file of a compared crackme
+type compareFile struct {
+    archive.Entry
+    Shared bool // The other crackme has a file of the same name and size
+}
+
+// compareLoad returns the crackme of hexid for the comparison, pending or not
+func compareLoad(hexid string) (compareSide, error) {
+    side := compareSide{HexId: hexid}
+    if hexid == "" {
+        return side, nil
+    }
+
+    crackme, err := model.CrackmeUploadByHexId(hexid)
+    if err == model.ErrNoResult {
+        return side, nil
+    } else if err != nil {
+        return side, err
+    }
+
+    side.Found = true
+    side.Crackme = crackme
+    if len(crackme.Versions) > 0 {
+        side.Upload = crackme.Versions[len(crackme.Versions)-1]
+    }
+    return side, nil
+}
+
+// compareFiles lists the files of a crackme, marking the ones of the other
+func compareFiles(side, other compareSide) []compareFile {
+    shared := map[archive.Entry]bool{}
+    for _, f := range other.Crackme.Files {
+        shared[archive.Entry{Name: f.Name, Size: f.Size}] = true
+    }
+
+    files := make([]compareFile, len(side.Crackme.Files))
+    for i, f := range side.Crackme.Files {
+        files[i] = compareFile{f, shared[archive.Entry{Name: f.Name, Size: f.Size}]}
+    }
+    return files
+}
+
+// AdminCompareGET shows two crackmes side by side, for the moderators to
+// decide whether one is a duplicate of the other
+func AdminCompareGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    query := r.URL.Query()
+
+    a, err := compareLoad(query.Get("a"))
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+    b, err := compareLoad(query.Get("b"))
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+    a.Files, b.Files = compareFiles(a, b), compareFiles(b, a)
+    a.Other, b.Other = b.HexId, a.HexId
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "admin/compare"
+    v.Vars["a"] = a
+    v.Vars["b"] = b
+    v.Vars["sides"] = []compareSide{a, b}
+    v.Vars["compared"] = a.Found && b.Found && a.HexId != b.HexId
+    v.Vars["samehash"] = a.Upload.SHA256 != "" && a.Upload.SHA256 == b.Upload.SHA256
+    v.Vars["notes"] = modNoteCounts([]string{a.Crackme.Author, b.Crackme.Author})
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// AdminComparePOST merges a duplicate crackme into the kept one, or rejects
+// the duplicate if it waits for approval, and notifies its author
+func AdminComparePOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    moderator := fmt.Sprintf("%s", sess.Values["name"])
+    duplicate := r.FormValue("duplicate")
+    keep := r.FormValue("keep")
+
+    var dup, kept model.Crackme
+    var err error
+    switch r.FormValue("action") {
+    case "merge":
+        dup, kept, err = model.CrackmeMerge(duplicate, keep)
+        if err == nil {
+            compareDecided(r, moderator, model.AuditCrackmeMerge, dup, kept,
+                "Your crackme '" + dup.Name + "' was a duplicate of '" + kept.Name + "', its writeups and comments have been moved there.")
+            sess.AddFlash(view.Flash{"Crackme merged.", view.FlashSuccess})
+        }
+    case "reject":
+        kept, err = model.CrackmeUploadByHexId(keep)
+        if err == nil {
+            dup, err = model.CrackmeRejectDuplicate(duplicate)
+        }
+        if err == nil {
+            compareDecided(r, moderator, model.AuditCrackmeReject, dup, kept,
+                "Your crackme '" + dup.Name + "' has been rejected as a duplicate of '" + kept.Name + "'.")
+            sess.AddFlash(view.Flash{"Crackme rejected.", view.FlashNotice})
+        }
+    default:
+        sess.AddFlash(view.Flash{"Unknown action.", view.FlashError})
+    }
+
+    if err == model.ErrSameCrackme {
+        sess.AddFlash(view.Flash{err.Error(), view.FlashWarning})
+    } else if err == model.ErrNoResult {
+        sess.AddFlash(view.Flash{"The crackme was not found, or is not waiting for approval anymore.", view.FlashWarning})
+    } else if err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/admin/compare?a=" + url.QueryEscape(keep), http.StatusFound)
+}
+
+// compareDecided deletes the files of the duplicate, notifies its author and
+// records the decision in the audit log
+func compareDecided(r *http.Request, moderator, action string, dup, kept model.Crackme, notification string) {
+    removeUploads("tmp/crackme", dup.Author, dup.HexId)
+    if err := os.Remove(download.Path(download.KindCrackme, dup.HexId)); err != nil && !os.IsNotExist(err) {
+        log.Println(err)
+    }
+
+    if err := model.NotificationAdd(dup.Author, notification); err != nil {
+        log.Println(err)
+    }
+    if err := model.AuditAdd(moderator, action, dup.Author, dup.HexId + " duplicate of " + kept.HexId, auditIP(r)); err != nil {
+        log.Println(err)
+    }
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+    "fmt"
+    "html"
+    "log"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/download"
+    "github.com/crackmesone/crackmes.one/app/shared/site"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/gorilla/context"
+    "github.com/julienschmidt/httprouter"
+)
+
+// The size of the embedded card, in pixels
+const (
+    embedWidth  = 480
+    embedHeight = 150
+)
+
+// embedCrackme returns the crackme of a hexid if it can be embedded, the
+// approved ones only
+func embedCrackme(hexid string) (model.Crackme, error) {
+    crackme, err := model.CrackmeByHexId(hexid)
+    if err == nil && (!crackme.Visible || crackme.Deleted) {
+        err = model.ErrNoResult
+    }
+    return crackme, err
+}
+
+// EmbedCrackmeGET renders the card of a crackme, to be shown in an iframe
+// by blogs and writeups
+func EmbedCrackmeGET(w http.ResponseWriter, r *http.Request) {
+    params := context.Get(r, "params").(httprouter.Params)
+
+    crackme, err := embedCrackme(params.ByName("hexid"))
+    if err == model.ErrNoResult {
+        Error404(w, r)
+        return
+    } else if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    v := view.New(r)
+    v.Name = "crackme/embed"
+    // The embedded cards are shown elsewhere, their links are absolute
+    v.Vars["site"] = site.URL("")
+    v.Vars["crackme"] = crackme
+    v.Vars["download"] = site.URL(download.URL(download.KindCrackme, crackme.HexId))
+    card, err := v.Fragment("embed")
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    // The signed download URL must stay valid while the card is cached
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.Header().Set("Cache-Control", "public, max-age=300")
+    w.Write([]byte(card))
+}
+
+// oEmbed is the answer of the oEmbed endpoint, see https://oembed.com
+type oEmbed struct {
+    Version      string `json:"version"`
+    Type         string `json:"type"`
+    Title        string `json:"title"`
+    AuthorName   string `json:"author_name"`
+    AuthorURL    string `json:"author_url"`
+    ProviderName string `json:"provider_name"`
+    ProviderURL  string `json:"provider_url"`
+    HTML         string `json:"html"`
+    Width        int    `json:"width"`
+    Height       int    `json:"height"`
+}
+
+// OEmbedGET describes the card of a crackme for the oEmbed consumers, given
+// the URL of its page
+func OEmbedGET(w http.ResponseWriter, r *http.Request) {
+    if format := r.URL.Query().Get("format"); format != "" && format != "json" {
+        http.Error(w, "Only the json format is supported.", http.StatusNotImplemented)
+        return
+    }
+
+    base, _ := url.Parse(site.URL(""))
+    page := site.Path("/crackme/")
+    u, err := url.Parse(r.URL.Query().Get("url"))
+    if err != nil || (u.Host != base.Host && u.Host != "www."+base.Host) || !strings.HasPrefix(u.Path, page) {
+        Error404(w, r)
+        return
+    }
+    crackme, err := embedCrackme(strings.Trim(strings.TrimPrefix(u.Path, page), "/"))
+    if err == model.ErrNoResult {
+        Error404(w, r)
+        return
+    } else if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    width := embedWidth
+    if max, err := strconv.Atoi(r.URL.Query().Get("maxwidth")); err == nil && max > 0 && max < width {
+        width = max
+    }
+
+    view.RenderJSON(w, oEmbed{
+        Version:      "1.0",
+        Type:         "rich",
+        Title:        crackme.Name,
+        AuthorName:   crackme.Author,
+        AuthorURL:    site.URL("/user/" + url.PathEscape(crackme.Author)),
+        ProviderName: "crackmes.one",
+        ProviderURL:  site.URL(""),
+        HTML: fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" frameborder="0" title="%s"></iframe>`,
+            site.URL("/embed/crackme/"+crackme.HexId), width, embedHeight, html.EscapeString(crackme.Name)),
+        Width:  width,
+        Height: embedHeight,
+    })
+}
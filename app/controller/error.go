@@ -16,10 +16,3 @@ func Error500(w http.ResponseWriter, r *http.Request) {
     w.WriteHeader(http.StatusInternalServerError)
     fmt.Fprint(w, "Internal Server Error 500")
 }
-
-// InvalidToken handles CSRF attacks
-func InvalidToken(w http.ResponseWriter, r *http.Request) {
-    w.Header().Set("Content-Type", "text/html")
-    w.WriteHeader(http.StatusForbidden)
-    fmt.Fprint(w, `Your token <strong>expired</strong>, click <a href="javascript:void(0)" onclick="location.replace(document.referrer)">here</a> to try again.`)
-}
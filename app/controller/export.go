@@ -0,0 +1,78 @@
+package controller
+
+import (
+    "fmt"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+)
+
+// crackmeExport is the public metadata of a crackme offered in JSON and CSV
+type crackmeExport struct {
+    HexId       string    `json:"hexid"`
+    Name        string    `json:"name"`
+    Author      string    `json:"author"`
+    Lang        string    `json:"lang"`
+    Arch        string    `json:"arch"`
+    Platform    string    `json:"platform"`
+    Difficulty  float64   `json:"difficulty"`
+    Quality     float64   `json:"quality"`
+    NbSolutions int       `json:"nbsolutions"`
+    NbComments  int       `json:"nbcomments"`
+    CreatedAt   time.Time `json:"created_at"`
+    Info        string    `json:"info"`
+    License     string    `json:"license"`
+}
+
+var crackmeExportHeader = []string{"hexid", "name", "author", "lang", "arch", "platform",
+    "difficulty", "quality", "nbsolutions", "nbcomments", "created_at", "info", "license"}
+
+func (c crackmeExport) record() []string {
+    return []string{c.HexId, c.Name, c.Author, c.Lang, c.Arch, c.Platform,
+        fmt.Sprintf("%.1f", c.Difficulty), fmt.Sprintf("%.1f", c.Quality),
+        strconv.Itoa(c.NbSolutions), strconv.Itoa(c.NbComments),
+        c.CreatedAt.Format(time.RFC3339), c.Info, c.License}
+}
+
+// renderCrackmesExport writes the crackmes as JSON or CSV if the client asked
+// for it, and returns false if the HTML page should be rendered instead
+func renderCrackmesExport(w http.ResponseWriter, r *http.Request, filename string, crackmes []model.Crackme) bool {
+    format := view.Format(r)
+    if format == view.FormatHTML {
+        return false
+    }
+
+    exports := make([]crackmeExport, len(crackmes))
+    for i, c := range crackmes {
+        exports[i] = crackmeExport{
+            HexId:       c.HexId,
+            Name:        c.Name,
+            Author:      c.Author,
+            Lang:        c.Lang,
+            Arch:        c.Arch,
+            Platform:    c.Platform,
+            Difficulty:  c.Difficulty,
+            Quality:     c.Quality,
+            NbSolutions: c.NbSolutions,
+            NbComments:  c.NbComments,
+            CreatedAt:   c.CreatedAt,
+            Info:        c.Info,
+            License:     c.License,
+        }
+    }
+
+    if format == view.FormatJSON {
+        view.RenderJSON(w, exports)
+        return true
+    }
+
+    records := make([][]string, len(exports))
+    for i, e := range exports {
+        records[i] = e.record()
+    }
+    view.RenderCSV(w, filename+".csv", crackmeExportHeader, records)
+    return true
+}
@@ -6,8 +6,12 @@ import (
     "github.com/crackmesone/crackmes.one/app/shared/view"
 )
 
-// AboutGET displays the About page
+// FaqGET displays the FAQ, from the "faq" page once written by the admins
 func FaqGET(w http.ResponseWriter, r *http.Request) {
+    if renderPage(w, r, "faq") {
+        return
+    }
+
     // Display the view
     v := view.New(r)
     v.Name = "faq/faq"
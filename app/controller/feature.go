@@ -0,0 +1,74 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/feature"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+    "github.com/josephspurrier/csrfbanana"
+)
+
+// featureEnabled returns true if a feature is on for the user of the request
+func featureEnabled(r *http.Request, name string) bool {
+    sess := session.Instance(r)
+    username := ""
+    if sess.Values["name"] != nil {
+        username = fmt.Sprintf("%s", sess.Values["name"])
+    }
+    return feature.Enabled(name, username)
+}
+
+// AdminFeaturesGET lists the feature flags with their state
+func AdminFeaturesGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "admin/features"
+    v.Vars["flags"] = feature.Flags()
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// AdminFeaturesPOST sets the state of a feature flag
+func AdminFeaturesPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    name := r.FormValue("name")
+    known := false
+    for _, f := range feature.Flags() {
+        known = known || f.Name == name
+    }
+
+    percent, err := strconv.Atoi(r.FormValue("percent"))
+    if !known {
+        sess.AddFlash(view.Flash{"Unknown feature.", view.FlashError})
+    } else if err != nil || percent < 0 || percent > 100 {
+        sess.AddFlash(view.Flash{"The percentage must be between 0 and 100.", view.FlashError})
+    } else {
+        state := feature.State{Enabled: r.FormValue("enabled") != "", Percent: percent}
+        for _, user := range strings.Split(r.FormValue("users"), ",") {
+            if user = strings.TrimSpace(user); user != "" {
+                state.Users = append(state.Users, user)
+            }
+        }
+
+        if err := model.FeatureSave(name, state, username); err != nil {
+            log.Println(err)
+            sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+        } else {
+            sess.AddFlash(view.Flash{"Feature " + name + " saved!", view.FlashSuccess})
+        }
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/admin/features", http.StatusFound)
+}
@@ -0,0 +1,42 @@
+package controller
+
+import (
+    "log"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/cache"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+)
+
+// fragmentCache keeps the rendered parts of the crackme pages and their lists.
+// The entries are keyed on the last update of their crackme, so they are
+// replaced as soon as it changes. The short lifetime bounds the staleness of
+// the counters not recorded as updates (downloads) and keeps the signed
+// download URLs valid.
+var fragmentCache = cache.New(5 * time.Minute)
+
+// fragmentKey returns the cache key of a fragment of the page of a crackme,
+// for the visitors sharing the given variants
+func fragmentKey(name string, crackme model.Crackme, variants ...string) string {
+    return name + "/" + crackme.HexId + "/" + strconv.FormatInt(crackme.UpdatedAt.UnixNano(), 10) + "/" + strings.Join(variants, "/")
+}
+
+// cachedFragment returns the fragment of the view stored for key, rendering
+// and storing it if missing. A fragment failing to render is logged and
+// left empty.
+func cachedFragment(v *view.View, name, key string) interface{} {
+    if cached, ok := fragmentCache.Get(key); ok {
+        return cached
+    }
+
+    fragment, err := v.Fragment(name)
+    if err != nil {
+        log.Println(err)
+        return ""
+    }
+    fragmentCache.Set(key, fragment)
+    return fragment
+}
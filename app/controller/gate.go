@@ -0,0 +1,60 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "time"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/gate"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+)
+
+// gateCheck returns nil if the user passes the gate of the action
+func gateCheck(user model.User, action string) error {
+    // Uploads are capped by the submissions waiting for approval
+    var pending int
+    var err error
+    switch action {
+    case gate.Crackme:
+        pending, err = model.CountPendingCrackmesByUser(user.Name)
+    case gate.Solution:
+        pending, err = model.CountPendingSolutionsByUser(user.Name)
+    }
+    if err != nil {
+        log.Println(err)
+    }
+
+    // The _id of the users holds their creation time
+    return gate.Check(action, gate.Account{
+        Created:   user.ObjectId.Timestamp(),
+        Crackmes:  user.NbCrackmes,
+        Solutions: user.NbSolutions,
+        Pending:   pending,
+    }, time.Now())
+}
+
+// gateAllowed returns true if the logged in user passes the gate of the
+// action, else it explains why not and goes back to the back URL
+func gateAllowed(w http.ResponseWriter, r *http.Request, action, back string) bool {
+    sess := session.Instance(r)
+
+    user, err := model.UserByName(fmt.Sprintf("%s", sess.Values["name"]))
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return false
+    }
+
+    err = gateCheck(user, action)
+    if err == nil {
+        return true
+    }
+
+    sess.AddFlash(view.Flash{err.Error(), view.FlashError})
+    sess.Save(r, w)
+    http.Redirect(w, r, back, http.StatusFound)
+    return false
+}
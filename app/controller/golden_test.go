@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/model"
+	"github.com/crackmesone/crackmes.one/app/shared/download"
+	"github.com/crackmesone/crackmes.one/app/shared/recaptcha"
+	"github.com/crackmesone/crackmes.one/app/shared/session"
+	"github.com/crackmesone/crackmes.one/app/shared/shadow"
+	"github.com/crackmesone/crackmes.one/app/shared/site"
+	"github.com/crackmesone/crackmes.one/app/shared/view"
+	"github.com/crackmesone/crackmes.one/app/shared/view/plugin"
+
+	"github.com/gorilla/context"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Run "go test ./app/controller -update" to rewrite the golden files after a
+// deliberate change of the pages, and review the diff
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// unstable matches the parts of the pages changing on every run: the CSRF
+// tokens, the expiry and the signature of the download URLs
+var unstable = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`name="token" value="[^"]*"`), `name="token" value="TOKEN"`},
+	{regexp.MustCompile(`expires=\d+(&amp;|&)sig=[0-9a-f]+`), `expires=EXPIRES${1}sig=SIG`},
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+
+	// The templates are found from the root of the repository
+	if err := os.Chdir("../.."); err != nil {
+		panic(err)
+	}
+
+	session.Configure(session.Session{Name: "test", SecretKey: "test"})
+	download.Configure(download.Info{Secret: "test", Expiry: 3600})
+
+	viewInfo := view.View{BaseURI: "/", Extension: "tmpl", Folder: "template", Caching: true}
+	view.Configure(viewInfo)
+	view.LoadTemplates("base", []string{"partial/menu", "partial/footer", "partial/winner"})
+	view.LoadPlugins(
+		plugin.TagHelper(viewInfo),
+		plugin.NoEscape(),
+		plugin.PrettyTime(),
+		plugin.TimeCompare(),
+		plugin.Humanize(),
+		recaptcha.Plugin(),
+		download.Plugin(),
+		site.Plugin())
+
+	repo = newFakeRepository()
+
+	os.Exit(m.Run())
+}
+
+// serve calls a handler the way the router does, with the route parameters
+// in the request context
+func serve(handler http.HandlerFunc, target string, params httprouter.Params) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", target, nil)
+	context.Set(r, "params", params)
+	defer context.Clear(r)
+
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// checkGolden compares a page with testdata/golden/<name>.html
+func checkGolden(t *testing.T, name string, body []byte) {
+	for _, u := range unstable {
+		body = u.re.ReplaceAll(body, []byte(u.repl))
+	}
+
+	path := filepath.Join("app", "controller", "testdata", "golden", name+".html")
+	if *update {
+		if err := ioutil.WriteFile(path, body, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run the tests with -update to create it", err)
+	}
+	if !bytes.Equal(body, want) {
+		t.Errorf("%s differs from %s, run the tests with -update if the change is expected", name, path)
+	}
+}
+
+func TestPages(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		target  string
+		params  httprouter.Params
+	}{
+		{"index", IndexGET, "/", nil},
+		{"crackme", CrackMeGET, "/crackme/65f3150e0000000000000001", httprouter.Params{{Key: "hexid", Value: "65f3150e0000000000000001"}}},
+		{"user", UserGET, "/user/bob", httprouter.Params{{Key: "name", Value: "bob"}}},
+		{"lasts", LastCrackMesGET, "/lasts/1", httprouter.Params{{Key: "page", Value: "1"}}},
+		{"lasts-empty", LastCrackMesGET, "/lasts/2", httprouter.Params{{Key: "page", Value: "2"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.handler, tt.target, tt.params)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Status %d: %s", w.Code, w.Body.String())
+			}
+			checkGolden(t, tt.name, w.Body.Bytes())
+		})
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	// bob wrote one crackme and solved the other
+	d, err := userDashboard(model.Viewer{Name: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.New) != 0 || len(d.Crackmes) != 0 || len(d.Attempts) != 0 {
+		t.Errorf("Dashboard of bob %+v, expected empty", d)
+	}
+
+	d, err = userDashboard(model.Viewer{Name: "carol"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.New) != 2 {
+		t.Errorf("%d new crackmes for carol, expected 2", len(d.New))
+	}
+}
+
+func TestPagesNotFound(t *testing.T) {
+	w := serve(UserGET, "/user/nobody", httprouter.Params{{Key: "name", Value: "nobody"}})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Status %d, expected 404", w.Code)
+	}
+}
+
+func TestShadow(t *testing.T) {
+	shadow.Reset()
+	shadow.Configure(shadow.Info{Percent: 100})
+	defer shadow.Configure(shadow.Info{})
+
+	serve(UserGET, "/user/alice", httprouter.Params{{Key: "name", Value: "alice"}})
+	serve(LastCrackMesGET, "/lasts/2", httprouter.Params{{Key: "page", Value: "2"}})
+
+	// The comparisons run in the background
+	for i := 0; i < 100 && len(shadow.All()) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	stats := shadow.All()
+	if len(stats) != 2 {
+		t.Fatalf("%d queries compared, expected 2", len(stats))
+	}
+	for _, s := range stats {
+		if s.Runs != 1 || s.Mismatches != 0 || s.Errors != 0 {
+			t.Errorf("%s: %d runs, %d mismatches, %d errors", s.Name, s.Runs, s.Mismatches, s.Errors)
+		}
+	}
+}
+
+func TestCrackmeHeaderCache(t *testing.T) {
+	f := repo.(*fakeRepository)
+	saved := f.crackmes[1]
+	defer func() { f.crackmes[1] = saved }()
+
+	hexid := f.crackmes[1].HexId
+	params := httprouter.Params{{Key: "hexid", Value: hexid}}
+	serve(CrackMeGET, "/crackme/"+hexid, params)
+
+	// Cached until the crackme is updated
+	f.crackmes[1].Lang = "Rust"
+	if w := serve(CrackMeGET, "/crackme/"+hexid, params); bytes.Contains(w.Body.Bytes(), []byte("Rust")) {
+		t.Error("header rendered again without an update")
+	}
+	f.crackmes[1].UpdatedAt = fakeTime.Add(time.Minute)
+	if w := serve(CrackMeGET, "/crackme/"+hexid, params); !bytes.Contains(w.Body.Bytes(), []byte("Rust")) {
+		t.Error("header not rendered again after an update")
+	}
+}
@@ -0,0 +1,204 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/contentfilter"
+    "github.com/crackmesone/crackmes.one/app/shared/grace"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/gorilla/context"
+    "github.com/julienschmidt/httprouter"
+)
+
+// graceOver is the message of the edits and withdrawals after the grace
+// period, when only the moderators can change what was posted
+const graceOver = "The time to edit or withdraw it is over, please contact the moderators."
+
+// graceComment returns the comment of the id parameter if the logged in user
+// wrote it and can still change it, else answers the error and returns false
+func graceComment(w http.ResponseWriter, r *http.Request) (string, model.Comment, bool) {
+    username, ok := apiUser(w, r)
+    if !ok {
+        return "", model.Comment{}, false
+    }
+    params := context.Get(r, "params").(httprouter.Params)
+
+    comment, err := model.CommentByHexId(params.ByName("id"))
+    if err == nil && comment.Author != username {
+        err = model.ErrNoResult
+    }
+    if err == model.ErrNoResult {
+        apiError(w, http.StatusNotFound)
+        return "", comment, false
+    } else if err != nil {
+        log.Println(err)
+        apiError(w, http.StatusInternalServerError)
+        return "", comment, false
+    }
+
+    if !grace.Open(comment.CreatedAt, time.Now()) {
+        apiJSON(w, http.StatusForbidden, map[string]string{"error": graceOver})
+        return "", comment, false
+    }
+    return username, comment, true
+}
+
+// CommentEditPOST replaces the content of a comment of the logged in user in
+// its grace period and returns, as JSON, the new content
+func CommentEditPOST(w http.ResponseWriter, r *http.Request) {
+    username, comment, ok := graceComment(w, r)
+    if !ok {
+        return
+    }
+
+    filtered, problem := postedText(r.FormValue("comment"), "comment", maxComment)
+    if problem == "" && filtered.Text == "" {
+        apiJSON(w, http.StatusBadRequest, map[string]string{"error": "Field missing: comment"})
+        return
+    }
+
+    // The comment is already published, an edit the moderators would have
+    // to see first is refused rather than hiding it again
+    if problem == "" && filtered.Action == contentfilter.Hold {
+        problem = "Your comment was refused by the content filter (" + filtered.Reason + ")."
+    }
+    if problem != "" {
+        apiJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": problem})
+        return
+    }
+
+    comment, err := model.CommentEdit(comment.ObjectId.Hex(), username, filtered.Text, grace.Since(time.Now()))
+    if err == model.ErrNoResult {
+        apiJSON(w, http.StatusForbidden, map[string]string{"error": graceOver})
+        return
+    } else if err != nil {
+        log.Println(err)
+        apiError(w, http.StatusInternalServerError)
+        return
+    }
+
+    apiJSON(w, http.StatusOK, map[string]string{"content": comment.Content})
+}
+
+// CommentWithdrawPOST deletes a comment of the logged in user in its grace
+// period
+func CommentWithdrawPOST(w http.ResponseWriter, r *http.Request) {
+    username, comment, ok := graceComment(w, r)
+    if !ok {
+        return
+    }
+
+    _, err := model.CommentWithdraw(comment.ObjectId.Hex(), username, grace.Since(time.Now()))
+    if err == model.ErrNoResult {
+        apiJSON(w, http.StatusForbidden, map[string]string{"error": graceOver})
+        return
+    } else if err != nil {
+        log.Println(err)
+        apiError(w, http.StatusInternalServerError)
+        return
+    }
+
+    apiJSON(w, http.StatusOK, map[string]bool{"withdrawn": true})
+}
+
+// CrackmeEditPOST replaces the description of a crackme of the logged in user
+// waiting for approval, in its grace period, then goes back to the profile
+func CrackmeEditPOST(w http.ResponseWriter, r *http.Request) {
+    graceEdit(w, r, model.CrackmePendingEdit)
+}
+
+// SolutionEditPOST replaces the description of a writeup of the logged in
+// user waiting for approval, in its grace period, then goes back to the
+// profile
+func SolutionEditPOST(w http.ResponseWriter, r *http.Request) {
+    graceEdit(w, r, model.SolutionPendingEdit)
+}
+
+// graceEdit replaces the description of a submission with edit
+func graceEdit(w http.ResponseWriter, r *http.Request, edit func(hexid, username, info string, since time.Time) error) {
+    sess := session.Instance(r)
+    params := context.Get(r, "params").(httprouter.Params)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    // The submission waits for approval, a held description is seen by the
+    // moderators anyway
+    filtered, problem := postedText(r.FormValue("info"), "description", maxInfo)
+    if problem != "" {
+        sess.AddFlash(view.Flash{problem, view.FlashError})
+    } else if filtered.Text == "" {
+        sess.AddFlash(view.Flash{"Field missing: info", view.FlashError})
+    } else if err := edit(params.ByName("hexid"), username, filtered.Text, grace.Since(time.Now())); err == model.ErrNoResult {
+        sess.AddFlash(view.Flash{graceOver, view.FlashError})
+    } else if err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    } else {
+        sess.AddFlash(view.Flash{"Description updated.", view.FlashSuccess})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/user/"+username, http.StatusFound)
+}
+
+// CrackmeWithdrawPOST deletes a crackme of the logged in user waiting for
+// approval, in its grace period, with its file, then goes back to the profile
+func CrackmeWithdrawPOST(w http.ResponseWriter, r *http.Request) {
+    graceWithdraw(w, r, "tmp/crackme", func(hexid, username string, since time.Time) error {
+        _, err := model.CrackmeWithdraw(hexid, username, since)
+        return err
+    })
+}
+
+// SolutionWithdrawPOST deletes a writeup of the logged in user waiting for
+// approval, in its grace period, with its file, then goes back to the profile
+func SolutionWithdrawPOST(w http.ResponseWriter, r *http.Request) {
+    graceWithdraw(w, r, "tmp/solution", func(hexid, username string, since time.Time) error {
+        _, err := model.SolutionWithdraw(hexid, username, since)
+        return err
+    })
+}
+
+// graceWithdraw deletes a submission with withdraw, and its file waiting in
+// dir for the moderators
+func graceWithdraw(w http.ResponseWriter, r *http.Request, dir string, withdraw func(hexid, username string, since time.Time) error) {
+    sess := session.Instance(r)
+    params := context.Get(r, "params").(httprouter.Params)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+    hexid := params.ByName("hexid")
+
+    err := withdraw(hexid, username, grace.Since(time.Now()))
+    if err == model.ErrNoResult {
+        sess.AddFlash(view.Flash{graceOver, view.FlashError})
+    } else if err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    } else {
+        removeUploads(dir, username, hexid)
+        sess.AddFlash(view.Flash{"Withdrawn.", view.FlashSuccess})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/user/"+username, http.StatusFound)
+}
+
+// removeUploads deletes the files of a submission waiting in dir for the
+// moderators, named after the user and the hexid, see the uploads
+func removeUploads(dir, username, hexid string) {
+    files, _ := os.ReadDir(dir)
+    for _, f := range files {
+        if strings.HasPrefix(f.Name(), username+"+++"+hexid+"+++") {
+            if err := os.Remove(filepath.Join(dir, f.Name())); err != nil {
+                log.Println(err)
+            }
+        }
+    }
+}
@@ -0,0 +1,391 @@
+package controller
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "regexp"
+    "time"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/clientip"
+    "github.com/crackmesone/crackmes.one/app/shared/feature"
+    "github.com/crackmesone/crackmes.one/app/shared/graphql"
+    "github.com/crackmesone/crackmes.one/app/shared/ratelimit"
+)
+
+// featureGraphQL gates the GraphQL endpoint
+const featureGraphQL = "graphql"
+
+func init() {
+    feature.Register(featureGraphQL, "GraphQL endpoint at /graphql", feature.State{})
+}
+
+const (
+    // graphqlFirst is the size of a page when "first" is not given
+    graphqlFirst = 20
+    // graphqlMaxBody is the maximum size of a POSTed request
+    graphqlMaxBody = 64 << 10
+)
+
+// graphqlLimiter limits the number of queries per IP
+var graphqlLimiter = ratelimit.New(60, time.Minute)
+
+// GraphQLGET runs the GraphQL query of the "query", "variables" and
+// "operationName" query parameters
+func GraphQLGET(w http.ResponseWriter, r *http.Request) {
+    query := r.URL.Query()
+    req := graphql.Request{Query: query.Get("query"), OperationName: query.Get("operationName")}
+    if vars := query.Get("variables"); vars != "" {
+        if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+            apiJSON(w, http.StatusBadRequest, graphql.Response{Errors: []graphql.Error{{Message: "variables must be a JSON object"}}})
+            return
+        }
+    }
+    graphqlServe(w, r, req)
+}
+
+// GraphQLPOST runs the GraphQL request of the JSON body
+func GraphQLPOST(w http.ResponseWriter, r *http.Request) {
+    var req graphql.Request
+    if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, graphqlMaxBody)).Decode(&req); err != nil {
+        apiJSON(w, http.StatusBadRequest, graphql.Response{Errors: []graphql.Error{{Message: "the body must be a JSON request"}}})
+        return
+    }
+    graphqlServe(w, r, req)
+}
+
+// graphqlServe executes the request on the schema, when the endpoint is enabled
+func graphqlServe(w http.ResponseWriter, r *http.Request, req graphql.Request) {
+    if !featureEnabled(r, featureGraphQL) {
+        apiError(w, http.StatusNotFound)
+        return
+    }
+
+    if ok, retry := graphqlLimiter.Allow(clientip.NetworkFromRequest(r)); !ok {
+        tooManyRequests(w, r, retry, "Too many GraphQL requests, please slow down.", true)
+        return
+    }
+
+    apiJSON(w, http.StatusOK, graphqlSchema.Execute(req))
+}
+
+// *****************************************************************************
+// Schema
+// *****************************************************************************
+
+// graphqlSchema exposes the visible crackmes, users, solutions and comments.
+// Lists are connections paged with opaque cursors, newest first:
+//
+//   { crackmes(first: 10, lang: "C/C++") { edges { cursor node { id name } } pageInfo { hasNextPage endCursor } } }
+var graphqlSchema = &graphql.Schema{
+    Query: "Query",
+    Types: map[string]graphql.Object{
+        "Query": {
+            "crackme": {Type: "Crackme", Args: []string{"id"}, Resolve: func(_ interface{}, args graphql.Args) (interface{}, error) {
+                return graphqlCrackme(args.String("id"))
+            }},
+            "crackmes": {Type: "CrackmeConnection", Args: []string{"first", "after", "author", "lang", "platform"}, Resolve: func(_ interface{}, args graphql.Args) (interface{}, error) {
+                return graphqlCrackmes(model.CursorFilter{Author: args.String("author"), Lang: args.String("lang"), Platform: args.String("platform")}, args)
+            }},
+            "user": {Type: "User", Args: []string{"name"}, Resolve: func(_ interface{}, args graphql.Args) (interface{}, error) {
+                return graphqlUser(args.String("name"))
+            }},
+            "users": {Type: "UserConnection", Args: []string{"first", "after"}, Resolve: func(_ interface{}, args graphql.Args) (interface{}, error) {
+                return graphqlUsers(args)
+            }},
+            "solution": {Type: "Solution", Args: []string{"id"}, Resolve: func(_ interface{}, args graphql.Args) (interface{}, error) {
+                return graphqlSolution(args.String("id"))
+            }},
+            "solutions": {Type: "SolutionConnection", Args: []string{"first", "after", "author", "crackme"}, Resolve: func(_ interface{}, args graphql.Args) (interface{}, error) {
+                return graphqlSolutions(model.CursorFilter{Author: args.String("author"), Crackme: args.String("crackme")}, args)
+            }},
+            "comments": {Type: "CommentConnection", Args: []string{"first", "after", "author", "crackme"}, Resolve: func(_ interface{}, args graphql.Args) (interface{}, error) {
+                return graphqlComments(model.CursorFilter{Author: args.String("author"), Crackme: args.String("crackme")}, args)
+            }},
+        },
+        "Crackme": {
+            "id":             graphqlScalar(func(c model.Crackme) interface{} { return c.HexId }),
+            "name":           graphqlScalar(func(c model.Crackme) interface{} { return c.Name }),
+            "info":           graphqlScalar(func(c model.Crackme) interface{} { return c.Info }),
+            "lang":           graphqlScalar(func(c model.Crackme) interface{} { return c.Lang }),
+            "arch":           graphqlScalar(func(c model.Crackme) interface{} { return c.Arch }),
+            "platform":       graphqlScalar(func(c model.Crackme) interface{} { return c.Platform }),
+            "license":        graphqlScalar(func(c model.Crackme) interface{} { return c.License }),
+            "authorName":     graphqlScalar(func(c model.Crackme) interface{} { return c.Author }),
+            "createdAt":      graphqlScalar(func(c model.Crackme) interface{} { return c.CreatedAt.UTC().Format(time.RFC3339) }),
+            "difficulty":     graphqlScalar(func(c model.Crackme) interface{} { return c.Difficulty }),
+            "quality":        graphqlScalar(func(c model.Crackme) interface{} { return c.Quality }),
+            "downloads":      graphqlScalar(func(c model.Crackme) interface{} { return c.Downloads }),
+            "solutionsCount": graphqlScalar(func(c model.Crackme) interface{} { return c.NbSolutions }),
+            "commentsCount":  graphqlScalar(func(c model.Crackme) interface{} { return c.NbComments }),
+            "author": {Type: "User", Resolve: func(parent interface{}, _ graphql.Args) (interface{}, error) {
+                return graphqlUser(parent.(model.Crackme).Author)
+            }},
+            "solutions": {Type: "SolutionConnection", Args: []string{"first", "after"}, Resolve: func(parent interface{}, args graphql.Args) (interface{}, error) {
+                return graphqlSolutions(model.CursorFilter{Crackme: parent.(model.Crackme).HexId}, args)
+            }},
+            "comments": {Type: "CommentConnection", Args: []string{"first", "after"}, Resolve: func(parent interface{}, args graphql.Args) (interface{}, error) {
+                return graphqlComments(model.CursorFilter{Crackme: parent.(model.Crackme).HexId}, args)
+            }},
+        },
+        "User": {
+            "name":           graphqlScalar(func(u model.User) interface{} { return u.Name }),
+            "crackmesCount":  graphqlScalar(func(u model.User) interface{} { return u.NbCrackmes }),
+            "solutionsCount": graphqlScalar(func(u model.User) interface{} { return u.NbSolutions }),
+            "commentsCount":  graphqlScalar(func(u model.User) interface{} { return u.NbComments }),
+            "crackmes": {Type: "CrackmeConnection", Args: []string{"first", "after", "lang", "platform"}, Resolve: func(parent interface{}, args graphql.Args) (interface{}, error) {
+                return graphqlCrackmes(model.CursorFilter{Author: parent.(model.User).Name, Lang: args.String("lang"), Platform: args.String("platform")}, args)
+            }},
+            "solutions": {Type: "SolutionConnection", Args: []string{"first", "after"}, Resolve: func(parent interface{}, args graphql.Args) (interface{}, error) {
+                return graphqlSolutions(model.CursorFilter{Author: parent.(model.User).Name}, args)
+            }},
+            "comments": {Type: "CommentConnection", Args: []string{"first", "after"}, Resolve: func(parent interface{}, args graphql.Args) (interface{}, error) {
+                return graphqlComments(model.CursorFilter{Author: parent.(model.User).Name}, args)
+            }},
+        },
+        "Solution": {
+            "id":         graphqlScalar(func(s model.Solution) interface{} { return s.HexId }),
+            "info":       graphqlScalar(func(s model.Solution) interface{} { return s.Info }),
+            "language":   graphqlScalar(func(s model.Solution) interface{} { return s.Language }),
+            "license":    graphqlScalar(func(s model.Solution) interface{} { return s.License }),
+            "authorName": graphqlScalar(func(s model.Solution) interface{} { return s.Author }),
+            "createdAt":  graphqlScalar(func(s model.Solution) interface{} { return s.CreatedAt.UTC().Format(time.RFC3339) }),
+            "downloads":  graphqlScalar(func(s model.Solution) interface{} { return s.Downloads }),
+            "hintsUsed":  graphqlScalar(func(s model.Solution) interface{} { return s.HintsUsed }),
+            "author": {Type: "User", Resolve: func(parent interface{}, _ graphql.Args) (interface{}, error) {
+                return graphqlUser(parent.(model.Solution).Author)
+            }},
+            "crackme": {Type: "Crackme", Resolve: func(parent interface{}, _ graphql.Args) (interface{}, error) {
+                return graphqlCrackme(parent.(model.Solution).CrackmeHexId)
+            }},
+        },
+        "Comment": {
+            "id":         graphqlScalar(func(c model.Comment) interface{} { return c.ObjectId.Hex() }),
+            "content":    graphqlScalar(func(c model.Comment) interface{} { return c.Content }),
+            "byAuthor":   graphqlScalar(func(c model.Comment) interface{} { return c.ByAuthor }),
+            "authorName": graphqlScalar(func(c model.Comment) interface{} { return c.Author }),
+            "createdAt":  graphqlScalar(func(c model.Comment) interface{} { return c.CreatedAt.UTC().Format(time.RFC3339) }),
+            "author": {Type: "User", Resolve: func(parent interface{}, _ graphql.Args) (interface{}, error) {
+                return graphqlUser(parent.(model.Comment).Author)
+            }},
+            "crackme": {Type: "Crackme", Resolve: func(parent interface{}, _ graphql.Args) (interface{}, error) {
+                return graphqlCrackme(parent.(model.Comment).CrackMeHexId)
+            }},
+        },
+        "CrackmeConnection":  graphqlConnectionType("Crackme"),
+        "CrackmeEdge":        graphqlEdgeType("Crackme"),
+        "UserConnection":     graphqlConnectionType("User"),
+        "UserEdge":           graphqlEdgeType("User"),
+        "SolutionConnection": graphqlConnectionType("Solution"),
+        "SolutionEdge":       graphqlEdgeType("Solution"),
+        "CommentConnection":  graphqlConnectionType("Comment"),
+        "CommentEdge":        graphqlEdgeType("Comment"),
+        "PageInfo": {
+            "hasNextPage": {Resolve: func(parent interface{}, _ graphql.Args) (interface{}, error) {
+                return parent.(*graphqlConnection).hasNext, nil
+            }},
+            "endCursor": {Resolve: func(parent interface{}, _ graphql.Args) (interface{}, error) {
+                c := parent.(*graphqlConnection)
+                if len(c.edges) == 0 {
+                    return nil, nil
+                }
+                return c.edges[len(c.edges)-1].cursor, nil
+            }},
+        },
+    },
+    MaxDepth:   8,
+    MaxObjects: 1000,
+}
+
+// graphqlScalar returns a field read by get from the model value of its
+// parent, a crackme, a user, a solution or a comment
+func graphqlScalar(get interface{}) graphql.Field {
+    return graphql.Field{Resolve: func(parent interface{}, _ graphql.Args) (interface{}, error) {
+        switch get := get.(type) {
+        case func(model.Crackme) interface{}:
+            return get(parent.(model.Crackme)), nil
+        case func(model.User) interface{}:
+            return get(parent.(model.User)), nil
+        case func(model.Solution) interface{}:
+            return get(parent.(model.Solution)), nil
+        case func(model.Comment) interface{}:
+            return get(parent.(model.Comment)), nil
+        }
+        return nil, fmt.Errorf("unknown field getter %T", get)
+    }}
+}
+
+// *****************************************************************************
+// Connections
+// *****************************************************************************
+
+// graphqlConnection is a page of a list
+type graphqlConnection struct {
+    edges   []graphqlEdge
+    hasNext bool
+}
+
+// graphqlEdge is an item of a page, with the cursor of the next page starting after it
+type graphqlEdge struct {
+    cursor string
+    node   interface{}
+}
+
+// add appends an item, identified by the hexid of its _id
+func (c *graphqlConnection) add(id string, node interface{}) {
+    c.edges = append(c.edges, graphqlEdge{base64.RawURLEncoding.EncodeToString([]byte(id)), node})
+}
+
+// graphqlConnectionType returns the connection type of a list of node
+func graphqlConnectionType(node string) graphql.Object {
+    return graphql.Object{
+        "edges": {Type: node + "Edge", Resolve: func(parent interface{}, _ graphql.Args) (interface{}, error) {
+            return parent.(*graphqlConnection).edges, nil
+        }},
+        "nodes": {Type: node, Resolve: func(parent interface{}, _ graphql.Args) (interface{}, error) {
+            c := parent.(*graphqlConnection)
+            nodes := make([]interface{}, len(c.edges))
+            for i := range c.edges {
+                nodes[i] = c.edges[i].node
+            }
+            return nodes, nil
+        }},
+        "pageInfo": {Type: "PageInfo", Resolve: func(parent interface{}, _ graphql.Args) (interface{}, error) {
+            return parent, nil
+        }},
+    }
+}
+
+// graphqlEdgeType returns the edge type of a list of node
+func graphqlEdgeType(node string) graphql.Object {
+    return graphql.Object{
+        "cursor": {Resolve: func(parent interface{}, _ graphql.Args) (interface{}, error) {
+            return parent.(graphqlEdge).cursor, nil
+        }},
+        "node": {Type: node, Resolve: func(parent interface{}, _ graphql.Args) (interface{}, error) {
+            return parent.(graphqlEdge).node, nil
+        }},
+    }
+}
+
+// graphqlCursorRe matches a decoded cursor, the hexid of a document
+var graphqlCursorRe = regexp.MustCompile(`^[0-9a-f]{24}$`)
+
+// graphqlPage returns the size of the page and the decoded cursor of the
+// "first" and "after" arguments
+func graphqlPage(args graphql.Args) (int, string, error) {
+    first := args.Int("first", graphqlFirst)
+    if first < 1 || first > model.CursorLimit {
+        return 0, "", fmt.Errorf("first must be between 1 and %d", model.CursorLimit)
+    }
+
+    after := ""
+    if cursor := args.String("after"); cursor != "" {
+        id, err := base64.RawURLEncoding.DecodeString(cursor)
+        if err != nil || !graphqlCursorRe.Match(id) {
+            return 0, "", errors.New("invalid cursor")
+        }
+        after = string(id)
+    }
+    return first, after, nil
+}
+
+// *****************************************************************************
+// Resolvers
+// *****************************************************************************
+
+// graphqlCrackme returns the visible crackme hexid, nil if there is none
+func graphqlCrackme(hexid string) (interface{}, error) {
+    crackme, err := model.CrackmeByHexId(hexid)
+    if err == model.ErrNoResult {
+        return nil, nil
+    }
+    return crackme, graphqlError(err)
+}
+
+// graphqlSolution returns the visible solution hexid, nil if there is none
+func graphqlSolution(hexid string) (interface{}, error) {
+    solution, err := model.SolutionByHexId(hexid)
+    if err == model.ErrNoResult {
+        return nil, nil
+    }
+    return solution, graphqlError(err)
+}
+
+// graphqlUser returns the visible user name, nil if there is none
+func graphqlUser(name string) (interface{}, error) {
+    user, err := model.UserByName(name)
+    if err == model.ErrNoResult || err == nil && (!user.Visible || user.Deleted) {
+        return nil, nil
+    }
+    return user, graphqlError(err)
+}
+
+// graphqlUsers returns a page of the visible users
+func graphqlUsers(args graphql.Args) (interface{}, error) {
+    first, after, err := graphqlPage(args)
+    if err != nil {
+        return nil, err
+    }
+    users, more, err := model.UsersAfter(after, first)
+    c := &graphqlConnection{hasNext: more}
+    for _, user := range users {
+        c.add(user.ObjectId.Hex(), user)
+    }
+    return c, graphqlError(err)
+}
+
+// graphqlCrackmes returns a page of the crackmes matching filter
+func graphqlCrackmes(filter model.CursorFilter, args graphql.Args) (interface{}, error) {
+    first, after, err := graphqlPage(args)
+    if err != nil {
+        return nil, err
+    }
+    crackmes, more, err := model.CrackmesAfter(filter, after, first)
+    c := &graphqlConnection{hasNext: more}
+    for _, crackme := range crackmes {
+        c.add(crackme.ObjectId.Hex(), crackme)
+    }
+    return c, graphqlError(err)
+}
+
+// graphqlSolutions returns a page of the solutions matching filter
+func graphqlSolutions(filter model.CursorFilter, args graphql.Args) (interface{}, error) {
+    first, after, err := graphqlPage(args)
+    if err != nil {
+        return nil, err
+    }
+    solutions, more, err := model.SolutionsAfter(filter, after, first)
+    c := &graphqlConnection{hasNext: more}
+    for _, solution := range solutions {
+        c.add(solution.ObjectId.Hex(), solution)
+    }
+    return c, graphqlError(err)
+}
+
+// graphqlComments returns a page of the comments matching filter
+func graphqlComments(filter model.CursorFilter, args graphql.Args) (interface{}, error) {
+    first, after, err := graphqlPage(args)
+    if err != nil {
+        return nil, err
+    }
+    comments, more, err := model.CommentsAfter(filter, after, first)
+    c := &graphqlConnection{hasNext: more}
+    for _, comment := range comments {
+        c.add(comment.ObjectId.Hex(), comment)
+    }
+    return c, graphqlError(err)
+}
+
+// graphqlError logs the errors of the database and hides them from the clients
+func graphqlError(err error) error {
+    if err != nil {
+        log.Println(err)
+        return errors.New("Internal Server Error")
+    }
+    return nil
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "strings"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/gorilla/context"
+    "github.com/julienschmidt/httprouter"
+    "github.com/kennygrant/sanitize"
+)
+
+// hintCrackme returns the crackme of the hexid parameter and the logged in
+// user, or displays a 404 page
+func hintCrackme(w http.ResponseWriter, r *http.Request) (model.Crackme, string, bool) {
+    sess := session.Instance(r)
+    params := context.Get(r, "params").(httprouter.Params)
+
+    crackme, err := model.CrackmeByHexId(params.ByName("hexid"))
+    if err != nil {
+        log.Println(err)
+        Error404(w, r)
+        return crackme, "", false
+    }
+    return crackme, fmt.Sprintf("%s", sess.Values["name"]), true
+}
+
+// HintCreatePOST adds a hint to a crackme, for its author
+func HintCreatePOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    crackme, username, ok := hintCrackme(w, r)
+    if !ok {
+        return
+    }
+
+    text := strings.TrimSpace(sanitize.HTML(r.FormValue("hint")))
+    hints, err := model.HintsByCrackme(crackme.HexId)
+
+    if crackme.Author != username {
+        sess.AddFlash(view.Flash{"Only the author can add hints.", view.FlashError})
+    } else if text == "" {
+        sess.AddFlash(view.Flash{"Field missing: hint", view.FlashError})
+    } else if err == nil && len(hints) >= model.MaxHints {
+        sess.AddFlash(view.Flash{fmt.Sprintf("A crackme can't have more than %d hints.", model.MaxHints), view.FlashError})
+    } else if err == nil {
+        err = model.HintCreate(crackme.HexId, text)
+        if err == nil {
+            sess.AddFlash(view.Flash{"Hint added!", view.FlashSuccess})
+        }
+    }
+
+    if err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/crackme/"+crackme.HexId+"#hints", http.StatusFound)
+}
+
+// HintDeletePOST removes a hint of a crackme, for its author
+func HintDeletePOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    crackme, username, ok := hintCrackme(w, r)
+    if !ok {
+        return
+    }
+
+    if crackme.Author != username {
+        sess.AddFlash(view.Flash{"Only the author can remove hints.", view.FlashError})
+    } else if err := model.HintDelete(crackme.HexId, r.FormValue("hint")); err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"The hint could not be removed.", view.FlashError})
+    } else {
+        sess.AddFlash(view.Flash{"Hint removed.", view.FlashSuccess})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/crackme/"+crackme.HexId+"#hints", http.StatusFound)
+}
+
+// HintRevealPOST reveals the next hint of a crackme to the logged in user
+func HintRevealPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    crackme, username, ok := hintCrackme(w, r)
+    if !ok {
+        return
+    }
+
+    if crackme.Author != username {
+        _, err := model.HintRevealNext(username, crackme.HexId)
+        if err == model.ErrNoResult {
+            sess.AddFlash(view.Flash{"All the hints are already revealed.", view.FlashNotice})
+        } else if err != nil {
+            log.Println(err)
+            sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+        }
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/crackme/"+crackme.HexId+"#hints", http.StatusFound)
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "log"
+    "net/http"
+    "regexp"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+)
+
+// idempotencyKeyFormat is the format of the idempotency keys
+var idempotencyKeyFormat = regexp.MustCompile(`^[A-Za-z0-9_-]{8,64}$`)
+
+// newIdempotencyKey returns a key for an upload form, sent back with the
+// upload and its retries
+func newIdempotencyKey() string {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        log.Println(err)
+        return ""
+    }
+    return hex.EncodeToString(b)
+}
+
+// idempotencyFormKey returns the key of an upload form: the key of the form
+// kept for an expired token, the retry of an upload must not upload again,
+// else a new one
+func idempotencyFormKey(vars map[string]interface{}) string {
+    if key := view.KeptValue(vars, "idempotency_key"); idempotencyKeyFormat.MatchString(key) {
+        return key
+    }
+    return newIdempotencyKey()
+}
+
+// idempotentUpload is an upload sent with an idempotency key
+type idempotentUpload struct {
+    username string
+    key      string
+    hexid    string // Set once the upload succeeded
+}
+
+// idempotencyBegin reserves the idempotency key of an upload, from the
+// Idempotency-Key header or the idempotency_key field. When the upload with
+// that key was already done, or still runs, the uploader is sent to their
+// profile as after the first one and true is returned. The upload must call
+// end once done.
+func idempotencyBegin(w http.ResponseWriter, r *http.Request, kind, username, success string) (*idempotentUpload, bool) {
+    key := r.Header.Get("Idempotency-Key")
+    if key == "" {
+        key = r.FormValue("idempotency_key")
+    }
+    if !idempotencyKeyFormat.MatchString(key) {
+        return &idempotentUpload{}, false
+    }
+
+    _, ok, err := model.IdempotencyReserve(username, kind, key)
+    if ok {
+        return &idempotentUpload{username: username, key: key}, false
+    }
+
+    sess := session.Instance(r)
+    if err == model.ErrRequestRunning {
+        sess.AddFlash(view.Flash{"Your upload is still being processed, it will be listed here in a moment.", view.FlashNotice})
+    } else if err != nil {
+        // Upload anyway rather than failing it
+        log.Println(err)
+        return &idempotentUpload{}, false
+    } else {
+        sess.AddFlash(view.Flash{success, view.FlashSuccess})
+    }
+    sess.Save(r, w)
+    http.Redirect(w, r, "/user/"+username, http.StatusFound)
+    return nil, true
+}
+
+// end records the result of the upload, or releases its key when it failed
+// so it can be retried
+func (u *idempotentUpload) end() {
+    if u.key == "" {
+        return
+    }
+
+    var err error
+    if u.hexid != "" {
+        err = model.IdempotencyComplete(u.username, u.key, u.hexid)
+    } else {
+        err = model.IdempotencyRelease(u.username, u.key)
+    }
+    if err != nil {
+        log.Println(err)
+    }
+}
@@ -0,0 +1,185 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/clientip"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/site"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/gorilla/sessions"
+    "github.com/josephspurrier/csrfbanana"
+    "github.com/kennygrant/sanitize"
+)
+
+// impersonationLength is how long an admin views the site as a user
+const impersonationLength = 30 * time.Minute
+
+// Session keys of the impersonation
+const (
+    sessImpersonator     = "impersonator"
+    sessImpersonateUntil = "impersonate_until"
+)
+
+// auditIP returns the IP of the request for the audit log, normalized
+func auditIP(r *http.Request) string {
+    return clientip.FromRequest(r)
+}
+
+// impersonationEnd gives the session back to the admin and records it in
+// the audit log
+func impersonationEnd(sess *sessions.Session, r *http.Request, detail string) {
+    admin := fmt.Sprintf("%s", sess.Values[sessImpersonator])
+    user := fmt.Sprintf("%s", sess.Values["name"])
+
+    sess.Values["name"] = admin
+    if u, err := model.UserByName(admin); err == nil {
+        sess.Values["email"] = u.Email
+    }
+    delete(sess.Values, sessImpersonator)
+    delete(sess.Values, sessImpersonateUntil)
+
+    if err := model.AuditAdd(admin, model.AuditImpersonateStop, user, detail, auditIP(r)); err != nil {
+        log.Println(err)
+    }
+}
+
+// impersonating returns true if an admin views the site as a user, whose
+// GET requests don't change anything on their behalf either: the
+// notifications stay unseen and the downloads aren't counted
+func impersonating(r *http.Request) bool {
+    return session.Instance(r).Values[sessImpersonator] != nil
+}
+
+// ImpersonationGuard keeps the sessions of the admins viewing the site as a
+// user read-only, and ends them once expired
+func ImpersonationGuard(h http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        sess := session.Instance(r)
+        if sess.Values[sessImpersonator] == nil {
+            h.ServeHTTP(w, r)
+            return
+        }
+
+        if until, ok := sess.Values[sessImpersonateUntil].(int64); !ok || time.Now().Unix() > until {
+            impersonationEnd(sess, r, "expired")
+            sess.AddFlash(view.Flash{"The impersonation has expired.", view.FlashNotice})
+            sess.Save(r, w)
+            http.Redirect(w, r, "/admin/impersonate", http.StatusFound)
+            return
+        }
+
+        if r.Method != http.MethodGet && r.Method != http.MethodHead {
+            sess.AddFlash(view.Flash{"You are viewing the site as another user, nothing can be changed.", view.FlashWarning})
+            sess.Save(r, w)
+            back := "/"
+            if u, err := url.Parse(r.Referer()); err == nil && u.Host == r.Host && u.Path != "" {
+                back = site.Strip(u.Path)
+            }
+            http.Redirect(w, r, back, http.StatusFound)
+            return
+        }
+
+        h.ServeHTTP(w, r)
+    })
+}
+
+// AdminImpersonateGET displays the form to view the site as a user
+func AdminImpersonateGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "admin/impersonate"
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Vars["minutes"] = int(impersonationLength / time.Minute)
+    view.Repopulate([]string{"user", "reason"}, r.Form, v.Vars)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// AdminImpersonatePOST starts viewing the site as a user, read-only
+func AdminImpersonatePOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    admin := fmt.Sprintf("%s", sess.Values["name"])
+
+    if validate, missingField := view.Validate(r, []string{"user", "reason"}); !validate {
+        sess.AddFlash(view.Flash{"Field missing: " + missingField, view.FlashError})
+        sess.Save(r, w)
+        AdminImpersonateGET(w, r)
+        return
+    }
+
+    user, err := model.UserByName(r.FormValue("user"))
+    if err == model.ErrNoResult {
+        sess.AddFlash(view.Flash{"No such user.", view.FlashError})
+        sess.Save(r, w)
+        AdminImpersonateGET(w, r)
+        return
+    } else if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+    if user.Name == admin {
+        sess.AddFlash(view.Flash{"You can't impersonate yourself.", view.FlashError})
+        sess.Save(r, w)
+        AdminImpersonateGET(w, r)
+        return
+    }
+
+    // Nobody is impersonated without a trace
+    reason := strings.TrimSpace(sanitize.HTML(r.FormValue("reason")))
+    if err := model.AuditAdd(admin, model.AuditImpersonateStart, user.Name, reason, auditIP(r)); err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+        sess.Save(r, w)
+        AdminImpersonateGET(w, r)
+        return
+    }
+
+    sess.Values[sessImpersonator] = admin
+    sess.Values[sessImpersonateUntil] = time.Now().Add(impersonationLength).Unix()
+    sess.Values["name"] = user.Name
+    sess.Values["email"] = user.Email
+    sess.AddFlash(view.Flash{"You are viewing the site as " + user.Name + ", read-only.", view.FlashNotice})
+    sess.Save(r, w)
+    http.Redirect(w, r, "/user/"+user.Name, http.StatusFound)
+}
+
+// ImpersonateStopGET gives the session back to the admin
+func ImpersonateStopGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    if sess.Values[sessImpersonator] == nil {
+        http.Redirect(w, r, "/", http.StatusFound)
+        return
+    }
+
+    impersonationEnd(sess, r, "")
+    sess.AddFlash(view.Flash{"You are back to your account.", view.FlashNotice})
+    sess.Save(r, w)
+    http.Redirect(w, r, "/admin/impersonate", http.StatusFound)
+}
+
+// AdminAuditGET displays the latest entries of the audit log
+func AdminAuditGET(w http.ResponseWriter, r *http.Request) {
+    entries, err := model.AuditLatest(200)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "admin/audit"
+    v.Vars["entries"] = entries
+    v.Render(w)
+}
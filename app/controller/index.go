@@ -3,10 +3,14 @@ package controller
 import (
     "log"
     "net/http"
+
+    "github.com/crackmesone/crackmes.one/app/shared/session"
     "github.com/crackmesone/crackmes.one/app/shared/view"
-    "github.com/crackmesone/crackmes.one/app/model"
 )
 
+// trendingShown is the number of trending crackmes on the home page
+const trendingShown = 5
+
 // IndexGET displays the home page
 func IndexGET(w http.ResponseWriter, r *http.Request) {
     // Display the view
@@ -15,27 +19,46 @@ func IndexGET(w http.ResponseWriter, r *http.Request) {
     var nbusers, nbcrackmes, nbsolutions int
     var err error
 
-    nbusers, err = model.CountUsers()
+    nbusers, err = repo.CountUsers()
     if err != nil {
         log.Println(err)
         Error500(w, r)
         return
     }
 
-    nbcrackmes, err = model.CountCrackmes()
+    nbcrackmes, err = repo.CountCrackmes()
     if err != nil {
         log.Println(err)
         Error500(w, r)
         return
     }
 
-    nbsolutions, err = model.CountSolutions()
+    nbsolutions, err = repo.CountSolutions()
     if err != nil {
         log.Println(err)
         Error500(w, r)
         return
     }
 
+    // The ranking is computed in the background, an error leaves the section
+    // out rather than failing the page
+    trending, err := repo.TrendingCrackmes(trendingShown)
+    if err != nil {
+        log.Println(err)
+    }
+
+    // The logged in users see where they are rather than the counters
+    if sess := session.Instance(r); sess.Values["name"] != nil {
+        d, err := userDashboard(listingViewer(r))
+        if err != nil {
+            log.Println(err)
+            Error500(w, r)
+            return
+        }
+        v.Vars["dashboard"] = d
+    }
+
+    v.Vars["trending"] = trending
     v.Vars["nbusers"] = nbusers
     v.Vars["nbsolutions"] = nbsolutions
     v.Vars["nbcrackmes"] = nbcrackmes
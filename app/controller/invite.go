@@ -0,0 +1,157 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/settings"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+    "github.com/josephspurrier/csrfbanana"
+)
+
+// The knobs of the invitations
+const (
+    settingRegistrationInvite = "registration.invite"
+    settingInviteQuota        = "invite.quota"
+    settingInviteTrust        = "invite.trust"
+)
+
+// inviteQuotaPeriod is the period the invite codes of the users are counted
+// over
+const inviteQuotaPeriod = 30 * 24 * time.Hour
+
+// inviteListMax is the number of invite codes listed
+const inviteListMax = 100
+
+func init() {
+    settings.Register(settings.Setting{Name: settingRegistrationInvite, Description: "Registration only with an invite code", Switch: true},
+        func() int { return 0 }, nil)
+    settings.Register(settings.Setting{Name: settingInviteQuota, Description: "Invite codes of a trusted user", Unit: "per 30 days", Min: 0, Max: 50},
+        func() int { return 3 }, nil)
+    settings.Register(settings.Setting{Name: settingInviteTrust, Description: "Approved crackmes and writeups to be trusted with invite codes", Min: 0, Max: 1000},
+        func() int { return 5 }, nil)
+}
+
+// inviteQuota returns how many invite codes a user may still create, or why
+// they may not
+func inviteQuota(user model.User) (int, string) {
+    if trust := settings.Int(settingInviteTrust); user.NbCrackmes + user.NbSolutions < trust {
+        return 0, fmt.Sprintf("You need %d approved crackmes or writeups to invite people.", trust)
+    }
+
+    created, err := model.CountInvitesSince(user.Name, time.Now().Add(-inviteQuotaPeriod))
+    if err != nil {
+        log.Println(err)
+        return 0, "An error occurred on the server. Please try again later."
+    }
+    left := settings.Int(settingInviteQuota) - created
+    if left <= 0 {
+        return 0, "You created all the invite codes of the last 30 days."
+    }
+    return left, ""
+}
+
+// InvitesGET displays the invite codes of the logged in user
+func InvitesGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+
+    user, err := model.UserByName(fmt.Sprintf("%s", sess.Values["name"]))
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+    invites, err := model.InvitesByCreator(user.Name, inviteListMax)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+    left, problem := inviteQuota(user)
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "user/invites"
+    v.Vars["invites"] = invites
+    v.Vars["left"] = left
+    v.Vars["problem"] = problem
+    v.Vars["required"] = settings.Bool(settingRegistrationInvite)
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// InvitesPOST creates an invite code of the logged in user, within their
+// quota
+func InvitesPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+
+    user, err := model.UserByName(fmt.Sprintf("%s", sess.Values["name"]))
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    if _, problem := inviteQuota(user); problem != "" {
+        sess.AddFlash(view.Flash{problem, view.FlashError})
+    } else if invite, err := model.InviteCreate(user.Name); err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    } else {
+        sess.AddFlash(view.Flash{"Invite code " + invite.Code + " created.", view.FlashSuccess})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/settings/invites", http.StatusFound)
+}
+
+// AdminInvitesGET lists the latest invite codes of everybody
+func AdminInvitesGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+
+    invites, err := model.InvitesByCreator("", inviteListMax)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "admin/invites"
+    v.Vars["invites"] = invites
+    v.Vars["required"] = settings.Bool(settingRegistrationInvite)
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// AdminInvitesPOST creates invite codes, without quota
+func AdminInvitesPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    n, err := strconv.Atoi(r.FormValue("count"))
+    if err != nil || n < 1 || n > 50 {
+        sess.AddFlash(view.Flash{"Create between 1 and 50 codes at once.", view.FlashError})
+    } else {
+        for i := 0; i < n && err == nil; i++ {
+            _, err = model.InviteCreate(username)
+        }
+        if err != nil {
+            log.Println(err)
+            sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+        } else {
+            sess.AddFlash(view.Flash{fmt.Sprintf("%d invite codes created.", n), view.FlashSuccess})
+        }
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/admin/invites", http.StatusFound)
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "math"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/clientip"
+    "github.com/crackmesone/crackmes.one/app/shared/download"
+    "github.com/crackmesone/crackmes.one/app/shared/gate"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+)
+
+// retrySeconds returns a wait in whole seconds, rounded up, at least 1
+func retrySeconds(retry time.Duration) int {
+    seconds := int(math.Ceil(retry.Seconds()))
+    if seconds < 1 {
+        seconds = 1
+    }
+    return seconds
+}
+
+// tooManyRequests answers 429 to a rate limited request with how long to wait
+// in Retry-After, as JSON for the API clients and as text for the others
+func tooManyRequests(w http.ResponseWriter, r *http.Request, retry time.Duration, message string, api bool) {
+    seconds := retrySeconds(retry)
+    w.Header().Set("Retry-After", strconv.Itoa(seconds))
+    if api || view.Format(r) == view.FormatJSON {
+        apiJSON(w, http.StatusTooManyRequests, map[string]interface{}{
+            "error":       http.StatusText(http.StatusTooManyRequests),
+            "message":     message,
+            "retry_after": seconds,
+        })
+        return
+    }
+    http.Error(w, fmt.Sprintf("%s Retry in %d seconds.", message, seconds), http.StatusTooManyRequests)
+}
+
+// limitQuota is a quota of the logged in user shown by LimitsGET
+type limitQuota struct {
+    Name    string `json:"name"`
+    Used    int    `json:"used"`
+    Limit   int    `json:"limit"`             // 0 if not capped
+    Per     string `json:"per,omitempty"`     // What the limit is counted over
+    Reset   int    `json:"reset,omitempty"`   // Seconds until the count starts over
+    Blocked string `json:"blocked,omitempty"` // Why the action is refused now
+}
+
+// limitQuotas returns the quotas of a user making the request r
+func limitQuotas(r *http.Request, user model.User) []limitQuota {
+    // The rate limits count the network of the address
+    ip := clientip.NetworkFromRequest(r)
+    per := clientip.Describe(clientip.FromRequest(r))
+
+    var quotas []limitQuota
+    for _, q := range []struct{ action, name string }{
+        {gate.Crackme, "Crackme uploads"},
+        {gate.Solution, "Writeup uploads"},
+    } {
+        quota := limitQuota{Name: q.name, Limit: gate.MaxPending(q.action), Per: "waiting for approval"}
+        var err error
+        switch q.action {
+        case gate.Crackme:
+            quota.Used, err = model.CountPendingCrackmesByUser(user.Name)
+        case gate.Solution:
+            quota.Used, err = model.CountPendingSolutionsByUser(user.Name)
+        }
+        if err != nil {
+            log.Println(err)
+        }
+        if err := gateCheck(user, q.action); err != nil {
+            quota.Blocked = err.Error()
+        }
+        quotas = append(quotas, quota)
+    }
+
+    comments := limitQuota{Name: "Comments"}
+    if err := gateCheck(user, gate.Comment); err != nil {
+        comments.Blocked = err.Error()
+    }
+    quotas = append(quotas, comments, limitQuota{Name: "Ratings", Blocked: ratingProblem(user)})
+
+    used, reset := graphqlLimiter.Usage(ip)
+    limit, window := graphqlLimiter.Limit()
+    quotas = append(quotas, limitQuota{Name: "GraphQL requests", Used: used, Limit: limit, Per: per + " per " + window.String(), Reset: int(math.Ceil(reset.Seconds()))})
+
+    // Only the anonymous downloads are limited
+    _, anonymous, _ := download.AnonymousUsage(ip)
+    quotas = append(quotas, limitQuota{Name: "Downloads", Per: fmt.Sprintf("not capped while logged in, %d per hour and %s when logged out", anonymous, per)})
+    return quotas
+}
+
+// LimitsGET displays the quotas of the logged in user and how much of them is
+// used, as JSON for the API clients
+func LimitsGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+
+    user, err := model.UserByName(fmt.Sprintf("%s", sess.Values["name"]))
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+    quotas := limitQuotas(r, user)
+
+    if view.Format(r) == view.FormatJSON {
+        apiJSON(w, http.StatusOK, map[string]interface{}{"quotas": quotas})
+        return
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "user/limits"
+    v.Vars["quotas"] = quotas
+    v.Render(w)
+    sess.Save(r, w)
+}
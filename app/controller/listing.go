@@ -0,0 +1,97 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/josephspurrier/csrfbanana"
+)
+
+// listingViewer returns the viewer of the listings, with the preferences of
+// the logged in user
+func listingViewer(r *http.Request) model.Viewer {
+    sess := session.Instance(r)
+    if sess.Values["name"] == nil {
+        return model.Viewer{}
+    }
+
+    user, err := repo.UserByName(fmt.Sprintf("%s", sess.Values["name"]))
+    if err != nil {
+        // List everything rather than failing the page
+        log.Println(err)
+        return model.Viewer{}
+    }
+    return model.ViewerOf(user)
+}
+
+// listingMarks returns what the viewer did on the listed crackmes, by hexid,
+// nothing for the visitors
+func listingMarks(viewer model.Viewer, crackmes []model.Crackme) map[string]string {
+    marks, err := repo.ListingMarks(viewer.Name, crackmes)
+    if err != nil {
+        // List them unmarked rather than failing the page
+        log.Println(err)
+        return map[string]string{}
+    }
+    return marks
+}
+
+// ListingSettingsGET displays the listing preferences of the user
+func ListingSettingsGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    user, err := model.UserByName(username)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+    prefs := model.ViewerOf(user).Prefs
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "user/listing"
+    v.Vars["hidesolved"] = prefs.HideSolved
+    v.Vars["hidearchived"] = prefs.HideArchived
+    v.Vars["langs"] = choices(crackmeLangs, prefs.HideLangs)
+    v.Vars["platforms"] = choices(crackmePlatforms, prefs.HidePlatforms)
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// ListingSettingsPOST stores the listing preferences of the user
+func ListingSettingsPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    if err := r.ParseForm(); err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    // Only the known choices are kept
+    prefs := model.ListingPrefs{
+        HideSolved:    r.FormValue("hidesolved") == "on",
+        HideArchived:  r.FormValue("hidearchived") == "on",
+        HideLangs:     checked(choices(crackmeLangs, r.Form["lang"])),
+        HidePlatforms: checked(choices(crackmePlatforms, r.Form["platform"])),
+    }
+
+    if err := model.ListingSetPrefs(username, prefs); err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    } else {
+        sess.AddFlash(view.Flash{"Your preferences have been saved.", view.FlashSuccess})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/settings/listing", http.StatusFound)
+}
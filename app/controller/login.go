@@ -90,11 +90,18 @@ func LoginPOST(w http.ResponseWriter, r *http.Request) {
         sess.AddFlash(view.Flash{"There was an error. Please try again later.", view.FlashError})
         sess.Save(r, w)
     } else if passhash.MatchString(result.Password, password) {
-        // Login successfully
+        // Login successfully, the form kept when the session expired is
+        // still refilled once logged in again
+        kept := sess.Values[view.KeptFormKey]
         session.Empty(sess)
+        if kept != nil {
+            sess.Values[view.KeptFormKey] = kept
+        }
         sess.AddFlash(view.Flash{"Login successful!", view.FlashSuccess})
         sess.Values["email"] = result.Email
         sess.Values["name"] = result.Name
+        setClock(sess, result)
+        signInRecord(w, r, result)
         sess.Save(r, w)
         http.Redirect(w, r, "/", http.StatusFound)
         return
@@ -115,6 +122,10 @@ func LogoutGET(w http.ResponseWriter, r *http.Request) {
 
     // If user is authenticated
     if sess.Values["name"] != nil {
+        // An admin viewing the site as a user logs out of both, on record
+        if sess.Values[sessImpersonator] != nil {
+            impersonationEnd(sess, r, "logout")
+        }
         session.Empty(sess)
         sess.AddFlash(view.Flash{"Goodbye!", view.FlashNotice})
         sess.Save(r, w)
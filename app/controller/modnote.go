@@ -0,0 +1,99 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "strings"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/gorilla/context"
+    "github.com/josephspurrier/csrfbanana"
+    "github.com/julienschmidt/httprouter"
+    "github.com/kennygrant/sanitize"
+)
+
+// modNoteCounts returns the number of moderator notes of the users, logging
+// the errors since the counts are only a hint
+func modNoteCounts(usernames []string) map[string]int {
+    counts, err := model.ModNoteCounts(usernames)
+    if err != nil {
+        log.Println(err)
+    }
+    return counts
+}
+
+// AdminUserGET displays a user for the moderators, with their private notes
+func AdminUserGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    params := context.Get(r, "params").(httprouter.Params)
+
+    user, err := model.UserByName(params.ByName("name"))
+    if err != nil {
+        Error404(w, r)
+        return
+    }
+
+    notes, err := model.ModNotesByUser(user.Name)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "admin/user"
+    v.Vars["user"] = user
+    v.Vars["created"] = user.ObjectId.Timestamp()
+    v.Vars["notes"] = notes
+    v.Vars["kinds"] = model.ModNoteKinds
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// AdminUserPOST adds or deletes a moderator note on a user
+func AdminUserPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    params := context.Get(r, "params").(httprouter.Params)
+    moderator := fmt.Sprintf("%s", sess.Values["name"])
+
+    user, err := model.UserByName(params.ByName("name"))
+    if err != nil {
+        Error404(w, r)
+        return
+    }
+
+    switch r.FormValue("action") {
+    case "add":
+        text := strings.TrimSpace(sanitize.HTML(r.FormValue("text")))
+        kind := r.FormValue("kind")
+        known := false
+        for _, k := range model.ModNoteKinds {
+            known = known || k == kind
+        }
+        if text == "" || !known {
+            sess.AddFlash(view.Flash{"Write the note and choose its kind.", view.FlashError})
+            break
+        }
+        err = model.ModNoteAdd(user.Name, moderator, kind, text)
+    case "delete":
+        err = model.ModNoteDelete(r.FormValue("note"))
+    default:
+        sess.AddFlash(view.Flash{"Unknown action.", view.FlashError})
+    }
+
+    if err == model.ErrNoResult {
+        sess.AddFlash(view.Flash{"This note doesn't exist anymore.", view.FlashWarning})
+    } else if err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/admin/user/"+user.Name, http.StatusFound)
+}
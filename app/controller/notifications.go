@@ -1,40 +1,77 @@
 package controller
 
 import (
+    "fmt"
     "github.com/crackmesone/crackmes.one/app/model"
     "log"
     "net/http"
-    "time"
 
     "github.com/crackmesone/crackmes.one/app/shared/session"
     "github.com/crackmesone/crackmes.one/app/shared/view"
     "github.com/josephspurrier/csrfbanana"
 )
 
+// NotificationsGET displays the notifications page, which loads the
+// notifications from NotificationsAPIGET
 func NotificationsGET(w http.ResponseWriter, r *http.Request) {
     sess := session.Instance(r)
 
-    notifs, err := model.NotificationsByUser(sess.Values["name"].(string))
+    // Display the view
+    v := view.New(r)
+    v.Name = "notifs/notifs"
+    v.Vars["archived"] = r.URL.Query().Get("archived") == "1"
+    v.Vars["token"] = csrfbanana.TokenWithPath(w, r, sess, "/notifications/delete")
+    v.Vars["archivetoken"] = csrfbanana.TokenWithPath(w, r, sess, "/notifications/archive")
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// NotificationsAPIGET returns, as JSON, a page of notifications of the logged
+// in user and marks them as seen, the archived ones with archived=1
+func NotificationsAPIGET(w http.ResponseWriter, r *http.Request) {
+    username, ok := apiUser(w, r)
+    if !ok {
+        return
+    }
+    page := apiPage(r)
+
+    notifs, err := model.NotificationsByUserPage(username, page, r.URL.Query().Get("archived") == "1")
     if err != nil {
         log.Println(err)
-        Error500(w, r)
+        apiError(w, http.StatusInternalServerError)
         return
     }
 
     for i, _ := range notifs {
-        if !notifs[i].Seen {
+        if !notifs[i].Seen && !impersonating(r) {
             model.NotificationsSetSeen(notifs)
             break
         }
     }
 
-    // Display the view
-    v := view.New(r)
-    v.Name = "notifs/notifs"
-    v.Vars["notifs"] = notifs
-    v.Vars["token"] = csrfbanana.TokenWithPath(w, r, sess, "/notifications/delete")
-    v.Vars["startTime"] = time.Unix(0, 0)
-    v.Render(w)
+    apiJSON(w, http.StatusOK, map[string]interface{}{
+        "notifications": notifs,
+        "page":          page,
+        "next":          len(notifs) == model.NotificationsPerPage,
+    })
+}
+
+// NotificationsUnreadCountGET returns, as JSON, the number of unseen
+// notifications of the logged in user, for the navbar badge
+func NotificationsUnreadCountGET(w http.ResponseWriter, r *http.Request) {
+    username, ok := apiUser(w, r)
+    if !ok {
+        return
+    }
+
+    count, err := model.NotificationsCountUnseen(username)
+    if err != nil {
+        log.Println(err)
+        apiError(w, http.StatusInternalServerError)
+        return
+    }
+
+    apiJSON(w, http.StatusOK, map[string]int64{"unread": count})
 }
 
 func NotificationsDeletePOST(w http.ResponseWriter, r *http.Request) {
@@ -55,3 +92,20 @@ func NotificationsDeletePOST(w http.ResponseWriter, r *http.Request) {
 
     w.WriteHeader(http.StatusOK)
 }
+
+// NotificationsArchivePOST archives all the notifications of the logged in
+// user, emptying their list
+func NotificationsArchivePOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    if err := model.NotificationsArchiveAll(username); err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    } else {
+        sess.AddFlash(view.Flash{"All your notifications have been archived.", view.FlashSuccess})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/notifications", http.StatusFound)
+}
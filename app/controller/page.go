@@ -0,0 +1,208 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "regexp"
+    "strings"
+    "time"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/cache"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/gorilla/context"
+    "github.com/josephspurrier/csrfbanana"
+    "github.com/julienschmidt/httprouter"
+)
+
+const (
+    pageMaxTitle = 100
+    pageMaxBody  = 100000
+)
+
+var (
+    // pageSlugRe matches the slugs pages can be created with
+    pageSlugRe = regexp.MustCompile(`^[a-z0-9-]{1,64}$`)
+
+    // pageBuiltins are the pages still served from a template until they are
+    // written, by slug and URL
+    pageBuiltins = []struct{ Slug, URL string }{
+        {"faq", "/faq"},
+        {"crackme-rules", "/upload/crackmerules"},
+        {"writeup-rules", "/upload/writeuprules"},
+    }
+
+    // pageCache keeps the pages for a few minutes, a missing page is cached
+    // as nil so the pages still served from a template don't hit the database
+    pageCache = cache.New(5 * time.Minute)
+)
+
+// pageBySlug returns the page of a slug, nil if there is none
+func pageBySlug(slug string) (*model.Page, error) {
+    if cached, ok := pageCache.Get(slug); ok {
+        return cached.(*model.Page), nil
+    }
+
+    page, err := model.PageBySlug(slug)
+    if err == model.ErrNoResult {
+        pageCache.Set(slug, (*model.Page)(nil))
+        return nil, nil
+    } else if err != nil {
+        return nil, err
+    }
+
+    pageCache.Set(slug, &page)
+    return &page, nil
+}
+
+// renderPage displays the page of a slug and returns true, or returns false
+// if no such page has been written yet
+func renderPage(w http.ResponseWriter, r *http.Request, slug string) bool {
+    page, err := pageBySlug(slug)
+    if err != nil {
+        log.Println(err)
+    }
+    if page == nil {
+        return false
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "page/page"
+    v.Vars["page"] = page
+    v.Render(w)
+    return true
+}
+
+// PageGET displays a page written by the admins
+func PageGET(w http.ResponseWriter, r *http.Request) {
+    var params httprouter.Params
+    params = context.Get(r, "params").(httprouter.Params)
+
+    if !renderPage(w, r, params.ByName("slug")) {
+        Error404(w, r)
+    }
+}
+
+// AdminPagesGET lists the pages, and the pages still served from a template
+func AdminPagesGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+
+    pages, err := model.Pages()
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "admin/pages"
+    v.Vars["pages"] = pages
+    v.Vars["builtins"] = pageBuiltins
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// AdminPageGET displays the edit form and the history of a page. The
+// "version" query parameter fills the form with an older version, saving it
+// reverts the page.
+func AdminPageGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    var params httprouter.Params
+    params = context.Get(r, "params").(httprouter.Params)
+    slug := params.ByName("slug")
+
+    if !pageSlugRe.MatchString(slug) {
+        Error404(w, r)
+        return
+    }
+
+    page, err := model.PageBySlug(slug)
+    if err != nil && err != model.ErrNoResult {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    versions, err := model.PageVersions(slug)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    // The form is posted back to AdminPagePOST, keep the values on errors
+    title, body := page.Title, page.Body
+    if r.Method == http.MethodPost {
+        title, body = r.FormValue("title"), r.FormValue("body")
+    } else if hexid := r.URL.Query().Get("version"); hexid != "" {
+        version, err := model.PageVersionByHexId(slug, hexid)
+        if err != nil {
+            log.Println(err)
+            Error404(w, r)
+            return
+        }
+        title, body = version.Title, version.Body
+        sess.AddFlash(view.Flash{"The form holds the version of " + version.CreatedAt.Format("01/02/2006 15:04:05") + ", save it to revert the page.", view.FlashNotice})
+        sess.Save(r, w)
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "admin/page"
+    v.Vars["slug"] = slug
+    v.Vars["exists"] = !page.ObjectId.IsZero()
+    v.Vars["title"] = title
+    v.Vars["body"] = body
+    v.Vars["versions"] = versions
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// AdminPagePOST saves a page
+func AdminPagePOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    var params httprouter.Params
+    params = context.Get(r, "params").(httprouter.Params)
+    slug := params.ByName("slug")
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    if !pageSlugRe.MatchString(slug) {
+        Error404(w, r)
+        return
+    }
+
+    if validate, missingField := view.Validate(r, []string{"title", "body"}); !validate {
+        sess.AddFlash(view.Flash{"Field missing: " + missingField, view.FlashError})
+        sess.Save(r, w)
+        AdminPageGET(w, r)
+        return
+    }
+
+    title := strings.TrimSpace(r.FormValue("title"))
+    body := r.FormValue("body")
+    if len(title) > pageMaxTitle || len(body) > pageMaxBody {
+        sess.AddFlash(view.Flash{"The title or the content is too long.", view.FlashError})
+        sess.Save(r, w)
+        AdminPageGET(w, r)
+        return
+    }
+
+    if err := model.PageSave(slug, title, body, username); err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+        sess.Save(r, w)
+        AdminPageGET(w, r)
+        return
+    }
+    pageCache.Delete(slug)
+
+    sess.AddFlash(view.Flash{"Page saved!", view.FlashSuccess})
+    sess.Save(r, w)
+    http.Redirect(w, r, "/admin/page/"+slug, http.StatusFound)
+}
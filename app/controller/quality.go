@@ -13,6 +13,11 @@ import (
     "github.com/julienschmidt/httprouter"
 )
 
+// qualityForm are the rules of the quality vote
+var qualityForm = []*view.Rule{
+    view.Field("quality").Label("quality rating").Required().Range(1, 6),
+}
+
 func RateQualityPOST(w http.ResponseWriter, r *http.Request) {
     // Get session
     var already_exist bool
@@ -22,9 +27,9 @@ func RateQualityPOST(w http.ResponseWriter, r *http.Request) {
     params = context.Get(r, "params").(httprouter.Params)
     crackmehexid := params.ByName("hexid")
 
-    // Validate with required fields
-    if validate, missingField := view.Validate(r, []string{"quality"}); !validate {
-        sess.AddFlash(view.Flash{"Field missing: " + missingField, view.FlashError})
+    // Validate the fields
+    if ok, problem := view.Check(r, qualityForm...); !ok {
+        sess.AddFlash(view.Flash{problem, view.FlashError})
         sess.Save(r, w)
         CrackMeGET(w, r)
         return
@@ -33,11 +38,10 @@ func RateQualityPOST(w http.ResponseWriter, r *http.Request) {
     username := fmt.Sprintf("%s", sess.Values["name"])
     rating := r.FormValue("quality")
 
+    // In range, see qualityForm
     ratingint, _ := strconv.Atoi(rating)
 
-    if ratingint < 1 || ratingint > 6 {
-        log.Println("Wrong rating number")
-        Error500(w, r)
+    if !ratingAllowed(w, r, username, crackmehexid) {
         return
     }
 
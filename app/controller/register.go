@@ -7,11 +7,19 @@ import (
     "github.com/crackmesone/crackmes.one/app/shared/passhash"
     "github.com/crackmesone/crackmes.one/app/shared/recaptcha"
     "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/settings"
     "github.com/crackmesone/crackmes.one/app/shared/view"
     "github.com/josephspurrier/csrfbanana"
     "strings"
 )
 
+// registerForm are the rules of the registration form
+var registerForm = []*view.Rule{
+    view.Field("name").Label("username").Required().MaxLen(32).Chars(view.CharsName),
+    view.Field("email").Required().MaxLen(254).Chars(view.CharsName),
+    view.Field("password").Required().MaxLen(128),
+}
+
 // RegisterGET displays the register page
 func RegisterGET(w http.ResponseWriter, r *http.Request) {
     // Get session
@@ -21,6 +29,9 @@ func RegisterGET(w http.ResponseWriter, r *http.Request) {
     v := view.New(r)
     v.Name = "register/register"
     v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Vars["closed"] = !settings.Bool(settingRegistrationOpen)
+    v.Vars["invite"] = settings.Bool(settingRegistrationInvite)
+    v.Vars["invitecode"] = r.FormValue("invite")
     // Refill any form fields
     view.Repopulate([]string{"name", "email"}, r.Form, v.Vars)
     v.Render(w)
@@ -38,9 +49,16 @@ func RegisterPOST(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    // Validate with required fields
-    if validate, missingField := view.Validate(r, []string{"name", "email", "password"}); !validate {
-        sess.AddFlash(view.Flash{"Field missing: " + missingField, view.FlashError})
+    if !settings.Bool(settingRegistrationOpen) {
+        sess.AddFlash(view.Flash{"Registrations are closed for now, please come back later.", view.FlashError})
+        sess.Save(r, w)
+        http.Redirect(w, r, "/register", http.StatusFound)
+        return
+    }
+
+    // Validate the fields
+    if ok, problem := view.Check(r, registerForm...); !ok {
+        sess.AddFlash(view.Flash{problem, view.FlashError})
         sess.Save(r, w)
         RegisterGET(w, r)
         return
@@ -59,13 +77,6 @@ func RegisterPOST(w http.ResponseWriter, r *http.Request) {
     email := strings.ToLower(r.FormValue("email"))
     password, errp := passhash.HashString(r.FormValue("password"))
 
-    if (!view.AuthorizedCharsOnly(name) || !view.AuthorizedCharsOnly(email)){
-        sess.AddFlash(view.Flash{"Non allowed chars", view.FlashError})
-        sess.Save(r, w)
-        RegisterGET(w, r)
-        return
-    }
-
     // If password hashing failed
     if errp != nil {
         log.Println(errp)
@@ -84,11 +95,26 @@ func RegisterPOST(w http.ResponseWriter, r *http.Request) {
     } else {
         _, err := model.UserByName(name)
 
+        // The invite code is used up before the account is created, so two
+        // registrations can't share it
+        invite := ""
+        if err == model.ErrNoResult && settings.Bool(settingRegistrationInvite) {
+            invite = r.FormValue("invite")
+            if ierr := model.InviteRedeem(invite, name); ierr != nil {
+                err, invite = ierr, ""
+            }
+        }
+
         if err == model.ErrNoResult { // If success (no user exists with that email)
             ex := model.UserCreate(name, email, password)
             // Will only error if there is a problem with the query
             if ex != nil {
                 log.Println(ex)
+                if invite != "" {
+                    if rerr := model.InviteRelease(invite); rerr != nil {
+                        log.Println(rerr)
+                    }
+                }
                 sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
                 sess.Save(r, w)
             } else {
@@ -97,6 +123,9 @@ func RegisterPOST(w http.ResponseWriter, r *http.Request) {
                 http.Redirect(w, r, "/login", http.StatusFound)
                 return
             }
+        } else if err == model.ErrInviteInvalid {
+            sess.AddFlash(view.Flash{err.Error(), view.FlashError})
+            sess.Save(r, w)
         } else if err != nil { // Catch all other errors
             log.Println(err)
             sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
@@ -0,0 +1,154 @@
+package controller
+
+import (
+    "time"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// repository is the part of the model read by the main pages (index, crackme,
+// user, lasts). The handlers go through repo, which the tests replace with a
+// fake so the pages render without MongoDB.
+type repository interface {
+    CountUsers() (int, error)
+    CountCrackmes() (int, error)
+    CountSolutions() (int, error)
+
+    CrackmeByHexId(hexid string) (model.Crackme, error)
+    CrackmeCountView(hexid string) error
+    CrackmesByUser(username string) ([]model.Crackme, error)
+    CrackmesPendingByUser(username string, since time.Time) ([]model.Crackme, error)
+    CrackmesUnlistedByUser(username string) ([]model.Crackme, error)
+    LastCrackMes(page int, viewer model.Viewer) ([]model.Crackme, error)
+    LastCrackMesAggregate(page int, viewer model.Viewer) ([]model.Crackme, error)
+    TrendingCrackmes(n int) ([]model.Crackme, error)
+    ListingMarks(username string, crackmes []model.Crackme) (map[string]string, error)
+
+    SolutionByHexId(hexid string) (model.Solution, error)
+    SolutionsByUser(username string) ([]model.Solution, error)
+    SolutionsPendingByUser(username string, since time.Time) ([]model.Solution, error)
+    HasSolved(username string, crackme primitive.ObjectID) (bool, error)
+    CommentsByUser(username string) ([]model.Comment, error)
+
+    HintsByCrackme(crackmehexid string) ([]model.Hint, error)
+    HintsRevealed(username, crackmehexid string) (map[string]bool, error)
+
+    NotificationsCountUnseen(username string) (int64, error)
+
+    UserByName(name string) (model.User, error)
+    UserProfileByName(name string) (model.UserProfile, error)
+    IsFollowing(follower, followed string) (bool, error)
+    FollowedSolvers(username string, crackme primitive.ObjectID) ([]string, error)
+
+    IsAttempting(username, crackmehexid string) (bool, error)
+    CountAttempters(crackmehexid string) (int, error)
+    AttemptsByUser(username string) ([]model.Attempt, error)
+}
+
+// repo is the repository used by the handlers
+var repo repository = modelRepository{}
+
+// modelRepository is the repository backed by MongoDB, through the model
+type modelRepository struct{}
+
+func (modelRepository) CountUsers() (int, error)     { return model.CountUsers() }
+func (modelRepository) CountCrackmes() (int, error)  { return model.CountCrackmes() }
+func (modelRepository) CountSolutions() (int, error) { return model.CountSolutions() }
+
+func (modelRepository) CrackmeByHexId(hexid string) (model.Crackme, error) {
+    return model.CrackmeByHexId(hexid)
+}
+
+func (modelRepository) CrackmeCountView(hexid string) error {
+    return model.CrackmeCountView(hexid)
+}
+
+func (modelRepository) CrackmesByUser(username string) ([]model.Crackme, error) {
+    return model.CrackmesByUser(username)
+}
+
+func (modelRepository) CrackmesPendingByUser(username string, since time.Time) ([]model.Crackme, error) {
+    return model.CrackmesPendingByUser(username, since)
+}
+
+func (modelRepository) CrackmesUnlistedByUser(username string) ([]model.Crackme, error) {
+    return model.CrackmesUnlistedByUser(username)
+}
+
+func (modelRepository) LastCrackMes(page int, viewer model.Viewer) ([]model.Crackme, error) {
+    return model.LastCrackMes(page, viewer)
+}
+
+func (modelRepository) LastCrackMesAggregate(page int, viewer model.Viewer) ([]model.Crackme, error) {
+    return model.LastCrackMesAggregate(page, viewer)
+}
+
+func (modelRepository) TrendingCrackmes(n int) ([]model.Crackme, error) {
+    return model.TrendingCrackmes(n)
+}
+
+func (modelRepository) ListingMarks(username string, crackmes []model.Crackme) (map[string]string, error) {
+    return model.ListingMarks(username, crackmes)
+}
+
+func (modelRepository) SolutionByHexId(hexid string) (model.Solution, error) {
+    return model.SolutionByHexId(hexid)
+}
+
+func (modelRepository) SolutionsByUser(username string) ([]model.Solution, error) {
+    return model.SolutionsByUser(username)
+}
+
+func (modelRepository) SolutionsPendingByUser(username string, since time.Time) ([]model.Solution, error) {
+    return model.SolutionsPendingByUser(username, since)
+}
+
+func (modelRepository) HasSolved(username string, crackme primitive.ObjectID) (bool, error) {
+    return model.HasSolved(username, crackme)
+}
+
+func (modelRepository) CommentsByUser(username string) ([]model.Comment, error) {
+    return model.CommentsByUser(username)
+}
+
+func (modelRepository) HintsByCrackme(crackmehexid string) ([]model.Hint, error) {
+    return model.HintsByCrackme(crackmehexid)
+}
+
+func (modelRepository) HintsRevealed(username, crackmehexid string) (map[string]bool, error) {
+    return model.HintsRevealed(username, crackmehexid)
+}
+
+func (modelRepository) NotificationsCountUnseen(username string) (int64, error) {
+    return model.NotificationsCountUnseen(username)
+}
+
+func (modelRepository) UserByName(name string) (model.User, error) {
+    return model.UserByName(name)
+}
+
+func (modelRepository) UserProfileByName(name string) (model.UserProfile, error) {
+    return model.UserProfileByName(name)
+}
+
+func (modelRepository) IsFollowing(follower, followed string) (bool, error) {
+    return model.IsFollowing(follower, followed)
+}
+
+func (modelRepository) FollowedSolvers(username string, crackme primitive.ObjectID) ([]string, error) {
+    return model.FollowedSolvers(username, crackme)
+}
+
+func (modelRepository) IsAttempting(username, crackmehexid string) (bool, error) {
+    return model.IsAttempting(username, crackmehexid)
+}
+
+func (modelRepository) CountAttempters(crackmehexid string) (int, error) {
+    return model.CountAttempters(crackmehexid)
+}
+
+func (modelRepository) AttemptsByUser(username string) ([]model.Attempt, error) {
+    return model.AttemptsByUser(username)
+}
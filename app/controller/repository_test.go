@@ -0,0 +1,300 @@
+package controller
+
+import (
+	"strings"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/model"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeRepository is an in-memory repository holding a small fixed site
+type fakeRepository struct {
+	users     []model.User
+	crackmes  []model.Crackme
+	solutions []model.Solution
+	comments  []model.Comment
+	hints     []model.Hint
+	attempts  []model.Attempt
+	follows   map[string][]string // Followed users, by follower
+}
+
+// fakeTime is the date of everything in the fake repository, so the pages
+// render the same on every run
+var fakeTime = time.Date(2024, time.March, 14, 15, 9, 26, 0, time.UTC)
+
+// newFakeRepository returns a fake repository with two users, who uploaded a
+// crackme each and solved the crackme of the other one
+func newFakeRepository() *fakeRepository {
+	f := &fakeRepository{follows: map[string][]string{"bob": {"alice"}}}
+
+	for _, name := range []string{"alice", "bob"} {
+		f.users = append(f.users, model.User{Name: name, Visible: true, NbCrackmes: 1, NbSolutions: 1, NbComments: 1})
+	}
+
+	f.crackmes = []model.Crackme{
+		{
+			HexId: "65f3150e0000000000000001", Name: "KeygenMe #1", Info: "Find a valid serial.\n<b>No patching.</b>",
+			Lang: "C/C++", Arch: "x86-64", Platform: "Linux", Author: "alice", CreatedAt: fakeTime, Visible: true,
+			Difficulty: 2.5, Quality: 4, NbSolutions: 1, NbComments: 1, ShortId: "a1b2c3", Downloads: 42, License: "CC-BY-4.0",
+			Prereqs: []string{"x86-asm", "crypto"}, Readme: "Run it with the serial as argument.",
+		},
+		{
+			HexId: "65f3150e0000000000000002", Name: "Packed & <Obfuscated>", Info: "UPX, then a VM.",
+			Lang: "Assembler", Arch: "x86", Platform: "Windows", Author: "bob", CreatedAt: fakeTime.Add(-time.Hour), Visible: true,
+			Difficulty: 4.2, Quality: 3.5, NbSolutions: 1, NbComments: 0, ShortId: "d4e5f6",
+		},
+	}
+	for i := range f.crackmes {
+		f.crackmes[i].ObjectId, _ = primitive.ObjectIDFromHex(f.crackmes[i].HexId)
+	}
+
+	f.solutions = []model.Solution{
+		{
+			HexId: "65f3150e0000000000000011", Info: "Reversed the checksum.", CrackmeId: f.crackmes[0].ObjectId,
+			CrackmeHexId: f.crackmes[0].HexId, CrackmeName: f.crackmes[0].Name, CreatedAt: fakeTime, Author: "bob", Visible: true, Language: "en",
+		},
+		{
+			HexId: "65f3150e0000000000000012", Info: "Unpacked and traced the VM.", CrackmeId: f.crackmes[1].ObjectId,
+			CrackmeHexId: f.crackmes[1].HexId, CrackmeName: f.crackmes[1].Name, CreatedAt: fakeTime, Author: "alice", Visible: true, Language: "fr",
+		},
+	}
+
+	f.comments = []model.Comment{
+		{Content: "Nice one!", Author: "bob", CrackMeHexId: f.crackmes[0].HexId, CrackmeName: f.crackmes[0].Name, CreatedAt: fakeTime, Visible: true},
+		{Content: "Thanks :)", Author: "alice", CrackMeHexId: f.crackmes[0].HexId, CrackmeName: f.crackmes[0].Name, CreatedAt: fakeTime, Visible: true, ByAuthor: true},
+	}
+
+	f.hints = []model.Hint{
+		{HexId: "65f3150e0000000000000021", CrackmeHexId: f.crackmes[0].HexId, Position: 1, Text: "Look at the main loop.", CreatedAt: fakeTime},
+	}
+
+	return f
+}
+
+func (f *fakeRepository) CountUsers() (int, error)     { return len(f.users), nil }
+func (f *fakeRepository) CountCrackmes() (int, error)  { return len(f.crackmes), nil }
+func (f *fakeRepository) CountSolutions() (int, error) { return len(f.solutions), nil }
+
+func (f *fakeRepository) CrackmeByHexId(hexid string) (model.Crackme, error) {
+	for _, c := range f.crackmes {
+		if c.HexId == hexid {
+			return c, nil
+		}
+	}
+	return model.Crackme{}, model.ErrNoResult
+}
+
+func (f *fakeRepository) CrackmeCountView(hexid string) error {
+	return nil
+}
+
+func (f *fakeRepository) CrackmesByUser(username string) ([]model.Crackme, error) {
+	var result []model.Crackme
+	for _, c := range f.crackmes {
+		if c.Author == username {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeRepository) CrackmesUnlistedByUser(username string) ([]model.Crackme, error) {
+	result := []model.Crackme{}
+	for _, c := range f.crackmes {
+		if c.Author == username && c.Visible && c.Visibility == model.VisibilityUnlisted {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeRepository) CrackmesPendingByUser(username string, since time.Time) ([]model.Crackme, error) {
+	result := []model.Crackme{}
+	for _, c := range f.crackmes {
+		if c.Author == username && !c.Visible && !c.CreatedAt.Before(since) {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeRepository) LastCrackMes(page int, viewer model.Viewer) ([]model.Crackme, error) {
+	if page != 1 {
+		return nil, nil
+	}
+	return f.crackmes, nil
+}
+
+func (f *fakeRepository) LastCrackMesAggregate(page int, viewer model.Viewer) ([]model.Crackme, error) {
+	crackmes, err := f.LastCrackMes(page, viewer)
+	if crackmes == nil {
+		crackmes = []model.Crackme{}
+	}
+	return crackmes, err
+}
+
+func (f *fakeRepository) TrendingCrackmes(n int) ([]model.Crackme, error) {
+	result := []model.Crackme{}
+	for _, c := range f.crackmes {
+		if c.Visible && len(result) < n {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeRepository) ListingMarks(username string, crackmes []model.Crackme) (map[string]string, error) {
+	marks := map[string]string{}
+	for _, c := range crackmes {
+		if attempting, _ := f.IsAttempting(username, c.HexId); attempting {
+			marks[c.HexId] = model.MarkAttempting
+		}
+		if solved, _ := f.HasSolved(username, c.ObjectId); solved {
+			marks[c.HexId] = model.MarkSolved
+		}
+	}
+	return marks, nil
+}
+
+func (f *fakeRepository) SolutionByHexId(hexid string) (model.Solution, error) {
+	for _, s := range f.solutions {
+		if s.HexId == hexid {
+			return s, nil
+		}
+	}
+	return model.Solution{}, model.ErrNoResult
+}
+
+func (f *fakeRepository) SolutionsByUser(username string) ([]model.Solution, error) {
+	var result []model.Solution
+	for _, s := range f.solutions {
+		for _, author := range s.Authors() {
+			if author == username {
+				result = append(result, s)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeRepository) SolutionsPendingByUser(username string, since time.Time) ([]model.Solution, error) {
+	result := []model.Solution{}
+	for _, s := range f.solutions {
+		if s.Author == username && !s.Visible && !s.CreatedAt.Before(since) {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeRepository) HasSolved(username string, crackme primitive.ObjectID) (bool, error) {
+	for _, s := range f.solutions {
+		if s.CrackmeId == crackme && s.Author == username && s.Visible {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeRepository) CommentsByUser(username string) ([]model.Comment, error) {
+	var result []model.Comment
+	for _, c := range f.comments {
+		if c.Author == username {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeRepository) HintsByCrackme(crackmehexid string) ([]model.Hint, error) {
+	var result []model.Hint
+	for _, h := range f.hints {
+		if h.CrackmeHexId == crackmehexid {
+			result = append(result, h)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeRepository) HintsRevealed(username, crackmehexid string) (map[string]bool, error) {
+	return map[string]bool{}, nil
+}
+
+func (f *fakeRepository) NotificationsCountUnseen(username string) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeRepository) UserByName(name string) (model.User, error) {
+	for _, u := range f.users {
+		if strings.EqualFold(u.Name, name) {
+			return u, nil
+		}
+	}
+	return model.User{}, model.ErrNoResult
+}
+
+func (f *fakeRepository) UserProfileByName(name string) (model.UserProfile, error) {
+	user, err := f.UserByName(name)
+	if err != nil {
+		return model.UserProfile{}, err
+	}
+	profile := model.UserProfile{User: user}
+	profile.Crackmes, _ = f.CrackmesByUser(user.Name)
+	profile.Solutions, _ = f.SolutionsByUser(user.Name)
+	profile.Comments, _ = f.CommentsByUser(user.Name)
+	profile.Normalize()
+	return profile, nil
+}
+
+func (f *fakeRepository) IsFollowing(follower, followed string) (bool, error) {
+	for _, name := range f.follows[follower] {
+		if name == followed {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeRepository) FollowedSolvers(username string, crackme primitive.ObjectID) ([]string, error) {
+	var result []string
+	for _, s := range f.solutions {
+		if s.CrackmeId == crackme {
+			if following, _ := f.IsFollowing(username, s.Author); following {
+				result = append(result, s.Author)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeRepository) IsAttempting(username, crackmehexid string) (bool, error) {
+	for _, a := range f.attempts {
+		if a.User == username && a.CrackmeHexId == crackmehexid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeRepository) CountAttempters(crackmehexid string) (int, error) {
+	nb := 0
+	for _, a := range f.attempts {
+		if a.CrackmeHexId == crackmehexid {
+			nb++
+		}
+	}
+	return nb, nil
+}
+
+func (f *fakeRepository) AttemptsByUser(username string) ([]model.Attempt, error) {
+	result := []model.Attempt{}
+	for _, a := range f.attempts {
+		if a.User == username {
+			result = append(result, a)
+		}
+	}
+	return result, nil
+}
@@ -1,11 +1,23 @@
 package controller
 
 import (
-    "github.com/crackmesone/crackmes.one/app/model"
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
     "encoding/xml"
     "log"
     "net/http"
+    "sort"
     "time"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/cache"
+    "github.com/crackmesone/crackmes.one/app/shared/download"
+    "github.com/crackmesone/crackmes.one/app/shared/site"
+
+    "github.com/gorilla/context"
+    "github.com/julienschmidt/httprouter"
 )
 
 type item struct {
@@ -16,6 +28,14 @@ type item struct {
     Category    string `xml:"category"`
     Guid        string `xml:"guid"`
     PubDate     string `xml:"pubDate"`
+    Enclosure   *enclosure `xml:"enclosure,omitempty"`
+}
+
+// enclosure is the file of an item, for the feed readers downloading them
+type enclosure struct {
+    URL    string `xml:"url,attr"`
+    Length int64  `xml:"length,attr"`
+    Type   string `xml:"type,attr"`
 }
 
 type rss struct {
@@ -28,23 +48,60 @@ type rss struct {
 
 var diffs = []string{"Very Easy", "Easy", "Medium", "Hard", "Very Hard", "Insane"}
 
-func RssCrackmesGET(w http.ResponseWriter, r *http.Request) {
-    crackmes, err := model.LastCrackMes(1)
+// feedCache keeps the generated feeds for a few minutes, feed readers poll
+// them far more often than crackmes are approved
+var feedCache = cache.New(5 * time.Minute)
+
+// feed is a generated feed, with the validators of the conditional requests
+type feed struct {
+    body        []byte
+    contentType string
+    etag        string
+    modified    time.Time // Date of the newest item
+}
+
+// newFeed returns the feed of body, its ETag being a hash of it
+func newFeed(body []byte, contentType string, modified time.Time) feed {
+    sum := sha256.Sum256(body)
+    return feed{body: body, contentType: contentType, etag: "\"" + hex.EncodeToString(sum[:16]) + "\"", modified: modified}
+}
+
+// serveFeed sends a feed, or 304 Not Modified when the reader already has it
+// according to its If-None-Match or If-Modified-Since headers
+func serveFeed(w http.ResponseWriter, r *http.Request, f feed) {
+    w.Header().Set("Content-Type", f.contentType)
+    w.Header().Set("ETag", f.etag)
+    w.Header().Set("Cache-Control", "public, max-age=300")
+    http.ServeContent(w, r, "", f.modified, bytes.NewReader(f.body))
+}
+
+// feedEntry is a crackme of the feeds
+type feedEntry struct {
+    crackme   model.Crackme
+    title     string
+    link      string
+    enclosure string // Signed download URL of the crackme file
+}
+
+// feedEntries returns the latest crackmes for the feeds, and the date of the
+// newest one
+func feedEntries() ([]feedEntry, time.Time, error) {
+    var modified time.Time
+    crackmes, err := model.LastCrackMes(1, model.Viewer{})
     if err != nil {
-        log.Println(err)
-        Error500(w, r)
-        return
+        return nil, modified, err
     }
 
-    var items []item
+    var entries []feedEntry
     for _, v := range(crackmes) {
+        if v.CreatedAt.After(modified) {
+            modified = v.CreatedAt
+        }
 
         var difficulty float64
         difficulties, err := model.RatingDifficultyByCrackme(v.HexId)
         if err != nil {
-            log.Println(err)
-            Error500(w, r)
-            return
+            return nil, modified, err
         }
 
         for _, d := range difficulties {
@@ -52,20 +109,53 @@ func RssCrackmesGET(w http.ResponseWriter, r *http.Request) {
         }
         difficulty /= float64(len(difficulties))
 
-        items = append(items, item{
-            Title: v.Name+" ["+v.Platform+" - "+v.Lang+" - "+diffs[int(difficulty) - 1]+"]",
+        entries = append(entries, feedEntry{
+            crackme: v,
+            title: v.Name+" ["+v.Platform+" - "+v.Lang+" - "+diffs[int(difficulty) - 1]+"]",
+            link: site.URL("/crackme/"+v.HexId),
+            enclosure: site.URL(download.URL(download.KindCrackme, v.HexId)),
+        })
+    }
+    return entries, modified, nil
+}
+
+// RssCrackmesGET serves the RSS feed of the latest crackmes, generated at most
+// once per feedCache period
+func RssCrackmesGET(w http.ResponseWriter, r *http.Request) {
+    if cached, ok := feedCache.Get("rss"); ok {
+        serveFeed(w, r, cached.(feed))
+        return
+    }
+
+    entries, modified, err := feedEntries()
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    var items []item
+    for _, e := range entries {
+        v := e.crackme
+        it := item{
+            Title: e.title,
             Description: v.Info,
             Author: v.Author,
             PubDate: v.CreatedAt.Format(time.RFC1123Z),
             Category: v.Platform,
-            Link: "https://crackmes.one/crackme/"+v.HexId,
-            Guid: "https://crackmes.one/crackme/"+v.HexId,
-        })
+            Link: e.link,
+            Guid: e.link,
+        }
+        // The length is required, the crackmes without a recorded size have none
+        if v.Size > 0 {
+            it.Enclosure = &enclosure{URL: e.enclosure, Length: v.Size, Type: "application/zip"}
+        }
+        items = append(items, it)
     }
     crss := rss{
         Version: "2.0",
         Title: "Latest crackmes - crackmes.one",
-        Link: "https://crackmes.one/lasts",
+        Link: site.URL("/lasts"),
         Description: "The latest 50 crackmes from crackmes.one",
         Items: items,
     }
@@ -77,7 +167,187 @@ func RssCrackmesGET(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    w.Header().Set("content-type", "application/rss+xml; charset=utf-8")
-    w.WriteHeader(http.StatusOK)
-    w.Write(b)
+    f := newFeed(b, "application/rss+xml; charset=utf-8", modified)
+    feedCache.Set("rss", f)
+    serveFeed(w, r, f)
+}
+
+// crackmeFeedMax is the number of solutions and comments of the feed of a
+// crackme
+const crackmeFeedMax = 50
+
+// RssCrackmeGET serves the RSS feed of the approved solutions and the comments
+// of a crackme, newest first, so its author can watch it from a feed reader
+func RssCrackmeGET(w http.ResponseWriter, r *http.Request) {
+    params := context.Get(r, "params").(httprouter.Params)
+    hexid := params.ByName("hexid")
+
+    key := "rss/" + hexid
+    if cached, ok := feedCache.Get(key); ok {
+        serveFeed(w, r, cached.(feed))
+        return
+    }
+
+    crackme, err := model.CrackmeByHexId(hexid)
+    if err == model.ErrNoResult {
+        Error404(w, r)
+        return
+    } else if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    solutions, err := model.SolutionsByCrackme(crackme.ObjectId)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+    comments, err := model.CommentsByCrackMe(crackme.HexId)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    link := site.URL("/crackme/" + crackme.HexId)
+    type dated struct {
+        item
+        date time.Time
+    }
+    var all []dated
+    for _, s := range solutions {
+        all = append(all, dated{item{
+            Title:       "Writeup by " + s.Author,
+            Link:        link,
+            Description: s.Info,
+            Author:      s.Author,
+            Category:    "writeup",
+            Guid:        link + "#solution-" + s.HexId,
+            PubDate:     s.CreatedAt.Format(time.RFC1123Z),
+        }, s.CreatedAt})
+    }
+    for _, c := range comments {
+        all = append(all, dated{item{
+            Title:       "Comment by " + c.Author,
+            Link:        link,
+            Description: c.Content,
+            Author:      c.Author,
+            Category:    "comment",
+            Guid:        link + "#comment-" + c.ObjectId.Hex(),
+            PubDate:     c.CreatedAt.Format(time.RFC1123Z),
+        }, c.CreatedAt})
+    }
+    sort.SliceStable(all, func(i, j int) bool { return all[i].date.After(all[j].date) })
+    if len(all) > crackmeFeedMax {
+        all = all[:crackmeFeedMax]
+    }
+
+    modified := crackme.CreatedAt
+    items := []item{}
+    for _, d := range all {
+        if d.date.After(modified) {
+            modified = d.date
+        }
+        items = append(items, d.item)
+    }
+
+    b, err := xml.Marshal(rss{
+        Version:     "2.0",
+        Title:       crackme.Name + " by " + crackme.Author + " - crackmes.one",
+        Link:        link,
+        Description: "The latest writeups and comments of " + crackme.Name,
+        Items:       items,
+    })
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    f := newFeed(b, "application/rss+xml; charset=utf-8", modified)
+    feedCache.Set(key, f)
+    serveFeed(w, r, f)
+}
+
+// jsonFeedItem is an item of the JSON Feed, see https://jsonfeed.org/version/1.1
+type jsonFeedItem struct {
+    Id            string               `json:"id"`
+    URL           string               `json:"url"`
+    Title         string               `json:"title"`
+    ContentText   string               `json:"content_text"`
+    DatePublished string               `json:"date_published"`
+    Authors       []jsonFeedAuthor     `json:"authors"`
+    Tags          []string             `json:"tags"`
+    Attachments   []jsonFeedAttachment `json:"attachments,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+    Name string `json:"name"`
+    URL  string `json:"url"`
+}
+
+type jsonFeedAttachment struct {
+    URL         string `json:"url"`
+    MimeType    string `json:"mime_type"`
+    SizeInBytes int64  `json:"size_in_bytes,omitempty"`
+}
+
+type jsonFeed struct {
+    Version     string         `json:"version"`
+    Title       string         `json:"title"`
+    HomePageURL string         `json:"home_page_url"`
+    FeedURL     string         `json:"feed_url"`
+    Description string         `json:"description"`
+    Items       []jsonFeedItem `json:"items"`
+}
+
+// JSONFeedCrackmesGET serves the latest crackmes as a JSON Feed, with the
+// same caching as the RSS feed
+func JSONFeedCrackmesGET(w http.ResponseWriter, r *http.Request) {
+    if cached, ok := feedCache.Get("json"); ok {
+        serveFeed(w, r, cached.(feed))
+        return
+    }
+
+    entries, modified, err := feedEntries()
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    items := []jsonFeedItem{}
+    for _, e := range entries {
+        v := e.crackme
+        items = append(items, jsonFeedItem{
+            Id:            e.link,
+            URL:           e.link,
+            Title:         e.title,
+            ContentText:   v.Info,
+            DatePublished: v.CreatedAt.Format(time.RFC3339),
+            Authors:       []jsonFeedAuthor{{Name: v.Author, URL: site.URL("/user/" + v.Author)}},
+            Tags:          []string{v.Platform, v.Lang, v.Arch},
+            Attachments:   []jsonFeedAttachment{{URL: e.enclosure, MimeType: "application/zip", SizeInBytes: v.Size}},
+        })
+    }
+
+    b, err := json.Marshal(jsonFeed{
+        Version:     "https://jsonfeed.org/version/1.1",
+        Title:       "Latest crackmes - crackmes.one",
+        HomePageURL: site.URL("/lasts"),
+        FeedURL:     site.URL("/feed/crackme.json"),
+        Description: "The latest 50 crackmes from crackmes.one",
+        Items:       items,
+    })
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    f := newFeed(b, "application/feed+json; charset=utf-8", modified)
+    feedCache.Set("json", f)
+    serveFeed(w, r, f)
 }
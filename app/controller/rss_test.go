@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServeFeedConditional(t *testing.T) {
+	f := newFeed([]byte("<rss/>"), "application/rss+xml; charset=utf-8", fakeTime)
+
+	get := func(header, value string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/rss/crackme", nil)
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		serveFeed(w, r, f)
+		return w
+	}
+
+	if w := get("", ""); w.Code != http.StatusOK || w.Body.String() != "<rss/>" || w.Header().Get("ETag") != f.etag {
+		t.Errorf("plain GET: got %d %q, ETag %q", w.Code, w.Body.String(), w.Header().Get("ETag"))
+	}
+	if w := get("If-None-Match", f.etag); w.Code != http.StatusNotModified {
+		t.Errorf("same ETag: got %d", w.Code)
+	}
+	if w := get("If-None-Match", `"other"`); w.Code != http.StatusOK {
+		t.Errorf("other ETag: got %d", w.Code)
+	}
+	if w := get("If-Modified-Since", fakeTime.Add(time.Hour).Format(http.TimeFormat)); w.Code != http.StatusNotModified {
+		t.Errorf("not modified since: got %d", w.Code)
+	}
+	if w := get("If-Modified-Since", fakeTime.Add(-time.Hour).Format(http.TimeFormat)); w.Code != http.StatusOK {
+		t.Errorf("modified since: got %d", w.Code)
+	}
+}
@@ -8,15 +8,45 @@ import (
     "github.com/josephspurrier/csrfbanana"
     "github.com/crackmesone/crackmes.one/app/shared/view"
     "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/feature"
 )
 
+// featureSearchWriteupLang gates the filter of the search on the language of
+// the writeups
+const featureSearchWriteupLang = "search-writeup-language"
+
+func init() {
+    feature.Register(featureSearchWriteupLang, "Filter of the search on the language of the writeups", feature.State{Enabled: true, Percent: 100})
+}
+
 // AboutGET displays the About page
 func SearchGET(w http.ResponseWriter, r *http.Request) {
+    if r.FormValue("mode") == searchModeWriteups {
+        searchWriteups(w, r)
+        return
+    }
+
+    // Exports run the search straight from the query parameters
+    if view.Format(r) != view.FormatHTML {
+        SearchPOST(w, r)
+        return
+    }
+
     // Display the view
     sess := session.Instance(r)
     v := view.New(r)
     v.Name = "search/search"
     v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Vars["languages"] = model.WriteupLanguages
+    v.Vars["prerequisites"] = model.Prerequisites
+    v.Vars["archivefilters"] = model.ArchiveFilters
+    v.Vars["sorts"] = model.SearchSorts
+    v.Vars["mode"] = ""
+    v.Vars["q"] = ""
+    v.Vars["archived"] = ""
+    v.Vars["sort"] = ""
+    v.Vars["order"] = ""
+    v.Vars["writeuplangfilter"] = featureEnabled(r, featureSearchWriteupLang)
     v.Render(w)
     sess.Save(r, w)
 }
@@ -36,13 +66,27 @@ func SearchPOST(w http.ResponseWriter, r *http.Request) {
     lang := r.FormValue("lang")
     arch := r.FormValue("arch")
     platform := r.FormValue("platform")
+    writeuplang := r.FormValue("writeuplang")
+    prerequisite := r.FormValue("prerequisite")
+    archived := r.FormValue("archived")
+    sortBy := r.FormValue("sort")
+    order := r.FormValue("order")
+    if !featureEnabled(r, featureSearchWriteupLang) {
+        writeuplang = ""
+    }
 
     difficulty_min_int, _ = strconv.Atoi(difficulty_min)
-    difficulty_max_int, _ = strconv.Atoi(difficulty_max)
+    difficulty_max_int, err := strconv.Atoi(difficulty_max)
+    if err != nil {
+        difficulty_max_int = 6
+    }
     quality_min_int, _ = strconv.Atoi(quality_min)
-    quality_max_int, _ = strconv.Atoi(quality_max)
+    quality_max_int, err = strconv.Atoi(quality_max)
+    if err != nil {
+        quality_max_int = 6
+    }
 
-    crackmes, err := model.SearchCrackme(name, author, lang, arch, platform, difficulty_min_int, difficulty_max_int, quality_min_int, quality_max_int)
+    crackmes, err := model.SearchCrackme(name, author, lang, arch, platform, writeuplang, prerequisite, archived, difficulty_min_int, difficulty_max_int, quality_min_int, quality_max_int, sortBy, order == "asc")
     if err != nil {
         log.Println(err)
         Error500(w, r)
@@ -51,10 +95,25 @@ func SearchPOST(w http.ResponseWriter, r *http.Request) {
 
     //crackmes = CrackMeConvertDiffToImg(crackmes)
 
+    if renderCrackmesExport(w, r, "search", crackmes) {
+        return
+    }
+
     v := view.New(r)
     v.Name = "search/search"
     v.Vars["token"] = csrfbanana.Token(w, r, sess)
     v.Vars["crackmes"] = crackmes
+    v.Vars["marks"] = listingMarks(listingViewer(r), crackmes)
+    v.Vars["languages"] = model.WriteupLanguages
+    v.Vars["prerequisites"] = model.Prerequisites
+    v.Vars["archivefilters"] = model.ArchiveFilters
+    v.Vars["sorts"] = model.SearchSorts
+    v.Vars["mode"] = ""
+    v.Vars["q"] = ""
+    v.Vars["archived"] = archived
+    v.Vars["sort"] = sortBy
+    v.Vars["order"] = order
+    v.Vars["writeuplangfilter"] = featureEnabled(r, featureSearchWriteupLang)
     sess.Save(r, w)
     v.Render(w)
 }
@@ -0,0 +1,125 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "strconv"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/route/middleware/pprofhandler"
+    "github.com/crackmesone/crackmes.one/app/shared/download"
+    "github.com/crackmesone/crackmes.one/app/shared/gate"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/settings"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+    "github.com/josephspurrier/csrfbanana"
+)
+
+// The operational knobs read by the controllers
+const (
+    settingUploadSize       = "upload.size"
+    settingRegistrationOpen = "registration.open"
+)
+
+func init() {
+    settings.Register(settings.Setting{Name: settingUploadSize, Description: "Size of an upload", Unit: "MB", Min: 1, Max: 5},
+        func() int { return 5 }, nil)
+    settings.Register(settings.Setting{Name: settingRegistrationOpen, Description: "Registration of new accounts", Switch: true},
+        func() int { return 1 }, nil)
+
+    // The approval requirements of the gates
+    for _, action := range []string{gate.Comment, gate.Crackme, gate.Solution} {
+        action := action
+        settings.Register(settings.Setting{Name: "gate." + action + ".age", Description: "Age of an account to " + action, Unit: "minutes", Min: 0, Max: 60 * 24 * 30},
+            func() int { return gate.ReadConfig()[action].MinAccountMinutes },
+            func(v int) { gateRule(action, func(rule *gate.Rule) { rule.MinAccountMinutes = v }) })
+    }
+    for _, action := range []string{gate.Crackme, gate.Solution} {
+        action := action
+        settings.Register(settings.Setting{Name: "gate." + action + ".pending", Description: "Submissions waiting for approval of an account, " + action + "s", Min: 1, Max: 100},
+            func() int { return gate.MaxPending(action) },
+            func(v int) { gateRule(action, func(rule *gate.Rule) { rule.MaxPending = v }) })
+    }
+
+    // The rate limits
+    settings.Register(settings.Setting{Name: "ratelimit.graphql", Description: "GraphQL requests of an IP", Unit: "per minute", Min: 1, Max: 1000},
+        func() int { limit, _ := graphqlLimiter.Limit(); return limit },
+        graphqlLimiter.SetLimit)
+    settings.Register(settings.Setting{Name: "ratelimit.download", Description: "Anonymous downloads of an IP", Unit: "per hour", Min: 1, Max: 1000},
+        func() int { return download.ReadConfig().AnonymousLimit },
+        download.SetAnonymousLimit)
+    settings.Register(settings.Setting{Name: "ratelimit.preview", Description: "Previews of the texts of an IP", Unit: "per minute", Min: 1, Max: 1000},
+        func() int { limit, _ := previewLimiter.Limit(); return limit },
+        previewLimiter.SetLimit)
+
+    // The runtime profiles of /debug/pprof, off unless an admin samples them
+    settings.Register(settings.Setting{Name: "pprof.block", Description: "Block profile, one sample per time blocked, 0 for off", Unit: "ns", Min: 0, Max: 1000000000},
+        pprofhandler.BlockProfileRate,
+        pprofhandler.SetBlockProfileRate)
+    settings.Register(settings.Setting{Name: "pprof.mutex", Description: "Mutex profile, one sample per contentions, 0 for off", Unit: "contentions", Min: 0, Max: 100000},
+        pprofhandler.MutexProfileFraction,
+        pprofhandler.SetMutexProfileFraction)
+}
+
+// gateRule changes the rule of a gated action
+func gateRule(action string, change func(*gate.Rule)) {
+    rules := gate.Info{}
+    for a, rule := range gate.ReadConfig() {
+        rules[a] = rule
+    }
+    rule := rules[action]
+    change(&rule)
+    rules[action] = rule
+    gate.Configure(rules)
+}
+
+// maxUploadSize returns the size in bytes the uploaded files can't exceed
+func maxUploadSize() int64 {
+    return int64(settings.Int(settingUploadSize)) * 1000000
+}
+
+// AdminSettingsGET lists the operational knobs with their value
+func AdminSettingsGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "admin/settings"
+    v.Vars["settings"] = settings.Settings()
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// AdminSettingsPOST sets the value of a knob and logs it in the audit log
+func AdminSettingsPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+    name := r.FormValue("name")
+
+    value, err := strconv.Atoi(r.FormValue("value"))
+    if r.FormValue("switch") != "" {
+        value, err = 0, nil
+        if r.FormValue("value") != "" {
+            value = 1
+        }
+    }
+    previous := settings.Int(name)
+    if err != nil {
+        sess.AddFlash(view.Flash{"The value must be a number.", view.FlashError})
+    } else if err = settings.Validate(name, value); err != nil {
+        sess.AddFlash(view.Flash{err.Error(), view.FlashError})
+    } else if err = model.SettingSave(name, value, username); err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    } else {
+        if err := model.AuditAdd(username, model.AuditSettingChange, "", fmt.Sprintf("%s %d -> %d", name, previous, value), auditIP(r)); err != nil {
+            log.Println(err)
+        }
+        sess.AddFlash(view.Flash{"Setting " + name + " saved!", view.FlashSuccess})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/admin/settings", http.StatusFound)
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+    "log"
+    "net/http"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/qrcode"
+    "github.com/crackmesone/crackmes.one/app/shared/site"
+
+    "github.com/gorilla/context"
+    "github.com/julienschmidt/httprouter"
+)
+
+// ShortLinkGET redirects a short link to its crackme
+func ShortLinkGET(w http.ResponseWriter, r *http.Request) {
+    var params httprouter.Params
+    params = context.Get(r, "params").(httprouter.Params)
+
+    crackme, err := model.CrackmeByShortId(params.ByName("shortid"))
+    if err == model.ErrNoResult {
+        Error404(w, r)
+        return
+    } else if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    http.Redirect(w, r, "/crackme/"+crackme.HexId, http.StatusMovedPermanently)
+}
+
+// CrackMeQRGET renders a PNG QR code of the short link of a crackme
+func CrackMeQRGET(w http.ResponseWriter, r *http.Request) {
+    var params httprouter.Params
+    params = context.Get(r, "params").(httprouter.Params)
+
+    crackme, err := model.CrackmeByHexId(params.ByName("hexid"))
+    if err != nil {
+        log.Println(err)
+        Error404(w, r)
+        return
+    }
+
+    // The full link for the pending crackmes, their short id is given at
+    // their approval
+    link := site.URL("/crackme/" + crackme.HexId)
+    if crackme.ShortId != "" {
+        link = site.URL("/c/" + crackme.ShortId)
+    }
+
+    png, err := qrcode.PNG(link, 4)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    w.Header().Set("Content-Type", "image/png")
+    w.Header().Set("Cache-Control", "public, max-age=86400")
+    w.Write(png)
+}
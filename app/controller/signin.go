@@ -0,0 +1,174 @@
+package controller
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "fmt"
+    "log"
+    "net"
+    "net/http"
+    "time"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/download"
+    "github.com/crackmesone/crackmes.one/app/shared/mail"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/site"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/josephspurrier/csrfbanana"
+)
+
+// deviceCookie is the long lived cookie telling the devices apart, it
+// outlives the sessions
+const deviceCookie = "device"
+
+// deviceCookieAge is how long a device is remembered without signing in
+const deviceCookieAge = 2 * 365 * 24 * time.Hour
+
+// signInDevice returns the device id of the request, after giving one to the
+// devices without
+func signInDevice(w http.ResponseWriter, r *http.Request) string {
+    if c, err := r.Cookie(deviceCookie); err == nil && len(c.Value) == 32 {
+        if _, err := hex.DecodeString(c.Value); err == nil {
+            return c.Value
+        }
+    }
+
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        log.Println(err)
+    }
+    device := hex.EncodeToString(b)
+    http.SetCookie(w, &http.Cookie{
+        Name:     deviceCookie,
+        Value:    device,
+        Path:     "/",
+        MaxAge:   int(deviceCookieAge.Seconds()),
+        HttpOnly: true,
+        Secure:   r.TLS != nil,
+        SameSite: http.SameSiteLaxMode,
+    })
+    return device
+}
+
+// signInLocation returns the approximate location of an IP address: its
+// network, and the country when the CDN in front tells it
+func signInLocation(r *http.Request, ip string) string {
+    location := ip
+    if parsed := net.ParseIP(ip); parsed == nil {
+        location = "unknown network"
+    } else if v4 := parsed.To4(); v4 != nil {
+        location = v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+    } else {
+        location = parsed.Mask(net.CIDRMask(48, 128)).String() + "/48"
+    }
+    if header := download.ReadConfig().CountryHeader; header != "" {
+        if country := r.Header.Get(header); country != "" && country != "XX" {
+            location = country + ", " + location
+        }
+    }
+    return location
+}
+
+// signInMail is the email of the sign-ins from a new device
+var signInMail = mail.MustTemplate("New sign-in to your crackmes.one account",
+    "Hello {{.Name}},\n\nSomebody signed in to your crackmes.one account from a new device.\n\n"+
+    "Time: {{.Time}}\nLocation: {{.Location}}\nBrowser: {{.Browser}}\n\n"+
+    "If it was you, there is nothing to do. Otherwise change your password right away at {{.URL}}\n")
+
+// signInRecord records the sign-in of a user and alerts them, on the site and
+// by email if they want, when it comes from a device or an address never seen
+// for their account
+func signInRecord(w http.ResponseWriter, r *http.Request, user model.User) {
+    ip := auditIP(r)
+    location := signInLocation(r, ip)
+    unseen, err := model.SignInRecord(user.Name, signInDevice(w, r), ip, r.UserAgent(), location)
+    if err != nil {
+        log.Println(err)
+        return
+    }
+    if !unseen {
+        return
+    }
+
+    when := time.Now().UTC().Format("2006-01-02 15:04 MST")
+    text := fmt.Sprintf("New sign-in to your account on %s from %s. If it wasn't you, change your password.", when, location)
+    if err := model.NotificationAdd(user.Name, text); err != nil {
+        log.Println(err)
+    }
+
+    if !user.SignInEmail || user.Email == "" || !mail.Available() {
+        return
+    }
+    m, err := signInMail.Message(user.Email, map[string]string{
+        "Name": user.Name, "Time": when, "Location": location, "Browser": r.UserAgent(), "URL": site.URL("/change-password"),
+    })
+    if err != nil {
+        log.Println(err)
+        return
+    }
+    mail.Queue(m)
+}
+
+// SignInsGET displays the devices the logged in user signed in from
+func SignInsGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+
+    user, err := model.UserByName(fmt.Sprintf("%s", sess.Values["name"]))
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+    signins, err := model.SignInsByUser(user.Name)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+    device := ""
+    if c, err := r.Cookie(deviceCookie); err == nil {
+        device = c.Value
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "user/signins"
+    v.Vars["signins"] = signins
+    v.Vars["device"] = device
+    v.Vars["email"] = user.SignInEmail
+    v.Vars["available"] = mail.Available()
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// SignInsPOST turns the sign-in emails of the logged in user on or off, or
+// forgets their devices
+func SignInsPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    if r.FormValue("action") == "forget" {
+        if err := model.SignInForget(username); err != nil {
+            log.Println(err)
+            sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+        } else {
+            sess.AddFlash(view.Flash{"Your devices were forgotten.", view.FlashSuccess})
+        }
+    } else {
+        on := r.FormValue("email") == "on"
+        if err := model.UserSetSignInEmail(username, on); err != nil {
+            log.Println(err)
+            sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+        } else if on {
+            sess.AddFlash(view.Flash{"You will be emailed about the sign-ins from new devices.", view.FlashSuccess})
+        } else {
+            sess.AddFlash(view.Flash{"You will only be notified on the site about the sign-ins from new devices.", view.FlashNotice})
+        }
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/settings/signins", http.StatusFound)
+}
@@ -10,8 +10,11 @@ import (
 	"strings"
 
 	"github.com/crackmesone/crackmes.one/app/model"
+	"github.com/crackmesone/crackmes.one/app/shared/gate"
+	"github.com/crackmesone/crackmes.one/app/shared/preview"
 	"github.com/crackmesone/crackmes.one/app/shared/recaptcha"
 	"github.com/crackmesone/crackmes.one/app/shared/session"
+	"github.com/crackmesone/crackmes.one/app/shared/similarity"
 	"github.com/crackmesone/crackmes.one/app/shared/view"
 
 	"github.com/gorilla/context"
@@ -20,6 +23,71 @@ import (
 	"github.com/kennygrant/sanitize"
 )
 
+// Limits of the writeup sections of the upload form
+const (
+    writeupMinApproach = 100   // Characters the approach must at least have
+    writeupMaxSection  = 10000 // Characters a section can have at most
+)
+
+// writeupSections returns the writeup sections of the upload form, or the
+// problem with them
+func writeupSections(r *http.Request) (model.WriteupSections, string) {
+    var sections model.WriteupSections
+    for _, section := range []struct {
+        field, label string
+        text         *string
+    }{
+        {"tools", "tools used", &sections.Tools},
+        {"approach", "approach", &sections.Approach},
+        {"key", "key", &sections.Key},
+        {"patch", "patch", &sections.Patch},
+    } {
+        filtered, problem := postedText(r.FormValue(section.field), section.label, writeupMaxSection)
+        if problem != "" {
+            return sections, problem
+        }
+        *section.text = filtered.Text
+    }
+
+    if sections.Tools == "" {
+        return sections, "Field missing: tools used"
+    }
+    if sections.Approach == "" {
+        return sections, "Field missing: approach"
+    }
+    if len([]rune(sections.Approach)) < writeupMinApproach {
+        return sections, fmt.Sprintf("Please describe your approach in at least %d characters.", writeupMinApproach)
+    }
+    return sections, ""
+}
+
+// writeupCoAuthors returns the registered users listed in the co-authors
+// field of the upload form, by their exact name, or the problem with them
+func writeupCoAuthors(r *http.Request, username string) ([]string, string) {
+    var coauthors []string
+    seen := map[string]bool{strings.ToLower(username): true}
+    for _, name := range strings.FieldsFunc(r.FormValue("coauthors"), func(c rune) bool {
+        return c == ',' || c == ' '
+    }) {
+        if seen[strings.ToLower(name)] {
+            continue
+        }
+        seen[strings.ToLower(name)] = true
+        if len(coauthors) == model.MaxCoAuthors {
+            return nil, fmt.Sprintf("A writeup can't have more than %d co-authors.", model.MaxCoAuthors)
+        }
+        user, err := model.UserByName(name)
+        if err != nil {
+            if err != model.ErrNoResult {
+                log.Println(err)
+            }
+            return nil, "The co-author " + name + " isn't a registered user."
+        }
+        coauthors = append(coauthors, user.Name)
+    }
+    return coauthors, ""
+}
+
 func UploadSolutionGET(w http.ResponseWriter, r *http.Request) {
     // Get session
     var params httprouter.Params
@@ -27,16 +95,34 @@ func UploadSolutionGET(w http.ResponseWriter, r *http.Request) {
     params = context.Get(r, "params").(httprouter.Params)
     hexidcrackme := params.ByName("hexidcrackme")
 
+    if !gateAllowed(w, r, gate.Solution, "/crackme/" + hexidcrackme) {
+        return
+    }
+
     //Get crackme and user
     crackme, _ := model.CrackmeByHexId(hexidcrackme)
+    if crackme.Archived {
+        sess.AddFlash(view.Flash{crackmeArchived, view.FlashError})
+        sess.Save(r, w)
+        http.Redirect(w, r, "/crackme/" + hexidcrackme, http.StatusFound)
+        return
+    }
 
     // Display the view
     v := view.New(r)
     v.Name = "solution/create"
+    v.Vars["captchaexempt"] = captchaExempt(r, recaptcha.ActionSolution)
     v.Vars["token"] = csrfbanana.Token(w, r, sess)
     v.Vars["hexidcrackme"] = hexidcrackme
     v.Vars["username"] = crackme.Author
     v.Vars["crackmename"] = crackme.Name
+    v.Vars["minapproach"] = writeupMinApproach
+    v.Vars["maxcoauthors"] = model.MaxCoAuthors
+    v.Vars["languages"] = model.WriteupLanguages
+    v.Vars["licenses"] = model.Licenses
+    v.Vars["teams"] = userTeams(fmt.Sprintf("%s", sess.Values["name"]))
+    v.Vars["idempotencykey"] = idempotencyFormKey(v.Vars)
+    view.Repopulate(draftFields["solution"], draftForm(r, "solution", fmt.Sprintf("%s", sess.Values["name"]), hexidcrackme), v.Vars)
     v.Render(w)
     sess.Save(r, w)
 }
@@ -49,23 +135,74 @@ func UploadSolutionPOST(w http.ResponseWriter, r *http.Request) {
     hexidcrackme := params.ByName("hexidcrackme")
     var solution model.Solution
 
+    if !gateAllowed(w, r, gate.Solution, "/crackme/" + hexidcrackme) {
+        return
+    }
+
+    if crackme, err := model.CrackmeByHexId(hexidcrackme); err == nil && crackme.Archived {
+        sess.AddFlash(view.Flash{crackmeArchived, view.FlashError})
+        sess.Save(r, w)
+        http.Redirect(w, r, "/crackme/" + hexidcrackme, http.StatusFound)
+        return
+    }
+
     username := fmt.Sprintf("%s", sess.Values["name"])
-    info := r.FormValue("info")
-    file, header, err := r.FormFile("file")
 
-    info = sanitize.HTML(info)
+    // A retry of an upload that went through doesn't upload it twice
+    upload, replayed := idempotencyBegin(w, r, "solution", username, "Solution uploaded! Should be available soon.")
+    if replayed {
+        return
+    }
+    defer upload.end()
+
+    // Keep the fields, should the upload fail
+    if err := draftSave(r, "solution", username, hexidcrackme); err != nil {
+        log.Println(err)
+    }
+
+    file, header, err := r.FormFile("file")
 
     solution, _ = model.SolutionsByUserAndCrackMe(username, hexidcrackme)
 
-    emptysol := model.Solution{}
-    if solution != emptysol {
-        sess.AddFlash(view.Flash{"You've already submitted a solution to this crackme", view.FlashError})
+    if !solution.ObjectId.IsZero() {
+        sess.AddFlash(view.Flash{model.ErrSolutionExists.Error(), view.FlashError})
+        sess.Save(r, w)
+        UploadSolutionGET(w, r)
+        return
+    }
+
+    // The writeup waits for approval, a held text is seen by the moderators
+    // anyway
+    filtered, problem := postedText(r.FormValue("info"), "description", maxInfo)
+    info := filtered.Text
+    language := r.FormValue("language")
+    sections, sectionsproblem := writeupSections(r)
+    if problem == "" {
+        problem = sectionsproblem
+    }
+    if model.WriteupLanguageName(language) == "" {
+        problem = "Please choose the language of your writeup."
+    }
+    license := r.FormValue("license")
+    if _, ok := model.LicenseByCode(license); !ok {
+        problem = "Please choose a license for your writeup."
+    }
+    team, ok := postingTeam(r, username)
+    if !ok {
+        problem = "You can only post for the teams you are a member of."
+    }
+    coauthors, coproblem := writeupCoAuthors(r, username)
+    if coproblem != "" {
+        problem = coproblem
+    }
+    if problem != "" {
+        sess.AddFlash(view.Flash{problem, view.FlashError})
         sess.Save(r, w)
         UploadSolutionGET(w, r)
         return
     }
 
-    if !recaptcha.Verified(r) {
+    if !captchaVerified(r, recaptcha.ActionSolution) {
         sess.AddFlash(view.Flash{"reCAPTCHA invalid!", view.FlashError})
         sess.Save(r, w)
         UploadSolutionGET(w, r)
@@ -82,7 +219,7 @@ func UploadSolutionPOST(w http.ResponseWriter, r *http.Request) {
 
 
     // check header size before reading the data into memory to avoid potential DOS attack 
-    if header.Size > 5000000 {
+    if header.Size > maxUploadSize() {
         sess.AddFlash(view.Flash{"This file is too large !", view.FlashError})
         sess.Save(r, w)
         UploadSolutionGET(w, r)
@@ -96,7 +233,14 @@ func UploadSolutionPOST(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    err = model.SolutionCreate(info, username, hexidcrackme)
+    err = model.SolutionCreate(info, sections, language, license, username, team, coauthors, hexidcrackme)
+    if err == model.ErrSolutionExists {
+        // A concurrent upload passed the check above first
+        sess.AddFlash(view.Flash{err.Error(), view.FlashError})
+        sess.Save(r, w)
+        UploadSolutionGET(w, r)
+        return
+    }
     solution, _ = model.SolutionsByUserAndCrackMe(username, hexidcrackme)
 
     if err != nil {
@@ -135,6 +279,22 @@ func UploadSolutionPOST(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    // Summarize the upload for the moderators in the background
+    hexid := solution.HexId
+    preview.Queue(safePath, func(p *preview.Preview) {
+        if err := model.SolutionSetPreview(hexid, p); err != nil {
+            log.Println(err)
+        }
+    })
+
+    // Flag copy-pasted writeups for the moderators, the author isn't told
+    sig := model.SolutionSignature(info, sections, similarity.Text(data))
+    if score, err2 := model.SolutionCheckSimilarity(solution.HexId, sig); err2 != nil {
+        log.Println(err2)
+    } else if score >= model.SimilarityThreshold {
+        log.Printf("Solution %s is %.0f%% similar to an approved solution\n", solution.HexId, score*100)
+    }
+
     // Submitting a solution for your own crackme looks valid... Kinda weird, but ok.
     //  Send notif in that case too, because approval.
     // If these fail, the user shouldn't see an error, because the part he cares about succeeded.
@@ -144,11 +304,67 @@ func UploadSolutionPOST(w http.ResponseWriter, r *http.Request) {
         if err2 != nil {
             log.Println(err2)
         }
+        for _, coauthor := range coauthors {
+            err2 = model.NotificationAdd(coauthor, username + " listed you as co-author of their writeup for '" + crackme.Name + "', waiting approval!")
+            if err2 != nil {
+                log.Println(err2)
+            }
+        }
     } else {
         log.Println(err2)
     }
 
+    upload.hexid = solution.HexId
+    draftDelete("solution", username, hexidcrackme)
     sess.AddFlash(view.Flash{"Solution uploaded! Should be available soon.", view.FlashSuccess})
     sess.Save(r, w)
     http.Redirect(w, r, "/user/"+username, http.StatusFound)
-}
\ No newline at end of file
+}
+// PickSolutionPOST marks a solution of a crackme as the author's pick, or
+// removes the pick when the solution field is empty, and notifies the solver
+func PickSolutionPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    params := context.Get(r, "params").(httprouter.Params)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    crackme, err := model.CrackmeByHexId(params.ByName("hexid"))
+    if err != nil {
+        log.Println(err)
+        Error404(w, r)
+        return
+    }
+
+    solutionhexid := r.FormValue("solution")
+    var solution model.Solution
+    if solutionhexid != "" {
+        solution, err = model.SolutionByHexId(solutionhexid)
+    }
+
+    if crackme.Author != username {
+        sess.AddFlash(view.Flash{"Only the author can pick a writeup.", view.FlashError})
+    } else if err != nil || (solutionhexid != "" && solution.CrackmeHexId != crackme.HexId) {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"This writeup doesn't exist.", view.FlashError})
+    } else if err = model.CrackmeSetPick(crackme.HexId, solutionhexid); err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    } else if solutionhexid == "" {
+        sess.AddFlash(view.Flash{"Pick removed.", view.FlashSuccess})
+    } else {
+        sess.AddFlash(view.Flash{"Writeup picked!", view.FlashSuccess})
+        if solutionhexid != crackme.Pick {
+            for _, author := range solution.Authors() {
+                if author == username {
+                    continue
+                }
+                err = model.NotificationAdd(author, "Your writeup for '" + crackme.Name + "' was picked by its author!")
+                if err != nil {
+                    log.Println(err)
+                }
+            }
+        }
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/crackme/"+crackme.HexId, http.StatusFound)
+}
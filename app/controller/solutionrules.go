@@ -7,6 +7,10 @@ import (
 
 
 func SolutionRulesGET(w http.ResponseWriter, r *http.Request) {
+    if renderPage(w, r, "writeup-rules") {
+        return
+    }
+
     v := view.New(r)
     v.Name = "rules/solutionrules"
     v.Render(w)
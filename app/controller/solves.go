@@ -0,0 +1,128 @@
+package controller
+
+import (
+    "crypto/hmac"
+    "fmt"
+    "log"
+    "net/http"
+    "net/url"
+    "strconv"
+    "time"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/download"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/site"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/gorilla/context"
+    "github.com/julienschmidt/httprouter"
+)
+
+// solvesKind is what the links of the solves summaries are signed as, apart
+// from the downloads signed with the same secret
+const solvesKind = "solves"
+
+// solveExport is an approved solution of the solves summary
+type solveExport struct {
+    Crackme       string    `json:"crackme"`
+    CrackmeHexId  string    `json:"crackme_hexid"`
+    CrackmeAuthor string    `json:"crackme_author"`
+    Difficulty    float64   `json:"difficulty"`
+    SolvedAt      time.Time `json:"solved_at"`
+    Solution      string    `json:"solution_hexid"`
+}
+
+var solveExportHeader = []string{"crackme", "crackme_hexid", "crackme_author", "difficulty", "solved_at", "solution_hexid"}
+
+func (s solveExport) record() []string {
+    return []string{s.Crackme, s.CrackmeHexId, s.CrackmeAuthor,
+        fmt.Sprintf("%.1f", s.Difficulty), s.SolvedAt.Format(time.RFC3339), s.Solution}
+}
+
+// solvesSummary is the signed summary of the solves of a user approved until
+// a date. It is read live: the solves withdrawn or deleted since are left out.
+type solvesSummary struct {
+    User        string        `json:"user"`
+    Until       time.Time     `json:"until"`
+    Count       int           `json:"count"`
+    Solves      []solveExport `json:"solves"`
+    URL         string        `json:"url"` // Signed, anyone can check the summary there
+}
+
+// solvesURL returns the signed link of the solves summary of a user until a
+// date, which never expires
+func solvesURL(username string, at int64) string {
+    q := url.Values{}
+    q.Set("at", strconv.FormatInt(at, 10))
+    q.Set("sig", download.Sign(solvesKind, username, at))
+    return site.URL("/solves/" + url.PathEscape(username) + "?" + q.Encode())
+}
+
+// renderSolves renders the summary of the solves of a user until a date as
+// an HTML page, JSON or CSV
+func renderSolves(w http.ResponseWriter, r *http.Request, username string, at int64, own bool) {
+    until := time.Unix(at, 0)
+    solves, err := model.SolvesByUser(username, until)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    summary := solvesSummary{User: username, Until: until.UTC(), Count: len(solves), Solves: make([]solveExport, len(solves)), URL: solvesURL(username, at)}
+    for i, s := range solves {
+        summary.Solves[i] = solveExport{
+            Crackme:       s.CrackmeName,
+            CrackmeHexId:  s.CrackmeHexId,
+            CrackmeAuthor: s.CrackmeAuthor,
+            Difficulty:    s.Difficulty,
+            SolvedAt:      s.SolvedAt,
+            Solution:      s.SolutionHexId,
+        }
+    }
+
+    switch view.Format(r) {
+    case view.FormatJSON:
+        view.RenderJSON(w, summary)
+        return
+    case view.FormatCSV:
+        records := make([][]string, len(summary.Solves))
+        for i, s := range summary.Solves {
+            records[i] = s.record()
+        }
+        view.RenderCSV(w, "solves-"+username+".csv", solveExportHeader, records)
+        return
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "user/solves"
+    v.Vars["summary"] = summary
+    v.Vars["own"] = own
+    v.Render(w)
+}
+
+// SolvesExportGET displays the summary of the solves of the logged in user,
+// with the signed link to share it
+func SolvesExportGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    renderSolves(w, r, username, time.Now().Unix(), true)
+}
+
+// SolvesSharedGET displays the summary of the solves of a user behind a
+// signed link
+func SolvesSharedGET(w http.ResponseWriter, r *http.Request) {
+    params := context.Get(r, "params").(httprouter.Params)
+    username := params.ByName("name")
+
+    at, err := strconv.ParseInt(r.URL.Query().Get("at"), 10, 64)
+    if err != nil || !hmac.Equal([]byte(r.URL.Query().Get("sig")), []byte(download.Sign(solvesKind, username, at))) {
+        Error404(w, r)
+        return
+    }
+
+    renderSolves(w, r, username, at, false)
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/gorilla/context"
+    "github.com/gorilla/sessions"
+    "github.com/julienschmidt/httprouter"
+)
+
+// spoilerFree tells if the visitor turned the spoiler-free mode on, on their
+// account when logged in, else in their session
+func spoilerFree(sess *sessions.Session) bool {
+    if sess.Values["name"] == nil {
+        on, _ := sess.Values["spoilerfree"].(bool)
+        return on
+    }
+
+    user, err := repo.UserByName(fmt.Sprintf("%s", sess.Values["name"]))
+    if err != nil {
+        // Show everything rather than failing the page
+        log.Println(err)
+        return false
+    }
+    return user.SpoilerFree
+}
+
+// hideSpoilers tells if the writeups of the crackme must be hidden from the
+// visitor in spoiler-free mode: unless they wrote it or solved it
+func hideSpoilers(sess *sessions.Session, crackme model.Crackme) bool {
+    if sess.Values["name"] == nil {
+        return true
+    }
+
+    username := fmt.Sprintf("%s", sess.Values["name"])
+    if username == crackme.Author {
+        return false
+    }
+    solved, err := repo.HasSolved(username, crackme.ObjectId)
+    if err != nil {
+        log.Println(err)
+    }
+    return !solved
+}
+
+// SpoilerFreePOST turns the spoiler-free mode on or off, then goes back to
+// the crackme
+func SpoilerFreePOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    var params httprouter.Params
+    params = context.Get(r, "params").(httprouter.Params)
+
+    on := r.FormValue("spoilerfree") == "on"
+    if sess.Values["name"] == nil {
+        sess.Values["spoilerfree"] = on
+    } else if err := model.UserSetSpoilerFree(fmt.Sprintf("%s", sess.Values["name"]), on); err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/crackme/"+params.ByName("hexid"), http.StatusFound)
+}
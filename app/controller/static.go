@@ -2,9 +2,19 @@ package controller
 
 import (
     "net/http"
+    "path"
+    "strings"
 )
 
 // Static maps static files
 func Static(w http.ResponseWriter, r *http.Request) {
+    // Crackmes and solutions are only downloaded through signed URLs, see
+    // DownloadGET
+    p := path.Clean(r.URL.Path)
+    if strings.HasPrefix(p, "/static/crackme/") || strings.HasPrefix(p, "/static/solution/") {
+        Error404(w, r)
+        return
+    }
+
     http.ServeFile(w, r, r.URL.Path[1:])
 }
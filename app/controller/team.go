@@ -0,0 +1,234 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "net/url"
+    "strings"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/gorilla/context"
+    "github.com/josephspurrier/csrfbanana"
+    "github.com/julienschmidt/httprouter"
+)
+
+// teamActivityMax is the number of the latest writeups of the members shown
+// on a team page
+const teamActivityMax = 20
+
+// userTeams returns the teams of the logged in user, for the upload forms
+func userTeams(username string) []model.Team {
+    teams, err := model.TeamsByMember(username)
+    if err != nil {
+        log.Println(err)
+    }
+    return teams
+}
+
+// postingTeam returns the name of the team chosen in the team field of an
+// upload form, empty when posting alone, and false if the user is not one of
+// its members
+func postingTeam(r *http.Request, username string) (string, bool) {
+    name := r.FormValue("team")
+    if name == "" {
+        return "", true
+    }
+    team, err := model.TeamByName(name)
+    if err != nil {
+        if err != model.ErrNoResult {
+            log.Println(err)
+        }
+        return "", false
+    }
+    return team.Name, team.HasMember(username)
+}
+
+// teamURL returns the page of a team
+func teamURL(name string) string {
+    return "/team/" + url.PathEscape(name)
+}
+
+// TeamsGET displays the ranking of the teams, and the form creating one
+func TeamsGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+
+    teams, err := model.TeamsRanking()
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "team/list"
+    v.Vars["teams"] = teams
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// TeamCreatePOST creates a team owned by the logged in user
+func TeamCreatePOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    team, err := model.TeamCreate(strings.TrimSpace(r.FormValue("name")), username)
+    if err == model.ErrTeamName || err == model.ErrTeamExists {
+        sess.AddFlash(view.Flash{err.Error(), view.FlashError})
+    } else if err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    } else {
+        sess.AddFlash(view.Flash{"Team created, invite its members from its page.", view.FlashSuccess})
+        sess.Save(r, w)
+        http.Redirect(w, r, teamURL(team.Name), http.StatusFound)
+        return
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/teams", http.StatusFound)
+}
+
+// TeamGET displays a team: its members, what was posted for it and the
+// latest writeups of its members
+func TeamGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    params := context.Get(r, "params").(httprouter.Params)
+
+    team, err := model.TeamByName(params.ByName("name"))
+    if err == model.ErrNoResult {
+        Error404(w, r)
+        return
+    } else if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    crackmes, err := model.CrackmesByTeam(team.Name)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+    solutions, err := model.SolutionsByTeam(team.Name)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+    activity, err := model.TeamActivity(team, teamActivityMax)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    // The members with their counters
+    members := make([]model.User, 0, len(team.Members))
+    for _, name := range team.Members {
+        user, err := model.UserByName(name)
+        if err != nil {
+            log.Println(err)
+            user = model.User{Name: name}
+        }
+        members = append(members, user)
+    }
+
+    username := ""
+    if sess.Values["name"] != nil {
+        username = fmt.Sprintf("%s", sess.Values["name"])
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "team/read"
+    v.Vars["team"] = team
+    v.Vars["members"] = members
+    v.Vars["crackmes"] = crackmes
+    v.Vars["solutions"] = solutions
+    v.Vars["activity"] = activity
+    v.Vars["isowner"] = username != "" && username == team.Owner
+    v.Vars["ismember"] = team.HasMember(username)
+    v.Vars["isinvited"] = team.IsInvited(username)
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// TeamInvitePOST invites a user to a team of the logged in user, and notifies
+// them
+func TeamInvitePOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    params := context.Get(r, "params").(httprouter.Params)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+    name := params.ByName("name")
+
+    invited, err := model.UserByName(strings.TrimSpace(r.FormValue("username")))
+    if err == nil {
+        err = model.TeamInvite(name, username, invited.Name)
+    }
+    if err == model.ErrNoResult {
+        sess.AddFlash(view.Flash{"This user doesn't exist or is already a member.", view.FlashError})
+    } else if err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    } else {
+        if err := model.NotificationAdd(invited.Name, username+" invited you to join the team "+name+", accept from its page."); err != nil {
+            log.Println(err)
+        }
+        sess.AddFlash(view.Flash{invited.Name + " has been invited.", view.FlashSuccess})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, teamURL(name), http.StatusFound)
+}
+
+// TeamJoinPOST makes the logged in user a member of a team they were invited
+// to
+func TeamJoinPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    params := context.Get(r, "params").(httprouter.Params)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+    name := params.ByName("name")
+
+    err := model.TeamJoin(name, username)
+    if err == model.ErrNotInvited {
+        sess.AddFlash(view.Flash{err.Error(), view.FlashError})
+    } else if err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    } else {
+        sess.AddFlash(view.Flash{"Welcome to the team!", view.FlashSuccess})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, teamURL(name), http.StatusFound)
+}
+
+// TeamLeavePOST removes the logged in user from a team, or declines their
+// invitation
+func TeamLeavePOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    params := context.Get(r, "params").(httprouter.Params)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+    name := params.ByName("name")
+
+    err := model.TeamLeave(name, username)
+    if err == model.ErrNoResult {
+        sess.AddFlash(view.Flash{"The owner of a team can't leave it.", view.FlashError})
+    } else if err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    } else {
+        sess.AddFlash(view.Flash{"You are not part of the team anymore.", view.FlashNotice})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, teamURL(name), http.StatusFound)
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+    "fmt"
+    "html"
+    "html/template"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/crackmesone/crackmes.one/app/shared/clientip"
+    "github.com/crackmesone/crackmes.one/app/shared/contentfilter"
+    "github.com/crackmesone/crackmes.one/app/shared/markdown"
+    "github.com/crackmesone/crackmes.one/app/shared/ratelimit"
+
+    "github.com/kennygrant/sanitize"
+)
+
+// Limits of the texts posted by the users, the same on every form which
+// takes them, counted once the tags are stripped
+const (
+    maxInfo    = 5000 // Characters of the description of a crackme or a writeup
+    maxComment = 2000 // Characters of a comment
+)
+
+// postedText strips the tags of a text posted by a user, checks its length
+// and applies the content filter to it. It returns the result of the filter,
+// with the text to store, or the problem to show under the label. Whether a
+// held text is published is left to the caller.
+func postedText(text, label string, max int) (contentfilter.Result, string) {
+    text = strings.TrimSpace(sanitize.HTML(text))
+    if n := len([]rune(text)); n > max {
+        return contentfilter.Result{}, fmt.Sprintf("The %s is too long, %d characters at most (%d given).", label, max, n)
+    }
+
+    filtered := contentfilter.Check(text)
+    if filtered.Action == contentfilter.Block {
+        return filtered, fmt.Sprintf("Your %s was refused by the content filter (%s).", label, filtered.Reason)
+    }
+    return filtered, ""
+}
+
+// previewKinds are the texts which can be previewed, with their label and
+// their limit
+var previewKinds = map[string]struct {
+    label string
+    max   int
+}{
+    "description": {"description", maxInfo},
+    "section":     {"section", writeupMaxSection},
+    "comment":     {"comment", maxComment},
+}
+
+// previewLimiter limits the number of previews per network, rendering a long
+// text costs some CPU
+var previewLimiter = ratelimit.New(30, time.Minute)
+
+// previewHTML renders a text the way its page shows it: the descriptions and
+// the writeup sections with their code blocks, the comments as plain text
+func previewHTML(kind, text string) template.HTML {
+    if kind == "comment" {
+        return template.HTML(`<span style="white-space: pre-line">` + html.EscapeString(text) + "</span>")
+    }
+    return markdown.Text(text)
+}
+
+// PreviewPOST returns, as JSON, the HTML of the text of a form once saved:
+// stripped of its tags, checked against the limits and censored by the
+// content filter. The kind is "description", "section" or "comment".
+func PreviewPOST(w http.ResponseWriter, r *http.Request) {
+    if _, ok := apiUser(w, r); !ok {
+        return
+    }
+    if ok, retry := previewLimiter.Allow(clientip.NetworkFromRequest(r)); !ok {
+        tooManyRequests(w, r, retry, "Too many previews, please slow down.", true)
+        return
+    }
+
+    kind := r.FormValue("kind")
+    limit, ok := previewKinds[kind]
+    if !ok {
+        apiJSON(w, http.StatusBadRequest, map[string]string{"error": "Unknown kind of text"})
+        return
+    }
+
+    filtered, problem := postedText(r.FormValue("text"), limit.label, limit.max)
+    if problem != "" {
+        apiJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": problem})
+        return
+    }
+
+    apiJSON(w, http.StatusOK, map[string]interface{}{
+        "html": previewHTML(kind, filtered.Text),
+        "held": filtered.Action == contentfilter.Hold,
+    })
+}
@@ -0,0 +1,75 @@
+package controller
+
+import (
+    "net/http"
+    "net/url"
+    "path"
+    "regexp"
+    "strings"
+
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/site"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/josephspurrier/csrfbanana"
+)
+
+// tokenExpired is the message of the forms refused for their token
+const tokenExpired = "Your session expired, please submit the form again."
+
+// tokenOrigins are the pages of the forms posted to an action not below
+// their page, by the pattern of the action
+var tokenOrigins = []struct {
+    action *regexp.Regexp
+    page   string
+}{
+    {regexp.MustCompile(`^/comment/(\w+)$`), "/crackme/$1#modal-comment"},
+    {regexp.MustCompile(`^/hint/(\w+)(/\w+)?$`), "/crackme/$1"},
+    {regexp.MustCompile(`^/crackme/rate-(?:qual|diff)/(\w+)$`), "/crackme/$1"},
+}
+
+// InvalidToken returns the handler of the forms posted without a valid CSRF
+// token, most often once the session expired: their fields are kept, see
+// view.KeepForm, and the visitor goes back to the form with a flash, to send
+// it again. isPage tells whether a path is a page, for the forms posted to
+// their page or below it. The API requests get an error with a new token.
+func InvalidToken(isPage func(path string) bool) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        sess := session.Instance(r)
+
+        if strings.HasPrefix(r.URL.Path, "/api/") {
+            token := csrfbanana.Token(w, r, sess)
+            sess.Save(r, w)
+            apiJSON(w, http.StatusForbidden, map[string]string{"error": tokenExpired, "token": token})
+            return
+        }
+
+        view.KeepForm(r)
+        sess.AddFlash(view.Flash{tokenExpired, view.FlashWarning})
+        sess.Save(r, w)
+        http.Redirect(w, r, tokenOrigin(r, isPage), http.StatusFound)
+    })
+}
+
+// tokenOrigin returns the page a form was posted from: the page of its action
+// in tokenOrigins, else its action or the closest page above it, else the
+// Referer on this site, else the home page
+func tokenOrigin(r *http.Request, isPage func(path string) bool) string {
+    for _, origin := range tokenOrigins {
+        if origin.action.MatchString(r.URL.Path) {
+            return origin.action.ReplaceAllString(r.URL.Path, origin.page)
+        }
+    }
+
+    for p := path.Clean(r.URL.Path); p != "/"; p = path.Dir(p) {
+        if isPage(p) {
+            return p
+        }
+    }
+
+    if ref, err := url.Parse(r.Referer()); err == nil && ref.Host == r.Host && ref.Path != "" {
+        ref.Path, ref.RawPath = site.Strip(ref.Path), ""
+        return ref.RequestURI()
+    }
+    return "/"
+}
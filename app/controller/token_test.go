@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTokenOrigin(t *testing.T) {
+	pages := map[string]bool{"/crackme/abc": true, "/upload/crackme": true, "/team/x": true}
+	isPage := func(path string) bool { return pages[path] }
+
+	tests := []struct {
+		action, referer, want string
+	}{
+		{"/comment/abc", "", "/crackme/abc#modal-comment"},
+		{"/hint/abc/reveal", "", "/crackme/abc"},
+		{"/crackme/rate-diff/abc", "", "/crackme/abc"},
+		{"/crackme/abc/attempt", "", "/crackme/abc"},
+		{"/upload/crackme", "", "/upload/crackme"},
+		{"/team/x/invite", "", "/team/x"},
+		{"/pick/abc", "http://example.com/crackme/abc?tab=1", "/crackme/abc?tab=1"},
+		{"/pick/abc", "http://elsewhere.com/crackme/abc", "/"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", test.action, nil)
+		r.Header.Set("Referer", test.referer)
+		if got := tokenOrigin(r, isPage); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.action, got, test.want)
+		}
+	}
+}
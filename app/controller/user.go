@@ -1,55 +1,36 @@
 package controller
 
 import (
+    "encoding/json"
     "github.com/crackmesone/crackmes.one/app/model"
     "log"
     "net/http"
-    "sort"
+    "strconv"
+    "time"
+    "github.com/crackmesone/crackmes.one/app/shared/cache"
+    "github.com/crackmesone/crackmes.one/app/shared/grace"
+    "github.com/crackmesone/crackmes.one/app/shared/shadow"
     //"app/shared/session"
     "github.com/crackmesone/crackmes.one/app/shared/view"
 
     "fmt"
     "github.com/gorilla/context"
+    "github.com/josephspurrier/csrfbanana"
     "github.com/julienschmidt/httprouter"
     "github.com/crackmesone/crackmes.one/app/shared/session"
 )
 
-type By func(p1, p2 *model.User) bool
-
-func (by By) Sort(users []model.User) {
-    ps := &userSorter{
-        users: users,
-        by:    by, // The Sort method's receiver is the function (closure) that defines the sort order.
-    }
-    sort.Sort(ps)
-}
-
-type userSorter struct {
-    users []model.User
-    by    func(p1, p2 *model.User) bool // Closure used in the Less method.
-}
-
-func (s *userSorter) Len() int {
-    return len(s.users)
-}
-
-// Swap is part of sort.Interface.
-func (s *userSorter) Swap(i, j int) {
-    s.users[i], s.users[j] = s.users[j], s.users[i]
-}
-
-// Less is part of sort.Interface. It is implemented by calling the "by" closure in the sorter.
-func (s *userSorter) Less(i, j int) bool {
-    return s.by(&s.users[i], &s.users[j])
-}
+// activityCache keeps the activity heatmap data of each user for an hour
+var activityCache = cache.New(time.Hour)
 
 // NotepadReadGET displays the notes in the notepad
 func UserGET(w http.ResponseWriter, r *http.Request) {
     var params httprouter.Params
     params = context.Get(r, "params").(httprouter.Params)
     name := params.ByName("name")
+    start := time.Now()
 
-    user, err := model.UserByName(name)
+    user, err := repo.UserByName(name)
     if err != nil {
         log.Println(err)
         Error404(w, r)
@@ -60,27 +41,36 @@ func UserGET(w http.ResponseWriter, r *http.Request) {
     // This ensures case-insensitive lookup works while maintaining data consistency
     actualUsername := user.Name
 
-    crackmes, err := model.CrackmesByUser(actualUsername)
+    crackmes, err := repo.CrackmesByUser(actualUsername)
     if err != nil {
         log.Println(err)
         Error500(w, r)
         return
     }
 
-    solutions, err := model.SolutionsByUser(actualUsername)
+    solutions, err := repo.SolutionsByUser(actualUsername)
     if err != nil {
         log.Println(err)
         Error500(w, r)
         return
     }
 
-    comments, err := model.CommentsByUser(actualUsername)
+    comments, err := repo.CommentsByUser(actualUsername)
     if err != nil {
         log.Println(err)
         Error500(w, r)
         return
     }
 
+    // Compare these queries with the aggregation meant to replace them
+    if shadow.Sample() {
+        legacy := model.UserProfile{User: user, Crackmes: crackmes, Solutions: solutions, Comments: comments}
+        legacy.Normalize()
+        shadow.Compare("profile", legacy, time.Since(start), func() (interface{}, error) {
+            return repo.UserProfileByName(name)
+        })
+    }
+
     // Use len() instead of separate count queries
     nbCrackmes := len(crackmes)
     nbSolutions := len(solutions)
@@ -96,8 +86,8 @@ func UserGET(w http.ResponseWriter, r *http.Request) {
 
     // NbComments and NbSolutions for each CRACKME are stored in the database
     // and are retrieved directly from the crackme documents.
-    // Note: User.NbSolutions and User.NbComments (for the USER) are still
-    // calculated dynamically and NOT stored in the database.
+    // The USER counters are also stored on the user document (for the users
+    // directory), but the profile already has the full lists at hand.
 
     // Determine if the user is viewing their own profile page
     sess := session.Instance(r)
@@ -107,6 +97,45 @@ func UserGET(w http.ResponseWriter, r *http.Request) {
     }
     viewingOwnPage := sessionUsername != "" && sessionUsername == actualUsername
 
+    following := false
+    if sessionUsername != "" && !viewingOwnPage {
+        following, err = repo.IsFollowing(sessionUsername, actualUsername)
+        if err != nil {
+            log.Println(err)
+        }
+    }
+
+    // The crackmes in progress are only shown to their user
+    var attempts []model.Attempt
+    if viewingOwnPage {
+        attempts, err = repo.AttemptsByUser(actualUsername)
+        if err != nil {
+            log.Println(err)
+        }
+    }
+
+    // Their submissions waiting for approval can still be edited or
+    // withdrawn during the grace period
+    var pendingCrackmes, unlistedCrackmes []model.Crackme
+    var pendingSolutions []model.Solution
+    if viewingOwnPage {
+        since := grace.Since(time.Now())
+        pendingCrackmes, err = repo.CrackmesPendingByUser(actualUsername, since)
+        if err != nil {
+            log.Println(err)
+        }
+        pendingSolutions, err = repo.SolutionsPendingByUser(actualUsername, since)
+        if err != nil {
+            log.Println(err)
+        }
+
+        // Only their author finds the unlisted crackmes without the link
+        unlistedCrackmes, err = repo.CrackmesUnlistedByUser(actualUsername)
+        if err != nil {
+            log.Println(err)
+        }
+    }
+
     user.NbCrackmes = nbCrackmes
     user.NbSolutions = nbSolutions
     user.NbComments = nbComments
@@ -122,50 +151,138 @@ func UserGET(w http.ResponseWriter, r *http.Request) {
     v.Vars["solutions"] = solutionsext
     v.Vars["comments"] = comments
     v.Vars["viewingOwnPage"] = viewingOwnPage
+    v.Vars["following"] = following
+    v.Vars["attempts"] = attempts
+    v.Vars["pendingcrackmes"] = pendingCrackmes
+    v.Vars["unlistedcrackmes"] = unlistedCrackmes
+    v.Vars["pendingsolutions"] = pendingSolutions
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
     v.Render(w)
+    sess.Save(r, w)
+}
+
+// FollowPOST makes the logged in user follow another user
+func FollowPOST(w http.ResponseWriter, r *http.Request) {
+    followSet(w, r, true)
+}
+
+// UnfollowPOST makes the logged in user stop following another user
+func UnfollowPOST(w http.ResponseWriter, r *http.Request) {
+    followSet(w, r, false)
+}
+
+// followSet adds or removes the follow relation between the logged in user and
+// the user of the page, then goes back to the profile
+func followSet(w http.ResponseWriter, r *http.Request, follow bool) {
+    sess := session.Instance(r)
+    var params httprouter.Params
+    params = context.Get(r, "params").(httprouter.Params)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+
+    user, err := model.UserByName(params.ByName("name"))
+    if err != nil {
+        log.Println(err)
+        Error404(w, r)
+        return
+    }
+
+    if user.Name == username {
+        sess.AddFlash(view.Flash{"You can't follow yourself", view.FlashError})
+    } else if follow {
+        err = model.FollowCreate(username, user.Name)
+    } else {
+        err = model.FollowDelete(username, user.Name)
+    }
+
+    if err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/user/"+user.Name, http.StatusFound)
 }
 
+// UsersGET displays the users directory, filtered by the "q" query parameter,
+// sorted by "sort" and paginated by "page"
 func UsersGET(w http.ResponseWriter, r *http.Request) {
+    query := r.URL.Query()
+    search := query.Get("q")
+    sortBy := query.Get("sort")
+    if sortBy == "" {
+        sortBy = "name"
+    }
 
-    users, err := model.AllUsersVisible()
-    name := func(p1, p2 *model.User) bool {
-        return p1.Name < p2.Name
+    page, err := strconv.Atoi(query.Get("page"))
+    if err != nil || page < 1 {
+        page = 1
     }
-    By(name).Sort(users)
 
+    users, err := model.UsersDirectory(search, sortBy, page)
     if err != nil {
         log.Println(err)
         Error500(w, r)
         return
     }
 
-    for _, user := range users {
-        nbSolutions, err := model.CountSolutionsByUser(user.Name)
-        if err != nil {
-            log.Println(err)
-            Error500(w, r)
-            return
-        }
+    total, err := model.CountUsersDirectory(search)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
 
-        nbComments, err := model.CountCommentsByUser(user.Name)
-        if err != nil {
-            log.Println(err)
-            Error500(w, r)
-            return
-        }
-        nbCrackmes, err := model.CountCrackmesByUser(user.Name)
+    // Display the view
+    v := view.New(r)
+    v.Name = "users/read"
+    v.Vars["users"] = users
+    v.Vars["q"] = search
+    v.Vars["sort"] = sortBy
+    v.Vars["page"] = page
+    v.Vars["total"] = total
+    if page > 1 {
+        v.Vars["prec"] = page - 1
+    }
+    if page*model.UsersPerPage < total {
+        v.Vars["next"] = page + 1
+    }
+    v.Render(w)
+}
+
+// UserActivityGET returns, as JSON, the number of approved solutions and
+// comments of a user per day over the past year
+func UserActivityGET(w http.ResponseWriter, r *http.Request) {
+    var params httprouter.Params
+    params = context.Get(r, "params").(httprouter.Params)
+    name := params.ByName("name")
+
+    user, err := model.UserByName(name)
+    if err != nil {
+        log.Println(err)
+        Error404(w, r)
+        return
+    }
+
+    var days []model.ActivityDay
+    if cached, ok := activityCache.Get(user.Name); ok {
+        days = cached.([]model.ActivityDay)
+    } else {
+        days, err = model.UserActivity(user.Name, time.Now().UTC().AddDate(-1, 0, 0))
         if err != nil {
             log.Println(err)
             Error500(w, r)
             return
         }
-        user.NbSolutions = nbSolutions
-        user.NbComments = nbComments
-        user.NbCrackmes = nbCrackmes
+        activityCache.Set(user.Name, days)
     }
-    // Display the view
-    v := view.New(r)
-    v.Name = "users/read"
-    v.Vars["users"] = users
-    v.Render(w)
+
+    js, err := json.Marshal(days)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.Write(js)
 }
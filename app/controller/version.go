@@ -0,0 +1,209 @@
+package controller
+
+import (
+    "fmt"
+    "io/ioutil"
+    "log"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/exeinfo"
+    "github.com/crackmesone/crackmes.one/app/shared/preview"
+    "github.com/crackmesone/crackmes.one/app/shared/recaptcha"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/gorilla/context"
+    "github.com/josephspurrier/csrfbanana"
+    "github.com/julienschmidt/httprouter"
+    "github.com/kennygrant/sanitize"
+)
+
+// versionCrackme returns the crackme of the hexid parameter if the logged in
+// user is its author, or displays an error
+func versionCrackme(w http.ResponseWriter, r *http.Request) (model.Crackme, bool) {
+    sess := session.Instance(r)
+    params := context.Get(r, "params").(httprouter.Params)
+
+    crackme, err := model.CrackmeByHexId(params.ByName("hexid"))
+    if err != nil {
+        log.Println(err)
+        Error404(w, r)
+        return crackme, false
+    }
+
+    if crackme.Author != fmt.Sprintf("%s", sess.Values["name"]) {
+        sess.AddFlash(view.Flash{"Only the author can upload a new version.", view.FlashError})
+        sess.Save(r, w)
+        http.Redirect(w, r, "/crackme/"+crackme.HexId, http.StatusFound)
+        return crackme, false
+    }
+    return crackme, true
+}
+
+// UploadVersionGET displays the form to upload a new version of a crackme
+func UploadVersionGET(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    crackme, ok := versionCrackme(w, r)
+    if !ok {
+        return
+    }
+
+    // Display the view
+    v := view.New(r)
+    v.Name = "crackme/version"
+    v.Vars["captchaexempt"] = captchaExempt(r, recaptcha.ActionVersion)
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Vars["hexid"] = crackme.HexId
+    v.Vars["name"] = crackme.Name
+    v.Vars["version"] = crackme.CurrentVersion()
+    v.Vars["pending"] = crackme.PendingVersion() != nil
+    view.Repopulate([]string{"changelog"}, r.Form, v.Vars)
+    v.Render(w)
+    sess.Save(r, w)
+}
+
+// UploadVersionPOST stores a new version of a crackme, which waits for the
+// moderators like a new crackme
+func UploadVersionPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    crackme, ok := versionCrackme(w, r)
+    if !ok {
+        return
+    }
+
+    if validate, missingField := view.Validate(r, []string{"changelog"}); !validate {
+        sess.AddFlash(view.Flash{"Field missing: " + missingField, view.FlashError})
+        sess.Save(r, w)
+        UploadVersionGET(w, r)
+        return
+    }
+    changelog := strings.TrimSpace(sanitize.HTML(r.FormValue("changelog")))
+
+    if crackme.PendingVersion() != nil {
+        sess.AddFlash(view.Flash{"A new version is already waiting for approval.", view.FlashError})
+        sess.Save(r, w)
+        UploadVersionGET(w, r)
+        return
+    }
+
+    if !captchaVerified(r, recaptcha.ActionVersion) {
+        sess.AddFlash(view.Flash{"reCAPTCHA invalid!", view.FlashError})
+        sess.Save(r, w)
+        UploadVersionGET(w, r)
+        return
+    }
+
+    file, header, err := r.FormFile("file")
+    if err != nil || header.Filename == "" {
+        sess.AddFlash(view.Flash{"Field missing: file", view.FlashError})
+        sess.Save(r, w)
+        UploadVersionGET(w, r)
+        return
+    }
+
+    // check header size before reading the data into memory
+    if header.Size > maxUploadSize() {
+        sess.AddFlash(view.Flash{"This file is too large !", view.FlashError})
+        sess.Save(r, w)
+        UploadVersionGET(w, r)
+        return
+    }
+
+    data, err := ioutil.ReadAll(file)
+    if err != nil {
+        log.Println(err)
+        Error500(w, r)
+        return
+    }
+
+    // A new version targets the same architecture and platform
+    binary, err := exeinfo.Analyze(header.Filename, data)
+    if err == nil {
+        if binary.Executables == 1 && (!binary.MatchesArch(crackme.Arch) || !binary.MatchesPlatform(crackme.Platform)) {
+            sess.AddFlash(view.Flash{"The uploaded file is a " + binary.Arch + " " + binary.Format + " executable, but the crackme is for " + crackme.Arch + " " + crackme.Platform + ".", view.FlashError})
+            sess.Save(r, w)
+            UploadVersionGET(w, r)
+            return
+        }
+    } else {
+        binary = nil
+    }
+
+    filename, data, files, err := uploadBundle(r, sanitize.Name(filepath.Base(header.Filename)), data)
+    if err != nil {
+        sess.AddFlash(view.Flash{err.Error(), view.FlashError})
+        sess.Save(r, w)
+        UploadVersionGET(w, r)
+        return
+    }
+    if int64(len(data)) > maxUploadSize() {
+        sess.AddFlash(view.Flash{"These files are too large !", view.FlashError})
+        sess.Save(r, w)
+        UploadVersionGET(w, r)
+        return
+    }
+
+    if files == nil {
+        files = uploadFiles(filename, data)
+    }
+
+    version := model.CrackmeVersion{
+        SHA256:    sha256Hex(data),
+        Size:      int64(len(data)),
+        Format:    uploadFormat(data, binary),
+        Changelog: changelog,
+        CreatedAt: time.Now(),
+        Binary:    binary,
+        Files:     files,
+        Readme:    uploadReadme(data, files),
+    }
+
+    // Same place as a new crackme, the moderators approve it with validate.py
+    safePath := filepath.Join("tmp/crackme", crackme.Author+"+++"+crackme.HexId+"+++"+filename)
+    if !strings.HasPrefix(filepath.Clean(safePath), "tmp/crackme/") {
+        log.Println("invalid or unsafe file path detected")
+        sess.AddFlash(view.Flash{"Invalid file path", view.FlashError})
+        sess.Save(r, w)
+        return
+    }
+
+    err = ioutil.WriteFile(safePath, data, 0666)
+    if err != nil {
+        log.Println("File write error:", err)
+        sess.AddFlash(view.Flash{"Failed to save file. Please try again.", view.FlashError})
+        sess.Save(r, w)
+        UploadVersionGET(w, r)
+        return
+    }
+
+    err = model.CrackmeAddVersion(crackme, version)
+    if err != nil {
+        log.Println(err)
+        os.Remove(safePath)
+        sess.AddFlash(view.Flash{"A new version is already waiting for approval.", view.FlashError})
+        sess.Save(r, w)
+        http.Redirect(w, r, "/crackme/"+crackme.HexId, http.StatusFound)
+        return
+    }
+
+    // Summarize the upload for the moderators in the background
+    preview.Queue(safePath, func(p *preview.Preview) {
+        if err := model.CrackmeSetPreview(crackme.HexId, p); err != nil {
+            log.Println(err)
+        }
+    })
+
+    notifErr := model.NotificationAdd(crackme.Author, "New version of '" + crackme.Name + "' uploaded, waiting for approval!")
+    if notifErr != nil {
+        log.Println(notifErr)
+    }
+
+    sess.AddFlash(view.Flash{"New version uploaded! Should be available soon.", view.FlashSuccess})
+    sess.Save(r, w)
+    http.Redirect(w, r, "/crackme/"+crackme.HexId, http.StatusFound)
+}
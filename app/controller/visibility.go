@@ -0,0 +1,40 @@
+package controller
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/gorilla/context"
+    "github.com/julienschmidt/httprouter"
+)
+
+// CrackmeVisibilityPOST makes a crackme of the logged in user public or
+// unlisted, then goes back to the crackme
+func CrackmeVisibilityPOST(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    params := context.Get(r, "params").(httprouter.Params)
+    username := fmt.Sprintf("%s", sess.Values["name"])
+    hexid := params.ByName("hexid")
+
+    visibility, ok := model.ParseVisibility(r.FormValue("visibility"))
+    if !ok {
+        sess.AddFlash(view.Flash{"Wrong visibility", view.FlashError})
+    } else if err := model.CrackmeSetVisibility(hexid, username, visibility); err == model.ErrNoResult {
+        sess.AddFlash(view.Flash{"Only the author can change the visibility of a crackme.", view.FlashError})
+    } else if err != nil {
+        log.Println(err)
+        sess.AddFlash(view.Flash{"An error occurred on the server. Please try again later.", view.FlashError})
+    } else if visibility == model.VisibilityUnlisted {
+        sess.AddFlash(view.Flash{"The crackme is unlisted, share its link to give access to it.", view.FlashSuccess})
+    } else {
+        sess.AddFlash(view.Flash{"The crackme is public.", view.FlashSuccess})
+    }
+
+    sess.Save(r, w)
+    http.Redirect(w, r, "/crackme/"+hexid, http.StatusFound)
+}
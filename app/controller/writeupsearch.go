@@ -0,0 +1,156 @@
+package controller
+
+import (
+    "log"
+    "net/http"
+    "strings"
+    "unicode"
+
+    "github.com/crackmesone/crackmes.one/app/model"
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+
+    "github.com/josephspurrier/csrfbanana"
+)
+
+// searchModeWriteups is the mode of the search looking into the writeups
+// instead of the crackmes
+const searchModeWriteups = "writeups"
+
+// The number of characters of the writeups shown around the first match
+const (
+    snippetBefore = 80
+    snippetAfter  = 160
+)
+
+// snippet is the part of a writeup around the first term of the search
+// found in it
+type snippet struct {
+    Before string `json:"before"`
+    Match  string `json:"match"`
+    After  string `json:"after"`
+}
+
+// writeupResult is a writeup found by the search, with its snippet
+type writeupResult struct {
+    HexId         string  `json:"hexid"`
+    Author        string  `json:"author"`
+    Crackme       string  `json:"crackme"`
+    CrackmeHexId  string  `json:"crackme_hexid"`
+    CrackmeAuthor string  `json:"crackme_author"`
+    Snippet       snippet `json:"snippet"`
+}
+
+// writeupText returns the searched text of a writeup
+func writeupText(s model.Solution) string {
+    parts := []string{s.Info}
+    if s.Sections != nil {
+        parts = append(parts, s.Sections.Tools, s.Sections.Approach, s.Sections.Patch)
+    }
+    return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+// writeupSnippet returns the part of a text around the first term of the
+// query found in it, or its beginning. The negated terms are skipped.
+func writeupSnippet(text, query string) snippet {
+    runes := []rune(text)
+    lower := []rune(strings.ToLower(text))
+    if len(lower) != len(runes) {
+        lower = runes
+    }
+
+    start, length := -1, 0
+    for _, term := range strings.Fields(strings.ToLower(strings.Replace(query, `"`, " ", -1))) {
+        if strings.HasPrefix(term, "-") {
+            continue
+        }
+        term = strings.TrimFunc(term, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+        if term == "" {
+            continue
+        }
+        if i := strings.Index(string(lower), term); i >= 0 {
+            if at := len([]rune(string(lower)[:i])); start < 0 || at < start {
+                start, length = at, len([]rune(term))
+            }
+        }
+    }
+
+    if start < 0 {
+        if len(runes) > snippetAfter {
+            return snippet{Before: string(runes[:snippetAfter]) + "…"}
+        }
+        return snippet{Before: text}
+    }
+
+    var s snippet
+    from, to := start-snippetBefore, start+length+snippetAfter
+    if from > 0 {
+        s.Before = "…"
+    } else {
+        from = 0
+    }
+    s.Before += string(runes[from:start])
+    s.Match = string(runes[start : start+length])
+    if to < len(runes) {
+        s.After = string(runes[start+length:to]) + "…"
+    } else {
+        s.After = string(runes[start+length:])
+    }
+    return s
+}
+
+// searchWriteups displays the writeups matching the query of the search, or
+// exports them as JSON
+func searchWriteups(w http.ResponseWriter, r *http.Request) {
+    sess := session.Instance(r)
+    query := strings.TrimSpace(r.FormValue("q"))
+
+    results := []writeupResult{}
+    if query != "" {
+        matches, err := model.WriteupSearch(query)
+        if err != nil {
+            log.Println(err)
+            Error500(w, r)
+            return
+        }
+
+        // In spoiler-free mode the writeups of the crackmes not solved yet
+        // stay hidden
+        spoilerfree := spoilerFree(sess)
+        for _, m := range matches {
+            if spoilerfree && hideSpoilers(sess, model.Crackme{ObjectId: m.CrackmeId, Author: m.CrackmeAuthor}) {
+                continue
+            }
+            results = append(results, writeupResult{
+                HexId:         m.HexId,
+                Author:        m.Author,
+                Crackme:       m.CrackmeName,
+                CrackmeHexId:  m.CrackmeHexId,
+                CrackmeAuthor: m.CrackmeAuthor,
+                Snippet:       writeupSnippet(writeupText(m.Solution), query),
+            })
+        }
+    }
+
+    if view.Format(r) == view.FormatJSON {
+        view.RenderJSON(w, results)
+        return
+    }
+
+    v := view.New(r)
+    v.Name = "search/search"
+    v.Vars["token"] = csrfbanana.Token(w, r, sess)
+    v.Vars["mode"] = searchModeWriteups
+    v.Vars["q"] = query
+    v.Vars["writeups"] = results
+    v.Vars["languages"] = model.WriteupLanguages
+    v.Vars["prerequisites"] = model.Prerequisites
+    v.Vars["archivefilters"] = model.ArchiveFilters
+    v.Vars["sorts"] = model.SearchSorts
+    v.Vars["archived"] = ""
+    v.Vars["sort"] = ""
+    v.Vars["order"] = ""
+    v.Vars["writeuplangfilter"] = featureEnabled(r, featureSearchWriteupLang)
+    sess.Save(r, w)
+    v.Render(w)
+}
@@ -0,0 +1,4 @@
+// Package integration holds the end-to-end tests of the site, run against a
+// real MongoDB with "go test -tags integration ./app/integration". See
+// mongo_test.go for how the server is started.
+package integration
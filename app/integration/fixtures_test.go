@@ -0,0 +1,143 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/crackmesone/crackmes.one/app/model"
+	"github.com/crackmesone/crackmes.one/app/shared/download"
+	"github.com/crackmesone/crackmes.one/app/shared/passhash"
+)
+
+// fixtures are the seeded data, the tests look them up by name
+type fixtures struct {
+	crackmes  map[string]model.Crackme  // By name
+	solutions map[string]model.Solution // By author and crackme name, e.g. "bob/KeygenMe"
+}
+
+// fixtureUsers are the seeded users, the first ones being the most active
+// like on the site
+var fixtureUsers = []string{"alice", "bob", "carol", "dave"}
+
+// fixtureCrackmes are the seeded crackmes, with the users solving them
+var fixtureCrackmes = []struct {
+	Name, Author, Lang, Arch, Platform string
+	Solvers                            []string
+}{
+	{"KeygenMe", "alice", "C/C++", "x86-64", "Linux", []string{"bob", "carol"}},
+	{"VM Crackme", "alice", "Assembler", "x86", "Windows", []string{"bob"}},
+	{"Go Patchme", "bob", "Go", "x86-64", "Unix/linux etc.", []string{"alice"}},
+	{"Unsolved", "carol", ".NET", "x86", "Windows", nil},
+}
+
+// zipFile returns a zip archive holding a single file
+func zipFile(name string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create(name)
+	if err == nil {
+		_, err = w.Write(data)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	return buf.Bytes(), err
+}
+
+// storeFile writes the zip of a crackme or a solution in the storage, as
+// validate.py does on approval
+func storeFile(kind, hexid, name string, data []byte) error {
+	archive, err := zipFile(name, data)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(download.Dir(kind), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(download.Path(kind, hexid), archive, 0644)
+}
+
+// uploadCrackme creates a pending crackme with its file, like the upload form
+func uploadCrackme(name, author, lang, arch, platform string) (*model.Crackme, error) {
+	crackme, err := model.CrackmeCreatePrepare(name, "Find the password of "+name+".", author, lang, arch, platform)
+	if err != nil {
+		return nil, err
+	}
+	if err := storeFile("crackme", crackme.HexId, "crackme.bin", []byte("\x7fELF "+name)); err != nil {
+		return nil, err
+	}
+	return crackme, model.CrackmeInsert(crackme)
+}
+
+// uploadSolution creates a pending solution with its file, like the upload
+// form, and returns it
+func uploadSolution(author string, crackme model.Crackme, language string) (model.Solution, error) {
+	sections := model.WriteupSections{Tools: "Ghidra", Approach: "Followed the password check from main and reversed the comparison."}
+	err := model.SolutionCreate("Writeup of "+crackme.Name, sections, language, "", author, "", nil, crackme.HexId)
+	if err != nil {
+		return model.Solution{}, err
+	}
+
+	solution, err := model.SolutionsByUserAndCrackMe(author, crackme.HexId)
+	if err != nil {
+		return solution, err
+	}
+	return solution, storeFile("solution", solution.HexId, "writeup.md", []byte("# "+crackme.Name))
+}
+
+// seed fills the database and the storage with the fixtures, everything
+// approved
+func seed() (*fixtures, error) {
+	f := &fixtures{crackmes: map[string]model.Crackme{}, solutions: map[string]model.Solution{}}
+
+	for _, name := range fixtureUsers {
+		password, err := passhash.HashString("password of " + name)
+		if err != nil {
+			return nil, err
+		}
+		if err := model.UserCreate(name, name+"@example.com", password); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, c := range fixtureCrackmes {
+		crackme, err := uploadCrackme(c.Name, c.Author, c.Lang, c.Arch, c.Platform)
+		if err != nil {
+			return nil, err
+		}
+		if err := model.CrackmeApprove(crackme.HexId); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, c := range fixtureCrackmes {
+		crackme, err := model.CrackmeByUserAndName(c.Author, c.Name, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, solver := range c.Solvers {
+			solution, err := uploadSolution(solver, crackme, "en")
+			if err != nil {
+				return nil, err
+			}
+			if err := model.SolutionApprove(solution.HexId); err != nil {
+				return nil, err
+			}
+			f.solutions[fmt.Sprintf("%s/%s", solver, c.Name)] = solution
+		}
+
+		// Reload the crackme to get its counters
+		f.crackmes[c.Name], err = model.CrackmeByHexId(crackme.HexId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
@@ -0,0 +1,592 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/controller"
+	"github.com/crackmesone/crackmes.one/app/model"
+	"github.com/crackmesone/crackmes.one/app/shared/download"
+	"github.com/crackmesone/crackmes.one/app/shared/recaptcha"
+	"github.com/crackmesone/crackmes.one/app/shared/session"
+	"github.com/crackmesone/crackmes.one/app/shared/site"
+	"github.com/crackmesone/crackmes.one/app/shared/view"
+	"github.com/crackmesone/crackmes.one/app/shared/view/plugin"
+
+	"github.com/gorilla/context"
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// seeded are the fixtures seeded by TestMain
+var seeded *fixtures
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+// run starts MongoDB, seeds it and runs the tests in a temporary directory
+// holding the storage
+func run(m *testing.M) int {
+	templates, err := filepath.Abs("../../template")
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	stop, err := startMongo()
+	if err != nil {
+		fmt.Println(err, "(set MONGO_URL to use a running server)")
+		return 1
+	}
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "crackmesone")
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Chdir(dir); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	session.Configure(session.Session{Name: "test", SecretKey: "test"})
+	download.Configure(download.Info{Secret: "test", Expiry: 3600})
+	viewInfo := view.View{BaseURI: "/", Extension: "tmpl", Folder: templates, Caching: true}
+	view.Configure(viewInfo)
+	view.LoadTemplates("base", []string{"partial/menu", "partial/footer", "partial/winner"})
+	view.LoadPlugins(
+		plugin.TagHelper(viewInfo),
+		plugin.NoEscape(),
+		plugin.PrettyTime(),
+		plugin.TimeCompare(),
+		recaptcha.Plugin(),
+		download.Plugin(),
+		site.Plugin())
+
+	seeded, err = seed()
+	if err != nil {
+		fmt.Println("Seeding failed:", err)
+		return 1
+	}
+
+	return m.Run()
+}
+
+// userCounters returns the counters of a user
+func userCounters(t *testing.T, name string) (int, int, int) {
+	user, err := model.UserByName(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.NbCrackmes, user.NbSolutions, user.NbComments
+}
+
+// contains returns true if the crackmes hold one named name
+func contains(crackmes []model.Crackme, name string) bool {
+	for _, c := range crackmes {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestUploadApproval(t *testing.T) {
+	before, _, _ := userCounters(t, "dave")
+
+	crackme, err := uploadCrackme("Pending crackme", "dave", "Rust", "x86-64", "Linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lasts, err := model.LastCrackMes(1, model.Viewer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contains(lasts, crackme.Name) {
+		t.Error("Pending crackme listed before its approval")
+	}
+
+	if err := model.CrackmeApprove(crackme.HexId); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.CrackmeApprove(crackme.HexId); err != model.ErrNoResult {
+		t.Errorf("Second approval: %v, expected %v", err, model.ErrNoResult)
+	}
+
+	lasts, err = model.LastCrackMes(1, model.Viewer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(lasts, crackme.Name) {
+		t.Error("Approved crackme not listed")
+	}
+	if after, _, _ := userCounters(t, "dave"); after != before+1 {
+		t.Errorf("Author has %d crackmes, expected %d", after, before+1)
+	}
+
+	// The short link is given at the approval
+	approved, err := model.CrackmeByHexId(crackme.HexId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approved.ShortId == "" {
+		t.Error("Approved crackme without a short id")
+	} else if c, err := model.CrackmeByShortId(approved.ShortId); err != nil || c.HexId != crackme.HexId {
+		t.Errorf("Short id %s leads to %q, %v", approved.ShortId, c.HexId, err)
+	}
+}
+
+func TestRevision(t *testing.T) {
+	crackme, err := uploadCrackme("Edited crackme", "dave", "Go", "x86-64", "Linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reviewed := crackme.Rev
+
+	// The author edits the description while the moderator reviews it
+	if err := model.CrackmePendingEdit(crackme.HexId, "dave", "Edited", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.CrackmeApproveRev(crackme.HexId, reviewed); err != model.ErrConflict {
+		t.Errorf("Approval of a stale revision: %v, expected %v", err, model.ErrConflict)
+	}
+
+	edited, err := model.CrackmeByHexId(crackme.HexId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Visible {
+		t.Error("Crackme approved from a stale revision")
+	}
+	if err := model.CrackmeApproveRev(edited.HexId, edited.Rev); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.CrackmeUpdateRev(edited.HexId, edited.Rev, bson.M{"info": "Lost"}); err != model.ErrConflict {
+		t.Errorf("Update of a stale revision: %v, expected %v", err, model.ErrConflict)
+	}
+	if err := model.CrackmeUpdateRev("missing", 0, bson.M{"info": "Lost"}); err != model.ErrNoResult {
+		t.Errorf("Update of a missing crackme: %v, expected %v", err, model.ErrNoResult)
+	}
+}
+
+func TestSolutionApproval(t *testing.T) {
+	crackme := seeded.crackmes["Unsolved"]
+	_, before, _ := userCounters(t, "dave")
+	if err := model.AttemptCreate("dave", crackme); err != nil {
+		t.Fatal(err)
+	}
+
+	solution, err := uploadSolution("dave", crackme, "de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if solution.Visible {
+		t.Error("Solution visible before its approval")
+	}
+	if err := model.SolutionApprove(solution.HexId); err != nil {
+		t.Fatal(err)
+	}
+
+	crackme, err = model.CrackmeByHexId(crackme.HexId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crackme.NbSolutions != 1 {
+		t.Errorf("Crackme has %d solutions, expected 1", crackme.NbSolutions)
+	}
+	if len(crackme.WriteupLangs) != 1 || crackme.WriteupLangs[0] != "de" {
+		t.Errorf("Writeup languages %v, expected [de]", crackme.WriteupLangs)
+	}
+	if _, after, _ := userCounters(t, "dave"); after != before+1 {
+		t.Errorf("Solver has %d solutions, expected %d", after, before+1)
+	}
+	notifications, err := model.NotificationsByUser("dave")
+	notified := false
+	for _, n := range notifications {
+		notified = notified || strings.Contains(n.Text, "writeup of 'Unsolved' has been approved")
+	}
+	if err != nil || !notified {
+		t.Errorf("Solver not notified of the approval: %+v %v", notifications, err)
+	}
+	notifications, err = model.NotificationsByUser(crackme.Author)
+	if err != nil || len(notifications) == 0 || !strings.Contains(notifications[0].Text, "submitted by: dave") {
+		t.Errorf("Author of the crackme not notified: %+v %v", notifications, err)
+	}
+	if attempting, err := model.IsAttempting("dave", crackme.HexId); err != nil || attempting {
+		t.Errorf("Attempt of the solver kept: %v %v", attempting, err)
+	}
+
+	solutions, err := model.SolutionsByCrackmePage(crackme.ObjectId, "de", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(solutions) != 1 || solutions[0].HexId != solution.HexId {
+		t.Errorf("Solutions in German: %v", solutions)
+	}
+
+	events, err := model.EventsSince(solution.CreatedAt, model.EventSolutionApproved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := false
+	for _, e := range events {
+		recorded = recorded || (e.HexId == solution.HexId && e.User == "dave")
+	}
+	if !recorded {
+		t.Errorf("Approval not in the events: %v", events)
+	}
+}
+
+func TestComment(t *testing.T) {
+	crackme := seeded.crackmes["KeygenMe"]
+
+	if err := model.CommentCreate("How do I start?", "dave", crackme.HexId); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.CommentCreate("Look at main.", crackme.Author, crackme.HexId); err != nil {
+		t.Fatal(err)
+	}
+
+	comments, err := model.CommentsByCrackMe(crackme.HexId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 2 {
+		t.Fatalf("%d comments, expected 2", len(comments))
+	}
+	if comments[0].ByAuthor || !comments[1].ByAuthor {
+		t.Error("Comment of the crackme author not flagged")
+	}
+
+	after, err := model.CrackmeByHexId(crackme.HexId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.NbComments != crackme.NbComments+2 {
+		t.Errorf("Crackme has %d comments, expected %d", after.NbComments, crackme.NbComments+2)
+	}
+}
+
+// get calls a handler the way the router does
+func get(handler http.HandlerFunc, target string, params httprouter.Params) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", target, nil)
+	context.Set(r, "params", params)
+	defer context.Clear(r)
+
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestProfile(t *testing.T) {
+	w := get(controller.UserGET, "/user/ALICE", httprouter.Params{{Key: "name", Value: "ALICE"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, name := range []string{"KeygenMe", "VM Crackme", "Go Patchme"} {
+		if !strings.Contains(body, name) {
+			t.Errorf("%q missing from the profile", name)
+		}
+	}
+	if strings.Contains(body, "Unsolved") {
+		t.Error("Crackme of another user on the profile")
+	}
+
+	w = get(controller.UserGET, "/user/nobody", httprouter.Params{{Key: "name", Value: "nobody"}})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Status %d for an unknown user, expected 404", w.Code)
+	}
+
+	// The names are never read as patterns
+	for _, name := range []string{".*", "ali.e", "(a+)+$"} {
+		if _, err := model.UserByName(name); err != model.ErrNoResult {
+			t.Errorf("UserByName(%q): %v, expected %v", name, err, model.ErrNoResult)
+		}
+	}
+}
+
+func TestCrackmePage(t *testing.T) {
+	crackme := seeded.crackmes["KeygenMe"]
+
+	w := get(controller.CrackMeGET, "/crackme/"+crackme.HexId, httprouter.Params{{Key: "hexid", Value: crackme.HexId}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "/download/crackme/"+crackme.HexId+"?") {
+		t.Error("Download link missing from the crackme page")
+	}
+}
+
+func TestConsistency(t *testing.T) {
+	report, err := model.ConsistencyCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, issue := range report.Issues {
+		t.Errorf("%s %s %s", issue.Problem, issue.Kind, issue.Path)
+	}
+}
+
+func TestDrift(t *testing.T) {
+	user, err := model.UserByName("dave")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.UserSetCounters(user.Name, user.NbCrackmes+7, user.NbSolutions, user.NbComments); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := model.DriftCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, issue := range report.Issues {
+		if issue.Kind == "user" && issue.Key == user.Name && issue.Field == "nbcrackmes" {
+			found = true
+			if err := model.DriftFix(issue); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("Drift of the crackme counter of dave not found")
+	}
+
+	if fixed, err := model.UserByName(user.Name); err != nil {
+		t.Fatal(err)
+	} else if fixed.NbCrackmes != user.NbCrackmes {
+		t.Errorf("dave has %d crackmes after the fix, expected %d", fixed.NbCrackmes, user.NbCrackmes)
+	}
+}
+
+// TestDriftHeldComment checks a comment held for moderation, not counted
+// until approved, isn't taken for a drift of its author's counter
+func TestDriftHeldComment(t *testing.T) {
+	crackme, err := uploadCrackme("Held comment crackme", "dave", "Go", "x86-64", "Linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.CrackmeApprove(crackme.HexId); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.CommentCreateHeld("Held comment", "carol", crackme.HexId, "link"); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := model.DriftCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, issue := range report.Issues {
+		if issue.Kind == "user" && issue.Key == "carol" && issue.Field == "nbcomments" {
+			t.Errorf("Held comment counted as a drift: %+v", issue)
+		}
+	}
+	if n, err := model.CountCommentsByUser("carol"); err != nil {
+		t.Fatal(err)
+	} else if user, _ := model.UserByName("carol"); n != user.NbComments {
+		t.Errorf("carol has %d comments counted, %d stored", n, user.NbComments)
+	}
+}
+
+func TestProfileAggregation(t *testing.T) {
+	for _, name := range fixtureUsers {
+		user, err := model.UserByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		legacy := model.UserProfile{User: user}
+		if legacy.Crackmes, err = model.CrackmesByUser(name); err != nil {
+			t.Fatal(err)
+		}
+		if legacy.Solutions, err = model.SolutionsByUser(name); err != nil {
+			t.Fatal(err)
+		}
+		if legacy.Comments, err = model.CommentsByUser(name); err != nil {
+			t.Fatal(err)
+		}
+		legacy.Normalize()
+
+		profile, err := model.UserProfileByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(legacy, profile) {
+			t.Errorf("Profile of %s differs from the legacy queries", name)
+		}
+	}
+
+	lasts, err := model.LastCrackMes(1, model.Viewer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aggregated, err := model.LastCrackMesAggregate(1, model.Viewer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lasts, aggregated) {
+		t.Error("Last crackmes differ from the legacy query")
+	}
+}
+
+func TestClaim(t *testing.T) {
+	imported, err := uploadCrackme("OldOne by carol", model.PlaceholderAuthors[0], "C/C++", "x86", "DOS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.CrackmeApprove(imported.HexId); err != nil {
+		t.Fatal(err)
+	}
+	crackme, err := model.CrackmeByHexId(imported.HexId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.CommentCreate("I wrote this one.", "carol", crackme.HexId); err != nil {
+		t.Fatal(err)
+	}
+	crackmes, _, _ := userCounters(t, "carol")
+
+	for _, user := range []string{"carol", "dave"} {
+		if err := model.ClaimCreate(crackme, user, "My old crackme."); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := model.ClaimCreate(crackme, "dave", "Again."); err != model.ErrClaimExists {
+		t.Errorf("Second claim: %v, expected %v", err, model.ErrClaimExists)
+	}
+
+	claims, err := model.ClaimsPending()
+	if err != nil || len(claims) != 2 {
+		t.Fatalf("%d pending claims (%v), expected 2", len(claims), err)
+	}
+	if err := model.ClaimApprove(claims[0].HexId, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.ClaimApprove(claims[1].HexId, "alice"); err != model.ErrClaimDecided {
+		t.Errorf("Approval of the rejected claim: %v, expected %v", err, model.ErrClaimDecided)
+	}
+
+	after, err := model.CrackmeByHexId(crackme.HexId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Author != "carol" {
+		t.Errorf("Crackme of %s, expected carol", after.Author)
+	}
+	if nb, _, _ := userCounters(t, "carol"); nb != crackmes+1 {
+		t.Errorf("carol has %d crackmes, expected %d", nb, crackmes+1)
+	}
+	comments, err := model.CommentsByCrackMe(crackme.HexId)
+	if err != nil || len(comments) != 1 || !comments[0].ByAuthor {
+		t.Errorf("Comment of the new author not flagged: %+v %v", comments, err)
+	}
+
+	for user, text := range map[string]string{"carol": "now the author", "dave": "rejected"} {
+		notifications, err := model.NotificationsByUser(user)
+		if err != nil || len(notifications) == 0 || !strings.Contains(notifications[0].Text, text) {
+			t.Errorf("Notification of %s: %+v %v, expected %q", user, notifications, err, text)
+		}
+	}
+}
+
+func TestDraft(t *testing.T) {
+	if err := model.DraftSave("dave", "crackme", "", map[string]string{"name": "Half done"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.DraftSave("dave", "crackme", "", map[string]string{"name": "Done", "info": "Long story"}); err != nil {
+		t.Fatal(err)
+	}
+	draft, err := model.DraftByForm("dave", "crackme", "")
+	if err != nil || draft.Fields["name"] != "Done" || draft.Fields["info"] != "Long story" {
+		t.Errorf("Draft: %+v %v, expected the last one saved", draft, err)
+	}
+	if _, err := model.DraftByForm("dave", "solution", seeded.crackmes["Unsolved"].HexId); err != model.ErrNoResult {
+		t.Errorf("Draft of another form: %v, expected %v", err, model.ErrNoResult)
+	}
+
+	if err := model.DraftDelete("dave", "crackme", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := model.DraftByForm("dave", "crackme", ""); err != model.ErrNoResult {
+		t.Errorf("Deleted draft: %v, expected %v", err, model.ErrNoResult)
+	}
+}
+
+func TestUnlistedWriteups(t *testing.T) {
+	crackme := seeded.crackmes["Go Patchme"]
+	if err := model.CrackmeSetVisibility(crackme.HexId, crackme.Author, model.VisibilityUnlisted); err != nil {
+		t.Fatal(err)
+	}
+	defer model.CrackmeSetVisibility(crackme.HexId, crackme.Author, model.VisibilityPublic)
+
+	solutions, err := model.SolutionsByUser("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := model.UserProfileByName("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _, err := model.SolutionsAfter(model.CursorFilter{Author: "alice"}, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, list := range map[string][]model.Solution{"profile": solutions, "aggregated profile": profile.Solutions, "page": page} {
+		for _, s := range list {
+			if s.CrackmeHexId == crackme.HexId {
+				t.Errorf("Writeup of the unlisted crackme in the %s", name)
+			}
+		}
+	}
+
+	solves, err := model.SolvesByUser("alice", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range solves {
+		if s.CrackmeHexId == crackme.HexId {
+			t.Error("Writeup of the unlisted crackme in the solves summary")
+		}
+	}
+}
+
+func TestSignInNetworks(t *testing.T) {
+	for i, c := range []struct {
+		device, ip string
+		unseen     bool
+	}{
+		{"laptop", "192.0.2.1", false}, // The first device
+		{"laptop", "198.51.100.1", true},
+		{"laptop", "192.0.2.1", false}, // Back home
+		{"phone", "198.51.100.1", true},
+		{"phone", "192.0.2.1", false},
+	} {
+		unseen, err := model.SignInRecord("bob", c.device, c.ip, "test", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unseen != c.unseen {
+			t.Errorf("Sign-in %d from %s: unseen %v, expected %v", i, c.ip, unseen, c.unseen)
+		}
+	}
+	if err := model.SignInForget("bob"); err != nil {
+		t.Fatal(err)
+	}
+}
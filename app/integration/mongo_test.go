@@ -0,0 +1,95 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+)
+
+const (
+	// mongoImage is the image of the MongoDB container
+	mongoImage = "mongo:6"
+
+	// testDatabase is the database the tests run in, dropped before and
+	// after them
+	testDatabase = "crackmesone_test"
+)
+
+// startMongo connects the database package to the server of the MONGO_URL
+// environment variable, or to a throwaway MongoDB container started with
+// docker. The returned function drops the test database and stops the
+// container.
+func startMongo() (func(), error) {
+	url := os.Getenv("MONGO_URL")
+	container := ""
+	if url == "" {
+		out, err := exec.Command("docker", "run", "-d", "--rm", "-p", "127.0.0.1::27017", mongoImage).Output()
+		if err != nil {
+			return nil, fmt.Errorf("docker run: %v", err)
+		}
+		container = strings.TrimSpace(string(out))
+
+		// e.g. "127.0.0.1:49153"
+		out, err = exec.Command("docker", "port", container, "27017/tcp").Output()
+		if err != nil {
+			stopContainer(container)
+			return nil, fmt.Errorf("docker port: %v", err)
+		}
+		url = "mongodb://" + strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+	}
+
+	database.Connect(database.Info{
+		Type:    database.TypeMongoDB,
+		MongoDB: database.MongoDBInfo{URL: url, Database: testDatabase},
+	})
+	if err := waitMongo(30 * time.Second); err != nil {
+		stopContainer(container)
+		return nil, err
+	}
+
+	db := database.Mongo.Database(testDatabase)
+	if err := db.Drop(context.Background()); err != nil {
+		stopContainer(container)
+		return nil, err
+	}
+
+	return func() {
+		db.Drop(context.Background())
+		database.Mongo.Disconnect(context.Background())
+		stopContainer(container)
+	}, nil
+}
+
+// waitMongo waits for the server to answer, the container takes a few
+// seconds to start
+func waitMongo(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := database.Mongo.Ping(ctx, nil)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.New("MongoDB did not start: " + err.Error())
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// stopContainer removes a container started by startMongo, if any
+func stopContainer(container string) {
+	if container != "" {
+		exec.Command("docker", "rm", "-f", container).Run()
+	}
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// *****************************************************************************
+// Activity
+// *****************************************************************************
+
+// ActivityDay is the number of contributions of a user on one day
+type ActivityDay struct {
+	Date  string `bson:"_id" json:"date"`
+	Count int    `bson:"count" json:"count"`
+}
+
+// activityMatch matches the visible documents of username created after since
+func activityMatch(username string, since time.Time) bson.D {
+	return bson.D{{"$match", bson.M{
+		"author":     username,
+		"visible":    true,
+		"created_at": bson.M{"$gte": since},
+	}}}
+}
+
+// UserActivity returns the number of approved solutions and comments posted by
+// a user per day (formatted YYYY-MM-DD, UTC) since the given time. Days without
+// any activity are omitted.
+//
+// Both collections are counted by a single aggregation using $unionWith.
+func UserActivity(username string, since time.Time) ([]ActivityDay, error) {
+	var err error
+	var cursor *mongo.Cursor
+	result := []ActivityDay{}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
+
+		project := bson.D{{"$project", bson.M{"created_at": 1}}}
+		pipeline := mongo.Pipeline{
+			activityMatch(username, since),
+			project,
+			{{"$unionWith", bson.M{
+				"coll":     "comment",
+				"pipeline": bson.A{activityMatch(username, since), project},
+			}}},
+			{{"$group", bson.M{
+				"_id":   bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$created_at"}},
+				"count": bson.M{"$sum": 1},
+			}}},
+			{{"$sort", bson.M{"_id": 1}}},
+		}
+
+		cursor, err = collection.Aggregate(database.Ctx, pipeline)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
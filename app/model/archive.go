@@ -0,0 +1,64 @@
+package model
+
+import (
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// *****************************************************************************
+// Archive
+// *****************************************************************************
+
+// ArchiveFilter is which crackmes the search finds by their archival state
+type ArchiveFilter struct {
+	Code string
+	Name string
+}
+
+// ArchiveFilters are the choices of the search, the first is the default
+var ArchiveFilters = []ArchiveFilter{
+	{"", "Archived or not"},
+	{"hide", "Not archived"},
+	{"only", "Archived only"},
+}
+
+// archiveMatch returns the element of a filter for the code of an
+// ArchiveFilter, false when any crackme matches
+func archiveMatch(code string) (bson.E, bool) {
+	switch code {
+	case "hide":
+		return bson.E{"archived", bson.M{"$ne": true}}, true
+	case "only":
+		return bson.E{"archived", true}, true
+	}
+	return bson.E{}, false
+}
+
+// CrackmeSetArchived archives a crackme of the author, or of anybody when the
+// author is empty for the moderators, or takes it out of the archive. An
+// archived crackme is still downloaded but takes no new solution or comment.
+func CrackmeSetArchived(hexid, author string, archived bool) error {
+	var err error
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		filter := bson.M{"hexid": hexid, "visible": true}
+		if author != "" {
+			filter["author"] = author
+		}
+		var result *mongo.UpdateResult
+		result, err = collection.UpdateOne(database.Ctx, filter,
+			bson.M{"$set": bson.M{"archived": archived, "updated_at": time.Now()}})
+		if err == nil && result.MatchedCount == 0 {
+			err = ErrNoResult
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
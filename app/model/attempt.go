@@ -0,0 +1,133 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Attempt
+// *****************************************************************************
+
+// AttemptActiveDays is the number of days an attempt counts as active on the
+// crackme page, the older ones are still listed for their user
+const AttemptActiveDays = 30
+
+var (
+	// ErrAlreadySolved is returned when attempting a crackme already solved
+	ErrAlreadySolved = errors.New("You already solved this crackme.")
+	// ErrOwnCrackme is returned when attempting one's own crackme
+	ErrOwnCrackme = errors.New("You can't attempt your own crackme.")
+)
+
+// Attempt table contains one document per user attempting a crackme. It is
+// removed by the user, or when their solution is approved.
+type Attempt struct {
+	ObjectId     primitive.ObjectID `bson:"_id,omitempty"`
+	User         string             `bson:"user"`
+	CrackmeHexId string             `bson:"crackmehexid"`
+	CrackmeName  string             `bson:"crackmename"`
+	CreatedAt    time.Time          `bson:"created_at"`
+}
+
+// IsAttempting returns true if username is attempting the crackme
+func IsAttempting(username, crackmehexid string) (bool, error) {
+	var err error
+	var nb int64
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("attempt")
+		nb, err = collection.CountDocuments(database.Ctx, bson.M{"user": username, "crackmehexid": crackmehexid})
+	} else {
+		err = ErrUnavailable
+	}
+
+	return nb != 0, standardizeError(err)
+}
+
+// CountAttempters returns the number of users attempting the crackme for less
+// than AttemptActiveDays
+func CountAttempters(crackmehexid string) (int, error) {
+	var err error
+	var nb int64
+	if database.CheckConnection() {
+		collection := database.Listing("attempt")
+		nb, err = collection.CountDocuments(database.Ctx, bson.M{
+			"crackmehexid": crackmehexid,
+			"created_at":   bson.M{"$gte": time.Now().AddDate(0, 0, -AttemptActiveDays)},
+		})
+	} else {
+		err = ErrUnavailable
+	}
+
+	return int(nb), standardizeError(err)
+}
+
+// AttemptsByUser returns the crackmes attempted by username, newest first
+func AttemptsByUser(username string) ([]Attempt, error) {
+	var err error
+	result := []Attempt{}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("attempt")
+		var cursor *mongo.Cursor
+		opts := options.Find().SetSort(bson.M{"created_at": -1})
+		cursor, err = collection.Find(database.Ctx, bson.M{"user": username}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// AttemptCreate marks the crackme as attempted by username, does nothing if it
+// already is. The author can't attempt their crackme, nor can the users with
+// an approved solution.
+func AttemptCreate(username string, crackme Crackme) error {
+	if crackme.Author == username {
+		return ErrOwnCrackme
+	}
+
+	var err error
+	if database.CheckConnection() {
+		db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+
+		var nb int64
+		nb, err = db.Collection("solution").CountDocuments(database.Ctx, bson.M{"crackmeid": crackme.ObjectId, "author": username, "visible": true})
+		if err == nil && nb != 0 {
+			return ErrAlreadySolved
+		}
+		if err == nil {
+			filter := bson.M{"user": username, "crackmehexid": crackme.HexId}
+			update := bson.M{"$setOnInsert": bson.M{"crackmename": crackme.Name, "created_at": time.Now()}}
+			_, err = db.Collection("attempt").UpdateOne(database.Ctx, filter, update, options.Update().SetUpsert(true))
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// AttemptDelete makes username stop attempting the crackme
+func AttemptDelete(username, crackmehexid string) error {
+	var err error
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("attempt")
+		_, err = collection.DeleteOne(database.Ctx, bson.M{"user": username, "crackmehexid": crackmehexid})
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
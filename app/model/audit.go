@@ -0,0 +1,75 @@
+package model
+
+import (
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Audit log
+// *****************************************************************************
+
+// Actions of the audit log
+const (
+	AuditImpersonateStart = "impersonate.start"
+	AuditImpersonateStop  = "impersonate.stop"
+	AuditCrackmeMerge     = "crackme.merge"
+	AuditCrackmeReject    = "crackme.reject"
+	AuditCrackmeArchive   = "crackme.archive"
+	AuditSettingChange    = "setting.change"
+	AuditDriftFix         = "drift.fix"
+)
+
+// AuditEntry is a sensitive action of an admin
+type AuditEntry struct {
+	ObjectId  primitive.ObjectID `bson:"_id,omitempty"`
+	Actor     string             `bson:"actor"` // Admin who acted
+	Action    string             `bson:"action"`
+	Target    string             `bson:"target"` // User acted upon
+	Detail    string             `bson:"detail,omitempty"`
+	IP        string             `bson:"ip,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// AuditAdd records an action in the audit log
+func AuditAdd(actor, action, target, detail, ip string) error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("audit")
+		_, err = collection.InsertOne(database.Ctx, AuditEntry{
+			Actor:     actor,
+			Action:    action,
+			Target:    target,
+			Detail:    detail,
+			IP:        ip,
+			CreatedAt: time.Now(),
+		})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// AuditLatest returns the latest entries of the audit log, newest first
+func AuditLatest(limit int) ([]AuditEntry, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []AuditEntry{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("audit")
+		opts := options.Find().SetSort(bson.D{{"created_at", -1}}).SetLimit(int64(limit))
+		cursor, err = collection.Find(database.Ctx, bson.M{}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+	return result, standardizeError(err)
+}
@@ -0,0 +1,175 @@
+package model
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// *****************************************************************************
+// Backup
+// *****************************************************************************
+
+const (
+	// importBatchSize is the number of documents inserted at once on a restore
+	importBatchSize = 1000
+
+	// stagingPrefix names the collections a restore loads the dumps in, before
+	// they replace the collections of the database
+	stagingPrefix = "restore."
+)
+
+// BackupDatabase gives the backup package access to the collections. The
+// documents are exported as canonical extended JSON, one per line, which
+// keeps their exact BSON types.
+type BackupDatabase struct{}
+
+// CollectionNames returns the names of the collections of the database
+func (BackupDatabase) CollectionNames() ([]string, error) {
+	var err error
+	var names []string
+
+	if database.CheckConnection() {
+		// Without the ones left by an interrupted restore
+		names, err = database.Mongo.Database(database.ReadConfig().MongoDB.Database).ListCollectionNames(database.Ctx,
+			bson.M{"name": bson.M{"$not": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(stagingPrefix)}}})
+	} else {
+		err = ErrUnavailable
+	}
+
+	return names, standardizeError(err)
+}
+
+// Export writes every document of a collection to w
+func (BackupDatabase) Export(name string, w io.Writer) (int, error) {
+	var err error
+	n := 0
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection(name)
+		var cursor *mongo.Cursor
+		cursor, err = collection.Find(database.Ctx, bson.M{})
+		if err != nil {
+			return 0, standardizeError(err)
+		}
+		defer cursor.Close(database.Ctx)
+
+		for err == nil && cursor.Next(database.Ctx) {
+			var line []byte
+			line, err = bson.MarshalExtJSON(cursor.Current, true, false)
+			if err == nil {
+				_, err = w.Write(append(line, '\n'))
+				n++
+			}
+		}
+		if err == nil {
+			err = cursor.Err()
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return n, standardizeError(err)
+}
+
+// Import loads the documents exported in r in the staging collection of a
+// collection, the collection itself is left untouched until Replace
+func (BackupDatabase) Import(name string, r io.Reader) (int, error) {
+	var err error
+	n := 0
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection(stagingPrefix + name)
+		// Left by an interrupted restore
+		if err = collection.Drop(database.Ctx); err != nil {
+			return 0, standardizeError(err)
+		}
+
+		scanner := bufio.NewScanner(r)
+		// Documents are at most 16MB, more once in JSON
+		scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+		var batch []interface{}
+		for err == nil && scanner.Scan() {
+			var doc bson.D
+			if err = bson.UnmarshalExtJSON(scanner.Bytes(), true, &doc); err != nil {
+				break
+			}
+			batch = append(batch, doc)
+			if len(batch) == importBatchSize {
+				_, err = collection.InsertMany(database.Ctx, batch)
+				n += len(batch)
+				batch = nil
+			}
+		}
+		if err == nil {
+			err = scanner.Err()
+		}
+		if err == nil && len(batch) > 0 {
+			_, err = collection.InsertMany(database.Ctx, batch)
+			n += len(batch)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return n, standardizeError(err)
+}
+
+// Replace renames the staging collection loaded by Import over a collection,
+// at once. The indexes of the collection are created on it first.
+func (BackupDatabase) Replace(name string) error {
+	var err error
+
+	if database.CheckConnection() {
+		dbName := database.ReadConfig().MongoDB.Database
+		db := database.Mongo.Database(dbName)
+
+		var specs []bson.M
+		var cursor *mongo.Cursor
+		cursor, err = db.Collection(name).Indexes().List(database.Ctx)
+		if err == nil {
+			err = cursor.All(database.Ctx, &specs)
+		}
+		var indexes []bson.M
+		for _, spec := range specs {
+			if spec["name"] != "_id_" {
+				delete(spec, "ns")
+				indexes = append(indexes, spec)
+			}
+		}
+		if err == nil && len(indexes) > 0 {
+			err = db.RunCommand(database.Ctx, bson.D{{Key: "createIndexes", Value: stagingPrefix + name}, {Key: "indexes", Value: indexes}}).Err()
+		}
+
+		if err == nil {
+			err = database.Mongo.Database("admin").RunCommand(database.Ctx, bson.D{
+				{Key: "renameCollection", Value: dbName + "." + stagingPrefix + name},
+				{Key: "to", Value: dbName + "." + name},
+				{Key: "dropTarget", Value: true},
+			}).Err()
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// Discard drops the staging collection loaded by Import
+func (BackupDatabase) Discard(name string) error {
+	var err error
+
+	if database.CheckConnection() {
+		err = database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection(stagingPrefix + name).Drop(database.Ctx)
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
@@ -0,0 +1,280 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Claim
+// *****************************************************************************
+
+// PlaceholderAuthors are the authors of the imported crackmes, whose real
+// authors may claim them
+var PlaceholderAuthors = []string{"crackmes.de"}
+
+// Status of the claims
+const (
+	ClaimPending  = "pending"
+	ClaimApproved = "approved"
+	ClaimRejected = "rejected"
+)
+
+var (
+	// ErrNotClaimable is returned for crackmes not imported under a placeholder author
+	ErrNotClaimable = errors.New("This crackme can't be claimed.")
+	// ErrClaimExists is returned when the user already has a pending claim on the crackme
+	ErrClaimExists = errors.New("A claim of this crackme is already pending.")
+	// ErrClaimDecided is returned when deciding a claim which is not pending
+	// anymore, or whose crackme was transferred meanwhile
+	ErrClaimDecided = errors.New("This claim has already been decided.")
+)
+
+// Claim is a request of a user to become the author of an imported crackme
+type Claim struct {
+	ObjectId     primitive.ObjectID `bson:"_id,omitempty"`
+	HexId        string             `bson:"hexid,omitempty"`
+	CrackmeHexId string             `bson:"crackmehexid"`
+	CrackmeName  string             `bson:"crackmename"`
+	User         string             `bson:"user"`
+	Evidence     string             `bson:"evidence"` // Proof given by the user, e.g. links to the original
+	Status       string             `bson:"status"`
+	CreatedAt    time.Time          `bson:"created_at"`
+	DecidedAt    time.Time          `bson:"decided_at,omitempty"`
+	DecidedBy    string             `bson:"decided_by,omitempty"` // Moderator who decided
+	Reason       string             `bson:"reason,omitempty"`     // Reason of a rejection
+}
+
+// IsClaimable returns true if the crackme was imported under a placeholder
+// author
+func IsClaimable(crackme Crackme) bool {
+	for _, author := range PlaceholderAuthors {
+		if crackme.Author == author {
+			return true
+		}
+	}
+	return false
+}
+
+// ClaimCreate records the claim of a user on an imported crackme
+func ClaimCreate(crackme Crackme, username, evidence string) error {
+	var err error
+
+	if !IsClaimable(crackme) {
+		return ErrNotClaimable
+	}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("claim")
+
+		var nb int64
+		nb, err = collection.CountDocuments(database.Ctx, bson.M{"crackmehexid": crackme.HexId, "user": username, "status": ClaimPending})
+		if err == nil && nb > 0 {
+			return ErrClaimExists
+		}
+
+		if err == nil {
+			objId := primitive.NewObjectID()
+			_, err = collection.InsertOne(database.Ctx, &Claim{
+				ObjectId:     objId,
+				HexId:        objId.Hex(),
+				CrackmeHexId: crackme.HexId,
+				CrackmeName:  crackme.Name,
+				User:         username,
+				Evidence:     evidence,
+				Status:       ClaimPending,
+				CreatedAt:    time.Now(),
+			})
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// ClaimsPending returns the pending claims, oldest first
+func ClaimsPending() ([]Claim, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []Claim{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("claim")
+		opts := options.Find().SetSort(bson.D{{"created_at", 1}})
+		cursor, err = collection.Find(database.Ctx, bson.M{"status": ClaimPending}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// ClaimApprove makes the claimant the author of the crackme. The author of
+// the crackme, the fields derived from it, the counters of the users, the
+// decision on the claims of the crackme and the notifications of the
+// claimants are changed in a single transaction.
+func ClaimApprove(hexid, moderator string) error {
+	if !database.CheckConnection() {
+		return ErrUnavailable
+	}
+	db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+
+	err := withTransaction(func(ctx context.Context) error {
+		var claim Claim
+		now := time.Now()
+
+		err := db.Collection("claim").FindOne(ctx, bson.M{"hexid": hexid, "status": ClaimPending}).Decode(&claim)
+		if err == mongo.ErrNoDocuments {
+			return ErrClaimDecided
+		} else if err != nil {
+			return err
+		}
+
+		// Only one claim can win: the crackme may have been transferred by
+		// another one meanwhile
+		var crackme Crackme
+		err = db.Collection("crackme").FindOneAndUpdate(ctx,
+			bson.M{"hexid": claim.CrackmeHexId, "author": bson.M{"$in": PlaceholderAuthors}},
+			bson.M{"$set": bson.M{"author": claim.User, "updated_at": now}},
+		).Decode(&crackme)
+		if err == mongo.ErrNoDocuments {
+			return ErrClaimDecided
+		} else if err != nil {
+			return err
+		}
+
+		_, err = db.Collection("claim").UpdateOne(ctx,
+			bson.M{"hexid": hexid},
+			bson.M{"$set": bson.M{"status": ClaimApproved, "decided_at": now, "decided_by": moderator}},
+		)
+		if err != nil {
+			return err
+		}
+
+		// The comments of the new author now come from the author
+		comments := db.Collection("comment")
+		if _, err = comments.UpdateMany(ctx, bson.M{"crackmehexid": crackme.HexId}, bson.M{"$set": bson.M{"byauthor": false}}); err != nil {
+			return err
+		}
+		if _, err = comments.UpdateMany(ctx, bson.M{"crackmehexid": crackme.HexId, "author": claim.User}, bson.M{"$set": bson.M{"byauthor": true}}); err != nil {
+			return err
+		}
+
+		if crackme.Visible {
+			users := db.Collection("user")
+			if _, err = users.UpdateOne(ctx, bson.M{"name": crackme.Author}, bson.M{"$inc": bson.M{userCounterCrackmes: -1}}); err != nil {
+				return err
+			}
+			if _, err = users.UpdateOne(ctx, bson.M{"name": claim.User}, bson.M{"$inc": bson.M{userCounterCrackmes: 1}}); err != nil {
+				return err
+			}
+		}
+
+		// The other claims of the crackme lose
+		var others []Claim
+		cursor, err := db.Collection("claim").Find(ctx, bson.M{"crackmehexid": crackme.HexId, "status": ClaimPending})
+		if err == nil {
+			err = cursor.All(ctx, &others)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = db.Collection("claim").UpdateMany(ctx,
+			bson.M{"crackmehexid": crackme.HexId, "status": ClaimPending},
+			bson.M{"$set": bson.M{"status": ClaimRejected, "decided_at": now, "decided_by": moderator, "reason": "Another claim was approved."}},
+		)
+		if err != nil {
+			return err
+		}
+
+		if err = notificationInsert(ctx, claim.User, "You are now the author of '"+crackme.Name+"', your claim has been approved!"); err != nil {
+			return err
+		}
+		for _, other := range others {
+			if err = notificationInsert(ctx, other.User, "Your claim of '"+crackme.Name+"' has been rejected! Reason: Another claim was approved."); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return standardizeError(err)
+}
+
+// ClaimReject rejects a pending claim and notifies the claimant
+func ClaimReject(hexid, moderator, reason string) error {
+	var err error
+	var claim Claim
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("claim")
+		err = collection.FindOneAndUpdate(database.Ctx,
+			bson.M{"hexid": hexid, "status": ClaimPending},
+			bson.M{"$set": bson.M{"status": ClaimRejected, "decided_at": time.Now(), "decided_by": moderator, "reason": reason}},
+		).Decode(&claim)
+		if err == mongo.ErrNoDocuments {
+			return ErrClaimDecided
+		}
+		if err == nil {
+			text := "Your claim of '" + claim.CrackmeName + "' has been rejected!"
+			if reason != "" {
+				text += " Reason: " + reason
+			}
+			err = NotificationAdd(claim.User, text)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// notificationInsert adds a notification within a transaction
+func notificationInsert(ctx context.Context, username, text string) error {
+	collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("notifications")
+	objId := primitive.NewObjectID()
+	_, err := collection.InsertOne(ctx, &Notification{
+		ObjectId: objId,
+		HexId:    objId.Hex(),
+		User:     username,
+		Text:     text,
+		Time:     time.Now(),
+	})
+	return err
+}
+
+// errTransactionsUnsupported is the code of the error of the standalone
+// servers, which don't support transactions
+const errTransactionsUnsupported = 20
+
+// withTransaction runs fn in a transaction. On a standalone server, which
+// doesn't support them, fn runs without one: its first write must then be the
+// one preventing a concurrent run from doing the same.
+func withTransaction(fn func(ctx context.Context) error) error {
+	sess, err := database.Mongo.StartSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(database.Ctx)
+
+	_, err = sess.WithTransaction(database.Ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	if se, ok := err.(mongo.ServerError); ok && se.HasErrorCode(errTransactionsUnsupported) {
+		log.Println("Transactions unsupported by the database, running without one")
+		return fn(database.Ctx)
+	}
+	return err
+}
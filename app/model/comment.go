@@ -1,6 +1,7 @@
 package model
 
 import (
+	"log"
 	"time"
 
 	"github.com/crackmesone/crackmes.one/app/shared/database"
@@ -25,6 +26,9 @@ type Comment struct {
 	CreatedAt    time.Time          `bson:"created_at"`
 	Visible      bool               `bson:"visible"`
 	Deleted      bool               `bson:"deleted"`
+	ByAuthor     bool               `bson:"byauthor"`       // Written by the crackme author, stored so it survives a rename
+	Reactions    int                `bson:"reactions"`      // Number of reactions, counted from the reaction collection
+	Held         string             `bson:"held,omitempty"` // Why the content filter held it for the moderators
 }
 
 func CountCommentsByUser(username string) (int, error) {
@@ -32,7 +36,7 @@ func CountCommentsByUser(username string) (int, error) {
 	var nb int64
 	if database.CheckConnection() {
 		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("comment")
-		nb, err = collection.CountDocuments(database.Ctx, bson.M{"author": username})
+		nb, err = collection.CountDocuments(database.Ctx, bson.M{"author": username, "visible": true})
 	} else {
 		err = ErrUnavailable
 	}
@@ -57,10 +61,14 @@ func CommentsByUser(name string) ([]Comment, error) {
 	var result []Comment
 	if database.CheckConnection() {
 		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("comment")
-		// Validate the object id
-		opts := options.Find().SetSort(bson.D{{"created_at", -1}})
-		cursor, err = collection.Find(database.Ctx, bson.M{"author": name, "visible": true}, opts)
-		err = cursor.All(database.Ctx, &result)
+		// Not those of the unlisted crackmes, found by their link only
+		pipeline := mongo.Pipeline{{{"$match", bson.M{"author": name, "visible": true}}}}
+		pipeline = append(pipeline, ScopeListed.crackmeStages()...)
+		pipeline = append(pipeline, bson.D{{"$sort", bson.D{{"created_at", -1}}}})
+		cursor, err = collection.Aggregate(database.Ctx, pipeline)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
 	} else {
 		err = ErrUnavailable
 	}
@@ -85,7 +93,43 @@ func CommentsByCrackMe(crackmehexid string) ([]Comment, error) {
 	return result, err
 }
 
+// CommentsPerPage is the number of comments of a page of the crackme API
+const CommentsPerPage = 50
+
+// CommentsByCrackMePage returns a page of the visible comments of a crackme,
+// oldest first
+func CommentsByCrackMePage(crackmehexid string, page int) ([]Comment, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []Comment{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("comment")
+		opts := options.Find().SetSort(bson.D{{"created_at", 1}}).SetSkip(int64((page - 1) * CommentsPerPage)).SetLimit(CommentsPerPage)
+		cursor, err = collection.Find(database.Ctx, bson.M{"crackmehexid": crackmehexid, "visible": true}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// CommentCreate publishes a comment
 func CommentCreate(content, username, crackmehexid string) error {
+	return commentInsert(content, username, crackmehexid, "")
+}
+
+// CommentCreateHeld stores a comment held by the content filter, hidden until
+// the moderators approve it
+func CommentCreateHeld(content, username, crackmehexid, reason string) error {
+	return commentInsert(content, username, crackmehexid, reason)
+}
+
+// commentInsert stores a comment, visible unless it is held
+func commentInsert(content, username, crackmehexid, held string) error {
 	var err error
 
 	// Fetch crackme to get its name
@@ -104,13 +148,174 @@ func CommentCreate(content, username, crackmehexid string) error {
 			CrackMeHexId: crackmehexid,
 			CrackmeName:  crackme.Name,
 			CreatedAt:    time.Now(),
-			Visible:      true,
+			Visible:      held == "",
 			Deleted:      false,
+			ByAuthor:     crackme.Author == username,
+			Held:         held,
 		}
 		_, err = collection.InsertOne(database.Ctx, comment)
+		if err == nil && comment.Visible {
+			// Comments are visible right away, so they count immediately
+			if cerr := userIncrementCounter(username, userCounterComments, 1); cerr != nil {
+				log.Println("Failed to increment comment counter:", cerr)
+			}
+			EventEmit(Event{Type: EventCommentPosted, User: username, HexId: objId.Hex(), CrackmeHexId: crackmehexid})
+		}
 	} else {
 		err = ErrUnavailable
 	}
 
 	return standardizeError(err)
 }
+
+// CommentsHeld returns the comments held by the content filter, oldest first
+func CommentsHeld() ([]Comment, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []Comment{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("comment")
+		opts := options.Find().SetSort(bson.D{{"created_at", 1}})
+		cursor, err = collection.Find(database.Ctx, bson.M{"held": bson.M{"$exists": true}, "visible": false}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// CommentApprove publishes a held comment and returns it
+func CommentApprove(hexid string) (Comment, error) {
+	var err error
+	var result Comment
+
+	id, err := primitive.ObjectIDFromHex(hexid)
+	if err != nil {
+		return result, ErrNoResult
+	}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("comment")
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		err = collection.FindOneAndUpdate(database.Ctx,
+			bson.M{"_id": id, "held": bson.M{"$exists": true}, "visible": false},
+			bson.M{"$set": bson.M{"visible": true}, "$unset": bson.M{"held": ""}}, opts).Decode(&result)
+		if err == nil {
+			if cerr := userIncrementCounter(result.Author, userCounterComments, 1); cerr != nil {
+				log.Println("Failed to increment comment counter:", cerr)
+			}
+			// Posted once the moderators let it through
+			EventEmit(Event{Type: EventCommentPosted, User: result.Author, HexId: result.ObjectId.Hex(), CrackmeHexId: result.CrackMeHexId})
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// CommentReject deletes a held comment and returns it
+func CommentReject(hexid string) (Comment, error) {
+	var err error
+	var result Comment
+
+	id, err := primitive.ObjectIDFromHex(hexid)
+	if err != nil {
+		return result, ErrNoResult
+	}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("comment")
+		err = collection.FindOneAndDelete(database.Ctx, bson.M{"_id": id, "held": bson.M{"$exists": true}, "visible": false}).Decode(&result)
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// CommentByHexId returns a visible comment
+func CommentByHexId(hexid string) (Comment, error) {
+	var err error
+	var result Comment
+
+	id, err := primitive.ObjectIDFromHex(hexid)
+	if err != nil {
+		return result, ErrNoResult
+	}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("comment")
+		err = collection.FindOne(database.Ctx, bson.M{"_id": id, "visible": true}).Decode(&result)
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// CommentEdit replaces the content of a visible comment of username posted
+// since a date, and returns it
+func CommentEdit(hexid, username, content string, since time.Time) (Comment, error) {
+	var err error
+	var result Comment
+
+	id, err := primitive.ObjectIDFromHex(hexid)
+	if err != nil {
+		return result, ErrNoResult
+	}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("comment")
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		err = collection.FindOneAndUpdate(database.Ctx,
+			bson.M{"_id": id, "author": username, "visible": true, "created_at": bson.M{"$gte": since}},
+			bson.M{"$set": bson.M{"info": content}}, opts).Decode(&result)
+		if err == nil {
+			if cerr := CrackmeTouch(result.CrackMeHexId); cerr != nil {
+				log.Println("Failed to touch the crackme:", cerr)
+			}
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// CommentWithdraw deletes a visible comment of username posted since a date,
+// with its reactions, and returns it
+func CommentWithdraw(hexid, username string, since time.Time) (Comment, error) {
+	var err error
+	var result Comment
+
+	id, err := primitive.ObjectIDFromHex(hexid)
+	if err != nil {
+		return result, ErrNoResult
+	}
+
+	if database.CheckConnection() {
+		db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+		err = db.Collection("comment").FindOneAndDelete(database.Ctx,
+			bson.M{"_id": id, "author": username, "visible": true, "created_at": bson.M{"$gte": since}}).Decode(&result)
+		if err == nil {
+			if cerr := userIncrementCounter(username, userCounterComments, -1); cerr != nil {
+				log.Println("Failed to decrement comment counter:", cerr)
+			}
+			if cerr := CrackmeDecrementComments(result.CrackMeHexId); cerr != nil {
+				log.Println("Failed to decrement comment count:", cerr)
+			}
+			if _, cerr := db.Collection("reaction").DeleteMany(database.Ctx, bson.M{"commentid": id}); cerr != nil {
+				log.Println("Failed to delete the reactions:", cerr)
+			}
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
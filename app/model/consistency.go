@@ -0,0 +1,174 @@
+package model
+
+import (
+	"log"
+
+	"github.com/crackmesone/crackmes.one/app/shared/consistency"
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"github.com/crackmesone/crackmes.one/app/shared/download"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Consistency
+// *****************************************************************************
+
+// ConsistencyReport is a stored consistency check report
+type ConsistencyReport struct {
+	ObjectId           primitive.ObjectID `bson:"_id,omitempty"`
+	consistency.Report `bson:",inline"`
+}
+
+// consistencyDocuments returns the visible documents of a collection, which
+// must have a file in the storage
+func consistencyDocuments(kind string) ([]consistency.Document, error) {
+	var err error
+	var docs []consistency.Document
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection(kind)
+		opts := options.Find().SetProjection(bson.M{"hexid": 1, "sha256": 1})
+		var results []struct {
+			HexId  string `bson:"hexid"`
+			SHA256 string `bson:"sha256"`
+		}
+		cursor, err := collection.Find(database.Ctx, bson.M{"visible": true}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &results)
+		}
+		if err != nil {
+			return nil, standardizeError(err)
+		}
+		for _, r := range results {
+			docs = append(docs, consistency.Document{Kind: kind, HexId: r.HexId, SHA256: r.SHA256})
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return docs, standardizeError(err)
+}
+
+// ConsistencyCheck cross-checks the visible crackmes and solutions with the
+// storage, records the hashes of the files seen for the first time, flags the
+// documents whose file can't be downloaded and saves the report
+func ConsistencyCheck() (ConsistencyReport, error) {
+	result := ConsistencyReport{}
+
+	var docs []consistency.Document
+	dirs := make(map[string]string)
+	for _, kind := range []string{"crackme", "solution"} {
+		kindDocs, err := consistencyDocuments(kind)
+		if err != nil {
+			return result, err
+		}
+		docs = append(docs, kindDocs...)
+		dirs[kind] = download.Dir(kind)
+	}
+
+	report, err := consistency.Check(docs, dirs)
+	if err != nil {
+		return result, err
+	}
+	result.Report = *report
+
+	if database.CheckConnection() {
+		db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+		for _, doc := range report.Hashed {
+			_, err = db.Collection(doc.Kind).UpdateOne(database.Ctx, bson.M{"hexid": doc.HexId}, bson.M{"$set": bson.M{"sha256": doc.SHA256}})
+			if err != nil {
+				return result, standardizeError(err)
+			}
+		}
+
+		for _, kind := range []string{"crackme", "solution"} {
+			if err = consistencyFlag(db.Collection(kind), kind, report.Issues); err != nil {
+				return result, standardizeError(err)
+			}
+		}
+
+		var res *mongo.InsertOneResult
+		res, err = db.Collection("consistency").InsertOne(database.Ctx, result)
+		if err == nil {
+			result.ObjectId = res.InsertedID.(primitive.ObjectID)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// consistencyFlag flags the documents of a kind whose file can't be
+// downloaded, notifying their author the first time, and clears the flag of
+// the ones fixed since the previous check
+func consistencyFlag(collection *mongo.Collection, kind string, issues []consistency.Issue) error {
+	broken := []string{}
+	for _, i := range issues {
+		if i.Kind == kind && i.Broken() {
+			broken = append(broken, i.HexId)
+		}
+	}
+
+	_, err := collection.UpdateMany(database.Ctx,
+		bson.M{"broken": true, "hexid": bson.M{"$nin": broken}},
+		bson.M{"$unset": bson.M{"broken": ""}})
+	if err != nil || len(broken) == 0 {
+		return err
+	}
+
+	var flagged []struct {
+		Author      string `bson:"author"`
+		Name        string `bson:"name"`
+		CrackmeName string `bson:"crackmename"`
+	}
+	filter := bson.M{"hexid": bson.M{"$in": broken}, "broken": bson.M{"$ne": true}}
+	cursor, err := collection.Find(database.Ctx, filter)
+	if err == nil {
+		err = cursor.All(database.Ctx, &flagged)
+	}
+	if err == nil {
+		_, err = collection.UpdateMany(database.Ctx, filter, bson.M{"$set": bson.M{"broken": true}})
+	}
+	if err != nil {
+		return err
+	}
+
+	// Failing to notify doesn't fail the check
+	for _, f := range flagged {
+		text := "The file of your crackme '" + f.Name + "' can't be downloaded anymore and it is hidden from the listings, please upload it again as a new version."
+		if kind == "solution" {
+			text = "The file of your writeup for '" + f.CrackmeName + "' can't be downloaded anymore and it is hidden from the listings, please upload it again."
+		}
+		if nerr := NotificationAdd(f.Author, text); nerr != nil {
+			log.Println(nerr)
+		}
+	}
+	return nil
+}
+
+// ConsistencyLatest returns the latest consistency check report
+func ConsistencyLatest() (ConsistencyReport, error) {
+	var err error
+
+	result := ConsistencyReport{}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("consistency")
+		opts := options.FindOne().SetSort(bson.D{{"created_at", -1}})
+		err = collection.FindOne(database.Ctx, bson.M{}, opts).Decode(&result)
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// ConsistencyRun runs a consistency check, for the scheduler
+func ConsistencyRun() error {
+	_, err := ConsistencyCheck()
+	return err
+}
@@ -1,9 +1,15 @@
 package model
 
 import (
+	"context"
+	"log"
 	"time"
 
+	"github.com/crackmesone/crackmes.one/app/shared/archive"
 	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"github.com/crackmesone/crackmes.one/app/shared/exeinfo"
+	"github.com/crackmesone/crackmes.one/app/shared/site"
+	"github.com/crackmesone/crackmes.one/app/shared/webhook"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -17,21 +23,43 @@ import (
 
 // Crackme table contains the information for each note
 type Crackme struct {
-	ObjectId    primitive.ObjectID `bson:"_id,omitempty"`
-	HexId       string             `bson:"hexid,omitempty"`
-	Name        string             `bson:"name,omitempty"`
-	Info        string             `bson:"info,omitempty"`
-	Lang        string             `bson:"lang,omitempty"`
-	Arch        string             `bson:"arch,omitempty"`
-	Author      string             `bson:"author,omitempty"`
-	CreatedAt   time.Time          `bson:"created_at"`
-	Visible     bool               `bson:"visible"`
-	Deleted     bool               `bson:"deleted"`
-	Difficulty  float64            `bson:"difficulty"`
-	Quality     float64            `bson:"quality"`
-	NbSolutions int                `bson:"nbsolutions"`
-	NbComments  int                `bson:"nbcomments"`
-	Platform    string             `bson:"platform,omitempty"`
+	ObjectId     primitive.ObjectID `bson:"_id,omitempty"`
+	HexId        string             `bson:"hexid,omitempty"`
+	Name         string             `bson:"name,omitempty"`
+	Info         string             `bson:"info,omitempty"`
+	Lang         string             `bson:"lang,omitempty"`
+	Arch         string             `bson:"arch,omitempty"`
+	Author       string             `bson:"author,omitempty"`
+	CreatedAt    time.Time          `bson:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at,omitempty"` // Last change of what its page shows, keys the cached fragments
+	Visible      bool               `bson:"visible"`
+	Visibility   Visibility         `bson:"visibility,omitempty"` // Once visible, who finds it
+	Team         string             `bson:"team,omitempty"`       // Team it was posted for
+	Archived     bool               `bson:"archived,omitempty"`   // Takes no new solution or comment
+	Broken       bool               `bson:"broken,omitempty"`     // Its file is missing, empty or unreadable
+	Deleted      bool               `bson:"deleted"`
+	Difficulty   float64            `bson:"difficulty"`
+	Quality      float64            `bson:"quality"`
+	NbSolutions  int                `bson:"nbsolutions"`
+	NbComments   int                `bson:"nbcomments"`
+	Platform     string             `bson:"platform,omitempty"`
+	ShortId      string             `bson:"shortid,omitempty"`
+	Binary       *exeinfo.Info      `bson:"binary,omitempty"`
+	Files        []archive.Entry    `bson:"files,omitempty"`
+	Downloads    int                `bson:"downloads"`
+	Pick         string             `bson:"pick,omitempty"` // Hexid of the solution picked by the author
+	Version      int                `bson:"version,omitempty"`
+	Versions     []CrackmeVersion   `bson:"versions,omitempty"`
+	WriteupLangs []string           `bson:"writeuplangs,omitempty"` // Languages of the visible solutions
+	License      string             `bson:"license,omitempty"`
+	Format       string             `bson:"format,omitempty"`            // Format of the uploaded file: archive, executable or "file"
+	Size         int64              `bson:"size,omitempty"`              // Size of the download, recorded on approval
+	SHA256       string             `bson:"sha256,omitempty"`            // Hash of the download, recorded on approval
+	Prereqs      []string           `bson:"prerequisites,omitempty"`     // Codes of what solvers should know, see Prerequisites
+	Readme       string             `bson:"readme,omitempty"`            // Readme found in the upload, shown as the author notes
+	Processing   string             `bson:"processing_status,omitempty"` // See ProcessingStatus
+	Milestones   []string           `bson:"milestones,omitempty"`        // Milestones its author was notified of, see MilestonesCheck
+	Rev          int                `bson:"rev,omitempty"`               // Revision of the document, see CrackmeUpdateRev
 }
 
 // CountCrackmes returns the total number of crackmes in the collection.
@@ -48,7 +76,7 @@ func CountCrackmes() (int, error) {
 	var err error
 	var nb int64
 	if database.CheckConnection() {
-		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		collection := database.Listing("crackme")
 		nb, err = collection.EstimatedDocumentCount(database.Ctx)
 	} else {
 		err = ErrUnavailable
@@ -69,6 +97,20 @@ func CountCrackmesByUser(username string) (int, error) {
 	return int(nb), standardizeError(err)
 }
 
+// CountPendingCrackmesByUser returns the number of crackmes of username
+// waiting for approval
+func CountPendingCrackmesByUser(username string) (int, error) {
+	var err error
+	var nb int64
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		nb, err = collection.CountDocuments(database.Ctx, bson.M{"author": username, "visible": false, "deleted": false})
+	} else {
+		err = ErrUnavailable
+	}
+	return int(nb), standardizeError(err)
+}
+
 func GetAllCrackmes() ([]Crackme, error) {
 	var err error
 	var result []Crackme
@@ -93,7 +135,7 @@ func CrackmeSetFloat(hexid, champ string, nb float64) error {
 		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
 
 		// Validate the object id
-		_, err = collection.UpdateOne(database.Ctx, bson.M{"hexid": hexid}, bson.M{"$set": bson.M{champ: float64(nb)}})
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"hexid": hexid}, bson.M{"$set": bson.M{champ: float64(nb), "updated_at": time.Now()}})
 	} else {
 		err = ErrUnavailable
 	}
@@ -136,12 +178,25 @@ func CrackmeUpdateQuality(crackmehexid string) error {
 	return CrackmeSetFloat(crackmehexid, "quality", quality)
 }
 
+// CrackmeTouch records a change of what the page of a crackme shows, so its
+// cached fragments are rendered again
+func CrackmeTouch(crackmehexid string) error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"hexid": crackmehexid}, bson.M{"$set": bson.M{"updated_at": time.Now()}})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
 // CrackmeIncrementComments increments the comment count for a crackme
 func CrackmeIncrementComments(crackmehexid string) error {
 	var err error
 	if database.CheckConnection() {
 		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
-		_, err = collection.UpdateOne(database.Ctx, bson.M{"hexid": crackmehexid}, bson.M{"$inc": bson.M{"nbcomments": 1}})
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"hexid": crackmehexid}, bson.M{"$inc": bson.M{"nbcomments": 1}, "$set": bson.M{"updated_at": time.Now()}})
 	} else {
 		err = ErrUnavailable
 	}
@@ -153,35 +208,128 @@ func CrackmeDecrementComments(crackmehexid string) error {
 	var err error
 	if database.CheckConnection() {
 		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
-		_, err = collection.UpdateOne(database.Ctx, bson.M{"hexid": crackmehexid}, bson.M{"$inc": bson.M{"nbcomments": -1}})
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"hexid": crackmehexid}, bson.M{"$inc": bson.M{"nbcomments": -1}, "$set": bson.M{"updated_at": time.Now()}})
 	} else {
 		err = ErrUnavailable
 	}
 	return err
 }
 
-func SearchCrackme(name, author, lang, arch, platform string, difficulty_min, difficulty_max, quality_min, quality_max int) ([]Crackme, error) {
+// CrackmeIncrementSolutions increments the solution count for a crackme
+func CrackmeIncrementSolutions(crackmehexid string) error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"hexid": crackmehexid}, bson.M{"$inc": bson.M{"nbsolutions": 1}, "$set": bson.M{"updated_at": time.Now()}})
+	} else {
+		err = ErrUnavailable
+	}
+	return err
+}
+
+// CrackmeDecrementSolutions decrements the solution count for a crackme
+func CrackmeDecrementSolutions(crackmehexid string) error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"hexid": crackmehexid}, bson.M{"$inc": bson.M{"nbsolutions": -1}, "$set": bson.M{"updated_at": time.Now()}})
+	} else {
+		err = ErrUnavailable
+	}
+	return err
+}
+
+// SearchSort is an order of the search results
+type SearchSort struct {
+	Code  string
+	Name  string
+	Field string
+}
+
+// SearchSorts are the orders of the search results, the first is the default
+var SearchSorts = []SearchSort{
+	{"newest", "Date", "created_at"},
+	{"difficulty", "Difficulty", "difficulty"},
+	{"quality", "Quality", "quality"},
+	{"solutions", "Writeups", "nbsolutions"},
+}
+
+// searchSort returns the sort of the results by the order of code, the
+// newest first among equals, or reversed when ascending
+func searchSort(code string, ascending bool) bson.D {
+	dir := -1
+	if ascending {
+		dir = 1
+	}
+	field := SearchSorts[0].Field
+	for _, s := range SearchSorts {
+		if s.Code == code {
+			field = s.Field
+		}
+	}
+	if field == "created_at" {
+		return bson.D{{"created_at", dir}}
+	}
+	return bson.D{{field, dir}, {"created_at", dir}}
+}
+
+// SearchEnsureIndexes creates the indexes of the visible crackmes by each of
+// the orders of the search, scanned backwards for the ascending ones
+func SearchEnsureIndexes() error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		var models []mongo.IndexModel
+		for _, s := range SearchSorts {
+			models = append(models, mongo.IndexModel{
+				Keys:    append(bson.D{{"visible", 1}}, searchSort(s.Code, false)...),
+				Options: options.Index().SetName("search_" + s.Code),
+			})
+		}
+		_, err = collection.Indexes().CreateMany(database.Ctx, models)
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// SearchCrackme returns the first 150 visible crackmes matching the filters,
+// in the order of sortBy, descending unless ascending
+func SearchCrackme(name, author, lang, arch, platform, writeuplang, prerequisite, archived string, difficulty_min, difficulty_max, quality_min, quality_max int, sortBy string, ascending bool) ([]Crackme, error) {
 	var err error
 	var result []Crackme
 	var cursor *mongo.Cursor
 
 	if database.CheckConnection() {
 		// Create a copy of mongo
-		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
-		opts := options.Find().SetSort(bson.D{{"created_at", -1}}).SetLimit(150)
+		collection := database.Listing("crackme")
+		opts := options.Find().SetSort(searchSort(sortBy, ascending)).SetLimit(150)
+
+		filter := bson.D{
+			{"name", primitive.Regex{Pattern: name, Options: "i"}},
+			{"lang", primitive.Regex{Pattern: lang, Options: "i"}},
+			{"arch", primitive.Regex{Pattern: arch, Options: "i"}},
+			{"difficulty", bson.M{"$gte": difficulty_min, "$lte": difficulty_max}},
+			{"quality", bson.M{"$gte": quality_min, "$lte": quality_max}},
+			{"author", primitive.Regex{Pattern: author, Options: "i"}},
+			{"platform", primitive.Regex{Pattern: platform, Options: "i"}},
+		}
+		filter = append(filter, ScopeListed.match()...)
+		// Crackmes with at least one writeup in that language
+		if writeuplang != "" {
+			filter = append(filter, bson.E{"writeuplangs", writeuplang})
+		}
+		// Crackmes needing that knowledge
+		if prerequisite != "" {
+			filter = append(filter, bson.E{"prerequisites", prerequisite})
+		}
+		// Crackmes archived or not
+		if match, ok := archiveMatch(archived); ok {
+			filter = append(filter, match)
+		}
 
 		// Validate the object id
-		cursor, err = collection.Find(database.Ctx,
-			bson.D{
-				{"name", primitive.Regex{Pattern: name, Options: "i"}},
-				{"lang", primitive.Regex{Pattern: lang, Options: "i"}},
-				{"arch", primitive.Regex{Pattern: arch, Options: "i"}},
-				{"difficulty", bson.M{"$gte": difficulty_min, "$lte": difficulty_max}},
-				{"quality", bson.M{"$gte": quality_min, "$lte": quality_max}},
-				{"author", primitive.Regex{Pattern: author, Options: "i"}},
-				{"visible", true},
-				{"platform", primitive.Regex{Pattern: platform, Options: "i"}},
-			}, opts)
+		cursor, err = collection.Find(database.Ctx, filter, opts)
 
 		err = cursor.All(database.Ctx, &result)
 
@@ -191,18 +339,26 @@ func SearchCrackme(name, author, lang, arch, platform string, difficulty_min, di
 	return result, err
 }
 
-func LastCrackMes(page int) ([]Crackme, error) {
+// LastCrackMes returns a page of the latest crackmes, without the ones hidden
+// by the preferences of the viewer
+func LastCrackMes(page int, viewer Viewer) ([]Crackme, error) {
 	var err error
 	var result []Crackme
 	var cursor *mongo.Cursor
 
 	if database.CheckConnection() {
 		// Create a copy of mongo
-		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		collection := database.Listing("crackme")
 		opts := options.Find().SetSort(bson.D{{"created_at", -1}}).SetLimit(50).SetSkip(int64((page - 1) * 50))
 
+		var match bson.M
+		match, err = listingMatch(viewer)
+		if err != nil {
+			return result, err
+		}
+
 		// Validate the object id
-		cursor, err = collection.Find(database.Ctx, bson.M{"visible": true}, opts)
+		cursor, err = collection.Find(database.Ctx, match, opts)
 		err = cursor.All(database.Ctx, &result)
 
 	} else {
@@ -224,7 +380,7 @@ func CrackmeByHexId(hexid string) (Crackme, error) {
 	} else {
 		err = ErrUnavailable
 	}
-	return result, err
+	return result, standardizeError(err)
 }
 
 func CrackmesByUser(username string) ([]Crackme, error) {
@@ -237,7 +393,7 @@ func CrackmesByUser(username string) ([]Crackme, error) {
 		opts := options.Find().SetSort(bson.D{{"created_at", -1}})
 
 		// Validate the object id
-		cursor, err = collection.Find(database.Ctx, bson.M{"author": username, "visible": true}, opts)
+		cursor, err = collection.Find(database.Ctx, ScopeListed.filter(bson.M{"author": username}), opts)
 		err = cursor.All(database.Ctx, &result)
 	} else {
 		err = ErrUnavailable
@@ -283,6 +439,10 @@ func CrackmeCreate(name, info, username, lang, arch, platform string) error {
 			Platform:  platform,
 		}
 		_, err = collection.InsertOne(database.Ctx, crackme)
+		err = duplicateError(err, ErrCrackmeExists)
+		if err == nil {
+			EventEmit(Event{Type: EventCrackmeUploaded, User: username, HexId: crackme.HexId, CrackmeHexId: crackme.HexId})
+		}
 	} else {
 		err = ErrUnavailable
 	}
@@ -322,6 +482,217 @@ func CrackmeInsert(crackme *Crackme) error {
 	if database.CheckConnection() {
 		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
 		_, err = collection.InsertOne(database.Ctx, crackme)
+		err = duplicateError(err, ErrCrackmeExists)
+		if err == nil {
+			EventEmit(Event{Type: EventCrackmeUploaded, User: crackme.Author, HexId: crackme.HexId, CrackmeHexId: crackme.HexId})
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// webhookInfo returns the description of the crackme sent with webhook events
+func (c Crackme) webhookInfo() webhook.Crackme {
+	return webhook.Crackme{
+		HexId:      c.HexId,
+		Name:       c.Name,
+		Author:     c.Author,
+		Lang:       c.Lang,
+		Arch:       c.Arch,
+		Platform:   c.Platform,
+		Difficulty: c.Difficulty,
+		URL:        site.URL("/crackme/" + c.HexId),
+	}
+}
+
+// base62Chars are the digits of the short ids
+const base62Chars = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// base62 returns n written in base 62
+func base62(n int64) string {
+	if n == 0 {
+		return "0"
+	}
+	var b []byte
+	for ; n > 0; n /= 62 {
+		b = append([]byte{base62Chars[n%62]}, b...)
+	}
+	return string(b)
+}
+
+// nextSequence increments and returns the named counter of the counters
+// collection, creating it on first use. Within a transaction, ctx is its
+// context: the increment is undone with it.
+func nextSequence(ctx context.Context, name string) (int64, error) {
+	var err error
+	var result struct {
+		Seq int64 `bson:"seq"`
+	}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("counters")
+		opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+		err = collection.FindOneAndUpdate(ctx, bson.M{"_id": name}, bson.M{"$inc": bson.M{"seq": 1}}, opts).Decode(&result)
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result.Seq, standardizeError(err)
+}
+
+// crackmeShortId returns the short id of a crackme, used by the /c/ links,
+// assigning it if the crackme has none yet. Ids are the base 62 of a counter.
+func crackmeShortId(crackme Crackme) (string, error) {
+	if crackme.ShortId != "" {
+		return crackme.ShortId, nil
+	}
+
+	seq, err := nextSequence(database.Ctx, "crackme_shortid")
+	if err != nil {
+		return "", err
+	}
+
+	shortid := base62(seq)
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		var res *mongo.UpdateResult
+		res, err = collection.UpdateOne(database.Ctx,
+			bson.M{"hexid": crackme.HexId, "shortid": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"shortid": shortid}})
+		if err == nil && res.MatchedCount == 0 {
+			// Assigned concurrently, use the stored one
+			var stored Crackme
+			stored, err = CrackmeByHexId(crackme.HexId)
+			shortid = stored.ShortId
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return shortid, standardizeError(err)
+}
+
+// CrackmeShortIdsAssign gives their short id to the visible crackmes approved
+// before the ids were assigned at approval, the pages never write them
+func CrackmeShortIdsAssign() error {
+	var err error
+	var crackmes []Crackme
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		opts := options.Find().SetSort(bson.M{"created_at": 1}).SetProjection(bson.M{"hexid": 1})
+		var cursor *mongo.Cursor
+		cursor, err = collection.Find(database.Ctx, bson.M{"visible": true, "shortid": bson.M{"$exists": false}}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &crackmes)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	for _, crackme := range crackmes {
+		if err != nil {
+			break
+		}
+		_, err = crackmeShortId(crackme)
+	}
+	return standardizeError(err)
+}
+
+// CrackmeByShortId returns the visible crackme with the given short id
+func CrackmeByShortId(shortid string) (Crackme, error) {
+	var err error
+
+	var result Crackme
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		err = collection.FindOne(database.Ctx, bson.M{"shortid": shortid, "visible": true}).Decode(&result)
+	} else {
+		err = ErrUnavailable
+	}
+	return result, standardizeError(err)
+}
+
+// CrackmeApprove makes a pending crackme visible, counts it for its author and
+// fires the crackme approval webhooks. validate.py approves through it, see the
+// -approve-crackme flag.
+func CrackmeApprove(hexid string) error {
+	return crackmeApprove(bson.M{"hexid": hexid, "visible": false})
+}
+
+// CrackmeApproveRev approves a pending crackme like CrackmeApprove, as long
+// as it is still at the revision the moderator reviewed. It returns
+// ErrConflict when its author edited it since.
+func CrackmeApproveRev(hexid string, rev int) error {
+	err := crackmeApprove(bson.M{"hexid": hexid, "visible": false, "rev": revMatch(rev)})
+	if err == ErrNoResult {
+		err = crackmeRevConflict(bson.M{"hexid": hexid, "visible": false})
+	}
+	return err
+}
+
+func crackmeApprove(filter bson.M) error {
+	var crackme Crackme
+
+	if !database.CheckConnection() {
+		return ErrUnavailable
+	}
+	db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+
+	// The crackme, its short id, the counter and the notification of its
+	// author are written together or not at all
+	err := withTransaction(func(ctx context.Context) error {
+		crackme = Crackme{}
+		err := db.Collection("crackme").FindOneAndUpdate(ctx, filter,
+			bson.M{"$set": bson.M{"visible": true}, "$inc": bson.M{"rev": 1}}).Decode(&crackme)
+		if err != nil {
+			return err
+		}
+		if crackme.ShortId == "" {
+			seq, err := nextSequence(ctx, "crackme_shortid")
+			if err != nil {
+				return err
+			}
+			crackme.ShortId = base62(seq)
+			if _, err = db.Collection("crackme").UpdateOne(ctx, bson.M{"hexid": crackme.HexId}, bson.M{"$set": bson.M{"shortid": crackme.ShortId}}); err != nil {
+				return err
+			}
+		}
+		if _, err = db.Collection("user").UpdateOne(ctx, bson.M{"name": crackme.Author}, bson.M{"$inc": bson.M{userCounterCrackmes: 1}}); err != nil {
+			return err
+		}
+		return notificationInsert(ctx, crackme.Author, "Your crackme '"+crackme.Name+"' has been approved!")
+	})
+	if err != nil {
+		return standardizeError(err)
+	}
+
+	crackme.Visible = true
+	// The unlisted crackmes are not announced
+	if crackme.Visibility != VisibilityUnlisted {
+		webhook.Fire(webhook.Event{Type: webhook.EventCrackmeApproved, Crackme: crackme.webhookInfo()})
+	}
+
+	return nil
+}
+
+// CrackmeDeleteByHexId deletes a crackme by its hexid, and uncounts it for its
+// author if it was visible
+// CrackmeSetPick records the solution picked by the author of a crackme, or
+// removes the pick when solutionhexid is empty
+func CrackmeSetPick(crackmehexid, solutionhexid string) error {
+	var err error
+
+	update := bson.M{"$set": bson.M{"pick": solutionhexid, "updated_at": time.Now()}}
+	if solutionhexid == "" {
+		update = bson.M{"$unset": bson.M{"pick": ""}, "$set": bson.M{"updated_at": time.Now()}}
+	}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"hexid": crackmehexid}, update)
 	} else {
 		err = ErrUnavailable
 	}
@@ -329,16 +700,87 @@ func CrackmeInsert(crackme *Crackme) error {
 	return standardizeError(err)
 }
 
-// CrackmeDeleteByHexId deletes a crackme by its hexid
 func CrackmeDeleteByHexId(hexid string) error {
 	var err error
+	var crackme Crackme
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		err = collection.FindOneAndDelete(database.Ctx, bson.M{"hexid": hexid}).Decode(&crackme)
+		if err == nil && crackme.Visible {
+			if cerr := userIncrementCounter(crackme.Author, userCounterCrackmes, -1); cerr != nil {
+				log.Println("Failed to decrement crackme counter:", cerr)
+			}
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// CrackmesPendingByUser returns the crackmes of username waiting for approval
+// posted since a date, newest first
+func CrackmesPendingByUser(username string, since time.Time) ([]Crackme, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []Crackme{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		opts := options.Find().SetSort(bson.D{{"created_at", -1}})
+		cursor, err = collection.Find(database.Ctx, bson.M{"author": username, "visible": false, "deleted": false, "created_at": bson.M{"$gte": since}}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// CrackmePendingEdit replaces the description of a crackme of username
+// waiting for approval, posted since a date
+func CrackmePendingEdit(hexid, username, info string, since time.Time) error {
+	var err error
+	var res *mongo.UpdateResult
 
 	if database.CheckConnection() {
 		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
-		_, err = collection.DeleteOne(database.Ctx, bson.M{"hexid": hexid})
+		res, err = collection.UpdateOne(database.Ctx,
+			bson.M{"hexid": hexid, "author": username, "visible": false, "deleted": false, "created_at": bson.M{"$gte": since}},
+			bson.M{"$set": bson.M{"info": info, "updated_at": time.Now()}, "$inc": bson.M{"rev": 1}})
+		if err == nil && res.MatchedCount == 0 {
+			err = ErrNoResult
+		}
 	} else {
 		err = ErrUnavailable
 	}
 
 	return standardizeError(err)
 }
+
+// CrackmeWithdraw deletes a crackme of username waiting for approval, posted
+// since a date, with the ratings given on upload, and returns it
+func CrackmeWithdraw(hexid, username string, since time.Time) (Crackme, error) {
+	var err error
+	var crackme Crackme
+
+	if database.CheckConnection() {
+		db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+		err = db.Collection("crackme").FindOneAndDelete(database.Ctx,
+			bson.M{"hexid": hexid, "author": username, "visible": false, "deleted": false, "created_at": bson.M{"$gte": since}}).Decode(&crackme)
+		if err == nil {
+			for _, name := range []string{"rating_difficulty", "rating_quality"} {
+				if _, cerr := db.Collection(name).DeleteMany(database.Ctx, bson.M{"crackmehexid": hexid}); cerr != nil {
+					log.Println("Failed to delete the ratings:", cerr)
+				}
+			}
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return crackme, standardizeError(err)
+}
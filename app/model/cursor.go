@@ -0,0 +1,142 @@
+package model
+
+import (
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// *****************************************************************************
+// Cursor pagination
+// *****************************************************************************
+
+// The functions of this file page through the visible documents from the
+// newest, by _id. A page starts after the document whose hexid is given, so
+// pages stay stable while documents are added, unlike skip based pages.
+
+// CursorLimit is the maximum number of documents of a page
+const CursorLimit = 100
+
+// CursorFilter restricts the documents of a page, empty fields are ignored
+type CursorFilter struct {
+	Author   string // Name of the author
+	Crackme  string // Hexid of the crackme of the solutions and comments
+	Lang     string // Language of the crackmes
+	Platform string // Platform of the crackmes
+}
+
+// cursorLimit bounds the size of a page
+func cursorLimit(limit int) int {
+	if limit < 1 || limit > CursorLimit {
+		return CursorLimit
+	}
+	return limit
+}
+
+// findAfter decodes into result up to limit+1 documents of the collection
+// matching filter, and kept by the stages if any, starting after the document
+// with the id after. The email and password of the users are never read.
+func findAfter(name string, filter bson.M, after string, limit int, result interface{}, stages ...bson.D) error {
+	var err error
+	var cursor *mongo.Cursor
+
+	if after != "" {
+		id, err := primitive.ObjectIDFromHex(after)
+		if err != nil {
+			return err
+		}
+		filter["_id"] = bson.M{"$lt": id}
+	}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection(name)
+		pipeline := mongo.Pipeline{{{"$match", filter}}, {{"$sort", bson.D{{"_id", -1}}}}}
+		pipeline = append(pipeline, stages...)
+		pipeline = append(pipeline,
+			bson.D{{"$limit", limit + 1}},
+			bson.D{{"$project", bson.M{"password": 0, "email": 0}}})
+		cursor, err = collection.Aggregate(database.Ctx, pipeline)
+		if err == nil {
+			err = cursor.All(database.Ctx, result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// CrackmesAfter returns a page of up to limit public crackmes, and whether
+// there are more
+func CrackmesAfter(f CursorFilter, after string, limit int) ([]Crackme, bool, error) {
+	filter := ScopeListed.filter(bson.M{})
+	if f.Author != "" {
+		filter["author"] = f.Author
+	}
+	if f.Lang != "" {
+		filter["lang"] = f.Lang
+	}
+	if f.Platform != "" {
+		filter["platform"] = f.Platform
+	}
+
+	result := []Crackme{}
+	limit = cursorLimit(limit)
+	err := findAfter("crackme", filter, after, limit, &result)
+	if len(result) > limit {
+		return result[:limit], true, err
+	}
+	return result, false, err
+}
+
+// SolutionsAfter returns a page of up to limit visible solutions of the public
+// crackmes, and whether there are more
+func SolutionsAfter(f CursorFilter, after string, limit int) ([]Solution, bool, error) {
+	filter := bson.M{"visible": true}
+	if f.Author != "" {
+		filter["author"] = f.Author
+	}
+	if f.Crackme != "" {
+		filter["crackmehexid"] = f.Crackme
+	}
+
+	result := []Solution{}
+	limit = cursorLimit(limit)
+	err := findAfter("solution", filter, after, limit, &result, ScopeListed.crackmeStages()...)
+	if len(result) > limit {
+		return result[:limit], true, err
+	}
+	return result, false, err
+}
+
+// CommentsAfter returns a page of up to limit visible comments of the public
+// crackmes, and whether there are more
+func CommentsAfter(f CursorFilter, after string, limit int) ([]Comment, bool, error) {
+	filter := bson.M{"visible": true}
+	if f.Author != "" {
+		filter["author"] = f.Author
+	}
+	if f.Crackme != "" {
+		filter["crackmehexid"] = f.Crackme
+	}
+
+	result := []Comment{}
+	limit = cursorLimit(limit)
+	err := findAfter("comment", filter, after, limit, &result, ScopeListed.crackmeStages()...)
+	if len(result) > limit {
+		return result[:limit], true, err
+	}
+	return result, false, err
+}
+
+// UsersAfter returns a page of up to limit visible users, without their email
+// and password, and whether there are more
+func UsersAfter(after string, limit int) ([]User, bool, error) {
+	result := []User{}
+	limit = cursorLimit(limit)
+	err := findAfter("user", bson.M{"visible": true}, after, limit, &result)
+	if len(result) > limit {
+		return result[:limit], true, err
+	}
+	return result, false, err
+}
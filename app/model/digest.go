@@ -0,0 +1,194 @@
+package model
+
+import (
+	"log"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"github.com/crackmesone/crackmes.one/app/shared/digest"
+	"github.com/crackmesone/crackmes.one/app/shared/mail"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Digest
+// *****************************************************************************
+
+// digestLimit is the maximum number of items of each section of a digest
+const digestLimit = 20
+
+// DigestPrefs are the settings of the weekly digest of a user
+type DigestPrefs struct {
+	Enabled  bool      `bson:"enabled"`
+	Langs    []string  `bson:"langs,omitempty"` // Languages of the new crackmes listed, all if empty
+	Archs    []string  `bson:"archs,omitempty"` // Architectures of the new crackmes listed, all if empty
+	LastSent time.Time `bson:"lastsent,omitempty"`
+}
+
+// DigestSetPrefs stores the digest settings of a user, keeping the time of
+// the last digest sent
+func DigestSetPrefs(username string, enabled bool, langs, archs []string) error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("user")
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"name": username}, bson.M{"$set": bson.M{
+			"digest.enabled": enabled,
+			"digest.langs":   langs,
+			"digest.archs":   archs,
+		}})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// DigestRun sends their digest to the subscribed users whose last one is
+// older than a week. Users with nothing new get no email.
+func DigestRun() error {
+	var err error
+	var cursor *mongo.Cursor
+	var users []User
+
+	now := time.Now()
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("user")
+		cursor, err = collection.Find(database.Ctx, bson.M{
+			"digest.enabled": true,
+			"visible":        true,
+			"$or": []bson.M{
+				{"digest.lastsent": bson.M{"$lt": now.Add(-digest.Period)}},
+				{"digest.lastsent": bson.M{"$exists": false}},
+			},
+		})
+		if err == nil {
+			err = cursor.All(database.Ctx, &users)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+	if err != nil {
+		return standardizeError(err)
+	}
+
+	for _, user := range users {
+		since := user.Digest.LastSent
+		if since.IsZero() {
+			since = now.Add(-digest.Period)
+		}
+
+		d, err := digestOf(user, since)
+		if err != nil {
+			return err
+		}
+		if !d.Empty() {
+			subject, text, err := digest.Render(d)
+			if err == nil {
+				err = mail.Send(mail.Message{To: user.Email, Subject: subject, Body: text})
+			}
+			if err != nil {
+				// Tried again at the next run
+				log.Println("Digest of", user.Name, "not sent:", err)
+				continue
+			}
+		}
+
+		if err = digestSetSent(user.Name, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// digestSetSent stores the time of the last digest of a user
+func digestSetSent(username string, t time.Time) error {
+	collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("user")
+	_, err := collection.UpdateOne(database.Ctx, bson.M{"name": username}, bson.M{"$set": bson.M{"digest.lastsent": t}})
+	return standardizeError(err)
+}
+
+// digestOf gathers what happened for the user since a time
+func digestOf(user User, since time.Time) (digest.Digest, error) {
+	d := digest.Digest{User: user.Name, Since: since}
+	db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+	opts := options.Find().SetSort(bson.D{{"created_at", -1}}).SetLimit(digestLimit)
+
+	// New crackmes of the others in the preferred languages and architectures
+	filter := ScopeListed.filter(bson.M{"created_at": bson.M{"$gt": since}, "author": bson.M{"$ne": user.Name}})
+	if len(user.Digest.Langs) > 0 {
+		filter["lang"] = bson.M{"$in": user.Digest.Langs}
+	}
+	if len(user.Digest.Archs) > 0 {
+		filter["arch"] = bson.M{"$in": user.Digest.Archs}
+	}
+	var crackmes []Crackme
+	cursor, err := db.Collection("crackme").Find(database.Ctx, filter, opts)
+	if err == nil {
+		err = cursor.All(database.Ctx, &crackmes)
+	}
+	if err != nil {
+		return d, standardizeError(err)
+	}
+	for _, c := range crackmes {
+		d.Crackmes = append(d.Crackmes, digest.Item{Title: c.Name, Path: "/crackme/" + c.HexId, Detail: "by " + c.Author + ", " + c.Lang + ", " + c.Arch})
+	}
+
+	// New comments of the others on the crackmes the user commented
+	commented, err := db.Collection("comment").Distinct(database.Ctx, "crackmehexid", bson.M{"author": user.Name, "visible": true})
+	if err != nil {
+		return d, standardizeError(err)
+	}
+	if len(commented) > 0 {
+		var comments []Comment
+		cursor, err = db.Collection("comment").Find(database.Ctx, bson.M{
+			"crackmehexid": bson.M{"$in": commented},
+			"author":       bson.M{"$ne": user.Name},
+			"visible":      true,
+			"created_at":   bson.M{"$gt": since},
+		}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &comments)
+		}
+		if err != nil {
+			return d, standardizeError(err)
+		}
+		for _, c := range comments {
+			d.Replies = append(d.Replies, digest.Item{Title: c.Author + " on " + c.CrackmeName, Path: "/crackme/" + c.CrackMeHexId, Detail: digestExcerpt(c.Content)})
+		}
+	}
+
+	// New solutions of the user's crackmes
+	authored, err := db.Collection("crackme").Distinct(database.Ctx, "hexid", bson.M{"author": user.Name, "visible": true})
+	if err != nil {
+		return d, standardizeError(err)
+	}
+	if len(authored) > 0 {
+		var solutions []Solution
+		cursor, err = db.Collection("solution").Find(database.Ctx, bson.M{
+			"crackmehexid": bson.M{"$in": authored},
+			"visible":      true,
+			"created_at":   bson.M{"$gt": since},
+		}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &solutions)
+		}
+		if err != nil {
+			return d, standardizeError(err)
+		}
+		for _, s := range solutions {
+			d.Solutions = append(d.Solutions, digest.Item{Title: s.CrackmeName, Path: "/crackme/" + s.CrackmeHexId, Detail: "by " + s.Author})
+		}
+	}
+
+	return d, nil
+}
+
+// digestExcerpt shortens a comment for the digest
+func digestExcerpt(s string) string {
+	r := []rune(s)
+	if len(r) > 80 {
+		return string(r[:80]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// *****************************************************************************
+// Download
+// *****************************************************************************
+
+// incrementDownloads counts a download of the file of a document
+func incrementDownloads(collectionName, hexid string) error {
+	var err error
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection(collectionName)
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"hexid": hexid}, bson.M{"$inc": bson.M{"downloads": 1}})
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// CrackmeIncrementDownloads counts a download of a crackme, in total and
+// for the day, see TrendingRun
+func CrackmeIncrementDownloads(hexid string) error {
+	if err := incrementDownloads("crackme", hexid); err != nil {
+		return err
+	}
+	return crackmeDailyIncrement(hexid, "downloads")
+}
+
+// SolutionIncrementDownloads counts a download of a solution
+func SolutionIncrementDownloads(hexid string) error {
+	return incrementDownloads("solution", hexid)
+}
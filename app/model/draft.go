@@ -0,0 +1,93 @@
+package model
+
+import (
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Drafts
+// *****************************************************************************
+
+// DraftTTL is how long a draft is kept after its last change
+const DraftTTL = 30 * 24 * time.Hour
+
+// Draft table holds the fields of an upload form of a user while it is
+// written, so a refused captcha or an expired session doesn't lose them. The
+// files aren't kept. MongoDB removes the drafts after DraftTTL.
+type Draft struct {
+	ObjectId  primitive.ObjectID `bson:"_id,omitempty"`
+	User      string             `bson:"user"`
+	Kind      string             `bson:"kind"`      // crackme or solution
+	CrackmeId string             `bson:"crackmeid"` // Hexid of the crackme of a solution, empty for a crackme
+	Fields    map[string]string  `bson:"fields"`
+	UpdatedAt time.Time          `bson:"updated_at"`
+}
+
+// DraftEnsureIndexes creates the unique index of the drafts by user and form,
+// and the index removing them after DraftTTL
+func DraftEnsureIndexes() error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("draft")
+		_, err = collection.Indexes().CreateMany(database.Ctx, []mongo.IndexModel{
+			{
+				Keys:    bson.D{{"user", 1}, {"kind", 1}, {"crackmeid", 1}},
+				Options: options.Index().SetUnique(true).SetName("one_draft"),
+			},
+			{
+				Keys:    bson.D{{"updated_at", 1}},
+				Options: options.Index().SetExpireAfterSeconds(int32(DraftTTL.Seconds())).SetName("expiry"),
+			},
+		})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// DraftSave replaces the draft of a form of username
+func DraftSave(username, kind, crackmehexid string, fields map[string]string) error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("draft")
+		_, err = collection.UpdateOne(database.Ctx,
+			bson.M{"user": username, "kind": kind, "crackmeid": crackmehexid},
+			bson.M{"$set": bson.M{"fields": fields, "updated_at": time.Now()}},
+			options.Update().SetUpsert(true))
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// DraftByForm returns the draft of a form of username
+func DraftByForm(username, kind, crackmehexid string) (Draft, error) {
+	var err error
+	var draft Draft
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("draft")
+		err = collection.FindOne(database.Ctx, bson.M{"user": username, "kind": kind, "crackmeid": crackmehexid}).Decode(&draft)
+	} else {
+		err = ErrUnavailable
+	}
+	return draft, standardizeError(err)
+}
+
+// DraftDelete deletes the draft of a form of username, once it is uploaded
+func DraftDelete(username, kind, crackmehexid string) error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("draft")
+		_, err = collection.DeleteOne(database.Ctx, bson.M{"user": username, "kind": kind, "crackmeid": crackmehexid})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
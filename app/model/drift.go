@@ -0,0 +1,228 @@
+package model
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"github.com/crackmesone/crackmes.one/app/shared/drift"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Drift
+// *****************************************************************************
+
+// DriftReport is a stored drift check report
+type DriftReport struct {
+	ObjectId     primitive.ObjectID `bson:"_id,omitempty"`
+	drift.Report `bson:",inline"`
+}
+
+// driftCount returns the number of documents of a collection matching a
+// filter, grouped by a field. Arrays of the field are counted for each of
+// their elements.
+func driftCount(db *mongo.Database, name string, match bson.M, field interface{}) (map[string]int, error) {
+	pipeline := mongo.Pipeline{
+		{{"$match", match}},
+		{{"$project", bson.M{"key": field}}},
+		{{"$unwind", "$key"}},
+		{{"$group", bson.M{"_id": "$key", "n": bson.M{"$sum": 1}}}},
+	}
+	cursor, err := db.Collection(name).Aggregate(database.Ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		Key string `bson:"_id"`
+		N   int    `bson:"n"`
+	}
+	if err := cursor.All(database.Ctx, &groups); err != nil {
+		return nil, err
+	}
+	result := make(map[string]int, len(groups))
+	for _, g := range groups {
+		result[g.Key] = g.N
+	}
+	return result, nil
+}
+
+// driftCheck compares the denormalized fields with their sources
+func driftCheck(db *mongo.Database) (drift.Report, error) {
+	var report drift.Report
+
+	// Crackmes: their counters, and their names copied on the solutions and
+	// the comments
+	var crackmes []Crackme
+	opts := options.Find().SetProjection(bson.M{"hexid": 1, "name": 1, "nbsolutions": 1, "nbcomments": 1})
+	cursor, err := db.Collection("crackme").Find(database.Ctx, bson.M{"deleted": bson.M{"$ne": true}}, opts)
+	if err == nil {
+		err = cursor.All(database.Ctx, &crackmes)
+	}
+	if err != nil {
+		return report, err
+	}
+	nbsolutions, nbcomments, names := map[string]int{}, map[string]int{}, map[string]string{}
+	for _, c := range crackmes {
+		nbsolutions[c.HexId], nbcomments[c.HexId], names[c.HexId] = c.NbSolutions, c.NbComments, c.Name
+	}
+	solutions, err := driftCount(db, "solution", bson.M{"visible": true}, "$crackmehexid")
+	if err != nil {
+		return report, err
+	}
+	comments, err := driftCount(db, "comment", bson.M{"visible": true}, "$crackmehexid")
+	if err != nil {
+		return report, err
+	}
+	report.Counts("crackme", "nbsolutions", nbsolutions, solutions)
+	report.Counts("crackme", "nbcomments", nbcomments, comments)
+
+	for _, kind := range []string{"solution", "comment"} {
+		var docs []struct {
+			ObjectId     primitive.ObjectID `bson:"_id"`
+			HexId        string             `bson:"hexid"`
+			CrackmeHexId string             `bson:"crackmehexid"`
+			CrackmeName  string             `bson:"crackmename"`
+		}
+		opts := options.Find().SetProjection(bson.M{"hexid": 1, "crackmehexid": 1, "crackmename": 1})
+		cursor, err := db.Collection(kind).Find(database.Ctx, bson.M{"deleted": bson.M{"$ne": true}}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &docs)
+		}
+		if err != nil {
+			return report, err
+		}
+		stored, actual := map[string]string{}, map[string]string{}
+		for _, d := range docs {
+			key := d.HexId
+			if key == "" {
+				key = d.ObjectId.Hex()
+			}
+			stored[key] = d.CrackmeName
+			if name, ok := names[d.CrackmeHexId]; ok {
+				actual[key] = name
+			}
+		}
+		report.Values(kind, "crackmename", stored, actual)
+	}
+
+	// Users: their counters, counted like UserRecountCounters does
+	var users []User
+	opts = options.Find().SetProjection(bson.M{"name": 1, "nbcrackmes": 1, "nbsolutions": 1, "nbcomments": 1})
+	cursor, err = db.Collection("user").Find(database.Ctx, bson.M{"deleted": bson.M{"$ne": true}}, opts)
+	if err == nil {
+		err = cursor.All(database.Ctx, &users)
+	}
+	if err != nil {
+		return report, err
+	}
+	stored := map[string]map[string]int{userCounterCrackmes: {}, userCounterSolutions: {}, userCounterComments: {}}
+	for _, u := range users {
+		stored[userCounterCrackmes][u.Name] = u.NbCrackmes
+		stored[userCounterSolutions][u.Name] = u.NbSolutions
+		stored[userCounterComments][u.Name] = u.NbComments
+	}
+	actual := map[string]map[string]int{}
+	if actual[userCounterCrackmes], err = driftCount(db, "crackme", bson.M{"visible": true}, "$author"); err != nil {
+		return report, err
+	}
+	authors := bson.M{"$concatArrays": bson.A{bson.A{"$author"}, bson.M{"$ifNull": bson.A{"$coauthors", bson.A{}}}}}
+	if actual[userCounterSolutions], err = driftCount(db, "solution", bson.M{"visible": true}, authors); err != nil {
+		return report, err
+	}
+	if actual[userCounterComments], err = driftCount(db, "comment", bson.M{"visible": true}, "$author"); err != nil {
+		return report, err
+	}
+	for _, counter := range []string{userCounterCrackmes, userCounterSolutions, userCounterComments} {
+		report.Counts("user", counter, stored[counter], actual[counter])
+	}
+
+	return report, nil
+}
+
+// DriftCheck compares the counters of the crackmes and the users and the
+// crackme names copied on the solutions and the comments with their sources,
+// and saves the report
+func DriftCheck() (DriftReport, error) {
+	var err error
+	result := DriftReport{}
+
+	if database.CheckConnection() {
+		db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+		start := time.Now()
+		result.Report, err = driftCheck(db)
+		if err == nil {
+			result.CreatedAt = start
+			result.Duration = time.Since(start).Seconds()
+			var inserted *mongo.InsertOneResult
+			inserted, err = db.Collection("drift").InsertOne(database.Ctx, result)
+			if err == nil {
+				result.ObjectId = inserted.InsertedID.(primitive.ObjectID)
+			}
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// DriftLatest returns the latest drift check report
+func DriftLatest() (DriftReport, error) {
+	var err error
+
+	result := DriftReport{}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("drift")
+		opts := options.FindOne().SetSort(bson.D{{"created_at", -1}})
+		err = collection.FindOne(database.Ctx, bson.M{}, opts).Decode(&result)
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// DriftRun runs a drift check, for the scheduler
+func DriftRun() error {
+	_, err := DriftCheck()
+	return err
+}
+
+// DriftFix sets a drifted field to the value of its source. The counters of
+// the users are all recounted at once.
+func DriftFix(issue drift.Issue) error {
+	if issue.Kind == "user" {
+		return UserRecountCounters(issue.Key)
+	}
+
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection(issue.Kind)
+		filter := bson.M{"hexid": issue.Key}
+		var value interface{} = issue.Actual
+		switch {
+		case issue.Kind == "crackme":
+			value, err = strconv.Atoi(issue.Actual)
+		case issue.Kind == "comment":
+			var id primitive.ObjectID
+			id, err = primitive.ObjectIDFromHex(issue.Key)
+			filter = bson.M{"_id": id}
+		}
+		if err == nil {
+			update := bson.M{issue.Field: value}
+			if issue.Kind == "crackme" {
+				update["updated_at"] = time.Now()
+			}
+			_, err = collection.UpdateOne(database.Ctx, filter, bson.M{"$set": update})
+		}
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// *****************************************************************************
+// Duplicate
+// *****************************************************************************
+
+// ErrSameCrackme is returned when a crackme is compared with itself
+var ErrSameCrackme = errors.New("A crackme can't be a duplicate of itself.")
+
+// CrackmeMerge deletes a duplicate crackme, moving its solutions and comments
+// to the kept one, and returns both. The ratings, attempts and hints of the
+// duplicate are deleted, its solvers can rate the kept one. Everything is
+// changed in a single transaction.
+func CrackmeMerge(duplicate, into string) (Crackme, Crackme, error) {
+	var dup, kept Crackme
+	if duplicate == into {
+		return dup, kept, ErrSameCrackme
+	}
+	if !database.CheckConnection() {
+		return dup, kept, ErrUnavailable
+	}
+	db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+
+	err := withTransaction(func(ctx context.Context) error {
+		err := db.Collection("crackme").FindOne(ctx, bson.M{"hexid": into}).Decode(&kept)
+		if err != nil {
+			return err
+		}
+		err = db.Collection("crackme").FindOneAndDelete(ctx, bson.M{"hexid": duplicate}).Decode(&dup)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.Collection("solution").UpdateMany(ctx,
+			bson.M{"crackmeid": dup.ObjectId},
+			bson.M{"$set": bson.M{"crackmeid": kept.ObjectId, "crackmehexid": kept.HexId, "crackmename": kept.Name}})
+		if err != nil {
+			return err
+		}
+
+		// The comments keep their author flag only if they come from the
+		// author of the kept crackme
+		comments := db.Collection("comment")
+		_, err = comments.UpdateMany(ctx,
+			bson.M{"crackmehexid": dup.HexId},
+			bson.M{"$set": bson.M{"crackmehexid": kept.HexId, "crackmename": kept.Name, "byauthor": false}})
+		if err != nil {
+			return err
+		}
+		if _, err = comments.UpdateMany(ctx, bson.M{"crackmehexid": kept.HexId, "author": kept.Author}, bson.M{"$set": bson.M{"byauthor": true}}); err != nil {
+			return err
+		}
+
+		for _, name := range []string{"rating_difficulty", "rating_quality", "attempt", "hint"} {
+			if _, err = db.Collection(name).DeleteMany(ctx, bson.M{"crackmehexid": dup.HexId}); err != nil {
+				return err
+			}
+		}
+
+		_, err = db.Collection("crackme").UpdateOne(ctx,
+			bson.M{"hexid": kept.HexId},
+			bson.M{"$inc": bson.M{"nbsolutions": dup.NbSolutions, "nbcomments": dup.NbComments}, "$set": bson.M{"updated_at": time.Now()}})
+		if err != nil {
+			return err
+		}
+
+		if dup.Visible {
+			_, err = db.Collection("user").UpdateOne(ctx, bson.M{"name": dup.Author}, bson.M{"$inc": bson.M{userCounterCrackmes: -1}})
+		}
+		return err
+	})
+	if err == nil {
+		if cerr := crackmeUpdateWriteupLangs(kept.HexId); cerr != nil {
+			log.Println("Failed to update the writeup languages:", cerr)
+		}
+	}
+
+	return dup, kept, standardizeError(err)
+}
+
+// CrackmeRejectDuplicate deletes a crackme waiting for approval found to be a
+// duplicate, with the ratings given on upload, and returns it
+func CrackmeRejectDuplicate(hexid string) (Crackme, error) {
+	var err error
+	var crackme Crackme
+
+	if database.CheckConnection() {
+		db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+		err = db.Collection("crackme").FindOneAndDelete(database.Ctx, bson.M{"hexid": hexid, "visible": false}).Decode(&crackme)
+		if err == nil {
+			for _, name := range []string{"rating_difficulty", "rating_quality"} {
+				if _, cerr := db.Collection(name).DeleteMany(database.Ctx, bson.M{"crackmehexid": hexid}); cerr != nil {
+					log.Println("Failed to delete the ratings:", cerr)
+				}
+			}
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return crackme, standardizeError(err)
+}
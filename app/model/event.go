@@ -0,0 +1,135 @@
+package model
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Event
+// *****************************************************************************
+
+// Types of the events, validate.py records EventSolutionApproved too
+const (
+	EventCrackmeUploaded  = "crackme_uploaded"
+	EventSolutionApproved = "solution_approved"
+	EventCommentPosted    = "comment_posted"
+	EventUserRegistered   = "user_registered"
+	EventQualityRated     = "quality_rated"
+)
+
+// EventsMax is the maximum number of events returned by EventsSince
+const EventsMax = 10000
+
+// Event table is the append-only log of what happens on the site, for the
+// statistics and the features summing up the activity. Events are never
+// updated nor deleted.
+type Event struct {
+	ObjectId     primitive.ObjectID `bson:"_id,omitempty"`
+	Type         string             `bson:"type"`
+	User         string             `bson:"user"`                   // User who did it
+	HexId        string             `bson:"hexid,omitempty"`        // Crackme, solution or comment it is about
+	CrackmeHexId string             `bson:"crackmehexid,omitempty"` // Crackme of the solution or the comment
+	CreatedAt    time.Time          `bson:"created_at"`
+}
+
+// EventHandler is called with the emitted events
+type EventHandler func(Event)
+
+var (
+	eventMutex    sync.RWMutex
+	eventHandlers []EventHandler
+)
+
+// EventSubscribe registers a handler called with every event emitted after
+// it is recorded. Handlers run in the request emitting it and must be quick.
+func EventSubscribe(h EventHandler) {
+	eventMutex.Lock()
+	eventHandlers = append(eventHandlers, h)
+	eventMutex.Unlock()
+}
+
+// EventEmit records an event and passes it to the subscribers. Failing to
+// record it is only logged, an event never fails the action it describes.
+func EventEmit(e Event) {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+
+	if database.CheckConnection() {
+		if err := eventInsert(database.Ctx, e); err != nil {
+			log.Println("Failed to record the event", e.Type+":", err)
+		}
+	} else {
+		log.Println("Failed to record the event", e.Type+":", ErrUnavailable)
+	}
+
+	eventDispatch(e)
+}
+
+// eventInsert records an event, within a transaction when ctx is one. The
+// caller passes it to the subscribers with eventDispatch once committed.
+func eventInsert(ctx context.Context, e Event) error {
+	collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("events")
+	_, err := collection.InsertOne(ctx, e)
+	return err
+}
+
+// eventDispatch passes a recorded event to the subscribers
+func eventDispatch(e Event) {
+	eventMutex.RLock()
+	handlers := eventHandlers
+	eventMutex.RUnlock()
+	for _, h := range handlers {
+		h(e)
+	}
+}
+
+// EventEnsureIndexes creates the index of the events by type and date
+func EventEnsureIndexes() error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("events")
+		_, err = collection.Indexes().CreateOne(database.Ctx, mongo.IndexModel{
+			Keys:    bson.D{{"type", 1}, {"created_at", 1}},
+			Options: options.Index().SetName("type_date"),
+		})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// EventsSince returns, oldest first, at most EventsMax events of the given
+// types, of any type if none, recorded since a date
+func EventsSince(since time.Time, types ...string) ([]Event, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []Event{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("events")
+		filter := bson.M{"created_at": bson.M{"$gte": since}}
+		if len(types) > 0 {
+			filter["type"] = bson.M{"$in": types}
+		}
+		opts := options.Find().SetSort(bson.D{{"created_at", 1}}).SetLimit(EventsMax)
+		cursor, err = collection.Find(database.Ctx, filter, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
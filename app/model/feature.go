@@ -0,0 +1,71 @@
+package model
+
+import (
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"github.com/crackmesone/crackmes.one/app/shared/feature"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Feature
+// *****************************************************************************
+
+// FeatureOverride is the state of a feature flag set by an admin
+type FeatureOverride struct {
+	Name      string    `bson:"name"`
+	Enabled   bool      `bson:"enabled"`
+	Percent   int       `bson:"percent"`
+	Users     []string  `bson:"users,omitempty"`
+	UpdatedAt time.Time `bson:"updated_at"`
+	UpdatedBy string    `bson:"updated_by"`
+}
+
+// FeatureLoad applies the states set by the admins to the feature flags
+func FeatureLoad() error {
+	var err error
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("feature")
+		var cursor *mongo.Cursor
+		var result []FeatureOverride
+		cursor, err = collection.Find(database.Ctx, bson.M{})
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+		for _, o := range result {
+			feature.Override(o.Name, feature.State{Enabled: o.Enabled, Percent: o.Percent, Users: o.Users})
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// FeatureSave stores the state of a feature flag set by an admin and applies it
+func FeatureSave(name string, state feature.State, username string) error {
+	var err error
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("feature")
+		opts := options.Update().SetUpsert(true)
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"name": name}, bson.M{"$set": bson.M{
+			"enabled":    state.Enabled,
+			"percent":    state.Percent,
+			"users":      state.Users,
+			"updated_at": time.Now(),
+			"updated_by": username,
+		}}, opts)
+		if err == nil {
+			feature.Override(name, state)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Follow
+// *****************************************************************************
+
+// Follow table contains one document per user following another user
+type Follow struct {
+	ObjectId  primitive.ObjectID `bson:"_id,omitempty"`
+	Follower  string             `bson:"follower"`
+	Followed  string             `bson:"followed"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// IsFollowing returns true if follower follows followed
+func IsFollowing(follower, followed string) (bool, error) {
+	var err error
+	var nb int64
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("follow")
+		nb, err = collection.CountDocuments(database.Ctx, bson.M{"follower": follower, "followed": followed})
+	} else {
+		err = ErrUnavailable
+	}
+
+	return nb != 0, standardizeError(err)
+}
+
+// FollowedByUser returns the names of the users followed by username
+func FollowedByUser(username string) ([]string, error) {
+	var err error
+	var values []interface{}
+	result := []string{}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("follow")
+		values, err = collection.Distinct(database.Ctx, "followed", bson.M{"follower": username})
+		for _, v := range values {
+			if name, ok := v.(string); ok {
+				result = append(result, name)
+			}
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// FollowCreate makes follower follow followed, does nothing if it already does
+func FollowCreate(follower, followed string) error {
+	var err error
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("follow")
+		filter := bson.M{"follower": follower, "followed": followed}
+		update := bson.M{"$setOnInsert": bson.M{"created_at": time.Now()}}
+		_, err = collection.UpdateOne(database.Ctx, filter, update, options.Update().SetUpsert(true))
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// FollowDelete makes follower stop following followed
+func FollowDelete(follower, followed string) error {
+	var err error
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("follow")
+		_, err = collection.DeleteOne(database.Ctx, bson.M{"follower": follower, "followed": followed})
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// FollowedSolvers returns the names of the users followed by username who
+// have an approved solution for the crackme
+func FollowedSolvers(username string, crackme primitive.ObjectID) ([]string, error) {
+	followed, err := FollowedByUser(username)
+	if err != nil || len(followed) == 0 {
+		return []string{}, err
+	}
+
+	var values []interface{}
+	result := []string{}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
+		values, err = collection.Distinct(database.Ctx, "author", bson.M{
+			"crackmeid": crackme,
+			"visible":   true,
+			"author":    bson.M{"$in": followed},
+		})
+		for _, v := range values {
+			if name, ok := v.(string); ok {
+				result = append(result, name)
+			}
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
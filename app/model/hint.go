@@ -0,0 +1,190 @@
+package model
+
+import (
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Hint
+// *****************************************************************************
+
+// Hint is a hint given by the author of a crackme. The hints are revealed one
+// at a time, in the order of their position.
+type Hint struct {
+	ObjectId     primitive.ObjectID `bson:"_id,omitempty"`
+	HexId        string             `bson:"hexid,omitempty"`
+	CrackmeHexId string             `bson:"crackmehexid,omitempty"`
+	Position     int                `bson:"position"`
+	Text         string             `bson:"text"`
+	CreatedAt    time.Time          `bson:"created_at"`
+}
+
+// HintReveal records that a user revealed a hint
+type HintReveal struct {
+	ObjectId     primitive.ObjectID `bson:"_id,omitempty"`
+	HintHexId    string             `bson:"hinthexid"`
+	CrackmeHexId string             `bson:"crackmehexid"`
+	User         string             `bson:"user"`
+	RevealedAt   time.Time          `bson:"revealed_at"`
+}
+
+// MaxHints is the maximum number of hints of a crackme
+const MaxHints = 10
+
+// HintsByCrackme returns the hints of a crackme, in order
+func HintsByCrackme(crackmehexid string) ([]Hint, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []Hint{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("hint")
+		opts := options.Find().SetSort(bson.D{{"position", 1}})
+		cursor, err = collection.Find(database.Ctx, bson.M{"crackmehexid": crackmehexid}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// HintCreate adds a hint after the other hints of a crackme
+func HintCreate(crackmehexid, text string) error {
+	hints, err := HintsByCrackme(crackmehexid)
+	if err != nil {
+		return err
+	}
+
+	position := 1
+	if len(hints) > 0 {
+		position = hints[len(hints)-1].Position + 1
+	}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("hint")
+		objId := primitive.NewObjectID()
+		hint := &Hint{
+			ObjectId:     objId,
+			HexId:        objId.Hex(),
+			CrackmeHexId: crackmehexid,
+			Position:     position,
+			Text:         text,
+			CreatedAt:    time.Now(),
+		}
+		_, err = collection.InsertOne(database.Ctx, hint)
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// HintDelete removes a hint of a crackme and its reveals, the next hints move
+// up one position
+func HintDelete(crackmehexid, hexid string) error {
+	var err error
+
+	if database.CheckConnection() {
+		db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+		var hint Hint
+		err = db.Collection("hint").FindOneAndDelete(database.Ctx, bson.M{"crackmehexid": crackmehexid, "hexid": hexid}).Decode(&hint)
+		if err == nil {
+			_, err = db.Collection("hint").UpdateMany(database.Ctx,
+				bson.M{"crackmehexid": crackmehexid, "position": bson.M{"$gt": hint.Position}},
+				bson.M{"$inc": bson.M{"position": -1}})
+		}
+		if err == nil {
+			_, err = db.Collection("hint_reveal").DeleteMany(database.Ctx, bson.M{"hinthexid": hexid})
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// HintsRevealed returns the hexids of the hints of a crackme revealed by a user
+func HintsRevealed(username, crackmehexid string) (map[string]bool, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := make(map[string]bool)
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("hint_reveal")
+		var reveals []HintReveal
+		cursor, err = collection.Find(database.Ctx, bson.M{"user": username, "crackmehexid": crackmehexid})
+		if err == nil {
+			err = cursor.All(database.Ctx, &reveals)
+		}
+		for _, reveal := range reveals {
+			result[reveal.HintHexId] = true
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// HintRevealNext reveals to a user the first hint of a crackme they haven't
+// revealed yet. It returns ErrNoResult when all the hints are revealed.
+func HintRevealNext(username, crackmehexid string) (Hint, error) {
+	var next Hint
+
+	hints, err := HintsByCrackme(crackmehexid)
+	if err != nil {
+		return next, err
+	}
+	revealed, err := HintsRevealed(username, crackmehexid)
+	if err != nil {
+		return next, err
+	}
+
+	found := false
+	for _, hint := range hints {
+		if !revealed[hint.HexId] {
+			next, found = hint, true
+			break
+		}
+	}
+	if !found {
+		return next, ErrNoResult
+	}
+
+	if database.CheckConnection() {
+		// Upsert so that revealing twice in a row records a single reveal
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("hint_reveal")
+		_, err = collection.UpdateOne(database.Ctx,
+			bson.M{"user": username, "hinthexid": next.HexId},
+			bson.M{"$setOnInsert": bson.M{"crackmehexid": crackmehexid, "revealed_at": time.Now()}},
+			options.Update().SetUpsert(true))
+	} else {
+		err = ErrUnavailable
+	}
+
+	return next, standardizeError(err)
+}
+
+// HintsRevealedCount returns the number of hints of a crackme revealed by a user
+func HintsRevealedCount(username, crackmehexid string) (int, error) {
+	var err error
+	var result int64
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("hint_reveal")
+		result, err = collection.CountDocuments(database.Ctx, bson.M{"user": username, "crackmehexid": crackmehexid})
+	} else {
+		err = ErrUnavailable
+	}
+
+	return int(result), standardizeError(err)
+}
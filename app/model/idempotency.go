@@ -0,0 +1,127 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Idempotency
+// *****************************************************************************
+
+const (
+	// IdempotencyTTL is how long the result of a request is kept for its
+	// retries
+	IdempotencyTTL = 24 * time.Hour
+
+	// idempotencyStale is how long a request without result may still be
+	// running, a retry after that runs it again
+	idempotencyStale = 5 * time.Minute
+)
+
+// ErrRequestRunning is returned when retrying a request still running
+var ErrRequestRunning = errors.New("This request is still being processed.")
+
+// Idempotency table remembers the result of the uploads sent with an
+// idempotency key, so their retries after a network failure don't upload
+// twice. MongoDB removes them after IdempotencyTTL.
+type Idempotency struct {
+	ObjectId  primitive.ObjectID `bson:"_id,omitempty"`
+	User      string             `bson:"user"`
+	Key       string             `bson:"key"`
+	Kind      string             `bson:"kind"`            // crackme or solution
+	HexId     string             `bson:"hexid,omitempty"` // Result of the request, empty while it runs
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// IdempotencyEnsureIndexes creates the unique index of the keys by user, and
+// the index removing them after IdempotencyTTL
+func IdempotencyEnsureIndexes() error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("idempotency")
+		_, err = collection.Indexes().CreateMany(database.Ctx, []mongo.IndexModel{
+			{
+				Keys:    bson.D{{"user", 1}, {"key", 1}},
+				Options: options.Index().SetUnique(true).SetName("one_key"),
+			},
+			{
+				Keys:    bson.D{{"created_at", 1}},
+				Options: options.Index().SetExpireAfterSeconds(int32(IdempotencyTTL.Seconds())).SetName("expiry"),
+			},
+		})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// IdempotencyReserve reserves the key of a request of username. It returns
+// true when the request can run, else the earlier request with that key: done
+// if it has a HexId, else ErrRequestRunning is returned.
+func IdempotencyReserve(username, kind, key string) (Idempotency, bool, error) {
+	var err error
+	var earlier Idempotency
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("idempotency")
+		now := time.Now()
+		_, err = collection.InsertOne(database.Ctx, Idempotency{User: username, Key: key, Kind: kind, CreatedAt: now})
+		if err == nil {
+			return earlier, true, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return earlier, false, standardizeError(err)
+		}
+
+		// A request given up without releasing its key is run again
+		var res *mongo.UpdateResult
+		res, err = collection.UpdateOne(database.Ctx,
+			bson.M{"user": username, "key": key, "hexid": bson.M{"$exists": false}, "created_at": bson.M{"$lt": now.Add(-idempotencyStale)}},
+			bson.M{"$set": bson.M{"created_at": now}})
+		if err == nil && res.ModifiedCount == 1 {
+			return earlier, true, nil
+		}
+		if err == nil {
+			err = collection.FindOne(database.Ctx, bson.M{"user": username, "key": key}).Decode(&earlier)
+		}
+		if err == nil && (earlier.HexId == "" || earlier.Kind != kind) {
+			err = ErrRequestRunning
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return earlier, false, standardizeError(err)
+}
+
+// IdempotencyComplete records the result of the request with the key
+func IdempotencyComplete(username, key, hexid string) error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("idempotency")
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"user": username, "key": key}, bson.M{"$set": bson.M{"hexid": hexid}})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// IdempotencyRelease forgets the key of a failed request, so it can be retried
+func IdempotencyRelease(username, key string) error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("idempotency")
+		_, err = collection.DeleteOne(database.Ctx, bson.M{"user": username, "key": key, "hexid": bson.M{"$exists": false}})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
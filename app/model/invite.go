@@ -0,0 +1,145 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Invite
+// *****************************************************************************
+
+// ErrInviteInvalid is returned for the invite codes which don't exist or were
+// already used
+var ErrInviteInvalid = errors.New("This invite code is not valid or was already used.")
+
+// Invite is a code letting one person register while the registration needs
+// an invitation
+type Invite struct {
+	Code      string    `bson:"code"` // Unique
+	CreatedBy string    `bson:"createdby"`
+	CreatedAt time.Time `bson:"created_at"`
+	UsedBy    string    `bson:"usedby,omitempty"`
+	UsedAt    time.Time `bson:"used_at,omitempty"`
+}
+
+// InviteEnsureIndexes creates the unique index of the invite codes
+func InviteEnsureIndexes() error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("invite")
+		_, err = collection.Indexes().CreateOne(database.Ctx, mongo.IndexModel{
+			Keys:    bson.D{{"code", 1}},
+			Options: options.Index().SetUnique(true).SetName("one_code"),
+		})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// InviteCreate creates an invite code of a user
+func InviteCreate(username string) (Invite, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return Invite{}, err
+	}
+	invite := Invite{Code: hex.EncodeToString(b), CreatedBy: username, CreatedAt: time.Now()}
+
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("invite")
+		_, err = collection.InsertOne(database.Ctx, invite)
+	} else {
+		err = ErrUnavailable
+	}
+
+	return invite, standardizeError(err)
+}
+
+// InvitesByCreator returns the invite codes of a user, or of everybody if
+// empty, newest first
+func InvitesByCreator(username string, limit int) ([]Invite, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []Invite{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("invite")
+		filter := bson.M{}
+		if username != "" {
+			filter["createdby"] = username
+		}
+		opts := options.Find().SetSort(bson.D{{"created_at", -1}}).SetLimit(int64(limit))
+		cursor, err = collection.Find(database.Ctx, filter, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// CountInvitesSince returns the number of invite codes a user created since a
+// date
+func CountInvitesSince(username string, since time.Time) (int, error) {
+	var err error
+	var nb int64
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("invite")
+		nb, err = collection.CountDocuments(database.Ctx, bson.M{"createdby": username, "created_at": bson.M{"$gte": since}})
+	} else {
+		err = ErrUnavailable
+	}
+
+	return int(nb), standardizeError(err)
+}
+
+// InviteRedeem marks an unused invite code as used by a new user
+func InviteRedeem(code, username string) error {
+	var err error
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("invite")
+		var result *mongo.UpdateResult
+		result, err = collection.UpdateOne(database.Ctx,
+			bson.M{"code": strings.ToLower(strings.TrimSpace(code)), "usedby": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"usedby": username, "used_at": time.Now()}})
+		if err == nil && result.MatchedCount == 0 {
+			err = ErrInviteInvalid
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// InviteRelease makes an invite code redeemed by a registration which failed
+// usable again
+func InviteRelease(code string) error {
+	var err error
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("invite")
+		_, err = collection.UpdateOne(database.Ctx,
+			bson.M{"code": strings.ToLower(strings.TrimSpace(code))},
+			bson.M{"$unset": bson.M{"usedby": "", "used_at": ""}})
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
@@ -0,0 +1,32 @@
+package model
+
+// *****************************************************************************
+// License
+// *****************************************************************************
+
+// License is a license crackmes and writeups can be published under
+type License struct {
+	Code string
+	Name string
+	URL  string
+}
+
+// Licenses are the licenses of the upload forms, the first one is the default
+var Licenses = []License{
+	{"educational", "Educational use only", "/upload/crackmerules#license"},
+	{"CC-BY-4.0", "Creative Commons Attribution 4.0", "https://creativecommons.org/licenses/by/4.0/"},
+	{"CC-BY-SA-4.0", "Creative Commons Attribution-ShareAlike 4.0", "https://creativecommons.org/licenses/by-sa/4.0/"},
+	{"CC-BY-NC-4.0", "Creative Commons Attribution-NonCommercial 4.0", "https://creativecommons.org/licenses/by-nc/4.0/"},
+	{"CC0-1.0", "Public domain (CC0 1.0)", "https://creativecommons.org/publicdomain/zero/1.0/"},
+}
+
+// LicenseByCode returns the license of a code, the uploads made before the
+// licenses existed have none and ok is false
+func LicenseByCode(code string) (license License, ok bool) {
+	for _, l := range Licenses {
+		if l.Code == code {
+			return l, true
+		}
+	}
+	return License{Code: code, Name: "Not specified"}, false
+}
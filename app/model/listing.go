@@ -0,0 +1,129 @@
+package model
+
+import (
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// *****************************************************************************
+// Listing preferences
+// *****************************************************************************
+
+// ListingPrefs are the crackmes a user doesn't want in the default listings
+type ListingPrefs struct {
+	HideSolved    bool     `bson:"hidesolved"`
+	HideArchived  bool     `bson:"hidearchived,omitempty"`
+	HideLangs     []string `bson:"hidelangs,omitempty"`
+	HidePlatforms []string `bson:"hideplatforms,omitempty"`
+}
+
+// Active tells if the preferences hide any crackme
+func (p ListingPrefs) Active() bool {
+	return p.HideSolved || p.HideArchived || len(p.HideLangs) > 0 || len(p.HidePlatforms) > 0
+}
+
+// Viewer is the logged in user whose preferences filter the listings, the
+// zero Viewer lists everything
+type Viewer struct {
+	Name  string
+	Prefs ListingPrefs
+}
+
+// ViewerOf returns the viewer of a user, with their listing preferences
+func ViewerOf(user User) Viewer {
+	viewer := Viewer{Name: user.Name}
+	if user.Listing != nil {
+		viewer.Prefs = *user.Listing
+	}
+	return viewer
+}
+
+// ListingSetPrefs stores the listing preferences of a user
+func ListingSetPrefs(username string, prefs ListingPrefs) error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("user")
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"name": username}, bson.M{"$set": bson.M{"listing": prefs}})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// listingMatch returns the filter of the public crackmes listed to a viewer
+func listingMatch(viewer Viewer) (bson.M, error) {
+	match := ScopeListed.filter(bson.M{})
+	if viewer.Prefs.HideArchived {
+		match["archived"] = bson.M{"$ne": true}
+	}
+	if len(viewer.Prefs.HideLangs) > 0 {
+		match["lang"] = bson.M{"$nin": viewer.Prefs.HideLangs}
+	}
+	if len(viewer.Prefs.HidePlatforms) > 0 {
+		match["platform"] = bson.M{"$nin": viewer.Prefs.HidePlatforms}
+	}
+	if viewer.Prefs.HideSolved && viewer.Name != "" {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
+		solved, err := collection.Distinct(database.Ctx, "crackmehexid", bson.M{"author": viewer.Name})
+		if err != nil {
+			return nil, standardizeError(err)
+		}
+		if len(solved) > 0 {
+			match["hexid"] = bson.M{"$nin": solved}
+		}
+	}
+	return match, nil
+}
+
+// Marks of the listed crackmes, see ListingMarks
+const (
+	MarkSolved     = "Solved"
+	MarkAttempting = "Attempting"
+)
+
+// ListingMarks returns what username did on the listed crackmes, by hexid:
+// MarkSolved for those with an approved solution they wrote or co-wrote,
+// MarkAttempting for those they are attempting. A query per collection
+// covers the whole page.
+func ListingMarks(username string, crackmes []Crackme) (map[string]string, error) {
+	marks := map[string]string{}
+	if username == "" || len(crackmes) == 0 {
+		return marks, nil
+	}
+
+	hexids := make([]string, len(crackmes))
+	for i, c := range crackmes {
+		hexids[i] = c.HexId
+	}
+
+	if !database.CheckConnection() {
+		return marks, ErrUnavailable
+	}
+	db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+
+	attempting, err := db.Collection("attempt").Distinct(database.Ctx, "crackmehexid", bson.M{"user": username, "crackmehexid": bson.M{"$in": hexids}})
+	if err != nil {
+		return marks, standardizeError(err)
+	}
+	for _, h := range attempting {
+		if hexid, ok := h.(string); ok {
+			marks[hexid] = MarkAttempting
+		}
+	}
+
+	solved, err := db.Collection("solution").Distinct(database.Ctx, "crackmehexid", bson.M{
+		"$or":          []bson.M{{"author": username}, {"coauthors": username}},
+		"visible":      true,
+		"crackmehexid": bson.M{"$in": hexids},
+	})
+	if err != nil {
+		return marks, standardizeError(err)
+	}
+	for _, h := range solved {
+		if hexid, ok := h.(string); ok {
+			marks[hexid] = MarkSolved
+		}
+	}
+
+	return marks, nil
+}
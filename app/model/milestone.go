@@ -0,0 +1,108 @@
+package model
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// *****************************************************************************
+// Milestone
+// *****************************************************************************
+
+// MilestoneSolves are the numbers of approved solutions the authors are
+// congratulated for
+var MilestoneSolves = []int{10, 25, 50, 100, 250}
+
+// The quality milestone, an average of at least MilestoneQuality over at
+// least MilestoneQualityVotes votes
+const (
+	MilestoneQuality      = 5
+	MilestoneQualityVotes = 10
+)
+
+func init() {
+	EventSubscribe(func(e Event) {
+		if e.Type == EventSolutionApproved || e.Type == EventQualityRated {
+			go func() {
+				if err := MilestonesCheck(e.CrackmeHexId); err != nil {
+					log.Println("Failed to check the milestones of", e.CrackmeHexId+":", err)
+				}
+			}()
+		}
+	})
+}
+
+// milestoneReach records a milestone of a crackme and returns true the first
+// time only, so each one is announced once even when checked concurrently
+func milestoneReach(collection *mongo.Collection, hexid, milestone string) (bool, error) {
+	result, err := collection.UpdateOne(database.Ctx,
+		bson.M{"hexid": hexid, "milestones": bson.M{"$ne": milestone}},
+		bson.M{"$addToSet": bson.M{"milestones": milestone}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// MilestonesCheck notifies the author of a crackme of the solve counts and
+// the quality it reached and they were not told about yet
+func MilestonesCheck(crackmehexid string) error {
+	crackme, err := CrackmeByHexId(crackmehexid)
+	if err != nil {
+		return err
+	}
+	if !crackme.Visible || crackme.Deleted {
+		return nil
+	}
+
+	var messages []string
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+
+		// The highest count reached only, for the crackmes which were solved
+		// before the milestones existed
+		for i := len(MilestoneSolves) - 1; i >= 0; i-- {
+			if n := MilestoneSolves[i]; crackme.NbSolutions >= n {
+				var reached bool
+				reached, err = milestoneReach(collection, crackme.HexId, fmt.Sprintf("solves%d", n))
+				if reached {
+					messages = append(messages, fmt.Sprintf("Your crackme %s has %d approved writeups, congratulations!", crackme.Name, n))
+				}
+				break
+			}
+		}
+
+		if err == nil {
+			var qualities []RatingQuality
+			qualities, err = RatingQualityByCrackme(crackme.HexId)
+			if err == nil && len(qualities) >= MilestoneQualityVotes {
+				sum := 0
+				for _, q := range qualities {
+					sum += q.Rating
+				}
+				if average := float64(sum) / float64(len(qualities)); average >= MilestoneQuality {
+					var reached bool
+					reached, err = milestoneReach(collection, crackme.HexId, "quality")
+					if reached {
+						messages = append(messages, fmt.Sprintf("Your crackme %s is rated %.1f for quality by %d people, well done!", crackme.Name, average, len(qualities)))
+					}
+				}
+			}
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	for _, message := range messages {
+		if nerr := NotificationAdd(crackme.Author, message); nerr != nil {
+			log.Println(nerr)
+		}
+	}
+
+	return standardizeError(err)
+}
@@ -16,6 +16,8 @@ var (
 	ErrUnavailable = errors.New("Database is unavailable.")
 	// ErrUnauthorized is a permissions violation
 	ErrUnauthorized = errors.New("User does not have permission to perform this operation.")
+	// ErrConflict is a document changed since it was read
+	ErrConflict = errors.New("Document was changed by someone else.")
 )
 
 // standardizeErrors returns the same error regardless of the database used
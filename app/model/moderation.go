@@ -0,0 +1,111 @@
+package model
+
+import (
+	"log"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"github.com/crackmesone/crackmes.one/app/shared/moderation"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// *****************************************************************************
+// Moderation queue
+// *****************************************************************************
+
+// ModerationQueue returns the submissions waiting for the moderators: new
+// crackmes, versions and solutions, held comments and pending claims
+func ModerationQueue() ([]moderation.Item, error) {
+	var err error
+	var items []moderation.Item
+
+	if !database.CheckConnection() {
+		return items, ErrUnavailable
+	}
+	db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+
+	find := func(collection string, filter bson.M, result interface{}) error {
+		cursor, err := db.Collection(collection).Find(database.Ctx, filter)
+		if err == nil {
+			err = cursor.All(database.Ctx, result)
+		}
+		return err
+	}
+
+	var crackmes []Crackme
+	if err = find("crackme", bson.M{"$or": []bson.M{
+		{"visible": false, "deleted": bson.M{"$ne": true}},
+		{"visible": true, "versions.pending": true},
+	}}, &crackmes); err != nil {
+		return items, standardizeError(err)
+	}
+	for _, c := range crackmes {
+		if !c.Visible {
+			items = append(items, moderation.Item{Kind: moderation.KindCrackme, HexId: c.HexId, Title: c.Name, Author: c.Author, CreatedAt: c.CreatedAt})
+		} else if v := c.PendingVersion(); v != nil {
+			items = append(items, moderation.Item{Kind: moderation.KindVersion, HexId: c.HexId, Title: c.Name, Author: c.Author, CreatedAt: v.CreatedAt})
+		}
+	}
+
+	var solutions []Solution
+	if err = find("solution", bson.M{"visible": false, "deleted": bson.M{"$ne": true}}, &solutions); err != nil {
+		return items, standardizeError(err)
+	}
+	for _, s := range solutions {
+		items = append(items, moderation.Item{Kind: moderation.KindSolution, HexId: s.HexId, Title: s.CrackmeName, Author: s.Author, CreatedAt: s.CreatedAt})
+	}
+
+	var comments []Comment
+	if err = find("comment", bson.M{"held": bson.M{"$exists": true}, "visible": false}, &comments); err != nil {
+		return items, standardizeError(err)
+	}
+	for _, c := range comments {
+		items = append(items, moderation.Item{Kind: moderation.KindComment, HexId: c.CrackMeHexId, Title: c.CrackmeName, Author: c.Author, CreatedAt: c.CreatedAt})
+	}
+
+	var claims []Claim
+	if err = find("claim", bson.M{"status": ClaimPending}, &claims); err != nil {
+		return items, standardizeError(err)
+	}
+	for _, c := range claims {
+		items = append(items, moderation.Item{Kind: moderation.KindClaim, HexId: c.CrackmeHexId, Title: c.CrackmeName, Author: c.User, CreatedAt: c.CreatedAt})
+	}
+
+	return items, nil
+}
+
+// ModerationRun reminds the admins, by notification and on the Discord of
+// the moderators, of the submissions waiting for too long
+func ModerationRun() error {
+	items, err := ModerationQueue()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	c := moderation.ReadConfig()
+	queue := moderation.Measure(items, now, time.Duration(c.MaxAge)*time.Hour)
+	if len(queue.Stale) == 0 || !moderation.Due(now) {
+		return nil
+	}
+	text := moderation.Reminder(queue.Stale, now)
+
+	var admins []User
+	var cursor *mongo.Cursor
+	collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("user")
+	cursor, err = collection.Find(database.Ctx, bson.M{"role": RoleAdmin})
+	if err == nil {
+		err = cursor.All(database.Ctx, &admins)
+	}
+	if err != nil {
+		return standardizeError(err)
+	}
+	for _, admin := range admins {
+		if err := NotificationAdd(admin.Name, text); err != nil {
+			log.Println(err)
+		}
+	}
+
+	return moderation.PostDiscord(text)
+}
@@ -0,0 +1,128 @@
+package model
+
+import (
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Moderator notes
+// *****************************************************************************
+
+// Kinds of moderator notes
+const (
+	ModNoteNote     = "note"
+	ModNoteWarning  = "warning"  // A warning was issued to the user
+	ModNoteIncident = "incident" // The user broke the rules
+)
+
+// ModNoteKinds lists the kinds of notes of the form
+var ModNoteKinds = []string{ModNoteNote, ModNoteWarning, ModNoteIncident}
+
+// ModNote is a private note of the moderators on a user, never shown to them
+type ModNote struct {
+	ObjectId  primitive.ObjectID `bson:"_id,omitempty"`
+	User      string             `bson:"user"`
+	Author    string             `bson:"author"` // Moderator who wrote it
+	Kind      string             `bson:"kind"`
+	Text      string             `bson:"text"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// ModNoteAdd stores a note on a user
+func ModNoteAdd(username, moderator, kind, text string) error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("modnote")
+		_, err = collection.InsertOne(database.Ctx, ModNote{
+			User:      username,
+			Author:    moderator,
+			Kind:      kind,
+			Text:      text,
+			CreatedAt: time.Now(),
+		})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// ModNoteDelete deletes a note
+func ModNoteDelete(hexid string) error {
+	var err error
+
+	id, err := primitive.ObjectIDFromHex(hexid)
+	if err != nil {
+		return ErrNoResult
+	}
+
+	if database.CheckConnection() {
+		var res *mongo.DeleteResult
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("modnote")
+		res, err = collection.DeleteOne(database.Ctx, bson.M{"_id": id})
+		if err == nil && res.DeletedCount == 0 {
+			err = ErrNoResult
+		}
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// ModNotesByUser returns the notes on a user, newest first
+func ModNotesByUser(username string) ([]ModNote, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []ModNote{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("modnote")
+		opts := options.Find().SetSort(bson.D{{"created_at", -1}})
+		cursor, err = collection.Find(database.Ctx, bson.M{"user": username}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+	return result, standardizeError(err)
+}
+
+// ModNoteCounts returns the number of notes on each of the users who have
+// some, to flag them next to their content
+func ModNoteCounts(usernames []string) (map[string]int, error) {
+	var err error
+	var cursor *mongo.Cursor
+	var counts []struct {
+		User  string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+
+	result := map[string]int{}
+	if len(usernames) == 0 {
+		return result, nil
+	}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("modnote")
+		cursor, err = collection.Aggregate(database.Ctx, mongo.Pipeline{
+			{{"$match", bson.M{"user": bson.M{"$in": usernames}}}},
+			{{"$group", bson.M{"_id": "$user", "count": bson.M{"$sum": 1}}}},
+		})
+		if err == nil {
+			err = cursor.All(database.Ctx, &counts)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	for _, c := range counts {
+		result[c.User] = c.Count
+	}
+	return result, standardizeError(err)
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"github.com/crackmesone/crackmes.one/app/shared/retention"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -16,14 +17,18 @@ import (
 
 // Notifications table contains the notification informations for each user
 type Notification struct {
-	ObjectId primitive.ObjectID `bson:"_id,omitempty"`
-	HexId    string             `bson:"hexid,omitempty"`
-	User     string             `bson:"user,omitempty"`
-	Text     string             `bson:"text,omitempty"`
-	Time     time.Time          `bson:"time"`
-	Seen     bool               `bson:"seen"`
+	ObjectId primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	HexId    string             `bson:"hexid,omitempty" json:"hexid"`
+	User     string             `bson:"user,omitempty" json:"-"`
+	Text     string             `bson:"text,omitempty" json:"text"`
+	Time     time.Time          `bson:"time" json:"time"`
+	Seen     bool               `bson:"seen" json:"seen"`
+	Archived bool               `bson:"archived,omitempty" json:"archived"` // Out of the list, deleted with the old read ones
 }
 
+// NotificationsPerPage is the number of notifications of a page
+const NotificationsPerPage = 20
+
 // Returns all notifications of a user
 func NotificationsByUser(username string) ([]Notification, error) {
 	var err error
@@ -42,6 +47,31 @@ func NotificationsByUser(username string) ([]Notification, error) {
 	return result, standardizeError(err)
 }
 
+// Returns a page of notifications of a user, newest first, the archived ones
+// or the others
+func NotificationsByUserPage(username string, page int, archived bool) ([]Notification, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []Notification{}
+	if database.CheckConnection() {
+		opts := options.Find().SetSort(bson.D{{"time", -1}}).SetSkip(int64((page - 1) * NotificationsPerPage)).SetLimit(NotificationsPerPage)
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("notifications")
+		filter := bson.M{"user": username, "archived": bson.M{"$ne": true}}
+		if archived {
+			filter["archived"] = true
+		}
+		cursor, err = collection.Find(database.Ctx, filter, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
 // Sets these notifications to Seen in the db.
 func NotificationsSetSeen(toSetSeen []Notification) error {
 	var err error
@@ -87,6 +117,21 @@ func NotificationsHasUnseen(username string) (bool, error) {
 	return result, standardizeError(err)
 }
 
+// Returns the number of unseen notifications of user
+func NotificationsCountUnseen(username string) (int64, error) {
+	var err error
+	var result int64
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("notifications")
+		result, err = collection.CountDocuments(database.Ctx, bson.M{"user": username, "seen": false})
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
 // Adds a new notification for user
 func NotificationAdd(username, text string) error {
 	var err error
@@ -124,3 +169,92 @@ func NotificationRemove(username, hexid string) error {
 
 	return standardizeError(err)
 }
+
+// NotificationsArchiveAll moves all the notifications of a user out of their
+// list, as seen
+func NotificationsArchiveAll(username string) error {
+	var err error
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("notifications")
+		_, err = collection.UpdateMany(database.Ctx,
+			bson.M{"user": username, "archived": bson.M{"$ne": true}},
+			bson.M{"$set": bson.M{"archived": true, "seen": true}})
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// NotificationEnsureIndexes creates the index of the notifications by user
+// and date, used by the lists and the cleanup
+func NotificationEnsureIndexes() error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("notifications")
+		_, err = collection.Indexes().CreateOne(database.Ctx, mongo.IndexModel{
+			Keys:    bson.D{{"user", 1}, {"time", -1}},
+			Options: options.Index().SetName("user_time"),
+		})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// NotificationsCleanup deletes the read notifications older than the cutoff,
+// then the oldest ones of the users having more than maxPerUser, and returns
+// how many were deleted
+func NotificationsCleanup(cutoff time.Time, maxPerUser int) (int64, error) {
+	if !database.CheckConnection() {
+		return 0, ErrUnavailable
+	}
+	collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("notifications")
+
+	res, err := collection.DeleteMany(database.Ctx, bson.M{"seen": true, "time": bson.M{"$lt": cutoff}})
+	if err != nil {
+		return 0, standardizeError(err)
+	}
+	deleted := res.DeletedCount
+
+	// The users over the cap
+	cursor, err := collection.Aggregate(database.Ctx, mongo.Pipeline{
+		{{"$group", bson.M{"_id": "$user", "count": bson.M{"$sum": 1}}}},
+		{{"$match", bson.M{"count": bson.M{"$gt": maxPerUser}}}},
+	})
+	if err != nil {
+		return deleted, standardizeError(err)
+	}
+	var over []struct {
+		User string `bson:"_id"`
+	}
+	if err = cursor.All(database.Ctx, &over); err != nil {
+		return deleted, standardizeError(err)
+	}
+
+	for _, u := range over {
+		// The oldest notification kept, the older ones go
+		var last Notification
+		opts := options.FindOne().SetSort(bson.D{{"time", -1}}).SetSkip(int64(maxPerUser - 1))
+		err = collection.FindOne(database.Ctx, bson.M{"user": u.User}, opts).Decode(&last)
+		if err == mongo.ErrNoDocuments {
+			continue
+		} else if err != nil {
+			return deleted, standardizeError(err)
+		}
+		res, err = collection.DeleteMany(database.Ctx, bson.M{"user": u.User, "time": bson.M{"$lt": last.Time}})
+		if err != nil {
+			return deleted, standardizeError(err)
+		}
+		deleted += res.DeletedCount
+	}
+
+	return deleted, nil
+}
+
+// NotificationsRetentionRun applies the retention settings, on a schedule
+func NotificationsRetentionRun() (int64, error) {
+	c := retention.ReadConfig()
+	return NotificationsCleanup(c.Cutoff(time.Now()), c.MaxPerUser)
+}
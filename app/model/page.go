@@ -0,0 +1,150 @@
+package model
+
+import (
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Page
+// *****************************************************************************
+
+// PageVersionsLimit is the number of versions listed in the history of a page
+const PageVersionsLimit = 50
+
+// Page is an informational page (FAQ, rules...) written in markdown by the
+// admins
+type Page struct {
+	ObjectId  primitive.ObjectID `bson:"_id,omitempty"`
+	Slug      string             `bson:"slug"`
+	Title     string             `bson:"title"`
+	Body      string             `bson:"body"`
+	UpdatedAt time.Time          `bson:"updated_at"`
+	UpdatedBy string             `bson:"updated_by"`
+}
+
+// PageVersion is a saved state of a page, kept for its history
+type PageVersion struct {
+	ObjectId  primitive.ObjectID `bson:"_id,omitempty"`
+	HexId     string             `bson:"hexid,omitempty"`
+	Slug      string             `bson:"slug"`
+	Title     string             `bson:"title"`
+	Body      string             `bson:"body"`
+	CreatedAt time.Time          `bson:"created_at"`
+	Author    string             `bson:"author"`
+}
+
+// PageBySlug gets a page by its slug
+func PageBySlug(slug string) (Page, error) {
+	var err error
+
+	result := Page{}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("page")
+		err = collection.FindOne(database.Ctx, bson.M{"slug": slug}).Decode(&result)
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// Pages gets all the pages, by slug
+func Pages() ([]Page, error) {
+	var err error
+
+	var result []Page
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("page")
+		opts := options.Find().SetSort(bson.D{{"slug", 1}}).SetProjection(bson.M{"body": 0})
+		var cursor *mongo.Cursor
+		cursor, err = collection.Find(database.Ctx, bson.M{}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// PageSave creates or updates a page and records the new state in its history
+func PageSave(slug, title, body, username string) error {
+	var err error
+
+	now := time.Now()
+
+	if database.CheckConnection() {
+		db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+		opts := options.Update().SetUpsert(true)
+		_, err = db.Collection("page").UpdateOne(database.Ctx, bson.M{"slug": slug}, bson.M{"$set": bson.M{
+			"title":      title,
+			"body":       body,
+			"updated_at": now,
+			"updated_by": username,
+		}}, opts)
+		if err != nil {
+			return standardizeError(err)
+		}
+
+		version := &PageVersion{
+			ObjectId:  primitive.NewObjectID(),
+			Slug:      slug,
+			Title:     title,
+			Body:      body,
+			CreatedAt: now,
+			Author:    username,
+		}
+		version.HexId = version.ObjectId.Hex()
+		_, err = db.Collection("page_version").InsertOne(database.Ctx, version)
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// PageVersions gets the latest versions of a page, newest first
+func PageVersions(slug string) ([]PageVersion, error) {
+	var err error
+
+	var result []PageVersion
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("page_version")
+		opts := options.Find().SetSort(bson.D{{"created_at", -1}}).SetLimit(PageVersionsLimit).SetProjection(bson.M{"body": 0})
+		var cursor *mongo.Cursor
+		cursor, err = collection.Find(database.Ctx, bson.M{"slug": slug}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// PageVersionByHexId gets a version of a page
+func PageVersionByHexId(slug, hexid string) (PageVersion, error) {
+	var err error
+
+	result := PageVersion{}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("page_version")
+		err = collection.FindOne(database.Ctx, bson.M{"slug": slug, "hexid": hexid}).Decode(&result)
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
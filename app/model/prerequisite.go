@@ -0,0 +1,58 @@
+package model
+
+// *****************************************************************************
+// Prerequisite
+// *****************************************************************************
+
+// Prerequisite is something the authors tell solvers to know before trying
+// their crackme
+type Prerequisite struct {
+	Code string
+	Name string
+}
+
+// MaxPrerequisites is the maximum number of prerequisites of a crackme
+const MaxPrerequisites = 5
+
+// Prerequisites are the prerequisites of the upload form and the search
+var Prerequisites = []Prerequisite{
+	{"x86-asm", "x86/x64 assembly"},
+	{"arm-asm", "ARM assembly"},
+	{"dotnet", ".NET / IL"},
+	{"java", "Java bytecode"},
+	{"android", "Android (smali, APK)"},
+	{"crypto", "Cryptography"},
+	{"math", "Mathematics"},
+	{"unpacking", "Unpacking"},
+	{"anti-debug", "Anti-debugging"},
+	{"vm", "Virtual machines / obfuscation"},
+	{"scripting", "Scripting (Python, JS, ...)"},
+}
+
+// PrerequisiteName returns the name of a prerequisite, or the code itself if
+// it is unknown
+func PrerequisiteName(code string) string {
+	for _, p := range Prerequisites {
+		if p.Code == code {
+			return p.Name
+		}
+	}
+	return code
+}
+
+// ValidPrerequisites returns the known codes among codes, in the order of
+// Prerequisites and at most MaxPrerequisites of them
+func ValidPrerequisites(codes []string) []string {
+	chosen := map[string]bool{}
+	for _, code := range codes {
+		chosen[code] = true
+	}
+
+	var result []string
+	for _, p := range Prerequisites {
+		if chosen[p.Code] && len(result) < MaxPrerequisites {
+			result = append(result, p.Code)
+		}
+	}
+	return result
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"github.com/crackmesone/crackmes.one/app/shared/preview"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// *****************************************************************************
+// Preview
+// *****************************************************************************
+
+// setPreview stores the moderation preview of an upload on its document
+func setPreview(collectionName, hexid string, p *preview.Preview) error {
+	var err error
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection(collectionName)
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"hexid": hexid}, bson.M{"$set": bson.M{"preview": p}})
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// CrackmeSetPreview stores the moderation preview of a crackme upload
+func CrackmeSetPreview(hexid string, p *preview.Preview) error {
+	return setPreview("crackme", hexid, p)
+}
+
+// SolutionSetPreview stores the moderation preview of a solution upload
+func SolutionSetPreview(hexid string, p *preview.Preview) error {
+	return setPreview("solution", hexid, p)
+}
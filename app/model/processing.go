@@ -0,0 +1,77 @@
+package model
+
+import (
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/archive"
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// *****************************************************************************
+// Processing
+// *****************************************************************************
+
+// Processing statuses of the crackme uploads, the ones uploaded before the
+// processing was asynchronous have none and count as done
+const (
+	ProcessingPending = "processing"
+	ProcessingDone    = "done"
+	ProcessingFailed  = "failed"
+)
+
+// UploadMetadata is what the processing extracts from a crackme upload
+type UploadMetadata struct {
+	Files  []archive.Entry
+	Readme string
+}
+
+// ProcessingStatus returns the processing status of the crackme upload
+func (c *Crackme) ProcessingStatus() string {
+	if c.Processing == "" {
+		return ProcessingDone
+	}
+	return c.Processing
+}
+
+// CrackmeUploadByHexId returns a crackme, approved or not
+func CrackmeUploadByHexId(hexid string) (Crackme, error) {
+	var err error
+	var result Crackme
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		err = collection.FindOne(database.Ctx, bson.M{"hexid": hexid}).Decode(&result)
+	} else {
+		err = ErrUnavailable
+	}
+	return result, standardizeError(err)
+}
+
+// CrackmeSetProcessed stores the metadata extracted from a crackme upload and
+// marks its processing done
+func CrackmeSetProcessed(hexid string, m UploadMetadata) error {
+	set := bson.M{"files": m.Files, "processing_status": ProcessingDone, "updated_at": time.Now()}
+	if m.Readme != "" {
+		set["readme"] = m.Readme
+	}
+	return crackmeSetProcessing(hexid, set)
+}
+
+// CrackmeSetProcessingFailed marks the processing of a crackme upload failed,
+// the moderators see it when approving it
+func CrackmeSetProcessingFailed(hexid string) error {
+	return crackmeSetProcessing(hexid, bson.M{"processing_status": ProcessingFailed})
+}
+
+// crackmeSetProcessing sets fields of a crackme upload
+func crackmeSetProcessing(hexid string, set bson.M) error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"hexid": hexid}, bson.M{"$set": set})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
@@ -0,0 +1,134 @@
+package model
+
+import (
+	"regexp"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// *****************************************************************************
+// Profile
+// *****************************************************************************
+
+// The aggregations of this file replace several queries by one. They run in
+// the shadow of the legacy queries (see the shadow package) until they are
+// proven to return the same data.
+
+// UserProfile is a user with their visible crackmes, solutions and comments,
+// newest first
+type UserProfile struct {
+	User      `bson:",inline"`
+	Crackmes  []Crackme  `bson:"crackmes"`
+	Solutions []Solution `bson:"solutions"`
+	Comments  []Comment  `bson:"comments"`
+}
+
+// Normalize replaces the nil lists by empty ones, so profiles loaded both
+// ways compare equal
+func (p *UserProfile) Normalize() {
+	if p.Crackmes == nil {
+		p.Crackmes = []Crackme{}
+	}
+	if p.Solutions == nil {
+		p.Solutions = []Solution{}
+	}
+	if p.Comments == nil {
+		p.Comments = []Comment{}
+	}
+}
+
+// profileAuthor matches the documents written by the user of the lookup
+var profileAuthor = bson.E{"$expr", bson.D{{"$eq", bson.A{"$author", "$$name"}}}}
+
+// profileCoAuthor matches the solutions the user of the lookup wrote or
+// co-wrote
+var profileCoAuthor = bson.E{"$expr", bson.D{{"$or", bson.A{
+	bson.D{{"$eq", bson.A{"$author", "$$name"}}},
+	bson.D{{"$in", bson.A{"$$name", bson.D{{"$ifNull", bson.A{"$coauthors", bson.A{}}}}}}},
+}}}}
+
+// profileLookup joins the documents of a collection matching the user,
+// newest first, filtered by the stages if any
+func profileLookup(from, as string, match bson.D, stages ...bson.D) bson.D {
+	pipeline := mongo.Pipeline{{{"$match", match}}}
+	pipeline = append(pipeline, stages...)
+	pipeline = append(pipeline, bson.D{{"$sort", bson.D{{"created_at", -1}}}})
+	return bson.D{{"$lookup", bson.D{
+		{"from", from},
+		{"let", bson.D{{"name", "$name"}}},
+		{"pipeline", pipeline},
+		{"as", as},
+	}}}
+}
+
+// UserProfileByName gets a user, case insensitively, with their content in a
+// single aggregation
+func UserProfileByName(name string) (UserProfile, error) {
+	var err error
+
+	result := UserProfile{}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("user")
+		pipeline := mongo.Pipeline{
+			{{"$match", bson.M{"name": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(name) + "$", Options: "i"}}}},
+			{{"$limit", 1}},
+			profileLookup("crackme", "crackmes", append(bson.D{profileAuthor}, ScopeListed.match()...)),
+			// Not those of the unlisted crackmes, found by their link only
+			profileLookup("solution", "solutions", bson.D{profileCoAuthor, {"visible", true}}, ScopeListed.crackmeStages()...),
+			profileLookup("comment", "comments", bson.D{profileAuthor, {"visible", true}}, ScopeListed.crackmeStages()...),
+		}
+
+		var cursor *mongo.Cursor
+		var results []UserProfile
+		cursor, err = collection.Aggregate(database.Ctx, pipeline)
+		if err == nil {
+			err = cursor.All(database.Ctx, &results)
+		}
+		if err == nil && len(results) == 0 {
+			err = ErrNoResult
+		} else if err == nil {
+			result = results[0]
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	result.Normalize()
+	return result, standardizeError(err)
+}
+
+// LastCrackMesAggregate is LastCrackMes as an aggregation
+func LastCrackMesAggregate(page int, viewer Viewer) ([]Crackme, error) {
+	var err error
+
+	result := []Crackme{}
+
+	if database.CheckConnection() {
+		collection := database.Listing("crackme")
+		var match bson.M
+		match, err = listingMatch(viewer)
+		if err != nil {
+			return result, err
+		}
+		pipeline := mongo.Pipeline{
+			{{"$match", match}},
+			{{"$sort", bson.D{{"created_at", -1}}}},
+			{{"$skip", int64((page - 1) * 50)}},
+			{{"$limit", 50}},
+		}
+
+		var cursor *mongo.Cursor
+		cursor, err = collection.Aggregate(database.Ctx, pipeline)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
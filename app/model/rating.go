@@ -0,0 +1,137 @@
+package model
+
+import (
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"github.com/crackmesone/crackmes.one/app/shared/rating"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Rating
+// *****************************************************************************
+
+// RatingLevels is the number of levels of the difficulty and quality ratings,
+// rated from 1 to RatingLevels
+const RatingLevels = 6
+
+// ratingDistribution returns the number of votes of each level on the crackme,
+// in the ratings collection name. Index 0 counts the 1s.
+func ratingDistribution(name, crackmehexid string) ([]int, error) {
+	var err error
+	var cursor *mongo.Cursor
+	var groups []struct {
+		Rating int `bson:"_id"`
+		Count  int `bson:"count"`
+	}
+
+	result := make([]int, RatingLevels)
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection(name)
+		pipeline := mongo.Pipeline{
+			{{"$match", bson.M{"crackmehexid": crackmehexid}}},
+			{{"$group", bson.M{"_id": "$rating", "count": bson.M{"$sum": 1}}}},
+		}
+		cursor, err = collection.Aggregate(database.Ctx, pipeline)
+		if err == nil {
+			err = cursor.All(database.Ctx, &groups)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	for _, g := range groups {
+		if g.Rating >= 1 && g.Rating <= RatingLevels {
+			result[g.Rating-1] = g.Count
+		}
+	}
+	return result, standardizeError(err)
+}
+
+// RatingDifficultyDistribution returns the number of difficulty votes of each
+// level on the crackme
+func RatingDifficultyDistribution(crackmehexid string) ([]int, error) {
+	return ratingDistribution("rating_difficulty", crackmehexid)
+}
+
+// RatingQualityDistribution returns the number of quality votes of each level
+// on the crackme
+func RatingQualityDistribution(crackmehexid string) ([]int, error) {
+	return ratingDistribution("rating_quality", crackmehexid)
+}
+
+// ratingCollections are the ratings collections, by kind of vote
+var ratingCollections = map[string]string{
+	rating.KindDifficulty: "rating_difficulty",
+	rating.KindQuality:    "rating_quality",
+}
+
+// RatingEnsureIndexes creates the unique indexes allowing one vote of each
+// kind per user and crackme. It fails while the collections hold duplicates,
+// see script/dedupe_ratings.py.
+func RatingEnsureIndexes() error {
+	var err error
+	if database.CheckConnection() {
+		for _, name := range ratingCollections {
+			collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection(name)
+			_, err = collection.Indexes().CreateOne(database.Ctx, mongo.IndexModel{
+				Keys:    bson.D{{"crackmehexid", 1}, {"author", 1}},
+				Options: options.Index().SetUnique(true).SetName("one_vote"),
+			})
+			if err != nil {
+				break
+			}
+		}
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// RatingVotes returns all the difficulty and quality votes, with the author
+// of their crackme, for the search of anomalies
+func RatingVotes() ([]rating.Vote, error) {
+	var err error
+	var result []rating.Vote
+
+	if database.CheckConnection() {
+		for kind, name := range ratingCollections {
+			var cursor *mongo.Cursor
+			var votes []struct {
+				User      string    `bson:"author"`
+				Crackme   string    `bson:"crackmehexid"`
+				Rating    int       `bson:"rating"`
+				CreatedAt time.Time `bson:"created_at"`
+				Author    []string  `bson:"crackmeauthor"`
+			}
+
+			collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection(name)
+			pipeline := mongo.Pipeline{
+				{{"$lookup", bson.M{"from": "crackme", "localField": "crackmehexid", "foreignField": "hexid", "as": "crackme"}}},
+				{{"$project", bson.M{"author": 1, "crackmehexid": 1, "rating": 1, "created_at": 1, "crackmeauthor": "$crackme.author"}}},
+			}
+			cursor, err = collection.Aggregate(database.Ctx, pipeline)
+			if err == nil {
+				err = cursor.All(database.Ctx, &votes)
+			}
+			if err != nil {
+				break
+			}
+
+			for _, v := range votes {
+				vote := rating.Vote{Kind: kind, User: v.User, Crackme: v.Crackme, Rating: v.Rating, Time: v.CreatedAt}
+				if len(v.Author) > 0 {
+					vote.Author = v.Author[0]
+				}
+				result = append(result, vote)
+			}
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
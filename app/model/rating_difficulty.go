@@ -84,6 +84,11 @@ func RatingDifficultyCreate(username, crackmehexid string, rating int) error {
 			Deleted:      false,
 		}
 		_, err = collection.InsertOne(database.Ctx, rating_difficulty)
+
+		// A concurrent vote of the user was inserted first, see RatingEnsureIndexes
+		if mongo.IsDuplicateKeyError(err) {
+			return RatingDifficultySetRating(username, crackmehexid, rating)
+		}
 	} else {
 		err = ErrUnavailable
 	}
@@ -56,6 +56,9 @@ func RatingQualitySetRating(username, crackmehexid string, rating int) error {
 
 		// Validate the object id
 		_, err = collection.UpdateOne(database.Ctx, bson.M{"crackmehexid": crackmehexid, "author": username}, bson.M{"$set": bson.M{"rating": rating}})
+		if err == nil {
+			EventEmit(Event{Type: EventQualityRated, User: username, HexId: crackmehexid, CrackmeHexId: crackmehexid})
+		}
 	} else {
 		err = ErrUnavailable
 	}
@@ -78,6 +81,14 @@ func RatingQualityCreate(username, crackmehexid string, rating int) error {
 			Deleted:      false,
 		}
 		_, err = collection.InsertOne(database.Ctx, rating_quality)
+
+		// A concurrent vote of the user was inserted first, see RatingEnsureIndexes
+		if mongo.IsDuplicateKeyError(err) {
+			return RatingQualitySetRating(username, crackmehexid, rating)
+		}
+		if err == nil {
+			EventEmit(Event{Type: EventQualityRated, User: username, HexId: crackmehexid, CrackmeHexId: crackmehexid})
+		}
 	} else {
 		err = ErrUnavailable
 	}
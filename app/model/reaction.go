@@ -0,0 +1,130 @@
+package model
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Reaction
+// *****************************************************************************
+
+// ErrOwnComment is returned when users react to their own comment
+var ErrOwnComment = errors.New("You can't react to your own comment.")
+
+// Reaction is the thumbs up of a user on a comment. There are no downvotes,
+// and a user reacts at most once to a comment.
+type Reaction struct {
+	ObjectId  primitive.ObjectID `bson:"_id,omitempty"`
+	CommentId primitive.ObjectID `bson:"commentid"`
+	Author    string             `bson:"author"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// ReactionEnsureIndexes creates the unique index allowing a single reaction
+// per user and comment
+func ReactionEnsureIndexes() error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("reaction")
+		_, err = collection.Indexes().CreateOne(database.Ctx, mongo.IndexModel{
+			Keys:    bson.D{{"commentid", 1}, {"author", 1}},
+			Options: options.Index().SetUnique(true).SetName("one_reaction"),
+		})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// ReactionToggle adds the reaction of a user to a comment, or removes it if
+// they already reacted. It returns whether the user now reacts and the number
+// of reactions of the comment.
+func ReactionToggle(commenthexid, username string) (bool, int, error) {
+	var err error
+	var reacted bool
+	var comment Comment
+
+	id, err := primitive.ObjectIDFromHex(commenthexid)
+	if err != nil {
+		return false, 0, ErrNoResult
+	}
+
+	if database.CheckConnection() {
+		db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+		err = db.Collection("comment").FindOne(database.Ctx, bson.M{"_id": id, "visible": true}).Decode(&comment)
+		if err == nil && comment.Author == username {
+			return false, comment.Reactions, ErrOwnComment
+		}
+
+		// The unique index tells whether the user already reacted
+		inc := 1
+		if err == nil {
+			reacted = true
+			_, err = db.Collection("reaction").InsertOne(database.Ctx, Reaction{CommentId: id, Author: username, CreatedAt: time.Now()})
+			if mongo.IsDuplicateKeyError(err) {
+				var res *mongo.DeleteResult
+				res, err = db.Collection("reaction").DeleteOne(database.Ctx, bson.M{"commentid": id, "author": username})
+				reacted, inc = false, 0
+				if err == nil && res.DeletedCount == 1 {
+					inc = -1
+				}
+			}
+		}
+
+		if err == nil && inc != 0 {
+			opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+			err = db.Collection("comment").FindOneAndUpdate(database.Ctx, bson.M{"_id": id}, bson.M{"$inc": bson.M{"reactions": inc}}, opts).Decode(&comment)
+			if err == nil {
+				if cerr := CrackmeTouch(comment.CrackMeHexId); cerr != nil {
+					log.Println("Failed to touch the crackme:", cerr)
+				}
+			}
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return reacted, comment.Reactions, standardizeError(err)
+}
+
+// ReactedComments returns the comments, among the given ones, the user
+// reacted to
+func ReactedComments(username string, comments []Comment) (map[primitive.ObjectID]bool, error) {
+	var err error
+	var cursor *mongo.Cursor
+	var reactions []Reaction
+
+	result := map[primitive.ObjectID]bool{}
+	if username == "" || len(comments) == 0 {
+		return result, nil
+	}
+
+	ids := make([]primitive.ObjectID, len(comments))
+	for i, c := range comments {
+		ids[i] = c.ObjectId
+	}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("reaction")
+		cursor, err = collection.Find(database.Ctx, bson.M{"author": username, "commentid": bson.M{"$in": ids}})
+		if err == nil {
+			err = cursor.All(database.Ctx, &reactions)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	for _, reaction := range reactions {
+		result[reaction.CommentId] = true
+	}
+	return result, standardizeError(err)
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// *****************************************************************************
+// Revision
+// *****************************************************************************
+
+// The crackmes and the users carry a revision, bumped by the updates that
+// could race: a form sends back the revision it was rendered from and the
+// update only applies if nobody changed the document since. The documents
+// written before the revisions existed are at revision 0, without the field.
+
+// revMatch returns the filter on the rev field of a document at revision rev
+func revMatch(rev int) interface{} {
+	if rev == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return rev
+}
+
+// updateRev applies set to the document of collection matched by filter if
+// it is at revision rev, and bumps its revision. It returns ErrConflict when
+// the document is at another revision and ErrNoResult when there is none.
+func updateRev(name string, filter bson.M, rev int, set bson.M) error {
+	var err error
+	var res *mongo.UpdateResult
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection(name)
+		match := bson.M{"rev": revMatch(rev)}
+		for k, v := range filter {
+			match[k] = v
+		}
+		res, err = collection.UpdateOne(database.Ctx, match, bson.M{"$set": set, "$inc": bson.M{"rev": 1}})
+		if err == nil && res.MatchedCount == 0 {
+			err = revConflict(collection, filter)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// revConflict tells why a revision update matched nothing: ErrConflict if
+// the document exists, at another revision, ErrNoResult otherwise
+func revConflict(collection *mongo.Collection, filter bson.M) error {
+	n, err := collection.CountDocuments(database.Ctx, filter)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrConflict
+	}
+	return ErrNoResult
+}
+
+// crackmeRevConflict is revConflict on the crackmes
+func crackmeRevConflict(filter bson.M) error {
+	if !database.CheckConnection() {
+		return ErrUnavailable
+	}
+	collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+	return standardizeError(revConflict(collection, filter))
+}
+
+// CrackmeUpdateRev sets fields of a crackme if it is still at revision rev
+// and bumps its revision, ErrConflict otherwise
+func CrackmeUpdateRev(hexid string, rev int, set bson.M) error {
+	return updateRev("crackme", bson.M{"hexid": hexid}, rev, set)
+}
+
+// UserUpdateRev sets fields of a user if they are still at revision rev and
+// bumps their revision, ErrConflict otherwise
+func UserUpdateRev(name string, rev int, set bson.M) error {
+	return updateRev("user", bson.M{"name": name}, rev, set)
+}
@@ -0,0 +1,182 @@
+package model
+
+import (
+	"errors"
+	"log"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"github.com/crackmesone/crackmes.one/app/shared/passhash"
+	"github.com/crackmesone/crackmes.one/app/shared/seed"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Seed
+// *****************************************************************************
+
+// SeedPassword is the password of the generated users
+const SeedPassword = "seed"
+
+// ErrNotEmpty is returned when seeding a database which already has users
+var ErrNotEmpty = errors.New("Database is not empty.")
+
+// seedInsert inserts a batch of generated documents
+func seedInsert(collection *mongo.Collection, docs []interface{}) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	_, err := collection.InsertMany(database.Ctx, docs, options.InsertMany().SetOrdered(false))
+	return standardizeError(err)
+}
+
+// Seed fills an empty database with a synthetic site, see the seed package.
+// The counters of the users and the crackmes match the generated content. The
+// storage files are not created.
+func Seed(info seed.Info) error {
+	if !database.CheckConnection() {
+		return ErrUnavailable
+	}
+	db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+
+	nb, err := db.Collection("user").CountDocuments(database.Ctx, bson.M{})
+	if err != nil {
+		return standardizeError(err)
+	}
+	if nb > 0 {
+		return ErrNotEmpty
+	}
+
+	password, err := passhash.HashString(SeedPassword)
+	if err != nil {
+		return err
+	}
+
+	g := seed.New(info)
+	users := make([]User, info.Users)
+	for i := range users {
+		users[i].ObjectId = primitive.NewObjectID()
+		users[i].HexId = users[i].ObjectId.Hex()
+		users[i].Name = seed.UserName(i)
+		users[i].Email = users[i].Name + "@example.invalid"
+		users[i].Password = password
+		users[i].Visible = true
+	}
+
+	generated := g.Crackmes()
+	crackmes := make([]Crackme, len(generated))
+	for i, c := range generated {
+		crackmes[i] = Crackme{
+			ObjectId:   primitive.NewObjectID(),
+			Name:       c.Name,
+			Info:       c.Info,
+			Lang:       c.Lang,
+			Arch:       c.Arch,
+			Platform:   c.Platform,
+			Author:     users[c.Author].Name,
+			CreatedAt:  c.CreatedAt,
+			Visible:    !c.Pending,
+			Difficulty: c.Difficulty,
+			Quality:    c.Quality,
+			License:    Licenses[0].Code,
+		}
+		crackmes[i].HexId = crackmes[i].ObjectId.Hex()
+		if !c.Pending {
+			users[c.Author].NbCrackmes++
+		}
+	}
+	log.Println("Seed:", len(users), "users and", len(crackmes), "crackmes generated")
+
+	// The solutions and the comments are inserted as they are generated, the
+	// crackmes and the users once they are counted
+	nb = 0
+	err = g.Solutions(generated, func(batch []seed.Solution) error {
+		docs := make([]interface{}, len(batch))
+		for i, s := range batch {
+			crackme := &crackmes[s.Crackme]
+			solution := &Solution{
+				ObjectId:     primitive.NewObjectID(),
+				Info:         "Generated writeup.",
+				CrackmeId:    crackme.ObjectId,
+				CrackmeHexId: crackme.HexId,
+				CrackmeName:  crackme.Name,
+				CreatedAt:    s.CreatedAt,
+				Author:       users[s.Author].Name,
+				Visible:      !s.Pending,
+				Language:     "en",
+				License:      Licenses[0].Code,
+			}
+			solution.HexId = solution.ObjectId.Hex()
+			if !s.Pending {
+				users[s.Author].NbSolutions++
+				crackme.NbSolutions++
+				crackme.WriteupLangs = []string{"en"}
+			}
+			docs[i] = solution
+		}
+		nb += int64(len(docs))
+		if nb%100000 < int64(len(docs)) {
+			log.Println("Seed:", nb, "solutions inserted")
+		}
+		return seedInsert(db.Collection("solution"), docs)
+	})
+	if err != nil {
+		return err
+	}
+
+	nb = 0
+	err = g.Comments(generated, func(batch []seed.Comment) error {
+		docs := make([]interface{}, len(batch))
+		for i, c := range batch {
+			crackme := &crackmes[c.Crackme]
+			docs[i] = &Comment{
+				ObjectId:     primitive.NewObjectID(),
+				Content:      c.Text,
+				Author:       users[c.Author].Name,
+				CrackMeHexId: crackme.HexId,
+				CrackmeName:  crackme.Name,
+				CreatedAt:    c.CreatedAt,
+				Visible:      true,
+				ByAuthor:     crackme.Author == users[c.Author].Name,
+			}
+			users[c.Author].NbComments++
+			crackme.NbComments++
+		}
+		nb += int64(len(docs))
+		if nb%100000 < int64(len(docs)) {
+			log.Println("Seed:", nb, "comments inserted")
+		}
+		return seedInsert(db.Collection("comment"), docs)
+	})
+	if err != nil {
+		return err
+	}
+
+	batchSize := info.BatchSize
+	if batchSize < 1 {
+		batchSize = seed.Defaults().BatchSize
+	}
+	for start := 0; start < len(crackmes); start += batchSize {
+		var docs []interface{}
+		for i := start; i < start+batchSize && i < len(crackmes); i++ {
+			docs = append(docs, &crackmes[i])
+		}
+		if err := seedInsert(db.Collection("crackme"), docs); err != nil {
+			return err
+		}
+	}
+	for start := 0; start < len(users); start += batchSize {
+		var docs []interface{}
+		for i := start; i < start+batchSize && i < len(users); i++ {
+			docs = append(docs, &users[i])
+		}
+		if err := seedInsert(db.Collection("user"), docs); err != nil {
+			return err
+		}
+	}
+	log.Println("Seed: done")
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"github.com/crackmesone/crackmes.one/app/shared/settings"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Settings
+// *****************************************************************************
+
+// SettingOverride is the value of an operational knob set by an admin
+type SettingOverride struct {
+	Name      string    `bson:"name"`
+	Value     int       `bson:"value"`
+	UpdatedAt time.Time `bson:"updated_at"`
+	UpdatedBy string    `bson:"updated_by"`
+}
+
+// SettingsLoad applies the values set by the admins to the knobs, the ones
+// out of the current bounds are skipped
+func SettingsLoad() error {
+	var err error
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("settings")
+		var cursor *mongo.Cursor
+		var result []SettingOverride
+		cursor, err = collection.Find(database.Ctx, bson.M{})
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+		for _, o := range result {
+			settings.Override(o.Name, o.Value)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// SettingSave stores the value of a knob set by an admin and applies it
+func SettingSave(name string, value int, username string) error {
+	if err := settings.Validate(name, value); err != nil {
+		return err
+	}
+
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("settings")
+		opts := options.Update().SetUpsert(true)
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"name": name}, bson.M{"$set": bson.M{
+			"value":      value,
+			"updated_at": time.Now(),
+			"updated_by": username,
+		}}, opts)
+		if err == nil {
+			err = settings.Override(name, value)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
@@ -0,0 +1,148 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/clientip"
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Sign-in
+// *****************************************************************************
+
+// SignInMaxNetworks is the number of networks remembered per device, the
+// first seen are forgotten past it
+const SignInMaxNetworks = 50
+
+// SignIn is a device a user signed in from, told apart by the device cookie
+type SignIn struct {
+	User      string    `bson:"user"`
+	Device    string    `bson:"device"`   // Unique per user
+	IP        string    `bson:"ip"`       // Of the last sign-in
+	Network   string    `bson:"network"`  // Of the IP, as counted by the rate limits
+	Networks  []string  `bson:"networks"` // Of all the sign-ins, up to SignInMaxNetworks
+	UserAgent string    `bson:"useragent"`
+	Location  string    `bson:"location"`
+	Count     int       `bson:"count"`
+	FirstAt   time.Time `bson:"first_at"`
+	LastAt    time.Time `bson:"last_at"`
+}
+
+// SignInEnsureIndexes creates the unique index of the devices of the users
+func SignInEnsureIndexes() error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("signin")
+		_, err = collection.Indexes().CreateOne(database.Ctx, mongo.IndexModel{
+			Keys:    bson.D{{"user", 1}, {"device", 1}},
+			Options: options.Index().SetUnique(true).SetName("one_device"),
+		})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// SignInRecord records a sign-in of a user. It returns true if the device, or
+// the network of the IP address, was never seen before for this user while
+// they already signed in from elsewhere, the first device of a user isn't
+// news. The networks of every sign-in of a device are remembered, a user
+// going back and forth between home and work isn't alerted, nor are the IPv6
+// users changing of address in their network.
+func SignInRecord(username, device, ip, useragent, location string) (bool, error) {
+	var err error
+	var unseen bool
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("signin")
+
+		// The sign-ins recorded before the lists of networks match by their
+		// last network, or by IP before the networks
+		network := clientip.Network(ip)
+		var known, fromIP int64
+		known, err = collection.CountDocuments(database.Ctx, bson.M{"user": username})
+		if err == nil {
+			fromIP, err = collection.CountDocuments(database.Ctx, bson.M{"user": username, "$or": bson.A{
+				bson.M{"networks": network}, bson.M{"network": network}, bson.M{"ip": ip},
+			}})
+		}
+		var result *mongo.UpdateResult
+		if err == nil {
+			now := time.Now()
+			result, err = collection.UpdateOne(database.Ctx,
+				bson.M{"user": username, "device": device},
+				bson.M{
+					"$set":         bson.M{"ip": ip, "network": network, "useragent": useragent, "location": location, "last_at": now},
+					"$inc":         bson.M{"count": 1},
+					"$setOnInsert": bson.M{"first_at": now},
+					"$addToSet":    bson.M{"networks": network},
+				},
+				options.Update().SetUpsert(true))
+		}
+		if err == nil {
+			// Forget the first networks past the cap
+			_, err = collection.UpdateOne(database.Ctx,
+				bson.M{"user": username, "device": device, fmt.Sprintf("networks.%d", SignInMaxNetworks): bson.M{"$exists": true}},
+				bson.M{"$push": bson.M{"networks": bson.M{"$each": bson.A{}, "$slice": -SignInMaxNetworks}}})
+		}
+		if err == nil {
+			unseen = known > 0 && (result.UpsertedCount > 0 || fromIP == 0)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return unseen, standardizeError(err)
+}
+
+// SignInsByUser returns the devices a user signed in from, the latest first
+func SignInsByUser(username string) ([]SignIn, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []SignIn{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("signin")
+		opts := options.Find().SetSort(bson.D{{"last_at", -1}})
+		cursor, err = collection.Find(database.Ctx, bson.M{"user": username}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// SignInForget removes the devices of a user, the next sign-in from any of
+// them will be alerted about again
+func SignInForget(username string) error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("signin")
+		_, err = collection.DeleteMany(database.Ctx, bson.M{"user": username})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// UserSetSignInEmail turns the emails about the sign-ins from new devices of
+// a user on or off
+func UserSetSignInEmail(username string, on bool) error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("user")
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"name": username}, bson.M{"$set": bson.M{"signinemail": on}})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
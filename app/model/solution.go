@@ -1,9 +1,16 @@
 package model
 
 import (
+	"context"
+	"log"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"github.com/crackmesone/crackmes.one/app/shared/download"
+	"github.com/crackmesone/crackmes.one/app/shared/similarity"
+	"github.com/crackmesone/crackmes.one/app/shared/webhook"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -16,16 +23,88 @@ import (
 
 // Solution table contains the information for each solution/writeup
 type Solution struct {
-	ObjectId      primitive.ObjectID `bson:"_id,omitempty"`
-	HexId         string             `bson:"hexid,omitempty"`
-	Info          string             `bson:"info"`
-	CrackmeId     primitive.ObjectID `bson:"crackmeid,omitempty"`
-	CrackmeHexId  string             `bson:"crackmehexid,omitempty"`
-	CrackmeName   string             `bson:"crackmename,omitempty"`
-	CreatedAt     time.Time          `bson:"created_at"`
-	Author        string             `bson:"author,omitempty"`
-	Visible       bool               `bson:"visible"`
-	Deleted       bool               `bson:"deleted"`
+	ObjectId       primitive.ObjectID `bson:"_id,omitempty"`
+	HexId          string             `bson:"hexid,omitempty"`
+	Info           string             `bson:"info"`
+	CrackmeId      primitive.ObjectID `bson:"crackmeid,omitempty"`
+	CrackmeHexId   string             `bson:"crackmehexid,omitempty"`
+	CrackmeName    string             `bson:"crackmename,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at"`
+	Author         string             `bson:"author,omitempty"`
+	Visible        bool               `bson:"visible"`
+	Deleted        bool               `bson:"deleted"`
+	SimilarTo      string             `bson:"similarto,omitempty"`
+	Similarity     float64            `bson:"similarity,omitempty"`
+	Downloads      int                `bson:"downloads"`
+	HintsUsed      int                `bson:"hintsused"` // Hints of the crackme the author revealed before submitting
+	Sections       *WriteupSections   `bson:"sections,omitempty"`
+	CrackmeVersion int                `bson:"crackmeversion,omitempty"` // Version of the crackme the solution was written for
+	Language       string             `bson:"language,omitempty"`       // Code of the language the writeup is written in
+	License        string             `bson:"license,omitempty"`
+	Team           string             `bson:"team,omitempty"`      // Team it was posted for
+	CoAuthors      []string           `bson:"coauthors,omitempty"` // Registered users who wrote it with the author
+	Broken         bool               `bson:"broken,omitempty"`    // Its file is missing, empty or unreadable
+}
+
+// MaxCoAuthors is the number of co-authors a solution can list
+const MaxCoAuthors = 3
+
+// Authors returns the author of the solution followed by its co-authors
+func (s Solution) Authors() []string {
+	return append([]string{s.Author}, s.CoAuthors...)
+}
+
+// authoredBy matches the solutions a user wrote or co-wrote
+func authoredBy(username string) bson.M {
+	return bson.M{"$or": bson.A{bson.M{"author": username}, bson.M{"coauthors": username}}}
+}
+
+// WriteupLanguage is a language writeups can be written in
+type WriteupLanguage struct {
+	Code string
+	Name string
+}
+
+// WriteupLanguages are the languages of the upload form
+var WriteupLanguages = []WriteupLanguage{
+	{"en", "English"},
+	{"ar", "Arabic"},
+	{"zh", "Chinese"},
+	{"fr", "French"},
+	{"de", "German"},
+	{"hi", "Hindi"},
+	{"id", "Indonesian"},
+	{"it", "Italian"},
+	{"ja", "Japanese"},
+	{"ko", "Korean"},
+	{"fa", "Persian"},
+	{"pl", "Polish"},
+	{"pt", "Portuguese"},
+	{"ru", "Russian"},
+	{"es", "Spanish"},
+	{"tr", "Turkish"},
+	{"uk", "Ukrainian"},
+	{"vi", "Vietnamese"},
+}
+
+// WriteupLanguageName returns the name of a writeup language code, or an empty
+// string for an unknown code
+func WriteupLanguageName(code string) string {
+	for _, l := range WriteupLanguages {
+		if l.Code == code {
+			return l.Name
+		}
+	}
+	return ""
+}
+
+// WriteupSections are the parts of a writeup filled in the upload form, the
+// solutions uploaded before they existed don't have them
+type WriteupSections struct {
+	Tools    string `bson:"tools" json:"tools"`
+	Approach string `bson:"approach" json:"approach"`
+	Key      string `bson:"key,omitempty" json:"key,omitempty"`
+	Patch    string `bson:"patch,omitempty" json:"patch,omitempty"`
 }
 
 type SolutionExtended struct {
@@ -48,7 +127,7 @@ func CountSolutions() (int, error) {
 	var err error
 	var nb int64
 	if database.CheckConnection() {
-		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
+		collection := database.Listing("solution")
 		nb, err = collection.EstimatedDocumentCount(database.Ctx)
 	} else {
 		err = ErrUnavailable
@@ -62,7 +141,23 @@ func CountSolutionsByUser(username string) (int, error) {
 	var nb int64
 	if database.CheckConnection() {
 		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
-		nb, err = collection.CountDocuments(database.Ctx, bson.M{"author": username, "visible": true})
+		filter := authoredBy(username)
+		filter["visible"] = true
+		nb, err = collection.CountDocuments(database.Ctx, filter)
+	} else {
+		err = ErrUnavailable
+	}
+	return int(nb), standardizeError(err)
+}
+
+// CountPendingSolutionsByUser returns the number of solutions of username
+// waiting for approval
+func CountPendingSolutionsByUser(username string) (int, error) {
+	var err error
+	var nb int64
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
+		nb, err = collection.CountDocuments(database.Ctx, bson.M{"author": username, "visible": false})
 	} else {
 		err = ErrUnavailable
 	}
@@ -95,7 +190,7 @@ func SolutionByHexId(hexid string) (Solution, error) {
 	} else {
 		err = ErrUnavailable
 	}
-	return result, err
+	return result, standardizeError(err)
 }
 
 func SolutionsByUser(username string) ([]Solution, error) {
@@ -106,11 +201,18 @@ func SolutionsByUser(username string) ([]Solution, error) {
 
 	if database.CheckConnection() {
 		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
-		opts := options.Find().SetSort(bson.D{{"created_at", -1}})
 
-		// Validate the object id
-		cursor, err = collection.Find(database.Ctx, bson.M{"author": username, "visible": true}, opts)
-		err = cursor.All(database.Ctx, &result)
+		// With the solutions they co-wrote, not those of the unlisted
+		// crackmes, found by their link only
+		filter := authoredBy(username)
+		filter["visible"] = true
+		pipeline := mongo.Pipeline{{{"$match", filter}}}
+		pipeline = append(pipeline, ScopeListed.crackmeStages()...)
+		pipeline = append(pipeline, bson.D{{"$sort", bson.D{{"created_at", -1}}}})
+		cursor, err = collection.Aggregate(database.Ctx, pipeline)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
 	} else {
 		err = ErrUnavailable
 	}
@@ -133,6 +235,19 @@ func SolutionsByUserAndCrackMe(username, crackmehexid string) (Solution, error)
 	return result, err
 }
 
+// HasSolved returns true if username has an approved solution of the crackme
+func HasSolved(username string, crackme primitive.ObjectID) (bool, error) {
+	var err error
+	var nb int64
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
+		nb, err = collection.CountDocuments(database.Ctx, bson.M{"crackmeid": crackme, "author": username, "visible": true})
+	} else {
+		err = ErrUnavailable
+	}
+	return nb != 0, standardizeError(err)
+}
+
 func SolutionsByCrackme(crackme primitive.ObjectID) ([]Solution, error) {
 	var err error
 
@@ -152,33 +267,447 @@ func SolutionsByCrackme(crackme primitive.ObjectID) ([]Solution, error) {
 	return result, err
 }
 
+// SolutionsPerPage is the number of solutions of a page of the crackme API
+const SolutionsPerPage = 50
+
+// SolutionsByCrackmePage returns a page of the visible solutions of a crackme,
+// oldest first, in a language if not empty
+func SolutionsByCrackmePage(crackme primitive.ObjectID, language string, page int) ([]Solution, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []Solution{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
+		opts := options.Find().SetSort(bson.D{{"created_at", 1}}).SetSkip(int64((page - 1) * SolutionsPerPage)).SetLimit(SolutionsPerPage)
+		filter := bson.M{"crackmeid": crackme, "visible": true, "broken": bson.M{"$ne": true}}
+		if language != "" {
+			filter["language"] = language
+		}
+		cursor, err = collection.Find(database.Ctx, filter, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
 // SolutionCreate creates a solution
-func SolutionCreate(info, username, crackmehexid string) error {
+func SolutionCreate(info string, sections WriteupSections, language, license, username, team string, coauthors []string, crackmehexid string) error {
 	var err error
 	crackme, err := CrackmeByHexId(crackmehexid)
 	if err != nil {
 		return standardizeError(err)
 	}
 
+	hintsUsed, err := HintsRevealedCount(username, crackmehexid)
+	if err != nil {
+		return err
+	}
+
 	if database.CheckConnection() {
 		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
 		objId := primitive.NewObjectID()
 		solution := &Solution{
-			ObjectId:     objId,
-			HexId:        objId.Hex(),
-			Info:         info,
-			CrackmeId:    crackme.ObjectId,
-			CrackmeHexId: crackme.HexId,
-			CrackmeName:  crackme.Name,
-			CreatedAt:    time.Now(),
-			Author:       username,
-			Visible:      false,
-			Deleted:      false,
+			ObjectId:       objId,
+			HexId:          objId.Hex(),
+			Info:           info,
+			CrackmeId:      crackme.ObjectId,
+			CrackmeHexId:   crackme.HexId,
+			CrackmeName:    crackme.Name,
+			CreatedAt:      time.Now(),
+			Author:         username,
+			Visible:        false,
+			Deleted:        false,
+			HintsUsed:      hintsUsed,
+			Sections:       &sections,
+			CrackmeVersion: crackme.CurrentVersion(),
+			Language:       language,
+			License:        license,
+			Team:           team,
+			CoAuthors:      coauthors,
 		}
 		_, err = collection.InsertOne(database.Ctx, solution)
+		err = duplicateError(err, ErrSolutionExists)
 	} else {
 		err = ErrUnavailable
 	}
 
 	return standardizeError(err)
 }
+
+// SimilarityThreshold is the estimated similarity from which a pending solution
+// is flagged as a possible copy of an approved one
+const SimilarityThreshold = 0.6
+
+// SolutionCheckSimilarity stores the minhash signature of a pending solution
+// and compares it to the approved solutions of the same crackme. The closest
+// one is recorded on the solution (similarto, similarity) when it reaches
+// SimilarityThreshold, so the moderators see it in the queue.
+func SolutionCheckSimilarity(hexid string, sig similarity.Signature) (float64, error) {
+	var err error
+	var best float64
+
+	if sig == nil {
+		return 0, nil
+	}
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
+
+		var solution Solution
+		err = collection.FindOne(database.Ctx, bson.M{"hexid": hexid}).Decode(&solution)
+		if err != nil {
+			return 0, standardizeError(err)
+		}
+
+		var approved []struct {
+			HexId   string               `bson:"hexid"`
+			Minhash similarity.Signature `bson:"minhash"`
+		}
+		var cursor *mongo.Cursor
+		opts := options.Find().SetProjection(bson.M{"hexid": 1, "minhash": 1})
+		cursor, err = collection.Find(database.Ctx, bson.M{
+			"crackmeid": solution.CrackmeId,
+			"visible":   true,
+			"minhash":   bson.M{"$exists": true},
+		}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &approved)
+		}
+		if err != nil {
+			return 0, standardizeError(err)
+		}
+
+		set := bson.M{"minhash": sig}
+		for _, other := range approved {
+			if s := similarity.Similarity(sig, other.Minhash); s > best {
+				best = s
+				if s >= SimilarityThreshold {
+					set["similarto"] = other.HexId
+					set["similarity"] = s
+				}
+			}
+		}
+
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"hexid": hexid}, bson.M{"$set": set})
+	} else {
+		err = ErrUnavailable
+	}
+
+	return best, standardizeError(err)
+}
+
+// SolutionSignature returns the minhash signature of a writeup, from its info,
+// its sections and the text of its file
+func SolutionSignature(info string, sections WriteupSections, text string) similarity.Signature {
+	return similarity.Sign(strings.Join([]string{info, sections.Tools, sections.Approach, sections.Key, sections.Patch, text}, "\n"))
+}
+
+// SolutionMinhashAssign signs the visible solutions approved before they were
+// signed at upload, so SolutionCheckSimilarity compares the new ones to them.
+// The file of a solution is read from the storage, the ones missing from it
+// are left for the next run.
+func SolutionMinhashAssign() error {
+	var err error
+	var solutions []Solution
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
+		opts := options.Find().SetSort(bson.M{"created_at": 1}).SetProjection(bson.M{"hexid": 1, "info": 1, "sections": 1})
+		var cursor *mongo.Cursor
+		cursor, err = collection.Find(database.Ctx, bson.M{"visible": true, "minhash": bson.M{"$exists": false}}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &solutions)
+		}
+
+		for _, solution := range solutions {
+			if err != nil {
+				break
+			}
+			data, err2 := os.ReadFile(download.Path(download.KindSolution, solution.HexId))
+			if err2 != nil {
+				log.Println(err2)
+				continue
+			}
+			var sections WriteupSections
+			if solution.Sections != nil {
+				sections = *solution.Sections
+			}
+			// Empty for the writeups without text, so they aren't read again
+			sig := SolutionSignature(solution.Info, sections, similarity.StoredText(data, download.Password))
+			if sig == nil {
+				sig = similarity.Signature{}
+			}
+			_, err = collection.UpdateOne(database.Ctx, bson.M{"hexid": solution.HexId}, bson.M{"$set": bson.M{"minhash": sig}})
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// SolutionApprove makes a pending solution visible, counts it for its author
+// and its crackme, and fires the solution approval webhooks. validate.py
+// approves through it, see the -approve-solution flag.
+func SolutionApprove(hexid string) error {
+	var solution Solution
+	var crackme Crackme
+	var event Event
+
+	if !database.CheckConnection() {
+		return ErrUnavailable
+	}
+	db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+
+	// The solution, the counters, the notifications of its authors and of the
+	// author of the crackme, their attempts and the event are written
+	// together or not at all
+	err := withTransaction(func(ctx context.Context) error {
+		err := db.Collection("solution").FindOneAndUpdate(ctx,
+			bson.M{"hexid": hexid, "visible": false},
+			bson.M{"$set": bson.M{"visible": true}}).Decode(&solution)
+		if err != nil {
+			return err
+		}
+
+		for _, author := range solution.Authors() {
+			if _, err = db.Collection("user").UpdateOne(ctx, bson.M{"name": author}, bson.M{"$inc": bson.M{userCounterSolutions: 1}}); err != nil {
+				return err
+			}
+		}
+
+		langs, err := db.Collection("solution").Distinct(ctx, "language", bson.M{"crackmehexid": solution.CrackmeHexId, "visible": true})
+		if err != nil {
+			return err
+		}
+		if langs == nil {
+			langs = []interface{}{}
+		}
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		err = db.Collection("crackme").FindOneAndUpdate(ctx,
+			bson.M{"hexid": solution.CrackmeHexId},
+			bson.M{"$inc": bson.M{"nbsolutions": 1}, "$set": bson.M{"writeuplangs": langs, "updated_at": time.Now()}},
+			opts).Decode(&crackme)
+		if err != nil {
+			return err
+		}
+
+		for _, author := range solution.Authors() {
+			if err = notificationInsert(ctx, author, "Your writeup of '"+crackme.Name+"' has been approved!"); err != nil {
+				return err
+			}
+		}
+		if err = notificationInsert(ctx, crackme.Author, "A new solution for your crackme '"+crackme.Name+"' has been submitted by: "+solution.Author); err != nil {
+			return err
+		}
+
+		// Solved, no longer in progress
+		_, err = db.Collection("attempt").DeleteMany(ctx, bson.M{"user": bson.M{"$in": solution.Authors()}, "crackmehexid": solution.CrackmeHexId})
+		if err != nil {
+			return err
+		}
+
+		event = Event{Type: EventSolutionApproved, User: solution.Author, HexId: solution.HexId, CrackmeHexId: solution.CrackmeHexId, CreatedAt: time.Now()}
+		return eventInsert(ctx, event)
+	})
+	if err != nil {
+		return standardizeError(err)
+	}
+
+	eventDispatch(event)
+	webhook.Fire(webhook.Event{
+		Type:     webhook.EventSolutionApproved,
+		Crackme:  crackme.webhookInfo(),
+		Solution: &webhook.Solution{HexId: solution.HexId, Author: solution.Author},
+	})
+
+	return nil
+}
+
+// crackmeUpdateWriteupLangs stores on a crackme the languages of its visible
+// solutions, for the search
+func crackmeUpdateWriteupLangs(crackmehexid string) error {
+	var err error
+
+	if database.CheckConnection() {
+		db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+		var langs []interface{}
+		langs, err = db.Collection("solution").Distinct(database.Ctx, "language", bson.M{"crackmehexid": crackmehexid, "visible": true})
+		if langs == nil {
+			langs = []interface{}{}
+		}
+		if err == nil {
+			_, err = db.Collection("crackme").UpdateOne(database.Ctx, bson.M{"hexid": crackmehexid}, bson.M{"$set": bson.M{"writeuplangs": langs, "updated_at": time.Now()}})
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// SolutionDeleteByHexId deletes a solution by its hexid, and uncounts it for
+// its author and its crackme if it was visible
+func SolutionDeleteByHexId(hexid string) error {
+	var err error
+	var solution Solution
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
+		err = collection.FindOneAndDelete(database.Ctx, bson.M{"hexid": hexid}).Decode(&solution)
+		if err == nil && solution.Visible {
+			for _, author := range solution.Authors() {
+				if cerr := userIncrementCounter(author, userCounterSolutions, -1); cerr != nil {
+					log.Println("Failed to decrement solution counter:", cerr)
+				}
+			}
+			if cerr := CrackmeDecrementSolutions(solution.CrackmeHexId); cerr != nil {
+				log.Println("Failed to decrement solution count:", cerr)
+			}
+			crackmes := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+			if _, cerr := crackmes.UpdateOne(database.Ctx, bson.M{"hexid": solution.CrackmeHexId, "pick": hexid}, bson.M{"$unset": bson.M{"pick": ""}}); cerr != nil {
+				log.Println("Failed to remove the author's pick:", cerr)
+			}
+			if cerr := crackmeUpdateWriteupLangs(solution.CrackmeHexId); cerr != nil {
+				log.Println("Failed to update the writeup languages:", cerr)
+			}
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// SolutionsPendingByUser returns the solutions of username waiting for
+// approval posted since a date, newest first
+func SolutionsPendingByUser(username string, since time.Time) ([]Solution, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []Solution{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
+		opts := options.Find().SetSort(bson.D{{"created_at", -1}})
+		cursor, err = collection.Find(database.Ctx, bson.M{"author": username, "visible": false, "deleted": false, "created_at": bson.M{"$gte": since}}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// SolutionPendingEdit replaces the description of a solution of username
+// waiting for approval, posted since a date
+func SolutionPendingEdit(hexid, username, info string, since time.Time) error {
+	var err error
+	var res *mongo.UpdateResult
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
+		res, err = collection.UpdateOne(database.Ctx,
+			bson.M{"hexid": hexid, "author": username, "visible": false, "deleted": false, "created_at": bson.M{"$gte": since}},
+			bson.M{"$set": bson.M{"info": info}})
+		if err == nil && res.MatchedCount == 0 {
+			err = ErrNoResult
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// SolutionWithdraw deletes a solution of username waiting for approval,
+// posted since a date, and returns it
+func SolutionWithdraw(hexid, username string, since time.Time) (Solution, error) {
+	var err error
+	var solution Solution
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
+		err = collection.FindOneAndDelete(database.Ctx,
+			bson.M{"hexid": hexid, "author": username, "visible": false, "deleted": false, "created_at": bson.M{"$gte": since}}).Decode(&solution)
+	} else {
+		err = ErrUnavailable
+	}
+
+	return solution, standardizeError(err)
+}
+
+// *****************************************************************************
+// Writeup search
+// *****************************************************************************
+
+// WriteupSearchMax is the maximum number of writeups found by WriteupSearch
+const WriteupSearchMax = 50
+
+// WriteupMatch is a writeup found by WriteupSearch
+type WriteupMatch struct {
+	Solution      `bson:",inline"`
+	CrackmeAuthor string  `bson:"crackmeauthor"`
+	Score         float64 `bson:"score"`
+}
+
+// WriteupSearchEnsureIndexes creates the text index of the writeups, on their
+// info and their sections but the key, which is a spoiler
+func WriteupSearchEnsureIndexes() error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
+		_, err = collection.Indexes().CreateOne(database.Ctx, mongo.IndexModel{
+			Keys: bson.D{{"info", "text"}, {"sections.tools", "text"}, {"sections.approach", "text"}, {"sections.patch", "text"}},
+			Options: options.Index().SetName("writeup_text").
+				SetWeights(bson.M{"info": 3, "sections.approach": 2, "sections.tools": 1, "sections.patch": 1}),
+		})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// WriteupSearch returns the best WriteupSearchMax approved writeups matching
+// a text search, of the listed crackmes only
+func WriteupSearch(query string) ([]WriteupMatch, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []WriteupMatch{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
+		pipeline := mongo.Pipeline{
+			{{"$match", bson.M{"$text": bson.M{"$search": query}, "visible": true, "deleted": bson.M{"$ne": true}, "broken": bson.M{"$ne": true}}}},
+			{{"$addFields", bson.M{"score": bson.M{"$meta": "textScore"}}}},
+			{{"$sort", bson.D{{"score", -1}}}},
+			{{"$limit", WriteupSearchMax * 4}},
+			{{"$lookup", bson.M{"from": "crackme", "localField": "crackmehexid", "foreignField": "hexid", "as": "crackme"}}},
+			{{"$match", bson.M{
+				"crackme.visible":    true,
+				"crackme.deleted":    bson.M{"$ne": true},
+				"crackme.visibility": bson.M{"$ne": VisibilityUnlisted},
+			}}},
+			{{"$limit", WriteupSearchMax}},
+			{{"$addFields", bson.M{
+				"crackmename":   bson.M{"$arrayElemAt": bson.A{"$crackme.name", 0}},
+				"crackmeauthor": bson.M{"$arrayElemAt": bson.A{"$crackme.author", 0}},
+			}}},
+			{{"$project", bson.M{"crackme": 0}}},
+		}
+		cursor, err = collection.Aggregate(database.Ctx, pipeline)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// *****************************************************************************
+// Solves
+// *****************************************************************************
+
+// Solve is an approved solution of a user with the crackme it solves
+type Solve struct {
+	SolutionHexId string    `bson:"hexid"`
+	CrackmeHexId  string    `bson:"crackmehexid"`
+	CrackmeName   string    `bson:"crackmename"`
+	CrackmeAuthor string    `bson:"crackmeauthor"`
+	Difficulty    float64   `bson:"difficulty"`
+	SolvedAt      time.Time `bson:"created_at"`
+}
+
+// SolvesByUser returns the approved solutions a user wrote or co-wrote until
+// a date, the latest first, with the difficulty of their crackmes. The
+// solutions of the unlisted crackmes are left out, their hexid is their
+// secret link.
+func SolvesByUser(username string, until time.Time) ([]Solve, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []Solve{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
+		match := authoredBy(username)
+		match["visible"] = true
+		match["deleted"] = bson.M{"$ne": true}
+		match["created_at"] = bson.M{"$lte": until}
+		pipeline := mongo.Pipeline{
+			{{"$match", match}},
+			{{"$sort", bson.D{{"created_at", -1}}}},
+		}
+		pipeline = append(pipeline, ScopeListed.crackmeStages()...)
+		pipeline = append(pipeline, mongo.Pipeline{
+			{{"$lookup", bson.M{"from": "crackme", "localField": "crackmehexid", "foreignField": "hexid", "as": "crackme"}}},
+			{{"$project", bson.M{
+				"hexid":         1,
+				"crackmehexid":  1,
+				"created_at":    1,
+				"crackmename":   bson.M{"$arrayElemAt": bson.A{"$crackme.name", 0}},
+				"crackmeauthor": bson.M{"$arrayElemAt": bson.A{"$crackme.author", 0}},
+				"difficulty":    bson.M{"$arrayElemAt": bson.A{"$crackme.difficulty", 0}},
+			}}},
+		}...)
+		cursor, err = collection.Aggregate(database.Ctx, pipeline)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
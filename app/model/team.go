@@ -0,0 +1,312 @@
+package model
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Team
+// *****************************************************************************
+
+var (
+	// ErrTeamName is returned for the names teams can't be created with
+	ErrTeamName = errors.New("The name of a team has 3 to 32 letters, digits, - or _.")
+	// ErrTeamExists is returned when a team of the same name exists
+	ErrTeamExists = errors.New("A team of this name already exists.")
+	// ErrNotInvited is returned when joining a team without an invitation
+	ErrNotInvited = errors.New("You have not been invited to this team.")
+)
+
+// teamNameRe matches the names teams can be created with
+var teamNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{3,32}$`)
+
+// Team is a group of users, like a CTF team, the crackmes and solutions can be
+// posted in the name of
+type Team struct {
+	ObjectId    primitive.ObjectID `bson:"_id,omitempty"`
+	Name        string             `bson:"name"`
+	Key         string             `bson:"key"` // Lowercase name, unique
+	Owner       string             `bson:"owner"`
+	Members     []string           `bson:"members"`           // The owner included
+	Invites     []string           `bson:"invites,omitempty"` // Invited users who have not joined yet
+	CreatedAt   time.Time          `bson:"created_at"`
+	NbCrackmes  int                `bson:"nbcrackmes,omitempty"`  // Filled in by TeamsRanking
+	NbSolutions int                `bson:"nbsolutions,omitempty"` // Filled in by TeamsRanking
+}
+
+// HasMember tells if a user is a member of the team
+func (t Team) HasMember(username string) bool {
+	for _, m := range t.Members {
+		if m == username {
+			return true
+		}
+	}
+	return false
+}
+
+// IsInvited tells if a user has been invited to the team
+func (t Team) IsInvited(username string) bool {
+	for _, m := range t.Invites {
+		if m == username {
+			return true
+		}
+	}
+	return false
+}
+
+// TeamEnsureIndexes creates the unique index of the team names, whatever
+// their case
+func TeamEnsureIndexes() error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("team")
+		_, err = collection.Indexes().CreateOne(database.Ctx, mongo.IndexModel{
+			Keys:    bson.D{{"key", 1}},
+			Options: options.Index().SetUnique(true).SetName("one_name"),
+		})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// TeamCreate creates a team owned by a user, its first member
+func TeamCreate(name, owner string) (Team, error) {
+	team := Team{
+		ObjectId:  primitive.NewObjectID(),
+		Name:      name,
+		Key:       strings.ToLower(name),
+		Owner:     owner,
+		Members:   []string{owner},
+		CreatedAt: time.Now(),
+	}
+	if !teamNameRe.MatchString(name) {
+		return team, ErrTeamName
+	}
+
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("team")
+		_, err = collection.InsertOne(database.Ctx, team)
+		if mongo.IsDuplicateKeyError(err) {
+			err = ErrTeamExists
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return team, standardizeError(err)
+}
+
+// TeamByName returns a team, whatever the case of its name
+func TeamByName(name string) (Team, error) {
+	var err error
+	var result Team
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("team")
+		err = collection.FindOne(database.Ctx, bson.M{"key": strings.ToLower(name)}).Decode(&result)
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// TeamsByMember returns the teams of a user, by name
+func TeamsByMember(username string) ([]Team, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []Team{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("team")
+		opts := options.Find().SetSort(bson.D{{"key", 1}})
+		cursor, err = collection.Find(database.Ctx, bson.M{"members": username}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// TeamInvite invites a user to a team of the owner
+func TeamInvite(name, owner, username string) error {
+	var err error
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("team")
+		var result *mongo.UpdateResult
+		result, err = collection.UpdateOne(database.Ctx,
+			bson.M{"key": strings.ToLower(name), "owner": owner, "members": bson.M{"$ne": username}},
+			bson.M{"$addToSet": bson.M{"invites": username}})
+		if err == nil && result.MatchedCount == 0 {
+			err = ErrNoResult
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// TeamJoin makes an invited user a member of a team
+func TeamJoin(name, username string) error {
+	var err error
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("team")
+		var result *mongo.UpdateResult
+		result, err = collection.UpdateOne(database.Ctx,
+			bson.M{"key": strings.ToLower(name), "invites": username},
+			bson.M{"$pull": bson.M{"invites": username}, "$addToSet": bson.M{"members": username}})
+		if err == nil && result.MatchedCount == 0 {
+			err = ErrNotInvited
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// TeamLeave removes a member from a team, or declines an invitation. The
+// owner stays, the crackmes and solutions posted for the team stay with it.
+func TeamLeave(name, username string) error {
+	var err error
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("team")
+		var result *mongo.UpdateResult
+		result, err = collection.UpdateOne(database.Ctx,
+			bson.M{"key": strings.ToLower(name), "owner": bson.M{"$ne": username}},
+			bson.M{"$pull": bson.M{"members": username, "invites": username}})
+		if err == nil && result.MatchedCount == 0 {
+			err = ErrNoResult
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
+
+// teamCount joins the number of documents of a collection posted for the
+// team and matching visible
+func teamCount(from, as string, visible bson.D) bson.D {
+	return bson.D{{"$lookup", bson.D{
+		{"from", from},
+		{"let", bson.D{{"name", "$name"}}},
+		{"pipeline", mongo.Pipeline{
+			{{"$match", append(bson.D{
+				{"$expr", bson.D{{"$eq", bson.A{"$team", "$$name"}}}},
+			}, visible...)}},
+			{{"$count", "n"}},
+		}},
+		{"as", as},
+	}}}
+}
+
+// TeamsRanking returns the teams with their number of crackmes and solutions,
+// the most solutions first
+func TeamsRanking() ([]Team, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []Team{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("team")
+		pipeline := mongo.Pipeline{
+			teamCount("crackme", "crackmes", ScopeListed.match()),
+			teamCount("solution", "solutions", bson.D{{"visible", true}}),
+			{{"$addFields", bson.D{
+				{"nbcrackmes", bson.D{{"$ifNull", bson.A{bson.D{{"$arrayElemAt", bson.A{"$crackmes.n", 0}}}, 0}}}},
+				{"nbsolutions", bson.D{{"$ifNull", bson.A{bson.D{{"$arrayElemAt", bson.A{"$solutions.n", 0}}}, 0}}}},
+			}}},
+			{{"$sort", bson.D{{"nbsolutions", -1}, {"nbcrackmes", -1}, {"key", 1}}}},
+		}
+		cursor, err = collection.Aggregate(database.Ctx, pipeline)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// CrackmesByTeam returns the public crackmes posted for a team, newest first
+func CrackmesByTeam(name string) ([]Crackme, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []Crackme{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		opts := options.Find().SetSort(bson.D{{"created_at", -1}})
+		cursor, err = collection.Find(database.Ctx, ScopeListed.filter(bson.M{"team": name}), opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// SolutionsByTeam returns the visible solutions posted for a team, newest
+// first
+func SolutionsByTeam(name string) ([]Solution, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []Solution{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
+		opts := options.Find().SetSort(bson.D{{"created_at", -1}})
+		cursor, err = collection.Find(database.Ctx, bson.M{"team": name, "visible": true}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// TeamActivity returns the latest visible solutions of the members of a team,
+// posted for the team or not, newest first
+func TeamActivity(team Team, limit int) ([]Solution, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []Solution{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("solution")
+		opts := options.Find().SetSort(bson.D{{"created_at", -1}}).SetLimit(int64(limit))
+		cursor, err = collection.Find(database.Ctx, bson.M{"author": bson.M{"$in": team.Members}, "visible": true}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
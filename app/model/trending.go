@@ -0,0 +1,186 @@
+package model
+
+import (
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"github.com/crackmesone/crackmes.one/app/shared/trending"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Trending
+// *****************************************************************************
+
+// TrendingMax is the number of crackmes ranked by TrendingRun
+const TrendingMax = 10
+
+// trendingDay is the layout of the days of the daily counters
+const trendingDay = "2006-01-02"
+
+// CrackmeDaily table counts the views and the downloads of a crackme on a
+// day, the activity the events don't record
+type CrackmeDaily struct {
+	HexId     string    `bson:"hexid"`
+	Day       string    `bson:"day"`  // UTC, see trendingDay
+	Date      time.Time `bson:"date"` // Start of the day, the counters expire from it
+	Views     int       `bson:"views"`
+	Downloads int       `bson:"downloads"`
+}
+
+// Trending table holds the latest ranking computed by TrendingRun, shown on
+// the home page
+type Trending struct {
+	ObjectId  primitive.ObjectID `bson:"_id,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+	Entries   []trending.Entry   `bson:"entries"`
+}
+
+// TrendingEnsureIndexes creates the unique index of the daily counters, and
+// the one removing them once older than the longest activity window
+func TrendingEnsureIndexes() error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme_daily")
+		_, err = collection.Indexes().CreateMany(database.Ctx, []mongo.IndexModel{
+			{
+				Keys:    bson.D{{"day", 1}, {"hexid", 1}},
+				Options: options.Index().SetUnique(true).SetName("one_day"),
+			},
+			{
+				Keys:    bson.D{{"date", 1}},
+				Options: options.Index().SetExpireAfterSeconds((trending.MaxDays + 1) * 24 * 3600).SetName("expiry"),
+			},
+		})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// crackmeDailyIncrement counts a view or a download of a crackme today
+func crackmeDailyIncrement(hexid, counter string) error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme_daily")
+		day := time.Now().UTC().Truncate(24 * time.Hour)
+		_, err = collection.UpdateOne(database.Ctx,
+			bson.M{"hexid": hexid, "day": day.Format(trendingDay)},
+			bson.M{"$inc": bson.M{counter: 1}, "$setOnInsert": bson.M{"date": day}},
+			options.Update().SetUpsert(true))
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// CrackmeCountView counts a view of the page of a crackme
+func CrackmeCountView(hexid string) error {
+	return crackmeDailyIncrement(hexid, "views")
+}
+
+// TrendingRun ranks the crackmes by their views, downloads, approved
+// writeups and comments of the last days, the recent ones counting more, and
+// records the ranking in place of the previous ones
+func TrendingRun(c trending.Info) error {
+	now := time.Now()
+	since := now.Add(-c.Window())
+
+	if !database.CheckConnection() {
+		return ErrUnavailable
+	}
+	db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+
+	var activities []trending.Activity
+
+	var daily []CrackmeDaily
+	cursor, err := db.Collection("crackme_daily").Find(database.Ctx, bson.M{"day": bson.M{"$gte": since.UTC().Format(trendingDay)}})
+	if err == nil {
+		err = cursor.All(database.Ctx, &daily)
+	}
+	if err != nil {
+		return standardizeError(err)
+	}
+	for _, d := range daily {
+		day, err := time.Parse(trendingDay, d.Day)
+		if err != nil {
+			continue
+		}
+		// The actions of a day are counted at its middle
+		at := day.Add(12 * time.Hour)
+		activities = append(activities,
+			trending.Activity{HexId: d.HexId, Kind: trending.KindView, At: at, Count: d.Views},
+			trending.Activity{HexId: d.HexId, Kind: trending.KindDownload, At: at, Count: d.Downloads})
+	}
+
+	events, err := EventsSince(since, EventSolutionApproved, EventCommentPosted)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		kind := trending.KindSolution
+		if e.Type == EventCommentPosted {
+			kind = trending.KindComment
+		}
+		activities = append(activities, trending.Activity{HexId: e.CrackmeHexId, Kind: kind, At: e.CreatedAt, Count: 1})
+	}
+
+	// Rank more than shown, the crackmes unlisted since are left out when read
+	ranking := Trending{
+		ObjectId:  primitive.NewObjectID(),
+		CreatedAt: now,
+		Entries:   trending.Rank(activities, now, c.Decay(), 2*TrendingMax),
+	}
+	if _, err = db.Collection("trending").InsertOne(database.Ctx, ranking); err != nil {
+		return standardizeError(err)
+	}
+	_, err = db.Collection("trending").DeleteMany(database.Ctx, bson.M{"_id": bson.M{"$ne": ranking.ObjectId}})
+	return standardizeError(err)
+}
+
+// TrendingCrackmes returns at most n listed crackmes of the latest ranking,
+// highest first. There are none until the first ranking.
+func TrendingCrackmes(n int) ([]Crackme, error) {
+	var err error
+	var ranking Trending
+
+	result := []Crackme{}
+	if !database.CheckConnection() {
+		return result, ErrUnavailable
+	}
+
+	opts := options.FindOne().SetSort(bson.D{{"created_at", -1}})
+	err = database.Listing("trending").FindOne(database.Ctx, bson.M{}, opts).Decode(&ranking)
+	if err == mongo.ErrNoDocuments {
+		return result, nil
+	} else if err != nil {
+		return result, standardizeError(err)
+	}
+
+	hexids := make([]string, len(ranking.Entries))
+	for i, e := range ranking.Entries {
+		hexids[i] = e.HexId
+	}
+	var crackmes []Crackme
+	cursor, err := database.Listing("crackme").Find(database.Ctx, ScopeListed.filter(bson.M{"hexid": bson.M{"$in": hexids}}))
+	if err == nil {
+		err = cursor.All(database.Ctx, &crackmes)
+	}
+	if err != nil {
+		return result, standardizeError(err)
+	}
+
+	byHexId := make(map[string]Crackme, len(crackmes))
+	for _, c := range crackmes {
+		byHexId[c.HexId] = c
+	}
+	for _, hexid := range hexids {
+		if c, ok := byHexId[hexid]; ok && len(result) < n {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Unique submissions
+// *****************************************************************************
+
+var (
+	// ErrSolutionExists is returned when the user already submitted a
+	// solution to the crackme
+	ErrSolutionExists = errors.New("You've already submitted a solution to this crackme")
+	// ErrCrackmeExists is returned when the user already has a crackme of the
+	// same name waiting for approval
+	ErrCrackmeExists = errors.New("You already have a pending crackme with this name. Please wait for review or choose a different name.")
+)
+
+// SubmissionEnsureIndexes creates the unique indexes allowing one solution
+// per user and crackme, and one crackme of each name per user waiting for
+// approval. The controllers check first, the indexes stop the concurrent
+// uploads passing the check together. The votes have theirs, see
+// RatingEnsureIndexes. It fails while the collections hold duplicates.
+func SubmissionEnsureIndexes() error {
+	var err error
+	if database.CheckConnection() {
+		db := database.Mongo.Database(database.ReadConfig().MongoDB.Database)
+		_, err = db.Collection("solution").Indexes().CreateOne(database.Ctx, mongo.IndexModel{
+			Keys:    bson.D{{"crackmeid", 1}, {"author", 1}},
+			Options: options.Index().SetUnique(true).SetName("one_solution"),
+		})
+		if err == nil {
+			_, err = db.Collection("crackme").Indexes().CreateOne(database.Ctx, mongo.IndexModel{
+				Keys: bson.D{{"author", 1}, {"name", 1}},
+				Options: options.Index().SetUnique(true).SetName("one_pending_name").
+					SetPartialFilterExpression(bson.M{"visible": false}),
+			})
+		}
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// duplicateError returns exists in place of a duplicate key error
+func duplicateError(err, exists error) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return exists
+	}
+	return err
+}
@@ -7,6 +7,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"regexp"
 	"time"
 )
 
@@ -23,16 +24,51 @@ type User struct {
 	Password    string             `bson:"password,omitempty"`
 	Visible     bool               `bson:"visible"`
 	Deleted     bool               `bson:"deleted"`
-	NbCrackmes  int
-	NbSolutions int
-	NbComments  int
+	NbCrackmes  int                `bson:"nbcrackmes"`
+	NbSolutions int                `bson:"nbsolutions"`
+	NbComments  int                `bson:"nbcomments"`
+	Role        string             `bson:"role,omitempty"`
+	Digest      *DigestPrefs       `bson:"digest,omitempty"`
+	Listing     *ListingPrefs      `bson:"listing,omitempty"`
+	Clock       *ClockPrefs        `bson:"clock,omitempty"`
+	SpoilerFree bool               `bson:"spoilerfree"`   // Hide the writeups of the crackmes not solved yet
+	SignInEmail bool               `bson:"signinemail"`   // Email about the sign-ins from new devices
+	Rev         int                `bson:"rev,omitempty"` // Revision of the document, see UserUpdateRev
 }
 
+// ClockPrefs are how a user wants the dates shown
+type ClockPrefs struct {
+	Timezone string `bson:"timezone"` // IANA name, UTC when empty
+	Relative bool   `bson:"relative"` // "2 hours ago" for the recent dates
+}
+
+// RoleAdmin is the role of the users allowed in the admin pages
+const RoleAdmin = "admin"
+
+// Names of the per-user counters stored on the user document. They only count
+// visible content and are maintained by the model functions creating,
+// approving and deleting it.
+const (
+	userCounterCrackmes  = "nbcrackmes"
+	userCounterSolutions = "nbsolutions"
+	userCounterComments  = "nbcomments"
+)
+
 // Username returns the user name
 func (u *User) Username() string {
 	return u.Name
 }
 
+// IsAdmin returns true if the user has the admin role
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// Reputation is the number of approved crackmes and writeups of the user
+func (u *User) Reputation() int {
+	return u.NbCrackmes + u.NbSolutions
+}
+
 // CountUsers returns the total number of users in the collection.
 //
 // Performance optimization: Uses EstimatedDocumentCount() which reads from
@@ -46,7 +82,7 @@ func CountUsers() (int, error) {
 	var err error
 	var nb int64
 	if database.CheckConnection() {
-		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("user")
+		collection := database.Listing("user")
 		nb, err = collection.EstimatedDocumentCount(database.Ctx)
 	} else {
 		err = ErrUnavailable
@@ -55,7 +91,23 @@ func CountUsers() (int, error) {
 	return int(nb), standardizeError(err)
 }
 
-// UserByEmail gets user information from email
+// UserSolvesRank returns the rank of a user by number of approved
+// solutions, ties sharing the same rank
+func UserSolvesRank(nbsolutions int) (int, error) {
+	var err error
+	var nb int64
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("user")
+		nb, err = collection.CountDocuments(database.Ctx, bson.M{"deleted": bson.M{"$ne": true}, "nbsolutions": bson.M{"$gt": nbsolutions}})
+	} else {
+		err = ErrUnavailable
+	}
+
+	return int(nb) + 1, standardizeError(err)
+}
+
+// UserByName gets a user by their name, case insensitively. The name is
+// matched as is, never as a pattern.
 func UserByName(name string) (User, error) {
 	var err error
 
@@ -63,7 +115,7 @@ func UserByName(name string) (User, error) {
 
 	if database.CheckConnection() {
 		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("user")
-		err = collection.FindOne(database.Ctx, bson.M{"name": primitive.Regex{Pattern: "^" + name + "$", Options: "i"}}).Decode(&result)
+		err = collection.FindOne(database.Ctx, bson.M{"name": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(name) + "$", Options: "i"}}).Decode(&result)
 	} else {
 		err = ErrUnavailable
 	}
@@ -78,7 +130,7 @@ func UserByMail(email string) (User, error) {
 
 	if database.CheckConnection() {
 		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("user")
-		err = collection.FindOne(database.Ctx, bson.M{"email": primitive.Regex{Pattern: "^" + email + "$", Options: "i"}}).Decode(&result)
+		err = collection.FindOne(database.Ctx, bson.M{"email": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(email) + "$", Options: "i"}}).Decode(&result)
 	} else {
 		err = ErrUnavailable
 	}
@@ -119,6 +171,129 @@ func AllUsersVisible() ([]User, error) {
 	return result, err
 }
 
+// UsersPerPage is the number of users shown on one page of the directory
+const UsersPerPage = 50
+
+// usersDirectorySorts maps the sort keys accepted by the users directory to
+// the fields of the aggregated user documents
+var usersDirectorySorts = map[string]bson.D{
+	"name":      {{"name", 1}},
+	"crackmes":  {{"nbcrackmes", -1}, {"name", 1}},
+	"solutions": {{"nbsolutions", -1}, {"name", 1}},
+	"joined":    {{"_id", -1}},
+}
+
+// usersDirectoryFilter matches the visible users whose name contains search
+func usersDirectoryFilter(search string) bson.M {
+	filter := bson.M{"visible": true}
+	if search != "" {
+		filter["name"] = primitive.Regex{Pattern: regexp.QuoteMeta(search), Options: "i"}
+	}
+	return filter
+}
+
+// UsersDirectory returns one page of the visible users whose name contains
+// search, with their number of crackmes, solutions and comments filled in.
+// sortBy is one of "name", "crackmes", "solutions" or "joined".
+//
+// The counts are read from the counters stored on the user documents, so no
+// other collection is queried.
+func UsersDirectory(search, sortBy string, page int) ([]User, error) {
+	var err error
+	var cursor *mongo.Cursor
+	result := []User{}
+
+	sort, ok := usersDirectorySorts[sortBy]
+	if !ok {
+		sort = usersDirectorySorts["name"]
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	if database.CheckConnection() {
+		collection := database.Listing("user")
+
+		pipeline := mongo.Pipeline{
+			{{"$match", usersDirectoryFilter(search)}},
+			{{"$project", bson.D{{"password", 0}, {"email", 0}}}},
+			{{"$sort", sort}},
+			{{"$skip", int64((page - 1) * UsersPerPage)}},
+			{{"$limit", int64(UsersPerPage)}},
+		}
+
+		cursor, err = collection.Aggregate(database.Ctx, pipeline)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// CountUsersDirectory returns the number of visible users whose name contains search
+func CountUsersDirectory(search string) (int, error) {
+	var err error
+	var nb int64
+	if database.CheckConnection() {
+		collection := database.Listing("user")
+		nb, err = collection.CountDocuments(database.Ctx, usersDirectoryFilter(search))
+	} else {
+		err = ErrUnavailable
+	}
+
+	return int(nb), standardizeError(err)
+}
+
+// userIncrementCounter adds n to one of the userCounter* fields of a user
+func userIncrementCounter(username, counter string, n int) error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("user")
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"name": username}, bson.M{"$inc": bson.M{counter: n}})
+	} else {
+		err = ErrUnavailable
+	}
+	return err
+}
+
+// UserSetCounters overwrites the stored counters of a user, used by the
+// recount job to repair drift
+func UserSetCounters(username string, nbcrackmes, nbsolutions, nbcomments int) error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("user")
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"name": username}, bson.M{"$set": bson.M{
+			userCounterCrackmes:  nbcrackmes,
+			userCounterSolutions: nbsolutions,
+			userCounterComments:  nbcomments,
+		}})
+	} else {
+		err = ErrUnavailable
+	}
+	return err
+}
+
+// UserRecountCounters recounts the visible crackmes, solutions and comments
+// of a user and stores the result on the user document
+func UserRecountCounters(username string) error {
+	nbcrackmes, err := CountCrackmesByUser(username)
+	if err != nil {
+		return err
+	}
+	nbsolutions, err := CountSolutionsByUser(username)
+	if err != nil {
+		return err
+	}
+	nbcomments, err := CountCommentsByUser(username)
+	if err != nil {
+		return err
+	}
+	return UserSetCounters(username, nbcrackmes, nbsolutions, nbcomments)
+}
+
 // UserCreate creates user
 func UserCreate(name, email, password string) error {
 	var err error
@@ -137,6 +312,9 @@ func UserCreate(name, email, password string) error {
 			Deleted:  false,
 		}
 		_, err = collection.InsertOne(database.Ctx, user)
+		if err == nil {
+			EventEmit(Event{Type: EventUserRegistered, User: name})
+		}
 	} else {
 		err = ErrUnavailable
 	}
@@ -185,3 +363,27 @@ func UpdateUserPassword(username string, hashedPassword string) error {
 
 	return nil
 }
+
+// UserSetSpoilerFree turns the spoiler-free mode of a user on or off
+func UserSetSpoilerFree(username string, on bool) error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("user")
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"name": username}, bson.M{"$set": bson.M{"spoilerfree": on}})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
+
+// UserSetClock stores how a user wants the dates shown
+func UserSetClock(username string, prefs ClockPrefs) error {
+	var err error
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("user")
+		_, err = collection.UpdateOne(database.Ctx, bson.M{"name": username}, bson.M{"$set": bson.M{"clock": prefs}})
+	} else {
+		err = ErrUnavailable
+	}
+	return standardizeError(err)
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/archive"
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+	"github.com/crackmesone/crackmes.one/app/shared/exeinfo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// *****************************************************************************
+// Version
+// *****************************************************************************
+
+// CrackmeVersion is an uploaded version of a crackme. A new version waits for
+// the moderators like a new crackme, with the binary informations and the
+// files it will replace on the crackme once approved.
+type CrackmeVersion struct {
+	Number    int             `bson:"number"`
+	SHA256    string          `bson:"sha256,omitempty"`
+	Size      int64           `bson:"size,omitempty"`
+	Format    string          `bson:"format,omitempty"`
+	Changelog string          `bson:"changelog,omitempty"`
+	CreatedAt time.Time       `bson:"created_at"`
+	Pending   bool            `bson:"pending"`
+	Binary    *exeinfo.Info   `bson:"binary,omitempty"`
+	Files     []archive.Entry `bson:"files,omitempty"`
+	Readme    string          `bson:"readme,omitempty"`
+}
+
+// CurrentVersion returns the number of the approved version of the crackme,
+// the crackmes uploaded before the versions existed are at version 1
+func (c *Crackme) CurrentVersion() int {
+	if c.Version == 0 {
+		return 1
+	}
+	return c.Version
+}
+
+// PendingVersion returns the version of the crackme waiting for approval, if
+// any
+func (c *Crackme) PendingVersion() *CrackmeVersion {
+	for i := range c.Versions {
+		if c.Versions[i].Pending {
+			return &c.Versions[i]
+		}
+	}
+	return nil
+}
+
+// CrackmeAddVersion adds a version waiting for approval to a crackme, unless
+// one is already waiting, in which case it returns ErrNoResult. The first
+// version of the crackmes uploaded before the versions existed is recorded
+// from the crackme.
+func CrackmeAddVersion(crackme Crackme, version CrackmeVersion) error {
+	var err error
+
+	versions := []CrackmeVersion{}
+	if len(crackme.Versions) == 0 {
+		versions = append(versions, CrackmeVersion{Number: 1, CreatedAt: crackme.CreatedAt})
+	}
+	version.Number = len(crackme.Versions) + len(versions) + 1
+	version.Pending = true
+	versions = append(versions, version)
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		var res *mongo.UpdateResult
+		res, err = collection.UpdateOne(database.Ctx,
+			bson.M{"hexid": crackme.HexId, "visible": true, "versions.pending": bson.M{"$ne": true}},
+			bson.M{"$push": bson.M{"versions": bson.M{"$each": versions}}, "$set": bson.M{"updated_at": time.Now()}})
+		if err == nil && res.MatchedCount == 0 {
+			err = ErrNoResult
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
@@ -0,0 +1,122 @@
+package model
+
+import (
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// *****************************************************************************
+// Visibility
+// *****************************************************************************
+
+// Visibility is who finds an approved crackme
+type Visibility string
+
+const (
+	// VisibilityPublic crackmes are listed, searched and in the feeds, the
+	// crackmes stored without a visibility are public
+	VisibilityPublic Visibility = ""
+	// VisibilityUnlisted crackmes are only reached by their link, for private
+	// training groups
+	VisibilityUnlisted Visibility = "unlisted"
+)
+
+// ParseVisibility returns the visibility of a form value, public if empty
+func ParseVisibility(s string) (Visibility, bool) {
+	switch v := Visibility(s); v {
+	case VisibilityPublic, VisibilityUnlisted:
+		return v, true
+	}
+	return VisibilityPublic, false
+}
+
+// Scope is which of the approved crackmes a query finds
+type Scope int
+
+const (
+	// ScopeListed finds the public crackmes which can be downloaded: listings,
+	// search and feeds
+	ScopeListed Scope = iota
+	// ScopeLinked also finds the unlisted crackmes, reached by their link
+	ScopeLinked
+)
+
+// filter adds the scope to a filter of crackmes and returns it
+func (s Scope) filter(f bson.M) bson.M {
+	f["visible"] = true
+	if s == ScopeListed {
+		f["visibility"] = bson.M{"$ne": VisibilityUnlisted}
+		f["broken"] = bson.M{"$ne": true}
+	}
+	return f
+}
+
+// match returns the scope as the elements of an ordered filter
+func (s Scope) match() bson.D {
+	d := bson.D{{"visible", true}}
+	if s == ScopeListed {
+		d = append(d, bson.E{"visibility", bson.M{"$ne": VisibilityUnlisted}}, bson.E{"broken", bson.M{"$ne": true}})
+	}
+	return d
+}
+
+// crackmeStages returns the aggregation stages keeping the documents of
+// their crackme, the solutions and the comments by their crackmehexid, when
+// that crackme is in the scope
+func (s Scope) crackmeStages() []bson.D {
+	match := bson.D{}
+	for _, e := range s.match() {
+		match = append(match, bson.E{"scope." + e.Key, e.Value})
+	}
+	return []bson.D{
+		{{"$lookup", bson.D{{"from", "crackme"}, {"localField", "crackmehexid"}, {"foreignField", "hexid"}, {"as", "scope"}}}},
+		{{"$match", match}},
+		{{"$project", bson.D{{"scope", 0}}}},
+	}
+}
+
+// CrackmesUnlistedByUser returns the unlisted crackmes of a user, newest
+// first, shown only on their own profile
+func CrackmesUnlistedByUser(username string) ([]Crackme, error) {
+	var err error
+	var cursor *mongo.Cursor
+
+	result := []Crackme{}
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		opts := options.Find().SetSort(bson.D{{"created_at", -1}})
+		cursor, err = collection.Find(database.Ctx, bson.M{"author": username, "visible": true, "visibility": VisibilityUnlisted}, opts)
+		if err == nil {
+			err = cursor.All(database.Ctx, &result)
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return result, standardizeError(err)
+}
+
+// CrackmeSetVisibility changes the visibility of a crackme of a user
+func CrackmeSetVisibility(hexid, username string, visibility Visibility) error {
+	var err error
+
+	if database.CheckConnection() {
+		collection := database.Mongo.Database(database.ReadConfig().MongoDB.Database).Collection("crackme")
+		var result *mongo.UpdateResult
+		result, err = collection.UpdateOne(database.Ctx,
+			bson.M{"hexid": hexid, "author": username},
+			bson.M{"$set": bson.M{"visibility": visibility, "updated_at": time.Now()}})
+		if err == nil && result.MatchedCount == 0 {
+			err = ErrNoResult
+		}
+	} else {
+		err = ErrUnavailable
+	}
+
+	return standardizeError(err)
+}
@@ -1,8 +1,10 @@
 package acl
 
 import (
+	"fmt"
 	"net/http"
 
+	"github.com/crackmesone/crackmes.one/app/model"
 	"github.com/crackmesone/crackmes.one/app/shared/session"
 )
 
@@ -37,3 +39,26 @@ func DisallowAnon(h http.Handler) http.Handler {
 		h.ServeHTTP(w, r)
 	})
 }
+
+// AdminOnly only allows the users with the admin role to access the page. The
+// role is read from the database on every request, so revoking it takes effect
+// immediately.
+func AdminOnly(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Get session
+		sess := session.Instance(r)
+
+		if sess.Values["name"] == nil {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+
+		user, err := model.UserByName(fmt.Sprintf("%s", sess.Values["name"]))
+		if err != nil || !user.IsAdmin() {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,39 @@
+package bodylimit
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Default is the maximum size of the request bodies of the paths without a
+// limit of their own, enough for the forms of the site
+const Default = 64 << 10
+
+// Limits are maximum sizes of request bodies, by path prefix
+type Limits map[string]int64
+
+// Max returns the maximum body size of a path, the limit of its longest prefix
+// or Default
+func (l Limits) Max(path string) int64 {
+	max, longest := int64(Default), -1
+	for prefix, n := range l {
+		if strings.HasPrefix(path, prefix) && len(prefix) > longest {
+			max, longest = n, len(prefix)
+		}
+	}
+	return max
+}
+
+// Handler refuses the requests announcing a body over the limit of their path,
+// and cuts the others at the limit, before any handler reads them
+func Handler(next http.Handler, limits Limits) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		max := limits.Max(r.URL.Path)
+		if r.ContentLength > max {
+			http.Error(w, "Request too large.", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, max)
+		next.ServeHTTP(w, r)
+	})
+}
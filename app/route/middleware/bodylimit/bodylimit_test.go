@@ -0,0 +1,53 @@
+package bodylimit
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	limits := Limits{"/upload/": 100, "/upload/big/": 1000}
+	if limits.Max("/login") != Default || limits.Max("/upload/crackme") != 100 || limits.Max("/upload/big/x") != 1000 {
+		t.Fatal("wrong limits")
+	}
+
+	read := 0
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		read = len(b)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}), limits)
+
+	tests := []struct {
+		path    string
+		size    int
+		chunked bool
+		code    int
+	}{
+		{"/upload/crackme", 100, false, http.StatusOK},
+		{"/upload/crackme", 101, false, http.StatusRequestEntityTooLarge},
+		{"/upload/big/x", 500, false, http.StatusOK},
+		// Without a Content-Length, the body is cut at the limit
+		{"/upload/crackme", 500, true, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", test.path, strings.NewReader(strings.Repeat("a", test.size)))
+		if test.chunked {
+			r.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		read = 0
+		h.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("%s with %d bytes: got %d, want %d", test.path, test.size, w.Code, test.code)
+		}
+		if int64(read) > limits.Max(test.path) {
+			t.Errorf("%s: read %d bytes past the limit", test.path, read)
+		}
+	}
+}
@@ -4,12 +4,14 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/clientip"
 )
 
 // Handler will log the HTTP requests
 func Handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fmt.Println(time.Now().Format("2006-01-02 03:04:05 PM"), r.RemoteAddr, r.Method, r.URL)
+		fmt.Println(time.Now().Format("2006-01-02 03:04:05 PM"), clientip.FromRequest(r), r.Method, r.URL)
 		next.ServeHTTP(w, r)
 	})
 }
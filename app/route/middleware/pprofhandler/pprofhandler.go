@@ -16,11 +16,27 @@ package pprofhandler
 import (
 	"net/http"
 	"net/http/pprof"
+	"runtime"
+	"strconv"
+	"sync"
 
 	"github.com/gorilla/context"
 	"github.com/julienschmidt/httprouter"
 )
 
+// MaxSeconds is the longest CPU profile or trace, they run on the server
+// while the request lasts
+const MaxSeconds = 60
+
+var (
+	// running holds the CPU profile or the trace being recorded, only one
+	// runs at a time
+	running = make(chan struct{}, 1)
+
+	rateMutex sync.Mutex
+	blockRate int
+)
+
 // Handler routes the pprof pages using httprouter
 func Handler(w http.ResponseWriter, r *http.Request) {
 
@@ -30,10 +46,56 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	case "/cmdline":
 		pprof.Cmdline(w, r)
 	case "/profile":
-		pprof.Profile(w, r)
+		recording(w, r, pprof.Profile)
+	case "/trace":
+		recording(w, r, pprof.Trace)
 	case "/symbol":
 		pprof.Symbol(w, r)
 	default:
 		pprof.Index(w, r)
 	}
 }
+
+// recording serves a CPU profile or a trace, refused while another one runs
+// or for more than MaxSeconds
+func recording(w http.ResponseWriter, r *http.Request, serve http.HandlerFunc) {
+	if seconds, err := strconv.Atoi(r.FormValue("seconds")); err == nil && seconds > MaxSeconds {
+		http.Error(w, "At most "+strconv.Itoa(MaxSeconds)+" seconds can be recorded.", http.StatusBadRequest)
+		return
+	}
+	select {
+	case running <- struct{}{}:
+		defer func() { <-running }()
+		serve(w, r)
+	default:
+		http.Error(w, "A profile is already being recorded, try again later.", http.StatusConflict)
+	}
+}
+
+// BlockProfileRate returns the rate of the block profile: one blocking event
+// sampled per rate nanoseconds spent blocked, 0 when off
+func BlockProfileRate() int {
+	rateMutex.Lock()
+	defer rateMutex.Unlock()
+	return blockRate
+}
+
+// SetBlockProfileRate sets the rate of the block profile, 0 turns it off
+func SetBlockProfileRate(rate int) {
+	rateMutex.Lock()
+	defer rateMutex.Unlock()
+	blockRate = rate
+	runtime.SetBlockProfileRate(rate)
+}
+
+// MutexProfileFraction returns the fraction of the mutex profile: one mutex
+// contention event sampled out of fraction, 0 when off
+func MutexProfileFraction() int {
+	return runtime.SetMutexProfileFraction(-1)
+}
+
+// SetMutexProfileFraction sets the fraction of the mutex profile, 0 turns it
+// off
+func SetMutexProfileFraction(fraction int) {
+	runtime.SetMutexProfileFraction(fraction)
+}
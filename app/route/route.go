@@ -5,10 +5,12 @@ import (
 
 	"github.com/crackmesone/crackmes.one/app/controller"
 	"github.com/crackmesone/crackmes.one/app/route/middleware/acl"
+	"github.com/crackmesone/crackmes.one/app/route/middleware/bodylimit"
 	hr "github.com/crackmesone/crackmes.one/app/route/middleware/httprouterwrapper"
 	"github.com/crackmesone/crackmes.one/app/route/middleware/logrequest"
 	"github.com/crackmesone/crackmes.one/app/route/middleware/pprofhandler"
 	"github.com/crackmesone/crackmes.one/app/shared/session"
+	"github.com/crackmesone/crackmes.one/app/shared/site"
 
 	"github.com/gorilla/context"
 	"github.com/josephspurrier/csrfbanana"
@@ -21,22 +23,26 @@ func Load() http.Handler {
 	return middleware(routes())
 }
 
-// LoadHTTPS returns the HTTP routes and middleware
+// bodyLimits are the maximum sizes of the request bodies by path prefix, the
+// others are limited to bodylimit.Default. The uploads hold files of up to
+// 5 MB, the pages written by the admins can be long. The drafts of the
+// writeups hold 5000 + 4×10000 characters, up to 12 bytes each once UTF-8 and
+// URL-encoded.
+var bodyLimits = bodylimit.Limits{
+	"/upload/":     6 << 20,
+	"/admin/page/": 1 << 20,
+	"/api/draft/":  640 << 10,
+}
+
+// LoadHTTPS returns the HTTPS routes and middleware
 func LoadHTTPS() http.Handler {
 	return middleware(routes())
 }
 
-// LoadHTTP returns the HTTPS routes and middleware
+// LoadHTTP returns the HTTP routes and middleware. The server replaces them
+// with a redirection to HTTPS when RedirectHTTP is set.
 func LoadHTTP() http.Handler {
 	return middleware(routes())
-
-	// Uncomment this and comment out the line above to always redirect to HTTPS
-	//return http.HandlerFunc(redirectToHTTPS)
-}
-
-// Optional method to make it easy to redirect from HTTP to HTTPS
-func redirectToHTTPS(w http.ResponseWriter, req *http.Request) {
-	http.Redirect(w, req, "https://"+req.Host, http.StatusMovedPermanently)
 }
 
 // *****************************************************************************
@@ -58,6 +64,11 @@ func routes() *httprouter.Router {
 	r.GET("/.well-known/*filepath", hr.Handler(alice.
 		New().
 		ThenFunc(controller.Static)))
+
+	// Crackme and solution files, through signed URLs
+	r.GET("/download/:kind/:hexid", hr.Handler(alice.
+		New().
+		ThenFunc(controller.DownloadGET)))
 	// Home page
 	r.GET("/", hr.Handler(alice.
 		New().
@@ -78,9 +89,41 @@ func routes() *httprouter.Router {
 	r.GET("/user/:name", hr.Handler(alice.
 		New().
 		ThenFunc(controller.UserGET)))
-	/*r.GET("/users", hr.Handler(alice.
-	  New().
-	  ThenFunc(controller.UsersGET)))*/
+	r.GET("/badge/user/:name", hr.Handler(alice.
+		New().
+		ThenFunc(controller.UserBadgeGET)))
+	r.GET("/user/:name/activity", hr.Handler(alice.
+		New().
+		ThenFunc(controller.UserActivityGET)))
+	r.POST("/user/:name/follow", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.FollowPOST)))
+	r.POST("/user/:name/unfollow", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.UnfollowPOST)))
+	r.GET("/users", hr.Handler(alice.
+		New().
+		ThenFunc(controller.UsersGET)))
+
+	// Teams
+	r.GET("/teams", hr.Handler(alice.
+		New().
+		ThenFunc(controller.TeamsGET)))
+	r.POST("/teams", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.TeamCreatePOST)))
+	r.GET("/team/:name", hr.Handler(alice.
+		New().
+		ThenFunc(controller.TeamGET)))
+	r.POST("/team/:name/invite", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.TeamInvitePOST)))
+	r.POST("/team/:name/join", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.TeamJoinPOST)))
+	r.POST("/team/:name/leave", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.TeamLeavePOST)))
 
 	// Notifications
 	r.GET("/notifications", hr.Handler(alice.
@@ -89,6 +132,115 @@ func routes() *httprouter.Router {
 	r.POST("/notifications/delete", hr.Handler(alice.
 		New(acl.DisallowAnon).
 		ThenFunc(controller.NotificationsDeletePOST)))
+	r.POST("/notifications/archive", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.NotificationsArchivePOST)))
+	r.GET("/api/notifications", hr.Handler(alice.
+		New().
+		ThenFunc(controller.NotificationsAPIGET)))
+	r.GET("/api/notifications/unread-count", hr.Handler(alice.
+		New().
+		ThenFunc(controller.NotificationsUnreadCountGET)))
+
+	// GraphQL, read only so the POSTs need no CSRF token
+	r.GET("/graphql", hr.Handler(alice.
+		New().
+		ThenFunc(controller.GraphQLGET)))
+	r.POST("/graphql", hr.Handler(alice.
+		New().
+		ThenFunc(controller.GraphQLPOST)))
+
+	// Admin
+	r.GET("/admin/consistency", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminConsistencyGET)))
+	r.POST("/admin/consistency", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminConsistencyPOST)))
+	r.GET("/admin/drift", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminDriftGET)))
+	r.POST("/admin/drift", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminDriftPOST)))
+	r.GET("/admin/invites", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminInvitesGET)))
+	r.POST("/admin/invites", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminInvitesPOST)))
+	r.GET("/admin/settings", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminSettingsGET)))
+	r.POST("/admin/settings", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminSettingsPOST)))
+	r.GET("/admin/features", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminFeaturesGET)))
+	r.POST("/admin/features", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminFeaturesPOST)))
+	r.GET("/admin/shadow", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminShadowGET)))
+	r.POST("/admin/shadow", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminShadowPOST)))
+	r.GET("/admin/claims", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminClaimsGET)))
+	r.POST("/admin/claims", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminClaimsPOST)))
+	r.POST("/admin/crackme/:hexid/archive", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminCrackmeArchivePOST)))
+	r.GET("/admin/comments", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminCommentsGET)))
+	r.POST("/admin/comments", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminCommentsPOST)))
+	r.GET("/admin/moderation", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminModerationGET)))
+	r.GET("/admin/compare", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminCompareGET)))
+	r.POST("/admin/compare", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminComparePOST)))
+	r.GET("/admin/impersonate", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminImpersonateGET)))
+	r.POST("/admin/impersonate", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminImpersonatePOST)))
+	r.GET("/impersonate/stop", hr.Handler(alice.
+		New().
+		ThenFunc(controller.ImpersonateStopGET)))
+	r.GET("/admin/audit", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminAuditGET)))
+	r.GET("/admin/user/:name", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminUserGET)))
+	r.POST("/admin/user/:name", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminUserPOST)))
+	r.GET("/admin/ratings", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminRatingsGET)))
+	r.GET("/admin/pages", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminPagesGET)))
+	r.GET("/admin/page/:slug", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminPageGET)))
+	r.POST("/admin/page/:slug", hr.Handler(alice.
+		New(acl.AdminOnly).
+		ThenFunc(controller.AdminPagePOST)))
 
 	// Search
 	r.GET("/search", hr.Handler(alice.
@@ -110,17 +262,77 @@ func routes() *httprouter.Router {
 	r.GET("/faq", hr.Handler(alice.
 		New().
 		ThenFunc(controller.FaqGET)))
+	r.GET("/page/:slug", hr.Handler(alice.
+		New().
+		ThenFunc(controller.PageGET)))
 
 	// Crackmes
 	r.GET("/crackme/:hexid", hr.Handler(alice.
 		New().
 		ThenFunc(controller.CrackMeGET)))
+	r.GET("/embed/crackme/:hexid", hr.Handler(alice.
+		New().
+		ThenFunc(controller.EmbedCrackmeGET)))
+	r.GET("/oembed", hr.Handler(alice.
+		New().
+		ThenFunc(controller.OEmbedGET)))
+	r.GET("/api/upload/status/:hexid", hr.Handler(alice.
+		New().
+		ThenFunc(controller.UploadStatusAPIGET)))
+	r.GET("/api/crackme/:hexid/comments", hr.Handler(alice.
+		New().
+		ThenFunc(controller.CrackMeCommentsAPIGET)))
+	r.GET("/api/crackme/:hexid/solutions", hr.Handler(alice.
+		New().
+		ThenFunc(controller.CrackMeSolutionsAPIGET)))
+	r.GET("/api/crackme/:hexid/ratings", hr.Handler(alice.
+		New().
+		ThenFunc(controller.CrackMeRatingsAPIGET)))
+	r.GET("/crackme/:hexid/qr", hr.Handler(alice.
+		New().
+		ThenFunc(controller.CrackMeQRGET)))
+	r.POST("/crackme/:hexid/attempt", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.AttemptPOST)))
+	r.POST("/crackme/:hexid/unattempt", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.UnattemptPOST)))
+	r.POST("/crackme/:hexid/spoilerfree", hr.Handler(alice.
+		New().
+		ThenFunc(controller.SpoilerFreePOST)))
+	r.POST("/crackme/:hexid/edit", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.CrackmeEditPOST)))
+	r.POST("/crackme/:hexid/withdraw", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.CrackmeWithdrawPOST)))
+	r.POST("/crackme/:hexid/visibility", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.CrackmeVisibilityPOST)))
+	r.POST("/crackme/:hexid/archive", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.CrackmeArchivePOST)))
+	r.GET("/claim/:hexid", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.ClaimGET)))
+	r.POST("/claim/:hexid", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.ClaimPOST)))
+	r.GET("/c/:shortid", hr.Handler(alice.
+		New().
+		ThenFunc(controller.ShortLinkGET)))
 	r.GET("/upload/crackme", hr.Handler(alice.
 		New(acl.DisallowAnon).
 		ThenFunc(controller.UploadCrackMeGET)))
 	r.POST("/upload/crackme", hr.Handler(alice.
 		New(acl.DisallowAnon).
 		ThenFunc(controller.UploadCrackMePOST)))
+	r.GET("/upload/crackme/:hexid", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.UploadVersionGET)))
+	r.POST("/upload/crackme/:hexid", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.UploadVersionPOST)))
 	r.GET("/lasts/:page", hr.Handler(alice.
 		New().
 		ThenFunc(controller.LastCrackMesGET)))
@@ -138,6 +350,15 @@ func routes() *httprouter.Router {
 	r.POST("/upload/solution/:hexidcrackme", hr.Handler(alice.
 		New(acl.DisallowAnon).
 		ThenFunc(controller.UploadSolutionPOST)))
+	r.POST("/pick/:hexid", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.PickSolutionPOST)))
+	r.POST("/solution/:hexid/edit", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.SolutionEditPOST)))
+	r.POST("/solution/:hexid/withdraw", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.SolutionWithdrawPOST)))
 
 	//Solution Rules
 	r.GET("/upload/writeuprules", hr.Handler(alice.
@@ -153,16 +374,51 @@ func routes() *httprouter.Router {
 	r.POST("/comment/:hexid", hr.Handler(alice.
 		New(acl.DisallowAnon).
 		ThenFunc(controller.LeaveCommentPOST)))
+	r.POST("/api/comment/:id/react", hr.Handler(alice.
+		New().
+		ThenFunc(controller.ReactionPOST)))
+	r.POST("/api/comment/:id/edit", hr.Handler(alice.
+		New().
+		ThenFunc(controller.CommentEditPOST)))
+	r.POST("/api/comment/:id/withdraw", hr.Handler(alice.
+		New().
+		ThenFunc(controller.CommentWithdrawPOST)))
+	r.POST("/api/preview", hr.Handler(alice.
+		New().
+		ThenFunc(controller.PreviewPOST)))
+	r.POST("/api/draft/crackme", hr.Handler(alice.
+		New().
+		ThenFunc(controller.DraftCrackmePOST)))
+	r.POST("/api/draft/solution/:hexidcrackme", hr.Handler(alice.
+		New().
+		ThenFunc(controller.DraftSolutionPOST)))
 
-	// Enable Pprof
-	r.GET("/debug/pprof/*pprof", hr.Handler(alice.
+	// Hints
+	r.POST("/hint/:hexid", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.HintCreatePOST)))
+	r.POST("/hint/:hexid/delete", hr.Handler(alice.
 		New(acl.DisallowAnon).
+		ThenFunc(controller.HintDeletePOST)))
+	r.POST("/hint/:hexid/reveal", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.HintRevealPOST)))
+
+	// Enable Pprof, for the admins only
+	r.GET("/debug/pprof/*pprof", hr.Handler(alice.
+		New(acl.AdminOnly).
 		ThenFunc(pprofhandler.Handler)))
 
-	// RSS
+	// RSS and JSON Feed
 	r.GET("/rss/crackme", hr.Handler(alice.
 		New().
 		ThenFunc(controller.RssCrackmesGET)))
+	r.GET("/rss/crackme/:hexid", hr.Handler(alice.
+		New().
+		ThenFunc(controller.RssCrackmeGET)))
+	r.GET("/feed/crackme.json", hr.Handler(alice.
+		New().
+		ThenFunc(controller.JSONFeedCrackmesGET)))
 
 	// Change Password
 	r.GET("/change-password", hr.Handler(alice.
@@ -172,6 +428,59 @@ func routes() *httprouter.Router {
 		New(acl.DisallowAnon).
 		ThenFunc(controller.ResetPasswordWithCurrentPOST)))
 
+	// Email digest
+	r.GET("/settings/digest", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.DigestSettingsGET)))
+	r.POST("/settings/digest", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.DigestSettingsPOST)))
+
+	// Listing preferences
+	r.GET("/settings/listing", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.ListingSettingsGET)))
+	r.POST("/settings/listing", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.ListingSettingsPOST)))
+
+	// Invite codes
+	r.GET("/settings/invites", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.InvitesGET)))
+	r.POST("/settings/invites", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.InvitesPOST)))
+
+	// Limits
+	r.GET("/settings/limits", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.LimitsGET)))
+
+	// Solves summary
+	r.GET("/settings/export/solves", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.SolvesExportGET)))
+	r.GET("/solves/:name", hr.Handler(alice.
+		New().
+		ThenFunc(controller.SolvesSharedGET)))
+
+	// Sign-ins
+	r.GET("/settings/signins", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.SignInsGET)))
+	r.POST("/settings/signins", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.SignInsPOST)))
+
+	// Timezone
+	r.GET("/settings/time", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.ClockSettingsGET)))
+	r.POST("/settings/time", hr.Handler(alice.
+		New(acl.DisallowAnon).
+		ThenFunc(controller.ClockSettingsPOST)))
+
 	return r
 }
 
@@ -179,22 +488,37 @@ func routes() *httprouter.Router {
 // Middleware
 // *****************************************************************************
 
-func middleware(h http.Handler) http.Handler {
-	// Prevents CSRF and Double Submits
+func middleware(router *httprouter.Router) http.Handler {
+	var h http.Handler = router
+
+	// Prevents CSRF and Double Submits, the visitors go back to the forms
+	// refused for an expired token
 	cs := csrfbanana.New(h, session.Store, session.Name)
-	cs.FailureHandler(http.HandlerFunc(controller.InvalidToken))
+	cs.FailureHandler(controller.InvalidToken(func(path string) bool {
+		handle, _, _ := router.Lookup("GET", path)
+		return handle != nil
+	}))
 	cs.ClearAfterUsage(true)
-	cs.ExcludeRegexPaths([]string{"/static(.*)"})
+	cs.ExcludeRegexPaths([]string{"/static(.*)", "^/graphql$"})
 	csrfbanana.TokenLength = 32
 	csrfbanana.TokenName = "token"
 	csrfbanana.SingleToken = false
 	h = cs
 
+	// Keep the admins viewing the site as a user read-only
+	h = controller.ImpersonationGuard(h)
+
+	// Refuse the oversized requests before the forms are parsed
+	h = bodylimit.Handler(h, bodyLimits)
+
 	// Log every request
 	h = logrequest.Handler(h)
 
 	// Clear handler for Gorilla Context
 	h = context.ClearHandler(h)
 
+	// Serve the site below the path of its base URL
+	h = site.Handler(h)
+
 	return h
 }
@@ -0,0 +1,103 @@
+package route
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/crackmesone/crackmes.one/app/shared/session"
+	"github.com/crackmesone/crackmes.one/app/shared/view"
+
+	"github.com/josephspurrier/csrfbanana"
+	"github.com/julienschmidt/httprouter"
+)
+
+// TestRetryKeepsIdempotencyKey retries an upload with its used token: the
+// retry is refused by the CSRF middleware, and the form it leads back to
+// must send the same idempotency key so the upload isn't done twice
+func TestRetryKeepsIdempotencyKey(t *testing.T) {
+	session.Configure(session.Session{Name: "test", SecretKey: "test"})
+
+	router := httprouter.New()
+	router.GET("/login", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		sess := session.Instance(r)
+		sess.Values["name"] = "alice"
+		sess.Save(r, w)
+	})
+	router.GET("/upload/crackme", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		sess := session.Instance(r)
+		token := csrfbanana.Token(w, r, sess)
+		key := view.KeptValue(view.New(r).Vars, "idempotency_key")
+		sess.Save(r, w)
+		w.Write([]byte(token + " " + key))
+	})
+	router.POST("/upload/crackme", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		session.Instance(r).Save(r, w)
+		w.Write([]byte("uploaded"))
+	})
+
+	server := httptest.NewServer(middleware(router))
+	defer server.Close()
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	get := func(path string) string {
+		res, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, _ := ioutil.ReadAll(res.Body)
+		return string(b)
+	}
+	post := func(form url.Values) *http.Response {
+		req, _ := http.NewRequest("POST", server.URL+"/upload/crackme", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Origin", server.URL)
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	get("/login")
+	token := strings.Fields(get("/upload/crackme"))[0]
+	form := url.Values{"token": {token}, "idempotency_key": {"0123456789abcdef"}, "name": {"keygenme"}}
+
+	if res := post(form); res.StatusCode != http.StatusOK {
+		t.Fatalf("upload: status %d, want 200", res.StatusCode)
+	}
+	res := post(form)
+	if res.StatusCode != http.StatusFound || res.Header.Get("Location") != "/upload/crackme" {
+		t.Fatalf("retry: status %d to %q, want a redirection to the form", res.StatusCode, res.Header.Get("Location"))
+	}
+
+	if got := strings.Fields(get("/upload/crackme")); len(got) != 2 || got[1] != "0123456789abcdef" {
+		t.Errorf("form after the retry: got %q, want the idempotency key of the upload", got)
+	}
+}
+
+// TestDraftBodyLimit checks the longest writeup fits in the limit of its
+// drafts, in 4 bytes characters
+func TestDraftBodyLimit(t *testing.T) {
+	section := strings.Repeat("😀", 10000)
+	form := url.Values{
+		"info":  {strings.Repeat("😀", 5000)},
+		"tools": {section}, "approach": {section}, "key": {section}, "patch": {section},
+		"language": {"en"}, "license": {"CC-BY-4.0"}, "token": {strings.Repeat("a", 32)},
+	}
+	if n, max := int64(len(form.Encode())), bodyLimits.Max("/api/draft/solution/abc"); n > max {
+		t.Errorf("draft of %d bytes, over the limit of %d", n, max)
+	}
+}
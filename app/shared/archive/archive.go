@@ -0,0 +1,72 @@
+// Package archive lists the files of uploaded archives without extracting
+// them, and bundles the files uploaded apart in a zip.
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+)
+
+var (
+	// ErrUnknownFormat is returned for data that isn't a supported archive
+	ErrUnknownFormat = errors.New("unknown archive format")
+)
+
+// Entry describes a file of an archive
+type Entry struct {
+	Name       string `bson:"name" json:"name"`
+	Size       int64  `bson:"size" json:"size"`
+	Compressed int64  `bson:"compressed" json:"compressed"`
+	Encrypted  bool   `bson:"encrypted,omitempty" json:"encrypted,omitempty"`
+	Role       string `bson:"role,omitempty" json:"role,omitempty"` // Set for the files of a Bundle
+}
+
+// List returns the files of a zip, 7z or RAR archive, directories excluded
+func List(data []byte) ([]Entry, error) {
+	switch {
+	case bytes.HasPrefix(data, sevenZipMagic):
+		return listSevenZip(data)
+	case bytes.HasPrefix(data, rar5Magic):
+		return listRAR5(data)
+	case bytes.HasPrefix(data, rar4Magic):
+		return listRAR4(data)
+	}
+	return listZip(data)
+}
+
+// Format returns the format of a zip, 7z or RAR archive, or "" for other data
+func Format(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, sevenZipMagic):
+		return "7z"
+	case bytes.HasPrefix(data, rar5Magic), bytes.HasPrefix(data, rar4Magic):
+		return "RAR"
+	}
+	if _, err := zip.NewReader(bytes.NewReader(data), int64(len(data))); err == nil {
+		return "zip"
+	}
+	return ""
+}
+
+// listZip returns the files of a zip archive
+func listZip(data []byte) ([]Entry, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrUnknownFormat
+	}
+
+	var entries []Entry
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		entries = append(entries, Entry{
+			Name:       f.Name,
+			Size:       int64(f.UncompressedSize64),
+			Compressed: int64(f.CompressedSize64),
+			Encrypted:  f.Flags&0x1 != 0,
+		})
+	}
+	return entries, nil
+}
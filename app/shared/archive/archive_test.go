@@ -0,0 +1,158 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	// The RAR archives store their files, the 7z one compresses its header
+	// with LZMA
+	tests := []struct {
+		file string
+		want []Entry
+	}{
+		{"testdata/sample.7z", []Entry{
+			{Name: "readme.txt", Size: 13},
+			{Name: "bin/crackme.exe", Size: 102},
+			{Name: "empty.txt"},
+		}},
+		{"testdata/sample4.rar", []Entry{
+			{Name: "readme.txt", Size: 13, Compressed: 13},
+			{Name: "bin/crackme.exe", Size: 102, Compressed: 102},
+			{Name: "dir/ünï.txt", Size: 1, Compressed: 1},
+		}},
+		{"testdata/sample5.rar", []Entry{
+			{Name: "readme.txt", Size: 13, Compressed: 13},
+			{Name: "bin/crackme.exe", Size: 102, Compressed: 102},
+			{Name: "dir/ünï.txt", Size: 1, Compressed: 1},
+		}},
+	}
+
+	for _, test := range tests {
+		data, err := os.ReadFile(test.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := List(data)
+		if err != nil {
+			t.Errorf("%s: %v", test.file, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.file, got, test.want)
+		}
+
+		if Format(data) == "" {
+			t.Errorf("%s: format not detected", test.file)
+		}
+
+		// Truncated archives must fail or list less, never panic
+		for i := 0; i < len(data); i++ {
+			List(data[:i])
+		}
+	}
+}
+
+func TestListZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.Create("bin/")
+	f, _ := zw.Create("bin/crackme")
+	f.Write([]byte("crackme"))
+	zw.Close()
+
+	got, err := List(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Name != "bin/crackme" || got[0].Size != 7 {
+		t.Errorf("got %+v", got)
+	}
+
+	if _, err := List([]byte("not an archive")); err != ErrUnknownFormat {
+		t.Errorf("got %v, want ErrUnknownFormat", err)
+	}
+
+	if Format(buf.Bytes()) != "zip" || Format([]byte("not an archive")) != "" {
+		t.Error("zip format not detected")
+	}
+}
+
+func TestBundle(t *testing.T) {
+	data, entries, err := Bundle([]File{
+		{Name: "crackme.exe", Role: RoleBinary, Data: []byte("MZ")},
+		{Name: "readme.txt", Role: RoleReadme, Data: []byte("Find the key.")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{{Name: "crackme.exe", Size: 2, Role: RoleBinary}, {Name: "readme.txt", Size: 13, Role: RoleReadme}}
+	for i := range entries {
+		entries[i].Compressed = 0
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got %+v, want %+v", entries, want)
+	}
+	if Format(data) != "zip" {
+		t.Errorf("bundle not a zip")
+	}
+
+	if _, _, err := Bundle([]File{{Name: "a"}, {Name: "a"}}); err != ErrDuplicateName {
+		t.Errorf("duplicate names: got %v", err)
+	}
+}
+
+func TestReadme(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, _ := zw.Create("docs/README.md")
+	f.Write([]byte("Nested"))
+	f, _ = zw.Create("ReadMe.txt")
+	f.Write([]byte("Find the key."))
+	f, _ = zw.Create("notes.txt")
+	f.Write([]byte("Notes"))
+	zw.Close()
+
+	if got := Readme(buf.Bytes(), nil); got != "Find the key." {
+		t.Errorf("closest to the root: got %q", got)
+	}
+	if got := Readme(buf.Bytes(), []Entry{{Name: "notes.txt", Role: RoleReadme}}); got != "Notes" {
+		t.Errorf("readme role: got %q", got)
+	}
+	if got := Readme([]byte("MZ"), nil); got != "" {
+		t.Errorf("not an archive: got %q", got)
+	}
+}
+
+func TestOpenEncrypted(t *testing.T) {
+	// Zipped like the stored files, the readme stored and the padding deflated
+	r, err := zip.OpenReader("testdata/encrypted.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	want := map[string]string{
+		"writeup.txt": "Patch the jump at 0x401000 and the serial check passes.\n",
+		"padding.txt": strings.Repeat("x", 2000) + "\n",
+	}
+	for _, f := range r.File {
+		rc, err := OpenEncrypted(f, "crackmes.one")
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		got, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil || string(got) != want[f.Name] {
+			t.Errorf("%s: got %q, %v", f.Name, got, err)
+		}
+
+		if _, err := OpenEncrypted(f, "wrong"); err != ErrPassword {
+			t.Errorf("%s: got %v, want ErrPassword", f.Name, err)
+		}
+	}
+}
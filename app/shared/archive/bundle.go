@@ -0,0 +1,58 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"time"
+)
+
+// Roles of the files uploaded apart and bundled together
+const (
+	RoleBinary     = "binary"
+	RoleReadme     = "readme"
+	RoleDependency = "dependency"
+)
+
+// ErrDuplicateName is returned when two bundled files have the same name
+var ErrDuplicateName = errors.New("duplicate file name")
+
+// File is a file to bundle
+type File struct {
+	Name string
+	Role string
+	Data []byte
+}
+
+// Bundle returns a zip of the files, and their entries with their role
+func Bundle(files []File) ([]byte, []Entry, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	seen := map[string]bool{}
+	for _, f := range files {
+		if seen[f.Name] {
+			return nil, nil, ErrDuplicateName
+		}
+		seen[f.Name] = true
+
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Deflate, Modified: time.Now()})
+		if err == nil {
+			_, err = w.Write(f.Data)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	entries, err := listZip(buf.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range entries {
+		entries[i].Role = files[i].Role
+	}
+	return buf.Bytes(), entries, nil
+}
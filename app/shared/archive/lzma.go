@@ -0,0 +1,439 @@
+package archive
+
+import (
+	"errors"
+)
+
+// LZMA decoder, enough to read the compressed headers of 7z archives. It
+// follows the reference decoder of the LZMA SDK (LzmaSpec.cpp).
+
+var errLZMA = errors.New("corrupted lzma data")
+
+const (
+	lzmaNumStates       = 12
+	lzmaPosBitsMax      = 4
+	lzmaEndPosModel     = 14
+	lzmaNumFullDists    = 1 << (lzmaEndPosModel >> 1)
+	lzmaNumAlignBits    = 4
+	lzmaNumLenToPos     = 4
+	lzmaMatchMinLen     = 2
+	lzmaBitModelTotal   = 1 << 11
+	lzmaNumMoveBits     = 5
+	lzmaTopValue        = 1 << 24
+	lzmaProbInitValue   = lzmaBitModelTotal / 2
+	lzmaLiteralCoderLen = 0x300
+)
+
+type prob uint16
+
+func newProbs(n int) []prob {
+	p := make([]prob, n)
+	for i := range p {
+		p[i] = lzmaProbInitValue
+	}
+	return p
+}
+
+// rangeDecoder reads the bits of an LZMA stream
+type rangeDecoder struct {
+	data  []byte
+	pos   int
+	rng   uint32
+	code  uint32
+	error bool
+}
+
+func (rd *rangeDecoder) readByte() byte {
+	if rd.pos >= len(rd.data) {
+		rd.error = true
+		return 0
+	}
+	b := rd.data[rd.pos]
+	rd.pos++
+	return b
+}
+
+func (rd *rangeDecoder) init() {
+	rd.rng = 0xFFFFFFFF
+	if rd.readByte() != 0 {
+		rd.error = true
+	}
+	for i := 0; i < 4; i++ {
+		rd.code = rd.code<<8 | uint32(rd.readByte())
+	}
+	if rd.code == rd.rng {
+		rd.error = true
+	}
+}
+
+func (rd *rangeDecoder) normalize() {
+	if rd.rng < lzmaTopValue {
+		rd.rng <<= 8
+		rd.code = rd.code<<8 | uint32(rd.readByte())
+	}
+}
+
+func (rd *rangeDecoder) directBits(n int) uint32 {
+	var res uint32
+	for ; n > 0; n-- {
+		rd.rng >>= 1
+		rd.code -= rd.rng
+		t := 0 - (rd.code >> 31)
+		rd.code += rd.rng & t
+		if rd.code == rd.rng {
+			rd.error = true
+		}
+		rd.normalize()
+		res = res<<1 + t + 1
+	}
+	return res
+}
+
+func (rd *rangeDecoder) bit(p *prob) uint32 {
+	v := uint32(*p)
+	bound := (rd.rng >> 11) * v
+	var symbol uint32
+	if rd.code < bound {
+		*p = prob(v + (lzmaBitModelTotal-v)>>lzmaNumMoveBits)
+		rd.rng = bound
+	} else {
+		*p = prob(v - v>>lzmaNumMoveBits)
+		rd.code -= bound
+		rd.rng -= bound
+		symbol = 1
+	}
+	rd.normalize()
+	return symbol
+}
+
+func (rd *rangeDecoder) bitTree(probs []prob, numBits int) uint32 {
+	m := uint32(1)
+	for i := 0; i < numBits; i++ {
+		m = m<<1 + rd.bit(&probs[m])
+	}
+	return m - 1<<uint(numBits)
+}
+
+func (rd *rangeDecoder) bitTreeReverse(probs []prob, numBits int) uint32 {
+	m := uint32(1)
+	var symbol uint32
+	for i := 0; i < numBits; i++ {
+		bit := rd.bit(&probs[m])
+		m = m<<1 + bit
+		symbol |= bit << uint(i)
+	}
+	return symbol
+}
+
+// lenDecoder decodes the lengths of the matches
+type lenDecoder struct {
+	choice  prob
+	choice2 prob
+	low     [1 << lzmaPosBitsMax][]prob
+	mid     [1 << lzmaPosBitsMax][]prob
+	high    []prob
+}
+
+func newLenDecoder() *lenDecoder {
+	ld := &lenDecoder{choice: lzmaProbInitValue, choice2: lzmaProbInitValue, high: newProbs(1 << 8)}
+	for i := range ld.low {
+		ld.low[i] = newProbs(1 << 3)
+		ld.mid[i] = newProbs(1 << 3)
+	}
+	return ld
+}
+
+func (ld *lenDecoder) decode(rd *rangeDecoder, posState uint32) uint32 {
+	if rd.bit(&ld.choice) == 0 {
+		return rd.bitTree(ld.low[posState], 3)
+	}
+	if rd.bit(&ld.choice2) == 0 {
+		return 8 + rd.bitTree(ld.mid[posState], 3)
+	}
+	return 16 + rd.bitTree(ld.high, 8)
+}
+
+// lzmaDecoder holds the probabilities and the state of a decoder, kept from
+// a chunk to the next in LZMA2 streams. The whole output is the dictionary.
+type lzmaDecoder struct {
+	lc, lp, pb uint
+
+	literals    []prob
+	posSlot     [lzmaNumLenToPos][]prob
+	posDecoders []prob
+	align       []prob
+	isMatch     []prob
+	isRep       []prob
+	isRepG0     []prob
+	isRepG1     []prob
+	isRepG2     []prob
+	isRep0Long  []prob
+	lenDec      *lenDecoder
+	repLenDec   *lenDecoder
+
+	state                  uint32
+	rep0, rep1, rep2, rep3 uint32
+
+	out []byte
+}
+
+// setProps sets lc, lp and pb from their encoded byte
+func (d *lzmaDecoder) setProps(b byte) error {
+	if b >= 9*5*5 {
+		return errLZMA
+	}
+	d.lc = uint(b % 9)
+	b /= 9
+	d.lp = uint(b % 5)
+	d.pb = uint(b / 5)
+	return nil
+}
+
+// reset sets the probabilities and the state back to their initial values
+func (d *lzmaDecoder) reset() {
+	d.literals = newProbs(lzmaLiteralCoderLen << (d.lc + d.lp))
+	for i := range d.posSlot {
+		d.posSlot[i] = newProbs(1 << 6)
+	}
+	d.posDecoders = newProbs(1 + lzmaNumFullDists - lzmaEndPosModel)
+	d.align = newProbs(1 << lzmaNumAlignBits)
+	d.isMatch = newProbs(lzmaNumStates << lzmaPosBitsMax)
+	d.isRep = newProbs(lzmaNumStates)
+	d.isRepG0 = newProbs(lzmaNumStates)
+	d.isRepG1 = newProbs(lzmaNumStates)
+	d.isRepG2 = newProbs(lzmaNumStates)
+	d.isRep0Long = newProbs(lzmaNumStates << lzmaPosBitsMax)
+	d.lenDec = newLenDecoder()
+	d.repLenDec = newLenDecoder()
+	d.state = 0
+	d.rep0, d.rep1, d.rep2, d.rep3 = 0, 0, 0, 0
+}
+
+func (d *lzmaDecoder) decodeDistance(rd *rangeDecoder, length uint32) uint32 {
+	lenState := length
+	if lenState > lzmaNumLenToPos-1 {
+		lenState = lzmaNumLenToPos - 1
+	}
+	slot := rd.bitTree(d.posSlot[lenState], 6)
+	if slot < 4 {
+		return slot
+	}
+	numDirectBits := int(slot>>1) - 1
+	dist := (2 | slot&1) << uint(numDirectBits)
+	if slot < lzmaEndPosModel {
+		dist += rd.bitTreeReverse(d.posDecoders[dist-slot:], numDirectBits)
+	} else {
+		dist += rd.directBits(numDirectBits-lzmaNumAlignBits) << lzmaNumAlignBits
+		dist += rd.bitTreeReverse(d.align, lzmaNumAlignBits)
+	}
+	return dist
+}
+
+// decode appends to the output until it reaches size bytes or the end marker
+func (d *lzmaDecoder) decode(rd *rangeDecoder, size int) error {
+	for len(d.out) < size {
+		if rd.error {
+			return errLZMA
+		}
+		posState := uint32(len(d.out)) & (1<<d.pb - 1)
+		state := d.state
+
+		if rd.bit(&d.isMatch[state<<lzmaPosBitsMax+posState]) == 0 {
+			var prevByte byte
+			if len(d.out) > 0 {
+				prevByte = d.out[len(d.out)-1]
+			}
+			litState := (uint32(len(d.out))&(1<<d.lp-1))<<d.lc + uint32(prevByte)>>(8-d.lc)
+			probs := d.literals[lzmaLiteralCoderLen*litState:]
+
+			symbol := uint32(1)
+			if state >= 7 {
+				if int(d.rep0) >= len(d.out) {
+					return errLZMA
+				}
+				matchByte := uint32(d.out[len(d.out)-int(d.rep0)-1])
+				for symbol < 0x100 {
+					matchBit := (matchByte >> 7) & 1
+					matchByte <<= 1
+					bit := rd.bit(&probs[(1+matchBit)<<8+symbol])
+					symbol = symbol<<1 | bit
+					if matchBit != bit {
+						break
+					}
+				}
+			}
+			for symbol < 0x100 {
+				symbol = symbol<<1 | rd.bit(&probs[symbol])
+			}
+			d.out = append(d.out, byte(symbol-0x100))
+
+			switch {
+			case state < 4:
+				d.state = 0
+			case state < 10:
+				d.state = state - 3
+			default:
+				d.state = state - 6
+			}
+			continue
+		}
+
+		var length uint32
+		if rd.bit(&d.isRep[state]) != 0 {
+			if len(d.out) == 0 {
+				return errLZMA
+			}
+			if rd.bit(&d.isRepG0[state]) == 0 {
+				if rd.bit(&d.isRep0Long[state<<lzmaPosBitsMax+posState]) == 0 {
+					if state < 7 {
+						d.state = 9
+					} else {
+						d.state = 11
+					}
+					if int(d.rep0) >= len(d.out) {
+						return errLZMA
+					}
+					d.out = append(d.out, d.out[len(d.out)-int(d.rep0)-1])
+					continue
+				}
+			} else {
+				var dist uint32
+				if rd.bit(&d.isRepG1[state]) == 0 {
+					dist = d.rep1
+				} else {
+					if rd.bit(&d.isRepG2[state]) == 0 {
+						dist = d.rep2
+					} else {
+						dist = d.rep3
+						d.rep3 = d.rep2
+					}
+					d.rep2 = d.rep1
+				}
+				d.rep1 = d.rep0
+				d.rep0 = dist
+			}
+			length = d.repLenDec.decode(rd, posState)
+			if state < 7 {
+				d.state = 8
+			} else {
+				d.state = 11
+			}
+		} else {
+			d.rep3, d.rep2, d.rep1 = d.rep2, d.rep1, d.rep0
+			length = d.lenDec.decode(rd, posState)
+			if state < 7 {
+				d.state = 7
+			} else {
+				d.state = 10
+			}
+			d.rep0 = d.decodeDistance(rd, length)
+			if d.rep0 == 0xFFFFFFFF {
+				// End marker
+				break
+			}
+		}
+
+		length += lzmaMatchMinLen
+		if int(d.rep0) >= len(d.out) {
+			return errLZMA
+		}
+		for ; length > 0 && len(d.out) < size; length-- {
+			d.out = append(d.out, d.out[len(d.out)-int(d.rep0)-1])
+		}
+	}
+
+	if rd.error || len(d.out) < size {
+		return errLZMA
+	}
+	return nil
+}
+
+// lzmaDecode decompresses a raw LZMA stream of known size, as stored in 7z
+// archives, with the 5 bytes of properties of its coder
+func lzmaDecode(props, data []byte, size int) ([]byte, error) {
+	if len(props) < 5 {
+		return nil, errLZMA
+	}
+	d := &lzmaDecoder{out: make([]byte, 0, size)}
+	if err := d.setProps(props[0]); err != nil {
+		return nil, err
+	}
+	d.reset()
+
+	rd := &rangeDecoder{data: data}
+	rd.init()
+	if err := d.decode(rd, size); err != nil {
+		return nil, err
+	}
+	return d.out, nil
+}
+
+// lzma2Decode decompresses a raw LZMA2 stream of known size. LZMA2 splits the
+// data in chunks, either stored or compressed with LZMA, each one telling
+// what to reset before it.
+func lzma2Decode(data []byte, size int) ([]byte, error) {
+	d := &lzmaDecoder{out: make([]byte, 0, size)}
+	hasProps := false
+	pos := 0
+
+	for len(d.out) < size {
+		if pos >= len(data) {
+			return nil, errLZMA
+		}
+		control := data[pos]
+		pos++
+
+		switch {
+		case control == 0x00:
+			// End of the stream
+			return nil, errLZMA
+		case control == 0x01 || control == 0x02:
+			if pos+2 > len(data) {
+				return nil, errLZMA
+			}
+			n := int(data[pos])<<8 | int(data[pos+1]) + 1
+			pos += 2
+			if pos+n > len(data) || len(d.out)+n > size {
+				return nil, errLZMA
+			}
+			d.out = append(d.out, data[pos:pos+n]...)
+			pos += n
+		case control >= 0x80:
+			if pos+4 > len(data) {
+				return nil, errLZMA
+			}
+			unpacked := int(control&0x1F)<<16 | int(data[pos])<<8 | int(data[pos+1]) + 1
+			packed := int(data[pos+2])<<8 | int(data[pos+3]) + 1
+			pos += 4
+
+			if reset := (control >> 5) & 0x03; reset >= 2 {
+				if pos >= len(data) {
+					return nil, errLZMA
+				}
+				if err := d.setProps(data[pos]); err != nil {
+					return nil, err
+				}
+				pos++
+				hasProps = true
+				d.reset()
+			} else if !hasProps {
+				return nil, errLZMA
+			} else if reset == 1 {
+				d.reset()
+			}
+
+			if pos+packed > len(data) || len(d.out)+unpacked > size {
+				return nil, errLZMA
+			}
+			rd := &rangeDecoder{data: data[pos : pos+packed]}
+			rd.init()
+			if err := d.decode(rd, len(d.out)+unpacked); err != nil {
+				return nil, err
+			}
+			pos += packed
+		default:
+			return nil, errLZMA
+		}
+	}
+	return d.out, nil
+}
@@ -0,0 +1,242 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// RAR archives, in the 1.5-4.x and 5.0 formats. Only the block headers are
+// read, archives with encrypted headers can't be listed.
+
+var (
+	rar4Magic = []byte("Rar!\x1a\x07\x00")
+	rar5Magic = []byte("Rar!\x1a\x07\x01\x00")
+
+	errRAR = errors.New("corrupted rar archive")
+)
+
+// RAR 4 block types and flags
+const (
+	rar4BlockMain = 0x73
+	rar4BlockFile = 0x74
+	rar4BlockEnd  = 0x7B
+
+	rar4MainEncrypted  = 0x0080
+	rar4FileEncrypted  = 0x0004
+	rar4FileDirectory  = 0x00E0
+	rar4FileLarge      = 0x0100
+	rar4FileUnicode    = 0x0200
+	rar4LongBlock      = 0x8000
+	rar4FileHeaderSize = 25
+)
+
+// listRAR4 returns the files of a RAR 1.5-4.x archive
+func listRAR4(data []byte) ([]Entry, error) {
+	var entries []Entry
+	pos := len(rar4Magic)
+
+	for pos+7 <= len(data) {
+		blockType := data[pos+2]
+		flags := binary.LittleEndian.Uint16(data[pos+3:])
+		headSize := int(binary.LittleEndian.Uint16(data[pos+5:]))
+		if headSize < 7 || pos+headSize > len(data) {
+			return nil, errRAR
+		}
+		head := data[pos : pos+headSize]
+
+		var addSize uint64
+		if flags&rar4LongBlock != 0 {
+			if headSize < 11 {
+				return nil, errRAR
+			}
+			addSize = uint64(binary.LittleEndian.Uint32(head[7:]))
+		}
+
+		switch blockType {
+		case rar4BlockMain:
+			if flags&rar4MainEncrypted != 0 {
+				return nil, ErrEncryptedHeaders
+			}
+		case rar4BlockFile:
+			if headSize < 7+rar4FileHeaderSize {
+				return nil, errRAR
+			}
+			f := head[7:]
+			packSize := uint64(binary.LittleEndian.Uint32(f[0:]))
+			unpSize := uint64(binary.LittleEndian.Uint32(f[4:]))
+			nameSize := int(binary.LittleEndian.Uint16(f[19:]))
+			name := f[rar4FileHeaderSize:]
+			if flags&rar4FileLarge != 0 {
+				if len(name) < 8 {
+					return nil, errRAR
+				}
+				packSize |= uint64(binary.LittleEndian.Uint32(name[0:])) << 32
+				unpSize |= uint64(binary.LittleEndian.Uint32(name[4:])) << 32
+				name = name[8:]
+			}
+			if nameSize > len(name) {
+				return nil, errRAR
+			}
+			name = name[:nameSize]
+			if flags&rar4FileUnicode != 0 {
+				// The ASCII name comes first, followed by the encoded unicode one
+				if i := bytes.IndexByte(name, 0); i >= 0 {
+					name = name[:i]
+				}
+			}
+			addSize = packSize
+
+			if flags&rar4FileDirectory != rar4FileDirectory {
+				entries = append(entries, Entry{
+					Name:       string(name),
+					Size:       int64(unpSize),
+					Compressed: int64(packSize),
+					Encrypted:  flags&rar4FileEncrypted != 0,
+				})
+			}
+		case rar4BlockEnd:
+			return entries, nil
+		}
+
+		next := uint64(pos) + uint64(headSize) + addSize
+		if next > uint64(len(data)) {
+			// Truncated, e.g. a part of a multi-volume archive
+			break
+		}
+		pos = int(next)
+	}
+	return entries, nil
+}
+
+// RAR 5 header types and flags
+const (
+	rar5HeaderFile       = 2
+	rar5HeaderEncryption = 4
+	rar5HeaderEnd        = 5
+
+	rar5HasExtra = 0x0001
+	rar5HasData  = 0x0002
+
+	rar5FileDirectory = 0x0001
+	rar5FileTime      = 0x0002
+	rar5FileCRC       = 0x0004
+
+	rar5ExtraEncryption = 0x01
+)
+
+// rar5Reader reads the variable length integers of a RAR 5 header
+type rar5Reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *rar5Reader) vint() uint64 {
+	var v uint64
+	for i := uint(0); i < 10; i++ {
+		if r.pos >= len(r.data) {
+			break
+		}
+		b := r.data[r.pos]
+		r.pos++
+		v |= uint64(b&0x7F) << (7 * i)
+		if b&0x80 == 0 {
+			return v
+		}
+	}
+	r.err = errRAR
+	return 0
+}
+
+func (r *rar5Reader) bytes(n uint64) []byte {
+	if n > uint64(len(r.data)-r.pos) {
+		r.err = errRAR
+		r.pos = len(r.data)
+		return nil
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b
+}
+
+// listRAR5 returns the files of a RAR 5.0 archive
+func listRAR5(data []byte) ([]Entry, error) {
+	var entries []Entry
+	pos := len(rar5Magic)
+
+	for pos+5 < len(data) {
+		// CRC32, then the size of the rest of the header
+		r := &rar5Reader{data: data, pos: pos + 4}
+		headSize := r.vint()
+		if r.err != nil || headSize > uint64(len(data)-r.pos) {
+			return nil, errRAR
+		}
+		h := &rar5Reader{data: data[r.pos : r.pos+int(headSize)]}
+		end := uint64(r.pos) + headSize
+
+		headType := h.vint()
+		flags := h.vint()
+		var extraSize, dataSize uint64
+		if flags&rar5HasExtra != 0 {
+			extraSize = h.vint()
+		}
+		if flags&rar5HasData != 0 {
+			dataSize = h.vint()
+		}
+
+		switch headType {
+		case rar5HeaderEncryption:
+			return nil, ErrEncryptedHeaders
+		case rar5HeaderEnd:
+			return entries, nil
+		case rar5HeaderFile:
+			fileFlags := h.vint()
+			size := h.vint()
+			h.vint() // attributes
+			if fileFlags&rar5FileTime != 0 {
+				h.bytes(4)
+			}
+			if fileFlags&rar5FileCRC != 0 {
+				h.bytes(4)
+			}
+			h.vint() // compression
+			h.vint() // host os
+			name := h.bytes(h.vint())
+
+			encrypted := false
+			if extraSize > 0 && extraSize <= uint64(len(h.data)) {
+				extra := &rar5Reader{data: h.data[len(h.data)-int(extraSize):]}
+				for extra.err == nil && extra.pos < len(extra.data) {
+					record := &rar5Reader{data: extra.bytes(extra.vint())}
+					if record.vint() == rar5ExtraEncryption {
+						encrypted = true
+					}
+				}
+			}
+			if h.err != nil {
+				return nil, h.err
+			}
+
+			if fileFlags&rar5FileDirectory == 0 {
+				entries = append(entries, Entry{
+					Name:       string(name),
+					Size:       int64(size),
+					Compressed: int64(dataSize),
+					Encrypted:  encrypted,
+				})
+			}
+		}
+		if h.err != nil {
+			return nil, errRAR
+		}
+
+		next := end + dataSize
+		if next > uint64(len(data)) {
+			// Truncated, e.g. a part of a multi-volume archive
+			break
+		}
+		pos = int(next)
+	}
+	return entries, nil
+}
@@ -0,0 +1,70 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxReadme is the maximum size of the text returned by Readme, the rest of
+// the readme is cut
+const MaxReadme = 16 << 10
+
+// readmeNames are the names of the readmes, in lower case
+var readmeNames = map[string]bool{"readme": true, "readme.txt": true, "readme.md": true}
+
+// Readme returns the text of the readme of a zip archive: the file of the
+// readme role among its files, else the readme closest to its root. It
+// returns "" when there is none, or when it is encrypted or binary. The
+// other formats are not extracted.
+func Readme(data []byte, files []Entry) string {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return ""
+	}
+
+	name := ""
+	for _, f := range files {
+		if f.Role == RoleReadme {
+			name = f.Name
+		}
+	}
+
+	var readme *zip.File
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if name != "" {
+			if f.Name == name {
+				readme = f
+			}
+		} else if readmeNames[strings.ToLower(path.Base(f.Name))] {
+			if readme == nil || strings.Count(f.Name, "/") < strings.Count(readme.Name, "/") {
+				readme = f
+			}
+		}
+	}
+	if readme == nil || readme.Flags&0x1 != 0 {
+		return ""
+	}
+
+	rc, err := readme.Open()
+	if err != nil {
+		return ""
+	}
+	defer rc.Close()
+	text, err := io.ReadAll(io.LimitReader(rc, MaxReadme))
+	if err != nil || bytes.IndexByte(text, 0) >= 0 {
+		return ""
+	}
+
+	// The cut may split a character
+	for len(text) > 0 && !utf8.Valid(text) {
+		text = text[:len(text)-1]
+	}
+	return string(text)
+}
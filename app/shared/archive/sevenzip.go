@@ -0,0 +1,574 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"unicode/utf16"
+)
+
+// 7z archives. Only the headers are read: plain, or compressed with LZMA as
+// 7-Zip does by default. Archives with encrypted headers can't be listed.
+
+var (
+	sevenZipMagic = []byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}
+
+	errSevenZip = errors.New("corrupted 7z archive")
+
+	// ErrEncryptedHeaders is returned for archives whose file names are
+	// encrypted
+	ErrEncryptedHeaders = errors.New("archive headers are encrypted")
+)
+
+// Property ids of the 7z headers
+const (
+	szEnd               = 0x00
+	szHeader            = 0x01
+	szArchiveProperties = 0x02
+	szAdditionalStreams = 0x03
+	szMainStreams       = 0x04
+	szFilesInfo         = 0x05
+	szPackInfo          = 0x06
+	szUnpackInfo        = 0x07
+	szSubStreamsInfo    = 0x08
+	szSize              = 0x09
+	szCRC               = 0x0A
+	szFolder            = 0x0B
+	szCodersUnpackSize  = 0x0C
+	szNumUnpackStream   = 0x0D
+	szEmptyStream       = 0x0E
+	szEmptyFile         = 0x0F
+	szName              = 0x11
+	szWinAttributes     = 0x15
+	szEncodedHeader     = 0x17
+)
+
+// maxHeaderSize caps the size of a decompressed 7z header
+const maxHeaderSize = 16 << 20
+
+// Coder ids of the 7z methods
+var (
+	szMethodCopy  = []byte{0x00}
+	szMethodLZMA  = []byte{0x03, 0x01, 0x01}
+	szMethodLZMA2 = []byte{0x21}
+	szMethodAES   = []byte{0x06, 0xF1, 0x07, 0x01}
+)
+
+type szCoder struct {
+	id     []byte
+	props  []byte
+	numIn  int
+	numOut int
+}
+
+type szFolderInfo struct {
+	coders      []szCoder
+	bound       map[uint64]bool
+	unpackSizes []uint64
+}
+
+// unpackSize is the size of the final output of the folder, the only one not
+// bound to the input of another coder
+func (f szFolderInfo) unpackSize() uint64 {
+	for i, size := range f.unpackSizes {
+		if !f.bound[uint64(i)] {
+			return size
+		}
+	}
+	return 0
+}
+
+func (f szFolderInfo) encrypted() bool {
+	for _, c := range f.coders {
+		if bytes.Equal(c.id, szMethodAES) {
+			return true
+		}
+	}
+	return false
+}
+
+type szStreams struct {
+	packPos      uint64
+	packSizes    []uint64
+	folders      []szFolderInfo
+	streamCounts []uint64
+	streamSizes  []uint64
+}
+
+// szReader reads the fields of a 7z header
+type szReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *szReader) byte() byte {
+	if r.pos >= len(r.data) {
+		r.err = errSevenZip
+		return 0
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *szReader) bytes(n uint64) []byte {
+	if n > uint64(len(r.data)-r.pos) {
+		r.err = errSevenZip
+		r.pos = len(r.data)
+		return nil
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b
+}
+
+func (r *szReader) uint16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (r *szReader) uint32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+// number reads a 7z variable length number: the count of leading one bits of
+// the first byte is the number of bytes that follow
+func (r *szReader) number() uint64 {
+	first := r.byte()
+	mask := byte(0x80)
+	var value uint64
+	for i := 0; i < 8; i++ {
+		if first&mask == 0 {
+			high := uint64(first & (mask - 1))
+			return value | high<<(8*uint(i))
+		}
+		value |= uint64(r.byte()) << (8 * uint(i))
+		mask >>= 1
+	}
+	return value
+}
+
+// count reads a number used to size a slice, rejecting absurd ones
+func (r *szReader) count() int {
+	n := r.number()
+	if n > uint64(len(r.data)) {
+		r.err = errSevenZip
+		return 0
+	}
+	return int(n)
+}
+
+func (r *szReader) bits(n int) []bool {
+	v := make([]bool, n)
+	var b byte
+	for i := 0; i < n; i++ {
+		if i%8 == 0 {
+			b = r.byte()
+		}
+		v[i] = b&(0x80>>uint(i%8)) != 0
+	}
+	return v
+}
+
+// optionalBits reads an "all defined" flag followed, if unset, by a bit vector
+func (r *szReader) optionalBits(n int) []bool {
+	if r.byte() != 0 {
+		v := make([]bool, n)
+		for i := range v {
+			v[i] = true
+		}
+		return v
+	}
+	return r.bits(n)
+}
+
+func (r *szReader) digests(n int) {
+	for _, defined := range r.optionalBits(n) {
+		if defined {
+			r.bytes(4)
+		}
+	}
+}
+
+func (r *szReader) packInfo(s *szStreams) {
+	s.packPos = r.number()
+	n := r.count()
+	for r.err == nil {
+		switch r.number() {
+		case szEnd:
+			return
+		case szSize:
+			s.packSizes = make([]uint64, n)
+			for i := range s.packSizes {
+				s.packSizes[i] = r.number()
+			}
+		case szCRC:
+			r.digests(n)
+		default:
+			r.err = errSevenZip
+		}
+	}
+}
+
+func (r *szReader) folder() szFolderInfo {
+	var f szFolderInfo
+	var totalIn, totalOut int
+	n := r.count()
+	for i := 0; i < n && r.err == nil; i++ {
+		flags := r.byte()
+		c := szCoder{id: r.bytes(uint64(flags & 0x0F)), numIn: 1, numOut: 1}
+		if flags&0x10 != 0 {
+			c.numIn = r.count()
+			c.numOut = r.count()
+		}
+		if flags&0x20 != 0 {
+			c.props = r.bytes(r.number())
+		}
+		totalIn += c.numIn
+		totalOut += c.numOut
+		f.coders = append(f.coders, c)
+	}
+
+	bindPairs := totalOut - 1
+	if r.err != nil || totalOut == 0 || totalIn < bindPairs || totalOut > len(r.data) {
+		r.err = errSevenZip
+		return f
+	}
+
+	f.bound = make(map[uint64]bool)
+	for i := 0; i < bindPairs && r.err == nil; i++ {
+		r.number()
+		f.bound[r.number()] = true
+	}
+	if packed := totalIn - bindPairs; packed > 1 {
+		for i := 0; i < packed && r.err == nil; i++ {
+			r.number()
+		}
+	}
+	f.unpackSizes = make([]uint64, totalOut)
+	return f
+}
+
+func (r *szReader) unpackInfo(s *szStreams) {
+	if r.number() != szFolder {
+		r.err = errSevenZip
+		return
+	}
+	n := r.count()
+	if r.byte() != 0 {
+		// Folders stored in another stream, never written by 7-Zip
+		r.err = errSevenZip
+		return
+	}
+	s.folders = make([]szFolderInfo, n)
+	for i := range s.folders {
+		s.folders[i] = r.folder()
+	}
+
+	if r.number() != szCodersUnpackSize {
+		r.err = errSevenZip
+		return
+	}
+	for i := range s.folders {
+		for j := range s.folders[i].unpackSizes {
+			s.folders[i].unpackSizes[j] = r.number()
+		}
+	}
+
+	for r.err == nil {
+		switch r.number() {
+		case szEnd:
+			return
+		case szCRC:
+			r.digests(len(s.folders))
+		default:
+			r.err = errSevenZip
+		}
+	}
+}
+
+func (r *szReader) subStreamsInfo(s *szStreams) {
+	s.streamCounts = make([]uint64, len(s.folders))
+	for i := range s.streamCounts {
+		s.streamCounts[i] = 1
+	}
+
+	id := r.number()
+	if id == szNumUnpackStream {
+		total := 0
+		for i := range s.streamCounts {
+			n := r.count()
+			s.streamCounts[i] = uint64(n)
+			total += n
+		}
+		if total > len(r.data) {
+			r.err = errSevenZip
+			return
+		}
+		id = r.number()
+	}
+
+	hasSizes := id == szSize
+	for i, f := range s.folders {
+		if s.streamCounts[i] == 0 {
+			continue
+		}
+		var sum uint64
+		for j := uint64(1); j < s.streamCounts[i] && hasSizes && r.err == nil; j++ {
+			size := r.number()
+			s.streamSizes = append(s.streamSizes, size)
+			sum += size
+		}
+		s.streamSizes = append(s.streamSizes, f.unpackSize()-sum)
+	}
+	if hasSizes {
+		id = r.number()
+	}
+
+	for r.err == nil && id != szEnd {
+		if id != szCRC {
+			r.err = errSevenZip
+			return
+		}
+		// Digests of the streams whose folder has no single digest
+		n := 0
+		for _, c := range s.streamCounts {
+			n += int(c)
+		}
+		r.digests(n)
+		id = r.number()
+	}
+}
+
+func (r *szReader) streamsInfo() *szStreams {
+	s := &szStreams{}
+	for r.err == nil {
+		switch r.number() {
+		case szEnd:
+			if s.streamCounts == nil {
+				// No substreams: one stream per folder
+				for _, f := range s.folders {
+					s.streamCounts = append(s.streamCounts, 1)
+					s.streamSizes = append(s.streamSizes, f.unpackSize())
+				}
+			}
+			return s
+		case szPackInfo:
+			r.packInfo(s)
+		case szUnpackInfo:
+			r.unpackInfo(s)
+		case szSubStreamsInfo:
+			r.subStreamsInfo(s)
+		default:
+			r.err = errSevenZip
+		}
+	}
+	return s
+}
+
+func (r *szReader) filesInfo(s *szStreams) []Entry {
+	n := r.count()
+	if r.err != nil {
+		return nil
+	}
+	names := make([]string, n)
+	var emptyStream, emptyFile []bool
+	var attributes []uint32
+
+	for r.err == nil {
+		id := r.number()
+		if id == szEnd {
+			break
+		}
+		size := r.number()
+		prop := &szReader{data: r.bytes(size)}
+		if r.err != nil {
+			break
+		}
+
+		switch id {
+		case szEmptyStream:
+			emptyStream = prop.bits(n)
+		case szEmptyFile:
+			empty := 0
+			for _, e := range emptyStream {
+				if e {
+					empty++
+				}
+			}
+			emptyFile = prop.bits(empty)
+		case szName:
+			if prop.byte() != 0 {
+				r.err = errSevenZip
+				break
+			}
+			for i := range names {
+				var u []uint16
+				for prop.err == nil {
+					c := prop.uint16()
+					if c == 0 {
+						break
+					}
+					u = append(u, c)
+				}
+				names[i] = string(utf16.Decode(u))
+			}
+		case szWinAttributes:
+			defined := prop.optionalBits(n)
+			if prop.byte() != 0 {
+				break
+			}
+			attributes = make([]uint32, n)
+			for i, d := range defined {
+				if d {
+					attributes[i] = prop.uint32()
+				}
+			}
+		}
+		if prop.err != nil {
+			r.err = prop.err
+		}
+	}
+	if r.err != nil {
+		return nil
+	}
+
+	// Files with a stream take the streams in order, folder after folder
+	var streamFolder []int
+	for i, c := range s.streamCounts {
+		for j := uint64(0); j < c; j++ {
+			streamFolder = append(streamFolder, i)
+		}
+	}
+
+	var entries []Entry
+	stream, empty := 0, 0
+	for i := 0; i < n; i++ {
+		if i < len(emptyStream) && emptyStream[i] {
+			isFile := empty < len(emptyFile) && emptyFile[empty]
+			empty++
+			isDir := !isFile || (attributes != nil && attributes[i]&0x10 != 0)
+			if !isDir {
+				entries = append(entries, Entry{Name: names[i]})
+			}
+			continue
+		}
+
+		if stream >= len(s.streamSizes) {
+			r.err = errSevenZip
+			return nil
+		}
+		entries = append(entries, Entry{
+			Name:      names[i],
+			Size:      int64(s.streamSizes[stream]),
+			Encrypted: s.folders[streamFolder[stream]].encrypted(),
+		})
+		stream++
+	}
+	return entries
+}
+
+func (r *szReader) header() []Entry {
+	s := &szStreams{}
+	for r.err == nil {
+		switch r.number() {
+		case szEnd:
+			return nil
+		case szArchiveProperties:
+			for r.err == nil && r.number() != szEnd {
+				r.bytes(r.number())
+			}
+		case szAdditionalStreams:
+			r.streamsInfo()
+		case szMainStreams:
+			s = r.streamsInfo()
+		case szFilesInfo:
+			entries := r.filesInfo(s)
+			if r.err == nil && r.number() != szEnd {
+				r.err = errSevenZip
+			}
+			return entries
+		default:
+			r.err = errSevenZip
+		}
+	}
+	return nil
+}
+
+// decodeHeader decompresses an encoded 7z header
+func decodeHeader(data []byte, s *szStreams) ([]byte, error) {
+	if len(s.folders) != 1 || len(s.packSizes) < 1 || len(s.folders[0].coders) != 1 {
+		return nil, errSevenZip
+	}
+	f := s.folders[0]
+	if f.encrypted() {
+		return nil, ErrEncryptedHeaders
+	}
+
+	start := 32 + s.packPos
+	if start > uint64(len(data)) || s.packSizes[0] > uint64(len(data))-start || f.unpackSize() > maxHeaderSize {
+		return nil, errSevenZip
+	}
+	packed := data[start : start+s.packSizes[0]]
+
+	c := f.coders[0]
+	switch {
+	case bytes.Equal(c.id, szMethodCopy):
+		if uint64(len(packed)) < f.unpackSize() {
+			return nil, errSevenZip
+		}
+		return packed[:f.unpackSize()], nil
+	case bytes.Equal(c.id, szMethodLZMA):
+		return lzmaDecode(c.props, packed, int(f.unpackSize()))
+	case bytes.Equal(c.id, szMethodLZMA2):
+		return lzma2Decode(packed, int(f.unpackSize()))
+	}
+	return nil, errSevenZip
+}
+
+// listSevenZip returns the files of a 7z archive
+func listSevenZip(data []byte) ([]Entry, error) {
+	if len(data) < 32 {
+		return nil, errSevenZip
+	}
+	offset := binary.LittleEndian.Uint64(data[12:20])
+	size := binary.LittleEndian.Uint64(data[20:28])
+	if offset > uint64(len(data))-32 || size > uint64(len(data))-32-offset {
+		return nil, errSevenZip
+	}
+	if size == 0 {
+		// Empty archive
+		return nil, nil
+	}
+	header := data[32+offset : 32+offset+size]
+
+	// A compressed header is described by streams info, and may itself be
+	// compressed again
+	for i := 0; i < 4 && len(header) > 0 && header[0] == szEncodedHeader; i++ {
+		r := &szReader{data: header, pos: 1}
+		s := r.streamsInfo()
+		if r.err != nil {
+			return nil, r.err
+		}
+		var err error
+		header, err = decodeHeader(data, s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(header) == 0 || header[0] != szHeader {
+		return nil, errSevenZip
+	}
+	r := &szReader{data: header, pos: 1}
+	entries := r.header()
+	return entries, r.err
+}
@@ -0,0 +1,96 @@
+package archive
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+var (
+	// ErrPassword is returned when a zip file doesn't decrypt with the password
+	ErrPassword = errors.New("wrong zip password")
+)
+
+// zipCrypto is the state of the traditional PKWARE encryption of zip files,
+// the one of the stored files (zip --password)
+type zipCrypto struct {
+	keys [3]uint32
+}
+
+func crc32Update(crc uint32, b byte) uint32 {
+	return crc32.IEEETable[byte(crc)^b] ^ (crc >> 8)
+}
+
+func newZipCrypto(password string) *zipCrypto {
+	z := &zipCrypto{keys: [3]uint32{0x12345678, 0x23456789, 0x34567890}}
+	for i := 0; i < len(password); i++ {
+		z.update(password[i])
+	}
+	return z
+}
+
+func (z *zipCrypto) update(b byte) {
+	z.keys[0] = crc32Update(z.keys[0], b)
+	z.keys[1] = (z.keys[1]+z.keys[0]&0xff)*134775813 + 1
+	z.keys[2] = crc32Update(z.keys[2], byte(z.keys[1]>>24))
+}
+
+func (z *zipCrypto) decrypt(data []byte) {
+	for i, c := range data {
+		t := z.keys[2] | 2
+		data[i] = c ^ byte((t*(t^1))>>8)
+		z.update(data[i])
+	}
+}
+
+// zipCryptoReader decrypts the raw data of a zip file
+type zipCryptoReader struct {
+	r io.Reader
+	z *zipCrypto
+}
+
+func (r *zipCryptoReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.z.decrypt(p[:n])
+	return n, err
+}
+
+// OpenEncrypted returns the content of a zip file encrypted with password. Only
+// the traditional encryption of stored or deflated files is supported.
+func OpenEncrypted(f *zip.File, password string) (io.ReadCloser, error) {
+	if f.Flags&0x1 == 0 {
+		return f.Open()
+	}
+	if f.Method != zip.Store && f.Method != zip.Deflate {
+		return nil, zip.ErrAlgorithm
+	}
+
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	// The 12 bytes header ends with a byte of the CRC, or of the time when the
+	// CRC follows the data, to check the password
+	z := newZipCrypto(password)
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(raw, header); err != nil {
+		return nil, err
+	}
+	z.decrypt(header)
+	check := byte(f.CRC32 >> 24)
+	if f.Flags&0x8 != 0 {
+		check = byte(f.ModifiedTime >> 8)
+	}
+	if header[11] != check {
+		return nil, ErrPassword
+	}
+
+	r := &zipCryptoReader{r: raw, z: z}
+	if f.Method == zip.Deflate {
+		return flate.NewReader(r), nil
+	}
+	return io.NopCloser(r), nil
+}
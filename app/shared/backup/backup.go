@@ -0,0 +1,353 @@
+// Package backup takes snapshots of the database along with a manifest of the
+// storage files and their hashes, so the files and the documents can be
+// checked against each other before a restore.
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// ManifestFile is the name of the manifest in a snapshot directory
+	ManifestFile = "manifest.json"
+
+	// timeFormat names the snapshot directories, sorting them by date
+	timeFormat = "2006-01-02T15-04-05Z"
+)
+
+var (
+	// ErrCorrupted is returned when a database dump doesn't match its hash
+	ErrCorrupted = errors.New("snapshot is corrupted")
+	// ErrStorageMismatch is returned when the storage files don't match the
+	// manifest of a snapshot
+	ErrStorageMismatch = errors.New("storage doesn't match the snapshot")
+)
+
+// Info contains the backup settings
+type Info struct {
+	Enabled  bool     // Take snapshots on a schedule
+	Dir      string   // Directory of the snapshots
+	Interval int      // Hours between two scheduled snapshots
+	Keep     int      // Number of snapshots kept, 0 keeps them all
+	Paths    []string // Storage directories listed in the manifests
+}
+
+// Database gives access to the collections, see model.BackupDatabase
+type Database interface {
+	// CollectionNames returns the names of the collections
+	CollectionNames() ([]string, error)
+	// Export writes the documents of a collection, returning their number
+	Export(name string, w io.Writer) (int, error)
+	// Import loads the exported documents of a collection aside, leaving the
+	// collection itself untouched, and returns their number
+	Import(name string, r io.Reader) (int, error)
+	// Replace replaces a collection by the documents loaded aside by Import
+	Replace(name string) error
+	// Discard drops the documents loaded aside by Import
+	Discard(name string) error
+}
+
+// Manifest describes a snapshot
+type Manifest struct {
+	CreatedAt   time.Time    `json:"created_at"`
+	Collections []Collection `json:"collections"`
+	Files       []File       `json:"files"`
+}
+
+// Collection is the dump of a collection in a snapshot
+type Collection struct {
+	Name      string `json:"name"`
+	File      string `json:"file"`
+	Documents int    `json:"documents"`
+	SHA256    string `json:"sha256"`
+}
+
+// File is a storage file listed in a manifest
+type File struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// hashFile returns the hex encoded SHA-256 and the size of a file
+func hashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// listFiles hashes every file under the storage directories
+func listFiles(paths []string) ([]File, error) {
+	var files []File
+	for _, root := range paths {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) && path == root {
+				return nil
+			}
+			if err != nil || info.IsDir() {
+				return err
+			}
+			sum, size, err := hashFile(path)
+			if err != nil {
+				return err
+			}
+			files = append(files, File{Path: filepath.ToSlash(path), Size: size, SHA256: sum})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// Snapshot dumps every collection and lists the storage files in a new
+// directory of c.Dir, and returns its path. The directory only gets its final
+// name once complete.
+func Snapshot(c Info, db Database) (string, error) {
+	now := time.Now().UTC()
+	dir := filepath.Join(c.Dir, now.Format(timeFormat))
+	partial := dir + ".partial"
+	if err := os.MkdirAll(filepath.Join(partial, "db"), 0700); err != nil {
+		return "", err
+	}
+
+	manifest := Manifest{CreatedAt: now}
+
+	names, err := db.CollectionNames()
+	if err != nil {
+		os.RemoveAll(partial)
+		return "", err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		file := "db/" + name + ".jsonl"
+		f, err := os.Create(filepath.Join(partial, file))
+		if err != nil {
+			os.RemoveAll(partial)
+			return "", err
+		}
+		h := sha256.New()
+		n, err := db.Export(name, io.MultiWriter(f, h))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.RemoveAll(partial)
+			return "", fmt.Errorf("exporting %s: %v", name, err)
+		}
+		manifest.Collections = append(manifest.Collections, Collection{
+			Name:      name,
+			File:      file,
+			Documents: n,
+			SHA256:    hex.EncodeToString(h.Sum(nil)),
+		})
+	}
+
+	// Listed after the dump, so a file uploaded meanwhile shows up as an
+	// extra file rather than as a document without its file
+	if manifest.Files, err = listFiles(c.Paths); err != nil {
+		os.RemoveAll(partial)
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(partial, ManifestFile), data, 0600)
+	}
+	if err == nil {
+		err = os.Rename(partial, dir)
+	}
+	if err != nil {
+		os.RemoveAll(partial)
+		return "", err
+	}
+	return dir, nil
+}
+
+// ReadManifest reads the manifest of a snapshot
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// VerifyDump checks the database dumps of a snapshot against their hashes
+func VerifyDump(dir string, m *Manifest) error {
+	for _, c := range m.Collections {
+		sum, _, err := hashFile(filepath.Join(dir, filepath.FromSlash(c.File)))
+		if err != nil {
+			return err
+		}
+		if sum != c.SHA256 {
+			return fmt.Errorf("%v: %s", ErrCorrupted, c.File)
+		}
+	}
+	return nil
+}
+
+// VerifyStorage compares the storage files to the manifest of a snapshot,
+// and describes every missing, changed or extra file
+func VerifyStorage(m *Manifest, paths []string) ([]string, error) {
+	current, err := listFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]File)
+	for _, f := range current {
+		found[f.Path] = f
+	}
+
+	var problems []string
+	for _, f := range m.Files {
+		cur, ok := found[f.Path]
+		switch {
+		case !ok:
+			problems = append(problems, "missing: "+f.Path)
+		case cur.SHA256 != f.SHA256:
+			problems = append(problems, "changed: "+f.Path)
+		}
+		delete(found, f.Path)
+	}
+	for path := range found {
+		problems = append(problems, "extra: "+path)
+	}
+	sort.Strings(problems)
+	return problems, nil
+}
+
+// Restore replaces the collections by the ones of a snapshot. The dumps must
+// match their hashes, and unless forced, the storage must match the manifest.
+// Every dump is loaded aside before any collection is replaced, so a failed
+// import leaves the database as it was.
+func Restore(dir string, c Info, db Database, force bool) error {
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return err
+	}
+	if err := VerifyDump(dir, m); err != nil {
+		return err
+	}
+
+	problems, err := VerifyStorage(m, c.Paths)
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		log.Println("Backup:", p)
+	}
+	if len(problems) > 0 && !force {
+		return ErrStorageMismatch
+	}
+
+	for i, col := range m.Collections {
+		if err := importDump(dir, col, db); err != nil {
+			for _, c := range m.Collections[:i+1] {
+				if err := db.Discard(c.Name); err != nil {
+					log.Println("Backup:", err)
+				}
+			}
+			return fmt.Errorf("importing %s: %v", col.Name, err)
+		}
+	}
+
+	for _, col := range m.Collections {
+		if err := db.Replace(col.Name); err != nil {
+			return fmt.Errorf("replacing %s: %v", col.Name, err)
+		}
+	}
+	return nil
+}
+
+// importDump loads the dump of a collection aside, checking its number of
+// documents
+func importDump(dir string, col Collection, db Database) error {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(col.File)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := db.Import(col.Name, f)
+	if err == nil && n != col.Documents {
+		err = fmt.Errorf("%d documents instead of %d", n, col.Documents)
+	}
+	return err
+}
+
+// Prune removes the oldest snapshots beyond c.Keep
+func Prune(c Info) error {
+	if c.Keep <= 0 {
+		return nil
+	}
+
+	entries, err := ioutil.ReadDir(c.Dir)
+	if err != nil {
+		return err
+	}
+	var snapshots []string
+	for _, e := range entries {
+		if _, err := time.Parse(timeFormat, e.Name()); e.IsDir() && err == nil {
+			snapshots = append(snapshots, e.Name())
+		}
+	}
+	sort.Strings(snapshots)
+
+	for len(snapshots) > c.Keep {
+		if err := os.RemoveAll(filepath.Join(c.Dir, snapshots[0])); err != nil {
+			return err
+		}
+		snapshots = snapshots[1:]
+	}
+	return nil
+}
+
+// Schedule takes a snapshot and prunes the old ones every c.Interval hours,
+// forever
+func Schedule(c Info, db Database) {
+	if c.Interval <= 0 {
+		c.Interval = 24
+	}
+	for {
+		time.Sleep(time.Duration(c.Interval) * time.Hour)
+
+		dir, err := Snapshot(c, db)
+		if err != nil {
+			log.Println("Backup failed:", err)
+			continue
+		}
+		log.Println("Backup taken:", dir)
+
+		if err := Prune(c); err != nil {
+			log.Println("Backup pruning failed:", err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package backup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// memoryDatabase stores collections as lists of lines, the ones loaded aside
+// under a "restore." prefix
+type memoryDatabase map[string][]string
+
+func (m memoryDatabase) CollectionNames() ([]string, error) {
+	var names []string
+	for name := range m {
+		if !strings.HasPrefix(name, "restore.") {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (m memoryDatabase) Export(name string, w io.Writer) (int, error) {
+	for _, doc := range m[name] {
+		fmt.Fprintln(w, doc)
+	}
+	return len(m[name]), nil
+}
+
+func (m memoryDatabase) Import(name string, r io.Reader) (int, error) {
+	docs := []string{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		docs = append(docs, s.Text())
+	}
+	m["restore."+name] = docs
+	return len(docs), s.Err()
+}
+
+func (m memoryDatabase) Replace(name string) error {
+	m[name] = m["restore."+name]
+	delete(m, "restore."+name)
+	return nil
+}
+
+func (m memoryDatabase) Discard(name string) error {
+	delete(m, "restore."+name)
+	return nil
+}
+
+// failingDatabase fails the import of a collection
+type failingDatabase struct {
+	memoryDatabase
+	name string
+}
+
+func (f failingDatabase) Import(name string, r io.Reader) (int, error) {
+	if name == f.name {
+		return 0, errors.New("import failed")
+	}
+	return f.memoryDatabase.Import(name, r)
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	root, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	storage := filepath.Join(root, "static")
+	os.MkdirAll(filepath.Join(storage, "crackme"), 0700)
+	ioutil.WriteFile(filepath.Join(storage, "crackme", "a.zip"), []byte("crackme a"), 0600)
+
+	c := Info{Dir: filepath.Join(root, "backups"), Paths: []string{storage, filepath.Join(root, "none")}, Keep: 1}
+	db := memoryDatabase{"crackme": {`{"hexid":"a"}`}, "user": {`{"name":"x"}`, `{"name":"y"}`}}
+
+	dir, err := Snapshot(c, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Collections) != 2 || m.Collections[1].Documents != 2 || len(m.Files) != 1 {
+		t.Fatalf("got manifest %+v", m)
+	}
+
+	// The storage drifted: the restore needs to be forced
+	ioutil.WriteFile(filepath.Join(storage, "crackme", "b.zip"), []byte("crackme b"), 0600)
+	db["crackme"] = nil
+	if err := Restore(dir, c, db, false); err != ErrStorageMismatch {
+		t.Fatalf("got %v, want ErrStorageMismatch", err)
+	}
+	problems, _ := VerifyStorage(m, c.Paths)
+	if len(problems) != 1 || problems[0] != "extra: "+filepath.ToSlash(filepath.Join(storage, "crackme", "b.zip")) {
+		t.Errorf("got problems %q", problems)
+	}
+	if err := Restore(dir, c, db, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(db["crackme"]) != 1 {
+		t.Errorf("crackme not restored: %q", db["crackme"])
+	}
+
+	// A failed import leaves every collection as it was
+	db["crackme"] = nil
+	if err := Restore(dir, c, failingDatabase{db, "user"}, true); err == nil {
+		t.Error("restore of a failing import succeeded")
+	}
+	if len(db["crackme"]) != 0 || len(db) != 2 {
+		t.Errorf("database changed by a failed restore: %q", db)
+	}
+
+	// A corrupted dump is never restored
+	ioutil.WriteFile(filepath.Join(dir, "db", "user.jsonl"), []byte("{}\n"), 0600)
+	if err := Restore(dir, c, db, true); err == nil {
+		t.Error("restored a corrupted dump")
+	}
+
+	// Only the latest snapshot is kept
+	os.Rename(dir, filepath.Join(c.Dir, "2000-01-01T00-00-00Z"))
+	if _, err := Snapshot(c, db); err != nil {
+		t.Fatal(err)
+	}
+	if err := Prune(c); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(c.Dir, "2000-01-01T00-00-00Z")); !os.IsNotExist(err) {
+		t.Error("old snapshot not pruned")
+	}
+}
@@ -0,0 +1,46 @@
+// Package badge draws flat SVG badges, a grey label followed by a colored
+// message, in the style READMEs already show for builds and coverage.
+package badge
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+)
+
+// Colors of the message part
+const (
+	Green = "#4c1"
+	Blue  = "#007ec6"
+	Grey  = "#9f9f9f"
+)
+
+// charWidth is the average width of a character of 11px Verdana, close
+// enough for the short texts of the badges
+const charWidth = 7
+
+// padding is the space on each side of a text
+const padding = 6
+
+// width returns the width a text takes in a badge
+func width(text string) int {
+	return len([]rune(text))*charWidth + 2*padding
+}
+
+// SVG returns the badge of a label and a message
+func SVG(label, message, color string) []byte {
+	lw, mw := width(label), width(message)
+	label, message = html.EscapeString(label), html.EscapeString(message)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`, lw+mw, label, message)
+	fmt.Fprintf(&b, `<title>%s: %s</title>`, label, message)
+	b.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`)
+	fmt.Fprintf(&b, `<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>`, lw+mw)
+	fmt.Fprintf(&b, `<g clip-path="url(#r)"><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="%s"/><rect width="%d" height="20" fill="url(#s)"/></g>`, lw, lw, mw, color, lw+mw)
+	b.WriteString(`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`)
+	fmt.Fprintf(&b, `<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`, lw/2, label, lw/2, label)
+	fmt.Fprintf(&b, `<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`, lw+mw/2, message, lw+mw/2, message)
+	b.WriteString(`</g></svg>`)
+	return b.Bytes()
+}
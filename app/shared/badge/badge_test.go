@@ -0,0 +1,36 @@
+package badge
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestSVG(t *testing.T) {
+	svg := SVG("crackmes.one", "42 solves, rank #120", Green)
+
+	// Well-formed, with the whole text readable by screen readers
+	var doc struct {
+		Width string `xml:"width,attr"`
+		Title string `xml:"title"`
+	}
+	if err := xml.Unmarshal(svg, &doc); err != nil {
+		t.Fatalf("SVG() is not valid XML: %v", err)
+	}
+	if doc.Title != "crackmes.one: 42 solves, rank #120" {
+		t.Errorf("title = %q", doc.Title)
+	}
+	if want := "248"; doc.Width != want {
+		t.Errorf("width = %s, want %s", doc.Width, want)
+	}
+}
+
+func TestSVGEscapes(t *testing.T) {
+	svg := string(SVG("a<b", `"&"`, Grey))
+	if strings.Contains(svg, "a<b") || strings.Contains(svg, `"&"`) {
+		t.Errorf("SVG() does not escape the texts: %s", svg)
+	}
+	if err := xml.Unmarshal([]byte(svg), new(struct{})); err != nil {
+		t.Errorf("SVG() is not valid XML: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// pruneEvery is the number of writes between two prunings of the expired
+// entries
+const pruneEvery = 256
+
+// Cache is an in-memory key/value store whose entries expire after a fixed
+// duration. It is safe for concurrent use.
+type Cache struct {
+	ttl   time.Duration
+	mutex sync.RWMutex
+	items map[string]item
+	sets  int // Writes since the last pruning
+}
+
+type item struct {
+	value   interface{}
+	expires time.Time
+}
+
+// New returns an empty cache keeping entries for ttl
+func New(ttl time.Duration) *Cache {
+	return &Cache{
+		ttl:   ttl,
+		items: make(map[string]item),
+	}
+}
+
+// Get returns the value stored for key, if it has not expired yet
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.mutex.RLock()
+	it, ok := c.items[key]
+	c.mutex.RUnlock()
+
+	if !ok || time.Now().After(it.expires) {
+		return nil, false
+	}
+	return it.value, true
+}
+
+// Set stores value for key, replacing any previous value. Expired entries are
+// pruned every pruneEvery writes so the cache does not grow without bound,
+// without scanning it on every write.
+func (c *Cache) Set(key string, value interface{}) {
+	now := time.Now()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.sets++
+	if c.sets >= pruneEvery {
+		c.sets = 0
+		for k, it := range c.items {
+			if now.After(it.expires) {
+				delete(c.items, k)
+			}
+		}
+	}
+	c.items[key] = item{value: value, expires: now.Add(c.ttl)}
+}
+
+// Delete removes the value stored for key
+func (c *Cache) Delete(key string) {
+	c.mutex.Lock()
+	delete(c.items, key)
+	c.mutex.Unlock()
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetGet(t *testing.T) {
+	c := New(time.Minute)
+	c.Set("key", 42)
+
+	v, ok := c.Get("key")
+	if !ok || v.(int) != 42 {
+		t.Error("Value not found")
+	}
+
+	if _, ok := c.Get("other"); ok {
+		t.Error("Unexpected value")
+	}
+}
+
+func TestExpire(t *testing.T) {
+	c := New(time.Millisecond)
+	c.Set("key", 42)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.Get("key"); ok {
+		t.Error("Value did not expire")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	c := New(time.Millisecond)
+	c.Set("key", 42)
+	time.Sleep(5 * time.Millisecond)
+
+	for i := 1; i < pruneEvery; i++ {
+		c.Set("other", i)
+	}
+	if _, ok := c.items["key"]; ok {
+		t.Error("Expired value not pruned")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	c := New(time.Minute)
+	c.Set("key", 42)
+	c.Delete("key")
+
+	if _, ok := c.Get("key"); ok {
+		t.Error("Value not deleted")
+	}
+}
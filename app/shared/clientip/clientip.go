@@ -0,0 +1,114 @@
+// Package clientip gives the address of the clients in a single form, for the
+// audit log and the sign-ins, and the network they are counted in by the rate
+// limits. An IPv6 user usually holds a whole /64 and picks a new address in it
+// at will, the limits count the /64 by default.
+package clientip
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Info contains the sizes of the networks counted by the rate limits
+type Info struct {
+	IPv4Prefix int // Bits of the IPv4 networks, 32 by default: each address apart
+	IPv6Prefix int // Bits of the IPv6 networks, 64 by default
+}
+
+var (
+	info  = Info{IPv4Prefix: 32, IPv6Prefix: 64}
+	mutex sync.RWMutex
+)
+
+// Configure stores the settings, the prefixes out of range are set to their
+// default
+func Configure(c Info) {
+	if c.IPv4Prefix <= 0 || c.IPv4Prefix > 32 {
+		c.IPv4Prefix = 32
+	}
+	if c.IPv6Prefix <= 0 || c.IPv6Prefix > 128 {
+		c.IPv6Prefix = 64
+	}
+
+	mutex.Lock()
+	info = c
+	mutex.Unlock()
+}
+
+// ReadConfig returns the settings
+func ReadConfig() Info {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return info
+}
+
+// FromRequest returns the normalized address of the client of a request
+func FromRequest(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return Normalize(host)
+}
+
+// Normalize returns an address in its canonical form: the IPv6 addresses
+// compressed in lower case, without their zone, and the IPv4 addresses mapped
+// in IPv6 as IPv4. What isn't an address is returned as is.
+func Normalize(addr string) string {
+	if i := strings.IndexByte(addr, '%'); i >= 0 {
+		addr = addr[:i]
+	}
+	ip := net.ParseIP(strings.Trim(addr, "[]"))
+	if ip == nil {
+		return addr
+	}
+	return ip.String()
+}
+
+// Network returns the key of the network of an address in the rate limits:
+// the address itself for the full length prefixes, else the network in CIDR
+// notation, e.g. 2001:db8:1:2::/64
+func Network(addr string) string {
+	addr = Normalize(addr)
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return addr
+	}
+
+	c := ReadConfig()
+	bits, prefix := 128, c.IPv6Prefix
+	if v4 := ip.To4(); v4 != nil {
+		ip, bits, prefix = v4, 32, c.IPv4Prefix
+	}
+	if prefix >= bits {
+		return addr
+	}
+	return ip.Mask(net.CIDRMask(prefix, bits)).String() + "/" + strconv.Itoa(prefix)
+}
+
+// NetworkFromRequest returns the network of the client of a request in the
+// rate limits
+func NetworkFromRequest(r *http.Request) string {
+	return Network(FromRequest(r))
+}
+
+// Describe tells what a rate limit counts per, e.g. "IPv6 /64 network" in the
+// quotas shown to the users
+func Describe(addr string) string {
+	ip := net.ParseIP(Normalize(addr))
+	c := ReadConfig()
+	switch {
+	case ip == nil:
+		return "address"
+	case ip.To4() != nil && c.IPv4Prefix >= 32:
+		return "IP"
+	case ip.To4() != nil:
+		return "IPv4 /" + strconv.Itoa(c.IPv4Prefix) + " network"
+	case c.IPv6Prefix >= 128:
+		return "IP"
+	}
+	return "IPv6 /" + strconv.Itoa(c.IPv6Prefix) + " network"
+}
@@ -0,0 +1,43 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNetwork(t *testing.T) {
+	defer Configure(Info{})
+
+	Configure(Info{})
+	for _, c := range []struct{ addr, normal, network string }{
+		{"1.2.3.4", "1.2.3.4", "1.2.3.4"},
+		{"::ffff:1.2.3.4", "1.2.3.4", "1.2.3.4"},
+		{"2001:DB8:0:0:1::1", "2001:db8::1:0:0:1", "2001:db8::/64"},
+		{"2001:db8:1:2:aaaa::1", "2001:db8:1:2:aaaa::1", "2001:db8:1:2::/64"},
+		{"fe80::1%eth0", "fe80::1", "fe80::/64"},
+		{"not an ip", "not an ip", "not an ip"},
+	} {
+		if got := Normalize(c.addr); got != c.normal {
+			t.Errorf("Normalize(%q) = %q, want %q", c.addr, got, c.normal)
+		}
+		if got := Network(c.addr); got != c.network {
+			t.Errorf("Network(%q) = %q, want %q", c.addr, got, c.network)
+		}
+	}
+
+	Configure(Info{IPv4Prefix: 24, IPv6Prefix: 48})
+	if got := Network("1.2.3.4"); got != "1.2.3.0/24" {
+		t.Errorf("Network /24 = %q", got)
+	}
+	if got := Network("2001:db8:1:2::1"); got != "2001:db8:1::/48" {
+		t.Errorf("Network /48 = %q", got)
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "[2001:DB8::1]:4242"
+	if got := FromRequest(r); got != "2001:db8::1" {
+		t.Errorf("FromRequest = %q", got)
+	}
+}
@@ -0,0 +1,177 @@
+// Package consistency cross-checks the documents of the database against the
+// files of the storage.
+package consistency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/schedule"
+)
+
+// Problems found by a check
+const (
+	ProblemMissing  = "missing"
+	ProblemOrphan   = "orphan"
+	ProblemMismatch = "mismatch"
+	// The file can't be downloaded: zero bytes, or failing to be read
+	ProblemEmpty      = "empty"
+	ProblemUnreadable = "unreadable"
+)
+
+// Info contains the consistency check settings
+type Info struct {
+	Enabled  bool // Run the check on a schedule
+	Interval int  // Hours between two scheduled checks
+}
+
+// Document is a document expecting a file, the hash is empty until the first
+// check records it
+type Document struct {
+	Kind   string
+	HexId  string
+	SHA256 string
+}
+
+// Issue is a problem with a file
+type Issue struct {
+	Kind     string `bson:"kind"`
+	HexId    string `bson:"hexid"`
+	Path     string `bson:"path"`
+	Problem  string `bson:"problem"`
+	Expected string `bson:"expected,omitempty"`
+	Actual   string `bson:"actual,omitempty"`
+}
+
+// Broken tells if the problem keeps the document from being downloaded
+func (i Issue) Broken() bool {
+	return i.Problem == ProblemMissing || i.Problem == ProblemEmpty || i.Problem == ProblemUnreadable
+}
+
+// Report is the result of a check
+type Report struct {
+	CreatedAt time.Time  `bson:"created_at"`
+	Duration  float64    `bson:"duration"`
+	Documents int        `bson:"documents"`
+	Files     int        `bson:"files"`
+	Issues    []Issue    `bson:"issues"`
+	Hashed    []Document `bson:"-"`
+}
+
+// Count returns the number of issues with a problem
+func (r *Report) Count(problem string) int {
+	n := 0
+	for _, i := range r.Issues {
+		if i.Problem == problem {
+			n++
+		}
+	}
+	return n
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Check compares the documents to the <hexid>.zip files of the directory of
+// their kind in dirs. Documents without a hash get the one of their file, to
+// compare with on the next checks, and are listed in Report.Hashed.
+func Check(docs []Document, dirs map[string]string) (*Report, error) {
+	start := time.Now()
+	report := &Report{CreatedAt: start, Documents: len(docs)}
+
+	// The files present in storage, by kind then hexid
+	present := make(map[string]map[string]bool)
+	for kind, dir := range dirs {
+		present[kind] = make(map[string]bool)
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() && strings.HasSuffix(e.Name(), ".zip") {
+				present[kind][strings.TrimSuffix(e.Name(), ".zip")] = true
+				report.Files++
+			}
+		}
+	}
+
+	for _, doc := range docs {
+		path := filepath.Join(dirs[doc.Kind], doc.HexId+".zip")
+		if !present[doc.Kind][doc.HexId] {
+			report.Issues = append(report.Issues, Issue{Kind: doc.Kind, HexId: doc.HexId, Path: path, Problem: ProblemMissing})
+			continue
+		}
+		delete(present[doc.Kind], doc.HexId)
+
+		fi, err := os.Stat(path)
+		if err == nil && fi.Size() == 0 {
+			report.Issues = append(report.Issues, Issue{Kind: doc.Kind, HexId: doc.HexId, Path: path, Problem: ProblemEmpty})
+			continue
+		}
+		sum, err := hashFile(path)
+		if err != nil {
+			report.Issues = append(report.Issues, Issue{Kind: doc.Kind, HexId: doc.HexId, Path: path, Problem: ProblemUnreadable, Actual: err.Error()})
+			continue
+		}
+		if doc.SHA256 == "" {
+			doc.SHA256 = sum
+			report.Hashed = append(report.Hashed, doc)
+		} else if sum != doc.SHA256 {
+			report.Issues = append(report.Issues, Issue{Kind: doc.Kind, HexId: doc.HexId, Path: path, Problem: ProblemMismatch, Expected: doc.SHA256, Actual: sum})
+		}
+	}
+
+	// What is left has no document
+	for kind, hexids := range present {
+		for hexid := range hexids {
+			report.Issues = append(report.Issues, Issue{Kind: kind, HexId: hexid, Path: filepath.Join(dirs[kind], hexid+".zip"), Problem: ProblemOrphan})
+		}
+	}
+
+	sort.Slice(report.Issues, func(i, j int) bool {
+		a, b := report.Issues[i], report.Issues[j]
+		if a.Problem != b.Problem {
+			return a.Problem < b.Problem
+		}
+		return a.Path < b.Path
+	})
+	report.Duration = time.Since(start).Seconds()
+	return report, nil
+}
+
+// check runs the checks in the background, one at a time
+var check = schedule.New("Consistency check")
+
+// Running returns true while a check started by Start is in progress
+func Running() bool {
+	return check.Running()
+}
+
+// Start calls run in the background, unless a check is already in progress,
+// in which case it returns false
+func Start(run func() error) bool {
+	return check.Start(run)
+}
+
+// Schedule starts run every c.Interval hours, forever
+func Schedule(c Info, run func() error) {
+	check.Schedule(c.Interval, run)
+}
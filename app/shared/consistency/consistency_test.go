@@ -0,0 +1,68 @@
+package consistency
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	root, err := ioutil.TempDir("", "consistency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	dirs := map[string]string{"crackme": filepath.Join(root, "crackme"), "solution": filepath.Join(root, "solution")}
+	os.Mkdir(dirs["crackme"], 0700)
+	for _, name := range []string{"ok.zip", "new.zip", "changed.zip", "orphan.zip"} {
+		ioutil.WriteFile(filepath.Join(dirs["crackme"], name), []byte(name), 0600)
+	}
+
+	ioutil.WriteFile(filepath.Join(dirs["crackme"], "empty.zip"), nil, 0600)
+	os.Symlink(filepath.Join(root, "nowhere"), filepath.Join(dirs["crackme"], "dead.zip"))
+
+	ok, _ := hashFile(filepath.Join(dirs["crackme"], "ok.zip"))
+	docs := []Document{
+		{Kind: "crackme", HexId: "ok", SHA256: ok},
+		{Kind: "crackme", HexId: "new"},
+		{Kind: "crackme", HexId: "changed", SHA256: ok},
+		{Kind: "solution", HexId: "gone"},
+		{Kind: "crackme", HexId: "empty"},
+		{Kind: "crackme", HexId: "dead"},
+	}
+
+	report, err := Check(docs, dirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Documents != 6 || report.Files != 6 {
+		t.Errorf("got %d documents, %d files", report.Documents, report.Files)
+	}
+	if len(report.Hashed) != 1 || report.Hashed[0].HexId != "new" || report.Hashed[0].SHA256 == "" {
+		t.Errorf("got hashed %+v", report.Hashed)
+	}
+
+	want := []struct{ problem, hexid string }{
+		{ProblemEmpty, "empty"},
+		{ProblemMismatch, "changed"},
+		{ProblemMissing, "gone"},
+		{ProblemOrphan, "orphan"},
+		{ProblemUnreadable, "dead"},
+	}
+	if len(report.Issues) != len(want) {
+		t.Fatalf("got issues %+v", report.Issues)
+	}
+	for i, w := range want {
+		if report.Issues[i].Problem != w.problem || report.Issues[i].HexId != w.hexid {
+			t.Errorf("issue %d: got %+v, want %v", i, report.Issues[i], w)
+		}
+	}
+	if report.Count(ProblemOrphan) != 1 {
+		t.Error("Count")
+	}
+	if !report.Issues[0].Broken() || report.Issues[1].Broken() {
+		t.Error("Broken")
+	}
+}
@@ -0,0 +1,133 @@
+// Package contentfilter checks the texts posted by the users against lists
+// of forbidden words and a limit of links, and decides whether they are
+// published, censored, held for the moderators or refused.
+package contentfilter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Action is what happens to a text
+type Action int
+
+// The actions, from the mildest to the most severe
+const (
+	Allow Action = iota
+	Censor
+	Hold
+	Block
+)
+
+var (
+	info  Info
+	words *regexp.Regexp
+	mutex sync.RWMutex
+
+	actions = map[string]Action{"censor": Censor, "hold": Hold, "block": Block}
+	links   = regexp.MustCompile(`(?i)\b(?:https?://|ftp://|www\.)\S+`)
+)
+
+// Info contains the policy of the filter
+type Info struct {
+	Enabled     bool
+	Words       []string // Forbidden words, matched case insensitively on whole words
+	WordsAction string   // "censor" (default), "hold" or "block"
+	MaxLinks    int      // Links allowed in a text, 2 by default, negative for no limit
+	LinksAction string   // "hold" (default) or "block" when there are more links
+}
+
+// Configure stores the policy
+func Configure(c Info) {
+	if _, ok := actions[c.WordsAction]; !ok {
+		c.WordsAction = "censor"
+	}
+	if c.LinksAction != "block" {
+		c.LinksAction = "hold"
+	}
+	if c.MaxLinks == 0 {
+		c.MaxLinks = 2
+	}
+
+	var re *regexp.Regexp
+	quoted := []string{}
+	for _, w := range c.Words {
+		if w = strings.TrimSpace(w); w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+	if len(quoted) > 0 {
+		re = regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	}
+
+	mutex.Lock()
+	info = c
+	words = re
+	mutex.Unlock()
+}
+
+// ReadConfig returns the policy
+func ReadConfig() Info {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return info
+}
+
+// Result is the decision of the filter on a text
+type Result struct {
+	Action Action
+	Text   string // The text to publish, censored if needed
+	Reason string // Why the text isn't allowed as is, for the moderators
+}
+
+// Check applies the policy to a text. The most severe action of the words
+// and of the links wins.
+func Check(text string) Result {
+	mutex.RLock()
+	c, re := info, words
+	mutex.RUnlock()
+
+	result := Result{Action: Allow, Text: text}
+	if !c.Enabled {
+		return result
+	}
+
+	var reasons []string
+	if re != nil {
+		if found := re.FindAllString(text, -1); len(found) > 0 {
+			result.Action = actions[c.WordsAction]
+			reasons = append(reasons, fmt.Sprintf("forbidden words: %s", strings.Join(unique(found), ", ")))
+			if result.Action == Censor {
+				result.Text = re.ReplaceAllStringFunc(text, func(w string) string {
+					return strings.Repeat("*", len([]rune(w)))
+				})
+			}
+		}
+	}
+
+	if n := len(links.FindAllString(text, -1)); c.MaxLinks >= 0 && n > c.MaxLinks {
+		if action := actions[c.LinksAction]; action > result.Action {
+			result.Action = action
+		}
+		reasons = append(reasons, fmt.Sprintf("%d links, %d allowed", n, c.MaxLinks))
+	}
+
+	result.Reason = strings.Join(reasons, "; ")
+	return result
+}
+
+// unique returns the words, lower cased, without duplicates
+func unique(found []string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, w := range found {
+		w = strings.ToLower(w)
+		if !seen[w] {
+			seen[w] = true
+			result = append(result, w)
+		}
+	}
+	return result
+}
@@ -0,0 +1,32 @@
+package contentfilter
+
+import "testing"
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		info   Info
+		text   string
+		action Action
+		result string
+	}{
+		{Info{}, "darn http://a http://b http://c", Allow, "darn http://a http://b http://c"},
+		{Info{Enabled: true, Words: []string{"darn"}}, "Darn, it works", Censor, "****, it works"},
+		{Info{Enabled: true, Words: []string{"darn"}}, "darning works", Allow, "darning works"},
+		{Info{Enabled: true, Words: []string{"darn"}, WordsAction: "block"}, "darn", Block, "darn"},
+		{Info{Enabled: true}, "see http://a and www.b.com", Allow, "see http://a and www.b.com"},
+		{Info{Enabled: true}, "http://a http://b https://c", Hold, "http://a http://b https://c"},
+		{Info{Enabled: true, MaxLinks: -1}, "http://a http://b https://c", Allow, "http://a http://b https://c"},
+		{Info{Enabled: true, Words: []string{"darn"}, LinksAction: "block", MaxLinks: 1}, "darn http://a http://b", Block, "**** http://a http://b"},
+	}
+
+	for i, tt := range tests {
+		Configure(tt.info)
+		r := Check(tt.text)
+		if r.Action != tt.action || r.Text != tt.result {
+			t.Errorf("%d: got %v %q, want %v %q", i, r.Action, r.Text, tt.action, tt.result)
+		}
+		if (r.Action != Allow) != (r.Reason != "") {
+			t.Errorf("%d: reason %q for action %v", i, r.Reason, r.Action)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package database
 import (
 	"context"
 	"log"
+	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -13,6 +15,9 @@ var (
 	Ctx       context.Context
 	Mongo     *mongo.Client
 	databases Info
+
+	// listings is the read preference of the listings, counts and stats
+	listings *readpref.ReadPref
 )
 
 // Type is the type of database from a Type* constant
@@ -35,6 +40,14 @@ type Info struct {
 type MongoDBInfo struct {
 	URL      string
 	Database string
+	// Replica set members serving the listings, counts and stats, which can
+	// be a little stale: "primary" (by default), "primaryPreferred",
+	// "secondaryPreferred", "secondary" or "nearest". The writes and the
+	// other reads stay on the primary.
+	ReadPreference string
+	// Seconds a secondary can lag behind the primary and still serve the
+	// listings, 90 at least, without limit if 0
+	MaxStaleness int
 }
 
 // Connect to the database
@@ -46,8 +59,15 @@ func Connect(d Info) {
 
 	ctx := context.TODO()
 
-	// Connect to MongoDB
-	Mongo, err = mongo.Connect(ctx, options.Client().ApplyURI("mongodb://127.0.0.1:27017"))
+	// Connect to MongoDB, on the local server unless an URL is configured. A
+	// bare host (e.g. "127.0.0.1") is accepted too.
+	url := d.MongoDB.URL
+	if url == "" {
+		url = "mongodb://127.0.0.1:27017"
+	} else if !strings.HasPrefix(url, "mongodb://") && !strings.HasPrefix(url, "mongodb+srv://") {
+		url = "mongodb://" + url
+	}
+	Mongo, err = mongo.Connect(ctx, options.Client().ApplyURI(url))
 	if err != nil {
 		log.Println("MongoDB Driver Error", err)
 		return
@@ -55,6 +75,43 @@ func Connect(d Info) {
 	if err = Mongo.Ping(ctx, readpref.Primary()); err != nil {
 		log.Println("Database Error", err)
 	}
+	listings = listingsReadPref(d.MongoDB)
+}
+
+// listingsReadPref returns the read preference of the listings, the primary
+// when it is unknown
+func listingsReadPref(m MongoDBInfo) *readpref.ReadPref {
+	var opts []readpref.Option
+	if m.MaxStaleness > 0 {
+		staleness := m.MaxStaleness
+		if staleness < 90 {
+			staleness = 90
+		}
+		opts = append(opts, readpref.WithMaxStaleness(time.Duration(staleness)*time.Second))
+	}
+
+	switch strings.ToLower(m.ReadPreference) {
+	case "", "primary":
+		return readpref.Primary()
+	case "primarypreferred":
+		return readpref.PrimaryPreferred(opts...)
+	case "secondarypreferred":
+		return readpref.SecondaryPreferred(opts...)
+	case "secondary":
+		return readpref.Secondary(opts...)
+	case "nearest":
+		return readpref.Nearest(opts...)
+	}
+	log.Println("Unknown MongoDB ReadPreference", m.ReadPreference, "reading from the primary")
+	return readpref.Primary()
+}
+
+// Listing returns a collection read with the read preference of the
+// listings, for the queries which can be a little stale: the pages listing
+// crackmes or users, the counts and the stats. What a user just wrote and the
+// checks before a write must be read from the collection of the database.
+func Listing(name string) *mongo.Collection {
+	return Mongo.Database(databases.MongoDB.Database).Collection(name, options.Collection().SetReadPreference(listings))
 }
 
 // CheckConnection returns true if MongoDB is available
@@ -0,0 +1,113 @@
+// Package digest writes the weekly summary emails of the users who opted in:
+// the new crackmes matching their preferences, the replies to their comments
+// and the solutions to their crackmes.
+package digest
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Period is the time between two digests of a user
+const Period = 7 * 24 * time.Hour
+
+// Info contains the digest settings
+type Info struct {
+	Enabled  bool   // Send the digests
+	Interval int    // Minutes between two searches of the users due for a digest, 60 by default
+	BaseURL  string // URL of the site in the links, e.g. https://crackmes.one
+}
+
+var (
+	info  Info
+	mutex sync.RWMutex
+)
+
+// Configure stores the settings
+func Configure(c Info) {
+	if c.Interval <= 0 {
+		c.Interval = 60
+	}
+	c.BaseURL = strings.TrimRight(c.BaseURL, "/")
+
+	mutex.Lock()
+	info = c
+	mutex.Unlock()
+}
+
+// ReadConfig returns the settings
+func ReadConfig() Info {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return info
+}
+
+// Item is an entry of a digest
+type Item struct {
+	Title  string
+	Path   string // Path of the page of the entry on the site
+	Detail string
+}
+
+// Digest is the summary of a week for a user
+type Digest struct {
+	User      string
+	Since     time.Time
+	Crackmes  []Item // New crackmes in the preferred languages and architectures
+	Replies   []Item // Comments following the user's on the same crackmes
+	Solutions []Item // Solutions to the user's crackmes
+}
+
+// Empty returns true when nothing happened for the user
+func (d Digest) Empty() bool {
+	return len(d.Crackmes) == 0 && len(d.Replies) == 0 && len(d.Solutions) == 0
+}
+
+var body = template.Must(template.New("digest").Parse(`Hi {{.User}},
+
+Here is what happened on crackmes.one since {{.Since.Format "January 2"}}.
+{{if .Solutions}}
+Solutions to your crackmes:
+{{range .Solutions}}  - {{.Title}}{{with .Detail}} ({{.}}){{end}}
+    {{$.BaseURL}}{{.Path}}
+{{end}}{{end}}{{if .Replies}}
+Replies to your comments:
+{{range .Replies}}  - {{.Title}}{{with .Detail}}: {{.}}{{end}}
+    {{$.BaseURL}}{{.Path}}
+{{end}}{{end}}{{if .Crackmes}}
+New crackmes for you:
+{{range .Crackmes}}  - {{.Title}}{{with .Detail}} ({{.}}){{end}}
+    {{$.BaseURL}}{{.Path}}
+{{end}}{{end}}
+--
+You receive this digest because you subscribed to it. Change your
+preferences or unsubscribe at {{.BaseURL}}/settings/digest
+`))
+
+// Render returns the subject and the text of the email of a digest
+func Render(d Digest) (string, string, error) {
+	var buf bytes.Buffer
+	err := body.Execute(&buf, struct {
+		Digest
+		BaseURL string
+	}{d, ReadConfig().BaseURL})
+	return "Your weekly crackmes.one digest", buf.String(), err
+}
+
+// Schedule calls run every c.Interval minutes, forever. run sends the digests
+// of the users whose last one is older than Period.
+func Schedule(c Info, run func() error) {
+	if c.Interval <= 0 {
+		c.Interval = 60
+	}
+	for {
+		time.Sleep(time.Duration(c.Interval) * time.Minute)
+		if err := run(); err != nil {
+			log.Println("Digests failed:", err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package digest
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	Configure(Info{BaseURL: "https://crackmes.one/"})
+
+	d := Digest{
+		User:      "alice",
+		Since:     time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+		Solutions: []Item{{Title: "KeygenMe", Path: "/crackme/1", Detail: "by bob"}},
+		Crackmes:  []Item{{Title: "VMProtect", Path: "/crackme/2"}},
+	}
+	if d.Empty() || !(Digest{}).Empty() {
+		t.Fatal("Empty digest")
+	}
+
+	_, text, err := Render(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"Hi alice,",
+		"since March 4",
+		"Solutions to your crackmes:\n  - KeygenMe (by bob)\n    https://crackmes.one/crackme/1\n",
+		"New crackmes for you:\n  - VMProtect\n    https://crackmes.one/crackme/2\n",
+		"unsubscribe at https://crackmes.one/settings/digest",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("%q not in the digest:\n%s", expected, text)
+		}
+	}
+	if strings.Contains(text, "Replies") {
+		t.Errorf("Section without items in the digest:\n%s", text)
+	}
+}
@@ -0,0 +1,170 @@
+package download
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"html/template"
+	"log"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/ratelimit"
+	"github.com/crackmesone/crackmes.one/app/shared/site"
+)
+
+const (
+	// KindCrackme and KindSolution are the kinds of files to download
+	KindCrackme  = "crackme"
+	KindSolution = "solution"
+
+	// Password is the password of the zips of the storage, set by validate.py
+	Password = "crackmes.one"
+)
+
+var (
+	info    Info
+	limiter *ratelimit.Limiter
+	mutex   sync.RWMutex
+
+	// ErrExpired is returned for download URLs past their expiry
+	ErrExpired = errors.New("download link expired")
+	// ErrInvalidSignature is returned for forged or altered download URLs
+	ErrInvalidSignature = errors.New("invalid download link")
+)
+
+// Info contains the download settings
+type Info struct {
+	Secret         string       // Key of the HMAC signing the URLs, random if empty
+	Expiry         int          // Seconds a download URL stays valid
+	AnonymousLimit int          // Downloads per hour of anonymous visitors, per IP
+	Mirrors        []MirrorInfo // Servers the downloads are redirected to
+	HealthInterval int          // Seconds between two health checks of the mirrors
+	CountryHeader  string       // Request header with the visitor's country, set by a CDN
+}
+
+// Configure stores the settings and starts the health checks of the mirrors.
+// Without a secret, a random one is made up: the URLs handed out before a
+// restart won't work after it.
+func Configure(c Info) {
+	if c.Secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			log.Fatalln(err)
+		}
+		c.Secret = hex.EncodeToString(b)
+	}
+	if c.Expiry <= 0 {
+		c.Expiry = 3600
+	}
+	if c.AnonymousLimit <= 0 {
+		c.AnonymousLimit = 30
+	}
+	if c.HealthInterval <= 0 {
+		c.HealthInterval = 60
+	}
+
+	mutex.Lock()
+	info = c
+	limiter = ratelimit.New(c.AnonymousLimit, time.Hour)
+	mutex.Unlock()
+
+	configureMirrors(c.Mirrors)
+	if len(c.Mirrors) > 0 {
+		go watchMirrors(time.Duration(c.HealthInterval) * time.Second)
+	}
+}
+
+// ReadConfig returns the download settings
+func ReadConfig() Info {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return info
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of a download and its expiry
+func Sign(kind, hexid string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(ReadConfig().Secret))
+	mac.Write([]byte(kind + "/" + hexid + "/" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// URL returns a signed URL downloading the file of a crackme or a solution,
+// valid for the configured expiry, as a path of the site from "/"
+func URL(kind, hexid string) string {
+	expires := time.Now().Add(time.Duration(ReadConfig().Expiry) * time.Second).Unix()
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("sig", Sign(kind, hexid, expires))
+	return "/download/" + kind + "/" + hexid + "?" + q.Encode()
+}
+
+// Verify checks the signature and the expiry of a download URL
+func Verify(kind, hexid, expires, sig string) error {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(Sign(kind, hexid, exp))) {
+		return ErrInvalidSignature
+	}
+	if time.Now().Unix() > exp {
+		return ErrExpired
+	}
+	return nil
+}
+
+// AllowAnonymous counts a download of an anonymous visitor from an IP and
+// reports whether it is within the hourly limit, if not also how long to wait
+func AllowAnonymous(ip string) (bool, time.Duration) {
+	mutex.RLock()
+	l := limiter
+	mutex.RUnlock()
+	return l.Allow(ip)
+}
+
+// SetAnonymousLimit changes the hourly limit of the anonymous downloads
+func SetAnonymousLimit(n int) {
+	mutex.Lock()
+	info.AnonymousLimit = n
+	l := limiter
+	mutex.Unlock()
+	l.SetLimit(n)
+}
+
+// AnonymousUsage returns the downloads counted for an IP in the current hour,
+// the hourly limit and how long until the hour ends
+func AnonymousUsage(ip string) (int, int, time.Duration) {
+	mutex.RLock()
+	l := limiter
+	mutex.RUnlock()
+	used, reset := l.Usage(ip)
+	limit, _ := l.Limit()
+	return used, limit, reset
+}
+
+// Dir returns the directory of the files of a kind in the storage
+func Dir(kind string) string {
+	return filepath.Join("static", kind)
+}
+
+// Path returns the path of the file of a crackme or a solution in the storage
+func Path(kind, hexid string) string {
+	return filepath.Join(Dir(kind), filepath.Base(hexid)+".zip")
+}
+
+// Plugin returns a map of functions that are usable in templates
+func Plugin() template.FuncMap {
+	f := make(template.FuncMap)
+
+	f["DOWNLOADURL"] = func(kind, hexid string) string {
+		return site.Path(URL(kind, hexid))
+	}
+
+	return f
+}
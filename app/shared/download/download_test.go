@@ -0,0 +1,43 @@
+package download
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestURL(t *testing.T) {
+	Configure(Info{Secret: "secret", Expiry: 60})
+
+	u, err := url.Parse(URL(KindCrackme, "5f0c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/download/crackme/5f0c" {
+		t.Errorf("got path %q", u.Path)
+	}
+
+	q := u.Query()
+	if err := Verify(KindCrackme, "5f0c", q.Get("expires"), q.Get("sig")); err != nil {
+		t.Errorf("valid URL: %v", err)
+	}
+	if err := Verify(KindSolution, "5f0c", q.Get("expires"), q.Get("sig")); err != ErrInvalidSignature {
+		t.Errorf("other kind: got %v", err)
+	}
+	if err := Verify(KindCrackme, "5f0c", q.Get("expires")+"0", q.Get("sig")); err != ErrInvalidSignature {
+		t.Errorf("extended expiry: got %v", err)
+	}
+
+	past := time.Now().Add(-time.Minute).Unix()
+	if err := Verify(KindCrackme, "5f0c", strconv.FormatInt(past, 10), Sign(KindCrackme, "5f0c", past)); err != ErrExpired {
+		t.Errorf("expired URL: got %v", err)
+	}
+}
+
+func TestPath(t *testing.T) {
+	if p := Path(KindSolution, "../../config/config"); strings.Contains(p, "..") {
+		t.Errorf("got %q", p)
+	}
+}
@@ -0,0 +1,122 @@
+package download
+
+import (
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	mirrors     []*mirror
+	mirrorMutex sync.RWMutex
+	healthCheck = &http.Client{Timeout: 10 * time.Second}
+)
+
+// MirrorInfo describes a server holding a copy of the static crackme and
+// solution directories
+type MirrorInfo struct {
+	URL       string   // Base URL, the files are at URL/crackme/<hexid>.zip
+	Weight    int      // Share of the downloads, relative to the other mirrors
+	Countries []string // Only serves these countries, serves everywhere if empty
+	HealthURL string   // Checked for a 200 status, the base URL if empty
+}
+
+// mirror is a configured mirror and its latest health
+type mirror struct {
+	MirrorInfo
+	healthy bool
+}
+
+// configureMirrors replaces the mirrors, all healthy until the first check
+func configureMirrors(c []MirrorInfo) {
+	var list []*mirror
+	for _, m := range c {
+		if m.Weight <= 0 {
+			m.Weight = 1
+		}
+		if m.HealthURL == "" {
+			m.HealthURL = m.URL
+		}
+		m.URL = strings.TrimRight(m.URL, "/")
+		list = append(list, &mirror{MirrorInfo: m, healthy: true})
+	}
+
+	mirrorMutex.Lock()
+	mirrors = list
+	mirrorMutex.Unlock()
+}
+
+// checkMirrors updates the health of every mirror
+func checkMirrors() {
+	mirrorMutex.RLock()
+	list := mirrors
+	mirrorMutex.RUnlock()
+
+	for _, m := range list {
+		healthy := false
+		resp, err := healthCheck.Get(m.HealthURL)
+		if err == nil {
+			healthy = resp.StatusCode == http.StatusOK
+			resp.Body.Close()
+		}
+
+		mirrorMutex.Lock()
+		m.healthy = healthy
+		mirrorMutex.Unlock()
+	}
+}
+
+// watchMirrors checks the mirrors at every interval, forever
+func watchMirrors(interval time.Duration) {
+	for {
+		checkMirrors()
+		time.Sleep(interval)
+	}
+}
+
+// MirrorURL picks a healthy mirror for a visitor from a country, among the
+// ones dedicated to that country if any, else among the ones serving
+// everywhere, at random according to their weights.
+// It returns the URL of the file on the mirror, or an empty string when the
+// file should be served locally.
+func MirrorURL(kind, hexid, country string) string {
+	mirrorMutex.RLock()
+	defer mirrorMutex.RUnlock()
+
+	var healthy, local []*mirror
+	for _, m := range mirrors {
+		if !m.healthy {
+			continue
+		}
+		if len(m.Countries) == 0 {
+			healthy = append(healthy, m)
+		}
+		for _, c := range m.Countries {
+			if country != "" && strings.EqualFold(c, country) {
+				local = append(local, m)
+				break
+			}
+		}
+	}
+	if len(local) > 0 {
+		healthy = local
+	}
+	if len(healthy) == 0 {
+		return ""
+	}
+
+	total := 0
+	for _, m := range healthy {
+		total += m.Weight
+	}
+	n := rand.Intn(total)
+	for _, m := range healthy {
+		if n < m.Weight {
+			return m.URL + "/" + kind + "/" + hexid + ".zip"
+		}
+		n -= m.Weight
+	}
+	return ""
+}
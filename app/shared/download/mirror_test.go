@@ -0,0 +1,38 @@
+package download
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMirrorURL(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	configureMirrors([]MirrorInfo{
+		{URL: up.URL + "/", Weight: 1},
+		{URL: "https://eu.example.com", HealthURL: up.URL, Countries: []string{"FR", "DE"}},
+		{URL: down.URL, Weight: 100},
+	})
+	checkMirrors()
+
+	for i := 0; i < 20; i++ {
+		if u := MirrorURL(KindCrackme, "5f0c", "US"); u != up.URL+"/crackme/5f0c.zip" {
+			t.Fatalf("got %q from the US", u)
+		}
+		if u := MirrorURL(KindSolution, "5f0c", "fr"); u != "https://eu.example.com/solution/5f0c.zip" {
+			t.Fatalf("got %q from France", u)
+		}
+	}
+
+	configureMirrors([]MirrorInfo{{URL: down.URL}})
+	checkMirrors()
+	if u := MirrorURL(KindCrackme, "5f0c", ""); u != "" {
+		t.Errorf("got %q without a healthy mirror", u)
+	}
+}
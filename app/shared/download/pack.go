@@ -0,0 +1,106 @@
+package download
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// KindPack is the kind of the solution packs, the files of all the solutions
+// of a crackme in a zip
+const KindPack = "pack"
+
+var (
+	// PackDir is the directory of the cached solution packs
+	PackDir = filepath.Join("tmp", "pack")
+
+	packMutex sync.Mutex
+)
+
+// PackFile is a solution file of a pack
+type PackFile struct {
+	Name  string // Name of the file in the pack
+	HexId string // Solution the file is from
+}
+
+// Pack returns the path of the solution pack of a crackme, building it if it
+// isn't cached yet. The packs are cached under a hash of their files, so a new
+// or deleted solution makes a new pack and the previous one is removed.
+//
+// The solution files are stored as they are, still zipped with the password.
+// The ones missing from the storage are left out.
+func Pack(hexid string, files []PackFile) (string, error) {
+	hexid = filepath.Base(hexid)
+	h := sha256.New()
+	for _, f := range files {
+		h.Write([]byte(f.Name + "/" + f.HexId + "\n"))
+	}
+	path := filepath.Join(PackDir, hexid+"-"+hex.EncodeToString(h.Sum(nil))[:16]+".zip")
+
+	packMutex.Lock()
+	defer packMutex.Unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if err := os.MkdirAll(PackDir, 0755); err != nil {
+		return "", err
+	}
+
+	// Build it aside, so a failure or a concurrent download never sees half a pack
+	tmp, err := ioutil.TempFile(PackDir, hexid+"-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	err = writePack(tmp, files)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	old, _ := filepath.Glob(filepath.Join(PackDir, hexid+"-*.zip"))
+	for _, o := range old {
+		if o != path {
+			os.Remove(o)
+		}
+	}
+	return path, nil
+}
+
+// writePack writes the zip of the solution files to w
+func writePack(w io.Writer, files []PackFile) error {
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		src, err := os.Open(Path(KindSolution, f.HexId))
+		if os.IsNotExist(err) {
+			log.Println("solution pack: missing file of solution", f.HexId)
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		// Already compressed, no need to deflate them again
+		dst, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Store, Modified: time.Now()})
+		if err == nil {
+			_, err = io.Copy(dst, src)
+		}
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
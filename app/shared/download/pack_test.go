@@ -0,0 +1,58 @@
+package download
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPack(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	os.MkdirAll(Dir(KindSolution), 0755)
+	for _, hexid := range []string{"a1", "b2"} {
+		if err := ioutil.WriteFile(Path(KindSolution, hexid), []byte("writeup "+hexid), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := []PackFile{{"alice_a1.zip", "a1"}, {"bob_b2.zip", "b2"}, {"carol_c3.zip", "c3"}}
+	path, err := Pack("5f0c", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	r.Close()
+	if len(names) != 2 || names[0] != "alice_a1.zip" || names[1] != "bob_b2.zip" {
+		t.Errorf("got files %v, the missing one must be left out", names)
+	}
+
+	// Cached until the solutions change, then replaced
+	if again, err := Pack("5f0c", files); err != nil || again != path {
+		t.Errorf("got %q, %v, want the cached pack", again, err)
+	}
+	other, err := Pack("5f0c", files[:1])
+	if err != nil || other == path {
+		t.Fatalf("got %q, %v, want a new pack", other, err)
+	}
+	if packs, _ := filepath.Glob(filepath.Join(PackDir, "5f0c-*")); len(packs) != 1 {
+		t.Errorf("got packs %v, want only the new one", packs)
+	}
+}
@@ -0,0 +1,98 @@
+// Package drift compares the denormalized fields of the database, the
+// counters and the copied names, with the values computed from their source
+// of truth.
+package drift
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/schedule"
+)
+
+// Info contains the drift check settings
+type Info struct {
+	Enabled  bool // Run the check on a schedule
+	Interval int  // Hours between two scheduled checks
+}
+
+// Issue is a denormalized field of a document which differs from its source
+type Issue struct {
+	Kind   string `bson:"kind"`  // Collection of the document
+	Key    string `bson:"key"`   // Hexid, or name of the user
+	Field  string `bson:"field"` // e.g. "nbsolutions"
+	Stored string `bson:"stored"`
+	Actual string `bson:"actual"`
+}
+
+// Report is the result of a check
+type Report struct {
+	CreatedAt time.Time `bson:"created_at"`
+	Duration  float64   `bson:"duration"`
+	Checked   int       `bson:"checked"` // Fields compared
+	Issues    []Issue   `bson:"issues"`
+}
+
+// Count returns the number of issues of a field of a kind
+func (r *Report) Count(kind, field string) int {
+	n := 0
+	for _, i := range r.Issues {
+		if i.Kind == kind && i.Field == field {
+			n++
+		}
+	}
+	return n
+}
+
+// Counts adds to the report the counters of the documents of a kind which
+// differ from the actual counts, missing from actual when zero
+func (r *Report) Counts(kind, field string, stored, actual map[string]int) {
+	var issues []Issue
+	for key, s := range stored {
+		if a := actual[key]; a != s {
+			issues = append(issues, Issue{Kind: kind, Key: key, Field: field, Stored: strconv.Itoa(s), Actual: strconv.Itoa(a)})
+		}
+	}
+	r.add(len(stored), issues)
+}
+
+// Values adds to the report the copied values of the documents of a kind
+// which differ from their source. The documents whose source is missing from
+// actual are skipped, they are orphans rather than drifts.
+func (r *Report) Values(kind, field string, stored, actual map[string]string) {
+	var issues []Issue
+	for key, s := range stored {
+		if a, ok := actual[key]; ok && a != s {
+			issues = append(issues, Issue{Kind: kind, Key: key, Field: field, Stored: s, Actual: a})
+		}
+	}
+	r.add(len(stored), issues)
+}
+
+// add appends issues found among n fields, sorted so the reports read the
+// same from one check to the next
+func (r *Report) add(n int, issues []Issue) {
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Key < issues[j].Key })
+	r.Checked += n
+	r.Issues = append(r.Issues, issues...)
+}
+
+// check runs the checks in the background, one at a time
+var check = schedule.New("Drift check")
+
+// Running returns true while a check started by Start is in progress
+func Running() bool {
+	return check.Running()
+}
+
+// Start calls run in the background, unless a check is already in progress,
+// in which case it returns false
+func Start(run func() error) bool {
+	return check.Start(run)
+}
+
+// Schedule starts run every c.Interval hours, forever
+func Schedule(c Info, run func() error) {
+	check.Schedule(c.Interval, run)
+}
@@ -0,0 +1,32 @@
+package drift
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	var r Report
+	r.Counts("crackme", "nbsolutions",
+		map[string]int{"b": 2, "a": 1, "ok": 3, "gone": 1},
+		map[string]int{"a": 2, "b": 0, "ok": 3})
+	r.Values("solution", "crackmename",
+		map[string]string{"s1": "Old name", "s2": "Same", "orphan": "Whatever"},
+		map[string]string{"s1": "New name", "s2": "Same"})
+
+	want := []Issue{
+		{Kind: "crackme", Key: "a", Field: "nbsolutions", Stored: "1", Actual: "2"},
+		{Kind: "crackme", Key: "b", Field: "nbsolutions", Stored: "2", Actual: "0"},
+		{Kind: "crackme", Key: "gone", Field: "nbsolutions", Stored: "1", Actual: "0"},
+		{Kind: "solution", Key: "s1", Field: "crackmename", Stored: "Old name", Actual: "New name"},
+	}
+	if !reflect.DeepEqual(r.Issues, want) {
+		t.Errorf("Issues = %+v, want %+v", r.Issues, want)
+	}
+	if r.Checked != 7 {
+		t.Errorf("Checked = %d, want 7", r.Checked)
+	}
+	if n := r.Count("crackme", "nbsolutions"); n != 3 {
+		t.Errorf("Count() = %d, want 3", n)
+	}
+}
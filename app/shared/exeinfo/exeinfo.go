@@ -0,0 +1,398 @@
+// Package exeinfo reads the headers of PE, ELF and Mach-O executables to tell
+// their architecture, platform, compiler and packer.
+package exeinfo
+
+import (
+	"archive/zip"
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/worker"
+)
+
+// Executable formats
+const (
+	FormatPE    = "PE"
+	FormatELF   = "ELF"
+	FormatMachO = "Mach-O"
+)
+
+// Architectures and platforms, as offered by the upload form
+const (
+	ArchX86   = "x86"
+	ArchX8664 = "x86-64"
+	ArchARM   = "ARM"
+	ArchMIPS  = "MIPS"
+	ArchRISCV = "RISC-V"
+	ArchOther = "other"
+
+	PlatformWindows = "Windows"
+	PlatformUnix    = "Unix/linux etc."
+	PlatformMac     = "Mac OS X"
+)
+
+const (
+	// maxFileSize caps the size of an archived file read for analysis
+	maxFileSize = 20 << 20
+
+	// maxTotalSize caps the size of the files read out of an archive
+	maxTotalSize = 64 << 20
+
+	// maxEntries caps the number of files of an archive analyzed
+	maxEntries = 1000
+
+	// headerSize is the size of the start of a file where the packers leave
+	// their marks
+	headerSize = 0x1000
+)
+
+var (
+	// ErrNotExecutable is returned when no executable is found in an upload
+	ErrNotExecutable = errors.New("no executable found")
+	// ErrTimeout is returned when the analysis of an upload is given up
+	ErrTimeout = errors.New("analysis timed out")
+
+	// timeout bounds the time an upload is analyzed in, the request doesn't
+	// wait past it
+	timeout = 5 * time.Second
+)
+
+// Info describes an executable
+type Info struct {
+	File     string `bson:"file,omitempty" json:"file,omitempty"`
+	Format   string `bson:"format" json:"format"`
+	Arch     string `bson:"arch" json:"arch"`
+	Bits     int    `bson:"bits" json:"bits"`
+	Platform string `bson:"platform" json:"platform"`
+	Compiler string `bson:"compiler,omitempty" json:"compiler,omitempty"`
+	Packer   string `bson:"packer,omitempty" json:"packer,omitempty"`
+	// Executables is the number of executables found in the upload, the
+	// others are described by the first one
+	Executables int `bson:"executables" json:"executables"`
+}
+
+// Analyze describes the executable uploaded as name, or the first one found
+// in it if it is a zip archive. A malformed upload can't crash nor stall the
+// request: a panic of the parsers is returned as an error, and the analysis
+// is given up after timeout, the first files of an archive only are read.
+func Analyze(name string, data []byte) (*Info, error) {
+	type result struct {
+		info *Info
+		err  error
+	}
+	deadline := time.Now().Add(timeout)
+	done := make(chan result, 1)
+	go func() {
+		var info *Info
+		err := worker.Do("Analysis of "+name, func() (err error) {
+			info, err = analyze(name, data, deadline)
+			return err
+		})
+		done <- result{info, err}
+	}()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.info, r.err
+	case <-timer.C:
+		log.Println("Analysis of", name, "timed out")
+		return nil, ErrTimeout
+	}
+}
+
+// analyze describes the executable of an upload, reading its archive until
+// the deadline
+func analyze(name string, data []byte, deadline time.Time) (*Info, error) {
+	if info, err := analyzeExecutable(data); err == nil {
+		info.File = name
+		info.Executables = 1
+		return info, nil
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrNotExecutable
+	}
+
+	var first *Info
+	count, total := 0, 0
+	for i, f := range archive.File {
+		if time.Now().After(deadline) {
+			return nil, ErrTimeout
+		}
+		if i == maxEntries || total > maxTotalSize {
+			break
+		}
+		if f.FileInfo().IsDir() || f.UncompressedSize64 > maxFileSize {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			// Most likely encrypted
+			continue
+		}
+		content, err := io.ReadAll(io.LimitReader(rc, maxFileSize))
+		rc.Close()
+		total += len(content)
+		if err != nil {
+			continue
+		}
+		info, err := analyzeExecutable(content)
+		if err != nil {
+			continue
+		}
+		count++
+		if first == nil {
+			info.File = f.Name
+			first = info
+		}
+	}
+
+	if first == nil {
+		return nil, ErrNotExecutable
+	}
+	first.Executables = count
+	return first, nil
+}
+
+// analyzeExecutable describes a single executable
+func analyzeExecutable(data []byte) (*Info, error) {
+	r := bytes.NewReader(data)
+
+	if f, err := pe.NewFile(r); err == nil {
+		return analyzePE(f, data), nil
+	}
+	if f, err := elf.NewFile(r); err == nil {
+		return analyzeELF(f, data), nil
+	}
+	if f, err := macho.NewFile(r); err == nil {
+		return analyzeMachO(f, data), nil
+	}
+	if f, err := macho.NewFatFile(r); err == nil && len(f.Arches) > 0 {
+		return analyzeMachO(f.Arches[0].File, data), nil
+	}
+	return nil, ErrNotExecutable
+}
+
+func analyzePE(f *pe.File, data []byte) *Info {
+	info := &Info{Format: FormatPE, Platform: PlatformWindows, Bits: 32}
+
+	switch f.Machine {
+	case pe.IMAGE_FILE_MACHINE_I386:
+		info.Arch = ArchX86
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		info.Arch, info.Bits = ArchX8664, 64
+	case pe.IMAGE_FILE_MACHINE_ARM, pe.IMAGE_FILE_MACHINE_ARMNT:
+		info.Arch = ArchARM
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		info.Arch, info.Bits = ArchARM, 64
+	default:
+		info.Arch = ArchOther
+	}
+
+	var sections []string
+	for _, s := range f.Sections {
+		sections = append(sections, s.Name)
+	}
+
+	// The CLR runtime header data directory is only set for .NET assemblies
+	isDotNet := false
+	switch h := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		isDotNet = len(h.DataDirectory) > 14 && h.DataDirectory[14].VirtualAddress != 0
+	case *pe.OptionalHeader64:
+		isDotNet = len(h.DataDirectory) > 14 && h.DataDirectory[14].VirtualAddress != 0
+	}
+
+	libraries, _ := f.ImportedLibraries()
+
+	info.Packer = detectPacker(sections, data)
+	switch {
+	case isDotNet:
+		info.Compiler = ".NET"
+	case hasLibrary(libraries, "msvbvm60.dll", "msvbvm50.dll"):
+		info.Compiler = "Visual Basic"
+	default:
+		info.Compiler = detectCompiler(sections, data)
+	}
+	if info.Compiler == "" && bytes.Contains(data[:min(len(data), 0x400)], []byte("Rich")) {
+		info.Compiler = "MSVC"
+	}
+	return info
+}
+
+func analyzeELF(f *elf.File, data []byte) *Info {
+	info := &Info{Format: FormatELF, Platform: PlatformUnix, Bits: 32}
+	if f.Class == elf.ELFCLASS64 {
+		info.Bits = 64
+	}
+
+	switch f.Machine {
+	case elf.EM_386:
+		info.Arch = ArchX86
+	case elf.EM_X86_64:
+		info.Arch = ArchX8664
+	case elf.EM_ARM, elf.EM_AARCH64:
+		info.Arch = ArchARM
+	case elf.EM_MIPS, elf.EM_MIPS_RS3_LE:
+		info.Arch = ArchMIPS
+	case elf.EM_RISCV:
+		info.Arch = ArchRISCV
+	default:
+		info.Arch = ArchOther
+	}
+
+	var sections []string
+	for _, s := range f.Sections {
+		sections = append(sections, s.Name)
+	}
+
+	info.Packer = detectPacker(sections, data)
+	info.Compiler = detectCompiler(sections, data)
+	return info
+}
+
+func analyzeMachO(f *macho.File, data []byte) *Info {
+	info := &Info{Format: FormatMachO, Platform: PlatformMac, Bits: 32}
+
+	switch f.Cpu {
+	case macho.Cpu386:
+		info.Arch = ArchX86
+	case macho.CpuAmd64:
+		info.Arch, info.Bits = ArchX8664, 64
+	case macho.CpuArm:
+		info.Arch = ArchARM
+	case macho.CpuArm64:
+		info.Arch, info.Bits = ArchARM, 64
+	default:
+		info.Arch = ArchOther
+	}
+
+	var sections []string
+	for _, s := range f.Sections {
+		sections = append(sections, s.Name)
+	}
+
+	info.Packer = detectPacker(sections, data)
+	info.Compiler = detectCompiler(sections, data)
+	return info
+}
+
+// platforms lists the platforms of the upload form an executable format can
+// run on
+var platforms = map[string][]string{
+	FormatPE:    {PlatformWindows, "Multiplatform"},
+	FormatELF:   {PlatformUnix, "Android", "Multiplatform"},
+	FormatMachO: {PlatformMac, "iOS", "Multiplatform"},
+}
+
+// MatchesArch reports whether the declared architecture agrees with the
+// executable. .NET assemblies and unknown architectures agree with anything.
+func (i *Info) MatchesArch(arch string) bool {
+	return i.Arch == arch || i.Arch == ArchOther || i.Compiler == ".NET"
+}
+
+// MatchesPlatform reports whether the declared platform agrees with the
+// executable
+func (i *Info) MatchesPlatform(platform string) bool {
+	for _, p := range platforms[i.Format] {
+		if p == platform {
+			return true
+		}
+	}
+	return false
+}
+
+// signature tells a compiler or a packer by a section name or by bytes found
+// in the file
+type signature struct {
+	name     string
+	sections []string
+	patterns []string
+}
+
+var packers = []signature{
+	{"UPX", []string{"UPX0", "UPX1", ".upx0", "UPX!"}, []string{"UPX!"}},
+	{"ASPack", []string{".aspack", ".adata"}, nil},
+	{"MPRESS", []string{".MPRESS1", ".MPRESS2"}, nil},
+	{"PECompact", []string{"PEC2", "pec1", "PEC2TO"}, nil},
+	{"Themida", []string{".themida", ".winlice"}, nil},
+	{"VMProtect", []string{".vmp0", ".vmp1"}, nil},
+	{"Enigma", []string{".enigma1", ".enigma2"}, nil},
+	{"Petite", []string{".petite"}, nil},
+	{"NsPack", []string{".nsp0", ".nsp1"}, nil},
+}
+
+var compilers = []signature{
+	{"Go", []string{".gopclntab", ".go.buildinfo", "__gopclntab", "__go_buildinfo"}, []string{"Go build ID:"}},
+	{"Rust", nil, []string{"/rustc/", "rust_panic"}},
+	{"Delphi", nil, []string{"Embarcadero Delphi", "Borland Delphi", "SOFTWARE\\Borland\\Delphi"}},
+	{"Nim", nil, []string{"@nimGC", "fatal.nim"}},
+	{"GCC", nil, []string{"GCC: ("}},
+	{"Clang", nil, []string{"clang version"}},
+}
+
+// match reports whether the file has one of the signature's sections or
+// contains one of its patterns
+func (s signature) match(sections []string, data []byte) bool {
+	for _, name := range s.sections {
+		for _, section := range sections {
+			if section == name {
+				return true
+			}
+		}
+	}
+	for _, pattern := range s.patterns {
+		if bytes.Contains(data, []byte(pattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+func detectPacker(sections []string, data []byte) string {
+	data = data[:min(len(data), headerSize)]
+	for _, s := range packers {
+		if s.match(sections, data) {
+			return s.name
+		}
+	}
+	return ""
+}
+
+func detectCompiler(sections []string, data []byte) string {
+	for _, s := range compilers {
+		if s.match(sections, data) {
+			return s.name
+		}
+	}
+	return ""
+}
+
+// hasLibrary reports whether one of the names is in the imported libraries,
+// ignoring the case
+func hasLibrary(libraries []string, names ...string) bool {
+	for _, l := range libraries {
+		for _, n := range names {
+			if bytes.EqualFold([]byte(l), []byte(n)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,91 @@
+package exeinfo
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// The test binary itself is a Go executable of the running platform
+func testBinary(t *testing.T) []byte {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("expects a linux/amd64 test binary")
+	}
+	path, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestAnalyze(t *testing.T) {
+	info, err := Analyze("crackme", testBinary(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Info{File: "crackme", Format: FormatELF, Arch: ArchX8664, Bits: 64, Platform: PlatformUnix, Compiler: "Go", Executables: 1}
+	if *info != want {
+		t.Errorf("got %+v, want %+v", *info, want)
+	}
+
+	if !info.MatchesArch(ArchX8664) || info.MatchesArch(ArchX86) {
+		t.Error("MatchesArch")
+	}
+	if !info.MatchesPlatform("Android") || info.MatchesPlatform(PlatformWindows) {
+		t.Error("MatchesPlatform")
+	}
+}
+
+func TestAnalyzeZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, _ := zw.Create("readme.txt")
+	f.Write([]byte("Find the password"))
+	f, _ = zw.Create("bin/crackme")
+	f.Write(testBinary(t))
+	zw.Close()
+
+	info, err := Analyze("crackme.zip", buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.File != "bin/crackme" || info.Format != FormatELF || info.Executables != 1 {
+		t.Errorf("got %+v", *info)
+	}
+
+	if _, err := Analyze("readme.txt", []byte("Find the password")); err != ErrNotExecutable {
+		t.Errorf("got %v, want ErrNotExecutable", err)
+	}
+}
+
+func TestAnalyzeTimeout(t *testing.T) {
+	defer func(d time.Duration) { timeout = d }(timeout)
+	timeout = 0
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, _ := zw.Create("crackme")
+	f.Write([]byte("not an executable"))
+	zw.Close()
+
+	if _, err := Analyze("crackme.zip", buf.Bytes()); err != ErrTimeout {
+		t.Errorf("got %v, want ErrTimeout", err)
+	}
+}
+
+func TestDetectPacker(t *testing.T) {
+	if p := detectPacker([]string{"UPX0", "UPX1", ".rsrc"}, nil); p != "UPX" {
+		t.Errorf("got %q, want UPX", p)
+	}
+	if p := detectPacker([]string{".text", ".data"}, []byte("nothing")); p != "" {
+		t.Errorf("got %q, want none", p)
+	}
+}
@@ -0,0 +1,129 @@
+// Package feature switches features on and off at runtime, so risky ones can
+// be shipped dark and rolled out gradually to a percentage of the users.
+//
+// The features are registered by the controllers with their default state.
+// The Features section of the configuration overrides the defaults, and the
+// admins override both from /admin/features.
+package feature
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+// Sources of the state of a flag
+const (
+	SourceDefault = "default"
+	SourceConfig  = "config"
+	SourceAdmin   = "admin"
+)
+
+// State is the state of a flag
+type State struct {
+	Enabled bool     // Off for everyone when false
+	Percent int      // Share of the logged in users having the feature, 100 for everyone including the visitors
+	Users   []string // Users always having the enabled feature, e.g. the testers
+}
+
+// Info contains the feature settings, the states by flag name
+type Info map[string]State
+
+// Flag is a registered feature
+type Flag struct {
+	Name        string
+	Description string
+	State
+	Source string // Where the state comes from, a Source* constant
+}
+
+var (
+	mutex     sync.RWMutex
+	flags     = make(map[string]*Flag)
+	config    Info
+	overrides = make(map[string]State)
+)
+
+// Register declares a feature with its default state, when it is off by
+// default the feature is shipped dark
+func Register(name, description string, state State) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	flags[name] = &Flag{Name: name, Description: description, State: state, Source: SourceDefault}
+	resolve(flags[name])
+}
+
+// Configure sets the states of the configuration
+func Configure(c Info) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	config = c
+	for _, f := range flags {
+		resolve(f)
+	}
+}
+
+// Override sets the state of a flag chosen by an admin, it takes precedence
+// over the configuration
+func Override(name string, state State) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	overrides[name] = state
+	if f, ok := flags[name]; ok {
+		resolve(f)
+	}
+}
+
+// resolve applies the configuration and the overrides to a flag, the mutex
+// must be held
+func resolve(f *Flag) {
+	if state, ok := overrides[f.Name]; ok {
+		f.State, f.Source = state, SourceAdmin
+	} else if state, ok := config[f.Name]; ok {
+		f.State, f.Source = state, SourceConfig
+	}
+}
+
+// Flags returns the registered flags, by name
+func Flags() []Flag {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	result := make([]Flag, 0, len(flags))
+	for _, f := range flags {
+		result = append(result, *f)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// bucket returns the rollout bucket of a user for a flag, from 0 to 99. It
+// differs between the flags so the same users aren't always the first ones.
+func bucket(name, username string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name + "\x00" + username))
+	return int(h.Sum32() % 100)
+}
+
+// Enabled returns true if the feature is on for a user, the empty name being
+// a visitor. Unknown features are off.
+func Enabled(name, username string) bool {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	f, ok := flags[name]
+	if !ok || !f.Enabled {
+		return false
+	}
+	if f.Percent >= 100 {
+		return true
+	}
+	if username == "" {
+		return false
+	}
+	for _, u := range f.Users {
+		if u == username {
+			return true
+		}
+	}
+	return bucket(name, username) < f.Percent
+}
@@ -0,0 +1,55 @@
+package feature
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEnabled(t *testing.T) {
+	Register("test-off", "", State{})
+	Register("test-on", "", State{Enabled: true, Percent: 100})
+	Register("test-rollout", "", State{Enabled: true, Percent: 30, Users: []string{"tester"}})
+
+	if Enabled("test-off", "someone") || Enabled("test-unknown", "someone") {
+		t.Error("Feature off enabled")
+	}
+	if !Enabled("test-on", "") {
+		t.Error("Feature on disabled for the visitors")
+	}
+	if Enabled("test-rollout", "") {
+		t.Error("Rollout enabled for the visitors")
+	}
+	if !Enabled("test-rollout", "tester") {
+		t.Error("Rollout disabled for a listed user")
+	}
+
+	n := 0
+	for i := 0; i < 10000; i++ {
+		if Enabled("test-rollout", fmt.Sprintf("user%d", i)) {
+			n++
+		}
+	}
+	if n < 2500 || n > 3500 {
+		t.Errorf("Rollout enabled for %d users of 10000, expected about 3000", n)
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	Register("test-precedence", "", State{})
+
+	Configure(Info{"test-precedence": {Enabled: true, Percent: 100}})
+	if !Enabled("test-precedence", "someone") {
+		t.Error("Configuration ignored")
+	}
+
+	Override("test-precedence", State{Enabled: false})
+	if Enabled("test-precedence", "someone") {
+		t.Error("Override ignored")
+	}
+	for _, f := range Flags() {
+		if f.Name == "test-precedence" && f.Source != SourceAdmin {
+			t.Errorf("Source %s, expected %s", f.Source, SourceAdmin)
+		}
+	}
+	Configure(nil)
+}
@@ -0,0 +1,139 @@
+// Package gate keeps the new and inactive accounts from posting, to blunt the
+// waves of spam from freshly registered accounts.
+package gate
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// The gated actions
+const (
+	Comment  = "comment"
+	Crackme  = "crackme"
+	Solution = "solution"
+	Rate     = "rate"
+)
+
+// DefaultMaxPending caps the submissions waiting for approval of an account,
+// for the rules without MaxPending, so one account can't flood the queue
+var DefaultMaxPending = map[string]int{
+	Crackme:  3,
+	Solution: 10,
+}
+
+// pending names the submissions of the actions in the messages
+var pending = map[string]string{
+	Crackme:  "crackme",
+	Solution: "writeup",
+}
+
+// what describes the actions in the messages
+var what = map[string]string{
+	Comment:  "comment",
+	Crackme:  "upload crackmes",
+	Solution: "upload writeups",
+	Rate:     "rate crackmes",
+}
+
+var (
+	info  Info
+	mutex sync.RWMutex
+)
+
+// Rule is what an account needs for an action, zero values don't gate
+type Rule struct {
+	MinAccountMinutes int // Minutes since the account was created
+	MinCrackmes       int // Approved crackmes of the account
+	MinSolutions      int // Approved writeups of the account
+	MaxPending        int // Submissions of the account waiting for approval, DefaultMaxPending if 0, no cap if negative
+}
+
+// Info contains the rules, by action
+type Info map[string]Rule
+
+// Account is what the rules look at
+type Account struct {
+	Created   time.Time
+	Crackmes  int
+	Solutions int
+	Pending   int // Submissions of the action waiting for approval
+}
+
+// Denied is returned when an account doesn't pass the rule of an action, its
+// message explains why to the user
+type Denied struct {
+	Action  string
+	Message string
+}
+
+func (d *Denied) Error() string {
+	return d.Message
+}
+
+// Configure stores the rules
+func Configure(c Info) {
+	mutex.Lock()
+	info = c
+	mutex.Unlock()
+}
+
+// ReadConfig returns the rules
+func ReadConfig() Info {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return info
+}
+
+// Check returns nil if the account may do the action at now, else a *Denied
+func Check(action string, a Account, now time.Time) error {
+	rule := ReadConfig()[action]
+
+	if min := time.Duration(rule.MinAccountMinutes) * time.Minute; min > 0 && now.Sub(a.Created) < min {
+		return &Denied{action, fmt.Sprintf("Your account must be at least %s old to %s.", duration(min), what[action])}
+	}
+	if rule.MinCrackmes > 0 && a.Crackmes < rule.MinCrackmes {
+		return &Denied{action, fmt.Sprintf("You need %s approved to %s.", plural(rule.MinCrackmes, "crackme"), what[action])}
+	}
+	if rule.MinSolutions > 0 && a.Solutions < rule.MinSolutions {
+		return &Denied{action, fmt.Sprintf("You need %s approved to %s.", plural(rule.MinSolutions, "writeup"), what[action])}
+	}
+
+	if max := MaxPending(action); max > 0 && a.Pending >= max {
+		return &Denied{action, fmt.Sprintf("You already have %s waiting for approval, please wait for the moderators before you %s again.", plural(a.Pending, pending[action]), what[action])}
+	}
+	return nil
+}
+
+// MaxPending returns the cap on the submissions of an action waiting for
+// approval, 0 if not capped
+func MaxPending(action string) int {
+	max := ReadConfig()[action].MaxPending
+	if max == 0 {
+		max = DefaultMaxPending[action]
+	}
+	if max < 0 {
+		return 0
+	}
+	return max
+}
+
+// duration writes d in days, hours or minutes
+func duration(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return plural(int(d/(24*time.Hour)), "day")
+	case d%time.Hour == 0:
+		return plural(int(d/time.Hour), "hour")
+	}
+	return plural(int(d/time.Minute), "minute")
+}
+
+// plural writes n things
+func plural(n int, thing string) string {
+	if n == 1 {
+		return "1 " + thing
+	}
+	return fmt.Sprintf("%d %ss", n, thing)
+}
@@ -0,0 +1,42 @@
+package gate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	Configure(Info{
+		Comment:  {MinAccountMinutes: 60},
+		Rate:     {MinAccountMinutes: 2 * 24 * 60, MinSolutions: 1},
+		Solution: {MaxPending: -1},
+	})
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		action  string
+		account Account
+		message string
+	}{
+		{Comment, Account{Created: now.Add(-30 * time.Minute)}, "Your account must be at least 1 hour old to comment."},
+		{Comment, Account{Created: now.Add(-time.Hour)}, ""},
+		{Crackme, Account{Created: now}, ""},
+		{Rate, Account{Created: now.Add(-24 * time.Hour), Solutions: 3}, "Your account must be at least 2 days old to rate crackmes."},
+		{Rate, Account{Created: now.Add(-72 * time.Hour)}, "You need 1 writeup approved to rate crackmes."},
+		{Rate, Account{Created: now.Add(-72 * time.Hour), Solutions: 1}, ""},
+		{Crackme, Account{Created: now, Pending: 2}, ""},
+		{Crackme, Account{Created: now, Pending: 3}, "You already have 3 crackmes waiting for approval, please wait for the moderators before you upload crackmes again."},
+		{Solution, Account{Created: now, Pending: 50}, ""},
+	}
+
+	for i, tt := range tests {
+		err := Check(tt.action, tt.account, now)
+		message := ""
+		if err != nil {
+			message = err.Error()
+		}
+		if message != tt.message {
+			t.Errorf("%d: got %q, want %q", i, message, tt.message)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Package grace is the window after posting during which the authors can
+// still edit or withdraw their comments and their submissions waiting for
+// approval themselves, without asking the moderators.
+package grace
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultMinutes is the window when the settings don't set one
+const DefaultMinutes = 15
+
+// Info contains the grace period settings
+type Info struct {
+	Minutes int // Minutes the authors have after posting, DefaultMinutes if 0, no grace period if negative
+}
+
+var (
+	info  Info
+	mutex sync.RWMutex
+)
+
+// Configure stores the settings
+func Configure(i Info) {
+	mutex.Lock()
+	info = i
+	mutex.Unlock()
+}
+
+// Window returns how long after posting the authors can edit or withdraw,
+// 0 when they can't
+func Window() time.Duration {
+	mutex.RLock()
+	minutes := info.Minutes
+	mutex.RUnlock()
+
+	if minutes == 0 {
+		minutes = DefaultMinutes
+	} else if minutes < 0 {
+		return 0
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// Since returns the oldest posting date still in the window at now, the
+// model filters on it so a request can't slip past the end of the window
+func Since(now time.Time) time.Time {
+	return now.Add(-Window())
+}
+
+// Open tells if what was posted at a date can still be edited or withdrawn
+// at now
+func Open(posted, now time.Time) bool {
+	w := Window()
+	return w > 0 && now.Sub(posted) < w
+}
@@ -0,0 +1,29 @@
+package grace
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOpen(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		minutes int
+		posted  time.Time
+		open    bool
+	}{
+		{0, now.Add(-14 * time.Minute), true},
+		{0, now.Add(-15 * time.Minute), false},
+		{60, now.Add(-59 * time.Minute), true},
+		{60, now.Add(-2 * time.Hour), false},
+		{-1, now, false},
+	}
+	for _, tt := range tests {
+		Configure(Info{Minutes: tt.minutes})
+		if got := Open(tt.posted, now); got != tt.open {
+			t.Errorf("Minutes %d, posted %v ago: got %v, want %v", tt.minutes, now.Sub(tt.posted), got, tt.open)
+		}
+	}
+	Configure(Info{})
+}
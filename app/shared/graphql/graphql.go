@@ -0,0 +1,348 @@
+// Package graphql executes GraphQL queries on a schema of resolvers.
+//
+// Only the subset needed by read only APIs is supported: queries with field
+// selection, aliases, arguments and variables. There is no introspection.
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// *****************************************************************************
+// Schema
+// *****************************************************************************
+
+// Resolver returns the value of a field of the parent value
+type Resolver func(parent interface{}, args Args) (interface{}, error)
+
+// Field is a field of an object type
+type Field struct {
+	Type    string   // Object type of the value (or of the list items), empty for scalars
+	Args    []string // Accepted arguments
+	Resolve Resolver
+}
+
+// Object is an object type, by field name
+type Object map[string]Field
+
+// Schema holds the object types and the limits of the queries
+type Schema struct {
+	Query      string            // Root object type
+	Types      map[string]Object // Object types by name
+	MaxDepth   int               // Maximum nesting of the selections, 0 for no limit
+	MaxObjects int               // Maximum number of objects in a response, 0 for no limit
+}
+
+// Args holds the arguments of a field, with the variables substituted
+type Args map[string]interface{}
+
+// Int returns the integer argument name, or def when it is not given
+func (a Args) Int(name string, def int) int {
+	switch v := a[name].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return def
+}
+
+// String returns the string argument name, or "" when it is not given
+func (a Args) String(name string) string {
+	switch v := a[name].(type) {
+	case string:
+		return v
+	case enumValue:
+		return string(v)
+	}
+	return ""
+}
+
+// *****************************************************************************
+// Requests
+// *****************************************************************************
+
+// Request is a GraphQL request, as POSTed in JSON
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Response is the result of a request
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []Error     `json:"errors,omitempty"`
+}
+
+// Error is an error of a request, with the path of the field it occured on
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Execute runs the request on the schema. Errors of the document are returned
+// without data, errors of the resolvers along with the other fields.
+func (s *Schema) Execute(req Request) Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return failure(err.Error())
+	}
+
+	var op *operation
+	for _, o := range doc.operations {
+		if req.OperationName == "" || o.name == req.OperationName {
+			if op != nil {
+				return failure("operationName is required when there are several operations")
+			}
+			op = o
+		}
+	}
+	if op == nil {
+		return failure(fmt.Sprintf("unknown operation %q", req.OperationName))
+	}
+
+	vars := make(map[string]interface{})
+	for _, v := range op.variables {
+		value, ok := req.Variables[v.name]
+		if !ok && v.hasValue {
+			value, ok = v.def, true
+		}
+		if (!ok || value == nil) && v.nonNull {
+			return failure(fmt.Sprintf("variable $%s is required", v.name))
+		}
+		vars[v.name] = value
+	}
+
+	if err = s.validate(s.Query, op.selections, vars, 1); err != nil {
+		return failure(err.Error())
+	}
+
+	e := &executor{schema: s}
+	data := e.object(s.Query, nil, op.selections, vars, nil)
+	return Response{Data: data, Errors: e.errors}
+}
+
+// failure returns the response of a request that couldn't be executed
+func failure(message string) Response {
+	return Response{Errors: []Error{{Message: message}}}
+}
+
+// validate checks the selections against the type, before any execution
+func (s *Schema) validate(typ string, selections []*selection, vars map[string]interface{}, depth int) error {
+	if s.MaxDepth > 0 && depth > s.MaxDepth {
+		return fmt.Errorf("the query is nested deeper than %d levels", s.MaxDepth)
+	}
+
+	object := s.Types[typ]
+	for _, sel := range selections {
+		if sel.name == "__typename" {
+			if sel.selections != nil {
+				return fmt.Errorf("line %d: field \"__typename\" can't have a selection", sel.line)
+			}
+			continue
+		}
+
+		field, ok := object[sel.name]
+		if !ok {
+			return fmt.Errorf("line %d: cannot query field %q on type %q", sel.line, sel.name, typ)
+		}
+		for name, value := range sel.args {
+			if !contains(field.Args, name) {
+				return fmt.Errorf("line %d: unknown argument %q on field %q", sel.line, name, sel.name)
+			}
+			if err := checkVariables(value, vars); err != nil {
+				return fmt.Errorf("line %d: %v", sel.line, err)
+			}
+		}
+
+		if field.Type == "" && sel.selections != nil {
+			return fmt.Errorf("line %d: field %q of type %q can't have a selection", sel.line, sel.name, typ)
+		}
+		if field.Type != "" {
+			if sel.selections == nil {
+				return fmt.Errorf("line %d: field %q of type %q must have a selection of subfields", sel.line, sel.name, typ)
+			}
+			if err := s.validate(field.Type, sel.selections, vars, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkVariables checks that the variables of an argument value are defined
+func checkVariables(value interface{}, vars map[string]interface{}) error {
+	switch v := value.(type) {
+	case varRef:
+		if _, ok := vars[string(v)]; !ok {
+			return fmt.Errorf("variable $%s is not defined", v)
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := checkVariables(item, vars); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			if err := checkVariables(item, vars); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// *****************************************************************************
+// Execution
+// *****************************************************************************
+
+// executor holds the state of the execution of a request
+type executor struct {
+	schema   *Schema
+	objects  int  // Objects in the response so far
+	exceeded bool // Whether the response has too many objects
+	errors   []Error
+}
+
+// object resolves the selections on the value of type typ
+func (e *executor) object(typ string, value interface{}, selections []*selection, vars map[string]interface{}, path []interface{}) *orderedMap {
+	result := &orderedMap{}
+	for _, sel := range selections {
+		fieldPath := append(append([]interface{}{}, path...), sel.key())
+		if sel.name == "__typename" {
+			result.set(sel.key(), typ)
+			continue
+		}
+
+		field := e.schema.Types[typ][sel.name]
+		if field.Type != "" && e.exceeded {
+			result.set(sel.key(), nil)
+			continue
+		}
+
+		v, err := field.Resolve(value, substitute(sel.args, vars).(map[string]interface{}))
+		if err != nil {
+			e.fail(fieldPath, err.Error())
+			result.set(sel.key(), nil)
+			continue
+		}
+
+		if field.Type == "" {
+			result.set(sel.key(), v)
+		} else {
+			result.set(sel.key(), e.value(field.Type, v, sel.selections, vars, fieldPath))
+		}
+	}
+	return result
+}
+
+// value resolves the selections on an object, or on each item of a list
+func (e *executor) value(typ string, value interface{}, selections []*selection, vars map[string]interface{}, path []interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+	case reflect.Slice, reflect.Array:
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			list[i] = e.value(typ, rv.Index(i).Interface(), selections, vars, append(append([]interface{}{}, path...), i))
+		}
+		return list
+	}
+
+	e.objects++
+	if e.schema.MaxObjects > 0 && e.objects > e.schema.MaxObjects {
+		if !e.exceeded {
+			e.fail(path, fmt.Sprintf("the response has more than %d objects", e.schema.MaxObjects))
+			e.exceeded = true
+		}
+		return nil
+	}
+	return e.object(typ, value, selections, vars, path)
+}
+
+// fail records an error on a field
+func (e *executor) fail(path []interface{}, message string) {
+	e.errors = append(e.errors, Error{Message: message, Path: path})
+}
+
+// substitute replaces the variables of an argument value by their value
+func substitute(value interface{}, vars map[string]interface{}) interface{} {
+	switch v := value.(type) {
+	case varRef:
+		return vars[string(v)]
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i := range v {
+			list[i] = substitute(v[i], vars)
+		}
+		return list
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(v))
+		for name, item := range v {
+			obj[name] = substitute(item, vars)
+		}
+		return obj
+	}
+	return value
+}
+
+// orderedMap is a JSON object keeping the order of the selections
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (m *orderedMap) set(key string, value interface{}) {
+	if m.values == nil {
+		m.values = make(map[string]interface{})
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// MarshalJSON writes the fields in the order of the selections
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		js, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(js)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
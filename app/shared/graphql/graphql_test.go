@@ -0,0 +1,135 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type book struct {
+	Title  string
+	Author string
+}
+
+// schema returns a schema of books and their authors
+func schema() *Schema {
+	books := []book{{"Dune", "Herbert"}, {"Emma", "Austen"}, {"Persuasion", "Austen"}}
+	return &Schema{
+		Query: "Query",
+		Types: map[string]Object{
+			"Query": {
+				"books": {Type: "Book", Args: []string{"author", "first"}, Resolve: func(_ interface{}, args Args) (interface{}, error) {
+					var list []book
+					for _, b := range books {
+						if args.String("author") == "" || b.Author == args.String("author") {
+							list = append(list, b)
+						}
+					}
+					if first := args.Int("first", len(list)); first < len(list) {
+						list = list[:first]
+					}
+					return list, nil
+				}},
+				"book": {Type: "Book", Args: []string{"title"}, Resolve: func(_ interface{}, args Args) (interface{}, error) {
+					for i := range books {
+						if books[i].Title == args.String("title") {
+							return &books[i], nil
+						}
+					}
+					return (*book)(nil), nil
+				}},
+				"fail": {Resolve: func(interface{}, Args) (interface{}, error) {
+					return nil, errors.New("failed")
+				}},
+			},
+			"Book": {
+				"title": {Resolve: func(p interface{}, _ Args) (interface{}, error) {
+					return title(p), nil
+				}},
+				"related": {Type: "Book", Resolve: func(p interface{}, _ Args) (interface{}, error) {
+					return books, nil
+				}},
+			},
+		},
+		MaxDepth:   3,
+		MaxObjects: 5,
+	}
+}
+
+func title(p interface{}) string {
+	if b, ok := p.(*book); ok {
+		return b.Title
+	}
+	return p.(book).Title
+}
+
+// run executes the query and returns the response in JSON
+func run(t *testing.T, req Request) string {
+	js, err := json.Marshal(schema().Execute(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(js)
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		req      Request
+		expected string
+	}{
+		{Request{Query: `{ books { title } }`},
+			`{"data":{"books":[{"title":"Dune"},{"title":"Emma"},{"title":"Persuasion"}]}}`},
+		{Request{Query: `# Aliases and arguments
+			{ austen: books(author: "Austen", first: 1) { __typename, name: title } dune: book(title: "Dune") { title } }`},
+			`{"data":{"austen":[{"__typename":"Book","name":"Emma"}],"dune":{"title":"Dune"}}}`},
+		{Request{Query: `query Q($title: String!) { book(title: $title) { title } }`, Variables: map[string]interface{}{"title": "Emma"}},
+			`{"data":{"book":{"title":"Emma"}}}`},
+		{Request{Query: `query Q($first: Int = 2) { books(first: $first) { title } }`},
+			`{"data":{"books":[{"title":"Dune"},{"title":"Emma"}]}}`},
+		{Request{Query: `query A { book(title: "Emma") { title } } query B { book(title: "x") { title } }`, OperationName: "B"},
+			`{"data":{"book":null}}`},
+		{Request{Query: `{ fail books(first: 1) { title } }`},
+			`{"data":{"fail":null,"books":[{"title":"Dune"}]},"errors":[{"message":"failed","path":["fail"]}]}`},
+	}
+
+	for _, test := range tests {
+		if js := run(t, test.req); js != test.expected {
+			t.Errorf("%s\ngot      %s\nexpected %s", test.req.Query, js, test.expected)
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{`{ books { title }`, "syntax error"},
+		{`{ books { isbn } }`, `cannot query field "isbn" on type "Book"`},
+		{`{ books(year: 1) { title } }`, `unknown argument "year"`},
+		{`{ books }`, "must have a selection"},
+		{`{ fail { title } }`, "can't have a selection"},
+		{`{ books(author: $a) { title } }`, "$a is not defined"},
+		{`query Q($a: String!) { books(author: $a) { title } }`, "$a is required"},
+		{`mutation { books { title } }`, "only queries"},
+		{`{ ...f }`, "fragments are not supported"},
+		{`{ books { related { related { title } } } }`, "nested deeper than 3"},
+		{`query A { fail } query B { fail }`, "operationName is required"},
+	}
+
+	for _, test := range tests {
+		resp := schema().Execute(Request{Query: test.query})
+		if resp.Data != nil || len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0].Message, test.err) {
+			t.Errorf("%s: got %+v, expected the error %q", test.query, resp, test.err)
+		}
+	}
+}
+
+func TestMaxObjects(t *testing.T) {
+	// 3 books and 3 related books for each, only 5 are allowed
+	js := run(t, Request{Query: `{ books { related { title } } }`})
+	if !strings.Contains(js, `{"related":[null,null,null]},null]`) || !strings.Contains(js, "more than 5 objects") {
+		t.Errorf("Expected the related books over the limit to fail, got %s", js)
+	}
+}
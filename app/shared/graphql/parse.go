@@ -0,0 +1,477 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The parser supports the query operations of GraphQL: selection sets,
+// aliases, arguments and variables. Fragments, directives, mutations and
+// subscriptions are rejected.
+
+// document is a parsed GraphQL document
+type document struct {
+	operations []*operation
+}
+
+// operation is a query of a document
+type operation struct {
+	name       string
+	variables  []variable
+	selections []*selection
+}
+
+// variable is a variable definition of an operation
+type variable struct {
+	name     string
+	def      interface{} // Default value, nil if none
+	nonNull  bool
+	hasValue bool // Whether a default value is given
+}
+
+// selection is a field selected in a selection set
+type selection struct {
+	alias      string
+	name       string
+	args       map[string]interface{} // Values, with varRef for the variables
+	selections []*selection
+	line       int
+}
+
+// key returns the name of the field in the response
+func (s *selection) key() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+// varRef is a reference to a variable in an argument value
+type varRef string
+
+// enumValue is an enum value in an argument, passed to the resolvers as a string
+type enumValue string
+
+// token kinds
+const (
+	tokEOF = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind  int
+	value string
+	line  int
+}
+
+// lexer splits a document into tokens
+type lexer struct {
+	src  string
+	pos  int
+	line int
+	tok  token
+}
+
+// next reads the next token
+func (l *lexer) next() error {
+	// Skip the ignored tokens: white space, commas and comments
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == '\n' {
+			l.line++
+			l.pos++
+		} else if c == ' ' || c == '\t' || c == '\r' || c == ',' {
+			l.pos++
+		} else if c == '#' {
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		} else {
+			break
+		}
+	}
+
+	if l.pos >= len(l.src) {
+		l.tok = token{kind: tokEOF, line: l.line}
+		return nil
+	}
+
+	start := l.pos
+	c := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		l.tok = token{tokPunct, "...", l.line}
+	case strings.IndexByte("!$():=@[]{}|", c) >= 0:
+		l.pos++
+		l.tok = token{tokPunct, string(c), l.line}
+	case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		for l.pos < len(l.src) && isNameChar(l.src[l.pos]) {
+			l.pos++
+		}
+		l.tok = token{tokName, l.src[start:l.pos], l.line}
+	case c == '-' || c >= '0' && c <= '9':
+		kind := tokInt
+		l.pos++
+		for l.pos < len(l.src) {
+			c = l.src[l.pos]
+			if c == '.' || c == 'e' || c == 'E' || (c == '-' || c == '+') && (l.src[l.pos-1] == 'e' || l.src[l.pos-1] == 'E') {
+				kind = tokFloat
+			} else if c < '0' || c > '9' {
+				break
+			}
+			l.pos++
+		}
+		l.tok = token{kind, l.src[start:l.pos], l.line}
+	case c == '"':
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			return l.errorf("block strings are not supported")
+		}
+		var b strings.Builder
+		l.pos++
+		for {
+			if l.pos >= len(l.src) || l.src[l.pos] == '\n' {
+				return l.errorf("unterminated string")
+			}
+			c = l.src[l.pos]
+			if c == '"' {
+				l.pos++
+				break
+			}
+			if c == '\\' && l.pos+1 < len(l.src) {
+				l.pos++
+				switch e := l.src[l.pos]; e {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				case 'r':
+					b.WriteByte('\r')
+				case 'b':
+					b.WriteByte('\b')
+				case 'f':
+					b.WriteByte('\f')
+				case 'u':
+					if l.pos+4 >= len(l.src) {
+						return l.errorf("invalid unicode escape")
+					}
+					r, err := strconv.ParseUint(l.src[l.pos+1:l.pos+5], 16, 32)
+					if err != nil {
+						return l.errorf("invalid unicode escape")
+					}
+					b.WriteRune(rune(r))
+					l.pos += 4
+				default:
+					b.WriteByte(e)
+				}
+				l.pos++
+				continue
+			}
+			b.WriteByte(c)
+			l.pos++
+		}
+		l.tok = token{tokString, b.String(), l.line}
+	default:
+		return l.errorf("unexpected character %q", c)
+	}
+	return nil
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (l *lexer) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("syntax error line %d: %s", l.line, fmt.Sprintf(format, args...))
+}
+
+// is returns true if the current token is the punctuator or the name v
+func (l *lexer) is(v string) bool {
+	return (l.tok.kind == tokPunct || l.tok.kind == tokName) && l.tok.value == v
+}
+
+// expect reads the punctuator v
+func (l *lexer) expect(v string) error {
+	if !l.is(v) {
+		return l.errorf("expected %s, found %q", v, l.tok.value)
+	}
+	return l.next()
+}
+
+// name reads a name
+func (l *lexer) name() (string, error) {
+	if l.tok.kind != tokName {
+		return "", l.errorf("expected a name, found %q", l.tok.value)
+	}
+	name := l.tok.value
+	return name, l.next()
+}
+
+// parse parses a document
+func parse(src string) (*document, error) {
+	l := &lexer{src: src, line: 1}
+	if err := l.next(); err != nil {
+		return nil, err
+	}
+
+	doc := &document{}
+	for l.tok.kind != tokEOF {
+		op, err := l.operation()
+		if err != nil {
+			return nil, err
+		}
+		doc.operations = append(doc.operations, op)
+	}
+	if len(doc.operations) == 0 {
+		return nil, l.errorf("no operation")
+	}
+	return doc, nil
+}
+
+// operation parses an operation definition
+func (l *lexer) operation() (*operation, error) {
+	op := &operation{}
+	if l.is("{") {
+		var err error
+		op.selections, err = l.selectionSet()
+		return op, err
+	}
+
+	switch {
+	case l.is("query"):
+	case l.is("mutation"), l.is("subscription"):
+		return nil, l.errorf("only queries are supported")
+	case l.is("fragment"):
+		return nil, l.errorf("fragments are not supported")
+	default:
+		return nil, l.errorf("unexpected %q", l.tok.value)
+	}
+	if err := l.next(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if l.tok.kind == tokName {
+		if op.name, err = l.name(); err != nil {
+			return nil, err
+		}
+	}
+	if l.is("(") {
+		if op.variables, err = l.variableDefinitions(); err != nil {
+			return nil, err
+		}
+	}
+	if l.is("@") {
+		return nil, l.errorf("directives are not supported")
+	}
+	op.selections, err = l.selectionSet()
+	return op, err
+}
+
+// variableDefinitions parses the variable definitions of an operation
+func (l *lexer) variableDefinitions() ([]variable, error) {
+	var vars []variable
+	if err := l.expect("("); err != nil {
+		return nil, err
+	}
+	for !l.is(")") {
+		var v variable
+		var err error
+		if err = l.expect("$"); err != nil {
+			return nil, err
+		}
+		if v.name, err = l.name(); err != nil {
+			return nil, err
+		}
+		if err = l.expect(":"); err != nil {
+			return nil, err
+		}
+		if v.nonNull, err = l.typeRef(); err != nil {
+			return nil, err
+		}
+		if l.is("=") {
+			if err = l.next(); err != nil {
+				return nil, err
+			}
+			if v.def, err = l.value(true); err != nil {
+				return nil, err
+			}
+			v.hasValue = true
+		}
+		vars = append(vars, v)
+	}
+	return vars, l.next()
+}
+
+// typeRef parses a type reference, returning if it is non null
+func (l *lexer) typeRef() (bool, error) {
+	if l.is("[") {
+		if err := l.next(); err != nil {
+			return false, err
+		}
+		if _, err := l.typeRef(); err != nil {
+			return false, err
+		}
+		if err := l.expect("]"); err != nil {
+			return false, err
+		}
+	} else if _, err := l.name(); err != nil {
+		return false, err
+	}
+	if l.is("!") {
+		return true, l.next()
+	}
+	return false, nil
+}
+
+// selectionSet parses a selection set
+func (l *lexer) selectionSet() ([]*selection, error) {
+	if err := l.expect("{"); err != nil {
+		return nil, err
+	}
+	var selections []*selection
+	for !l.is("}") {
+		if l.is("...") {
+			return nil, l.errorf("fragments are not supported")
+		}
+		s, err := l.field()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, s)
+	}
+	if len(selections) == 0 {
+		return nil, l.errorf("empty selection set")
+	}
+	return selections, l.next()
+}
+
+// field parses a field selection
+func (l *lexer) field() (*selection, error) {
+	s := &selection{line: l.tok.line}
+	var err error
+	if s.name, err = l.name(); err != nil {
+		return nil, err
+	}
+	if l.is(":") {
+		if err = l.next(); err != nil {
+			return nil, err
+		}
+		s.alias = s.name
+		if s.name, err = l.name(); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.is("(") {
+		if err = l.next(); err != nil {
+			return nil, err
+		}
+		s.args = make(map[string]interface{})
+		for !l.is(")") {
+			name, err := l.name()
+			if err != nil {
+				return nil, err
+			}
+			if err = l.expect(":"); err != nil {
+				return nil, err
+			}
+			if s.args[name], err = l.value(false); err != nil {
+				return nil, err
+			}
+		}
+		if err = l.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.is("@") {
+		return nil, l.errorf("directives are not supported")
+	}
+	if l.is("{") {
+		if s.selections, err = l.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// value parses a value, constant ones can't hold variables
+func (l *lexer) value(constant bool) (interface{}, error) {
+	tok := l.tok
+	switch {
+	case tok.kind == tokPunct && tok.value == "$":
+		if constant {
+			return nil, l.errorf("unexpected variable")
+		}
+		if err := l.next(); err != nil {
+			return nil, err
+		}
+		name, err := l.name()
+		return varRef(name), err
+	case tok.kind == tokInt:
+		n, err := strconv.Atoi(tok.value)
+		if err != nil {
+			return nil, l.errorf("invalid integer %s", tok.value)
+		}
+		return n, l.next()
+	case tok.kind == tokFloat:
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, l.errorf("invalid float %s", tok.value)
+		}
+		return f, l.next()
+	case tok.kind == tokString:
+		return tok.value, l.next()
+	case tok.kind == tokName:
+		var v interface{}
+		switch tok.value {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			v = enumValue(tok.value)
+		}
+		return v, l.next()
+	case tok.kind == tokPunct && tok.value == "[":
+		if err := l.next(); err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for !l.is("]") {
+			v, err := l.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, l.next()
+	case tok.kind == tokPunct && tok.value == "{":
+		if err := l.next(); err != nil {
+			return nil, err
+		}
+		obj := map[string]interface{}{}
+		for !l.is("}") {
+			name, err := l.name()
+			if err != nil {
+				return nil, err
+			}
+			if err = l.expect(":"); err != nil {
+				return nil, err
+			}
+			if obj[name], err = l.value(constant); err != nil {
+				return nil, err
+			}
+		}
+		return obj, l.next()
+	}
+	return nil, l.errorf("unexpected %q", tok.value)
+}
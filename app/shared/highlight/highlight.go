@@ -0,0 +1,232 @@
+// Package highlight colors the code snippets of the descriptions and the
+// writeups. It knows the languages reversers write pseudocode and
+// disassembly in, and marks their keywords, types or registers, strings,
+// numbers and comments with spans of the classes hl-k, hl-t, hl-s, hl-n and
+// hl-c. The code of an unknown language is only escaped.
+package highlight
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// language is what the highlighter needs to know of a language
+type language struct {
+	keywords     map[string]bool
+	types        map[string]bool
+	lineComments []string
+	blockComment [2]string
+	quotes       string
+	ignoreCase   bool // Assembly mnemonics and registers are written in both cases
+}
+
+func words(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var (
+	cLike = language{
+		keywords: words(`if else for while do switch case default break continue return goto sizeof
+			typedef struct union enum static const volatile extern register inline
+			class public private protected virtual template typename namespace using new delete
+			this nullptr true false try catch throw operator auto`),
+		types: words(`void char short int long float double signed unsigned bool
+			int8_t int16_t int32_t int64_t uint8_t uint16_t uint32_t uint64_t size_t
+			BYTE WORD DWORD QWORD BOOL HANDLE LPVOID`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	}
+	asm = language{
+		keywords: words(`mov movzx movsx lea push pop call ret jmp je jne jz jnz jg jge jl jle ja jae jb jbe js jns
+			cmp test add sub mul imul div idiv inc dec neg not and or xor shl shr sal sar rol ror
+			nop int syscall leave enter loop rep cmovz cmovnz sete setne xchg cdq cqo
+			ldr str ldp stp bl blx bx b cbz cbnz beq bne adr adrp movk svc`),
+		types: words(`al ah ax eax rax bl bh bx ebx rbx cl ch cx ecx rcx dl dh dx edx rdx
+			si esi rsi di edi rdi sp esp rsp bp ebp rbp ip eip rip
+			r8 r9 r10 r11 r12 r13 r14 r15 r8d r9d r10d r11d r12d r13d r14d r15d
+			byte word dword qword ptr
+			x0 x1 x2 x3 x4 x5 x6 x7 x8 x29 x30 w0 w1 w2 w3 w4 w5 w6 w7 w8 lr pc xzr wzr`),
+		lineComments: []string{";", "#", "//"},
+		quotes:       `"'`,
+		ignoreCase:   true,
+	}
+	python = language{
+		keywords: words(`def class return if elif else for while in not and or is import from as
+			with try except finally raise pass break continue lambda yield global nonlocal
+			True False None`),
+		types:        words(`int str bytes bytearray list dict set tuple bool float len range print ord chr hex`),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	}
+	golang = language{
+		keywords: words(`break case chan const continue default defer else fallthrough for func go goto
+			if import interface map package range return select struct switch type var
+			true false nil`),
+		types: words(`bool byte rune string error int int8 int16 int32 int64 uint uint8 uint16 uint32 uint64
+			uintptr float32 float64`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+	}
+	rust = language{
+		keywords: words(`as break const continue crate else enum extern fn for if impl in let loop match mod
+			move mut pub ref return self Self static struct super trait type unsafe use where while
+			true false`),
+		types: words(`i8 i16 i32 i64 i128 isize u8 u16 u32 u64 u128 usize f32 f64 bool char str
+			String Vec Option Result Box`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"`,
+	}
+	javascript = language{
+		keywords: words(`var let const function return if else for while do switch case default break
+			continue new delete typeof instanceof in of this class extends import export from
+			try catch finally throw async await yield true false null undefined`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+	}
+	java = language{
+		keywords: words(`abstract class extends implements interface package import public private protected
+			static final native synchronized transient volatile return if else for while do switch
+			case default break continue new this super try catch finally throw throws instanceof
+			true false null`),
+		types: words(`void boolean byte char short int long float double String Object
+			var string uint ulong ushort sbyte decimal object`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	}
+)
+
+// languages are the languages by the names given after the fences
+var languages = map[string]*language{
+	"c": &cLike, "h": &cLike, "cpp": &cLike, "c++": &cLike, "cc": &cLike, "pseudocode": &cLike,
+	"asm": &asm, "nasm": &asm, "masm": &asm, "x86": &asm, "x86asm": &asm, "armasm": &asm, "assembly": &asm,
+	"python": &python, "py": &python,
+	"go": &golang, "golang": &golang,
+	"rust": &rust, "rs": &rust,
+	"javascript": &javascript, "js": &javascript,
+	"java": &java, "cs": &java, "csharp": &java, "c#": &java,
+}
+
+// Known returns true if the language of a fence is highlighted
+func Known(lang string) bool {
+	return languages[strings.ToLower(lang)] != nil
+}
+
+// span writes an escaped token in a span of a class
+func span(b *strings.Builder, class, token string) {
+	b.WriteString(`<span class="` + class + `">` + html.EscapeString(token) + `</span>`)
+}
+
+func isWord(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wordEnd returns the offset of the end of the word starting at i
+func wordEnd(code string, i int) int {
+	for i < len(code) {
+		r, size := utf8.DecodeRuneInString(code[i:])
+		if !isWord(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// HTML returns the code escaped with its tokens highlighted according to the
+// language
+func HTML(lang, code string) string {
+	l := languages[strings.ToLower(lang)]
+	if l == nil {
+		return html.EscapeString(code)
+	}
+
+	// The code is scanned by byte offsets, the rest of it is never copied
+	var b strings.Builder
+	for i := 0; i < len(code); {
+		rest := code[i:]
+
+		// Comments
+		if start := l.blockComment[0]; start != "" && strings.HasPrefix(rest, start) {
+			end := strings.Index(rest[len(start):], l.blockComment[1])
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += len(start) + len(l.blockComment[1])
+			}
+			span(&b, "hl-c", rest[:end])
+			i += end
+			continue
+		}
+		comment := false
+		for _, start := range l.lineComments {
+			if strings.HasPrefix(rest, start) {
+				comment = true
+				break
+			}
+		}
+		if comment {
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			span(&b, "hl-c", rest[:end])
+			i += end
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(rest)
+		switch {
+		case strings.ContainsRune(l.quotes, r):
+			// Strings end at the closing quote or the end of the line
+			j := i + size
+			for j < len(code) && rune(code[j]) != r && code[j] != '\n' {
+				if code[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j < len(code) && rune(code[j]) == r {
+				j++
+			}
+			if j > len(code) {
+				j = len(code)
+			}
+			span(&b, "hl-s", code[i:j])
+			i = j
+		case unicode.IsDigit(r):
+			j := wordEnd(code, i)
+			span(&b, "hl-n", code[i:j])
+			i = j
+		case isWord(r):
+			j := wordEnd(code, i)
+			word := code[i:j]
+			key := word
+			if l.ignoreCase {
+				key = strings.ToLower(word)
+			}
+			if l.keywords[key] {
+				span(&b, "hl-k", word)
+			} else if l.types[key] {
+				span(&b, "hl-t", word)
+			} else {
+				b.WriteString(html.EscapeString(word))
+			}
+			i = j
+		default:
+			b.WriteString(html.EscapeString(rest[:size]))
+			i += size
+		}
+	}
+	return b.String()
+}
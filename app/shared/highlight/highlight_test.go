@@ -0,0 +1,51 @@
+package highlight
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		lang, code, want string
+	}{
+		{"c", `if (x == 0x1337) return "ok"; // <done>`,
+			`<span class="hl-k">if</span> (x == <span class="hl-n">0x1337</span>) <span class="hl-k">return</span> <span class="hl-s">&#34;ok&#34;</span>; <span class="hl-c">// &lt;done&gt;</span>`},
+		{"C", "int/* a */b", `<span class="hl-t">int</span><span class="hl-c">/* a */</span>b`},
+		{"nasm", "XOR eax, eax ; zero", `<span class="hl-k">XOR</span> <span class="hl-t">eax</span>, <span class="hl-t">eax</span> <span class="hl-c">; zero</span>`},
+		{"py", `s = 'a\'b'`, `s = <span class="hl-s">&#39;a\&#39;b&#39;</span>`},
+		{"c", `"unterminated`, `<span class="hl-s">&#34;unterminated</span>`},
+		{"brainfuck", "<+>", "&lt;+&gt;"},
+	}
+	for _, tt := range tests {
+		if got := HTML(tt.lang, tt.code); got != tt.want {
+			t.Errorf("HTML(%q, %q) =\n%s\nwant\n%s", tt.lang, tt.code, got, tt.want)
+		}
+	}
+}
+
+// The highlighting runs on every page view, its time must grow linearly with
+// the code: 4 times the code can't take 10 times longer
+func TestHTMLLinear(t *testing.T) {
+	code := func(n int) string {
+		return strings.Repeat("int x = 0x41; // é\n/* b */ 'c' ", n/30)
+	}
+	elapsed := func(code string) time.Duration {
+		best := time.Duration(math.MaxInt64)
+		for i := 0; i < 3; i++ {
+			start := time.Now()
+			HTML("c", code)
+			if d := time.Since(start); d < best {
+				best = d
+			}
+		}
+		return best
+	}
+
+	small, large := elapsed(code(25000)), elapsed(code(100000))
+	if large > 10*small && large > 50*time.Millisecond {
+		t.Errorf("highlighting 100 K characters took %v, 25 K took %v", large, small)
+	}
+}
@@ -0,0 +1,165 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// MailgunInfo contains the settings of the Mailgun API
+type MailgunInfo struct {
+	Domain  string // Sending domain
+	APIKey  string
+	BaseURL string // https://api.mailgun.net by default, https://api.eu.mailgun.net for the EU region
+}
+
+// MailgunSender sends the messages with the Mailgun API
+type MailgunSender struct {
+	MailgunInfo
+}
+
+// Send posts the message to Mailgun
+func (s MailgunSender) Send(from string, m Message) error {
+	base := s.BaseURL
+	if base == "" {
+		base = "https://api.mailgun.net"
+	}
+	form := url.Values{"from": {from}, "to": {m.To}, "subject": {m.Subject}, "text": {m.Body}}
+
+	req, err := http.NewRequest("POST", strings.TrimSuffix(base, "/")+"/v3/"+s.Domain+"/messages", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("api", s.APIKey)
+	return do(req)
+}
+
+// SESInfo contains the settings of the Amazon SES API
+type SESInfo struct {
+	Region    string // e.g. eu-west-1
+	AccessKey string
+	SecretKey string
+	Endpoint  string // https://email.<Region>.amazonaws.com by default
+}
+
+// SESSender sends the messages with the SES v2 API, signed with AWS
+// Signature Version 4
+type SESSender struct {
+	SESInfo
+}
+
+// Send posts the message to SES
+func (s SESSender) Send(from string, m Message) error {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://email." + s.Region + ".amazonaws.com"
+	}
+	content := func(data string) map[string]string {
+		return map[string]string{"Data": data, "Charset": "UTF-8"}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"FromEmailAddress": from,
+		"Destination":      map[string][]string{"ToAddresses": {m.To}},
+		"Content": map[string]interface{}{
+			"Simple": map[string]interface{}{
+				"Subject": content(m.Subject),
+				"Body":    map[string]interface{}{"Text": content(m.Body)},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimSuffix(endpoint, "/")+"/v2/email/outbound-emails", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signV4(req, body, "ses", s.Region, s.AccessKey, s.SecretKey, time.Now())
+	return do(req)
+}
+
+// signV4 adds the X-Amz-Date and Authorization headers of AWS Signature
+// Version 4 to the request, signing its host, its date and its content type
+func signV4(req *http.Request, body []byte, service, region, accessKey, secretKey string, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host, "x-amz-date": amzDate}
+	if ct := req.Header.Get("Content-Type"); ct != "" {
+		headers["content-type"] = ct
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payload := sha256.Sum256(body)
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payload[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	hashed := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// do sends the request and fails on any non 2xx answer, with the beginning
+// of the body giving the reason
+func do(req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		reason, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("%s answered %s: %s", req.URL.Host, resp.Status, bytes.TrimSpace(reason))
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+// Package mail sends the emails of the site through a pluggable sender: an
+// SMTP server, the Mailgun or Amazon SES APIs, or the log during the
+// development. Send delivers a message right away, Queue in the background
+// with retries.
+package mail
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	info   Info
+	sender Sender
+	queue  chan job
+	mutex  sync.RWMutex
+
+	// retryDelay is the delay of the first retry of a queued message, doubled
+	// at each attempt
+	retryDelay = time.Minute
+)
+
+// ErrNotConfigured is returned when sending without a sender
+var ErrNotConfigured = errors.New("mail: no sender configured")
+
+// Info contains the mail configuration. The SMTP server is given at the top
+// level, as before the other senders.
+type Info struct {
+	Driver    string      // "smtp", "mailgun", "ses" or "log", "smtp" by default when a Hostname is set
+	From      string      // Address of the sender
+	Hostname  string      // SMTP server
+	Port      int         // SMTP port
+	Username  string      // SMTP user
+	Password  string      // SMTP password
+	Mailgun   MailgunInfo // Mailgun API
+	SES       SESInfo     // Amazon SES API
+	Retries   int         // Number of retries of a queued message
+	QueueSize int         // Number of messages waiting before new ones are dropped
+}
+
+// Message is an email in plain text
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers a message from an address
+type Sender interface {
+	Send(from string, m Message) error
+}
+
+// job is one delivery attempt of a queued message
+type job struct {
+	message Message
+	attempt int
+}
+
+// Configure stores the settings, picks the sender of the driver and starts
+// the delivery worker. Without a driver nor an SMTP server no mail is sent.
+func Configure(c Info) {
+	if c.QueueSize <= 0 {
+		c.QueueSize = 100
+	}
+	if c.Driver == "" && c.Hostname != "" {
+		c.Driver = "smtp"
+	}
+
+	var s Sender
+	switch c.Driver {
+	case "smtp":
+		s = SMTPSender{Hostname: c.Hostname, Port: c.Port, Username: c.Username, Password: c.Password}
+	case "mailgun":
+		s = MailgunSender{c.Mailgun}
+	case "ses":
+		s = SESSender{c.SES}
+	case "log":
+		s = LogSender{}
+	case "":
+	default:
+		log.Println("Unknown mail driver", c.Driver, "no mail will be sent")
+	}
+
+	mutex.Lock()
+	info = c
+	sender = s
+	if queue == nil {
+		queue = make(chan job, c.QueueSize)
+		go work()
+	}
+	mutex.Unlock()
+}
+
+// ReadConfig returns the mail settings
+func ReadConfig() Info {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return info
+}
+
+// Register replaces the sender, for the tests or a sender configured in code
+func Register(s Sender) {
+	mutex.Lock()
+	sender = s
+	mutex.Unlock()
+}
+
+// Available tells whether the emails can be sent
+func Available() bool {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return sender != nil
+}
+
+// Send delivers the message now
+func Send(m Message) error {
+	mutex.RLock()
+	s, from := sender, info.From
+	mutex.RUnlock()
+
+	if s == nil {
+		return ErrNotConfigured
+	}
+	return s.Send(from, m)
+}
+
+// Queue sends the message in the background, retried with an exponential
+// backoff when it fails. It never blocks: it returns false when no mail can
+// be sent or the queue is full.
+func Queue(m Message) bool {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if sender == nil || queue == nil {
+		return false
+	}
+	return enqueue(job{message: m})
+}
+
+func enqueue(j job) bool {
+	select {
+	case queue <- j:
+		return true
+	default:
+		log.Println("Mail queue full, dropping the mail to", j.message.To)
+		return false
+	}
+}
+
+// work sends the queued messages, scheduling a retry when a delivery fails
+func work() {
+	for j := range queue {
+		err := Send(j.message)
+		if err == nil {
+			continue
+		}
+
+		if j.attempt >= ReadConfig().Retries {
+			log.Println("Mail to", j.message.To, "not sent, giving up:", err)
+			continue
+		}
+
+		log.Println("Mail to", j.message.To, "not sent, retrying:", err)
+		retry := job{message: j.message, attempt: j.attempt + 1}
+		time.AfterFunc(time.Duration(1<<uint(j.attempt))*retryDelay, func() {
+			enqueue(retry)
+		})
+	}
+}
+
+// LogSender only logs the messages, for the development
+type LogSender struct{}
+
+// Send logs the message
+func (LogSender) Send(from string, m Message) error {
+	log.Printf("Mail from %s to %s: %s\n%s", from, m.To, m.Subject, m.Body)
+	return nil
+}
@@ -0,0 +1,91 @@
+package mail
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSignV4(t *testing.T) {
+	// Reference values from the get-vanilla case of the AWS Signature
+	// Version 4 test suite
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	signV4(req, nil, "service", "us-east-1", "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestMailgunSender(t *testing.T) {
+	var path, user, to string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		user, _, _ = r.BasicAuth()
+		to = r.FormValue("to")
+	}))
+	defer ts.Close()
+
+	s := MailgunSender{MailgunInfo{Domain: "mg.example.com", APIKey: "key", BaseURL: ts.URL}}
+	if err := s.Send("noreply@example.com", Message{To: "user@example.com", Subject: "Hi", Body: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v3/mg.example.com/messages" || user != "api" || to != "user@example.com" {
+		t.Errorf("got %s as %s to %s", path, user, to)
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	tmpl := MustTemplate("Hello\n{{.}}", "Dear {{.}},\nwelcome.")
+	m, err := tmpl.Message("user@example.com", "dave")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Subject != "Hello dave" || m.Body != "Dear dave,\nwelcome." {
+		t.Errorf("got %q and %q", m.Subject, m.Body)
+	}
+	if !strings.Contains(string(Encode("noreply@example.com", m)), "Subject: Hello dave\r\n") {
+		t.Error("Subject header missing")
+	}
+}
+
+// flakySender fails the first deliveries
+type flakySender struct {
+	mutex    sync.Mutex
+	failures int
+	sent     chan Message
+}
+
+func (s *flakySender) Send(from string, m Message) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("unavailable")
+	}
+	s.sent <- m
+	return nil
+}
+
+func TestQueueRetries(t *testing.T) {
+	retryDelay = time.Millisecond
+	Configure(Info{Retries: 2})
+	s := &flakySender{failures: 2, sent: make(chan Message, 1)}
+	Register(s)
+
+	if !Queue(Message{To: "user@example.com"}) {
+		t.Fatal("Message not queued")
+	}
+	select {
+	case m := <-s.sent:
+		if m.To != "user@example.com" {
+			t.Errorf("got a message to %s", m.To)
+		}
+	case <-time.After(time.Second):
+		t.Error("Message not sent after its retries")
+	}
+}
@@ -0,0 +1,40 @@
+package mail
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+)
+
+// SMTPSender sends the messages through an SMTP server
+type SMTPSender struct {
+	Hostname string
+	Port     int
+	Username string
+	Password string
+}
+
+// Send sends the message, its body base64 encoded
+func (s SMTPSender) Send(from string, m Message) error {
+	auth := smtp.PlainAuth("", s.Username, s.Password, s.Hostname)
+	return smtp.SendMail(fmt.Sprintf("%s:%d", s.Hostname, s.Port), auth, from, []string{m.To}, Encode(from, m))
+}
+
+// Encode returns the message with its headers, as sent over SMTP
+func Encode(from string, m Message) []byte {
+	var b strings.Builder
+	for _, header := range [][2]string{
+		{"From", from},
+		{"To", m.To},
+		{"Subject", mime.QEncoding.Encode("utf-8", m.Subject)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", `text/plain; charset="utf-8"`},
+		{"Content-Transfer-Encoding", "base64"},
+	} {
+		fmt.Fprintf(&b, "%s: %s\r\n", header[0], header[1])
+	}
+	b.WriteString("\r\n" + base64.StdEncoding.EncodeToString([]byte(m.Body)))
+	return []byte(b.String())
+}
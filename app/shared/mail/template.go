@@ -0,0 +1,51 @@
+package mail
+
+import (
+	"strings"
+	"text/template"
+)
+
+// Template is a message whose subject and body are text templates, e.g.
+//
+//	var welcome = mail.MustTemplate("Welcome {{.Name}}", "Hello {{.Name}},\n...")
+//	m, err := welcome.Message(user.Email, user)
+type Template struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// NewTemplate parses the subject and the body of a message
+func NewTemplate(subject, body string) (*Template, error) {
+	s, err := template.New("subject").Parse(subject)
+	if err != nil {
+		return nil, err
+	}
+	b, err := template.New("body").Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{subject: s, body: b}, nil
+}
+
+// MustTemplate is NewTemplate panicking on errors, for the templates of the
+// package variables
+func MustTemplate(subject, body string) *Template {
+	t, err := NewTemplate(subject, body)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Message returns the message to an address, rendered with data
+func (t *Template) Message(to string, data interface{}) (Message, error) {
+	var subject, body strings.Builder
+	if err := t.subject.Execute(&subject, data); err != nil {
+		return Message{}, err
+	}
+	if err := t.body.Execute(&body, data); err != nil {
+		return Message{}, err
+	}
+	// A subject is a single line
+	return Message{To: to, Subject: strings.Join(strings.Fields(subject.String()), " "), Body: body.String()}, nil
+}
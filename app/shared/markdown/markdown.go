@@ -0,0 +1,231 @@
+// Package markdown renders the subset of Markdown used by the pages edited by
+// the admins: headings, paragraphs, lists, quotes, code, rules, links and
+// emphasis. Raw HTML is escaped, so the output is safe to display. The fenced
+// code blocks of the known languages are highlighted.
+package markdown
+
+import (
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+
+	"github.com/crackmesone/crackmes.one/app/shared/highlight"
+)
+
+var (
+	headingRe  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	ruleRe     = regexp.MustCompile(`^(\*\s*){3,}$|^(-\s*){3,}$|^(_\s*){3,}$`)
+	bulletRe   = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+	orderedRe  = regexp.MustCompile(`^\d{1,9}[.)]\s+(.*)$`)
+	linkRe     = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	boldRe     = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	italicRe   = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+	slugStrip  = regexp.MustCompile(`[^a-z0-9]+`)
+	safeURLRes = []string{"http://", "https://", "mailto:", "/", "#"}
+)
+
+// Slug returns the id of a heading, e.g. "Difficulty Guide" gives
+// "difficulty-guide"
+func Slug(s string) string {
+	return strings.Trim(slugStrip.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// safeURL returns true for the URLs links may point to, which excludes
+// javascript: and data: URLs
+func safeURL(url string) bool {
+	for _, prefix := range safeURLRes {
+		if strings.HasPrefix(url, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// inline renders the emphasis, code spans and links of a line
+func inline(s string) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(s, '`')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(s[start+1:], '`')
+		if end < 0 {
+			break
+		}
+		b.WriteString(inlineText(s[:start]))
+		b.WriteString("<code>" + html.EscapeString(s[start+1:start+1+end]) + "</code>")
+		s = s[start+end+2:]
+	}
+	b.WriteString(inlineText(s))
+	return b.String()
+}
+
+// inlineText renders the emphasis and links of text without code spans
+func inlineText(s string) string {
+	s = html.EscapeString(s)
+	s = linkRe.ReplaceAllStringFunc(s, func(m string) string {
+		parts := linkRe.FindStringSubmatch(m)
+		if !safeURL(parts[2]) {
+			return parts[1]
+		}
+		return `<a href="` + parts[2] + `">` + parts[1] + `</a>`
+	})
+	s = boldRe.ReplaceAllString(s, "<b>$1$2</b>")
+	s = italicRe.ReplaceAllString(s, "<i>$1$2</i>")
+	return s
+}
+
+// fence renders a fenced code block, highlighted if the language given after
+// the opening fence is known
+func fence(opening string, code []string) string {
+	lang := strings.TrimSpace(strings.TrimLeft(opening, "`"))
+	if fields := strings.Fields(lang); len(fields) > 0 {
+		lang = fields[0]
+	}
+	if !highlight.Known(lang) {
+		return "<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>"
+	}
+	return `<pre><code class="language-` + html.EscapeString(strings.ToLower(lang)) + `">` + highlight.HTML(lang, strings.Join(code, "\n")) + "</code></pre>"
+}
+
+// Text renders plain text, such as the descriptions of the crackmes and the
+// writeups, keeping its line breaks. Only its fenced code blocks are
+// rendered, the rest is escaped.
+func Text(src string) template.HTML {
+	lines := strings.Split(strings.Replace(src, "\r\n", "\n", -1), "\n")
+	var b strings.Builder
+	var text []string
+
+	flush := func() {
+		if len(text) > 0 {
+			b.WriteString(`<span style="white-space: pre-line">` + html.EscapeString(strings.Join(text, "\n")) + "</span>")
+			text = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		if trimmed := strings.TrimSpace(lines[i]); strings.HasPrefix(trimmed, "```") {
+			flush()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			b.WriteString(fence(trimmed, code))
+			continue
+		}
+		text = append(text, lines[i])
+	}
+	flush()
+
+	return template.HTML(b.String())
+}
+
+// Render converts Markdown to HTML
+func Render(src string) template.HTML {
+	lines := strings.Split(strings.Replace(src, "\r\n", "\n", -1), "\n")
+	var b strings.Builder
+	var paragraph []string
+	list := "" // "ul" or "ol" while in a list
+
+	flush := func() {
+		if len(paragraph) > 0 {
+			b.WriteString("<p>" + inline(strings.Join(paragraph, " ")) + "</p>\n")
+			paragraph = nil
+		}
+	}
+	closeList := func() {
+		if list != "" {
+			b.WriteString("</" + list + ">\n")
+			list = ""
+		}
+	}
+	openList := func(kind string) {
+		if list != kind {
+			closeList()
+			b.WriteString("<" + kind + ">\n")
+			list = kind
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+
+		// Fenced code blocks are kept as they are, highlighted
+		if strings.HasPrefix(trimmed, "```") {
+			flush()
+			closeList()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			b.WriteString(fence(trimmed, code) + "\n")
+			continue
+		}
+
+		if trimmed == "" {
+			flush()
+			closeList()
+			continue
+		}
+
+		if m := headingRe.FindStringSubmatch(trimmed); m != nil {
+			flush()
+			closeList()
+			level := string('0' + rune(len(m[1])))
+			b.WriteString("<h" + level + ` id="` + Slug(m[2]) + `">` + inline(m[2]) + "</h" + level + ">\n")
+			continue
+		}
+
+		if ruleRe.MatchString(trimmed) {
+			flush()
+			closeList()
+			b.WriteString("<hr>\n")
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, ">") {
+			flush()
+			closeList()
+			var quote []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				quote = append(quote, strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(lines[i]), ">"), " "))
+			}
+			i--
+			b.WriteString("<blockquote>" + string(Render(strings.Join(quote, "\n"))) + "</blockquote>\n")
+			continue
+		}
+
+		if m := bulletRe.FindStringSubmatch(trimmed); m != nil && line == trimmed {
+			flush()
+			openList("ul")
+			b.WriteString("<li>" + inline(m[1]) + "</li>\n")
+			continue
+		}
+		if m := orderedRe.FindStringSubmatch(trimmed); m != nil && line == trimmed {
+			flush()
+			openList("ol")
+			b.WriteString("<li>" + inline(m[1]) + "</li>\n")
+			continue
+		}
+
+		// Indented lines continue the last list item
+		if list != "" && line != trimmed {
+			out := b.String()
+			if strings.HasSuffix(out, "</li>\n") {
+				b.Reset()
+				b.WriteString(strings.TrimSuffix(out, "</li>\n") + " " + inline(trimmed) + "</li>\n")
+				continue
+			}
+		}
+
+		closeList()
+		paragraph = append(paragraph, trimmed)
+	}
+	flush()
+	closeList()
+
+	return template.HTML(b.String())
+}
@@ -0,0 +1,51 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	src := "# Crackme Rules\n\nSome **bold**, *italic* and `<code>` text\non two lines.\n\n" +
+		"- one\n- two [link](https://crackmes.one/faq)\n  continued\n\n1. first\n2. second\n\n" +
+		"> quoted\n\n---\n\n```\n<b>raw</b>\n```\n"
+	want := `<h1 id="crackme-rules">Crackme Rules</h1>
+<p>Some <b>bold</b>, <i>italic</i> and <code>&lt;code&gt;</code> text on two lines.</p>
+<ul>
+<li>one</li>
+<li>two <a href="https://crackmes.one/faq">link</a> continued</li>
+</ul>
+<ol>
+<li>first</li>
+<li>second</li>
+</ol>
+<blockquote><p>quoted</p>
+</blockquote>
+<hr>
+<pre><code>&lt;b&gt;raw&lt;/b&gt;</code></pre>
+`
+	if got := string(Render(src)); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestText(t *testing.T) {
+	src := "Find the key.\n<b>Hint</b>:\n```c\nif (check(key)) return 1;\n```\n\nGood luck"
+	want := `<span style="white-space: pre-line">Find the key.
+&lt;b&gt;Hint&lt;/b&gt;:</span>` +
+		`<pre><code class="language-c"><span class="hl-k">if</span> (check(key)) <span class="hl-k">return</span> <span class="hl-n">1</span>;</code></pre>` +
+		`<span style="white-space: pre-line">
+Good luck</span>`
+	if got := string(Text(src)); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderUnsafe(t *testing.T) {
+	got := string(Render(`<script>alert(1)</script> [x](javascript:alert(1)) [y](" onclick="z)`))
+	for _, bad := range []string{"<script", "javascript:", `href="&#34;`, "onclick=\""} {
+		if strings.Contains(got, bad) {
+			t.Errorf("%q in %s", bad, got)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Package moderation watches the age of the submissions waiting for the
+// moderators, reminds them of the stale ones and measures the queue.
+package moderation
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/webhook"
+)
+
+// Kinds of submissions
+const (
+	KindCrackme  = "crackme"
+	KindVersion  = "version"
+	KindSolution = "solution"
+	KindComment  = "comment"
+	KindClaim    = "claim"
+)
+
+// Kinds lists the kinds of submissions in the order of the dashboard
+var Kinds = []string{KindCrackme, KindVersion, KindSolution, KindComment, KindClaim}
+
+var (
+	info  Info
+	mutex sync.RWMutex
+
+	// lastReminder is when the moderators were last reminded
+	lastReminder time.Time
+)
+
+// Info contains the moderation queue settings
+type Info struct {
+	Enabled     bool   // Check the queue on a schedule
+	Interval    int    // Minutes between two checks, 60 by default
+	MaxAge      int    // Hours a submission may wait before it is stale, 48 by default
+	RemindEvery int    // Hours between two reminders while submissions are stale, 24 by default
+	Discord     string // URL of the Discord webhook of the moderators, optional
+}
+
+// Configure stores the settings, with their defaults
+func Configure(c Info) {
+	if c.Interval <= 0 {
+		c.Interval = 60
+	}
+	if c.MaxAge <= 0 {
+		c.MaxAge = 48
+	}
+	if c.RemindEvery <= 0 {
+		c.RemindEvery = 24
+	}
+
+	mutex.Lock()
+	info = c
+	mutex.Unlock()
+}
+
+// ReadConfig returns the settings
+func ReadConfig() Info {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return info
+}
+
+// Item is a submission waiting for the moderators
+type Item struct {
+	Kind      string
+	HexId     string // Of the crackme for the versions and the claims
+	Title     string
+	Author    string
+	CreatedAt time.Time
+}
+
+// Age returns how long the item has waited at now
+func (i Item) Age(now time.Time) time.Duration {
+	return now.Sub(i.CreatedAt)
+}
+
+// Stats are the ages of the waiting submissions of a kind
+type Stats struct {
+	Kind  string
+	Count int
+	Stale int
+	P50   time.Duration
+	P90   time.Duration
+	Max   time.Duration
+}
+
+// Queue sums up the waiting submissions
+type Queue struct {
+	Stats []Stats // By kind, then for all the kinds
+	Stale []Item  // Oldest first
+}
+
+// Measure computes the percentiles of the ages of the items by kind and
+// lists the stale ones
+func Measure(items []Item, now time.Time, maxAge time.Duration) Queue {
+	var q Queue
+	byKind := map[string][]time.Duration{}
+	var all []time.Duration
+	stale := map[string]int{}
+
+	for _, i := range items {
+		age := i.Age(now)
+		byKind[i.Kind] = append(byKind[i.Kind], age)
+		all = append(all, age)
+		if age > maxAge {
+			q.Stale = append(q.Stale, i)
+			stale[i.Kind]++
+		}
+	}
+	sort.Slice(q.Stale, func(a, b int) bool { return q.Stale[a].CreatedAt.Before(q.Stale[b].CreatedAt) })
+
+	for _, kind := range Kinds {
+		q.Stats = append(q.Stats, stats(kind, byKind[kind], stale[kind]))
+	}
+	q.Stats = append(q.Stats, stats("all", all, len(q.Stale)))
+	return q
+}
+
+// stats computes the nearest-rank percentiles of the ages
+func stats(kind string, ages []time.Duration, stale int) Stats {
+	s := Stats{Kind: kind, Count: len(ages), Stale: stale}
+	if len(ages) == 0 {
+		return s
+	}
+	sort.Slice(ages, func(a, b int) bool { return ages[a] < ages[b] })
+	rank := func(p int) time.Duration {
+		return ages[(p*len(ages)+99)/100-1]
+	}
+	s.P50, s.P90, s.Max = rank(50), rank(90), ages[len(ages)-1]
+	return s
+}
+
+// Reminder returns the text reminding the moderators of the stale items
+func Reminder(stale []Item, now time.Time) string {
+	var b strings.Builder
+	if len(stale) == 1 {
+		b.WriteString("1 submission is waiting for moderation for too long:")
+	} else {
+		fmt.Fprintf(&b, "%d submissions are waiting for moderation for too long:", len(stale))
+	}
+	for n, i := range stale {
+		if n == 10 {
+			fmt.Fprintf(&b, "\n- and %d more", len(stale)-n)
+			break
+		}
+		fmt.Fprintf(&b, "\n- %s '%s' by %s, %s", i.Kind, i.Title, i.Author, Hours(i.Age(now)))
+	}
+	return b.String()
+}
+
+// Hours writes a duration in hours, or days past two days
+func Hours(d time.Duration) string {
+	switch h := int(d.Hours()); {
+	case h >= 48:
+		return fmt.Sprintf("%d days", h/24)
+	case h == 1:
+		return "1 hour"
+	default:
+		return fmt.Sprintf("%d hours", h)
+	}
+}
+
+// Due tells if the moderators should be reminded at now, and records the
+// reminder if so
+func Due(now time.Time) bool {
+	c := ReadConfig()
+	mutex.Lock()
+	defer mutex.Unlock()
+	if now.Sub(lastReminder) < time.Duration(c.RemindEvery)*time.Hour {
+		return false
+	}
+	lastReminder = now
+	return true
+}
+
+// PostDiscord posts the text to the Discord webhook of the moderators, if any
+func PostDiscord(text string) error {
+	url := ReadConfig().Discord
+	if url == "" {
+		return nil
+	}
+	body, err := json.Marshal(map[string]string{"content": text})
+	if err != nil {
+		return err
+	}
+	return webhook.Post(url, "application/json", body, nil)
+}
+
+// Schedule checks the queue every Interval minutes
+func Schedule(c Info, run func() error) {
+	if c.Interval <= 0 {
+		c.Interval = 60
+	}
+	for {
+		time.Sleep(time.Duration(c.Interval) * time.Minute)
+		if err := run(); err != nil {
+			log.Println("Moderation queue check failed:", err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMeasure(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	var items []Item
+	for h := 1; h <= 10; h++ {
+		items = append(items, Item{Kind: KindSolution, Title: "s", CreatedAt: now.Add(-time.Duration(h) * time.Hour)})
+	}
+	items = append(items, Item{Kind: KindCrackme, Title: "old", CreatedAt: now.Add(-72 * time.Hour)})
+
+	q := Measure(items, now, 48*time.Hour)
+	if len(q.Stats) != len(Kinds)+1 {
+		t.Fatalf("got %d stats", len(q.Stats))
+	}
+
+	solutions := q.Stats[2]
+	if solutions.Kind != KindSolution || solutions.Count != 10 || solutions.P50 != 5*time.Hour || solutions.P90 != 9*time.Hour || solutions.Max != 10*time.Hour {
+		t.Errorf("solutions: got %+v", solutions)
+	}
+	if versions := q.Stats[1]; versions.Count != 0 || versions.Max != 0 {
+		t.Errorf("versions: got %+v", versions)
+	}
+	all := q.Stats[len(q.Stats)-1]
+	if all.Count != 11 || all.Stale != 1 || all.Max != 72*time.Hour {
+		t.Errorf("all: got %+v", all)
+	}
+	if len(q.Stale) != 1 || q.Stale[0].Title != "old" {
+		t.Errorf("stale: got %+v", q.Stale)
+	}
+
+	want := "1 submission is waiting for moderation for too long:\n- crackme 'old' by , 3 days"
+	if got := Reminder(q.Stale, now); got != want {
+		t.Errorf("reminder: got %q, want %q", got, want)
+	}
+}
+
+func TestDue(t *testing.T) {
+	Configure(Info{RemindEvery: 24})
+	now := time.Now()
+	if !Due(now) || Due(now.Add(time.Hour)) || !Due(now.Add(25*time.Hour)) {
+		t.Error("the reminders are not spaced by RemindEvery hours")
+	}
+}
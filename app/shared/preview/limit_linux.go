@@ -0,0 +1,21 @@
+//go:build linux
+// +build linux
+
+package preview
+
+import (
+	"log"
+	"syscall"
+	"time"
+)
+
+// limitResources bounds the address space and the CPU time of the process
+// generating a preview
+func limitResources() {
+	cpu := uint64(timeout / time.Second)
+	for resource, limit := range map[int]uint64{syscall.RLIMIT_AS: maxMemory, syscall.RLIMIT_CPU: cpu} {
+		if err := syscall.Setrlimit(resource, &syscall.Rlimit{Cur: limit, Max: limit}); err != nil {
+			log.Println("Preview limit not set:", err)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package preview
+
+// limitResources leaves the process generating a preview to the timeout of
+// GenerateFile, the limits are set on Linux only
+func limitResources() {}
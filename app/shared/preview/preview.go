@@ -0,0 +1,258 @@
+// Package preview summarizes uploads for the moderators: the files of the
+// archive, a sample of the printable strings and the entropy along the data.
+// Uploads are only parsed, never run, by a background worker, in a process
+// of their own with bounded memory and time.
+package preview
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/archive"
+	"github.com/crackmesone/crackmes.one/app/shared/worker"
+)
+
+const (
+	// MinStringLength is the length from which a run of printable characters
+	// is a string
+	MinStringLength = 6
+
+	// MaxStrings is the size of the strings sample
+	MaxStrings = 200
+
+	// EntropyBlocks is the number of points of the entropy chart
+	EntropyBlocks = 128
+
+	// maxFileSize caps the size of an archived file read for a preview
+	maxFileSize = 20 << 20
+
+	// maxUploadSize caps the size of an upload read for a preview
+	maxUploadSize = 16 << 20
+
+	// maxMemory caps the address space of the process generating a preview
+	maxMemory = 1 << 30
+)
+
+var (
+	// queue holds up to 50 uploads waiting for a preview, before new ones are
+	// skipped
+	queue = worker.New("Preview", 50)
+
+	// timeout bounds the time a preview is generated in, its process is
+	// killed past it
+	timeout = 30 * time.Second
+
+	// errTooLarge is returned for the uploads over maxUploadSize
+	errTooLarge = errors.New("upload too large for a preview")
+)
+
+// Preview summarizes an upload
+type Preview struct {
+	SHA256    string          `bson:"sha256" json:"sha256"`
+	Size      int64           `bson:"size" json:"size"`
+	Files     []archive.Entry `bson:"files,omitempty" json:"files,omitempty"`
+	File      string          `bson:"file,omitempty" json:"file,omitempty"`
+	Strings   []string        `bson:"strings" json:"strings"`
+	Entropy   []float64       `bson:"entropy" json:"entropy"`
+	CreatedAt time.Time       `bson:"created_at" json:"created_at"`
+}
+
+// Generate returns the preview of an upload. When it is a zip archive, the
+// strings and entropy are those of its largest readable file.
+func Generate(data []byte) *Preview {
+	sum := sha256.Sum256(data)
+	p := &Preview{
+		SHA256:    hex.EncodeToString(sum[:]),
+		Size:      int64(len(data)),
+		CreatedAt: time.Now(),
+	}
+
+	content := data
+	if files, err := archive.List(data); err == nil {
+		p.Files = files
+		if name, largest := largestFile(data); largest != nil {
+			p.File = name
+			content = largest
+		}
+	}
+
+	p.Strings = Strings(content, MinStringLength, MaxStrings)
+	p.Entropy = Entropy(content, EntropyBlocks)
+	return p
+}
+
+// largestFile returns the largest file of a zip archive that can be read,
+// i.e. isn't encrypted
+func largestFile(data []byte) (string, []byte) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", nil
+	}
+
+	var name string
+	var largest []byte
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || f.UncompressedSize64 > maxFileSize || f.Flags&0x1 != 0 {
+			continue
+		}
+		if int(f.UncompressedSize64) <= len(largest) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			continue
+		}
+		content, err := io.ReadAll(io.LimitReader(rc, maxFileSize))
+		rc.Close()
+		if err == nil && len(content) > len(largest) {
+			name, largest = f.Name, content
+		}
+	}
+	return name, largest
+}
+
+// Strings returns the first max runs of at least min printable ASCII
+// characters, like strings(1)
+func Strings(data []byte, min, max int) []string {
+	var found []string
+	start := -1
+	for i := 0; i <= len(data) && len(found) < max; i++ {
+		if i < len(data) && (data[i] >= 0x20 && data[i] < 0x7f || data[i] == '\t') {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && i-start >= min {
+			found = append(found, string(data[start:i]))
+		}
+		start = -1
+	}
+	return found
+}
+
+// Entropy returns the Shannon entropy, in bits per byte, of the data split in
+// at most n blocks. Packed or encrypted parts are close to 8.
+func Entropy(data []byte, n int) []float64 {
+	if len(data) == 0 {
+		return nil
+	}
+
+	size := (len(data) + n - 1) / n
+	var result []float64
+	for start := 0; start < len(data); start += size {
+		end := start + size
+		if end > len(data) {
+			end = len(data)
+		}
+
+		var counts [256]int
+		for _, b := range data[start:end] {
+			counts[b]++
+		}
+
+		e := 0.0
+		total := float64(end - start)
+		for _, c := range counts {
+			if c > 0 {
+				p := float64(c) / total
+				e -= p * math.Log2(p)
+			}
+		}
+		result = append(result, math.Round(e*1000)/1000)
+	}
+	return result
+}
+
+// Main writes the preview of the upload stored at path to the standard
+// output, as JSON. It is the process started by GenerateFile, the server run
+// with the -preview flag, and bounds its own memory and CPU time first.
+func Main(path string) error {
+	limitResources()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxUploadSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxUploadSize {
+		return errTooLarge
+	}
+
+	return json.NewEncoder(os.Stdout).Encode(Generate(data))
+}
+
+// command returns the process generating the preview of the upload stored
+// at path: the server itself with the -preview flag
+var command = func(ctx context.Context, path string) (*exec.Cmd, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return exec.CommandContext(ctx, exe, "-preview", path), nil
+}
+
+// GenerateFile returns the preview of the upload stored at path, generated
+// by Main in a process of its own: a malformed upload can neither crash nor
+// stall the server, the process is killed past the timeout
+func GenerateFile(path string) (*Preview, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd, err := command(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("preview of %s: timed out after %v", path, timeout)
+	} else if err != nil {
+		return nil, fmt.Errorf("preview of %s: %v %s", path, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	var p Preview
+	if err = json.Unmarshal(out, &p); err != nil {
+		return nil, fmt.Errorf("preview of %s: %v", path, err)
+	}
+	return &p, nil
+}
+
+// Start runs the preview worker
+func Start() {
+	queue.Start()
+}
+
+// Queue asks the worker for the preview of the upload stored at path, done
+// is called with it. It never blocks: if the queue is full the preview is
+// skipped and logged.
+func Queue(path string, done func(*Preview)) {
+	err := queue.Push(func() {
+		p, err := GenerateFile(path)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		done(p)
+	})
+	if err == worker.ErrFull {
+		log.Println("Preview queue full, skipping an upload")
+	}
+}
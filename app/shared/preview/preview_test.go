@@ -0,0 +1,109 @@
+package preview
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain is also the process generating the previews, in place of the
+// server with its -preview flag
+func TestMain(m *testing.M) {
+	switch path := os.Getenv("PREVIEW_FILE"); {
+	case path == "hang":
+		time.Sleep(time.Hour)
+	case path != "":
+		if err := Main(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	command = func(ctx context.Context, path string) (*exec.Cmd, error) {
+		cmd := exec.CommandContext(ctx, os.Args[0])
+		cmd.Env = append(os.Environ(), "PREVIEW_FILE="+path)
+		return cmd, nil
+	}
+	os.Exit(m.Run())
+}
+
+func TestStrings(t *testing.T) {
+	data := []byte("\x00\x01Enter the key:\x00ab\x00\xffWrong key!\nok\x00tail end")
+	want := []string{"Enter the key:", "Wrong key!", "tail end"}
+	if got := Strings(data, 6, 10); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := Strings(data, 6, 1); len(got) != 1 {
+		t.Errorf("got %d strings, want 1", len(got))
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	uniform := make([]byte, 512)
+	for i := range uniform {
+		uniform[i] = byte(i)
+	}
+	data := append(make([]byte, 512), uniform...)
+
+	got := Entropy(data, 2)
+	if want := []float64{0, 8}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, _ := zw.Create("readme.txt")
+	f.Write([]byte("short"))
+	f, _ = zw.Create("crackme.bin")
+	f.Write([]byte("\x00\x00Password please\x00\x00"))
+	zw.Close()
+
+	p := Generate(buf.Bytes())
+	if len(p.Files) != 2 || p.File != "crackme.bin" {
+		t.Errorf("got files %v, file %q", p.Files, p.File)
+	}
+	if !reflect.DeepEqual(p.Strings, []string{"Password please"}) {
+		t.Errorf("got strings %q", p.Strings)
+	}
+}
+
+func TestGenerateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crackme.bin")
+	if err := os.WriteFile(path, []byte("\x00\x00Password please\x00\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := GenerateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Size != 19 || !reflect.DeepEqual(p.Strings, []string{"Password please"}) {
+		t.Errorf("got size %d, strings %q", p.Size, p.Strings)
+	}
+
+	if _, err := GenerateFile(path + ".missing"); err == nil {
+		t.Error("preview of a missing file")
+	}
+}
+
+func TestGenerateFileTimeout(t *testing.T) {
+	old := timeout
+	timeout = 100 * time.Millisecond
+	defer func() { timeout = old }()
+
+	_, err := GenerateFile("hang")
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("got %v, want a timeout", err)
+	}
+}
@@ -0,0 +1,34 @@
+// Package processing runs the processing of the uploads (metadata
+// extraction, readme...) in a background worker, so the upload requests
+// return as soon as the files are stored.
+package processing
+
+import (
+	"log"
+
+	"github.com/crackmesone/crackmes.one/app/shared/worker"
+)
+
+// queue holds up to 50 uploads waiting for their processing, before new ones
+// are processed by the request itself
+var queue = worker.New("Processing", 50)
+
+// Start runs the processing worker
+func Start() {
+	queue.Start()
+}
+
+// Queue asks the worker to run an upload processing, done is called with its
+// error, a panic included. When the worker isn't started or its queue is
+// full, the processing runs right away instead of being lost.
+func Queue(run func() error, done func(error)) {
+	job := func() {
+		done(worker.Do("Processing", run))
+	}
+	if err := queue.Push(job); err != nil {
+		if err == worker.ErrFull {
+			log.Println("Processing queue full, processing an upload in its request")
+		}
+		job()
+	}
+}
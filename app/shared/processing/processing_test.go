@@ -0,0 +1,21 @@
+package processing
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQueueWithoutWorker(t *testing.T) {
+	var got error
+	want := errors.New("failed")
+	Queue(func() error { return want }, func(err error) { got = err })
+	if got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	got = nil
+	Queue(func() error { panic("malformed") }, func(err error) { got = err })
+	if got == nil {
+		t.Errorf("panic not reported")
+	}
+}
@@ -0,0 +1,513 @@
+// Package qrcode encodes short texts, such as URLs, into QR codes.
+//
+// Only what the site needs is implemented: byte mode, error correction
+// level M and versions 1 to 10, which fits up to 213 bytes.
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// ErrTooLong is returned when the content does not fit in a version 10 code
+var ErrTooLong = errors.New("Content too long for a QR code.")
+
+// blockInfo is the error correction layout of a version at level M
+type blockInfo struct {
+	ecPerBlock int
+	groups     [2][2]int // {number of blocks, data codewords per block}
+}
+
+var versions = []blockInfo{
+	{10, [2][2]int{{1, 16}, {0, 0}}},
+	{16, [2][2]int{{1, 28}, {0, 0}}},
+	{26, [2][2]int{{1, 44}, {0, 0}}},
+	{18, [2][2]int{{2, 32}, {0, 0}}},
+	{24, [2][2]int{{2, 43}, {0, 0}}},
+	{16, [2][2]int{{4, 27}, {0, 0}}},
+	{18, [2][2]int{{4, 31}, {0, 0}}},
+	{22, [2][2]int{{2, 38}, {2, 39}}},
+	{22, [2][2]int{{3, 36}, {2, 37}}},
+	{26, [2][2]int{{4, 43}, {1, 44}}},
+}
+
+var alignments = [][]int{
+	{},
+	{6, 18},
+	{6, 22},
+	{6, 26},
+	{6, 30},
+	{6, 34},
+	{6, 22, 38},
+	{6, 24, 42},
+	{6, 26, 46},
+	{6, 28, 50},
+}
+
+func (b blockInfo) dataCodewords() int {
+	return b.groups[0][0]*b.groups[0][1] + b.groups[1][0]*b.groups[1][1]
+}
+
+// Code is an encoded QR code, Modules[y][x] is true for dark modules
+type Code struct {
+	Size    int
+	Modules [][]bool
+	version int
+	fn      [][]bool // function patterns, excluded from data and masking
+}
+
+// Encode returns the smallest QR code holding content
+func Encode(content string) (*Code, error) {
+	return encode(content, -1)
+}
+
+// encode returns the smallest QR code holding content with the given mask, or
+// the mask of lowest penalty when negative
+func encode(content string, mask int) (*Code, error) {
+	data := []byte(content)
+
+	version := 0
+	for v := 1; v <= len(versions); v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= 8*versions[v-1].dataCodewords() {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	c := &Code{Size: 17 + 4*version, version: version}
+	c.Modules = make([][]bool, c.Size)
+	c.fn = make([][]bool, c.Size)
+	for i := range c.Modules {
+		c.Modules[i] = make([]bool, c.Size)
+		c.fn[i] = make([]bool, c.Size)
+	}
+
+	c.drawFunctionPatterns()
+	c.drawCodewords(c.codewords(data))
+
+	// Keep the mask with the lowest penalty
+	if mask < 0 {
+		bestPenalty := -1
+		for m := 0; m < 8; m++ {
+			c.applyMask(m)
+			c.drawFormatBits(m)
+			if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+				mask, bestPenalty = m, p
+			}
+			c.applyMask(m)
+		}
+	}
+	c.applyMask(mask)
+	c.drawFormatBits(mask)
+
+	return c, nil
+}
+
+// PNG returns content encoded as a QR code PNG image, each module being
+// scale pixels wide, surrounded by the standard 4 modules quiet zone
+func PNG(content string, scale int) ([]byte, error) {
+	c, err := Encode(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = png.Encode(&buf, c.Image(scale))
+	return buf.Bytes(), err
+}
+
+// Image renders the code, each module being scale pixels wide, surrounded by
+// the standard 4 modules quiet zone
+func (c *Code) Image(scale int) image.Image {
+	if scale < 1 {
+		scale = 1
+	}
+	width := (c.Size + 8) * scale
+	img := image.NewGray(image.Rect(0, 0, width, width))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.Modules[y][x] {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetGray((x+4)*scale+dx, (y+4)*scale+dy, color.Gray{0})
+				}
+			}
+		}
+	}
+	return img
+}
+
+func (c *Code) set(x, y int, dark bool) {
+	c.Modules[y][x] = dark
+	c.fn[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+	// Timing patterns
+	for i := 0; i < c.Size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns with their separators
+	for _, p := range [][2]int{{3, 3}, {c.Size - 4, 3}, {3, c.Size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := p[0]+dx, p[1]+dy
+				if x >= 0 && x < c.Size && y >= 0 && y < c.Size {
+					d := max(abs(dx), abs(dy))
+					c.set(x, y, d != 2 && d != 4)
+				}
+			}
+		}
+	}
+
+	// Alignment patterns, except where they overlap the finders
+	pos := alignments[c.version-1]
+	last := len(pos) - 1
+	for i := range pos {
+		for j := range pos {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.set(pos[i]+dx, pos[j]+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format areas, drawn for real once the mask is chosen
+	c.drawFormatBits(0)
+
+	// Version information
+	if c.version >= 7 {
+		bits := versionBits(c.version)
+		for i := 0; i < 18; i++ {
+			dark := bits>>uint(i)&1 != 0
+			a, b := c.Size-11+i%3, i/3
+			c.set(a, b, dark)
+			c.set(b, a, dark)
+		}
+	}
+}
+
+// formatBits returns the 15 bits format information for level M and mask
+func formatBits(mask int) int {
+	data := 0<<3 | mask // level M is 00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// versionBits returns the 18 bits version information
+func versionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return version<<12 | rem
+}
+
+func (c *Code) drawFormatBits(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return bits>>uint(i)&1 != 0 }
+
+	// Around the top left finder
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+
+	// Split between the two other finders
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true)
+}
+
+// codewords returns the interleaved data and error correction codewords
+func (c *Code) codewords(data []byte) []byte {
+	info := versions[c.version-1]
+	capacity := info.dataCodewords() * 8
+
+	var bits []bool
+	appendBits := func(v, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, v>>uint(i)&1 != 0)
+		}
+	}
+
+	countBits := 8
+	if c.version >= 10 {
+		countBits = 16
+	}
+	appendBits(0x4, 4)
+	appendBits(len(data), countBits)
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+	appendBits(0, min(4, capacity-len(bits)))
+	appendBits(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		appendBits(pad, 8)
+	}
+
+	stream := make([]byte, len(bits)/8)
+	for i, b := range bits {
+		if b {
+			stream[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+
+	// Split in blocks and compute their error correction
+	divisor := rsDivisor(info.ecPerBlock)
+	var blocks, ecs [][]byte
+	for _, g := range info.groups {
+		for i := 0; i < g[0]; i++ {
+			block := stream[:g[1]]
+			stream = stream[g[1]:]
+			blocks = append(blocks, block)
+			ecs = append(ecs, rsRemainder(block, divisor))
+		}
+	}
+
+	var result []byte
+	for i := 0; i < info.groups[0][1] || i < info.groups[1][1]; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < info.ecPerBlock; i++ {
+		for _, ec := range ecs {
+			result = append(result, ec[i])
+		}
+	}
+	return result
+}
+
+// drawCodewords places the data in the zigzag order of the specification
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !c.fn[y][x] && i < len(data)*8 {
+					c.Modules[y][x] = data[i>>3]>>uint(7-i&7)&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by the mask, applying it twice
+// restores the original modules
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !c.fn[y][x] {
+				c.Modules[y][x] = !c.Modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code is to read, following the four rules of
+// the specification
+func (c *Code) penalty() int {
+	result := 0
+	at := func(x, y int, transpose bool) bool {
+		if transpose {
+			return c.Modules[x][y]
+		}
+		return c.Modules[y][x]
+	}
+
+	for _, transpose := range []bool{false, true} {
+		for y := 0; y < c.Size; y++ {
+			// Rule 1: runs of five or more modules of the same color
+			run := 1
+			for x := 1; x < c.Size; x++ {
+				if at(x, y, transpose) == at(x-1, y, transpose) {
+					run++
+					if run == 5 {
+						result += 3
+					} else if run > 5 {
+						result++
+					}
+				} else {
+					run = 1
+				}
+			}
+
+			// Rule 3: finder like 1:1:3:1:1 patterns next to 4 light modules
+			for x := 0; x+7 <= c.Size; x++ {
+				pattern := true
+				for i, dark := range []bool{true, false, true, true, true, false, true} {
+					if at(x+i, y, transpose) != dark {
+						pattern = false
+						break
+					}
+				}
+				if pattern && (c.light(x-4, x, y, transpose) || c.light(x+7, x+11, y, transpose)) {
+					result += 40
+				}
+			}
+		}
+	}
+
+	// Rule 2: 2x2 blocks of the same color
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Modules[y][x] {
+				dark++
+			}
+			if x > 0 && y > 0 {
+				v := c.Modules[y][x]
+				if v == c.Modules[y-1][x] && v == c.Modules[y][x-1] && v == c.Modules[y-1][x-1] {
+					result += 3
+				}
+			}
+		}
+	}
+
+	// Rule 4: balance of dark and light modules
+	total := c.Size * c.Size
+	result += abs(dark*20-total*10) / total * 10
+
+	return result
+}
+
+// light returns true if the modules from..to (excluded) of a line are light,
+// the modules outside of the code count as light
+func (c *Code) light(from, to, line int, transpose bool) bool {
+	for i := from; i < to; i++ {
+		if i < 0 || i >= c.Size {
+			continue
+		}
+		if (transpose && c.Modules[i][line]) || (!transpose && c.Modules[line][i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// rsDivisor returns the Reed-Solomon generator polynomial of the given
+// degree, highest coefficient first and the leading 1 omitted
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder returns the error correction codewords of data
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= gfMultiply(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int(y>>uint(i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
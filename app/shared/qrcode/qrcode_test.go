@@ -0,0 +1,108 @@
+package qrcode
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRSRemainder(t *testing.T) {
+	// Version 1-M "HELLO WORLD" example from the specification annex
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+		t.Errorf("rsRemainder() = %v, want %v", got, want)
+	}
+}
+
+func TestFormatBits(t *testing.T) {
+	if got := formatBits(5); got != 0x40CE {
+		t.Errorf("formatBits(5) = %015b, want 100000011001110", got)
+	}
+}
+
+func TestVersionBits(t *testing.T) {
+	if got := versionBits(7); got != 0x07C94 {
+		t.Errorf("versionBits(7) = %018b, want 000111110010010100", got)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		content string
+		size    int
+	}{
+		{"https://crackmes.one/c/1", 25},
+		{"https://crackmes.one/crackme/5ab77f5233c5d40ad448c3ba", 33},
+	}
+
+	for _, tt := range tests {
+		c, err := Encode(tt.content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Size != tt.size {
+			t.Errorf("Encode(%q).Size = %d, want %d", tt.content, c.Size, tt.size)
+		}
+		// Dark module next to the bottom left finder
+		if !c.Modules[c.Size-8][8] {
+			t.Error("Dark module missing")
+		}
+	}
+}
+
+// TestEncodeConformance compares the codes with the matrices of a known-good
+// encoder, github.com/skip2/go-qrcode, at its pick of mask, in testdata: one
+// line per row, # for the dark modules
+func TestEncodeConformance(t *testing.T) {
+	tests := []struct {
+		file    string
+		content string
+		mask    int
+	}{
+		{"v2.txt", "https://crackmes.one/c/1", 6},
+		{"v4.txt", "https://crackmes.one/crackme/5ab77f5233c5d40ad448c3ba", 3},
+		// Version information, two groups of blocks
+		{"v8.txt", "https://crackmes.one/crackme/5ab77f5233c5d40ad448c3ba/some/long/path/to/reach/version/seven/or/more/" + strings.Repeat("a", 51), 2},
+		// 16 bits character count
+		{"v10.txt", "https://crackmes.one/search?q=" + strings.Repeat("keygenme", 21), 1},
+	}
+
+	for _, tt := range tests {
+		b, err := ioutil.ReadFile(filepath.Join("testdata", tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := strings.Fields(string(b))
+
+		c, err := encode(tt.content, tt.mask)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Size != len(want) {
+			t.Errorf("%s: size %d, want %d", tt.file, c.Size, len(want))
+			continue
+		}
+		for y, row := range c.Modules {
+			got := make([]byte, len(row))
+			for x, dark := range row {
+				got[x] = '.'
+				if dark {
+					got[x] = '#'
+				}
+			}
+			if string(got) != want[y] {
+				t.Errorf("%s: row %d = %s, want %s", tt.file, y, got, want[y])
+			}
+		}
+	}
+}
+
+func TestEncodeTooLong(t *testing.T) {
+	if _, err := Encode(string(make([]byte, 300))); err != ErrTooLong {
+		t.Errorf("Encode() error = %v, want ErrTooLong", err)
+	}
+}
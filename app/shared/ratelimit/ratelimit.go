@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows a number of events per key (e.g. an IP address) in a fixed
+// window of time. It is safe for concurrent use.
+type Limiter struct {
+	limit   int
+	window  time.Duration
+	mutex   sync.Mutex
+	windows map[string]*window
+}
+
+type window struct {
+	start time.Time
+	count int
+}
+
+// New returns a limiter allowing limit events per key every period
+func New(limit int, period time.Duration) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		window:  period,
+		windows: make(map[string]*window),
+	}
+}
+
+// Allow records an event for key and reports whether it is within the limit.
+// When it isn't, it also returns how long to wait before the next window.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		l.prune(now)
+		w = &window{start: now}
+		l.windows[key] = w
+	}
+
+	if w.count >= l.limit {
+		return false, w.start.Add(l.window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// Limit returns the number of events allowed per window and the length of a
+// window
+func (l *Limiter) Limit() (int, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.limit, l.window
+}
+
+// SetLimit changes the number of events allowed per window, the events
+// already counted stay
+func (l *Limiter) SetLimit(limit int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.limit = limit
+}
+
+// Usage returns the number of events of key counted in the current window and
+// how long until it ends, without counting one
+func (l *Limiter) Usage(key string) (int, time.Duration) {
+	now := time.Now()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		return 0, 0
+	}
+	return w.count, w.start.Add(l.window).Sub(now)
+}
+
+// prune removes the finished windows so idle keys don't pile up
+func (l *Limiter) prune(now time.Time) {
+	for key, w := range l.windows {
+		if now.Sub(w.start) >= l.window {
+			delete(l.windows, key)
+		}
+	}
+}
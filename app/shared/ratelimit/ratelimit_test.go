@@ -0,0 +1,35 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	l := New(2, 50*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("event %d refused", i)
+		}
+	}
+	ok, retry := l.Allow("a")
+	if ok || retry <= 0 || retry > 50*time.Millisecond {
+		t.Errorf("third event: got %v, %v", ok, retry)
+	}
+
+	if ok, _ := l.Allow("b"); !ok {
+		t.Error("other key refused")
+	}
+	if used, reset := l.Usage("a"); used != 2 || reset <= 0 {
+		t.Errorf("usage: got %d, %v", used, reset)
+	}
+	if used, _ := l.Usage("c"); used != 0 {
+		t.Errorf("usage of an unknown key: got %d", used)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Error("event refused in the next window")
+	}
+}
@@ -0,0 +1,285 @@
+// Package rating protects the difficulty and quality ratings of the crackmes
+// from gaming: it decides which accounts may vote and finds the suspicious
+// voting patterns for the admins to review.
+package rating
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	info  Info
+	mutex sync.RWMutex
+
+	// ErrTooYoung is returned for accounts too recent to vote
+	ErrTooYoung = errors.New("account too recent to rate")
+	// ErrInactive is returned for accounts without enough activity to vote
+	ErrInactive = errors.New("account without enough activity to rate")
+)
+
+// Info contains the rating rules
+type Info struct {
+	MinAccountDays int // Days since an account was created before it can vote, 7 by default, negative to disable
+	MinActivity    int // Visible crackmes, solutions and comments an account needs to vote, 1 by default, negative to disable
+}
+
+// Configure stores the rules
+func Configure(c Info) {
+	if c.MinAccountDays == 0 {
+		c.MinAccountDays = 7
+	}
+	if c.MinActivity == 0 {
+		c.MinActivity = 1
+	}
+
+	mutex.Lock()
+	info = c
+	mutex.Unlock()
+}
+
+// ReadConfig returns the rules
+func ReadConfig() Info {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return info
+}
+
+// Allowed returns nil if an account created at created, with activity visible
+// crackmes, solutions and comments, may vote at now
+func Allowed(created time.Time, activity int, now time.Time) error {
+	c := ReadConfig()
+	if c.MinAccountDays > 0 && now.Sub(created) < time.Duration(c.MinAccountDays)*24*time.Hour {
+		return ErrTooYoung
+	}
+	if c.MinActivity > 0 && activity < c.MinActivity {
+		return ErrInactive
+	}
+	return nil
+}
+
+// *****************************************************************************
+// Anomalies
+// *****************************************************************************
+
+// Vote is a rating of a crackme
+type Vote struct {
+	Kind    string // KindDifficulty or KindQuality
+	User    string
+	Crackme string // Hexid of the crackme
+	Author  string // Author of the crackme
+	Rating  int
+	Time    time.Time
+}
+
+// Kinds of votes
+const (
+	KindDifficulty = "difficulty"
+	KindQuality    = "quality"
+)
+
+// Kinds of anomalies
+const (
+	// AnomalyOutlier is a user whose votes are far from the ones of the
+	// others much more than the other users' are
+	AnomalyOutlier = "outlier"
+	// AnomalyBurst is a crackme receiving most of its votes at once
+	AnomalyBurst = "burst"
+	// AnomalyAuthor is a user voting mostly for the crackmes of one author
+	AnomalyAuthor = "author"
+)
+
+const (
+	// minVotes is the number of votes of a user before their pattern is judged
+	minVotes = 5
+	// minOthers is the number of votes of the others on a crackme for a vote
+	// to be compared with them
+	minOthers = 3
+	// outlierZ is the z-score of the deviation of an outlier
+	outlierZ = 2.5
+	// burstWindow and burstVotes make a burst: as many votes on a crackme
+	// within the window, at least half of its votes of the kind
+	burstWindow = time.Hour
+	burstVotes  = 5
+	// authorShare is the share of the votes of a user on one author
+	authorShare = 0.8
+)
+
+// Anomaly is a suspicious voting pattern
+type Anomaly struct {
+	Type    string // AnomalyOutlier, AnomalyBurst or AnomalyAuthor
+	Kind    string // Kind of the votes
+	User    string // User voting, empty for bursts
+	Crackme string // Crackme of a burst
+	Author  string // Author voted for by an AnomalyAuthor
+	Votes   int    // Number of votes involved
+	Score   float64
+	Detail  string
+}
+
+// Anomalies returns the suspicious patterns of the votes, the most suspicious
+// first in each type
+func Anomalies(votes []Vote) []Anomaly {
+	var result []Anomaly
+	for _, kind := range []string{KindDifficulty, KindQuality} {
+		var ofKind []Vote
+		for _, v := range votes {
+			if v.Kind == kind {
+				ofKind = append(ofKind, v)
+			}
+		}
+		result = append(result, outliers(kind, ofKind)...)
+		result = append(result, bursts(kind, ofKind)...)
+		result = append(result, authors(kind, ofKind)...)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Type != result[j].Type {
+			return result[i].Type < result[j].Type
+		}
+		return result[i].Score > result[j].Score
+	})
+	return result
+}
+
+// outliers finds the users whose votes deviate from the ones of the others
+// on the same crackmes much more than the users' average deviation
+func outliers(kind string, votes []Vote) []Anomaly {
+	sums := make(map[string]int)
+	counts := make(map[string]int)
+	for _, v := range votes {
+		sums[v.Crackme] += v.Rating
+		counts[v.Crackme]++
+	}
+
+	// Mean absolute deviation of each user from the average of the others
+	deviation := make(map[string]float64)
+	compared := make(map[string]int)
+	for _, v := range votes {
+		others := counts[v.Crackme] - 1
+		if others < minOthers {
+			continue
+		}
+		avg := float64(sums[v.Crackme]-v.Rating) / float64(others)
+		deviation[v.User] += math.Abs(float64(v.Rating) - avg)
+		compared[v.User]++
+	}
+
+	var users []string
+	var mean float64
+	for user, n := range compared {
+		if n >= minVotes {
+			deviation[user] /= float64(n)
+			users = append(users, user)
+			mean += deviation[user]
+		}
+	}
+	if len(users) < 2 {
+		return nil
+	}
+	mean /= float64(len(users))
+
+	var variance float64
+	for _, user := range users {
+		variance += (deviation[user] - mean) * (deviation[user] - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(users)))
+	if stddev == 0 {
+		return nil
+	}
+
+	var result []Anomaly
+	for _, user := range users {
+		if z := (deviation[user] - mean) / stddev; z >= outlierZ {
+			result = append(result, Anomaly{
+				Type:   AnomalyOutlier,
+				Kind:   kind,
+				User:   user,
+				Votes:  compared[user],
+				Score:  z,
+				Detail: fmt.Sprintf("votes %.2f away from the others on average, against %.2f for all the users", deviation[user], mean),
+			})
+		}
+	}
+	return result
+}
+
+// bursts finds the crackmes receiving at least half of their votes within a
+// short window
+func bursts(kind string, votes []Vote) []Anomaly {
+	byCrackme := make(map[string][]Vote)
+	for _, v := range votes {
+		byCrackme[v.Crackme] = append(byCrackme[v.Crackme], v)
+	}
+
+	var result []Anomaly
+	for crackme, list := range byCrackme {
+		if len(list) < burstVotes {
+			continue
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Time.Before(list[j].Time) })
+
+		// Largest number of votes within the window
+		best, start := 0, 0
+		first := 0
+		for end := range list {
+			for list[end].Time.Sub(list[start].Time) > burstWindow {
+				start++
+			}
+			if n := end - start + 1; n > best {
+				best, first = n, start
+			}
+		}
+
+		if best >= burstVotes && best*2 >= len(list) {
+			result = append(result, Anomaly{
+				Type:    AnomalyBurst,
+				Kind:    kind,
+				Crackme: crackme,
+				Author:  list[0].Author,
+				Votes:   best,
+				Score:   float64(best) / float64(len(list)),
+				Detail:  fmt.Sprintf("%d of its %d votes within %v from %s", best, len(list), burstWindow, list[first].Time.UTC().Format("2006-01-02 15:04")),
+			})
+		}
+	}
+	return result
+}
+
+// authors finds the users voting mostly for the crackmes of a single author
+func authors(kind string, votes []Vote) []Anomaly {
+	total := make(map[string]int)
+	byAuthor := make(map[string]map[string]int)
+	for _, v := range votes {
+		total[v.User]++
+		if byAuthor[v.User] == nil {
+			byAuthor[v.User] = make(map[string]int)
+		}
+		byAuthor[v.User][v.Author]++
+	}
+
+	var result []Anomaly
+	for user, n := range total {
+		if n < minVotes {
+			continue
+		}
+		for author, count := range byAuthor[user] {
+			if share := float64(count) / float64(n); share >= authorShare {
+				result = append(result, Anomaly{
+					Type:   AnomalyAuthor,
+					Kind:   kind,
+					User:   user,
+					Author: author,
+					Votes:  count,
+					Score:  share,
+					Detail: fmt.Sprintf("%d of their %d votes on the crackmes of %s", count, n, author),
+				})
+			}
+		}
+	}
+	return result
+}
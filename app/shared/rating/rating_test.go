@@ -0,0 +1,75 @@
+package rating
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAllowed(t *testing.T) {
+	Configure(Info{})
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	if err := Allowed(now.AddDate(0, 0, -3), 10, now); err != ErrTooYoung {
+		t.Errorf("Account of 3 days: %v, expected %v", err, ErrTooYoung)
+	}
+	if err := Allowed(now.AddDate(0, 0, -30), 0, now); err != ErrInactive {
+		t.Errorf("Account without activity: %v, expected %v", err, ErrInactive)
+	}
+	if err := Allowed(now.AddDate(0, 0, -30), 1, now); err != nil {
+		t.Errorf("Active account of 30 days: %v", err)
+	}
+
+	Configure(Info{MinAccountDays: -1, MinActivity: -1})
+	if err := Allowed(now, 0, now); err != nil {
+		t.Errorf("Disabled rules: %v", err)
+	}
+}
+
+func TestAnomalies(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var votes []Vote
+
+	// 10 users agree on 10 crackmes of 10 authors, over days
+	for u := 0; u < 10; u++ {
+		for c := 0; c < 10; c++ {
+			rating := 3
+			if (u+c)%3 == 0 {
+				rating = 4
+			}
+			votes = append(votes, Vote{KindDifficulty, fmt.Sprintf("user%d", u), fmt.Sprintf("c%d", c), fmt.Sprintf("author%d", c), rating, start.AddDate(0, 0, u*10+c)})
+		}
+	}
+	// troll votes the opposite of everyone
+	for c := 0; c < 10; c++ {
+		votes = append(votes, Vote{KindDifficulty, "troll", fmt.Sprintf("c%d", c), fmt.Sprintf("author%d", c), 6, start.AddDate(0, 0, c)})
+	}
+	// friends vote within minutes for the only crackmes of buddy
+	for u := 0; u < 6; u++ {
+		for c := 0; c < 2; c++ {
+			votes = append(votes, Vote{KindQuality, fmt.Sprintf("friend%d", u), fmt.Sprintf("b%d", c), "buddy", 6, start.Add(time.Duration(u) * time.Minute)})
+		}
+	}
+	votes = append(votes, Vote{KindQuality, "friend0", "b2", "buddy", 6, start}, Vote{KindQuality, "friend0", "b3", "buddy", 6, start}, Vote{KindQuality, "friend0", "b4", "buddy", 6, start})
+
+	found := make(map[string]bool)
+	for _, a := range Anomalies(votes) {
+		found[a.Type+" "+a.Kind+" "+a.User+a.Crackme] = true
+	}
+
+	for _, expected := range []string{
+		"outlier difficulty troll",
+		"burst quality b0",
+		"burst quality b1",
+		"author quality friend0",
+	} {
+		if !found[expected] {
+			t.Errorf("Anomaly %q not found in %v", expected, found)
+		}
+	}
+	for key := range found {
+		if key[:7] == "outlier" && key != "outlier difficulty troll" {
+			t.Errorf("Unexpected anomaly %q", key)
+		}
+	}
+}
@@ -11,11 +11,20 @@ var (
 	recap Info
 )
 
+// Actions the trusted users can be exempted from the reCAPTCHA for
+const (
+	ActionComment  = "comment"
+	ActionCrackme  = "crackme"
+	ActionSolution = "solution"
+	ActionVersion  = "version"
+)
+
 // Info has the details for the Google reCAPTCHA
 type Info struct {
 	Enabled bool
 	Secret  string
 	SiteKey string
+	Trusted map[string]int // Reputation exempting the users from the reCAPTCHA, by action, 0 for no exemption
 }
 
 // Configure adds the settings for Google reCAPTCHA
@@ -41,6 +50,13 @@ func Verified(r *http.Request) bool {
 	return re.Verify(*r)
 }
 
+// Exempt tells if a user with the reputation skips the reCAPTCHA of the
+// action
+func Exempt(action string, reputation int) bool {
+	min := recap.Trusted[action]
+	return min > 0 && reputation >= min
+}
+
 // Plugin returns a map of functions that are usable in templates
 func Plugin() template.FuncMap {
 	f := make(template.FuncMap)
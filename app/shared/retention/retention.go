@@ -0,0 +1,66 @@
+// Package retention keeps the notifications from growing unbounded: the read
+// ones are deleted after a while, and each user keeps only the latest ones.
+package retention
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	info  Info
+	mutex sync.RWMutex
+)
+
+// Info contains the notification retention settings
+type Info struct {
+	Enabled    bool // Delete the old notifications on a schedule
+	Interval   int  // Hours between two cleanups, 24 by default
+	MaxAge     int  // Days a read notification is kept, 90 by default
+	MaxPerUser int  // Notifications kept per user, the latest, 500 by default
+}
+
+// Configure stores the settings, with their defaults
+func Configure(c Info) {
+	if c.Interval <= 0 {
+		c.Interval = 24
+	}
+	if c.MaxAge <= 0 {
+		c.MaxAge = 90
+	}
+	if c.MaxPerUser <= 0 {
+		c.MaxPerUser = 500
+	}
+
+	mutex.Lock()
+	info = c
+	mutex.Unlock()
+}
+
+// ReadConfig returns the settings
+func ReadConfig() Info {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return info
+}
+
+// Cutoff returns the date before which the read notifications are deleted
+func (c Info) Cutoff(now time.Time) time.Time {
+	return now.AddDate(0, 0, -c.MaxAge)
+}
+
+// Schedule runs the cleanup every Interval hours, forever
+func Schedule(c Info, run func() (int64, error)) {
+	if c.Interval <= 0 {
+		c.Interval = 24
+	}
+	for {
+		time.Sleep(time.Duration(c.Interval) * time.Hour)
+		if n, err := run(); err != nil {
+			log.Println("Notification cleanup failed:", err)
+		} else if n > 0 {
+			log.Println("Notification cleanup:", n, "deleted")
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package retention
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfigureDefaults(t *testing.T) {
+	Configure(Info{MaxPerUser: 50})
+	c := ReadConfig()
+	if c.Interval != 24 || c.MaxAge != 90 || c.MaxPerUser != 50 {
+		t.Errorf("got %+v", c)
+	}
+
+	now := time.Date(2024, 3, 14, 12, 0, 0, 0, time.UTC)
+	if got, want := c.Cutoff(now), time.Date(2023, 12, 15, 12, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Cutoff: got %v, want %v", got, want)
+	}
+	Configure(Info{})
+}
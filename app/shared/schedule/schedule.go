@@ -0,0 +1,52 @@
+// Package schedule runs the periodic checks of the database, such as the
+// consistency and the drift checks, in the background, one run of each at a
+// time whether started on a schedule or by an administrator.
+package schedule
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Check is a check run in the background
+type Check struct {
+	name    string
+	running int32 // 1 while a run started by Start is in progress
+}
+
+// New returns a check, its name tells its failures apart in the logs
+func New(name string) *Check {
+	return &Check{name: name}
+}
+
+// Running returns true while a run started by Start is in progress
+func (c *Check) Running() bool {
+	return atomic.LoadInt32(&c.running) == 1
+}
+
+// Start calls run in the background, unless a run is already in progress, in
+// which case it returns false
+func (c *Check) Start(run func() error) bool {
+	if !atomic.CompareAndSwapInt32(&c.running, 0, 1) {
+		return false
+	}
+	go func() {
+		defer atomic.StoreInt32(&c.running, 0)
+		if err := run(); err != nil {
+			log.Println(c.name, "failed:", err)
+		}
+	}()
+	return true
+}
+
+// Schedule starts run every interval hours, 24 if not set, forever
+func (c *Check) Schedule(interval int, run func() error) {
+	if interval <= 0 {
+		interval = 24
+	}
+	for {
+		time.Sleep(time.Duration(interval) * time.Hour)
+		c.Start(run)
+	}
+}
@@ -0,0 +1,24 @@
+package schedule
+
+import (
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	c := New("Test check")
+	block, done := make(chan bool), make(chan bool)
+	if !c.Start(func() error { <-block; return nil }) {
+		t.Fatal("first run not started")
+	}
+	if !c.Running() {
+		t.Error("not running during the first run")
+	}
+	if c.Start(func() error { return nil }) {
+		t.Error("second run started during the first")
+	}
+
+	close(block)
+	for !c.Start(func() error { close(done); return nil }) {
+	}
+	<-done
+}
@@ -0,0 +1,256 @@
+// Package seed generates a synthetic site at scale to validate performance
+// work against realistic data volumes. The activity of the users and the
+// popularity of the crackmes follow heavy-tailed (Zipf) distributions: a few
+// users write most of the crackmes, solutions and comments, and a few
+// crackmes get most of the solutions, like on the real site.
+package seed
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	// Parameters of the Zipf distributions, P(k) ∝ (zipfV + k)^-zipfS. The
+	// top 1% of the users write about 40% of the content.
+	zipfS = 1.1
+	zipfV = 20
+
+	// pendingRate is the share of the content left waiting for a moderator
+	pendingRate = 0.02
+
+	// maxTries is the number of draws tried to find a solver who didn't
+	// solve a crackme yet before the solution is skipped
+	maxTries = 10
+)
+
+// Info contains the seed settings
+type Info struct {
+	Users     int   // Number of users
+	Crackmes  int   // Number of crackmes
+	Solutions int   // Number of solutions, fewer when the users run out of crackmes to solve
+	Comments  int   // Number of comments
+	Years     int   // Age of the site, the content is spread over it
+	Seed      int64 // Seed of the random generator, the same seed generates the same site
+	BatchSize int   // Number of documents passed at once to the callbacks
+}
+
+// Defaults returns the settings of a site of the scale of a large crackmes.one
+func Defaults() Info {
+	return Info{
+		Users:     20000,
+		Crackmes:  100000,
+		Solutions: 1000000,
+		Comments:  1000000,
+		Years:     7,
+		Seed:      1,
+		BatchSize: 1000,
+	}
+}
+
+// Crackme is a generated crackme
+type Crackme struct {
+	Author     int // Index of the user
+	Name       string
+	Info       string
+	Lang       string
+	Arch       string
+	Platform   string
+	Difficulty float64
+	Quality    float64
+	CreatedAt  time.Time
+	Pending    bool
+}
+
+// Solution is a generated solution
+type Solution struct {
+	Author    int // Index of the user
+	Crackme   int // Index of the crackme
+	CreatedAt time.Time
+	Pending   bool
+}
+
+// Comment is a generated comment
+type Comment struct {
+	Author    int // Index of the user
+	Crackme   int // Index of the crackme
+	Text      string
+	CreatedAt time.Time
+}
+
+var (
+	langs      = []string{"C/C++", "C/C++", "C/C++", "Assembler", "Java", "Go", "Rust", "WebAssembly", "(Visual) Basic", "Borland Delphi", "Turbo Pascal", ".NET", ".NET", "Unspecified/other"}
+	archs      = []string{"x86", "x86-64", "x86-64", "java", "ARM", "MIPS", "RISC-V", "other"}
+	platforms  = []string{"Windows", "Windows", "Windows", "Unix/linux etc.", "Unix/linux etc.", "Mac OS X", "Multiplatform", "Android", "iOS", "Unspecified/other"}
+	adjectives = []string{"Easy", "Tiny", "Tricky", "Obfuscated", "Packed", "Simple", "Broken", "Hidden", "Twisted", "Silent"}
+	nouns      = []string{"KeygenMe", "CrackMe", "PatchMe", "Unpackme", "VM", "Serial", "License", "Maze", "Puzzle", "Riddle"}
+	remarks    = []string{
+		"Nice one, thanks!",
+		"Took me a while, the anti-debug trick is clever.",
+		"Is patching allowed?",
+		"Great for beginners.",
+		"The serial check is in the second thread.",
+		"Does it run on Windows 10?",
+		"Solved it with a debugger only.",
+		"Any hint for the second stage?",
+	}
+)
+
+// Generator generates the content of a site
+type Generator struct {
+	info  Info
+	rnd   *rand.Rand
+	users *rand.Zipf
+	now   time.Time
+	from  time.Time
+}
+
+// New returns a generator of the site described by info
+func New(info Info) *Generator {
+	if info.Users < 1 {
+		info.Users = 1
+	}
+	if info.BatchSize < 1 {
+		info.BatchSize = Defaults().BatchSize
+	}
+
+	rnd := rand.New(rand.NewSource(info.Seed))
+	now := time.Now().UTC().Truncate(time.Second)
+	return &Generator{
+		info:  info,
+		rnd:   rnd,
+		users: rand.NewZipf(rnd, zipfS, zipfV, uint64(info.Users-1)),
+		now:   now,
+		from:  now.AddDate(-info.Years, 0, 0),
+	}
+}
+
+// UserName returns the name of a user, the most active ones first
+func UserName(i int) string {
+	return fmt.Sprintf("user%06d", i)
+}
+
+// between returns a random time between from and the present
+func (g *Generator) between(from time.Time) time.Time {
+	span := g.now.Sub(from)
+	if span <= 0 {
+		return g.now
+	}
+	return from.Add(time.Duration(g.rnd.Int63n(int64(span)))).Truncate(time.Second)
+}
+
+// pick returns a random element of a list
+func (g *Generator) pick(list []string) string {
+	return list[g.rnd.Intn(len(list))]
+}
+
+// Crackmes returns the crackmes of the site, by date
+func (g *Generator) Crackmes() []Crackme {
+	crackmes := make([]Crackme, g.info.Crackmes)
+	span := g.now.Sub(g.from)
+	for i := range crackmes {
+		// The crackmes are spread evenly over the age of the site
+		createdAt := g.from.Add(span * time.Duration(i) / time.Duration(len(crackmes))).Truncate(time.Second)
+		crackmes[i] = Crackme{
+			Author:     int(g.users.Uint64()),
+			Name:       fmt.Sprintf("%s %s #%d", g.pick(adjectives), g.pick(nouns), i),
+			Info:       "Find the password, no patching. Generated crackme " + fmt.Sprint(i) + ".",
+			Lang:       g.pick(langs),
+			Arch:       g.pick(archs),
+			Platform:   g.pick(platforms),
+			Difficulty: float64(1+g.rnd.Intn(50)) / 10,
+			Quality:    float64(1+g.rnd.Intn(50)) / 10,
+			CreatedAt:  createdAt,
+			Pending:    g.rnd.Float64() < pendingRate,
+		}
+	}
+	return crackmes
+}
+
+// popularity returns the distribution of the solutions and comments among the
+// visible crackmes, the most popular ones being random ones
+func (g *Generator) popularity(crackmes []Crackme) (*rand.Zipf, []int) {
+	var visible []int
+	for i, c := range crackmes {
+		if !c.Pending {
+			visible = append(visible, i)
+		}
+	}
+	g.rnd.Shuffle(len(visible), func(i, j int) { visible[i], visible[j] = visible[j], visible[i] })
+	if len(visible) == 0 {
+		return nil, nil
+	}
+	return rand.NewZipf(g.rnd, zipfS, zipfV, uint64(len(visible)-1)), visible
+}
+
+// Solutions passes the solutions to fn by batches. A user solves a crackme
+// at most once and never their own crackmes.
+func (g *Generator) Solutions(crackmes []Crackme, fn func([]Solution) error) error {
+	zipf, visible := g.popularity(crackmes)
+	if zipf == nil {
+		return nil
+	}
+
+	solved := make(map[[2]int]bool, g.info.Solutions)
+	batch := make([]Solution, 0, g.info.BatchSize)
+	for n := 0; n < g.info.Solutions; n++ {
+		for try := 0; try < maxTries; try++ {
+			author := int(g.users.Uint64())
+			crackme := visible[zipf.Uint64()]
+			key := [2]int{author, crackme}
+			if solved[key] || crackmes[crackme].Author == author {
+				continue
+			}
+			solved[key] = true
+			batch = append(batch, Solution{
+				Author:    author,
+				Crackme:   crackme,
+				CreatedAt: g.between(crackmes[crackme].CreatedAt),
+				Pending:   g.rnd.Float64() < pendingRate,
+			})
+			break
+		}
+
+		if len(batch) == cap(batch) {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+// Comments passes the comments to fn by batches
+func (g *Generator) Comments(crackmes []Crackme, fn func([]Comment) error) error {
+	zipf, visible := g.popularity(crackmes)
+	if zipf == nil {
+		return nil
+	}
+
+	batch := make([]Comment, 0, g.info.BatchSize)
+	for n := 0; n < g.info.Comments; n++ {
+		crackme := visible[zipf.Uint64()]
+		batch = append(batch, Comment{
+			Author:    int(g.users.Uint64()),
+			Crackme:   crackme,
+			Text:      g.pick(remarks),
+			CreatedAt: g.between(crackmes[crackme].CreatedAt),
+		})
+
+		if len(batch) == cap(batch) {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
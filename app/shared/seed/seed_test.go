@@ -0,0 +1,70 @@
+package seed
+
+import (
+	"testing"
+)
+
+func testInfo() Info {
+	return Info{Users: 1000, Crackmes: 2000, Solutions: 20000, Comments: 5000, Years: 3, Seed: 42, BatchSize: 500}
+}
+
+func TestSolutions(t *testing.T) {
+	g := New(testInfo())
+	crackmes := g.Crackmes()
+	if len(crackmes) != 2000 {
+		t.Fatalf("%d crackmes, expected 2000", len(crackmes))
+	}
+
+	solved := map[[2]int]bool{}
+	perUser := make([]int, 1000)
+	total := 0
+	err := g.Solutions(crackmes, func(batch []Solution) error {
+		if len(batch) > 500 {
+			t.Errorf("Batch of %d solutions", len(batch))
+		}
+		for _, s := range batch {
+			key := [2]int{s.Author, s.Crackme}
+			if solved[key] {
+				t.Errorf("User %d solved crackme %d twice", s.Author, s.Crackme)
+			}
+			solved[key] = true
+			c := crackmes[s.Crackme]
+			if c.Author == s.Author {
+				t.Errorf("User %d solved their own crackme", s.Author)
+			}
+			if c.Pending {
+				t.Errorf("Pending crackme %d solved", s.Crackme)
+			}
+			if s.CreatedAt.Before(c.CreatedAt) {
+				t.Errorf("Crackme %d solved before its upload", s.Crackme)
+			}
+			perUser[s.Author]++
+		}
+		total += len(batch)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total < 19000 {
+		t.Errorf("%d solutions, expected about 20000", total)
+	}
+
+	// Heavy tail: the top 1% of the users write far more than 1%
+	top := 0
+	for _, n := range perUser[:10] {
+		top += n
+	}
+	if top < total/20 {
+		t.Errorf("The top 10 users wrote %d of %d solutions", top, total)
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	a, b := New(testInfo()).Crackmes(), New(testInfo()).Crackmes()
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Author != b[i].Author {
+			t.Fatalf("Crackme %d differs with the same seed", i)
+		}
+	}
+}
@@ -1,10 +1,16 @@
 package server
 
 import (
+    "crypto/tls"
     "fmt"
     "log"
+    "net"
     "net/http"
+    "strconv"
+    "strings"
     "time"
+
+    "golang.org/x/crypto/acme/autocert"
 )
 
 // Server stores the hostname and port number
@@ -16,39 +22,146 @@ type Server struct {
     HTTPSPort int    `json:"HTTPSPort"` // HTTPS port
     CertFile  string `json:"CertFile"`  // HTTPS certificate
     KeyFile   string `json:"KeyFile"`   // HTTPS private key
+
+    RedirectHTTP  bool     `json:"RedirectHTTP"`  // Redirect the HTTP requests to HTTPS
+    AutocertHosts []string `json:"AutocertHosts"` // Hosts to get ACME (Let's Encrypt) certificates for, instead of CertFile and KeyFile
+    AutocertEmail string   `json:"AutocertEmail"` // Contact address of the ACME account
+    AutocertCache string   `json:"AutocertCache"` // Directory of the ACME certificates
+    DisableHTTP2  bool     `json:"DisableHTTP2"`  // Serve HTTPS with HTTP/1.1 only
+
+    ReadHeaderTimeout int `json:"ReadHeaderTimeout"` // Seconds to read the headers of a request
+    ReadTimeout       int `json:"ReadTimeout"`       // Seconds to read a whole request, 0 for no limit
+    WriteTimeout      int `json:"WriteTimeout"`      // Seconds to write a response, 0 for no limit
+    IdleTimeout       int `json:"IdleTimeout"`       // Seconds a keep-alive connection waits for the next request
+}
+
+// withDefaults returns the settings with the defaults of the missing ones.
+// Slow clients can't hold connections open with their headers, but uploads
+// and downloads are not limited unless ReadTimeout and WriteTimeout are set.
+func withDefaults(s Server) Server {
+    if s.ReadHeaderTimeout <= 0 {
+        s.ReadHeaderTimeout = 10
+    }
+    if s.IdleTimeout <= 0 {
+        s.IdleTimeout = 120
+    }
+    if s.AutocertCache == "" {
+        s.AutocertCache = "tmp/autocert"
+    }
+    return s
 }
 
 // Run starts the HTTP and/or HTTPS listener
 func Run(httpHandlers http.Handler, httpsHandlers http.Handler, s Server) {
+    s = withDefaults(s)
+
+    // The HTTP listener answers the ACME challenges of the certificates
+    var manager *autocert.Manager
+    if s.UseHTTPS && len(s.AutocertHosts) > 0 {
+        manager = &autocert.Manager{
+            Prompt:     autocert.AcceptTOS,
+            HostPolicy: autocert.HostWhitelist(s.AutocertHosts...),
+            Cache:      autocert.DirCache(s.AutocertCache),
+            Email:      s.AutocertEmail,
+        }
+    }
+    if s.UseHTTPS && s.RedirectHTTP {
+        httpHandlers = redirectHandler(s)
+    }
+    if manager != nil {
+        httpHandlers = manager.HTTPHandler(httpHandlers)
+    }
+
     if s.UseHTTP && s.UseHTTPS {
         go func() {
-            startHTTPS(httpsHandlers, s)
+            startHTTPS(httpsHandlers, s, manager)
         }()
 
         startHTTP(httpHandlers, s)
     } else if s.UseHTTP {
         startHTTP(httpHandlers, s)
     } else if s.UseHTTPS {
-        startHTTPS(httpsHandlers, s)
+        startHTTPS(httpsHandlers, s, manager)
     } else {
         log.Println("Config file does not specify a listener to start")
     }
 }
 
+// newServer returns an HTTP server with the configured timeouts
+func newServer(addr string, handlers http.Handler, s Server) *http.Server {
+    return &http.Server{
+        Addr:              addr,
+        Handler:           handlers,
+        ReadHeaderTimeout: time.Duration(s.ReadHeaderTimeout) * time.Second,
+        ReadTimeout:       time.Duration(s.ReadTimeout) * time.Second,
+        WriteTimeout:      time.Duration(s.WriteTimeout) * time.Second,
+        IdleTimeout:       time.Duration(s.IdleTimeout) * time.Second,
+    }
+}
+
 // startHTTP starts the HTTP listener
 func startHTTP(handlers http.Handler, s Server) {
     fmt.Println(time.Now().Format("2006-01-02 03:04:05 PM"), "Running HTTP "+httpAddress(s))
 
     // Start the HTTP listener
-    log.Fatal(http.ListenAndServe(httpAddress(s), handlers))
+    log.Fatal(newServer(httpAddress(s), handlers, s).ListenAndServe())
 }
 
-// startHTTPs starts the HTTPS listener
-func startHTTPS(handlers http.Handler, s Server) {
+// startHTTPs starts the HTTPS listener, with the certificates of the ACME
+// manager if any, else with CertFile and KeyFile
+func startHTTPS(handlers http.Handler, s Server, manager *autocert.Manager) {
     fmt.Println(time.Now().Format("2006-01-02 03:04:05 PM"), "Running HTTPS "+httpsAddress(s))
 
+    srv := newServer(httpsAddress(s), handlers, s)
+    srv.TLSConfig = tlsConfig(s, manager)
+    if s.DisableHTTP2 {
+        // A non-nil map keeps net/http from setting up HTTP/2
+        srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+    }
+
     // Start the HTTPS listener
-    log.Fatal(http.ListenAndServeTLS(httpsAddress(s), s.CertFile, s.KeyFile, handlers))
+    if manager != nil {
+        log.Fatal(srv.ListenAndServeTLS("", ""))
+    }
+    log.Fatal(srv.ListenAndServeTLS(s.CertFile, s.KeyFile))
+}
+
+// tlsConfig returns the TLS settings of the HTTPS listener: TLS 1.2 at least,
+// and HTTP/2 offered unless disabled
+func tlsConfig(s Server, manager *autocert.Manager) *tls.Config {
+    config := &tls.Config{}
+    if manager != nil {
+        config = manager.TLSConfig()
+    }
+    config.MinVersion = tls.VersionTLS12
+
+    if s.DisableHTTP2 {
+        protos := []string{}
+        for _, p := range config.NextProtos {
+            if p != "h2" {
+                protos = append(protos, p)
+            }
+        }
+        config.NextProtos = protos
+    }
+    return config
+}
+
+// redirectHandler redirects the requests to the same URL on the HTTPS listener
+func redirectHandler(s Server) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        host := r.Host
+        if h, _, err := net.SplitHostPort(host); err == nil {
+            host = h
+            if strings.Contains(h, ":") {
+                host = "[" + h + "]"
+            }
+        }
+        if s.HTTPSPort != 443 && s.HTTPSPort != 0 {
+            host += ":" + strconv.Itoa(s.HTTPSPort)
+        }
+        http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+    })
 }
 
 // httpAddress returns the HTTP address
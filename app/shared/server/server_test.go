@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		port int
+		host string
+		want string
+	}{
+		{443, "crackmes.one", "https://crackmes.one/crackme/5f0c?x=1"},
+		{443, "crackmes.one:80", "https://crackmes.one/crackme/5f0c?x=1"},
+		{8443, "localhost:8080", "https://localhost:8443/crackme/5f0c?x=1"},
+		{8443, "[::1]:8080", "https://[::1]:8443/crackme/5f0c?x=1"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "http://"+test.host+"/crackme/5f0c?x=1", nil)
+		w := httptest.NewRecorder()
+		redirectHandler(Server{HTTPSPort: test.port}).ServeHTTP(w, r)
+		if got := w.Header().Get("Location"); got != test.want {
+			t.Errorf("%s on %d: got %q, want %q", test.host, test.port, got, test.want)
+		}
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	c := tlsConfig(Server{DisableHTTP2: true}, nil)
+	for _, p := range c.NextProtos {
+		if p == "h2" {
+			t.Error("HTTP/2 offered while disabled")
+		}
+	}
+	if c.MinVersion == 0 {
+		t.Error("no minimum TLS version")
+	}
+}
@@ -0,0 +1,132 @@
+// Package settings lets the admins tune the operational knobs at runtime,
+// without a redeploy: the upload size, the approval requirements, the rate
+// limits and the registration.
+//
+// The knobs are registered by the controllers with a getter of their value,
+// from the configuration or a default. The admins override them from
+// /admin/settings, within the bounds of each knob.
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Sources of the value of a knob
+const (
+	SourceConfig = "config"
+	SourceAdmin  = "admin"
+)
+
+// ErrUnknown is returned when overriding a knob which isn't registered
+var ErrUnknown = errors.New("Unknown setting.")
+
+// Setting is a knob, an integer within bounds, or 0 and 1 for the switches
+type Setting struct {
+	Name        string
+	Description string
+	Unit        string // What the value counts, shown next to it
+	Min         int
+	Max         int
+	Switch      bool // Off or on, 0 or 1
+	Value       int
+	Source      string // Where the value comes from, a Source* constant
+}
+
+// knob is a registered setting with how to read and apply it
+type knob struct {
+	Setting
+	get func() int
+	set func(int)
+}
+
+var (
+	mutex     sync.RWMutex
+	knobs     = make(map[string]*knob)
+	overrides = make(map[string]int)
+)
+
+// Register declares a knob: get returns its value when not overridden, set
+// applies an override to the package using it, nil if it reads the knob
+// with Int or Bool
+func Register(s Setting, get func() int, set func(int)) {
+	if s.Switch {
+		s.Min, s.Max = 0, 1
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	knobs[s.Name] = &knob{Setting: s, get: get, set: set}
+}
+
+// Validate returns nil if value is within the bounds of the knob
+func Validate(name string, value int) error {
+	mutex.RLock()
+	k, ok := knobs[name]
+	mutex.RUnlock()
+
+	if !ok {
+		return ErrUnknown
+	}
+	if value < k.Min || value > k.Max {
+		return fmt.Errorf("%s must be between %d and %d.", k.Description, k.Min, k.Max)
+	}
+	return nil
+}
+
+// Override sets the value of a knob chosen by an admin, it takes precedence
+// over the configuration
+func Override(name string, value int) error {
+	if err := Validate(name, value); err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	k := knobs[name]
+	overrides[name] = value
+	mutex.Unlock()
+
+	if k.set != nil {
+		k.set(value)
+	}
+	return nil
+}
+
+// Int returns the value of a knob, 0 if unknown
+func Int(name string) int {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if value, ok := overrides[name]; ok {
+		return value
+	}
+	if k, ok := knobs[name]; ok {
+		return k.get()
+	}
+	return 0
+}
+
+// Bool returns true if a switch is on
+func Bool(name string) bool {
+	return Int(name) != 0
+}
+
+// Settings returns the registered knobs with their value, by name
+func Settings() []Setting {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	result := make([]Setting, 0, len(knobs))
+	for _, k := range knobs {
+		s := k.Setting
+		s.Value, s.Source = k.get(), SourceConfig
+		if value, ok := overrides[s.Name]; ok {
+			s.Value, s.Source = value, SourceAdmin
+		}
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
@@ -0,0 +1,41 @@
+package settings
+
+import "testing"
+
+func TestOverride(t *testing.T) {
+	applied := 0
+	Register(Setting{Name: "test-size", Description: "Size", Min: 1, Max: 5}, func() int { return 5 }, nil)
+	Register(Setting{Name: "test-applied", Description: "Applied", Min: 0, Max: 100}, func() int { return applied }, func(v int) { applied = v })
+	Register(Setting{Name: "test-switch", Description: "Switch", Switch: true}, func() int { return 1 }, nil)
+
+	if Int("test-size") != 5 || !Bool("test-switch") || Int("test-unknown") != 0 {
+		t.Error("Values of the configuration")
+	}
+
+	if err := Override("test-size", 6); err == nil {
+		t.Error("Value out of bounds accepted")
+	}
+	if err := Override("test-switch", 2); err == nil {
+		t.Error("Switch set to 2")
+	}
+	if err := Override("test-unknown", 1); err != ErrUnknown {
+		t.Errorf("Unknown setting: got %v", err)
+	}
+
+	if err := Override("test-size", 2); err != nil || Int("test-size") != 2 {
+		t.Errorf("Override: got %v, %d", err, Int("test-size"))
+	}
+	if err := Override("test-applied", 42); err != nil || applied != 42 {
+		t.Errorf("Override not applied: got %v, %d", err, applied)
+	}
+	Override("test-switch", 0)
+	if Bool("test-switch") {
+		t.Error("Switch still on")
+	}
+
+	for _, s := range Settings() {
+		if s.Name == "test-size" && (s.Value != 2 || s.Source != SourceAdmin) {
+			t.Errorf("Setting listed as %+v", s)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Package shadow runs a new implementation of a query next to the legacy one
+// on a share of the requests. The legacy result is always the one served, the
+// new one is compared with it and both are timed, so a rewrite can be
+// trusted to return identical data, and to be faster, before it replaces the
+// legacy one.
+package shadow
+
+import (
+	"log"
+	"math/rand"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Info contains the shadow settings
+type Info struct {
+	Percent float64 // Share of the requests also running the new implementation, 0 disables the comparisons
+}
+
+// Stats are the results of the comparisons of a query
+type Stats struct {
+	Name       string
+	Runs       int           // Number of comparisons
+	Mismatches int           // Comparisons where the results differed
+	Errors     int           // Comparisons where the new implementation failed
+	Legacy     time.Duration // Total time of the legacy implementation
+	Candidate  time.Duration // Total time of the new implementation
+	LastDiff   time.Time     // Time of the last mismatch
+}
+
+// AvgLegacy returns the average time of the legacy implementation
+func (s Stats) AvgLegacy() time.Duration {
+	if s.Runs == 0 {
+		return 0
+	}
+	return s.Legacy / time.Duration(s.Runs)
+}
+
+// AvgCandidate returns the average time of the new implementation
+func (s Stats) AvgCandidate() time.Duration {
+	if s.Runs == 0 {
+		return 0
+	}
+	return s.Candidate / time.Duration(s.Runs)
+}
+
+// Delta returns how much faster, negative, or slower, positive, the new
+// implementation is on average, in percent of the legacy one
+func (s Stats) Delta() float64 {
+	if s.Legacy == 0 {
+		return 0
+	}
+	return float64(s.Candidate-s.Legacy) * 100 / float64(s.Legacy)
+}
+
+var (
+	mutex sync.Mutex
+	info  Info
+	stats = make(map[string]*Stats)
+)
+
+// Configure sets the shadow settings
+func Configure(i Info) {
+	mutex.Lock()
+	info = i
+	mutex.Unlock()
+}
+
+// Sample returns true for the share of the requests to compare
+func Sample() bool {
+	mutex.Lock()
+	percent := info.Percent
+	mutex.Unlock()
+	return percent > 0 && rand.Float64()*100 < percent
+}
+
+// Compare runs the new implementation of a query, in the background, and
+// records how it compares with the result and the duration of the legacy one.
+// The results are compared with reflect.DeepEqual, nil and empty slices must
+// be normalized beforehand.
+func Compare(name string, legacy interface{}, legacyTime time.Duration, candidate func() (interface{}, error)) {
+	go func() {
+		start := time.Now()
+		result, err := candidate()
+		candidateTime := time.Since(start)
+
+		mismatch := err == nil && !reflect.DeepEqual(legacy, result)
+		if err != nil {
+			log.Println("Shadow", name, "failed:", err)
+		} else if mismatch {
+			log.Println("Shadow", name, "returned a different result")
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		s, ok := stats[name]
+		if !ok {
+			s = &Stats{Name: name}
+			stats[name] = s
+		}
+		s.Runs++
+		s.Legacy += legacyTime
+		s.Candidate += candidateTime
+		if err != nil {
+			s.Errors++
+		}
+		if mismatch {
+			s.Mismatches++
+			s.LastDiff = time.Now()
+		}
+	}()
+}
+
+// All returns the stats of the compared queries, by name
+func All() []Stats {
+	mutex.Lock()
+	defer mutex.Unlock()
+	result := make([]Stats, 0, len(stats))
+	for _, s := range stats {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Reset forgets the stats
+func Reset() {
+	mutex.Lock()
+	stats = make(map[string]*Stats)
+	mutex.Unlock()
+}
@@ -0,0 +1,53 @@
+package shadow
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// wait waits for the background comparisons to reach n runs
+func wait(t *testing.T, name string, n int) Stats {
+	for i := 0; i < 100; i++ {
+		for _, s := range All() {
+			if s.Name == name && s.Runs == n {
+				return s
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s didn't reach %d runs", name, n)
+	return Stats{}
+}
+
+func TestCompare(t *testing.T) {
+	Reset()
+	Compare("q", []int{1, 2}, 2*time.Millisecond, func() (interface{}, error) { return []int{1, 2}, nil })
+	wait(t, "q", 1)
+	Compare("q", []int{1, 2}, 2*time.Millisecond, func() (interface{}, error) { return []int{2, 1}, nil })
+	wait(t, "q", 2)
+	Compare("q", []int{1, 2}, 2*time.Millisecond, func() (interface{}, error) { return nil, errors.New("failed") })
+	s := wait(t, "q", 3)
+
+	if s.Mismatches != 1 || s.Errors != 1 {
+		t.Errorf("%d mismatches and %d errors, expected 1 and 1", s.Mismatches, s.Errors)
+	}
+	if s.AvgLegacy() != 2*time.Millisecond {
+		t.Errorf("Average legacy time %v, expected 2ms", s.AvgLegacy())
+	}
+	if s.Delta() >= 0 {
+		t.Errorf("Delta %f, the new implementation is faster", s.Delta())
+	}
+}
+
+func TestSample(t *testing.T) {
+	Configure(Info{})
+	if Sample() {
+		t.Error("Sampled while disabled")
+	}
+	Configure(Info{Percent: 100})
+	if !Sample() {
+		t.Error("Not sampled at 100%")
+	}
+	Configure(Info{})
+}
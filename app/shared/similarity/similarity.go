@@ -0,0 +1,166 @@
+// Package similarity estimates how much two texts have in common, using
+// word shingles and minhash signatures.
+package similarity
+
+import (
+	"archive/zip"
+	"bytes"
+	"hash/fnv"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/crackmesone/crackmes.one/app/shared/archive"
+)
+
+const (
+	// ShingleSize is the number of consecutive words of a shingle
+	ShingleSize = 5
+
+	// SignatureSize is the number of hash functions of a signature
+	SignatureSize = 128
+
+	// maxTextSize caps the amount of text read from an upload
+	maxTextSize = 1 << 20
+)
+
+// Signature is the minhash signature of a text
+type Signature []uint32
+
+// words returns the lowercased words of a text, punctuation and layout are
+// ignored so reformatting a copied text doesn't hide it
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// shingles returns the hashes of the distinct word shingles of a text. Texts
+// shorter than a shingle are a single shingle.
+func shingles(text string) map[uint32]bool {
+	w := words(text)
+	set := make(map[uint32]bool)
+	if len(w) == 0 {
+		return set
+	}
+
+	n := len(w) - ShingleSize + 1
+	if n < 1 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		end := i + ShingleSize
+		if end > len(w) {
+			end = len(w)
+		}
+		h := fnv.New32a()
+		h.Write([]byte(strings.Join(w[i:end], " ")))
+		set[h.Sum32()] = true
+	}
+	return set
+}
+
+// mix derives the hash of a shingle for the i-th hash function
+func mix(x uint32, i int) uint32 {
+	x ^= uint32(i) * 0x9e3779b9
+	x ^= x >> 16
+	x *= 0x85ebca6b
+	x ^= x >> 13
+	x *= 0xc2b2ae35
+	x ^= x >> 16
+	return x
+}
+
+// Sign returns the minhash signature of a text, or nil if it has no words
+func Sign(text string) Signature {
+	set := shingles(text)
+	if len(set) == 0 {
+		return nil
+	}
+
+	sig := make(Signature, SignatureSize)
+	for i := range sig {
+		sig[i] = ^uint32(0)
+	}
+	for s := range set {
+		for i := range sig {
+			if h := mix(s, i); h < sig[i] {
+				sig[i] = h
+			}
+		}
+	}
+	return sig
+}
+
+// Similarity estimates the Jaccard similarity, between 0 and 1, of the texts
+// of two signatures
+func Similarity(a, b Signature) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
+
+// isText reports whether data looks like text rather than a binary
+func isText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) == -1
+}
+
+// Text returns the text of an uploaded file: the file itself if it is text,
+// or the text files it contains if it is a zip archive. Binaries are ignored.
+func Text(data []byte) string {
+	if isText(data) {
+		if len(data) > maxTextSize {
+			data = data[:maxTextSize]
+		}
+		return string(data)
+	}
+
+	return zipText(data, (*zip.File).Open)
+}
+
+// StoredText returns the text files of a zip of the storage, whose files are
+// encrypted with password
+func StoredText(data []byte, password string) string {
+	return zipText(data, func(f *zip.File) (io.ReadCloser, error) {
+		return archive.OpenEncrypted(f, password)
+	})
+}
+
+// zipText returns the text files of a zip archive, opened with open
+func zipText(data []byte, open func(*zip.File) (io.ReadCloser, error)) string {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return ""
+	}
+
+	var text strings.Builder
+	for _, f := range r.File {
+		if text.Len() >= maxTextSize {
+			break
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := open(f)
+		if err != nil {
+			continue
+		}
+		content, err := io.ReadAll(io.LimitReader(rc, int64(maxTextSize-text.Len())))
+		rc.Close()
+		if err != nil || !isText(content) {
+			continue
+		}
+		text.Write(content)
+		text.WriteString("\n")
+	}
+	return text.String()
+}
@@ -0,0 +1,52 @@
+package similarity
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+)
+
+const writeup = `Open the binary in a disassembler and look at main. The key is read
+with scanf and compared byte by byte against a table xored with 0x42. Xoring the
+table back gives the password, which the program accepts.`
+
+func TestSimilarity(t *testing.T) {
+	a := Sign(writeup)
+	if s := Similarity(a, Sign(writeup)); s != 1 {
+		t.Errorf("identical texts: %v", s)
+	}
+
+	// Case, punctuation and layout don't matter
+	reformatted := "OPEN the binary, in a disassembler; and look at main!\n\n" + writeup[42:]
+	if s := Similarity(a, Sign(reformatted)); s < 0.8 {
+		t.Errorf("reformatted text: %v", s)
+	}
+
+	other := Sign(`I patched the conditional jump after the check with a debugger, then
+dumped the decrypted string from memory and it was the flag all along.`)
+	if s := Similarity(a, other); s > 0.2 {
+		t.Errorf("different texts: %v", s)
+	}
+
+	if Sign("  ,,, ") != nil {
+		t.Error("signature of a text without words")
+	}
+}
+
+func TestText(t *testing.T) {
+	if Text([]byte(writeup)) != writeup {
+		t.Error("plain text upload")
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, _ := zw.Create("writeup.md")
+	f.Write([]byte(writeup))
+	f, _ = zw.Create("keygen.exe")
+	f.Write([]byte{'M', 'Z', 0, 0})
+	zw.Close()
+
+	if Text(buf.Bytes()) != writeup+"\n" {
+		t.Error("zip upload")
+	}
+}
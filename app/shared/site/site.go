@@ -0,0 +1,155 @@
+// Package site holds the external address of the site: the links leaving it,
+// in the feeds, the emails and the webhooks, are made absolute with it, and
+// its path, if any, hosts the site below a subpath of a reverse proxy.
+// Handler strips that path from the requests and puts it back in the
+// redirections, so the routes and the handlers keep their paths from "/".
+package site
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// DefaultBaseURL is the address of the site when none is configured
+const DefaultBaseURL = "https://crackmes.one"
+
+// Info contains the site settings
+type Info struct {
+	BaseURL string // External URL of the site, with the path it is served below if any, e.g. https://example.com/crackmes
+}
+
+var (
+	info   = Info{BaseURL: DefaultBaseURL}
+	prefix string
+	mutex  sync.RWMutex
+)
+
+// Configure stores the settings. An invalid base URL is replaced by the
+// default one.
+func Configure(c Info) {
+	c.BaseURL = strings.TrimRight(c.BaseURL, "/")
+	if c.BaseURL == "" {
+		c.BaseURL = DefaultBaseURL
+	}
+	u, err := url.Parse(c.BaseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		log.Println("Invalid site BaseURL", c.BaseURL, "using", DefaultBaseURL)
+		c.BaseURL = DefaultBaseURL
+		u, _ = url.Parse(DefaultBaseURL)
+	}
+
+	mutex.Lock()
+	info = c
+	prefix = u.Path
+	mutex.Unlock()
+}
+
+// ReadConfig returns the settings
+func ReadConfig() Info {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return info
+}
+
+// Prefix returns the path the site is served below, without a trailing
+// slash, empty at the root of its host
+func Prefix() string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return prefix
+}
+
+// URL returns the absolute URL of a path of the site, for the links read
+// outside of it
+func URL(path string) string {
+	return ReadConfig().BaseURL + path
+}
+
+// Path returns a path of the site as seen by the browsers, below the prefix
+func Path(path string) string {
+	return Prefix() + path
+}
+
+// Strip removes the prefix from a path seen by the browsers, such as the
+// path of a Referer, giving the path of the route
+func Strip(path string) string {
+	p := Prefix()
+	switch {
+	case p == "":
+		return path
+	case path == p:
+		return "/"
+	case strings.HasPrefix(path, p+"/"):
+		return path[len(p):]
+	}
+	return path
+}
+
+// Handler serves the requests below the prefix with their path stripped and
+// prefixes the redirections to a path of the site. The requests outside the
+// prefix, from a proxy removing it itself, are served as they come.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := Prefix()
+		if p == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if stripped := Strip(r.URL.Path); stripped != r.URL.Path {
+			r = r.Clone(r.Context())
+			r.URL.Path = stripped
+			r.URL.RawPath = ""
+		}
+		h.ServeHTTP(&prefixWriter{ResponseWriter: w, prefix: p}, r)
+	})
+}
+
+// prefixWriter prefixes the Location header of the redirections to a path of
+// the site
+type prefixWriter struct {
+	http.ResponseWriter
+	prefix      string
+	wroteHeader bool
+}
+
+func (w *prefixWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		loc := w.Header().Get("Location")
+		if strings.HasPrefix(loc, "/") && !strings.HasPrefix(loc, "//") {
+			w.Header().Set("Location", w.prefix+loc)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *prefixWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush lets the handlers streaming their answer flush it through the writer
+func (w *prefixWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Plugin returns a map of functions that are usable in templates
+// * SITEURL returns the absolute URL of a path of the site
+// * SITEPATH returns a path of the site below the prefix
+func Plugin() template.FuncMap {
+	f := make(template.FuncMap)
+
+	f["SITEURL"] = URL
+	f["SITEPATH"] = Path
+
+	return f
+}
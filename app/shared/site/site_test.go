@@ -0,0 +1,49 @@
+package site
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	Configure(Info{BaseURL: "https://example.com/crackmes/"})
+	defer Configure(Info{})
+
+	if got := URL("/crackme/abc"); got != "https://example.com/crackmes/crackme/abc" {
+		t.Errorf("URL = %q", got)
+	}
+	if got := Path("/"); got != "/crackmes/" {
+		t.Errorf("Path = %q", got)
+	}
+
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login?next="+r.URL.Path, http.StatusFound)
+	}))
+	for _, c := range []struct{ path, location string }{
+		{"/crackmes/crackme/abc", "/crackmes/login?next=/crackme/abc"},
+		{"/crackmes", "/crackmes/login?next=/"},
+		// Behind a proxy removing the prefix itself
+		{"/crackme/abc", "/crackmes/login?next=/crackme/abc"},
+		// Not below the prefix, only starting like it
+		{"/crackmesx", "/crackmes/login?next=/crackmesx"},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", c.path, nil))
+		if got := w.Header().Get("Location"); got != c.location {
+			t.Errorf("%s: Location = %q, want %q", c.path, got, c.location)
+		}
+	}
+}
+
+func TestConfigureDefault(t *testing.T) {
+	Configure(Info{BaseURL: "not a url"})
+	defer Configure(Info{})
+
+	if got := URL("/lasts"); got != DefaultBaseURL+"/lasts" {
+		t.Errorf("URL = %q", got)
+	}
+	if Prefix() != "" {
+		t.Errorf("Prefix = %q", Prefix())
+	}
+}
@@ -0,0 +1,116 @@
+// Package trending ranks the crackmes by their recent activity, each action
+// counting less as it gets older.
+package trending
+
+import (
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+// Info contains the trending settings
+type Info struct {
+	Enabled  bool // Compute the ranking on a schedule
+	Interval int  // Hours between two computations
+	Days     int  // Days of activity taken into account, 7 when 0, MaxDays at most
+	HalfLife int  // Hours after which an action counts half, 48 when 0
+}
+
+// MaxDays is the longest activity window, the daily counters are kept no
+// longer
+const MaxDays = 30
+
+// Window returns the duration of the activity taken into account
+func (c Info) Window() time.Duration {
+	if c.Days <= 0 {
+		c.Days = 7
+	} else if c.Days > MaxDays {
+		c.Days = MaxDays
+	}
+	return time.Duration(c.Days) * 24 * time.Hour
+}
+
+// Decay returns the half-life of the actions
+func (c Info) Decay() time.Duration {
+	if c.HalfLife <= 0 {
+		c.HalfLife = 48
+	}
+	return time.Duration(c.HalfLife) * time.Hour
+}
+
+// Kinds of activity
+const (
+	KindView     = "view"
+	KindDownload = "download"
+	KindSolution = "solution"
+	KindComment  = "comment"
+)
+
+// Weights are what an action of each kind counts for, a writeup being worth
+// more than a look at the page
+var Weights = map[string]float64{
+	KindView:     0.1,
+	KindDownload: 1,
+	KindSolution: 5,
+	KindComment:  2,
+}
+
+// Activity is Count actions of a kind on a crackme around a date
+type Activity struct {
+	HexId string
+	Kind  string
+	At    time.Time
+	Count int
+}
+
+// Entry is a ranked crackme
+type Entry struct {
+	HexId string  `bson:"hexid"`
+	Score float64 `bson:"score"`
+}
+
+// Rank returns the n crackmes with the highest score at now, highest first.
+// An action counts its weight, halved every halfLife since it happened.
+func Rank(activities []Activity, now time.Time, halfLife time.Duration, n int) []Entry {
+	scores := make(map[string]float64)
+	for _, a := range activities {
+		age := now.Sub(a.At)
+		if age < 0 {
+			age = 0
+		}
+		decay := math.Pow(0.5, float64(age)/float64(halfLife))
+		scores[a.HexId] += Weights[a.Kind] * float64(a.Count) * decay
+	}
+
+	entries := make([]Entry, 0, len(scores))
+	for hexid, score := range scores {
+		if score > 0 {
+			entries = append(entries, Entry{HexId: hexid, Score: score})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Score != entries[j].Score {
+			return entries[i].Score > entries[j].Score
+		}
+		return entries[i].HexId < entries[j].HexId
+	})
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// Schedule computes the ranking now, then every Interval hours. It never
+// returns, start it in a goroutine.
+func Schedule(c Info, run func(Info) error) {
+	if c.Interval <= 0 {
+		c.Interval = 6
+	}
+	for {
+		if err := run(c); err != nil {
+			log.Println("Trending ranking failed:", err)
+		}
+		time.Sleep(time.Duration(c.Interval) * time.Hour)
+	}
+}
@@ -0,0 +1,48 @@
+package trending
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRank(t *testing.T) {
+	now := time.Date(2024, time.March, 14, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	activities := []Activity{
+		// Many old downloads
+		{HexId: "old", Kind: KindDownload, At: now.Add(-6 * day), Count: 20},
+		// A few recent ones and a writeup
+		{HexId: "new", Kind: KindDownload, At: now.Add(-time.Hour), Count: 3},
+		{HexId: "new", Kind: KindSolution, At: now.Add(-2 * time.Hour), Count: 1},
+		{HexId: "viewed", Kind: KindView, At: now, Count: 5},
+		{HexId: "unknown", Kind: "other", At: now, Count: 100},
+	}
+
+	entries := Rank(activities, now, 48*time.Hour, 10)
+	if len(entries) != 3 {
+		t.Fatalf("%d entries, expected 3: %v", len(entries), entries)
+	}
+	for i, hexid := range []string{"new", "old", "viewed"} {
+		if entries[i].HexId != hexid {
+			t.Errorf("Rank %d is %s, expected %s", i+1, entries[i].HexId, hexid)
+		}
+	}
+	// 20 downloads three half-lives ago count for 2.5
+	if s := entries[1].Score; s < 2.49 || s > 2.51 {
+		t.Errorf("Score of the old downloads %f, expected 2.5", s)
+	}
+
+	if entries := Rank(activities, now, 48*time.Hour, 1); len(entries) != 1 || entries[0].HexId != "new" {
+		t.Errorf("Top 1: %v", entries)
+	}
+}
+
+func TestInfoDefaults(t *testing.T) {
+	var c Info
+	if c.Window() != 7*24*time.Hour || c.Decay() != 48*time.Hour {
+		t.Errorf("Defaults %v and %v", c.Window(), c.Decay())
+	}
+	if c = (Info{Days: 365}); c.Window() != MaxDays*24*time.Hour {
+		t.Errorf("Window of %d days: %v", c.Days, c.Window())
+	}
+}
@@ -0,0 +1,104 @@
+package view
+
+import (
+    "fmt"
+    "sync"
+    "time"
+
+    "github.com/gorilla/sessions"
+)
+
+// Session keys of the clock preferences, set from the user on login and when
+// they change them
+const (
+    sessTimezone     = "timezone"
+    sessRelativeTime = "relativetime"
+)
+
+// TimeFormat is the format of the dates shown on the pages
+const TimeFormat = "3:04 PM 01/02/2006"
+
+// Clock is how the dates are shown to the viewer: in their timezone, UTC by
+// default, and optionally relative to now
+type Clock struct {
+    Location *time.Location
+    Relative bool // "2 hours ago" rather than the date
+}
+
+// locations caches the loaded timezones by name
+var locations sync.Map
+
+// LoadLocation returns the timezone of an IANA name, UTC for an empty name
+func LoadLocation(name string) (*time.Location, error) {
+    if loc, ok := locations.Load(name); ok {
+        return loc.(*time.Location), nil
+    }
+    loc, err := time.LoadLocation(name)
+    if err != nil {
+        return nil, err
+    }
+    locations.Store(name, loc)
+    return loc, nil
+}
+
+// SetClock stores the clock preferences of the user in their session
+func SetClock(sess *sessions.Session, timezone string, relative bool) {
+    sess.Values[sessTimezone] = timezone
+    sess.Values[sessRelativeTime] = relative
+}
+
+// clockOf returns the clock of the session
+func clockOf(sess *sessions.Session) Clock {
+    c := Clock{Location: time.UTC}
+    if name, ok := sess.Values[sessTimezone].(string); ok {
+        if loc, err := LoadLocation(name); err == nil {
+            c.Location = loc
+        }
+    }
+    c.Relative, _ = sess.Values[sessRelativeTime].(bool)
+    return c
+}
+
+// Key identifies the clock in the cache keys of what is rendered with it
+func (c Clock) Key() string {
+    return fmt.Sprintf("%s/%t", c.Location, c.Relative)
+}
+
+// Format returns a date for the viewer, relative to now if they prefer
+func (c Clock) Format(t, now time.Time) string {
+    if c.Relative {
+        if s, ok := Ago(t, now); ok {
+            return s
+        }
+    }
+    loc := c.Location
+    if loc == nil {
+        loc = time.UTC
+    }
+    return t.In(loc).Format(TimeFormat)
+}
+
+// Ago returns how long before now a date is, like "2 hours ago", and false
+// for the dates in the future or more than 30 days ago, better shown as dates
+func Ago(t, now time.Time) (string, bool) {
+    d := now.Sub(t)
+    switch {
+    case d < 0 || d >= 30*24*time.Hour:
+        return "", false
+    case d < time.Minute:
+        return "just now", true
+    case d < time.Hour:
+        return ago(int(d/time.Minute), "minute"), true
+    case d < 24*time.Hour:
+        return ago(int(d/time.Hour), "hour"), true
+    }
+    return ago(int(d/(24*time.Hour)), "day"), true
+}
+
+// ago returns "n units ago"
+func ago(n int, unit string) string {
+    if n > 1 {
+        unit += "s"
+    }
+    return fmt.Sprintf("%d %s ago", n, unit)
+}
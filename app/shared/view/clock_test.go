@@ -0,0 +1,34 @@
+package view
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockFormat(t *testing.T) {
+	now := time.Date(2024, 3, 14, 12, 0, 0, 0, time.UTC)
+	paris, err := LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("no timezone database:", err)
+	}
+
+	tests := []struct {
+		clock Clock
+		t     time.Time
+		want  string
+	}{
+		{Clock{Location: time.UTC}, now, "12:00 PM 03/14/2024"},
+		{Clock{Location: paris}, now, "1:00 PM 03/14/2024"},
+		{Clock{Location: time.UTC, Relative: true}, now.Add(-30 * time.Second), "just now"},
+		{Clock{Location: time.UTC, Relative: true}, now.Add(-time.Minute), "1 minute ago"},
+		{Clock{Location: time.UTC, Relative: true}, now.Add(-3 * time.Hour), "3 hours ago"},
+		{Clock{Location: time.UTC, Relative: true}, now.Add(-29 * 24 * time.Hour), "29 days ago"},
+		{Clock{Location: time.UTC, Relative: true}, now.Add(-30 * 24 * time.Hour), "12:00 PM 02/13/2024"},
+		{Clock{Location: time.UTC, Relative: true}, now.Add(time.Hour), "1:00 PM 03/14/2024"},
+	}
+	for _, tt := range tests {
+		if got := tt.clock.Format(tt.t, now); got != tt.want {
+			t.Errorf("Format(%v) with %s: got %q, want %q", tt.t, tt.clock.Key(), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package view
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	// FormatHTML is the default, rendered through the templates
+	FormatHTML = "html"
+	// FormatJSON is an application/json response
+	FormatJSON = "json"
+	// FormatCSV is a text/csv response
+	FormatCSV = "csv"
+)
+
+// Format returns the representation requested by the client: the "format"
+// query parameter if set, else the Accept header, else FormatHTML
+func Format(r *http.Request) string {
+	switch strings.ToLower(r.URL.Query().Get("format")) {
+	case FormatJSON:
+		return FormatJSON
+	case FormatCSV:
+		return FormatCSV
+	case "":
+	default:
+		return FormatHTML
+	}
+
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.HasPrefix(accept, "application/json"):
+		return FormatJSON
+	case strings.HasPrefix(accept, "text/csv"):
+		return FormatCSV
+	}
+	return FormatHTML
+}
+
+// RenderJSON writes data as JSON
+func RenderJSON(w http.ResponseWriter, data interface{}) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, "JSON Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
+
+// RenderCSV writes the header line followed by the records as CSV, offered as
+// a download named filename, quoted or encoded as the header needs. The cells
+// a spreadsheet would read as a formula are escaped, see csvCell.
+func RenderCSV(w http.ResponseWriter, filename string, header []string, records [][]string) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	for _, record := range records {
+		cells := make([]string, len(record))
+		for i, cell := range record {
+			cells[i] = csvCell(cell)
+		}
+		cw.Write(cells)
+	}
+	cw.Flush()
+}
+
+// csvCell prefixes with a quote the cells starting like a formula, so a name
+// or a title written by a user is shown as text when the export is opened
+// in a spreadsheet rather than run
+func csvCell(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
@@ -0,0 +1,39 @@
+package view
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRenderCSV(t *testing.T) {
+	w := httptest.NewRecorder()
+	RenderCSV(w, "export.csv", []string{"name", "author"}, [][]string{
+		{"=HYPERLINK(\"http://example.com\")", "+alice"},
+		{"-1+2", "@bob"},
+		{"keygenme", "carol"},
+		{"\tcmd", ""},
+	})
+
+	want := "name,author\n" +
+		"\"'=HYPERLINK(\"\"http://example.com\"\")\",'+alice\n" +
+		"'-1+2,'@bob\n" +
+		"keygenme,carol\n" +
+		"'\tcmd,\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRenderCSVFilename(t *testing.T) {
+	for name, want := range map[string]string{
+		"solves-alice.csv":              `attachment; filename=solves-alice.csv`,
+		"solves-a\"; b=c.csv":           `attachment; filename="solves-a\"; b=c.csv"`,
+		"solves-a\r\nSet-Cookie: x.csv": `attachment; filename*=utf-8''solves-a%0D%0ASet-Cookie%3A%20x.csv`,
+	} {
+		w := httptest.NewRecorder()
+		RenderCSV(w, name, []string{"name"}, nil)
+		if got := w.Header().Get("Content-Disposition"); got != want {
+			t.Errorf("%q: got %s, want %s", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package view
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "net/http"
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/crackmesone/crackmes.one/app/shared/session"
+)
+
+// The bounds of the kept forms, in bytes of their fields
+const (
+    keptMaxForm  = 256 << 10 // A kept form, the longest writeup fits
+    keptMaxTotal = 32 << 20  // All the kept forms
+)
+
+// keptForms holds the fields of the forms refused for an expired session or
+// token, by id, out of the session cookie which can't hold long texts
+var keptForms = newKeptStore(15 * time.Minute)
+
+// KeptFormKey is the key of the id of the kept form in the session, and in
+// the variables of the views
+const KeptFormKey = "keptform"
+
+// keptSkipped are the fields never kept, the passwords aren't either. The
+// idempotency key of an upload is, its retry must send the same one.
+var keptSkipped = map[string]bool{"token": true, "g-recaptcha-response": true}
+
+// KeepForm keeps the fields posted with the request, but the passwords and
+// the tokens, for the next form of the visitor refilled with Repopulate. The
+// caller saves the session.
+//
+// Only the forms posted from the site are kept, a page elsewhere can't fill
+// the forms of a visitor, and only for the visitors signed in or whose
+// session expired. The forms too long are dropped, as are the new ones once
+// the kept forms fill keptMaxTotal.
+func KeepForm(r *http.Request) {
+    sess := session.Instance(r)
+    if sess.Values["name"] == nil {
+        if _, err := r.Cookie(session.Name); err != nil {
+            return
+        }
+    }
+    if !sameOrigin(r) {
+        return
+    }
+
+    // Parses the urlencoded forms too
+    r.ParseMultipartForm(keptMaxForm)
+
+    values := url.Values{}
+    for name, value := range r.PostForm {
+        if !keptSkipped[name] && !strings.Contains(name, "password") {
+            values[name] = value
+        }
+    }
+    if len(values) == 0 {
+        return
+    }
+
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        return
+    }
+    id := hex.EncodeToString(b)
+    if keptForms.Set(id, values) {
+        sess.Values[KeptFormKey] = id
+    }
+}
+
+// sameOrigin returns true if the request was sent by a page of the site,
+// according to its Origin header or else its Referer
+func sameOrigin(r *http.Request) bool {
+    from := r.Header.Get("Origin")
+    if from == "" {
+        from = r.Referer()
+    }
+    u, err := url.Parse(from)
+    return err == nil && u.Host != "" && u.Host == r.Host
+}
+
+// keptForm returns the fields kept under the id, nil once expired or used
+func keptForm(id interface{}) url.Values {
+    s, _ := id.(string)
+    if values, ok := keptForms.Get(s); ok {
+        return values
+    }
+    return nil
+}
+
+// KeptValue returns a field of the form kept for the view variables, empty if
+// none is. It doesn't use the kept form, Repopulate still refills the next
+// form with it.
+func KeptValue(vars map[string]interface{}, name string) string {
+    return keptForm(vars[KeptFormKey]).Get(name)
+}
+
+// keptStore holds the kept forms for a while, within keptMaxTotal bytes
+type keptStore struct {
+    ttl   time.Duration
+    mutex sync.Mutex
+    forms map[string]keptEntry
+    size  int
+}
+
+type keptEntry struct {
+    values  url.Values
+    size    int
+    expires time.Time
+}
+
+func newKeptStore(ttl time.Duration) *keptStore {
+    return &keptStore{ttl: ttl, forms: make(map[string]keptEntry)}
+}
+
+// Set keeps the fields under the id, it returns false if they are too long
+// or there is no room left for them
+func (s *keptStore) Set(id string, values url.Values) bool {
+    size := 0
+    for name, value := range values {
+        for _, v := range value {
+            size += len(name) + len(v)
+        }
+    }
+    if size > keptMaxForm {
+        return false
+    }
+
+    now := time.Now()
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    for k, e := range s.forms {
+        if now.After(e.expires) {
+            s.size -= e.size
+            delete(s.forms, k)
+        }
+    }
+    if s.size+size > keptMaxTotal {
+        return false
+    }
+    s.forms[id] = keptEntry{values: values, size: size, expires: now.Add(s.ttl)}
+    s.size += size
+    return true
+}
+
+// Get returns the fields kept under the id, unless they expired
+func (s *keptStore) Get(id string) (url.Values, bool) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    e, ok := s.forms[id]
+    if !ok || time.Now().After(e.expires) {
+        return nil, false
+    }
+    return e.values, true
+}
+
+// Delete forgets the fields kept under the id
+func (s *keptStore) Delete(id string) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    if e, ok := s.forms[id]; ok {
+        s.size -= e.size
+        delete(s.forms, id)
+    }
+}
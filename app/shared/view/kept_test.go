@@ -0,0 +1,91 @@
+package view
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crackmesone/crackmes.one/app/shared/session"
+)
+
+func TestRepopulateKept(t *testing.T) {
+	keptForms.Set("id", url.Values{"comment": {"Kept comment"}})
+
+	// A form without the kept fields leaves them for the next one
+	dst := map[string]interface{}{KeptFormKey: "id"}
+	Repopulate([]string{"email"}, url.Values{}, dst)
+	if dst["email"] != "" {
+		t.Errorf("got email %q, want none", dst["email"])
+	}
+
+	dst = map[string]interface{}{KeptFormKey: "id"}
+	Repopulate([]string{"comment", "hint"}, url.Values{"hint": {"Posted hint"}}, dst)
+	if dst["comment"] != "Kept comment" || dst["hint"] != "Posted hint" {
+		t.Errorf("got %q and %q, want the kept comment and the posted hint", dst["comment"], dst["hint"])
+	}
+
+	// The kept fields refill a single form
+	dst = map[string]interface{}{KeptFormKey: "id"}
+	Repopulate([]string{"comment"}, url.Values{}, dst)
+	if dst["comment"] != "" {
+		t.Errorf("got comment %q, want none", dst["comment"])
+	}
+}
+
+func TestKeepForm(t *testing.T) {
+	session.Configure(session.Session{Name: "test", SecretKey: "test"})
+
+	post := func(origin string, cookie bool, form url.Values) *http.Request {
+		r := httptest.NewRequest("POST", "http://example.com/comment/abc", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		if cookie {
+			r.AddCookie(&http.Cookie{Name: "test", Value: "expired"})
+		}
+		return r
+	}
+	kept := func(r *http.Request) url.Values {
+		return keptForm(session.Instance(r).Values[KeptFormKey])
+	}
+	form := url.Values{"comment": {"Long comment"}, "token": {"t"}, "password": {"p"}}
+
+	r := post("http://example.com", true, form)
+	KeepForm(r)
+	if got := kept(r); got.Get("comment") != "Long comment" || got.Get("token") != "" || got.Get("password") != "" {
+		t.Errorf("kept %v, want the comment only", got)
+	}
+
+	for name, r := range map[string]*http.Request{
+		"cross-site": post("http://evil.example", true, form),
+		"no origin":  post("", true, form),
+		"anonymous":  post("http://example.com", false, form),
+		"too long":   post("http://example.com", true, url.Values{"comment": {strings.Repeat("a", keptMaxForm)}}),
+	} {
+		KeepForm(r)
+		if got := kept(r); got != nil {
+			t.Errorf("%s: kept %v, want nothing", name, got)
+		}
+	}
+}
+
+func TestKeptTotal(t *testing.T) {
+	s := newKeptStore(time.Minute)
+	form := url.Values{"info": {strings.Repeat("a", keptMaxForm-10)}}
+	n := 0
+	for s.Set(strconv.Itoa(n), form) {
+		n++
+	}
+	if n != keptMaxTotal/keptMaxForm {
+		t.Errorf("kept %d forms, want %d", n, keptMaxTotal/keptMaxForm)
+	}
+	s.Delete("0")
+	if !s.Set("again", form) {
+		t.Error("no room once a form is deleted")
+	}
+}
@@ -0,0 +1,96 @@
+package plugin
+
+import (
+    "fmt"
+    "html/template"
+    "time"
+
+    "github.com/crackmesone/crackmes.one/app/shared/markdown"
+    "github.com/crackmesone/crackmes.one/app/shared/view"
+)
+
+// Humanize returns a template.FuncMap
+// * HUMANTIME outputs how long ago a date is, or the date past 30 days, piped
+//   as {{.CreatedAt | HUMANTIME $.clock}}
+// * PLURAL outputs a count with its noun, {{PLURAL 2 "note"}} is "2 notes",
+//   an irregular plural is given after the singular
+// * BYTESIZE outputs a size like "1.5 MB"
+// * TRUNCATE cuts a text to a number of characters, ending it with "..."
+// * MARKDOWN renders a text written in markdown
+// * TEXT renders a plain text keeping its line breaks, with its fenced code
+//   blocks highlighted
+func Humanize() template.FuncMap {
+    f := make(template.FuncMap)
+
+    f["HUMANTIME"] = func(c view.Clock, t time.Time) string {
+        c.Relative = true
+        return c.Format(t, time.Now())
+    }
+
+    f["PLURAL"] = func(n int, forms ...string) string {
+        return Plural(n, forms...)
+    }
+
+    f["BYTESIZE"] = func(size int64) string {
+        return ByteSize(size)
+    }
+
+    f["TRUNCATE"] = func(s string, n int) string {
+        return Truncate(s, n)
+    }
+
+    f["MARKDOWN"] = func(s string) template.HTML {
+        return markdown.Render(s)
+    }
+
+    f["TEXT"] = func(s string) template.HTML {
+        return markdown.Text(s)
+    }
+
+    return f
+}
+
+// Plural returns a count followed by its noun, singular or plural. The plural
+// adds an "s" to the singular unless given after it.
+func Plural(n int, forms ...string) string {
+    if len(forms) == 0 {
+        return fmt.Sprint(n)
+    }
+    noun := forms[0]
+    if n != 1 && n != -1 {
+        if len(forms) > 1 {
+            noun = forms[1]
+        } else {
+            noun += "s"
+        }
+    }
+    return fmt.Sprintf("%d %s", n, noun)
+}
+
+// ByteSize returns a size in bytes, KB, MB or GB of 1000 bytes like the
+// upload limits
+func ByteSize(size int64) string {
+    if size < 1000 {
+        return Plural(int(size), "byte")
+    }
+    value := float64(size)
+    for _, unit := range []string{"KB", "MB", "GB"} {
+        value /= 1000
+        if value < 1000 || unit == "GB" {
+            return fmt.Sprintf("%.1f %s", value, unit)
+        }
+    }
+    return ""
+}
+
+// Truncate cuts s to n characters, the last three being "...", if longer
+func Truncate(s string, n int) string {
+    runes := []rune(s)
+    if len(runes) <= n {
+        return s
+    }
+    if n <= 3 {
+        return string(runes[:n])
+    }
+    return string(runes[:n-3]) + "..."
+}
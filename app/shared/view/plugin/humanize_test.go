@@ -0,0 +1,51 @@
+package plugin
+
+import "testing"
+
+func TestPlural(t *testing.T) {
+	tests := []struct {
+		n     int
+		forms []string
+		want  string
+	}{
+		{1, []string{"note"}, "1 note"},
+		{0, []string{"note"}, "0 notes"},
+		{3, []string{"note"}, "3 notes"},
+		{2, []string{"reply", "replies"}, "2 replies"},
+	}
+	for _, tt := range tests {
+		if got := Plural(tt.n, tt.forms...); got != tt.want {
+			t.Errorf("Plural(%d, %v): got %q, want %q", tt.n, tt.forms, got, tt.want)
+		}
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	tests := []struct {
+		size int64
+		want string
+	}{
+		{1, "1 byte"},
+		{999, "999 bytes"},
+		{1500, "1.5 KB"},
+		{5000000, "5.0 MB"},
+		{7300000000, "7.3 GB"},
+	}
+	for _, tt := range tests {
+		if got := ByteSize(tt.size); got != tt.want {
+			t.Errorf("ByteSize(%d): got %q, want %q", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := Truncate("short", 10); got != "short" {
+		t.Errorf("got %q", got)
+	}
+	if got := Truncate("crackmes.one", 8); got != "crack..." {
+		t.Errorf("got %q", got)
+	}
+	if got := Truncate("été à l'eau", 5); got != "ét..." {
+		t.Errorf("got %q", got)
+	}
+}
@@ -3,10 +3,14 @@ package plugin
 import (
     "html/template"
     "time"
+
+    "github.com/crackmesone/crackmes.one/app/shared/view"
 )
 
 // PrettyTime returns a template.FuncMap
 // * PRETTYTIME outputs a nice time format
+// * LOCALTIME outputs it in the timezone of the viewer, piped as
+//   {{.CreatedAt | LOCALTIME $.clock}}
 func PrettyTime() template.FuncMap {
     f := make(template.FuncMap)
 
@@ -18,5 +22,9 @@ func PrettyTime() template.FuncMap {
         return t.Format(format)
     }
 
+    f["LOCALTIME"] = func(c view.Clock, t time.Time) string {
+        return c.Format(t, time.Now())
+    }
+
     return f
 }
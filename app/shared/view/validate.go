@@ -0,0 +1,145 @@
+package view
+
+import (
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+
+    "github.com/gorilla/context"
+)
+
+// Charsets of the fields
+const (
+    // CharsName are the characters of the user names and the emails
+    CharsName = authorizedChars
+)
+
+// Rule is the checks of a form field, built with Field:
+//
+//     view.Field("name").Required().MaxLen(32).Chars(view.CharsName)
+type Rule struct {
+    name     string
+    label    string
+    required bool
+    maxLen   int
+    chars    string
+    choices  []string
+    ranged   bool
+    min, max int
+}
+
+// Field returns the rule of a form field, which accepts anything until
+// checks are added
+func Field(name string) *Rule {
+    return &Rule{name: name, label: name}
+}
+
+// Label names the field in the error messages, its form name by default
+func (f *Rule) Label(label string) *Rule {
+    f.label = label
+    return f
+}
+
+// Required refuses an empty field
+func (f *Rule) Required() *Rule {
+    f.required = true
+    return f
+}
+
+// MaxLen refuses a field longer than n characters
+func (f *Rule) MaxLen(n int) *Rule {
+    f.maxLen = n
+    return f
+}
+
+// Chars refuses a field with characters out of set
+func (f *Rule) Chars(set string) *Rule {
+    f.chars = set
+    return f
+}
+
+// OneOf refuses a field which is none of the choices
+func (f *Rule) OneOf(choices []string) *Rule {
+    f.choices = choices
+    return f
+}
+
+// Range refuses a field which is not an integer from min to max
+func (f *Rule) Range(min, max int) *Rule {
+    f.ranged, f.min, f.max = true, min, max
+    return f
+}
+
+// check returns the problem of a value, "" if it passes
+func (f *Rule) check(value string) string {
+    if value == "" {
+        if f.required {
+            return "Field missing: " + f.label
+        }
+        return ""
+    }
+    if f.maxLen > 0 && utf8.RuneCountInString(value) > f.maxLen {
+        return fmt.Sprintf("The %s is too long, %d characters at most.", f.label, f.maxLen)
+    }
+    if f.chars != "" {
+        for _, c := range value {
+            if !strings.ContainsRune(f.chars, c) {
+                return fmt.Sprintf("The %s has characters which are not allowed: %q.", f.label, c)
+            }
+        }
+    }
+    if f.choices != nil {
+        known := false
+        for _, c := range f.choices {
+            known = known || c == value
+        }
+        if !known {
+            return fmt.Sprintf("Please choose the %s from the list.", f.label)
+        }
+    }
+    if f.ranged {
+        n, err := strconv.Atoi(value)
+        if err != nil || n < f.min || n > f.max {
+            return fmt.Sprintf("The %s must be from %d to %d.", f.label, f.min, f.max)
+        }
+    }
+    return ""
+}
+
+// FieldErrors are the problems of the fields of a form, by field name
+type FieldErrors map[string]string
+
+// fieldErrorsKey is the key of the field errors in the request context
+const fieldErrorsKey = "fielderrors"
+
+// Check returns true if the form values of the request pass the rules, else
+// the problem of the first field failing, for a flash message. Like Validate,
+// with the problems of every field also kept with the request, so the views
+// created for it afterwards show them next to their field, in the "errors"
+// variable.
+func Check(req *http.Request, rules ...*Rule) (bool, string) {
+    errs := FieldErrors{}
+    first := ""
+    for _, f := range rules {
+        if msg := f.check(req.FormValue(f.name)); msg != "" {
+            errs[f.name] = msg
+            if first == "" {
+                first = msg
+            }
+        }
+    }
+    if first != "" {
+        context.Set(req, fieldErrorsKey, errs)
+    }
+    return first == "", first
+}
+
+// fieldErrors returns the problems found by Check for the request
+func fieldErrors(req *http.Request) FieldErrors {
+    if errs, ok := context.Get(req, fieldErrorsKey).(FieldErrors); ok {
+        return errs
+    }
+    return FieldErrors{}
+}
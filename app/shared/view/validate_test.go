@@ -0,0 +1,55 @@
+package view
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/context"
+)
+
+func TestCheck(t *testing.T) {
+	rules := []*Rule{
+		Field("name").Required().MaxLen(8).Chars(CharsName),
+		Field("difficulty").Label("difficulty rating").Required().Range(1, 6),
+		Field("info").MaxLen(4),
+		Field("arch").OneOf([]string{"x86", "ARM"}),
+	}
+
+	tests := []struct {
+		form  url.Values
+		field string
+		want  string
+	}{
+		{url.Values{"name": {"bob"}, "difficulty": {"3"}}, "", ""},
+		{url.Values{"difficulty": {"3"}}, "name", "Field missing: name"},
+		{url.Values{"name": {"bobbybobby"}, "difficulty": {"3"}}, "name", "too long"},
+		{url.Values{"name": {"bob bob"}, "difficulty": {"3"}}, "name", "not allowed"},
+		{url.Values{"name": {"bob"}, "difficulty": {"7"}}, "difficulty", "difficulty rating must be from 1 to 6"},
+		{url.Values{"name": {"bob"}, "difficulty": {"x"}}, "difficulty", "must be from"},
+		{url.Values{"name": {"bob"}, "difficulty": {"3"}, "info": {"héllo"}}, "info", "4 characters at most"},
+		{url.Values{"name": {"bob"}, "difficulty": {"3"}, "arch": {"ARM"}}, "", ""},
+		{url.Values{"name": {"bob"}, "difficulty": {"3"}, "arch": {"sparc"}}, "arch", "choose the arch"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(tt.form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		ok, msg := Check(r, rules...)
+		errs := fieldErrors(r)
+		context.Clear(r)
+		if tt.want == "" {
+			if !ok || len(errs) != 0 {
+				t.Errorf("%v: %q %v, expected no problem", tt.form, msg, errs)
+			}
+			continue
+		}
+		if ok || !strings.Contains(msg, tt.want) {
+			t.Errorf("%v: %q, expected %q", tt.form, msg, tt.want)
+		}
+		if errs[tt.field] != msg {
+			t.Errorf("%v: %v, expected the problem on %s", tt.form, errs, tt.field)
+		}
+	}
+}
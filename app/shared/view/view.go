@@ -1,6 +1,7 @@
 package view
 
 import (
+    "bytes"
     "encoding/gob"
     "encoding/json"
     "fmt"
@@ -143,6 +144,22 @@ func New(req *http.Request) *View {
         v.Vars["usersess"] = sess.Values["name"]
     }
 
+    // The dates are shown in the timezone of the viewer
+    v.Vars["clock"] = clockOf(sess)
+
+    // The problems of the fields of a form sent back, see Check
+    v.Vars["errors"] = fieldErrors(req)
+
+    // The fields of a form refused for an expired token, see KeepForm
+    if id, ok := sess.Values[KeptFormKey]; ok {
+        v.Vars[KeptFormKey] = id
+    }
+
+    // Admins viewing the site as a user get a banner to stop
+    if sess.Values["impersonator"] != nil {
+        v.Vars["impersonator"] = sess.Values["impersonator"]
+    }
+
     return v
 }
 
@@ -243,8 +260,9 @@ func (v *View) RenderSingle(w http.ResponseWriter) {
     }
 }
 
-// Render renders a template to the writer
-func (v *View) Render(w http.ResponseWriter) {
+// collection returns the templates of the view, parsed on the first use when
+// caching is enabled
+func (v *View) collection() (*template.Template, error) {
 
     // Get the template collection from cache
     mutex.RLock()
@@ -270,8 +288,7 @@ func (v *View) Render(w http.ResponseWriter) {
             // Get the absolute path of the root template
             path, err := filepath.Abs(v.Folder + string(os.PathSeparator) + name + "." + v.Extension)
             if err != nil {
-                http.Error(w, "Template Path Error: "+err.Error(), http.StatusInternalServerError)
-                return
+                return nil, fmt.Errorf("Template Path Error: %v", err)
             }
             templateList[i] = path
         }
@@ -280,8 +297,7 @@ func (v *View) Render(w http.ResponseWriter) {
         templates, err := template.New(v.Name).Funcs(pc).ParseFiles(templateList...)
 
         if err != nil {
-            http.Error(w, "Template Parse Error: "+err.Error(), http.StatusInternalServerError)
-            return
+            return nil, fmt.Errorf("Template Parse Error: %v", err)
         }
 
         // Cache the template collection
@@ -293,6 +309,33 @@ func (v *View) Render(w http.ResponseWriter) {
         tc = templates
     }
 
+    return tc.Funcs(pc), nil
+}
+
+// Fragment renders a template of the view defined with {{define}}, for the
+// handlers caching parts of their pages. The result is given to the page in
+// a variable.
+func (v *View) Fragment(name string) (template.HTML, error) {
+    tc, err := v.collection()
+    if err != nil {
+        return "", err
+    }
+
+    var buf bytes.Buffer
+    if err := tc.ExecuteTemplate(&buf, name, v.Vars); err != nil {
+        return "", err
+    }
+    return template.HTML(buf.String()), nil
+}
+
+// Render renders a template to the writer
+func (v *View) Render(w http.ResponseWriter) {
+    tc, err := v.collection()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+
     // Get session
     sess := session.Instance(v.request)
 
@@ -312,7 +355,7 @@ func (v *View) Render(w http.ResponseWriter) {
     }
 
     // Display the content to the screen
-    err := tc.Funcs(pc).ExecuteTemplate(w, rootTemplate+"."+v.Extension, v.Vars)
+    err = tc.ExecuteTemplate(w, rootTemplate+"."+v.Extension, v.Vars)
 
     if err != nil {
         http.Error(w, "Template File Error: "+err.Error(), http.StatusInternalServerError)
@@ -371,10 +414,23 @@ func peekFlashes(w http.ResponseWriter, r *http.Request) []Flash {
     return v
 }
 
-// Repopulate updates the dst map so the form fields can be refilled
+// Repopulate updates the dst map so the form fields can be refilled, with
+// the fields of the form refused for an expired token first when dst holds
+// the variables of a view. The kept form refills the first form with one of
+// its fields.
 func Repopulate(list []string, src url.Values, dst map[string]interface{}) {
+    kept := keptForm(dst[KeptFormKey])
+    used := false
     for _, v := range list {
-        dst[v] = src.Get(v)
+        if _, ok := kept[v]; ok {
+            dst[v] = kept.Get(v)
+            used = true
+        } else {
+            dst[v] = src.Get(v)
+        }
+    }
+    if used {
+        keptForms.Delete(dst[KeptFormKey].(string))
     }
 }
 
@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"text/template"
+)
+
+// telegramAPI is the base URL of the Telegram Bot API
+var telegramAPI = "https://api.telegram.org"
+
+// defaultTemplates are the announcement messages used when a sender does not
+// configure its own for an event type
+var defaultTemplates = map[string]string{
+	EventCrackmeApproved: `New crackme: {{.Crackme.Name}} by {{.Crackme.Author}}` +
+		` [{{.Crackme.Platform}} - {{.Crackme.Lang}} - {{difficulty .Crackme.Difficulty}}] {{.Crackme.URL}}`,
+	EventSolutionApproved: `New writeup by {{.Solution.Author}} for {{.Crackme.Name}}` +
+		` [{{difficulty .Crackme.Difficulty}}] {{.Crackme.URL}}`,
+}
+
+var difficulties = []string{"Very Easy", "Easy", "Medium", "Hard", "Very Hard", "Insane"}
+
+var templateFuncs = template.FuncMap{
+	// difficulty turns a 1-6 rating into its label
+	"difficulty": func(d float64) string {
+		i := int(d+0.5) - 1
+		if i < 0 || i >= len(difficulties) {
+			return fmt.Sprintf("%.1f", d)
+		}
+		return difficulties[i]
+	},
+}
+
+// AnnounceInfo configures the message templates of an announcement sender.
+// Templates maps event types to text/template sources executed on the Event,
+// the default templates are used for the missing ones.
+type AnnounceInfo struct {
+	Templates map[string]string
+}
+
+// DiscordInfo is a Discord channel webhook
+type DiscordInfo struct {
+	AnnounceInfo
+	URL string
+}
+
+// TelegramInfo is a Telegram bot posting to a chat
+type TelegramInfo struct {
+	AnnounceInfo
+	Token  string
+	ChatID string
+}
+
+// Message renders the announcement of the event
+func (a AnnounceInfo) Message(e Event) (string, error) {
+	src, ok := a.Templates[e.Type]
+	if !ok {
+		src, ok = defaultTemplates[e.Type]
+	}
+	if !ok {
+		return "", fmt.Errorf("no template for event %s", e.Type)
+	}
+
+	t, err := template.New(e.Type).Funcs(templateFuncs).Parse(src)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err = t.Execute(&buf, e); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// DiscordSender posts announcements to a Discord webhook
+type DiscordSender struct {
+	DiscordInfo
+}
+
+// Send posts the announcement of the event
+func (d DiscordSender) Send(e Event) error {
+	msg, err := d.Message(e)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]string{"content": msg})
+	if err != nil {
+		return err
+	}
+	return Post(d.URL, "application/json", body, nil)
+}
+
+// TelegramSender posts announcements to a Telegram chat through a bot
+type TelegramSender struct {
+	TelegramInfo
+}
+
+// Send posts the announcement of the event
+func (t TelegramSender) Send(e Event) error {
+	msg, err := t.Message(e)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]string{"chat_id": t.ChatID, "text": msg})
+	if err != nil {
+		return err
+	}
+	return Post(telegramAPI+"/bot"+t.Token+"/sendMessage", "application/json", body, nil)
+}
@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testEvent = Event{
+	Type: EventCrackmeApproved,
+	Crackme: Crackme{
+		Name:       "keygenme",
+		Author:     "alice",
+		Lang:       "C/C++",
+		Platform:   "Windows",
+		Difficulty: 3.2,
+		URL:        "https://crackmes.one/crackme/abc",
+	},
+}
+
+func TestMessageDefault(t *testing.T) {
+	msg, err := AnnounceInfo{}.Message(testEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "New crackme: keygenme by alice [Windows - C/C++ - Medium] https://crackmes.one/crackme/abc"
+	if msg != want {
+		t.Errorf("Message() = %q, want %q", msg, want)
+	}
+}
+
+func TestMessageCustom(t *testing.T) {
+	a := AnnounceInfo{Templates: map[string]string{
+		EventCrackmeApproved: "{{.Crackme.Name}} ({{difficulty .Crackme.Difficulty}})",
+	}}
+
+	msg, err := a.Message(testEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg != "keygenme (Medium)" {
+		t.Errorf("Message() = %q", msg)
+	}
+}
+
+func TestTelegramSender(t *testing.T) {
+	var path string
+	var payload map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer ts.Close()
+
+	old := telegramAPI
+	telegramAPI = ts.URL
+	defer func() { telegramAPI = old }()
+
+	s := TelegramSender{TelegramInfo{Token: "123:abc", ChatID: "@crackmes"}}
+	if err := s.Send(testEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	if path != "/bot123:abc/sendMessage" {
+		t.Errorf("Unexpected path %s", path)
+	}
+	if payload["chat_id"] != "@crackmes" || payload["text"] == "" {
+		t.Errorf("Unexpected payload %v", payload)
+	}
+}
+
+// TestAnnounceWait fires an approval as the -approve flags of validate.py do:
+// the announcement is posted before Wait returns and the command exits
+func TestAnnounceWait(t *testing.T) {
+	var content string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		content = payload["content"]
+	}))
+	defer ts.Close()
+
+	Configure(Info{Enabled: true, Discord: []DiscordInfo{{URL: ts.URL}}})
+	defer func() {
+		mutex.Lock()
+		info, senders = Info{}, nil
+		mutex.Unlock()
+	}()
+
+	Fire(testEvent)
+	if !Wait(5 * time.Second) {
+		t.Fatal("Announcement still pending")
+	}
+	if content == "" {
+		t.Error("Nothing announced")
+	}
+}
@@ -0,0 +1,243 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// EventCrackmeApproved is fired when a crackme becomes visible
+	EventCrackmeApproved = "crackme.approved"
+	// EventSolutionApproved is fired when a solution becomes visible
+	EventSolutionApproved = "solution.approved"
+
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of the body
+	SignatureHeader = "X-Crackmes-Signature"
+)
+
+var (
+	info    Info
+	senders []Sender
+	queue   chan job
+	mutex   sync.RWMutex
+	client  = &http.Client{Timeout: 10 * time.Second}
+
+	// pending counts the deliveries queued or being sent, see Wait
+	pending int64
+)
+
+// Info contains the webhook configuration
+type Info struct {
+	Enabled   bool           // Fire events at all
+	Secret    string         // Key of the HMAC signing the payloads
+	URLs      []string       // Endpoints receiving every event
+	Discord   []DiscordInfo  // Discord channels announcing every event
+	Telegram  []TelegramInfo // Telegram chats announcing every event
+	Retries   int            // Number of retries of a failed delivery
+	QueueSize int            // Number of deliveries waiting before events are dropped
+}
+
+// Crackme describes the crackme an event is about
+type Crackme struct {
+	HexId      string  `json:"hexid"`
+	Name       string  `json:"name"`
+	Author     string  `json:"author"`
+	Lang       string  `json:"lang"`
+	Arch       string  `json:"arch"`
+	Platform   string  `json:"platform"`
+	Difficulty float64 `json:"difficulty"`
+	URL        string  `json:"url"`
+}
+
+// Solution describes the solution an event is about
+type Solution struct {
+	HexId  string `json:"hexid"`
+	Author string `json:"author"`
+}
+
+// Event is the payload delivered to the webhooks
+type Event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Crackme  Crackme   `json:"crackme"`
+	Solution *Solution `json:"solution,omitempty"`
+}
+
+// Sender delivers an event somewhere
+type Sender interface {
+	Send(e Event) error
+}
+
+// job is one delivery of an event to a sender
+type job struct {
+	sender  Sender
+	event   Event
+	attempt int
+}
+
+// Configure stores the settings, registers a signed HTTP sender per
+// configured URL and an announcement sender per Discord and Telegram entry,
+// then starts the delivery worker
+func Configure(c Info) {
+	if c.QueueSize <= 0 {
+		c.QueueSize = 100
+	}
+
+	mutex.Lock()
+	info = c
+	for _, url := range c.URLs {
+		senders = append(senders, HTTPSender{URL: url, Secret: c.Secret})
+	}
+	for _, d := range c.Discord {
+		senders = append(senders, DiscordSender{d})
+	}
+	for _, t := range c.Telegram {
+		senders = append(senders, TelegramSender{t})
+	}
+	if queue == nil {
+		queue = make(chan job, c.QueueSize)
+		go work()
+	}
+	mutex.Unlock()
+}
+
+// ReadConfig returns the webhook settings
+func ReadConfig() Info {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return info
+}
+
+// Register adds a sender receiving every event
+func Register(s Sender) {
+	mutex.Lock()
+	senders = append(senders, s)
+	mutex.Unlock()
+}
+
+// Fire queues the event for delivery to every sender. It never blocks: if the
+// queue is full the delivery is dropped and logged.
+func Fire(e Event) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if !info.Enabled || queue == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	for _, s := range senders {
+		enqueue(job{sender: s, event: e})
+	}
+}
+
+func enqueue(j job) {
+	atomic.AddInt64(&pending, 1)
+	select {
+	case queue <- j:
+	default:
+		atomic.AddInt64(&pending, -1)
+		log.Println("Webhook queue full, dropping", j.event.Type)
+	}
+}
+
+// work delivers the queued events, scheduling a retry with an exponential
+// backoff when a delivery fails
+func work() {
+	for j := range queue {
+		deliver(j)
+		atomic.AddInt64(&pending, -1)
+	}
+}
+
+// deliver sends a queued event, scheduling a retry if it fails
+func deliver(j job) {
+	err := j.sender.Send(j.event)
+	if err == nil {
+		return
+	}
+
+	if j.attempt >= ReadConfig().Retries {
+		log.Println("Webhook delivery failed, giving up:", err)
+		return
+	}
+
+	log.Println("Webhook delivery failed, retrying:", err)
+	retry := job{sender: j.sender, event: j.event, attempt: j.attempt + 1}
+	time.AfterFunc(time.Duration(1<<uint(j.attempt))*time.Minute, func() {
+		enqueue(retry)
+	})
+}
+
+// Wait waits, at most for the timeout, until every queued delivery was tried,
+// for the commands firing events right before they exit. It returns false if
+// some were not. The retries scheduled after a failure are not waited for.
+func Wait(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for atomic.LoadInt64(&pending) > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return true
+}
+
+// HTTPSender posts the events as JSON to a URL, signed with an HMAC-SHA256 of
+// the body in the SignatureHeader
+type HTTPSender struct {
+	URL    string
+	Secret string
+}
+
+// Send posts the event
+func (h HTTPSender) Send(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return Post(h.URL, "application/json", body, map[string]string{
+		SignatureHeader: "sha256=" + Sign(h.Secret, body),
+	})
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of body keyed with secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Post sends body to url and fails on any non 2xx answer
+func Post(url, contentType string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s answered %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	// Reference value from RFC 4231 test case 2
+	got := Sign("Jefe", []byte("what do ya want for nothing?"))
+	want := "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+}
+
+func TestHTTPSender(t *testing.T) {
+	var signature string
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(SignatureHeader)
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer ts.Close()
+
+	s := HTTPSender{URL: ts.URL, Secret: "secret"}
+	if err := s.Send(Event{Type: EventCrackmeApproved}); err != nil {
+		t.Fatal(err)
+	}
+
+	if signature != "sha256="+Sign("secret", body) {
+		t.Error("Signature does not match the body")
+	}
+}
+
+func TestHTTPSenderError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	s := HTTPSender{URL: ts.URL}
+	if err := s.Send(Event{Type: EventCrackmeApproved}); err == nil {
+		t.Error("Expected an error")
+	}
+}
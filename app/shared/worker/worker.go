@@ -0,0 +1,84 @@
+// Package worker runs background jobs, such as the processing and the
+// previews of the uploads, one at a time per queue: they don't slow down the
+// requests, and a malformed upload panicking its job can't crash the server.
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+var (
+	// ErrStopped is returned when a job is pushed before the worker started
+	ErrStopped = errors.New("worker not started")
+	// ErrFull is returned when a job is pushed to a full queue
+	ErrFull = errors.New("queue full")
+)
+
+// Queue holds the jobs waiting for the worker
+type Queue struct {
+	name  string
+	size  int
+	jobs  chan func()
+	mutex sync.Mutex
+}
+
+// New returns a queue of up to size jobs, its name tells its failures apart
+// in the logs
+func New(name string, size int) *Queue {
+	return &Queue{name: name, size: size}
+}
+
+// Start runs the worker of the queue, once
+func (q *Queue) Start() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.jobs == nil {
+		q.jobs = make(chan func(), q.size)
+		go q.work()
+	}
+}
+
+// Push queues a job without blocking. It returns ErrStopped or ErrFull when
+// the job isn't queued, the caller runs it itself or skips it.
+func (q *Queue) Push(job func()) error {
+	q.mutex.Lock()
+	jobs := q.jobs
+	q.mutex.Unlock()
+
+	if jobs == nil {
+		return ErrStopped
+	}
+	select {
+	case jobs <- job:
+		return nil
+	default:
+		return ErrFull
+	}
+}
+
+// work runs the queued jobs one at a time
+func (q *Queue) work() {
+	for job := range q.jobs {
+		Do(q.name, func() error {
+			job()
+			return nil
+		})
+	}
+}
+
+// Do runs a job and returns its error, or its panic as an error once logged
+// with its stack
+func Do(name string, job func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("%s failed: %v\n%s", name, r, debug.Stack())
+			err = fmt.Errorf("%s panicked: %v", name, r)
+		}
+	}()
+	return job()
+}
@@ -0,0 +1,32 @@
+package worker
+
+import (
+	"testing"
+)
+
+func TestQueue(t *testing.T) {
+	q := New("Test", 1)
+	if err := q.Push(func() {}); err != ErrStopped {
+		t.Errorf("push before the start: got %v, want %v", err, ErrStopped)
+	}
+
+	q.Start()
+	block, done := make(chan bool), make(chan bool)
+	q.Push(func() { <-block })
+	q.Push(func() { panic("malformed") })
+	if err := q.Push(func() {}); err != ErrFull {
+		t.Errorf("push to a full queue: got %v, want %v", err, ErrFull)
+	}
+
+	// The worker survives the panic
+	close(block)
+	for q.Push(func() { close(done) }) != nil {
+	}
+	<-done
+}
+
+func TestDo(t *testing.T) {
+	if err := Do("Test", func() error { panic("malformed") }); err == nil {
+		t.Error("panic not reported")
+	}
+}
@@ -2,19 +2,42 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"log"
 	"os"
 	"runtime"
+	"time"
 
+	"github.com/crackmesone/crackmes.one/app/model"
 	"github.com/crackmesone/crackmes.one/app/route"
+	"github.com/crackmesone/crackmes.one/app/shared/backup"
+	"github.com/crackmesone/crackmes.one/app/shared/clientip"
+	"github.com/crackmesone/crackmes.one/app/shared/consistency"
+	"github.com/crackmesone/crackmes.one/app/shared/contentfilter"
 	"github.com/crackmesone/crackmes.one/app/shared/database"
-	"github.com/crackmesone/crackmes.one/app/shared/email"
+	"github.com/crackmesone/crackmes.one/app/shared/digest"
+	"github.com/crackmesone/crackmes.one/app/shared/download"
+	"github.com/crackmesone/crackmes.one/app/shared/drift"
+	"github.com/crackmesone/crackmes.one/app/shared/feature"
+	"github.com/crackmesone/crackmes.one/app/shared/gate"
+	"github.com/crackmesone/crackmes.one/app/shared/grace"
 	"github.com/crackmesone/crackmes.one/app/shared/jsonconfig"
+	"github.com/crackmesone/crackmes.one/app/shared/mail"
+	"github.com/crackmesone/crackmes.one/app/shared/moderation"
+	"github.com/crackmesone/crackmes.one/app/shared/preview"
+	"github.com/crackmesone/crackmes.one/app/shared/processing"
+	"github.com/crackmesone/crackmes.one/app/shared/rating"
 	"github.com/crackmesone/crackmes.one/app/shared/recaptcha"
+	"github.com/crackmesone/crackmes.one/app/shared/retention"
+	"github.com/crackmesone/crackmes.one/app/shared/seed"
 	"github.com/crackmesone/crackmes.one/app/shared/server"
 	"github.com/crackmesone/crackmes.one/app/shared/session"
+	"github.com/crackmesone/crackmes.one/app/shared/shadow"
+	"github.com/crackmesone/crackmes.one/app/shared/site"
+	"github.com/crackmesone/crackmes.one/app/shared/trending"
 	"github.com/crackmesone/crackmes.one/app/shared/view"
 	"github.com/crackmesone/crackmes.one/app/shared/view/plugin"
+	"github.com/crackmesone/crackmes.one/app/shared/webhook"
 )
 
 // *****************************************************************************
@@ -30,19 +53,232 @@ func init() {
 }
 
 func main() {
+	backupOnce := flag.Bool("backup", false, "take a snapshot of the database and the storage, then exit")
+	restoreDir := flag.String("restore", "", "restore the database from a snapshot directory, then exit")
+	force := flag.Bool("force", false, "restore even if the storage doesn't match the snapshot")
+	seedInfo := seed.Defaults()
+	seedOnce := flag.Bool("seed", false, "fill an empty database with synthetic data for load testing, then exit")
+	flag.IntVar(&seedInfo.Users, "seed-users", seedInfo.Users, "number of users generated by -seed")
+	flag.IntVar(&seedInfo.Crackmes, "seed-crackmes", seedInfo.Crackmes, "number of crackmes generated by -seed")
+	flag.IntVar(&seedInfo.Solutions, "seed-solutions", seedInfo.Solutions, "number of solutions generated by -seed")
+	flag.IntVar(&seedInfo.Comments, "seed-comments", seedInfo.Comments, "number of comments generated by -seed")
+	flag.Int64Var(&seedInfo.Seed, "seed-random", seedInfo.Seed, "seed of the random generator of -seed")
+	approveCrackme := flag.String("approve-crackme", "", "approve the pending crackme of this hexid, then exit")
+	approveSolution := flag.String("approve-solution", "", "approve the pending solution of this hexid, then exit")
+	previewFile := flag.String("preview", "", "print the preview of the upload stored at this path as JSON, then exit; run by the preview worker")
+	flag.Parse()
+
+	// The previews of the uploads are generated in a process of their own,
+	// without the configuration
+	if *previewFile != "" {
+		if err := preview.Main(*previewFile); err != nil {
+			log.Fatalln("Preview failed:", err)
+		}
+		return
+	}
+
 	// Load the configuration file
 	jsonconfig.Load("config"+string(os.PathSeparator)+"config.json", config)
 
+	// The external address of the site, for the links and the hosting below
+	// a subpath
+	site.Configure(config.Site)
+
+	// The networks of the addresses counted by the rate limits
+	clientip.Configure(config.ClientIP)
+
 	// Configure the session cookie store
 	session.Configure(config.Session)
 
 	// Connect to database
 	database.Connect(config.Database)
 
+	// Backups, from the command line or on a schedule
+	if *backupOnce {
+		dir, err := backup.Snapshot(config.Backup, model.BackupDatabase{})
+		if err != nil {
+			log.Fatalln("Backup failed:", err)
+		}
+		log.Println("Backup taken:", dir)
+		return
+	}
+	if *restoreDir != "" {
+		if err := backup.Restore(*restoreDir, config.Backup, model.BackupDatabase{}, *force); err != nil {
+			log.Fatalln("Restore failed:", err)
+		}
+		log.Println("Restored", *restoreDir)
+		return
+	}
+	if *seedOnce {
+		if err := model.Seed(seedInfo); err != nil {
+			log.Fatalln("Seed failed:", err)
+		}
+		return
+	}
+	if *approveCrackme != "" || *approveSolution != "" {
+		approve(*approveCrackme, *approveSolution)
+		return
+	}
+	if config.Backup.Enabled {
+		go backup.Schedule(config.Backup, model.BackupDatabase{})
+	}
+
+	// Feature flags, from the configuration then from the admins
+	feature.Configure(config.Features)
+	if err := model.FeatureLoad(); err != nil {
+		log.Println("Feature flags not loaded:", err)
+	}
+
+	// Compare the rewritten queries with the legacy ones
+	shadow.Configure(config.Shadow)
+
+	// Rules against the gaming of the ratings
+	rating.Configure(config.Rating)
+	if err := model.RatingEnsureIndexes(); err != nil {
+		log.Println("Unique indexes of the ratings not created:", err)
+	}
+
+	// Keep the new accounts from posting
+	gate.Configure(config.Gates)
+
+	// Filter the words and the links of the comments
+	contentfilter.Configure(config.Comments)
+
+	// Let the authors edit and withdraw what they just posted
+	grace.Configure(config.EditGrace)
+
+	// A single solution per user and crackme, a single pending crackme per
+	// user and name
+	if err := model.SubmissionEnsureIndexes(); err != nil {
+		log.Println("Unique indexes of the submissions not created:", err)
+	}
+
+	// A single reaction per user and comment
+	if err := model.ReactionEnsureIndexes(); err != nil {
+		log.Println("Unique index of the reactions not created:", err)
+	}
+
+	// The orders of the search results
+	if err := model.SearchEnsureIndexes(); err != nil {
+		log.Println("Indexes of the search not created:", err)
+	}
+
+	// The log of the events, read by date and type
+	if err := model.EventEnsureIndexes(); err != nil {
+		log.Println("Index of the events not created:", err)
+	}
+
+	// One upload per idempotency key, forgotten after a day
+	if err := model.IdempotencyEnsureIndexes(); err != nil {
+		log.Println("Indexes of the idempotency keys not created:", err)
+	}
+
+	// The short ids of the crackmes approved before they were given at
+	// approval, in the background not to delay the start
+	go func() {
+		if err := model.CrackmeShortIdsAssign(); err != nil {
+			log.Println("Short ids of the crackmes not assigned:", err)
+		}
+	}()
+
+	// The signatures of the solutions approved before they were signed at
+	// upload, compared to the new ones to flag copies
+	go func() {
+		if err := model.SolutionMinhashAssign(); err != nil {
+			log.Println("Signatures of the solutions not assigned:", err)
+		}
+	}()
+
+	// Cross-check the database and the storage on a schedule
+	if config.Consistency.Enabled {
+		go consistency.Schedule(config.Consistency, model.ConsistencyRun)
+	}
+
+	// Compare the denormalized fields with their sources on a schedule
+	if config.Drift.Enabled {
+		go drift.Schedule(config.Drift, model.DriftRun)
+	}
+
+	// One draft per user and upload form, forgotten after a month
+	if err := model.DraftEnsureIndexes(); err != nil {
+		log.Println("Indexes of the drafts not created:", err)
+	}
+
+	// Rank the crackmes by their recent activity for the home page
+	if err := model.TrendingEnsureIndexes(); err != nil {
+		log.Println("Indexes of the daily counters not created:", err)
+	}
+	if config.Trending.Enabled {
+		go trending.Schedule(config.Trending, model.TrendingRun)
+	}
+
+	// Remind the moderators of the stale submissions
+	moderation.Configure(config.Moderation)
+	if config.Moderation.Enabled {
+		go moderation.Schedule(moderation.ReadConfig(), model.ModerationRun)
+	}
+
+	// One invite code per person
+	if err := model.InviteEnsureIndexes(); err != nil {
+		log.Println("Indexes of the invite codes not created:", err)
+	}
+
+	// Text index of the writeups, for their search
+	if err := model.WriteupSearchEnsureIndexes(); err != nil {
+		log.Println("Indexes of the writeup search not created:", err)
+	}
+
+	// One record per device of a user
+	if err := model.SignInEnsureIndexes(); err != nil {
+		log.Println("Indexes of the sign-ins not created:", err)
+	}
+
+	// One team per name, whatever its case
+	if err := model.TeamEnsureIndexes(); err != nil {
+		log.Println("Indexes of the teams not created:", err)
+	}
+
+	// Delete the old notifications on a schedule
+	if err := model.NotificationEnsureIndexes(); err != nil {
+		log.Println("Indexes of the notifications not created:", err)
+	}
+	retention.Configure(config.Notifications)
+	if config.Notifications.Enabled {
+		go retention.Schedule(retention.ReadConfig(), model.NotificationsRetentionRun)
+	}
+
+	// Send the emails, and the weekly digests on a schedule
+	mail.Configure(config.Email)
+	if config.Digest.BaseURL == "" {
+		config.Digest.BaseURL = site.URL("")
+	}
+	digest.Configure(config.Digest)
+	if config.Digest.Enabled {
+		go digest.Schedule(digest.ReadConfig(), model.DigestRun)
+	}
+
 	// Configure the Google reCAPTCHA prior to loading view plugins
 	recaptcha.Configure(config.Recaptcha)
 
-	// Setup the views
+	// Configure the signed download URLs
+	download.Configure(config.Download)
+
+	// Operational settings set by the admins, over the configuration of the
+	// gates, the rate limits and the downloads
+	if err := model.SettingsLoad(); err != nil {
+		log.Println("Settings not loaded:", err)
+	}
+
+	// Configure the outgoing webhooks
+	webhook.Configure(config.Webhook)
+
+	// Start the workers processing the uploads and previewing them for the
+	// moderators
+	processing.Start()
+	preview.Start()
+
+	// Setup the views, their links below the path of the site
+	config.View.BaseURI = site.Path("/")
 	view.Configure(config.View)
 	view.LoadTemplates(config.Template.Root, config.Template.Children)
 	view.LoadPlugins(
@@ -50,12 +286,47 @@ func main() {
 		plugin.NoEscape(),
 		plugin.PrettyTime(),
 		plugin.TimeCompare(),
-		recaptcha.Plugin())
+		plugin.Humanize(),
+		recaptcha.Plugin(),
+		download.Plugin(),
+		site.Plugin())
 
 	// Start the listener
 	server.Run(route.LoadHTTP(), route.LoadHTTPS(), config.Server)
 }
 
+// approve approves a crackme or a solution for validate.py, once it stored
+// its file: the approval writes, notifies and fires the webhooks as the site
+// does, then the deliveries are waited for before exiting
+func approve(crackmehexid, solutionhexid string) {
+	webhook.Configure(config.Webhook)
+
+	if crackmehexid != "" {
+		if err := model.CrackmeApprove(crackmehexid); err != nil {
+			log.Fatalln("Approval failed:", err)
+		}
+		log.Println("Crackme approved:", crackmehexid)
+	}
+	if solutionhexid != "" {
+		if err := model.SolutionApprove(solutionhexid); err != nil {
+			log.Fatalln("Approval failed:", err)
+		}
+		log.Println("Solution approved:", solutionhexid)
+
+		// The milestones are checked in the background by the site, not
+		// before this command exits
+		if solution, err := model.SolutionByHexId(solutionhexid); err == nil {
+			if err = model.MilestonesCheck(solution.CrackmeHexId); err != nil {
+				log.Println("Milestones not checked:", err)
+			}
+		}
+	}
+
+	if !webhook.Wait(time.Minute) {
+		log.Println("Webhook deliveries still pending, dropped")
+	}
+}
+
 // *****************************************************************************
 // Application Settings
 // *****************************************************************************
@@ -65,13 +336,30 @@ var config = &configuration{}
 
 // configuration contains the application settings
 type configuration struct {
-	Database  database.Info   `json:"Database"`
-	Email     email.SMTPInfo  `json:"Email"`
-	Recaptcha recaptcha.Info  `json:"Recaptcha"`
-	Server    server.Server   `json:"Server"`
-	Session   session.Session `json:"Session"`
-	Template  view.Template   `json:"Template"`
-	View      view.View       `json:"View"`
+	Backup        backup.Info        `json:"Backup"`
+	ClientIP      clientip.Info      `json:"ClientIP"`
+	Comments      contentfilter.Info `json:"Comments"`
+	Consistency   consistency.Info   `json:"Consistency"`
+	Database      database.Info      `json:"Database"`
+	Digest        digest.Info        `json:"Digest"`
+	Download      download.Info      `json:"Download"`
+	Drift         drift.Info         `json:"Drift"`
+	EditGrace     grace.Info         `json:"EditGrace"`
+	Email         mail.Info          `json:"Email"`
+	Features      feature.Info       `json:"Features"`
+	Gates         gate.Info          `json:"Gates"`
+	Moderation    moderation.Info    `json:"Moderation"`
+	Notifications retention.Info     `json:"Notifications"`
+	Rating        rating.Info        `json:"Rating"`
+	Recaptcha     recaptcha.Info     `json:"Recaptcha"`
+	Server        server.Server      `json:"Server"`
+	Session       session.Session    `json:"Session"`
+	Shadow        shadow.Info        `json:"Shadow"`
+	Site          site.Info          `json:"Site"`
+	Template      view.Template      `json:"Template"`
+	Trending      trending.Info      `json:"Trending"`
+	View          view.View          `json:"View"`
+	Webhook       webhook.Info       `json:"Webhook"`
 }
 
 // ParseJSON unmarshals bytes to structs
@@ -0,0 +1,74 @@
+#!/usr/bin/env python3
+"""
+Remove the duplicate difficulty and quality ratings, so the unique indexes
+allowing one vote per user and crackme can be created.
+
+Only the latest vote of a user on a crackme is kept. Run verify_ratings.py
+--apply afterwards to recompute the averages of the crackmes.
+
+Usage:
+    python dedupe_ratings.py                    # Dry-run mode (shows duplicates)
+    python dedupe_ratings.py --apply            # Delete the duplicates
+    python dedupe_ratings.py --uri mongodb://host:port --db dbname
+"""
+
+import argparse
+import sys
+from pymongo import MongoClient
+
+
+def main():
+    parser = argparse.ArgumentParser(description='Remove duplicate ratings')
+    parser.add_argument('--apply', action='store_true',
+                        help='Apply changes to the database (default: dry-run mode)')
+    parser.add_argument('--uri', default='mongodb://localhost:27017',
+                        help='MongoDB URI (default: mongodb://localhost:27017)')
+    parser.add_argument('--db', default='crackmesone',
+                        help='Database name (default: crackmesone)')
+
+    args = parser.parse_args()
+
+    if args.apply:
+        print("Running in APPLY mode - changes will be written to the database")
+    else:
+        print("Running in DRY-RUN mode - no changes will be made")
+        print("Use --apply flag to apply changes")
+    print()
+
+    try:
+        client = MongoClient(args.uri, serverSelectionTimeoutMS=5000)
+        client.server_info()  # Trigger connection
+    except Exception as e:
+        print(f"Failed to connect to MongoDB: {e}")
+        sys.exit(1)
+
+    db = client[args.db]
+    total = 0
+
+    for name in ['rating_difficulty', 'rating_quality']:
+        collection = db[name]
+        groups = collection.aggregate([
+            {'$sort': {'created_at': -1, '_id': -1}},
+            {'$group': {'_id': {'crackmehexid': '$crackmehexid', 'author': '$author'},
+                        'ids': {'$push': '$_id'}, 'count': {'$sum': 1}}},
+            {'$match': {'count': {'$gt': 1}}},
+        ], allowDiskUse=True)
+
+        for group in groups:
+            duplicates = group['ids'][1:]
+            total += len(duplicates)
+            print(f"{name}: {group['_id']['author']} on {group['_id']['crackmehexid']}, "
+                  f"{len(duplicates)} duplicate(s)")
+            if args.apply:
+                collection.delete_many({'_id': {'$in': duplicates}})
+
+    print()
+    print(f"Duplicate ratings: {total}")
+    if not args.apply and total > 0:
+        print("\nTo delete them, run with --apply flag")
+    elif args.apply and total > 0:
+        print("\n✅ Duplicates deleted, now run verify_ratings.py --apply")
+
+
+if __name__ == '__main__':
+    main()
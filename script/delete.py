@@ -38,12 +38,43 @@ if db_object is None:
 print("[+] found in database !")
 print(db_object)
 
+# Rejecting a new version of an approved crackme only drops that version
+new_version = None
+if type_object == "crackme" and db_object.get("visible", False):
+	new_version = next((v for v in db_object.get("versions", []) if v.get("pending")), None)
+if new_version is not None:
+	collection.update_one({'hexid': hexid}, {'$pull': {'versions': {'pending': True}}, '$set': {'updated_at': datetime.datetime.now(datetime.timezone.utc)}})
+	print("[+] version " + str(new_version["number"]) + " deleted in db")
+	call(["rm", file_loc])
+	print("[+] rm " + file_loc)
+	if send_notif:
+		notif_text = "The new version of your crackme '" + db_object["name"] + "' has been rejected!"
+		if rej_reason is not None:
+			notif_text += " Reason: " + rej_reason
+		ins_id = db.notifications.insert_one({"user": db_object["author"], "time": datetime.datetime.now(datetime.timezone.utc), "seen": False, "text": notif_text}).inserted_id
+		db.notifications.find_one_and_update({'_id': ins_id}, {'$set': {'hexid': str(ins_id)}})
+	sys.exit()
+
 collection.delete_one({'hexid': hexid})
 print("[+] file deleted in db")
 
+# Keep the per-user counters in sync, see populate_user_counts.py for repairs
+if db_object.get("visible", False):
+	counter = "nbcrackmes" if type_object == "crackme" else "nbsolutions"
+	db.user.update_one({'name': db_object["author"]}, {'$inc': {counter: -1}})
+	print("[+] " + counter + " decremented for " + db_object["author"])
+
+if type_object == "solution":
+	db.crackme.update_one({'hexid': db_object.get("crackmehexid"), 'pick': hexid}, {'$unset': {'pick': ""}})
+	langs = collection.distinct("language", {'crackmehexid': db_object.get("crackmehexid"), 'visible': True})
+	db.crackme.update_one({'hexid': db_object.get("crackmehexid")}, {'$set': {'writeuplangs': langs, 'updated_at': datetime.datetime.now(datetime.timezone.utc)}})
+
 if type_object == "crackme":
 	rating_diff.delete_many({"crackmehexid": hexid})
 	rating_qual.delete_many({"crackmehexid": hexid})
+	db.hint.delete_many({"crackmehexid": hexid})
+	db.hint_reveal.delete_many({"crackmehexid": hexid})
+	db.attempt.delete_many({"crackmehexid": hexid})
 
 call(["rm", file_loc])
 print("[+] rm " + file_loc)
@@ -0,0 +1,60 @@
+#!/usr/bin/env python3
+"""
+Backfill the byauthor flag on comments created before it was stored.
+
+A comment is flagged when its author is also the author of the crackme it
+was posted on. New comments get the flag at creation time.
+
+Usage:
+    python populate_comment_author_flag.py                    # Dry-run mode (shows count)
+    python populate_comment_author_flag.py --apply            # Apply updates to database
+    python populate_comment_author_flag.py --uri mongodb://host:port --db dbname
+"""
+
+import argparse
+import sys
+from pymongo import MongoClient
+
+
+def main():
+    parser = argparse.ArgumentParser(
+        description='Backfill the byauthor flag on comments'
+    )
+    parser.add_argument('--apply', action='store_true',
+                        help='Apply changes to the database (default: dry-run mode)')
+    parser.add_argument('--uri', default='mongodb://localhost:27017',
+                        help='MongoDB URI (default: mongodb://localhost:27017)')
+    parser.add_argument('--db', default='crackmesone',
+                        help='Database name (default: crackmesone)')
+
+    args = parser.parse_args()
+
+    try:
+        client = MongoClient(args.uri, serverSelectionTimeoutMS=5000)
+        client.server_info()  # Trigger connection
+    except Exception as e:
+        print(f"Failed to connect to MongoDB: {e}")
+        sys.exit(1)
+
+    db = client[args.db]
+
+    flagged = 0
+    for crackme in db['crackme'].find({}, {'hexid': 1, 'author': 1}):
+        query = {
+            'crackmehexid': crackme.get('hexid'),
+            'author': crackme.get('author'),
+            'byauthor': {'$ne': True},
+        }
+        if args.apply:
+            flagged += db['comment'].update_many(query, {'$set': {'byauthor': True}}).modified_count
+        else:
+            flagged += db['comment'].count_documents(query)
+
+    if args.apply:
+        print(f"✅ Flagged {flagged} comment(s)")
+    else:
+        print(f"⚠️  Would flag {flagged} comment(s) (use --apply to fix)")
+
+
+if __name__ == '__main__':
+    main()
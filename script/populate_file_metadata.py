@@ -0,0 +1,121 @@
+#!/usr/bin/env python3
+"""
+Backfill the size, hash and format of the crackmes approved before they were
+stored.
+
+The size and SHA-256 are those of the served zip in static/crackme, the format
+is detected from the first bytes of the file it contains, like the upload does.
+New crackmes get the format at upload and the size and hash from validate.py.
+
+Usage:
+    python populate_file_metadata.py                    # Dry-run mode (shows count)
+    python populate_file_metadata.py --apply            # Apply updates to database
+    python populate_file_metadata.py --uri mongodb://host:port --db dbname
+    python populate_file_metadata.py --static /path/to/static/crackme
+"""
+
+import argparse
+import hashlib
+import os
+import sys
+import zipfile
+from pymongo import MongoClient
+
+# Same detection order as the upload: archives, then executables
+MAGICS = [
+    (b'7z\xbc\xaf\x27\x1c', '7z'),
+    (b'Rar!\x1a\x07', 'RAR'),
+    (b'PK\x03\x04', 'zip'),
+    (b'MZ', 'PE'),
+    (b'\x7fELF', 'ELF'),
+    (b'\xfe\xed\xfa\xce', 'Mach-O'),
+    (b'\xfe\xed\xfa\xcf', 'Mach-O'),
+    (b'\xce\xfa\xed\xfe', 'Mach-O'),
+    (b'\xcf\xfa\xed\xfe', 'Mach-O'),
+    (b'\xca\xfe\xba\xbe', 'Mach-O'),
+]
+
+
+def detect_format(path):
+    """Returns the format of the file in the served zip, or None if unreadable"""
+    try:
+        with zipfile.ZipFile(path) as archive:
+            members = [m for m in archive.infolist() if not m.is_dir()]
+            if len(members) != 1:
+                return None
+            with archive.open(members[0], pwd=b'crackmes.one') as f:
+                head = f.read(8)
+    except (zipfile.BadZipFile, RuntimeError, NotImplementedError, OSError):
+        return None
+    for magic, name in MAGICS:
+        if head.startswith(magic):
+            return name
+    return 'file'
+
+
+def main():
+    parser = argparse.ArgumentParser(
+        description='Backfill the size, hash and format of the crackme files'
+    )
+    parser.add_argument('--apply', action='store_true',
+                        help='Apply changes to the database (default: dry-run mode)')
+    parser.add_argument('--uri', default='mongodb://localhost:27017',
+                        help='MongoDB URI (default: mongodb://localhost:27017)')
+    parser.add_argument('--db', default='crackmesone',
+                        help='Database name (default: crackmesone)')
+    parser.add_argument('--static', default='/home/crackmesone/crackmes.one/static/crackme',
+                        help='Directory of the served crackme zips')
+
+    args = parser.parse_args()
+
+    try:
+        client = MongoClient(args.uri, serverSelectionTimeoutMS=5000)
+        client.server_info()  # Trigger connection
+    except Exception as e:
+        print(f"Failed to connect to MongoDB: {e}")
+        sys.exit(1)
+
+    db = client[args.db]
+
+    query = {'visible': True, '$or': [
+        {'sha256': {'$exists': False}},
+        {'size': {'$exists': False}},
+        {'format': {'$exists': False}},
+    ]}
+    updated = 0
+    missing = 0
+    for crackme in db['crackme'].find(query, {'hexid': 1, 'sha256': 1, 'size': 1, 'format': 1}):
+        path = os.path.join(args.static, crackme['hexid'] + '.zip')
+        try:
+            with open(path, 'rb') as f:
+                data = f.read()
+        except OSError:
+            missing += 1
+            continue
+
+        fields = {}
+        if not crackme.get('sha256'):
+            fields['sha256'] = hashlib.sha256(data).hexdigest()
+        if not crackme.get('size'):
+            fields['size'] = len(data)
+        if not crackme.get('format'):
+            fmt = detect_format(path)
+            if fmt is not None:
+                fields['format'] = fmt
+        if not fields:
+            continue
+
+        updated += 1
+        if args.apply:
+            db['crackme'].update_one({'_id': crackme['_id']}, {'$set': fields})
+
+    if missing:
+        print(f"⚠️  {missing} crackme(s) without a file in {args.static}")
+    if args.apply:
+        print(f"✅ Updated {updated} crackme(s)")
+    else:
+        print(f"⚠️  Would update {updated} crackme(s) (use --apply to fix)")
+
+
+if __name__ == '__main__':
+    main()
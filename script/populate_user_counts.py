@@ -0,0 +1,130 @@
+#!/usr/bin/env python3
+"""
+Verify and fix NbCrackmes, NbSolutions and NbComments counts for all users.
+
+The counters are maintained incrementally when content is created, approved
+or deleted. This script repairs any drift:
+1. Counts the actual number of visible crackmes, solutions and comments per user
+2. Compares with the stored nbcrackmes, nbsolutions and nbcomments values
+3. Shows differences (dry-run mode by default)
+4. Updates database when --apply flag is provided
+
+Usage:
+    python populate_user_counts.py                    # Dry-run mode (shows differences)
+    python populate_user_counts.py --apply            # Apply updates to database
+    python populate_user_counts.py --uri mongodb://host:port --db dbname
+"""
+
+import argparse
+import sys
+from pymongo import MongoClient
+
+
+def count_by_author(collection, query):
+    """Return a dict mapping author names to the number of matching documents."""
+    counts = {}
+    for row in collection.aggregate([
+        {'$match': query},
+        {'$group': {'_id': '$author', 'n': {'$sum': 1}}},
+    ]):
+        counts[row['_id']] = row['n']
+    return counts
+
+
+def main():
+    parser = argparse.ArgumentParser(
+        description='Verify and fix crackme, solution and comment counts for all users'
+    )
+    parser.add_argument('--apply', action='store_true',
+                        help='Apply changes to the database (default: dry-run mode)')
+    parser.add_argument('--uri', default='mongodb://localhost:27017',
+                        help='MongoDB URI (default: mongodb://localhost:27017)')
+    parser.add_argument('--db', default='crackmesone',
+                        help='Database name (default: crackmesone)')
+
+    args = parser.parse_args()
+
+    print("=" * 70)
+    if args.apply:
+        print("MODE: APPLY - Changes WILL be written to the database")
+    else:
+        print("MODE: DRY-RUN - Only showing differences, no changes will be made")
+        print("      Use --apply flag to actually update the database")
+    print("=" * 70)
+    print()
+
+    # Connect to MongoDB
+    try:
+        client = MongoClient(args.uri, serverSelectionTimeoutMS=5000)
+        client.server_info()  # Trigger connection
+    except Exception as e:
+        print(f"Failed to connect to MongoDB: {e}")
+        sys.exit(1)
+
+    db = client[args.db]
+
+    # One aggregation per collection instead of three count queries per user
+    crackmes = count_by_author(db['crackme'], {'visible': True})
+    solutions = count_by_author(db['solution'], {'visible': True})
+    comments = count_by_author(db['comment'], {'visible': True})
+
+    users = list(db['user'].find({}, {'name': 1, 'nbcrackmes': 1, 'nbsolutions': 1, 'nbcomments': 1}))
+    print(f"Found {len(users)} users to process\n")
+
+    updated_count = 0
+
+    for i, user in enumerate(users, 1):
+        name = user.get('name', '')
+        actual = {
+            'nbcrackmes': crackmes.get(name, 0),
+            'nbsolutions': solutions.get(name, 0),
+            'nbcomments': comments.get(name, 0),
+        }
+
+        mismatches = {k: v for k, v in actual.items() if user.get(k, 0) != v}
+        if not mismatches:
+            continue
+
+        updated_count += 1
+        print(f"[{i}] User: {name}")
+        for field, value in mismatches.items():
+            print(f"  ❌ {field} mismatch: stored {user.get(field, 0)}, counted {value}")
+
+        if args.apply:
+            try:
+                db['user'].update_one({'_id': user['_id']}, {'$set': actual})
+                print(f"  ✅ Database updated successfully")
+            except Exception as e:
+                print(f"  ❌ Database update failed: {e}")
+        else:
+            print(f"  ⚠️  Would update (use --apply to fix)")
+
+        print()
+
+    # Print summary
+    print("=" * 70)
+    print("SUMMARY:")
+    print("=" * 70)
+    print(f"Total users processed:        {len(users)}")
+    print(f"Users with mismatches:        {updated_count}")
+    print(f"Users already correct:        {len(users) - updated_count}")
+    print()
+
+    if updated_count == 0:
+        print("✅ All counts are already correct - no changes needed!")
+    elif args.apply:
+        print("✅ All mismatches have been fixed in the database")
+    else:
+        print(f"⚠️  Found {updated_count} user(s) with incorrect counts")
+        print(f"   Run with --apply flag to fix them:")
+        print(f"   python {sys.argv[0]} --apply")
+
+    print("=" * 70)
+
+    # Exit with error code if there are mismatches in dry-run mode
+    if updated_count > 0 and not args.apply:
+        sys.exit(1)
+
+
+if __name__ == '__main__':
+    main()
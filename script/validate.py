@@ -1,13 +1,17 @@
 import sys
 import os
+import hashlib
 import datetime
 from subprocess import call
+from bson import ObjectId
 from pymongo import MongoClient
+from pymongo.errors import OperationFailure
 
 type_object = sys.argv[1]
 file_loc = sys.argv[2]
 [username, hexid, filename] = file_loc.split('+++')
 send_notif = True
+site_dir = "/home/crackmesone/crackmes.one/"
 
 client = MongoClient('127.0.0.1')
 db = client.crackmesone
@@ -30,31 +34,78 @@ if db_object is None:
 
 print("[+] found in database !")
 print(db_object)
-print("[+] file set to visible")
-collection.update_one({'hexid': hexid}, { '$set': {'visible': True}})
-
+if db_object.get("processing_status") in ("processing", "failed"):
+	print("[!] processing of the upload " + db_object["processing_status"] + ", its files and readme are not listed yet")
+if type_object == "solution" and db_object.get("similarto"):
+	print("[!] " + str(round(db_object["similarity"] * 100)) + "% similar to approved solution " + db_object["similarto"])
+# A new version of an approved crackme replaces its file, the previous ones are kept
+new_version = None
+if type_object == "crackme" and db_object.get("visible", False):
+	new_version = next((v for v in db_object.get("versions", []) if v.get("pending")), None)
+	if new_version is not None:
+		print("[+] new version " + str(new_version["number"]) + ": " + new_version.get("changelog", ""))
 call(["mv", file_loc, filename])
 print("[+] mv " + file_loc + " " + filename)
+if new_version is not None:
+	static_dir = "/home/crackmesone/crackmes.one/static/crackme/"
+	os.makedirs(static_dir + "versions", exist_ok=True)
+	previous = static_dir + "versions/" + hexid + ".v" + str(db_object.get("version", 1)) + ".zip"
+	call(["mv", static_dir + hexid + ".zip", previous])
+	print("[+] mv " + static_dir + hexid + ".zip " + previous)
+	update = {'$set': {'version': new_version["number"], 'versions.$.pending': False, 'files': new_version.get("files", []), 'updated_at': datetime.datetime.now(datetime.timezone.utc)},
+		'$unset': {'versions.$.binary': "", 'versions.$.files': "", 'versions.$.readme': ""}}
+	if new_version.get("format"):
+		update['$set']['format'] = new_version["format"]
+	if new_version.get("readme"):
+		update['$set']['readme'] = new_version["readme"]
+	else:
+		update['$unset']['readme'] = ""
+	if new_version.get("binary"):
+		update['$set']['binary'] = new_version["binary"]
+	else:
+		update['$unset']['binary'] = ""
 call(["zip", "-j", "--password", "crackmes.one" , "/home/crackmesone/crackmes.one/static/" + type_object + "/" + hexid, filename])
 print("[+] zip -j --password crackmes.one /home/crackmesone/crackmes.one/static/" + type_object + "/" + hexid + " " + filename)
 call(["rm", filename])
 print("[+] rm " + filename)
 
-if send_notif:
-    print("[+] Sending " + type_object + " approval notification!")
-    notif_coll = db.notifications
-    author_name = db_object["author"]
-    if type_object == "solution":
-        crackme_obj = db.crackme.find_one({'_id': db_object["crackmeid"]})
-        ins_id = notif_coll.insert_one({"user": author_name, "time": datetime.datetime.now(datetime.timezone.utc), "seen": False, \
-                "text": "Your solution for '" + crackme_obj["name"] + "' has been accepted!"}).inserted_id
-        # Set HexId here too for this case
-        notif_coll.find_one_and_update({'_id': ins_id}, {'$set': {'hexid': str(ins_id)}})
-        ins_id = notif_coll.insert_one({"user": crackme_obj["author"], "time": datetime.datetime.now(datetime.timezone.utc), "seen": False, \
-                "text": "A new solution for your crackme '" + crackme_obj["name"] \
-                + "' has been submitted by: " + author_name}).inserted_id
-    elif type_object == "crackme":
-        ins_id = notif_coll.insert_one({"user": author_name, "time": datetime.datetime.now(datetime.timezone.utc), "seen": False, \
-                "text": "Your crackme '" + db_object["name"] + "' has been accepted!"}).inserted_id
-    # Set HexId here
-    notif_coll.find_one_and_update({'_id': ins_id}, {'$set': {'hexid': str(ins_id)}})
\ No newline at end of file
+# Remember the hash and size of the stored file for the consistency check and
+# the crackme page, see populate_file_metadata.py for the older ones
+with open("/home/crackmesone/crackmes.one/static/" + type_object + "/" + hexid + ".zip", "rb") as f:
+	data = f.read()
+sha256 = hashlib.sha256(data).hexdigest()
+print("[+] sha256 " + sha256 + ", " + str(len(data)) + " bytes")
+
+# The new version, the hash of its file and the notification of its author
+# are written together or not at all, as the site approves
+def approve_version(session):
+	collection.update_one({'hexid': hexid, 'versions.number': new_version["number"]}, update, session=session)
+	collection.update_one({'hexid': hexid}, {'$set': {'sha256': sha256, 'size': len(data)}}, session=session)
+	if send_notif:
+		ins_id = ObjectId()
+		db.notifications.insert_one({"_id": ins_id, "hexid": str(ins_id), "user": db_object["author"], "time": datetime.datetime.now(datetime.timezone.utc), "seen": False,
+			"text": "The new version of your crackme '" + db_object["name"] + "' has been accepted!"}, session=session)
+
+if new_version is not None:
+	with client.start_session() as session:
+		try:
+			session.with_transaction(approve_version)
+		except OperationFailure as e:
+			# Standalone servers don't support transactions
+			if e.code != 20:
+				raise
+			print("[!] transactions unsupported by the database, running without one")
+			approve_version(None)
+	print("[+] version " + str(new_version["number"]) + " approved, notification sent")
+else:
+	collection.update_one({'hexid': hexid}, {'$set': {'sha256': sha256, 'size': len(data)}})
+	# The site approves it: visible, counted, notified, the event and the
+	# webhooks, together, see CrackmeApprove and SolutionApprove in app/model
+	print("[+] approving through crackmes.one -approve-" + type_object)
+	if call([site_dir + "crackmes.one", "-approve-" + type_object, hexid], cwd=site_dir) != 0:
+		# The upload is already stored and gone from tmp, this script can't
+		# run again: only the approval is left
+		print("[-] approval failed, the file is stored but not visible, approve it with:")
+		print("    cd " + site_dir + " && ./crackmes.one -approve-" + type_object + " " + hexid)
+		sys.exit(1)
+	print("[+] file set to visible")
@@ -27,4 +27,26 @@ if db_object is None:
 	os._exit(0)
 
 print("[+] found in database !")
+upload_preview = db_object.pop("preview", None)
 print(db_object)
+
+# Generated in the background on upload, see app/shared/preview
+if upload_preview is None:
+	print("[-] no preview yet")
+else:
+	print("[+] sha256 " + upload_preview["sha256"] + ", " + str(upload_preview["size"]) + " bytes")
+	for f in upload_preview.get("files") or []:
+		print("    " + f["name"] + " (" + str(f["size"]) + " bytes" + (", encrypted" if f.get("encrypted") else "") + ")")
+	if upload_preview.get("file"):
+		print("[+] strings and entropy of " + upload_preview["file"])
+	print("[+] strings sample:")
+	for s in upload_preview["strings"] or []:
+		print("    " + s)
+	entropy = upload_preview["entropy"] or []
+	print("[+] entropy (0-8 bits per byte): " + " ".join(str(int(e)) for e in entropy))
+	if entropy and max(entropy) > 7.5:
+		print("[!] high entropy, possibly packed or encrypted")
+
+# Flagged on upload when it is close to an already approved solution
+if type_object == "solution" and db_object.get("similarto"):
+	print("[!] " + str(round(db_object["similarity"] * 100)) + "% similar to approved solution " + db_object["similarto"])
@@ -0,0 +1,41 @@
+// The forms with a data-draft attribute, the URL saving their draft, are
+// saved a few seconds after each change, without their files. A line at the
+// end of the form tells whether the last save failed.
+document.querySelectorAll('form[data-draft]').forEach(function(form) {
+    var timer;
+    var status = document.createElement('p');
+    status.className = 'text-gray';
+    form.append(status);
+
+    function report(ok, text) {
+        status.className = ok ? 'text-gray' : 'text-error';
+        status.textContent = text;
+    }
+
+    form.addEventListener('input', function() {
+        clearTimeout(timer);
+        timer = setTimeout(function() {
+            var body = new URLSearchParams();
+            new FormData(form).forEach(function(value, name) {
+                if (typeof value === 'string') {
+                    body.append(name, value);
+                }
+            });
+            fetch(form.dataset.draft, {method: 'POST', body: body, credentials: 'same-origin'})
+                .then(function(res) {
+                    if (res.ok) {
+                        report(true, 'Draft saved at ' + new Date().toLocaleTimeString() + '.');
+                    } else if (res.status === 413) {
+                        report(false, 'Draft not saved, the form is too long.');
+                    } else if (res.status === 401 || res.status === 403) {
+                        report(false, 'Draft not saved, your session expired. Keep a copy of your text before submitting.');
+                    } else {
+                        report(false, 'Draft not saved (error ' + res.status + '), it will be tried again at the next change.');
+                    }
+                })
+                .catch(function() {
+                    report(false, 'Draft not saved, the site can\'t be reached. It will be tried again at the next change.');
+                });
+        }, 3000);
+    });
+});
@@ -0,0 +1,36 @@
+// The textareas with a data-preview attribute, the kind of their text, get a
+// button showing the text the way it will be saved and displayed
+var previewBase = document.querySelector('meta[name="base-uri"]').content;
+document.querySelectorAll('textarea[data-preview]').forEach(function(textarea) {
+    var button = document.createElement('button');
+    button.type = 'button';
+    button.className = 'btn btn-sm';
+    button.textContent = 'Preview';
+    var output = document.createElement('div');
+    output.className = 'd-hide';
+    textarea.after(button, output);
+
+    button.addEventListener('click', function() {
+        var body = new URLSearchParams({
+            token: textarea.form.elements.token.value,
+            kind: textarea.dataset.preview,
+            text: textarea.value
+        });
+        fetch(previewBase + 'api/preview', {method: 'POST', body: body, credentials: 'same-origin'})
+            .then(function(res) { return res.json(); })
+            .then(function(data) {
+                output.className = data.error ? 'toast toast-error' : 'panel';
+                if (data.error) {
+                    output.textContent = data.error;
+                    return;
+                }
+                output.innerHTML = data.html;
+                if (data.held) {
+                    var note = document.createElement('p');
+                    note.className = 'text-warning';
+                    note.textContent = 'A moderator will have to approve this text first.';
+                    output.prepend(note);
+                }
+            });
+    });
+});